@@ -189,7 +189,7 @@ mod tests {
         sync::{mpsc, mpsc::UnboundedReceiver},
     };
     use turborepo_api_client::{analytics::AnalyticsClient, APIAuth};
-    use turborepo_vercel_api::{AnalyticsEvent, CacheEvent, CacheSource};
+    use turborepo_vercel_api::{AnalyticsEvent, CacheEvent, CacheSource, RunCacheAnalytics};
 
     use crate::start_analytics;
 
@@ -217,6 +217,14 @@ mod tests {
 
             Ok(())
         }
+
+        async fn record_run_analytics(
+            &self,
+            _api_auth: &APIAuth,
+            _analytics: &RunCacheAnalytics,
+        ) -> Result<(), turborepo_api_client::Error> {
+            unimplemented!()
+        }
     }
 
     // Asserts that we get the message after the timeout
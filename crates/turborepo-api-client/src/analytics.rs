@@ -1,7 +1,7 @@
 use std::future::Future;
 
 use reqwest::Method;
-pub use turborepo_vercel_api::{AnalyticsEvent, CacheEvent, CacheSource};
+pub use turborepo_vercel_api::{AnalyticsEvent, CacheEvent, CacheSource, RunCacheAnalytics};
 
 use crate::{retry, APIAuth, APIClient, Error};
 
@@ -11,6 +11,12 @@ pub trait AnalyticsClient {
         api_auth: &APIAuth,
         events: Vec<AnalyticsEvent>,
     ) -> impl Future<Output = Result<(), Error>> + Send;
+
+    fn record_run_analytics(
+        &self,
+        api_auth: &APIAuth,
+        analytics: &RunCacheAnalytics,
+    ) -> impl Future<Output = Result<(), Error>> + Send;
 }
 
 impl AnalyticsClient for APIClient {
@@ -25,10 +31,37 @@ impl AnalyticsClient for APIClient {
             .await?
             .json(&events);
 
-        retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
+        retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
+
+        Ok(())
+    }
+
+    #[tracing::instrument(skip_all)]
+    async fn record_run_analytics(
+        &self,
+        api_auth: &APIAuth,
+        analytics: &RunCacheAnalytics,
+    ) -> Result<(), Error> {
+        let request_builder = self
+            .create_request_builder("/v8/artifacts/analytics", api_auth, Method::POST)
             .await?
-            .into_response()
-            .error_for_status()?;
+            .json(analytics);
+
+        retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
 
         Ok(())
     }
@@ -15,6 +15,8 @@ pub enum Error {
     TooManyFailures(#[from] Box<reqwest::Error>),
     #[error("Unable to set up TLS.")]
     TlsError(#[source] reqwest::Error),
+    #[error("No PEM encoded certificates found in the certificate authority bundle.")]
+    NoCaCertificates,
     #[error("Error parsing header: {0}")]
     InvalidHeader(#[from] ToStrError),
     #[error("Error parsing '{url}' as URL: {err}")]
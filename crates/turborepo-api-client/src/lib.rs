@@ -13,12 +13,15 @@ use serde::Deserialize;
 use turborepo_ci::{is_ci, Vendor};
 use turborepo_vercel_api::{
     token::ResponseTokenMetadata, APIError, CachingStatus, CachingStatusResponse,
-    PreflightResponse, SpacesResponse, Team, TeamsResponse, UserResponse, VerificationResponse,
-    VerifiedSsoUser,
+    DeviceCodeResponse, PreflightResponse, SpacesResponse, Team, TeamsResponse, UserResponse,
+    VerificationResponse, VerifiedSsoUser,
 };
 use url::Url;
 
-pub use crate::error::{Error, Result};
+pub use crate::{
+    error::{Error, Result},
+    retry::{ArtifactTransferStats, ArtifactTransferTracker, RetryPolicy},
+};
 
 pub mod analytics;
 mod error;
@@ -29,6 +32,11 @@ pub mod telemetry;
 pub use bytes::Bytes;
 pub use tokio_stream::Stream;
 
+// How long an idle connection to the cache is kept around for reuse
+const CACHE_POOL_IDLE_TIMEOUT: Duration = Duration::from_secs(90);
+// Artifacts get the full download timeout for every started chunk of this size
+const DOWNLOAD_TIMEOUT_CHUNK_SIZE: u64 = 100 * 1024 * 1024;
+
 lazy_static! {
     static ref AUTHORIZATION_REGEX: Regex =
         Regex::new(r"(?i)(?:^|,) *authorization *(?:,|$)").unwrap();
@@ -73,17 +81,21 @@ pub trait CacheClient {
         team_id: Option<&str>,
         team_slug: Option<&str>,
     ) -> impl Future<Output = Result<Option<Response>>> + Send;
+    /// Uploads an artifact. `artifact_body` is called for every attempt so
+    /// that failed uploads can be retried with a fresh stream.
     #[allow(clippy::too_many_arguments)]
-    fn put_artifact(
+    fn put_artifact<S>(
         &self,
         hash: &str,
-        artifact_body: impl tokio_stream::Stream<Item = Result<bytes::Bytes>> + Send + Sync + 'static,
+        artifact_body: impl Fn() -> S + Send + Sync,
         duration: u64,
         tag: Option<&str>,
         token: &str,
         team_id: Option<&str>,
         team_slug: Option<&str>,
-    ) -> impl Future<Output = Result<()>> + Send;
+    ) -> impl Future<Output = Result<()>> + Send
+    where
+        S: tokio_stream::Stream<Item = Result<bytes::Bytes>> + Send + Sync + 'static;
     fn artifact_exists(
         &self,
         hash: &str,
@@ -107,13 +119,115 @@ pub trait TokenClient {
     fn delete_token(&self, token: &str) -> impl Future<Output = Result<()>> + Send;
 }
 
+/// The state of a device code login after polling for its token.
+#[derive(Debug, Clone, PartialEq)]
+pub enum DeviceTokenStatus {
+    /// The user hasn't approved the login yet
+    Pending,
+    /// We're polling too often and should increase the interval
+    SlowDown,
+    /// The device code expired before it was approved
+    Expired,
+    /// The user rejected the login
+    Denied,
+    Authorized(String),
+}
+
+pub trait DeviceCodeClient {
+    fn request_device_code(
+        &self,
+        team_id: Option<&str>,
+    ) -> impl Future<Output = Result<DeviceCodeResponse>> + Send;
+    fn poll_device_token(
+        &self,
+        device_code: &str,
+    ) -> impl Future<Output = Result<DeviceTokenStatus>> + Send;
+}
+
+/// Timeouts for transferring artifacts to and from the remote cache. Unset
+/// timeouts don't limit the transfer.
+#[derive(Debug, Default, Clone, Copy, PartialEq, Eq)]
+pub struct CacheTimeouts {
+    /// Time allowed to connect to the remote cache
+    pub connect: Option<Duration>,
+    /// Time allowed to upload an artifact
+    pub upload: Option<Duration>,
+    /// Time allowed to download every started 100MB of an artifact
+    pub download: Option<Duration>,
+}
+
+impl CacheTimeouts {
+    /// Returns the time allowed to download an artifact of `size` bytes, or
+    /// the time allowed for a single chunk if the size isn't known.
+    pub fn download_timeout(&self, size: Option<u64>) -> Option<Duration> {
+        let chunks = size.map_or(1, |size| size.div_ceil(DOWNLOAD_TIMEOUT_CHUNK_SIZE).max(1));
+        self.download
+            .map(|download| download.saturating_mul(u32::try_from(chunks).unwrap_or(u32::MAX)))
+    }
+}
+
+/// TLS settings for requests made by the API client. Proxies are configured
+/// with the standard `HTTPS_PROXY`, `HTTP_PROXY`, and `NO_PROXY` variables.
+#[derive(Debug, Default, Clone, PartialEq, Eq)]
+pub struct TlsOptions {
+    /// PEM encoded certificate authorities to trust in addition to the
+    /// system's
+    pub ca_cert: Option<String>,
+    /// Accept invalid certificates. This should only be used for debugging.
+    pub insecure_skip_verify: bool,
+}
+
+impl TlsOptions {
+    #[cfg(any(feature = "native-tls", feature = "rustls-tls"))]
+    fn apply(&self, builder: reqwest::ClientBuilder) -> Result<reqwest::ClientBuilder> {
+        const END_CERTIFICATE: &str = "-----END CERTIFICATE-----";
+
+        let mut builder = builder.danger_accept_invalid_certs(self.insecure_skip_verify);
+        if let Some(ca_cert) = &self.ca_cert {
+            // A bundle can contain several certificates, but each one has to be
+            // parsed separately
+            let pems = ca_cert
+                .split_inclusive(END_CERTIFICATE)
+                .filter(|pem| pem.contains(END_CERTIFICATE))
+                .collect::<Vec<_>>();
+            // Otherwise a file that isn't a bundle would silently trust nothing
+            if pems.is_empty() {
+                return Err(Error::NoCaCertificates);
+            }
+            for pem in pems {
+                let certificate = reqwest::Certificate::from_pem(pem.trim().as_bytes())
+                    .map_err(Error::TlsError)?;
+                builder = builder.add_root_certificate(certificate);
+            }
+        }
+        Ok(builder)
+    }
+
+    #[cfg(not(any(feature = "native-tls", feature = "rustls-tls")))]
+    fn apply(&self, builder: reqwest::ClientBuilder) -> Result<reqwest::ClientBuilder> {
+        Ok(builder)
+    }
+}
+
+/// The kind of request sent by `APIClient::send_artifact_request`
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum ArtifactRequest {
+    Exists,
+    Download,
+    Upload,
+    Delete,
+}
+
 #[derive(Clone)]
 pub struct APIClient {
     client: reqwest::Client,
     cache_client: reqwest::Client,
+    cache_timeouts: CacheTimeouts,
     base_url: String,
     user_agent: String,
     use_preflight: bool,
+    retry_policy: RetryPolicy,
+    artifact_transfers: ArtifactTransferTracker,
 }
 
 #[derive(Clone)]
@@ -138,11 +252,14 @@ impl Client for APIClient {
             .header("User-Agent", self.user_agent.clone())
             .header("Authorization", format!("Bearer {}", token))
             .header("Content-Type", "application/json");
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response()
-                .error_for_status()?;
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
 
         Ok(response.json().await?)
     }
@@ -155,11 +272,14 @@ impl Client for APIClient {
             .header("Content-Type", "application/json")
             .header("Authorization", format!("Bearer {}", token));
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response()
-                .error_for_status()?;
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
 
         Ok(response.json().await?)
     }
@@ -202,11 +322,14 @@ impl Client for APIClient {
             .header("Content-Type", "application/json")
             .header("Authorization", format!("Bearer {}", token));
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response()
-                .error_for_status()?;
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
 
         Ok(response.json().await?)
     }
@@ -218,11 +341,14 @@ impl Client for APIClient {
             .query(&[("token", token), ("tokenName", token_name)])
             .header("User-Agent", self.user_agent.clone());
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response()
-                .error_for_status()?;
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
 
         let verification_response: VerificationResponse = response.json().await?;
 
@@ -311,8 +437,15 @@ impl CacheClient for APIClient {
             request_url = preflight_response.location;
         };
 
-        let mut request_builder = self
-            .client
+        let is_download = method == Method::GET;
+        // Downloads aren't limited by the client timeout, the caller limits
+        // them based on the size of the artifact
+        let client = if is_download {
+            &self.cache_client
+        } else {
+            &self.client
+        };
+        let mut request_builder = client
             .request(method, request_url)
             .header("User-Agent", self.user_agent.clone());
 
@@ -322,9 +455,16 @@ impl CacheClient for APIClient {
 
         request_builder = Self::add_team_params(request_builder, team_id, team_slug);
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout).await?;
-        let response = response.into_response();
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await;
+        if is_download {
+            self.artifact_transfers.record(&response);
+        }
+        let response = response?.into_response();
 
         match response.status() {
             StatusCode::FORBIDDEN => Err(Self::handle_403(response).await),
@@ -358,16 +498,19 @@ impl CacheClient for APIClient {
     }
 
     #[tracing::instrument(skip_all)]
-    async fn put_artifact(
+    async fn put_artifact<S>(
         &self,
         hash: &str,
-        artifact_body: impl tokio_stream::Stream<Item = Result<bytes::Bytes>> + Send + Sync + 'static,
+        artifact_body: impl Fn() -> S + Send + Sync,
         duration: u64,
         tag: Option<&str>,
         token: &str,
         team_id: Option<&str>,
         team_slug: Option<&str>,
-    ) -> Result<()> {
+    ) -> Result<()>
+    where
+        S: tokio_stream::Stream<Item = Result<bytes::Bytes>> + Send + Sync + 'static,
+    {
         let mut request_url = self.make_url(&format!("/v8/artifacts/{}", hash))?;
         let mut allow_auth = true;
 
@@ -385,15 +528,16 @@ impl CacheClient for APIClient {
             request_url = preflight_response.location.clone();
         }
 
-        let stream = Body::wrap_stream(artifact_body);
-
         let mut request_builder = self
             .cache_client
             .put(request_url)
             .header("Content-Type", "application/octet-stream")
             .header("x-artifact-duration", duration.to_string())
-            .header("User-Agent", self.user_agent.clone())
-            .body(stream);
+            .header("User-Agent", self.user_agent.clone());
+
+        if let Some(upload_timeout) = self.cache_timeouts.upload {
+            request_builder = request_builder.timeout(upload_timeout);
+        }
 
         if allow_auth {
             request_builder = request_builder.header("Authorization", format!("Bearer {}", token));
@@ -407,10 +551,21 @@ impl CacheClient for APIClient {
             request_builder = request_builder.header("x-artifact-tag", tag);
         }
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Connection)
-                .await?
-                .into_response();
+        // The body is a stream which can't be cloned, so we attach a new one
+        // on every attempt
+        let response = retry::make_retryable_request_with(
+            || {
+                request_builder
+                    .try_clone()
+                    .expect("request without a body can be cloned")
+                    .body(Body::wrap_stream(artifact_body()))
+            },
+            retry::RetryStrategy::Connection,
+            &self.retry_policy,
+        )
+        .await;
+        self.artifact_transfers.record(&response);
+        let response = response?.into_response();
 
         if response.status() == StatusCode::FORBIDDEN {
             return Err(Self::handle_403(response).await);
@@ -435,14 +590,90 @@ impl CacheClient for APIClient {
 
         let request_builder = Self::add_team_params(request_builder, team_id, team_slug);
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response()
-                .error_for_status()?;
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
+
+        Ok(response.json().await?)
+    }
+}
+
+impl DeviceCodeClient for APIClient {
+    async fn request_device_code(&self, team_id: Option<&str>) -> Result<DeviceCodeResponse> {
+        let mut request_builder = self
+            .client
+            .post(self.make_url("/registration/device")?)
+            .header("User-Agent", self.user_agent.clone());
+        if let Some(team_id) = team_id {
+            request_builder = request_builder.query(&[("teamId", team_id)]);
+        }
+
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response()
+        .error_for_status()?;
 
         Ok(response.json().await?)
     }
+
+    async fn poll_device_token(&self, device_code: &str) -> Result<DeviceTokenStatus> {
+        let request_builder = self
+            .client
+            .post(self.make_url("/registration/device/token")?)
+            .query(&[("deviceCode", device_code)])
+            .header("User-Agent", self.user_agent.clone());
+
+        #[derive(Deserialize)]
+        struct TokenResponse {
+            token: String,
+        }
+        #[derive(Deserialize)]
+        struct ErrorResponse {
+            error: String,
+        }
+
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response();
+
+        // Follows the OAuth device authorization grant, a pending login is
+        // reported as a 400 with an error code in the body.
+        match response.status() {
+            StatusCode::OK => Ok(DeviceTokenStatus::Authorized(
+                response.json::<TokenResponse>().await?.token,
+            )),
+            StatusCode::BAD_REQUEST => {
+                let text = response.text().await?;
+                let body: ErrorResponse =
+                    serde_json::from_str(&text).map_err(|err| Error::InvalidJson { err, text })?;
+                match body.error.as_str() {
+                    "authorization_pending" => Ok(DeviceTokenStatus::Pending),
+                    "slow_down" => Ok(DeviceTokenStatus::SlowDown),
+                    "expired_token" => Ok(DeviceTokenStatus::Expired),
+                    "access_denied" => Ok(DeviceTokenStatus::Denied),
+                    code => Err(Error::UnknownStatus {
+                        code: code.to_string(),
+                        message: "unexpected device token response".to_string(),
+                        backtrace: Backtrace::capture(),
+                    }),
+                }
+            }
+            _ => Err(response.error_for_status().unwrap_err().into()),
+        }
+    }
 }
 
 impl TokenClient for APIClient {
@@ -472,8 +703,12 @@ impl TokenClient for APIClient {
             invalid_token: bool,
         }
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout).await?;
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?;
         let response = response.into_response();
         let status = response.status();
         // Give a better error message for invalid tokens. This endpoint returns the
@@ -526,10 +761,13 @@ impl TokenClient for APIClient {
             invalid_token: bool,
         }
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response();
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response();
         let status = response.status();
         // Give a better error message for invalid tokens. This endpoint returns the
         // following statuses:
@@ -566,21 +804,22 @@ impl APIClient {
     /// # Arguments
     /// `base_url` - The base URL for the API.
     /// `timeout` - The timeout for requests.
-    /// `upload_timeout` - If specified, uploading files will use `timeout` for
-    ///                    the connection, and `upload_timeout` for the total.
-    ///                    Otherwise, `timeout` will be used for the total.
+    /// `cache_timeouts` - The timeouts for artifact transfers.
     /// `version` - The version of the client.
     /// `use_preflight` - If true, use the preflight API for all requests.
+    /// `tls` - Certificate authorities to trust and whether to verify
+    /// certificates.
     pub fn new(
         base_url: impl AsRef<str>,
         timeout: Option<Duration>,
-        upload_timeout: Option<Duration>,
+        cache_timeouts: CacheTimeouts,
         version: &str,
         use_preflight: bool,
+        tls: &TlsOptions,
     ) -> Result<Self> {
         // for the api client, the timeout applies for the entire duration
         // of the request, including the connection phase
-        let client = reqwest::Client::builder();
+        let client = tls.apply(reqwest::Client::builder())?;
         let client = if let Some(dur) = timeout {
             client.timeout(dur)
         } else {
@@ -589,14 +828,19 @@ impl APIClient {
         .build()
         .map_err(Error::TlsError)?;
 
-        // for the cache client, the timeout applies only to the request
-        // connection time, while the upload timeout applies to the entire
-        // request
-        let cache_client = reqwest::Client::builder();
-        let cache_client = match (timeout, upload_timeout) {
-            (Some(dur), Some(upload_dur)) => cache_client.connect_timeout(dur).timeout(upload_dur),
-            (Some(dur), None) | (None, Some(dur)) => cache_client.timeout(dur),
-            (None, None) => cache_client,
+        // for the cache client, only the connection time is limited for every
+        // request. Uploads and downloads set their own timeouts since they take
+        // much longer for large artifacts. Artifacts tend to be transferred in
+        // bursts, so idle connections are kept alive to avoid paying for a new
+        // handshake on every transfer.
+        let cache_client = tls
+            .apply(reqwest::Client::builder())?
+            .pool_idle_timeout(CACHE_POOL_IDLE_TIMEOUT)
+            .tcp_keepalive(CACHE_POOL_IDLE_TIMEOUT);
+        let cache_client = if let Some(dur) = cache_timeouts.connect {
+            cache_client.connect_timeout(dur)
+        } else {
+            cache_client
         }
         .build()
         .map_err(Error::TlsError)?;
@@ -605,12 +849,65 @@ impl APIClient {
         Ok(APIClient {
             client,
             cache_client,
+            cache_timeouts,
             base_url: base_url.as_ref().to_string(),
             user_agent,
             use_preflight,
+            retry_policy: RetryPolicy::default(),
+            artifact_transfers: ArtifactTransferTracker::default(),
         })
     }
 
+    /// The client used for artifact transfers. It trusts the configured
+    /// certificate authorities and only limits the time to connect, so other
+    /// caches can share it.
+    pub fn cache_client(&self) -> &reqwest::Client {
+        &self.cache_client
+    }
+
+    /// Sends a request for an artifact that isn't stored in the Vercel Remote
+    /// Cache, e.g. in an S3 bucket, with the same retries as the requests of
+    /// this client. `make_request` is called for every attempt, so that
+    /// streamed bodies can be attached again. Uploads and downloads are
+    /// counted by `artifact_transfers`. The status of the response isn't
+    /// checked.
+    pub async fn send_artifact_request(
+        &self,
+        make_request: impl Fn() -> RequestBuilder,
+        kind: ArtifactRequest,
+    ) -> Result<Response> {
+        let strategy = match kind {
+            // Uploads can't be retried after a timeout since part of the
+            // artifact may have been written
+            ArtifactRequest::Upload => retry::RetryStrategy::Connection,
+            ArtifactRequest::Exists | ArtifactRequest::Download | ArtifactRequest::Delete => {
+                retry::RetryStrategy::Timeout
+            }
+        };
+        let response =
+            retry::make_retryable_request_with(make_request, strategy, &self.retry_policy).await;
+        if matches!(kind, ArtifactRequest::Download | ArtifactRequest::Upload) {
+            self.artifact_transfers.record(&response);
+        }
+        Ok(response?.into_response())
+    }
+
+    /// Sets the policy used to retry failed requests
+    pub fn with_retry_policy(mut self, retry_policy: RetryPolicy) -> Self {
+        self.retry_policy = retry_policy;
+        self
+    }
+
+    /// Returns a handle to the counters of retried and failed artifact
+    /// transfers made by this client and its clones.
+    pub fn artifact_transfers(&self) -> ArtifactTransferTracker {
+        self.artifact_transfers.clone()
+    }
+
+    pub fn cache_timeouts(&self) -> CacheTimeouts {
+        self.cache_timeouts
+    }
+
     pub fn base_url(&self) -> &str {
         self.base_url.as_str()
     }
@@ -630,10 +927,13 @@ impl APIClient {
             .header("Access-Control-Request-Headers", request_headers)
             .header("Authorization", format!("Bearer {}", token));
 
-        let response =
-            retry::make_retryable_request(request_builder, retry::RetryStrategy::Timeout)
-                .await?
-                .into_response();
+        let response = retry::make_retryable_request(
+            request_builder,
+            retry::RetryStrategy::Timeout,
+            &self.retry_policy,
+        )
+        .await?
+        .into_response();
 
         let headers = response.headers();
         let location = if let Some(location) = headers.get("Location") {
@@ -760,16 +1060,22 @@ impl AnonAPIClient {
         format!("{}{}", self.base_url, endpoint)
     }
 
-    pub fn new(base_url: impl AsRef<str>, timeout: u64, version: &str) -> Result<Self> {
+    /// `tls` - Certificate authorities to trust and whether to verify
+    /// certificates, the same as for `APIClient`.
+    pub fn new(
+        base_url: impl AsRef<str>,
+        timeout: u64,
+        version: &str,
+        tls: &TlsOptions,
+    ) -> Result<Self> {
+        let client_build = tls.apply(reqwest::Client::builder())?;
         let client_build = if timeout != 0 {
-            reqwest::Client::builder()
-                .timeout(Duration::from_secs(timeout))
-                .build()
+            client_build.timeout(Duration::from_secs(timeout))
         } else {
-            reqwest::Client::builder().build()
+            client_build
         };
 
-        let client = client_build.map_err(Error::TlsError)?;
+        let client = client_build.build().map_err(Error::TlsError)?;
 
         let user_agent = build_user_agent(version);
         Ok(AnonAPIClient {
@@ -798,7 +1104,38 @@ mod test {
     use turborepo_vercel_api_mock::start_test_server;
     use url::Url;
 
-    use crate::{APIClient, Client};
+    use crate::{
+        APIClient, AnonAPIClient, ArtifactRequest, ArtifactTransferStats, CacheTimeouts, Client,
+        Error, RetryPolicy, TlsOptions,
+    };
+
+    #[cfg(any(feature = "native-tls", feature = "rustls-tls"))]
+    #[test]
+    fn test_ca_cert_without_certificates() {
+        let result = APIClient::new(
+            "http://localhost",
+            None,
+            CacheTimeouts::default(),
+            "2.0.0",
+            false,
+            &TlsOptions {
+                ca_cert: Some("not a certificate".to_string()),
+                insecure_skip_verify: false,
+            },
+        );
+        assert!(matches!(result, Err(Error::NoCaCertificates)));
+
+        let result = AnonAPIClient::new(
+            "http://localhost",
+            0,
+            "2.0.0",
+            &TlsOptions {
+                ca_cert: Some("not a certificate".to_string()),
+                insecure_skip_verify: false,
+            },
+        );
+        assert!(matches!(result, Err(Error::NoCaCertificates)));
+    }
 
     #[tokio::test]
     async fn test_do_preflight() -> Result<()> {
@@ -809,9 +1146,10 @@ mod test {
         let client = APIClient::new(
             &base_url,
             Some(Duration::from_secs(200)),
-            None,
+            CacheTimeouts::default(),
             "2.0.0",
             true,
+            &TlsOptions::default(),
         )?;
 
         let response = client
@@ -888,4 +1226,93 @@ mod test {
         let err = APIClient::handle_403(response).await;
         assert_eq!(err.to_string(), "unknown status forbidden: Not authorized");
     }
+
+    #[test]
+    fn test_download_timeout_scales_with_size() {
+        let timeouts = CacheTimeouts {
+            download: Some(Duration::from_secs(60)),
+            ..CacheTimeouts::default()
+        };
+        const MB: u64 = 1024 * 1024;
+
+        assert_eq!(
+            timeouts.download_timeout(None),
+            Some(Duration::from_secs(60))
+        );
+        assert_eq!(
+            timeouts.download_timeout(Some(0)),
+            Some(Duration::from_secs(60))
+        );
+        assert_eq!(
+            timeouts.download_timeout(Some(100 * MB)),
+            Some(Duration::from_secs(60))
+        );
+        assert_eq!(
+            timeouts.download_timeout(Some(250 * MB)),
+            Some(Duration::from_secs(180))
+        );
+        assert_eq!(
+            CacheTimeouts::default().download_timeout(Some(250 * MB)),
+            None
+        );
+    }
+
+    #[tokio::test]
+    async fn test_send_artifact_request_retries() -> Result<()> {
+        let mock = httpmock::MockServer::start_async().await;
+        let download = mock
+            .mock_async(|when, then| {
+                when.method(httpmock::Method::GET);
+                then.status(500);
+            })
+            .await;
+        let exists = mock
+            .mock_async(|when, then| {
+                when.method(httpmock::Method::HEAD);
+                then.status(503);
+            })
+            .await;
+
+        let client = APIClient::new(
+            mock.base_url(),
+            None,
+            CacheTimeouts::default(),
+            "2.0.0",
+            false,
+            &TlsOptions::default(),
+        )?
+        .with_retry_policy(RetryPolicy {
+            max_attempts: 3,
+            min_backoff: Duration::from_millis(1),
+            max_backoff: Duration::from_millis(5),
+            max_elapsed: None,
+        });
+
+        let response = client
+            .send_artifact_request(
+                || client.cache_client().get(mock.url("/artifact")),
+                ArtifactRequest::Download,
+            )
+            .await?;
+        assert_eq!(response.status(), 500);
+        download.assert_hits_async(3).await;
+
+        // Checking whether an artifact exists is retried, but isn't a transfer
+        client
+            .send_artifact_request(
+                || client.cache_client().head(mock.url("/artifact")),
+                ArtifactRequest::Exists,
+            )
+            .await?;
+        exists.assert_hits_async(3).await;
+
+        assert_eq!(
+            client.artifact_transfers().stats(),
+            ArtifactTransferStats {
+                retried: 1,
+                failed: 1
+            }
+        );
+        Ok(())
+    }
 }
@@ -1,16 +1,25 @@
+use std::{
+    sync::{
+        atomic::{AtomicUsize, Ordering},
+        Arc,
+    },
+    time::{Duration, Instant},
+};
+
+use rand::Rng;
 use reqwest::{RequestBuilder, Response, StatusCode};
+use serde::Serialize;
 use tokio::time::sleep;
 
 use crate::Error;
 
-const MIN_SLEEP_TIME_SECS: u64 = 2;
-const MAX_SLEEP_TIME_SECS: u64 = 10;
+const MIN_SLEEP_TIME: Duration = Duration::from_secs(2);
+const MAX_SLEEP_TIME: Duration = Duration::from_secs(10);
 const RETRY_MAX: u32 = 2;
 
 #[derive(Debug)]
 pub enum Retry {
     Once(Response),
-    #[allow(dead_code)]
     Retried(Response, u32),
 }
 
@@ -22,7 +31,6 @@ impl Retry {
         }
     }
 
-    #[allow(dead_code)]
     pub fn retry_count(&self) -> Option<u32> {
         match self {
             Retry::Once(_) => None,
@@ -31,48 +39,169 @@ impl Retry {
     }
 }
 
-/// Retries a request until `RETRY_MAX` is reached, the `should_retry_request`
-/// function returns false, or the future succeeds. Uses an exponential backoff
-/// with a base of 2 to delay between retries.
+/// Controls how often and for how long a failed request is retried.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct RetryPolicy {
+    /// Total number of attempts made for a request, including the first one
+    pub max_attempts: u32,
+    /// Delay before the first retry, doubled for every following retry
+    pub min_backoff: Duration,
+    /// Upper bound on the delay between two attempts
+    pub max_backoff: Duration,
+    /// If set, no retry is started once this much time has passed since the
+    /// first attempt
+    pub max_elapsed: Option<Duration>,
+}
+
+impl Default for RetryPolicy {
+    fn default() -> Self {
+        Self {
+            max_attempts: RETRY_MAX,
+            min_backoff: MIN_SLEEP_TIME,
+            max_backoff: MAX_SLEEP_TIME,
+            max_elapsed: None,
+        }
+    }
+}
+
+impl RetryPolicy {
+    /// Returns the delay before the next attempt after `retry_count` retries.
+    /// Uses an exponential backoff with a base of 2 and adds jitter so that
+    /// concurrent requests don't retry in lockstep.
+    fn backoff(&self, retry_count: u32) -> Duration {
+        let backoff = self
+            .min_backoff
+            .saturating_mul(2_u32.saturating_pow(retry_count))
+            .min(self.max_backoff);
+        // Jitter between half and the full backoff so we never retry immediately
+        let half = backoff / 2;
+        half + rand::thread_rng().gen_range(Duration::ZERO..=half)
+    }
+
+    fn can_retry(&self, retry_count: u32, elapsed: Duration, backoff: Duration) -> bool {
+        retry_count + 1 < self.max_attempts
+            && self
+                .max_elapsed
+                .map_or(true, |max_elapsed| elapsed + backoff <= max_elapsed)
+    }
+}
+
+/// Counts of artifact transfers that had to be retried or that failed after
+/// exhausting all retries.
+#[derive(Debug, Default, Clone, Copy, PartialEq, Eq, Serialize)]
+pub struct ArtifactTransferStats {
+    pub retried: usize,
+    pub failed: usize,
+}
+
+impl ArtifactTransferStats {
+    pub fn is_empty(&self) -> bool {
+        self.retried == 0 && self.failed == 0
+    }
+}
+
+/// Tracks retried and failed artifact transfers. Clones share the same
+/// counters.
+#[derive(Debug, Default, Clone)]
+pub struct ArtifactTransferTracker {
+    retried: Arc<AtomicUsize>,
+    failed: Arc<AtomicUsize>,
+}
+
+impl ArtifactTransferTracker {
+    pub(crate) fn record(&self, result: &Result<Retry, Error>) {
+        let failed = match result {
+            Ok(retry) => {
+                if retry.retry_count().is_some_and(|count| count > 0) {
+                    self.retried.fetch_add(1, Ordering::Relaxed);
+                }
+                let response = match retry {
+                    Retry::Once(response) | Retry::Retried(response, _) => response,
+                };
+                is_retryable_status(response.status())
+            }
+            Err(_) => true,
+        };
+        if failed {
+            self.failed.fetch_add(1, Ordering::Relaxed);
+        }
+    }
+
+    pub fn stats(&self) -> ArtifactTransferStats {
+        ArtifactTransferStats {
+            retried: self.retried.load(Ordering::Relaxed),
+            failed: self.failed.load(Ordering::Relaxed),
+        }
+    }
+}
+
+/// Retries a request until the policy's attempts or time budget is exhausted,
+/// the `should_retry_request` function returns false, or the future succeeds.
+/// Responses with a 429 or 5xx status are also retried.
 ///
 /// # Arguments
 ///
 /// * `request_builder`: The request builder with everything, i.e. headers and
 ///   body already set. NOTE: This must be cloneable, so no streams are allowed.
 /// * `strategy`: The strategy to use for retrying requests.
+/// * `policy`: How often and for how long to retry.
 ///
 /// returns: Result<Response, Error>
 pub(crate) async fn make_retryable_request(
     request_builder: RequestBuilder,
     strategy: RetryStrategy,
+    policy: &RetryPolicy,
 ) -> Result<Retry, Error> {
-    let mut last_error = None;
-    for retry_count in 0..RETRY_MAX {
-        // A request builder can fail to clone for two reasons:
-        // - the URL given was given as a string and isn't a valid URL this can be
-        //   mitigated by constructing requests with pre-parsed URLs via Url::parse
-        // - the request body is a stream, in this case we'll just send the one request
-        //   we have
-        let Some(builder) = request_builder.try_clone() else {
-            return Ok(Retry::Once(request_builder.send().await?));
-        };
-        match builder.send().await {
-            Ok(value) => return Ok(Retry::Retried(value, retry_count)),
-            Err(err) => {
-                if !strategy.should_retry(&err) {
-                    return Err(err.into());
-                }
-                last_error = Some(err);
-            }
+    // A request builder can fail to clone for two reasons:
+    // - the URL given was given as a string and isn't a valid URL this can be
+    //   mitigated by constructing requests with pre-parsed URLs via Url::parse
+    // - the request body is a stream, in this case we'll just send the one request
+    //   we have
+    if request_builder.try_clone().is_none() {
+        return Ok(Retry::Once(request_builder.send().await?));
+    }
+
+    make_retryable_request_with(
+        || {
+            request_builder
+                .try_clone()
+                .expect("request builder was cloned above")
+        },
+        strategy,
+        policy,
+    )
+    .await
+}
+
+/// Like `make_retryable_request`, but builds a fresh request for every
+/// attempt. This allows retrying requests with streamed bodies.
+pub(crate) async fn make_retryable_request_with(
+    make_request: impl Fn() -> RequestBuilder,
+    strategy: RetryStrategy,
+    policy: &RetryPolicy,
+) -> Result<Retry, Error> {
+    let start = Instant::now();
+    let mut retry_count = 0;
+    loop {
+        let result = make_request().send().await;
+        let backoff = policy.backoff(retry_count);
+        let can_retry = policy.can_retry(retry_count, start.elapsed(), backoff);
+        match result {
+            Ok(response) if can_retry && is_retryable_status(response.status()) => {}
+            Ok(response) => return Ok(Retry::Retried(response, retry_count)),
+            Err(err) if !strategy.should_retry(&err) => return Err(err.into()),
+            Err(err) if !can_retry => return Err(Error::TooManyFailures(Box::new(err))),
+            Err(_) => {}
         }
 
-        let sleep_period = (2_u64)
-            .pow(retry_count)
-            .clamp(MIN_SLEEP_TIME_SECS, MAX_SLEEP_TIME_SECS);
-        sleep(std::time::Duration::from_secs(sleep_period)).await;
+        sleep(backoff).await;
+        retry_count += 1;
     }
+}
 
-    Err(Error::TooManyFailures(Box::new(last_error.unwrap())))
+fn is_retryable_status(status: StatusCode) -> bool {
+    status == StatusCode::TOO_MANY_REQUESTS
+        || (status.is_server_error() && status != StatusCode::NOT_IMPLEMENTED)
 }
 
 /// A retry strategy. Note that error statuses and TOO_MANY_REQUESTS are always
@@ -86,14 +215,8 @@ pub enum RetryStrategy {
 
 impl RetryStrategy {
     fn should_retry(&self, error: &reqwest::Error) -> bool {
-        if let Some(status) = error.status() {
-            if status == StatusCode::TOO_MANY_REQUESTS {
-                return true;
-            }
-
-            if status.as_u16() >= 500 && status.as_u16() != 501 {
-                return true;
-            }
+        if error.status().is_some_and(is_retryable_status) {
+            return true;
         }
 
         match self {
@@ -108,10 +231,19 @@ mod test {
     use std::{assert_matches::assert_matches, time::Duration};
 
     use crate::{
-        retry::{make_retryable_request, RetryStrategy},
+        retry::{make_retryable_request, RetryPolicy, RetryStrategy},
         Error,
     };
 
+    fn fast_policy(max_attempts: u32) -> RetryPolicy {
+        RetryPolicy {
+            max_attempts,
+            min_backoff: Duration::from_millis(1),
+            max_backoff: Duration::from_millis(5),
+            max_elapsed: None,
+        }
+    }
+
     #[tokio::test]
     async fn handles_too_many_failures() {
         let mock = httpmock::MockServer::start_async().await;
@@ -125,7 +257,12 @@ mod test {
         let request_builder = reqwest::Client::new()
             .get(mock.url("/"))
             .timeout(Duration::from_millis(10));
-        let result = make_retryable_request(request_builder, RetryStrategy::Timeout).await;
+        let result = make_retryable_request(
+            request_builder,
+            RetryStrategy::Timeout,
+            &RetryPolicy::default(),
+        )
+        .await;
 
         req.assert_hits_async(2).await;
         assert_matches!(result, Err(Error::TooManyFailures(_)));
@@ -161,11 +298,91 @@ mod test {
             .await;
 
         let request_builder = client.get(mock.url("/")); // bad port
-        let result = make_retryable_request(request_builder, RetryStrategy::Connection).await;
+        let result = make_retryable_request(
+            request_builder,
+            RetryStrategy::Connection,
+            &RetryPolicy::default(),
+        )
+        .await;
 
         // we should make at most one request and give up if it times out after
         // connecting
         assert_matches!(result, Err(_));
         req.assert_hits_async(1).await;
     }
+
+    #[tokio::test]
+    async fn retries_server_errors() {
+        let mock = httpmock::MockServer::start_async().await;
+        let req = mock
+            .mock_async(|when, then| {
+                when.method(httpmock::Method::GET);
+                then.status(503);
+            })
+            .await;
+
+        let request_builder = reqwest::Client::new().get(mock.url("/"));
+        let result =
+            make_retryable_request(request_builder, RetryStrategy::Timeout, &fast_policy(3))
+                .await
+                .unwrap();
+
+        // The last response is handed back to the caller once we run out of attempts
+        req.assert_hits_async(3).await;
+        assert_eq!(result.retry_count(), Some(2));
+        assert_eq!(result.into_response().status(), 503);
+    }
+
+    #[tokio::test]
+    async fn does_not_retry_client_errors() {
+        let mock = httpmock::MockServer::start_async().await;
+        let req = mock
+            .mock_async(|when, then| {
+                when.method(httpmock::Method::GET);
+                then.status(404);
+            })
+            .await;
+
+        let request_builder = reqwest::Client::new().get(mock.url("/"));
+        let result =
+            make_retryable_request(request_builder, RetryStrategy::Timeout, &fast_policy(3))
+                .await
+                .unwrap();
+
+        req.assert_hits_async(1).await;
+        assert_eq!(result.retry_count(), Some(0));
+    }
+
+    #[tokio::test]
+    async fn respects_max_elapsed() {
+        let mock = httpmock::MockServer::start_async().await;
+        let req = mock
+            .mock_async(|when, then| {
+                when.method(httpmock::Method::GET);
+                then.status(500);
+            })
+            .await;
+
+        let policy = RetryPolicy {
+            max_elapsed: Some(Duration::ZERO),
+            ..fast_policy(3)
+        };
+        let request_builder = reqwest::Client::new().get(mock.url("/"));
+        let result = make_retryable_request(request_builder, RetryStrategy::Timeout, &policy)
+            .await
+            .unwrap();
+
+        req.assert_hits_async(1).await;
+        assert_eq!(result.into_response().status(), 500);
+    }
+
+    #[test]
+    fn backoff_is_bounded() {
+        let policy = RetryPolicy::default();
+        for retry_count in 0..10 {
+            let backoff = policy.backoff(retry_count);
+            assert!(backoff >= policy.min_backoff / 2);
+            assert!(backoff <= policy.max_backoff);
+        }
+    }
 }
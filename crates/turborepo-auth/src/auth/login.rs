@@ -1,10 +1,13 @@
-use std::sync::Arc;
+use std::{
+    sync::Arc,
+    time::{Duration, Instant},
+};
 
 pub use error::Error;
 use reqwest::Url;
 use tokio::sync::OnceCell;
-use tracing::warn;
-use turborepo_api_client::{CacheClient, Client, TokenClient};
+use tracing::{debug, warn};
+use turborepo_api_client::{CacheClient, Client, DeviceCodeClient, DeviceTokenStatus, TokenClient};
 use turborepo_ui::{start_spinner, BOLD, UI};
 
 use crate::{auth::extract_vercel_token, error, ui, LoginOptions, Token};
@@ -135,6 +138,95 @@ pub async fn login<T: Client + TokenClient + CacheClient>(
     Ok(Token::new(token.into()))
 }
 
+// Amount to back off by when the server asks us to slow down, per RFC 8628
+const SLOW_DOWN_INCREMENT: Duration = Duration::from_secs(5);
+
+/// Login without a browser callback. We request a device code, print a URL and
+/// code for the user to enter on any device, and poll until the login is
+/// approved. Meant for remote machines and containers where we can't listen
+/// on localhost or open a browser.
+///
+/// If `sso_team` is set the user is asked to authenticate with that team's SSO
+/// provider.
+pub async fn device_login<T: Client + TokenClient + CacheClient + DeviceCodeClient>(
+    options: &LoginOptions<'_, T>,
+) -> Result<Token, Error> {
+    let LoginOptions {
+        api_client,
+        ui,
+        sso_team,
+        existing_token,
+        force,
+        login_url: _,
+        login_server: _,
+    } = *options;
+
+    if !force {
+        if let Some(token) = existing_token {
+            let token = Token::existing(token.into());
+            let on_valid = |user_email: &str| {
+                println!("{}", ui.apply(BOLD.apply_to("Existing token found!")));
+                ui::print_cli_authorized(user_email, ui);
+            };
+            let is_valid = match sso_team {
+                Some(sso_team) => {
+                    token
+                        .is_valid_sso(api_client, sso_team, Some(on_valid))
+                        .await?
+                }
+                None => token.is_valid(api_client, Some(on_valid)).await?,
+            };
+            if is_valid {
+                return Ok(token);
+            }
+        }
+    }
+
+    let device_code = api_client.request_device_code(sso_team).await?;
+    let url = device_code
+        .verification_uri_complete
+        .as_deref()
+        .unwrap_or(&device_code.verification_uri);
+    println!(">>> Visit {url} on any device and enter the code:");
+    println!();
+    println!("  {}", ui.apply(BOLD.apply_to(&device_code.user_code)));
+    println!();
+    let spinner = start_spinner("Waiting for your authorization...");
+
+    let expires_at = Instant::now() + Duration::from_secs(device_code.expires_in);
+    let mut interval = Duration::from_secs(device_code.interval);
+    let token = loop {
+        if Instant::now() >= expires_at {
+            return Err(Error::DeviceCodeExpired);
+        }
+        tokio::time::sleep(interval).await;
+        match api_client
+            .poll_device_token(&device_code.device_code)
+            .await?
+        {
+            DeviceTokenStatus::Pending => continue,
+            DeviceTokenStatus::SlowDown => {
+                interval += SLOW_DOWN_INCREMENT;
+                debug!("slowing down device token polling to {interval:?}");
+            }
+            DeviceTokenStatus::Expired => return Err(Error::DeviceCodeExpired),
+            DeviceTokenStatus::Denied => return Err(Error::DeviceCodeDenied),
+            DeviceTokenStatus::Authorized(token) => break token,
+        }
+    };
+
+    spinner.finish_and_clear();
+
+    let user_response = api_client
+        .get_user(&token)
+        .await
+        .map_err(Error::FailedToFetchUser)?;
+
+    ui::print_cli_authorized(&user_response.user.email, ui);
+
+    Ok(Token::new(token))
+}
+
 #[cfg(test)]
 mod tests {
     use std::{assert_matches::assert_matches, sync::atomic::AtomicUsize};
@@ -142,8 +234,8 @@ mod tests {
     use async_trait::async_trait;
     use reqwest::{Method, RequestBuilder, Response};
     use turborepo_vercel_api::{
-        CachingStatus, CachingStatusResponse, Membership, Role, SpacesResponse, Team,
-        TeamsResponse, User, UserResponse, VerifiedSsoUser,
+        CachingStatus, CachingStatusResponse, DeviceCodeResponse, Membership, Role, SpacesResponse,
+        Team, TeamsResponse, User, UserResponse, VerifiedSsoUser,
     };
     use turborepo_vercel_api_mock::start_test_server;
 
@@ -190,12 +282,18 @@ mod tests {
 
     struct MockApiClient {
         pub base_url: String,
+        pub device_polls: AtomicUsize,
+        pub device_status: DeviceTokenStatus,
     }
 
     impl MockApiClient {
         fn new() -> Self {
             Self {
                 base_url: String::new(),
+                device_polls: AtomicUsize::new(0),
+                device_status: DeviceTokenStatus::Authorized(
+                    turborepo_vercel_api_mock::EXPECTED_TOKEN.to_string(),
+                ),
             }
         }
     }
@@ -299,6 +397,36 @@ mod tests {
         }
     }
 
+    impl DeviceCodeClient for MockApiClient {
+        async fn request_device_code(
+            &self,
+            _team_id: Option<&str>,
+        ) -> turborepo_api_client::Result<DeviceCodeResponse> {
+            Ok(DeviceCodeResponse {
+                device_code: "device-code".to_string(),
+                user_code: "ABCD-EFGH".to_string(),
+                verification_uri: "https://vercel.com/device".to_string(),
+                verification_uri_complete: None,
+                expires_in: 60,
+                interval: 0,
+            })
+        }
+        async fn poll_device_token(
+            &self,
+            device_code: &str,
+        ) -> turborepo_api_client::Result<DeviceTokenStatus> {
+            assert_eq!(device_code, "device-code");
+            // Make the first poll wait on the user
+            match self
+                .device_polls
+                .fetch_add(1, std::sync::atomic::Ordering::SeqCst)
+            {
+                0 => Ok(DeviceTokenStatus::Pending),
+                _ => Ok(self.device_status.clone()),
+            }
+        }
+    }
+
     impl CacheClient for MockApiClient {
         async fn get_artifact(
             &self,
@@ -310,20 +438,23 @@ mod tests {
         ) -> Result<Option<Response>, turborepo_api_client::Error> {
             unimplemented!("get_artifact")
         }
-        async fn put_artifact(
+        async fn put_artifact<S>(
             &self,
             _hash: &str,
-            _artifact_body: impl turborepo_api_client::Stream<
-                    Item = Result<turborepo_api_client::Bytes, turborepo_api_client::Error>,
-                > + Send
-                + Sync
-                + 'static,
+            _artifact_body: impl Fn() -> S + Send + Sync,
             _duration: u64,
             _tag: Option<&str>,
             _token: &str,
             _team_id: Option<&str>,
             _team_slug: Option<&str>,
-        ) -> Result<(), turborepo_api_client::Error> {
+        ) -> Result<(), turborepo_api_client::Error>
+        where
+            S: turborepo_api_client::Stream<
+                    Item = Result<turborepo_api_client::Bytes, turborepo_api_client::Error>,
+                > + Send
+                + Sync
+                + 'static,
+        {
             unimplemented!("set_artifact")
         }
         async fn fetch_artifact(
@@ -388,4 +519,60 @@ mod tests {
             1
         );
     }
+
+    #[tokio::test]
+    async fn test_device_login() {
+        let ui = UI::new(false);
+        let api_client = MockApiClient::new();
+        let login_server = MockLoginServer {
+            hits: Arc::new(0.into()),
+        };
+        let mut options = LoginOptions::new(&ui, "", &api_client, &login_server);
+
+        let token = device_login(&options).await.unwrap();
+        assert_matches!(token, Token::New(..));
+        assert_eq!(
+            token.into_inner(),
+            turborepo_vercel_api_mock::EXPECTED_TOKEN
+        );
+        assert_eq!(
+            api_client
+                .device_polls
+                .load(std::sync::atomic::Ordering::SeqCst),
+            2
+        );
+
+        // An existing valid token skips the device flow entirely
+        let got_token = token.into_inner().to_string();
+        options.existing_token = Some(&got_token);
+        let second_token = device_login(&options).await.unwrap();
+        assert_matches!(second_token, Token::Existing(..));
+        assert_eq!(
+            api_client
+                .device_polls
+                .load(std::sync::atomic::Ordering::SeqCst),
+            2
+        );
+        // We never need the login server
+        assert_eq!(
+            login_server.hits.load(std::sync::atomic::Ordering::SeqCst),
+            0
+        );
+    }
+
+    #[tokio::test]
+    async fn test_device_login_denied() {
+        let ui = UI::new(false);
+        let api_client = MockApiClient {
+            device_status: DeviceTokenStatus::Denied,
+            ..MockApiClient::new()
+        };
+        let login_server = MockLoginServer {
+            hits: Arc::new(0.into()),
+        };
+        let options = LoginOptions::new(&ui, "", &api_client, &login_server);
+
+        let result = device_login(&options).await;
+        assert_matches!(result, Err(Error::DeviceCodeDenied));
+    }
 }
@@ -307,20 +307,23 @@ mod tests {
         ) -> Result<Option<Response>, turborepo_api_client::Error> {
             unimplemented!("get_artifact")
         }
-        async fn put_artifact(
+        async fn put_artifact<S>(
             &self,
             _hash: &str,
-            _artifact_body: impl turborepo_api_client::Stream<
-                    Item = Result<turborepo_api_client::Bytes, turborepo_api_client::Error>,
-                > + Send
-                + Sync
-                + 'static,
+            _artifact_body: impl Fn() -> S + Send + Sync,
             _duration: u64,
             _tag: Option<&str>,
             _token: &str,
             _team_id: Option<&str>,
             _team_slug: Option<&str>,
-        ) -> Result<(), turborepo_api_client::Error> {
+        ) -> Result<(), turborepo_api_client::Error>
+        where
+            S: turborepo_api_client::Stream<
+                    Item = Result<turborepo_api_client::Bytes, turborepo_api_client::Error>,
+                > + Send
+                + Sync
+                + 'static,
+        {
             unimplemented!("set_artifact")
         }
         async fn fetch_artifact(
@@ -28,6 +28,10 @@ pub enum Error {
     FailedToValidateSSOToken(#[source] turborepo_api_client::Error),
     #[error("failed to make sso token name")]
     FailedToMakeSSOTokenName(#[source] io::Error),
+    #[error("device code expired before the login was approved")]
+    DeviceCodeExpired,
+    #[error("login was denied")]
+    DeviceCodeDenied,
     #[error("sso team cannot be empty for login")]
     EmptySSOTeam,
     #[error("sso team not found: {0}")]
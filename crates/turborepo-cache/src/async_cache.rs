@@ -8,7 +8,8 @@ use turborepo_analytics::AnalyticsSender;
 use turborepo_api_client::{APIAuth, APIClient};
 
 use crate::{
-    http::UploadMap, multiplexer::CacheMultiplexer, CacheError, CacheHitMetadata, CacheOpts,
+    http::UploadMap, multiplexer::CacheMultiplexer, transfer::TransferTracker, CacheError,
+    CacheHitMetadata, CacheOpts,
 };
 
 const WARNING_CUTOFF: u8 = 4;
@@ -25,6 +26,12 @@ enum WorkerRequest {
         key: String,
         duration: u64,
         files: Vec<AnchoredSystemPathBuf>,
+        local_only_files: Vec<AnchoredSystemPathBuf>,
+        compression_level: Option<i32>,
+        // Notified once the artifact is in the local cache
+        locally_written: Option<oneshot::Sender<()>>,
+        // Notified once the artifact is in every cache
+        written: oneshot::Sender<()>,
     },
     Flush(oneshot::Sender<()>),
     /// Shutdown the cache. The first oneshot notifies when shutdown starts and
@@ -67,24 +74,56 @@ impl AsyncCache {
                         key,
                         duration,
                         files,
+                        local_only_files,
+                        compression_level,
+                        locally_written,
+                        written,
                     } => {
                         let permit = semaphore.clone().acquire_owned().await.unwrap();
                         let real_cache = real_cache.clone();
                         let warnings = warnings.clone();
-                        let worker_span = tracing::span!(Level::TRACE, "cache worker: cache PUT");
+                        let worker_span =
+                            tracing::span!(Level::TRACE, "cache worker: cache PUT", hash = %key);
                         workers.push(tokio::spawn(
                             async move {
-                                if let Err(err) =
-                                    real_cache.put(&anchor, &key, &files, duration).await
-                                {
-                                    let num_warnings =
-                                        warnings.load(std::sync::atomic::Ordering::Acquire);
-                                    if num_warnings <= WARNING_CUTOFF {
-                                        warnings.store(
-                                            num_warnings + 1,
-                                            std::sync::atomic::Ordering::Release,
-                                        );
-                                        warn!("{err}");
+                                let result = match real_cache.put_local(
+                                    &anchor,
+                                    &key,
+                                    &files,
+                                    duration,
+                                    compression_level,
+                                ) {
+                                    Ok(()) => {
+                                        if let Some(locally_written) = locally_written {
+                                            locally_written.send(()).ok();
+                                        }
+                                        real_cache
+                                            .put_remote(
+                                                &anchor,
+                                                &key,
+                                                &files,
+                                                &local_only_files,
+                                                duration,
+                                                compression_level,
+                                            )
+                                            .await
+                                    }
+                                    Err(err) => Err(err),
+                                };
+                                match result {
+                                    Ok(()) => {
+                                        written.send(()).ok();
+                                    }
+                                    Err(err) => {
+                                        let num_warnings =
+                                            warnings.load(std::sync::atomic::Ordering::Acquire);
+                                        if num_warnings <= WARNING_CUTOFF {
+                                            warnings.store(
+                                                num_warnings + 1,
+                                                std::sync::atomic::Ordering::Release,
+                                            );
+                                            warn!("{err}");
+                                        }
                                     }
                                 }
                                 // Release permit once we're done with the write
@@ -121,6 +160,10 @@ impl AsyncCache {
                 let _ = worker;
             }
 
+            // Now that nothing else is writing to the cache, make sure it fits
+            // within its size limit
+            real_cache.evict();
+
             if let Some(callback) = shutdown_callback {
                 callback.send(()).ok();
             }
@@ -132,7 +175,7 @@ impl AsyncCache {
         })
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %key))]
     pub async fn put(
         &self,
         anchor: AbsoluteSystemPathBuf,
@@ -140,6 +183,82 @@ impl AsyncCache {
         files: Vec<AnchoredSystemPathBuf>,
         duration: u64,
     ) -> Result<(), CacheError> {
+        self.put_with_local_only(anchor, key, files, Vec::new(), duration, None)
+            .await?;
+        Ok(())
+    }
+
+    /// Like `put`, but `local_only_files` are only written to the local
+    /// cache. They should also be included in `files`. `compression_level`
+    /// overrides the level the cache was created with.
+    ///
+    /// The returned receiver is notified once the artifact has been written to
+    /// every cache. If the write fails, it's dropped without a notification.
+    #[tracing::instrument(skip_all, fields(hash = %key))]
+    pub async fn put_with_local_only(
+        &self,
+        anchor: AbsoluteSystemPathBuf,
+        key: String,
+        files: Vec<AnchoredSystemPathBuf>,
+        local_only_files: Vec<AnchoredSystemPathBuf>,
+        duration: u64,
+        compression_level: Option<i32>,
+    ) -> Result<oneshot::Receiver<()>, CacheError> {
+        self.send_write(
+            anchor,
+            key,
+            files,
+            local_only_files,
+            duration,
+            compression_level,
+            None,
+        )
+        .await
+    }
+
+    /// Like `put_with_local_only`, but only returns once the artifact has been
+    /// written to the local cache, so that other processes on the machine can
+    /// restore it. Uploading it to the remote cache still happens in the
+    /// background.
+    #[tracing::instrument(skip_all, fields(hash = %key))]
+    pub async fn put_locally_and_wait(
+        &self,
+        anchor: AbsoluteSystemPathBuf,
+        key: String,
+        files: Vec<AnchoredSystemPathBuf>,
+        local_only_files: Vec<AnchoredSystemPathBuf>,
+        duration: u64,
+        compression_level: Option<i32>,
+    ) -> Result<oneshot::Receiver<()>, CacheError> {
+        let (tx, rx) = oneshot::channel();
+        let written = self
+            .send_write(
+                anchor,
+                key,
+                files,
+                local_only_files,
+                duration,
+                compression_level,
+                Some(tx),
+            )
+            .await?;
+        // The sender is dropped without a notification if the write failed, which
+        // is reported by the worker
+        rx.await.ok();
+        Ok(written)
+    }
+
+    async fn send_write(
+        &self,
+        anchor: AbsoluteSystemPathBuf,
+        key: String,
+        files: Vec<AnchoredSystemPathBuf>,
+        local_only_files: Vec<AnchoredSystemPathBuf>,
+        duration: u64,
+        compression_level: Option<i32>,
+        locally_written: Option<oneshot::Sender<()>>,
+    ) -> Result<oneshot::Receiver<()>, CacheError> {
+        let (written, written_rx) = oneshot::channel();
         if self
             .writer_sender
             .send(WorkerRequest::WriteRequest {
@@ -147,22 +266,26 @@ impl AsyncCache {
                 key,
                 duration,
                 files,
+                local_only_files,
+                compression_level,
+                locally_written,
+                written,
             })
             .await
             .is_err()
         {
             Err(CacheError::CacheShuttingDown)
         } else {
-            Ok(())
+            Ok(written_rx)
         }
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %key))]
     pub async fn exists(&self, key: &str) -> Result<Option<CacheHitMetadata>, CacheError> {
         self.real_cache.exists(key).await
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %key))]
     pub async fn fetch(
         &self,
         anchor: &AbsoluteSystemPath,
@@ -171,6 +294,12 @@ impl AsyncCache {
         self.real_cache.fetch(anchor, key).await
     }
 
+    /// Returns the tracker for transfers to and from the remote cache, if
+    /// one is in use
+    pub fn transfers(&self) -> Option<TransferTracker> {
+        self.real_cache.transfers()
+    }
+
     // Used for testing to ensure that the workers resolve
     // before checking the cache.
     #[tracing::instrument(skip_all)]
@@ -220,8 +349,8 @@ mod tests {
     use anyhow::Result;
     use futures::future::try_join_all;
     use tempfile::tempdir;
-    use turbopath::AbsoluteSystemPathBuf;
-    use turborepo_api_client::{APIAuth, APIClient};
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+    use turborepo_api_client::{APIAuth, APIClient, CacheTimeouts, TlsOptions};
     use turborepo_vercel_api_mock::start_test_server;
 
     use crate::{
@@ -229,6 +358,194 @@ mod tests {
         AsyncCache, CacheHitMetadata, CacheOpts, CacheSource, RemoteCacheOpts,
     };
 
+    #[tokio::test]
+    async fn test_uploads_limited_below_workers() -> Result<()> {
+        let port = port_scanner::request_open_port().unwrap();
+        let handle = tokio::spawn(start_test_server(port));
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let test_case = &get_test_cases()[0];
+        test_case.initialize(&repo_root_path)?;
+
+        let opts = CacheOpts {
+            skip_filesystem: true,
+            workers: 2,
+            upload_concurrency: Some(1),
+            remote_cache_opts: Some(RemoteCacheOpts {
+                unused_team_id: Some("my-team".to_string()),
+                signature: false,
+            }),
+            ..CacheOpts::default()
+        };
+        let api_client = APIClient::new(
+            format!("http://localhost:{}", port),
+            Some(Duration::from_secs(200)),
+            CacheTimeouts::default(),
+            "2.0.0",
+            true,
+            &TlsOptions::default(),
+        )?;
+        let api_auth = Some(APIAuth {
+            team_id: Some("my-team-id".to_string()),
+            token: "my-token".to_string(),
+            team_slug: None,
+        });
+        let async_cache = AsyncCache::new(&opts, &repo_root_path, api_client, api_auth, None)?;
+
+        // More writes than uploads that can run at once
+        let hashes = (0..5)
+            .map(|i| format!("{}-upload-{i}", test_case.hash))
+            .collect::<Vec<_>>();
+        let files = test_case
+            .files
+            .iter()
+            .map(|f| f.path().to_owned())
+            .collect::<Vec<_>>();
+        for hash in &hashes {
+            async_cache
+                .put(
+                    repo_root_path.clone(),
+                    hash.clone(),
+                    files.clone(),
+                    test_case.duration,
+                )
+                .await?;
+        }
+        tokio::time::timeout(Duration::from_secs(30), async_cache.wait())
+            .await
+            .expect("uploads should finish")?;
+
+        for hash in &hashes {
+            assert_matches!(async_cache.exists(hash).await, Ok(Some(_)));
+        }
+
+        async_cache.shutdown().await?;
+        handle.abort();
+        Ok(())
+    }
+
+    // A turbo process waiting on another one that runs the same task restores
+    // the outputs that the other process cached before it stopped waiting
+    #[tokio::test]
+    async fn test_put_locally_and_wait() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        let opts = CacheOpts {
+            skip_remote: true,
+            ..CacheOpts::default()
+        };
+        let cache = || -> Result<AsyncCache> {
+            let api_client = APIClient::new(
+                "http://localhost:0",
+                None,
+                CacheTimeouts::default(),
+                "2.0.0",
+                true,
+                &TlsOptions::default(),
+            )?;
+            Ok(AsyncCache::new(&opts, &repo_root, api_client, None, None)?)
+        };
+        let (running, waiting) = (cache()?, cache()?);
+
+        repo_root.resolve(&output).create_with_contents("output")?;
+        running
+            .put_locally_and_wait(
+                repo_root.clone(),
+                "abc".to_string(),
+                vec![output.clone()],
+                Vec::new(),
+                100,
+                None,
+            )
+            .await?;
+
+        repo_root.resolve(&output).remove_file()?;
+        let (metadata, files) = waiting
+            .fetch(&repo_root, "abc")
+            .await?
+            .expect("outputs are cached once the put returns");
+        assert_eq!(metadata.time_saved, 100);
+        assert_eq!(files, vec![output.clone()]);
+        assert_eq!(repo_root.resolve(&output).read_to_string()?, "output");
+
+        running.shutdown().await?;
+        waiting.shutdown().await?;
+        Ok(())
+    }
+
+    #[tokio::test]
+    async fn test_local_only_files_are_not_uploaded() -> Result<()> {
+        let port = port_scanner::request_open_port().unwrap();
+        let handle = tokio::spawn(start_test_server(port));
+        let repo_root = tempdir()?;
+        let repo_root = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.js")?;
+        let local_only = AnchoredSystemPathBuf::from_raw("output.js.map")?;
+        repo_root.resolve(&output).create_with_contents("output")?;
+        repo_root.resolve(&local_only).create_with_contents("map")?;
+
+        let opts = CacheOpts {
+            workers: 2,
+            remote_cache_opts: Some(RemoteCacheOpts {
+                unused_team_id: Some("my-team".to_string()),
+                signature: false,
+            }),
+            ..CacheOpts::default()
+        };
+        let api_client = APIClient::new(
+            format!("http://localhost:{}", port),
+            Some(Duration::from_secs(200)),
+            CacheTimeouts::default(),
+            "2.0.0",
+            true,
+            &TlsOptions::default(),
+        )?;
+        let api_auth = Some(APIAuth {
+            team_id: Some("my-team-id".to_string()),
+            token: "my-token".to_string(),
+            team_slug: None,
+        });
+        let async_cache = AsyncCache::new(&opts, &repo_root, api_client, api_auth, None)?;
+
+        let written = async_cache
+            .put_with_local_only(
+                repo_root.clone(),
+                "local-only".to_string(),
+                vec![output.clone(), local_only.clone()],
+                vec![local_only.clone()],
+                100,
+                None,
+            )
+            .await?;
+        written.await?;
+
+        let (metadata, files) = async_cache
+            .fetch(&repo_root, "local-only")
+            .await?
+            .expect("artifact is in the local cache");
+        assert_eq!(metadata.source, CacheSource::Local);
+        assert_eq!(files, vec![output.clone(), local_only.clone()]);
+
+        // Only the remote artifact is left
+        repo_root
+            .join_components(&[".turbo", "cache", "local-only.tar.zst"])
+            .remove_file()?;
+        repo_root.resolve(&output).remove_file()?;
+        repo_root.resolve(&local_only).remove_file()?;
+        let (metadata, files) = async_cache
+            .fetch(&repo_root, "local-only")
+            .await?
+            .expect("artifact is in the remote cache");
+        assert_eq!(metadata.source, CacheSource::Remote);
+        assert_eq!(files, vec![output.clone()]);
+        assert!(!repo_root.resolve(&local_only).exists());
+
+        async_cache.shutdown().await?;
+        handle.abort();
+        Ok(())
+    }
+
     #[tokio::test]
     async fn test_async_cache() -> Result<()> {
         let port = port_scanner::request_open_port().unwrap();
@@ -255,21 +572,31 @@ mod tests {
         let opts = CacheOpts {
             override_dir: None,
             remote_cache_read_only: false,
+            remote_cache_write_only: false,
             skip_remote: false,
             skip_filesystem: true,
             workers: 10,
+            upload_concurrency: None,
             remote_cache_opts: Some(RemoteCacheOpts {
                 unused_team_id: Some("my-team".to_string()),
                 signature: false,
             }),
+            max_size: None,
+            s3_opts: None,
+            compression_level: 0,
+            skip_unchanged: false,
+            tiers: vec![],
+            strict: false,
+            remote_failure_limit: None,
         };
 
         let api_client = APIClient::new(
             format!("http://localhost:{}", port),
             Some(Duration::from_secs(200)),
-            None,
+            CacheTimeouts::default(),
             "2.0.0",
             true,
+            &TlsOptions::default(),
         )?;
         let api_auth = Some(APIAuth {
             team_id: Some("my-team-id".to_string()),
@@ -337,13 +664,22 @@ mod tests {
         let opts = CacheOpts {
             override_dir: None,
             remote_cache_read_only: false,
+            remote_cache_write_only: false,
             skip_remote: true,
             skip_filesystem: false,
             workers: 10,
+            upload_concurrency: None,
             remote_cache_opts: Some(RemoteCacheOpts {
                 unused_team_id: Some("my-team".to_string()),
                 signature: false,
             }),
+            max_size: None,
+            s3_opts: None,
+            compression_level: 0,
+            skip_unchanged: false,
+            tiers: vec![],
+            strict: false,
+            remote_failure_limit: None,
         };
 
         // Initialize client with invalid API url to ensure that we don't hit the
@@ -351,9 +687,10 @@ mod tests {
         let api_client = APIClient::new(
             "http://example.com",
             Some(Duration::from_secs(200)),
-            None,
+            CacheTimeouts::default(),
             "2.0.0",
             true,
+            &TlsOptions::default(),
         )?;
         let api_auth = Some(APIAuth {
             team_id: Some("my-team-id".to_string()),
@@ -368,8 +705,8 @@ mod tests {
         assert_matches!(response, Ok(None));
 
         // Add test case
-        async_cache
-            .put(
+        let written = async_cache
+            .put_with_local_only(
                 repo_root_path.clone(),
                 hash.clone(),
                 test_case
@@ -377,13 +714,15 @@ mod tests {
                     .iter()
                     .map(|f| f.path().to_owned())
                     .collect(),
+                Vec::new(),
                 test_case.duration,
+                None,
             )
             .await
             .unwrap();
 
-        // Wait for async cache to process
-        async_cache.wait().await.unwrap();
+        // Wait for the artifact to be written to every cache
+        written.await.unwrap();
 
         let fs_cache_path =
             repo_root_path.join_components(&[".turbo", "cache", &format!("{}.tar.zst", hash)]);
@@ -429,21 +768,31 @@ mod tests {
         let opts = CacheOpts {
             override_dir: None,
             remote_cache_read_only: false,
+            remote_cache_write_only: false,
             skip_remote: false,
             skip_filesystem: false,
             workers: 10,
+            upload_concurrency: None,
             remote_cache_opts: Some(RemoteCacheOpts {
                 unused_team_id: Some("my-team".to_string()),
                 signature: false,
             }),
+            max_size: None,
+            s3_opts: None,
+            compression_level: 0,
+            skip_unchanged: false,
+            tiers: vec![],
+            strict: false,
+            remote_failure_limit: None,
         };
 
         let api_client = APIClient::new(
             format!("http://localhost:{}", port),
             Some(Duration::from_secs(200)),
-            None,
+            CacheTimeouts::default(),
             "2.0.0",
             true,
+            &TlsOptions::default(),
         )?;
         let api_auth = Some(APIAuth {
             team_id: Some("my-team-id".to_string()),
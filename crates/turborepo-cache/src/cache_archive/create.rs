@@ -7,12 +7,33 @@ use std::{
 };
 
 use tar::{EntryType, Header};
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, IntoUnix};
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, IntoUnix};
 
-use crate::CacheError;
+use crate::{
+    cache_archive::manifest::{ArtifactManifest, HashingReader, MANIFEST_PATH},
+    CacheError,
+};
 
 pub struct CacheWriter<'a> {
     builder: tar::Builder<Box<dyn Write + 'a>>,
+    manifest: ArtifactManifest,
+    // Entries are only written by `finish`, once the manifest that comes
+    // before them is complete
+    pending: Vec<PendingEntry>,
+}
+
+struct PendingEntry {
+    source_path: AbsoluteSystemPathBuf,
+    path: String,
+    header: Header,
+    kind: PendingKind,
+}
+
+enum PendingKind {
+    // The size and sha256 the file had when it was added
+    File { size: u64, sha256: String },
+    Symlink { target: String },
+    Directory,
 }
 
 impl<'a> CacheWriter<'a> {
@@ -35,27 +56,73 @@ impl<'a> CacheWriter<'a> {
         Ok(self.builder.append_link(header, path, target)?)
     }
 
+    // Writes the manifest of everything that was added, followed by the
+    // entries themselves and the end of the archive. The manifest comes first
+    // so that files can be checked as they're restored.
     pub fn finish(mut self) -> Result<(), CacheError> {
+        let manifest = self.manifest.to_bytes()?;
+        let mut header = Header::new_gnu();
+        header.set_entry_type(EntryType::Regular);
+        header.set_size(manifest.len() as u64);
+        header.set_mode(0o644);
+        header.set_mtime(0);
+        self.append_data(&mut header, MANIFEST_PATH, &manifest[..])?;
+
+        for PendingEntry {
+            source_path,
+            path,
+            mut header,
+            kind,
+        } in std::mem::take(&mut self.pending)
+        {
+            match kind {
+                PendingKind::File { size, sha256 } => {
+                    let mut file = HashingReader::new(source_path.open()?);
+                    self.append_data(&mut header, &path, &mut file)?;
+                    // The file was already hashed for the manifest, if it changed since
+                    // then the artifact would never pass verification
+                    if file.finish() != (size, sha256) {
+                        return Err(CacheError::OutputChanged(path, Backtrace::capture()));
+                    }
+                }
+                PendingKind::Symlink { target } => {
+                    self.append_link(&mut header, &path, &target)?;
+                }
+                PendingKind::Directory => {
+                    self.append_data(&mut header, &path, &mut std::io::empty())?;
+                }
+            }
+        }
+
         Ok(self.builder.finish()?)
     }
 
-    pub fn from_writer(writer: impl Write + 'a, use_compression: bool) -> Result<Self, CacheError> {
+    fn new(writer: Box<dyn Write + 'a>) -> Self {
+        CacheWriter {
+            builder: tar::Builder::new(writer),
+            manifest: ArtifactManifest::default(),
+            pending: Vec::new(),
+        }
+    }
+
+    // A compression level of 0 uses zstd's default level.
+    pub fn from_writer(
+        writer: impl Write + 'a,
+        use_compression: bool,
+        compression_level: i32,
+    ) -> Result<Self, CacheError> {
         if use_compression {
-            let zw = zstd::Encoder::new(writer, 0)?.auto_finish();
-            Ok(CacheWriter {
-                builder: tar::Builder::new(Box::new(zw)),
-            })
+            let zw = zstd::Encoder::new(writer, compression_level)?.auto_finish();
+            Ok(CacheWriter::new(Box::new(zw)))
         } else {
-            Ok(CacheWriter {
-                builder: tar::Builder::new(Box::new(writer)),
-            })
+            Ok(CacheWriter::new(Box::new(writer)))
         }
     }
 
     // Makes a new CacheArchive at the specified path
     // Wires up the chain of writers:
     // tar::Builder -> zstd::Encoder (optional) -> BufWriter -> File
-    pub fn create(path: &AbsoluteSystemPath) -> Result<Self, CacheError> {
+    pub fn create(path: &AbsoluteSystemPath, compression_level: i32) -> Result<Self, CacheError> {
         let mut options = OpenOptions::new();
         options.write(true).create(true).truncate(true);
 
@@ -67,19 +134,16 @@ impl<'a> CacheWriter<'a> {
         let is_compressed = path.extension() == Some("zst");
 
         if is_compressed {
-            let zw = zstd::Encoder::new(file_buffer, 0)?.auto_finish();
+            let zw = zstd::Encoder::new(file_buffer, compression_level)?.auto_finish();
 
-            Ok(CacheWriter {
-                builder: tar::Builder::new(Box::new(zw)),
-            })
+            Ok(CacheWriter::new(Box::new(zw)))
         } else {
-            Ok(CacheWriter {
-                builder: tar::Builder::new(Box::new(file_buffer)),
-            })
+            Ok(CacheWriter::new(Box::new(file_buffer)))
         }
     }
 
-    // Adds a user-cached item to the tar
+    // Adds a user-cached item to the tar. Files are hashed for the manifest
+    // right away, but only written by `finish`.
     pub(crate) fn add_file(
         &mut self,
         anchor: &AbsoluteSystemPath,
@@ -95,19 +159,38 @@ impl<'a> CacheWriter<'a> {
         let mut file_path = file_path.to_unix();
         file_path.make_canonical_for_tar(file_info.is_dir());
 
-        let mut header = Self::create_header(&file_info)?;
+        // The manifest is always written by `finish`
+        if file_path.as_str() == MANIFEST_PATH {
+            return Ok(());
+        }
+
+        let header = Self::create_header(&file_info)?;
 
-        if matches!(header.entry_type(), EntryType::Regular) && file_info.len() > 0 {
-            let file = source_path.open()?;
-            self.append_data(&mut header, file_path.as_str(), file)?;
+        let kind = if matches!(header.entry_type(), EntryType::Regular) {
+            let mut file = HashingReader::new(source_path.open()?);
+            std::io::copy(&mut file, &mut std::io::sink())?;
+            let (size, sha256) = file.finish();
+            self.manifest
+                .add_file(file_path.as_str(), header.mode()?, size, sha256.clone());
+            PendingKind::File { size, sha256 }
         } else if matches!(header.entry_type(), EntryType::Symlink) {
             // We convert to a Unix path because all paths in tar should be
             // Unix-style. This will get restored to a system path.
             let target = source_path.read_link()?.into_unix();
-            self.append_link(&mut header, file_path.as_str(), &target)?;
+            self.manifest
+                .add_symlink(file_path.as_str(), target.as_str());
+            PendingKind::Symlink {
+                target: target.to_string(),
+            }
         } else {
-            self.append_data(&mut header, file_path.as_str(), &mut std::io::empty())?;
-        }
+            PendingKind::Directory
+        };
+        self.pending.push(PendingEntry {
+            source_path,
+            path: file_path.as_str().to_string(),
+            header,
+            kind,
+        });
 
         Ok(())
     }
@@ -335,7 +418,7 @@ mod tests {
                 AbsoluteSystemPathBuf::try_from(archive_dir.path().join("out.tar"))?
             };
 
-            let mut cache_archive = CacheWriter::create(&archive_path)?;
+            let mut cache_archive = CacheWriter::create(&archive_path, 0)?;
 
             for file in files.iter() {
                 let result = create_entry(&input_dir_path, file);
@@ -394,7 +477,7 @@ mod tests {
         let tar_dir_path = AbsoluteSystemPath::new(tar_dir.path().to_str().unwrap())?;
 
         let tar_path = tar_dir_path.join_component("test.tar");
-        let mut archive = CacheWriter::create(&tar_path)?;
+        let mut archive = CacheWriter::create(&tar_path, 0)?;
         let base = "this-is-a-really-really-really-long-path-like-so-very-long-that-i-can-list-all-of-my-favorite-directors-like-edward-yang-claire-denis-lucrecia-martel-wong-kar-wai-even-kurosawa";
         let file_name = format!("{base}.txt");
         let dir_symlink_name = format!("{base}-dir");
@@ -435,6 +518,34 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_restore_deeply_nested_path() -> Result<()> {
+        let input_dir = tempdir()?;
+        let input = AbsoluteSystemPath::new(input_dir.path().to_str().unwrap())?;
+        // Deeper than MAX_PATH on Windows once restored
+        let segment = "a".repeat(50);
+        let nested =
+            AnchoredSystemPathBuf::from_raw(&segment)?.join_components(&[segment.as_str(); 5]);
+        let file = nested.join_component("file.txt");
+        input.resolve(&nested).create_dir_all()?;
+        input.resolve(&file).create_with_contents("deep")?;
+
+        let archive_dir = tempdir()?;
+        let archive_path = AbsoluteSystemPathBuf::try_from(archive_dir.path().join("out.tar"))?;
+        let mut archive = CacheWriter::create(&archive_path, 0)?;
+        archive.add_file(input, &file)?;
+        archive.finish()?;
+
+        let output_dir = tempdir()?;
+        let output = AbsoluteSystemPath::new(output_dir.path().to_str().unwrap())?;
+        let restored = CacheReader::open(&archive_path)?.restore(output)?;
+
+        assert!(output.resolve(&file).as_str().len() > 260);
+        assert_eq!(restored, vec![file.clone()]);
+        assert_eq!(output.resolve(&file).read_to_string()?, "deep");
+        Ok(())
+    }
+
     #[test]
     fn test_compression() -> Result<()> {
         let mut buffer = Vec::new();
@@ -0,0 +1,488 @@
+//! Every artifact written by turbo starts with a manifest describing the
+//! files that come after it. Each file is checked against the manifest as
+//! it's restored, so that a truncated or corrupted artifact never replaces
+//! an output and is never reported as a cache hit. Older versions of turbo
+//! wrote the manifest at the end of the artifact, those artifacts are
+//! checked once they've been restored.
+
+use std::{
+    backtrace::Backtrace,
+    collections::HashMap,
+    io::{self, Read},
+    path::Path,
+};
+
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPathBuf, IntoUnix};
+
+use crate::CacheError;
+
+/// Path of the manifest entry within the tar. Older versions of turbo will
+/// restore it like any other file, so it lives in the gitignored `.turbo`
+/// directory.
+pub const MANIFEST_PATH: &str = ".turbo/artifact-manifest.json";
+
+#[derive(Debug, Default, PartialEq, Serialize, Deserialize)]
+pub struct ArtifactManifest {
+    files: Vec<ManifestEntry>,
+}
+
+#[derive(Debug, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "camelCase")]
+enum ManifestEntry {
+    File {
+        path: String,
+        size: u64,
+        mode: u32,
+        sha256: String,
+    },
+    #[serde(rename_all = "camelCase")]
+    Symlink { path: String, link_target: String },
+}
+
+impl ManifestEntry {
+    fn path(&self) -> &str {
+        match self {
+            ManifestEntry::File { path, .. } | ManifestEntry::Symlink { path, .. } => path,
+        }
+    }
+}
+
+impl ArtifactManifest {
+    pub fn add_file(&mut self, path: &str, mode: u32, size: u64, sha256: String) {
+        self.files.push(ManifestEntry::File {
+            path: path.to_string(),
+            size,
+            mode,
+            sha256,
+        });
+    }
+
+    pub fn add_symlink(&mut self, path: &str, link_target: &str) {
+        self.files.push(ManifestEntry::Symlink {
+            path: path.to_string(),
+            link_target: link_target.to_string(),
+        });
+    }
+
+    pub fn to_bytes(&self) -> Result<Vec<u8>, CacheError> {
+        serde_json::to_vec(self).map_err(|e| CacheError::InvalidManifest(e, Backtrace::capture()))
+    }
+
+    pub fn read(reader: impl Read) -> Result<Self, CacheError> {
+        serde_json::from_reader(reader)
+            .map_err(|e| CacheError::InvalidManifest(e, Backtrace::capture()))
+    }
+
+    /// Checks that every file listed in the manifest was restored under
+    /// `anchor` with the expected contents.
+    pub fn verify(&self, anchor: &AbsoluteSystemPath) -> Result<(), CacheError> {
+        for entry in &self.files {
+            match entry {
+                ManifestEntry::File {
+                    path,
+                    size,
+                    mode,
+                    sha256,
+                } => {
+                    let mismatch =
+                        || CacheError::IntegrityMismatch(path.clone(), Backtrace::capture());
+                    let restored = anchor.resolve(&Self::anchored_path(path)?);
+                    let metadata = restored.symlink_metadata().map_err(|_| mismatch())?;
+                    if !metadata.is_file() || metadata.len() != *size {
+                        return Err(mismatch());
+                    }
+                    // Group and other bits are subject to the umask of the
+                    // restoring process, so only the owner bits are compared.
+                    #[cfg(unix)]
+                    {
+                        use std::os::unix::fs::PermissionsExt;
+                        if metadata.permissions().mode() & 0o700 != mode & 0o700 {
+                            return Err(mismatch());
+                        }
+                    }
+                    #[cfg(windows)]
+                    let _ = mode;
+
+                    let mut reader = HashingReader::new(restored.open()?);
+                    io::copy(&mut reader, &mut io::sink())?;
+                    let (_, actual) = reader.finish();
+                    if &actual != sha256 {
+                        return Err(mismatch());
+                    }
+                }
+                ManifestEntry::Symlink { path, link_target } => {
+                    let restored = anchor.resolve(&Self::anchored_path(path)?);
+                    let matches = restored
+                        .read_link()
+                        .map_or(false, |target| target.into_unix().as_str() == link_target);
+                    if !matches {
+                        return Err(CacheError::IntegrityMismatch(
+                            path.clone(),
+                            Backtrace::capture(),
+                        ));
+                    }
+                }
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Checks that the entries read from an archive, without restoring it,
+    /// match the ones listed in the manifest, and that the archive has no
+    /// entries the manifest doesn't list.
+    pub fn compare(&self, actual: &ArtifactManifest) -> Result<(), CacheError> {
+        let expected = self.by_path();
+        let actual_by_path = actual.by_path();
+        let mismatch = self
+            .files
+            .iter()
+            .find(|entry| actual_by_path.get(entry.path()) != Some(entry))
+            .or_else(|| {
+                actual
+                    .files
+                    .iter()
+                    .find(|entry| !expected.contains_key(entry.path()))
+            });
+        match mismatch {
+            Some(entry) => Err(CacheError::IntegrityMismatch(
+                entry.path().to_string(),
+                Backtrace::capture(),
+            )),
+            None => Ok(()),
+        }
+    }
+
+    /// Starts checking the entries of an archive as they're restored
+    pub(crate) fn check(self) -> ManifestCheck {
+        ManifestCheck {
+            order: self
+                .files
+                .iter()
+                .map(|entry| entry.path().to_string())
+                .collect(),
+            expected: self
+                .files
+                .into_iter()
+                .map(|entry| (entry.path().to_string(), entry))
+                .collect(),
+        }
+    }
+
+    fn by_path(&self) -> HashMap<&str, &ManifestEntry> {
+        self.files
+            .iter()
+            .map(|entry| (entry.path(), entry))
+            .collect()
+    }
+
+    fn anchored_path(path: &str) -> Result<AnchoredSystemPathBuf, CacheError> {
+        Ok(AnchoredSystemPathBuf::from_system_path(Path::new(
+            path.trim_end_matches('/'),
+        ))?)
+    }
+}
+
+/// Checks the entries of an archive against its manifest while they're
+/// restored. Every entry is taken out of the manifest when it's found, so
+/// duplicated entries and entries the manifest doesn't list are rejected.
+pub(crate) struct ManifestCheck {
+    expected: HashMap<String, ManifestEntry>,
+    // The order of the manifest, to report missing entries deterministically
+    order: Vec<String>,
+}
+
+/// What a file has to contain to match the manifest
+pub(crate) struct ExpectedFile {
+    pub size: u64,
+    pub mode: u32,
+    pub sha256: String,
+}
+
+impl ManifestCheck {
+    fn mismatch(path: &str) -> CacheError {
+        CacheError::IntegrityMismatch(path.to_string(), Backtrace::capture())
+    }
+
+    /// Returns what the regular file at `path` is expected to contain
+    pub(crate) fn file(&mut self, path: &str) -> Result<ExpectedFile, CacheError> {
+        match self.expected.remove(path) {
+            Some(ManifestEntry::File {
+                size, mode, sha256, ..
+            }) => Ok(ExpectedFile { size, mode, sha256 }),
+            _ => Err(Self::mismatch(path)),
+        }
+    }
+
+    pub(crate) fn symlink(&mut self, path: &str, target: &str) -> Result<(), CacheError> {
+        match self.expected.remove(path) {
+            Some(ManifestEntry::Symlink { link_target, .. }) if link_target == target => Ok(()),
+            _ => Err(Self::mismatch(path)),
+        }
+    }
+
+    /// Checks that nothing listed in the manifest is missing from the archive
+    pub(crate) fn finish(self) -> Result<(), CacheError> {
+        match self
+            .order
+            .iter()
+            .find(|path| self.expected.contains_key(*path))
+        {
+            Some(path) => Err(Self::mismatch(path)),
+            None => Ok(()),
+        }
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ArtifactFileKind {
+    File,
+    Directory,
+    Symlink,
+}
+
+/// A single entry stored in an artifact
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct ArtifactFile {
+    pub path: String,
+    pub kind: ArtifactFileKind,
+    pub size: u64,
+}
+
+/// The entries of an artifact as read from the archive, along with the
+/// manifest it was written with.
+#[derive(Debug, Default)]
+pub struct ArtifactContents {
+    pub(crate) files: Vec<ArtifactFile>,
+    pub(crate) manifest: Option<ArtifactManifest>,
+    pub(crate) actual: ArtifactManifest,
+}
+
+impl ArtifactContents {
+    pub fn files(&self) -> &[ArtifactFile] {
+        &self.files
+    }
+
+    /// Artifacts written by older versions of turbo don't have a manifest
+    /// and can't be verified.
+    pub fn has_manifest(&self) -> bool {
+        self.manifest.is_some()
+    }
+
+    /// Checks the artifact against its manifest. Returns the path of the
+    /// first file that doesn't match.
+    pub fn verify(&self) -> Result<(), CacheError> {
+        match &self.manifest {
+            Some(manifest) => manifest.compare(&self.actual),
+            None => Ok(()),
+        }
+    }
+}
+
+/// Wraps a reader and hashes everything that is read through it.
+pub struct HashingReader<R> {
+    inner: R,
+    hasher: Sha256,
+    size: u64,
+}
+
+impl<R> HashingReader<R> {
+    pub fn new(inner: R) -> Self {
+        Self {
+            inner,
+            hasher: Sha256::new(),
+            size: 0,
+        }
+    }
+
+    /// Returns the number of bytes read and the hex encoded sha256 of them.
+    pub fn finish(self) -> (u64, String) {
+        (self.size, hex::encode(self.hasher.finalize()))
+    }
+}
+
+impl<R: Read> Read for HashingReader<R> {
+    fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+        let n = self.inner.read(buf)?;
+        self.hasher.update(&buf[..n]);
+        self.size += n as u64;
+        Ok(n)
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use anyhow::Result;
+    use tempfile::tempdir;
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPath};
+
+    use super::{ArtifactFile, ArtifactFileKind, ArtifactManifest};
+    use crate::{
+        cache_archive::{CacheReader, CacheWriter},
+        CacheError,
+    };
+
+    fn write_artifact(input: &AbsoluteSystemPathBuf) -> Result<Vec<u8>> {
+        input.join_component("dist").create_dir_all()?;
+        input
+            .join_components(&["dist", "index.js"])
+            .create_with_contents("hello world")?;
+        input
+            .join_component("link")
+            .symlink_to_file("dist/index.js")?;
+
+        let mut buffer = Vec::new();
+        let mut writer = CacheWriter::from_writer(&mut buffer, false, 0)?;
+        for file in ["dist", "dist/index.js", "link"] {
+            writer.add_file(input, AnchoredSystemPath::new(file)?)?;
+        }
+        writer.finish()?;
+
+        Ok(buffer)
+    }
+
+    #[test]
+    fn test_manifest_round_trip() -> Result<()> {
+        let input_dir = tempdir()?;
+        let input = AbsoluteSystemPathBuf::try_from(input_dir.path())?;
+        let artifact = write_artifact(&input)?;
+
+        let output_dir = tempdir()?;
+        let output = AbsoluteSystemPathBuf::try_from(output_dir.path())?;
+        let restored = CacheReader::from_reader(&artifact[..], false)?.restore(&output)?;
+
+        // The manifest itself is never restored to disk
+        assert_eq!(restored.len(), 3);
+        assert!(!output
+            .join_components(&[".turbo", "artifact-manifest.json"])
+            .exists());
+        Ok(())
+    }
+
+    #[test]
+    fn test_corrupted_artifact() -> Result<()> {
+        let input_dir = tempdir()?;
+        let input = AbsoluteSystemPathBuf::try_from(input_dir.path())?;
+        let mut artifact = write_artifact(&input)?;
+
+        // Tar only checksums headers, so flipping bytes in a file body goes
+        // unnoticed until the manifest is checked.
+        let offset = artifact
+            .windows(b"hello world".len())
+            .position(|window| window == b"hello world")
+            .unwrap();
+        artifact[offset..offset + 5].copy_from_slice(b"HELLO");
+
+        let output_dir = tempdir()?;
+        let output = AbsoluteSystemPathBuf::try_from(output_dir.path())?;
+        let existing = output.join_components(&["dist", "index.js"]);
+        existing.ensure_dir()?;
+        existing.create_with_contents("old contents")?;
+        let result = CacheReader::from_reader(&artifact[..], false)?.restore(&output);
+
+        assert!(matches!(
+            result,
+            Err(CacheError::IntegrityMismatch(path, _)) if path == "dist/index.js"
+        ));
+        // The corrupted file never replaces the output it would restore
+        assert_eq!(existing.read_to_string()?, "old contents");
+        assert_eq!(std::fs::read_dir(output.join_component("dist"))?.count(), 1);
+        Ok(())
+    }
+
+    #[test]
+    fn test_inspect_artifact() -> Result<()> {
+        let input_dir = tempdir()?;
+        let input = AbsoluteSystemPathBuf::try_from(input_dir.path())?;
+        let mut artifact = write_artifact(&input)?;
+
+        let contents = CacheReader::from_reader(&artifact[..], false)?.inspect()?;
+        assert!(contents.verify().is_ok());
+        assert_eq!(
+            contents.files(),
+            [
+                ArtifactFile {
+                    path: "dist".to_string(),
+                    kind: ArtifactFileKind::Directory,
+                    size: 0
+                },
+                ArtifactFile {
+                    path: "dist/index.js".to_string(),
+                    kind: ArtifactFileKind::File,
+                    size: 11
+                },
+                ArtifactFile {
+                    path: "link".to_string(),
+                    kind: ArtifactFileKind::Symlink,
+                    size: 0
+                },
+            ]
+        );
+
+        let offset = artifact
+            .windows(b"hello world".len())
+            .position(|window| window == b"hello world")
+            .unwrap();
+        artifact[offset..offset + 5].copy_from_slice(b"HELLO");
+        let contents = CacheReader::from_reader(&artifact[..], false)?.inspect()?;
+
+        assert!(matches!(
+            contents.verify(),
+            Err(CacheError::IntegrityMismatch(path, _)) if path == "dist/index.js"
+        ));
+        Ok(())
+    }
+
+    #[test]
+    fn test_unlisted_entry() -> Result<()> {
+        let mut tar_writer = tar::Builder::new(Vec::new());
+        let manifest = ArtifactManifest::default().to_bytes()?;
+        let mut header = tar::Header::new_gnu();
+        header.set_entry_type(tar::EntryType::Regular);
+        header.set_size(manifest.len() as u64);
+        header.set_mode(0o644);
+        tar_writer.append_data(&mut header, super::MANIFEST_PATH, &manifest[..])?;
+        let mut header = tar::Header::new_gnu();
+        header.set_entry_type(tar::EntryType::Regular);
+        header.set_size(5);
+        header.set_mode(0o644);
+        tar_writer.append_data(&mut header, "extra.js", &b"extra"[..])?;
+        let artifact = tar_writer.into_inner()?;
+
+        let output_dir = tempdir()?;
+        let output = AbsoluteSystemPathBuf::try_from(output_dir.path())?;
+        let result = CacheReader::from_reader(&artifact[..], false)?.restore(&output);
+
+        assert!(matches!(
+            result,
+            Err(CacheError::IntegrityMismatch(path, _)) if path == "extra.js"
+        ));
+        assert!(!output.join_component("extra.js").exists());
+        Ok(())
+    }
+
+    #[test]
+    fn test_compare_extra_entry() {
+        let mut expected = ArtifactManifest::default();
+        expected.add_file("dist/index.js", 0o644, 11, "abc".to_string());
+
+        let mut actual = ArtifactManifest::default();
+        actual.add_file("dist/index.js", 0o644, 11, "abc".to_string());
+        assert!(expected.compare(&actual).is_ok());
+
+        // Entries that aren't in the manifest are reported as well as
+        // entries that are missing from the archive
+        actual.add_symlink("link", "dist/index.js");
+        assert!(matches!(
+            expected.compare(&actual),
+            Err(CacheError::IntegrityMismatch(path, _)) if path == "link"
+        ));
+        assert!(matches!(
+            actual.compare(&expected),
+            Err(CacheError::IntegrityMismatch(path, _)) if path == "link"
+        ));
+    }
+}
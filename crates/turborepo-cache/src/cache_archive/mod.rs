@@ -1,9 +1,12 @@
 #![allow(dead_code)]
 mod create;
+mod manifest;
 mod restore;
 mod restore_directory;
 mod restore_regular;
 mod restore_symlink;
 
 pub use create::CacheWriter;
+pub(crate) use manifest::HashingReader;
+pub use manifest::{ArtifactContents, ArtifactFile, ArtifactFileKind};
 pub use restore::CacheReader;
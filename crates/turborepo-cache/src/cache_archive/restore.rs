@@ -1,4 +1,9 @@
-use std::{backtrace::Backtrace, collections::HashMap, io::Read};
+use std::{
+    backtrace::Backtrace,
+    collections::HashMap,
+    io::{self, Read},
+    path::Path,
+};
 
 use petgraph::graph::DiGraph;
 use sha2::{Digest, Sha512};
@@ -7,6 +12,10 @@ use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf
 
 use crate::{
     cache_archive::{
+        manifest::{
+            ArtifactContents, ArtifactFile, ArtifactFileKind, ArtifactManifest, ExpectedFile,
+            HashingReader, ManifestCheck, MANIFEST_PATH,
+        },
         restore_directory::{restore_directory, CachedDirTree},
         restore_regular::restore_regular,
         restore_symlink::{
@@ -18,6 +27,7 @@ use crate::{
 
 pub struct CacheReader<'a> {
     reader: Box<dyn Read + 'a>,
+    skip_unchanged: bool,
 }
 
 impl<'a> CacheReader<'a> {
@@ -28,7 +38,10 @@ impl<'a> CacheReader<'a> {
             Box::new(reader)
         };
 
-        Ok(CacheReader { reader })
+        Ok(CacheReader {
+            reader,
+            skip_unchanged: false,
+        })
     }
 
     pub fn open(path: &AbsoluteSystemPathBuf) -> Result<Self, CacheError> {
@@ -41,7 +54,17 @@ impl<'a> CacheReader<'a> {
             Box::new(file)
         };
 
-        Ok(CacheReader { reader })
+        Ok(CacheReader {
+            reader,
+            skip_unchanged: false,
+        })
+    }
+
+    /// Leaves files that already exist with the same contents untouched when
+    /// restoring, rather than rewriting every file in the artifact.
+    pub fn skip_unchanged(mut self, skip_unchanged: bool) -> Self {
+        self.skip_unchanged = skip_unchanged;
+        self
     }
 
     pub fn get_sha(mut self) -> Result<Vec<u8>, CacheError> {
@@ -80,25 +103,97 @@ impl<'a> CacheReader<'a> {
         // not apply for your path, it will clobber and re-start from the common
         // shared prefix.
         let dir_cache = CachedDirTree::new(anchor.to_owned());
+        let skip_unchanged = self.skip_unchanged;
         let mut tr = tar::Archive::new(&mut self.reader);
 
-        Self::restore_entries(&mut tr, &mut restored, dir_cache, anchor)?;
+        let trailing_manifest =
+            Self::restore_entries(&mut tr, &mut restored, dir_cache, anchor, skip_unchanged)?;
+        // Artifacts written by older versions of turbo have their manifest at the
+        // end, or no manifest at all, so they can only be checked once they've been
+        // restored
+        if let Some(manifest) = trailing_manifest {
+            manifest.verify(anchor)?;
+        }
         Ok(restored)
     }
 
+    /// Reads every entry of the artifact without restoring anything to disk.
+    pub fn inspect(&mut self) -> Result<ArtifactContents, CacheError> {
+        let mut contents = ArtifactContents::default();
+        let mut tr = tar::Archive::new(&mut self.reader);
+
+        for entry in tr.entries()? {
+            let mut entry = entry?;
+            let path = entry.path()?.to_string_lossy().into_owned();
+            if path == MANIFEST_PATH {
+                contents.manifest = Some(ArtifactManifest::read(&mut entry)?);
+                continue;
+            }
+            let path = path.trim_end_matches('/').to_string();
+
+            let (kind, size) = match entry.header().entry_type() {
+                tar::EntryType::Regular => {
+                    let mode = entry.header().mode()?;
+                    let mut reader = HashingReader::new(&mut entry);
+                    io::copy(&mut reader, &mut io::sink())?;
+                    let (size, sha256) = reader.finish();
+                    contents.actual.add_file(&path, mode, size, sha256);
+                    (ArtifactFileKind::File, size)
+                }
+                tar::EntryType::Symlink => {
+                    let target = entry
+                        .link_name()?
+                        .ok_or_else(|| CacheError::MalformedTar(Backtrace::capture()))?;
+                    contents
+                        .actual
+                        .add_symlink(&path, &target.to_string_lossy());
+                    (ArtifactFileKind::Symlink, 0)
+                }
+                tar::EntryType::Directory => (ArtifactFileKind::Directory, 0),
+                ty => {
+                    return Err(CacheError::RestoreUnsupportedFileType(
+                        ty,
+                        Backtrace::capture(),
+                    ))
+                }
+            };
+            contents.files.push(ArtifactFile { path, kind, size });
+        }
+
+        Ok(contents)
+    }
+
+    // Restores every entry, checking them against the manifest if it comes
+    // first. Returns the manifest if it only came after the entries.
     fn restore_entries<T: Read>(
         tr: &mut tar::Archive<T>,
         restored: &mut Vec<AnchoredSystemPathBuf>,
         mut dir_cache: CachedDirTree,
         anchor: &AbsoluteSystemPath,
-    ) -> Result<(), CacheError> {
+        skip_unchanged: bool,
+    ) -> Result<Option<ArtifactManifest>, CacheError> {
         // On first attempt to restore it's possible that a link target doesn't exist.
         // Save them and topologically sort them.
         let mut symlinks = Vec::new();
+        let mut check = None;
+        let mut trailing_manifest = None;
 
-        for entry in tr.entries()? {
+        for (index, entry) in tr.entries()?.enumerate() {
             let mut entry = entry?;
-            match restore_entry(&mut dir_cache, anchor, &mut entry) {
+            if entry.path()? == Path::new(MANIFEST_PATH) {
+                let manifest = ArtifactManifest::read(&mut entry)?;
+                if index == 0 {
+                    check = Some(manifest.check());
+                } else {
+                    trailing_manifest = Some(manifest);
+                }
+                continue;
+            }
+            let expected = match &mut check {
+                Some(check) => Self::check_entry(check, &entry)?,
+                None => None,
+            };
+            match restore_entry(&mut dir_cache, anchor, &mut entry, skip_unchanged, expected) {
                 Err(CacheError::LinkTargetDoesNotExist(_, _)) => {
                     symlinks.push(entry);
                 }
@@ -107,10 +202,36 @@ impl<'a> CacheReader<'a> {
             }
         }
 
+        if let Some(check) = check {
+            check.finish()?;
+        }
+
         let mut restored_symlinks =
             Self::topologically_restore_symlinks(&mut dir_cache, anchor, &symlinks)?;
         restored.append(&mut restored_symlinks);
-        Ok(())
+        Ok(trailing_manifest)
+    }
+
+    // Symlinks are checked right away, files are returned so that they can be
+    // checked as they're written
+    fn check_entry<T: Read>(
+        check: &mut ManifestCheck,
+        entry: &Entry<T>,
+    ) -> Result<Option<ExpectedFile>, CacheError> {
+        let path = entry.path()?;
+        let path = path.to_string_lossy();
+        let path = path.trim_end_matches('/');
+        match entry.header().entry_type() {
+            tar::EntryType::Regular => Ok(Some(check.file(path)?)),
+            tar::EntryType::Symlink => {
+                let target = entry
+                    .link_name()?
+                    .ok_or_else(|| CacheError::MalformedTar(Backtrace::capture()))?;
+                check.symlink(path, &target.to_string_lossy())?;
+                Ok(None)
+            }
+            _ => Ok(None),
+        }
     }
 
     fn topologically_restore_symlinks<T: Read>(
@@ -128,7 +249,9 @@ impl<'a> CacheReader<'a> {
             let processed_sourcename =
                 canonicalize_linkname(anchor, &processed_name, processed_name.as_path())?;
             // symlink must have a linkname
-            let linkname = entry.link_name()?.expect("symlink without linkname");
+            let linkname = entry
+                .link_name()?
+                .ok_or_else(|| CacheError::MalformedTar(Backtrace::capture()))?;
 
             let processed_linkname = canonicalize_linkname(anchor, &processed_name, &linkname)?;
 
@@ -165,12 +288,16 @@ fn restore_entry<T: Read>(
     dir_cache: &mut CachedDirTree,
     anchor: &AbsoluteSystemPath,
     entry: &mut Entry<T>,
+    skip_unchanged: bool,
+    expected: Option<ExpectedFile>,
 ) -> Result<AnchoredSystemPathBuf, CacheError> {
     let header = entry.header();
 
     match header.entry_type() {
         tar::EntryType::Directory => restore_directory(dir_cache, anchor, entry),
-        tar::EntryType::Regular => restore_regular(dir_cache, anchor, entry),
+        tar::EntryType::Regular => {
+            restore_regular(dir_cache, anchor, entry, skip_unchanged, expected)
+        }
         tar::EntryType::Symlink => restore_symlink(dir_cache, anchor, entry),
         ty => Err(CacheError::RestoreUnsupportedFileType(
             ty,
@@ -188,9 +315,13 @@ mod tests {
     use tempfile::{tempdir, TempDir};
     use test_case::test_case;
     use tracing::debug;
-    use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+    use turbopath::{
+        AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
+    };
 
-    use crate::cache_archive::{restore::CacheReader, restore_symlink::canonicalize_linkname};
+    use crate::cache_archive::{
+        restore::CacheReader, restore_symlink::canonicalize_linkname, CacheWriter,
+    };
 
     // Expected output of the cache
     #[derive(Debug)]
@@ -911,6 +1042,169 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_symlink_without_linkname() -> Result<()> {
+        let archive_dir = tempdir()?;
+        let archive_path = archive_dir.path().join("test.tar");
+        let mut tar_writer = tar::Builder::new(File::create(&archive_path)?);
+        let mut header = Header::new_gnu();
+        header.set_size(0);
+        header.set_entry_type(tar::EntryType::Symlink);
+        tar_writer.append_data(&mut header, "link", empty())?;
+        tar_writer.into_inner()?;
+        let archive_path = AbsoluteSystemPathBuf::try_from(archive_path)?;
+
+        let output_dir = tempdir()?;
+        let anchor = AbsoluteSystemPath::from_std_path(output_dir.path())?;
+        let result = CacheReader::open(&archive_path)?.restore(anchor);
+        assert_eq!(result.unwrap_err().to_string(), "tar file is malformed");
+        let result = CacheReader::open(&archive_path)?.inspect();
+        assert_eq!(result.unwrap_err().to_string(), "tar file is malformed");
+
+        Ok(())
+    }
+
+    fn tar_with_file(test_dir: &TempDir, path: &str, mode: u32) -> Result<AbsoluteSystemPathBuf> {
+        let archive_path = test_dir.path().join("test.tar");
+        let mut tar_writer = tar::Builder::new(File::create(&archive_path)?);
+        let body = b"#!/bin/sh\necho hello\n";
+        let mut header = Header::new_gnu();
+        header.set_size(body.len() as u64);
+        header.set_entry_type(tar::EntryType::Regular);
+        header.set_mode(mode);
+        tar_writer.append_data(&mut header, path, &body[..])?;
+        tar_writer.into_inner()?;
+
+        Ok(AbsoluteSystemPathBuf::try_from(archive_path)?)
+    }
+
+    #[cfg(unix)]
+    #[test_case(None, 0o755, 0o755 ; "executable")]
+    #[test_case(None, 0o664, 0o664 ; "group writable")]
+    #[test_case(Some(0o755), 0o644, 0o644 ; "existing file with other mode")]
+    #[test_case(None, 0o4755, 0o755 ; "setuid")]
+    #[test_case(Some(0o644), 0o2755, 0o755 ; "existing file setgid")]
+    fn test_restore_mode(existing_mode: Option<u32>, mode: u32, expected: u32) -> Result<()> {
+        use std::os::unix::fs::PermissionsExt;
+
+        let archive_dir = tempdir()?;
+        let archive_path = tar_with_file(&archive_dir, "bin/cli", mode)?;
+
+        let output_dir = tempdir()?;
+        let anchor = AbsoluteSystemPath::from_std_path(output_dir.path())?;
+        let restored_path = anchor.join_components(&["bin", "cli"]);
+        if let Some(existing_mode) = existing_mode {
+            restored_path.ensure_dir()?;
+            restored_path.create_with_contents("old contents")?;
+            restored_path.set_mode(existing_mode)?;
+        }
+
+        CacheReader::open(&archive_path)?.restore(anchor)?;
+
+        let metadata = restored_path.symlink_metadata()?;
+        assert_eq!(metadata.permissions().mode() & 0o7777, expected);
+        Ok(())
+    }
+
+    // Windows has no file modes, so the mode in the archive is ignored and
+    // restoring over an existing file leaves it writable
+    #[cfg(windows)]
+    #[test_case(None ; "new file")]
+    #[test_case(Some("old contents") ; "existing file")]
+    fn test_restore_mode(existing: Option<&str>) -> Result<()> {
+        let archive_dir = tempdir()?;
+        let archive_path = tar_with_file(&archive_dir, "bin/cli", 0o444)?;
+
+        let output_dir = tempdir()?;
+        let anchor = AbsoluteSystemPath::from_std_path(output_dir.path())?;
+        let restored_path = anchor.join_components(&["bin", "cli"]);
+        if let Some(existing) = existing {
+            restored_path.ensure_dir()?;
+            restored_path.create_with_contents(existing)?;
+        }
+
+        CacheReader::open(&archive_path)?.restore(anchor)?;
+
+        assert_eq!(restored_path.read_to_string()?, "#!/bin/sh\necho hello\n");
+        assert!(!restored_path.symlink_metadata()?.permissions().readonly());
+        Ok(())
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn test_restore_file_over_symlink() -> Result<()> {
+        let archive_dir = tempdir()?;
+        let archive_path = tar_with_file(&archive_dir, "out.sh", 0o644)?;
+
+        let outside_dir = tempdir()?;
+        let outside = AbsoluteSystemPath::from_std_path(outside_dir.path())?;
+        let outside_file = outside.join_component("outside.sh");
+        outside_file.create_with_contents("untouched")?;
+
+        let output_dir = tempdir()?;
+        let anchor = AbsoluteSystemPath::from_std_path(output_dir.path())?;
+        let restored_path = anchor.join_component("out.sh");
+        restored_path.symlink_to_file(outside_file.as_str())?;
+
+        CacheReader::open(&archive_path)?.restore(anchor)?;
+
+        assert!(!restored_path.symlink_metadata()?.is_symlink());
+        assert_eq!(outside_file.read_to_string()?, "untouched");
+        Ok(())
+    }
+
+    // An artifact written by turbo, which starts with a manifest
+    fn artifact_with_file(test_dir: &TempDir, path: &str) -> Result<AbsoluteSystemPathBuf> {
+        let test_dir = AbsoluteSystemPath::from_std_path(test_dir.path())?;
+        let input = test_dir.join_component("input");
+        input.create_dir_all()?;
+        let file = input.join_component(path);
+        file.create_with_contents("#!/bin/sh\necho hello\n")?;
+        #[cfg(unix)]
+        file.set_mode(0o644)?;
+
+        let archive_path = test_dir.join_component("test.tar");
+        let mut writer = CacheWriter::create(&archive_path, 0)?;
+        writer.add_file(&input, AnchoredSystemPath::new(path)?)?;
+        writer.finish()?;
+
+        Ok(archive_path)
+    }
+
+    #[test_case("#!/bin/sh\necho hello\n", true, true ; "unchanged")]
+    #[test_case("#!/bin/sh\necho howdy\n", true, false ; "same size")]
+    #[test_case("old contents", true, false ; "different size")]
+    #[test_case("#!/bin/sh\necho hello\n", false, false ; "unchanged without manifest")]
+    fn test_restore_skip_unchanged(existing: &str, manifest: bool, untouched: bool) -> Result<()> {
+        let archive_dir = tempdir()?;
+        let archive_path = if manifest {
+            artifact_with_file(&archive_dir, "out.sh")?
+        } else {
+            tar_with_file(&archive_dir, "out.sh", 0o644)?
+        };
+
+        let output_dir = tempdir()?;
+        let anchor = AbsoluteSystemPath::from_std_path(output_dir.path())?;
+        let restored_path = anchor.join_component("out.sh");
+        restored_path.create_with_contents(existing)?;
+        #[cfg(unix)]
+        restored_path.set_mode(0o644)?;
+        let modified = std::time::SystemTime::UNIX_EPOCH;
+        File::options()
+            .write(true)
+            .open(restored_path.as_std_path())?
+            .set_modified(modified)?;
+
+        CacheReader::open(&archive_path)?
+            .skip_unchanged(true)
+            .restore(anchor)?;
+
+        assert_eq!(restored_path.read_to_string()?, "#!/bin/sh\necho hello\n");
+        let metadata = restored_path.symlink_metadata()?;
+        assert_eq!(metadata.modified()? == modified, untouched);
+        Ok(())
+    }
+
     #[test_case(Path::new("source").try_into()?, Path::new("target"), "/Users/test/target", "C:\\Users\\test\\target" ; "hello world")]
     #[test_case(Path::new("child/source").try_into()?, Path::new("../sibling/target"), "/Users/test/sibling/target", "C:\\Users\\test\\sibling\\target" ; "Unix path subdirectory traversal")]
     #[test_case(Path::new("child/source").try_into()?, Path::new("..\\sibling\\target"), "/Users/test/child/..\\sibling\\target", "C:\\Users\\test\\sibling\\target" ; "Windows path subdirectory traversal")]
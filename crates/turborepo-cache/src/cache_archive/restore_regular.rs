@@ -1,14 +1,24 @@
-use std::{fs::OpenOptions, io, io::Read, path::Path};
+use std::{backtrace::Backtrace, fs::OpenOptions, io, io::Read, path::Path};
 
 use tar::Entry;
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf};
+use turbopath::{
+    AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
+};
 
-use crate::{cache_archive::restore_directory::CachedDirTree, CacheError};
+use crate::{
+    cache_archive::{
+        manifest::{ExpectedFile, HashingReader},
+        restore_directory::CachedDirTree,
+    },
+    CacheError,
+};
 
 pub fn restore_regular(
     dir_cache: &mut CachedDirTree,
     anchor: &AbsoluteSystemPath,
     entry: &mut Entry<impl Read>,
+    skip_unchanged: bool,
+    expected: Option<ExpectedFile>,
 ) -> Result<AnchoredSystemPathBuf, CacheError> {
     // Assuming this was a `turbo`-created input, we currently have an
     // RelativeUnixPath. Assuming this is malicious input we don't really care
@@ -25,6 +35,31 @@ pub fn restore_regular(
     dir_cache.safe_mkdir_file(anchor, &processed_name)?;
 
     let resolved_path = anchor.resolve(&processed_name);
+
+    // If a previous output left a symlink here, opening the path would write
+    // through the link, possibly to somewhere outside of the anchor.
+    if resolved_path
+        .symlink_metadata()
+        .map_or(false, |metadata| metadata.is_symlink())
+    {
+        resolved_path.remove_file()?;
+    }
+
+    if let Some(expected) = expected {
+        if skip_unchanged && matches_manifest(&resolved_path, &expected)? {
+            // Only the mode may differ, fixing it leaves the contents and
+            // modification time alone
+            #[cfg(unix)]
+            resolved_path.set_mode(expected.mode & 0o777)?;
+        } else {
+            restore_verified(&resolved_path, &processed_name, entry, &expected)?;
+        }
+        return Ok(processed_name);
+    }
+
+    // Artifacts written by older versions of turbo don't list their files
+    // before them, so there's nothing to compare existing files with and
+    // they're always restored in full.
     let mut open_options = OpenOptions::new();
     open_options.write(true).truncate(true).create(true);
 
@@ -32,15 +67,106 @@ pub fn restore_regular(
     {
         use std::os::unix::fs::OpenOptionsExt;
         let header = entry.header();
-        open_options.mode(header.mode()?);
+        open_options.mode(header.mode()? & 0o777);
     }
 
     let mut file = open_options.open(resolved_path.as_path())?;
     io::copy(entry, &mut file)?;
 
+    // The mode passed when opening is only used if the file is created and is
+    // subject to the umask, so set it explicitly to match the cached file.
+    // Setuid, setgid and sticky bits are never restored.
+    #[cfg(unix)]
+    {
+        use std::{fs::Permissions, os::unix::fs::PermissionsExt};
+        let mode = entry.header().mode()?;
+        file.set_permissions(Permissions::from_mode(mode & 0o777))?;
+    }
+    #[cfg(windows)]
+    let _ = file;
+
     Ok(processed_name)
 }
 
+// Whether the file on disk has the size and hash listed in the manifest. Its
+// contents are only read if the size matches. Artifacts are written with a
+// zero mtime so they're reproducible, so there's no modification time to
+// compare with.
+fn matches_manifest(
+    path: &AbsoluteSystemPath,
+    expected: &ExpectedFile,
+) -> Result<bool, CacheError> {
+    let matches_size = path.symlink_metadata().map_or(false, |metadata| {
+        metadata.is_file() && metadata.len() == expected.size
+    });
+    if !matches_size {
+        return Ok(false);
+    }
+
+    let mut reader = HashingReader::new(path.open()?);
+    io::copy(&mut reader, &mut io::sink())?;
+    let (size, sha256) = reader.finish();
+    Ok(size == expected.size && sha256 == expected.sha256)
+}
+
+// Writes the entry next to its destination and only moves it into place once
+// it matches the manifest, so a corrupted artifact never replaces an output.
+fn restore_verified(
+    path: &AbsoluteSystemPath,
+    name: &AnchoredSystemPath,
+    entry: &mut Entry<impl Read>,
+    expected: &ExpectedFile,
+) -> Result<(), CacheError> {
+    let mismatch =
+        || CacheError::IntegrityMismatch(name.to_unix().to_string(), Backtrace::capture());
+    let mode = entry.header().mode()?;
+    if entry.size() != expected.size || mode != expected.mode {
+        return Err(mismatch());
+    }
+
+    // Other turbo processes may be restoring the same output
+    let tmp_path =
+        AbsoluteSystemPathBuf::new(format!("{}.{}.turbo-tmp", path, std::process::id()))?;
+    let result = write_verified(&tmp_path, entry, mode, expected).and_then(|matches| {
+        if matches {
+            Ok(tmp_path.rename(path)?)
+        } else {
+            Err(mismatch())
+        }
+    });
+    if result.is_err() {
+        let _ = tmp_path.remove_file();
+    }
+    result
+}
+
+// Returns whether what was written matches the manifest
+fn write_verified(
+    path: &AbsoluteSystemPath,
+    entry: &mut Entry<impl Read>,
+    mode: u32,
+    expected: &ExpectedFile,
+) -> Result<bool, CacheError> {
+    let mut open_options = OpenOptions::new();
+    open_options.write(true).truncate(true).create(true);
+    let mut file = path.open_with_options(open_options)?;
+
+    let mut reader = HashingReader::new(entry);
+    io::copy(&mut reader, &mut file)?;
+    let (size, sha256) = reader.finish();
+
+    // Setuid, setgid and sticky bits are never restored
+    #[cfg(unix)]
+    {
+        use std::{fs::Permissions, os::unix::fs::PermissionsExt};
+        file.set_permissions(Permissions::from_mode(mode & 0o777))?;
+    }
+    #[cfg(windows)]
+    let _ = mode;
+
+    Ok(size == expected.size && sha256 == expected.sha256)
+}
+
 impl CachedDirTree {
     pub fn safe_mkdir_file(
         &mut self,
@@ -1,6 +1,5 @@
-use std::{backtrace::Backtrace, io::Read};
+use std::{backtrace::Backtrace, io::Read, path::Path};
 
-use camino::Utf8Path;
 use turbopath::{
     AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
     PathError, UnknownPathType,
@@ -65,7 +64,10 @@ fn actually_restore_symlink<'a>(
         )
     })?;
 
-    if Utf8Path::new(symlink_to).is_dir() {
+    // Relative link targets are relative to the link, not to the current
+    // directory, so resolve them before checking what kind of link to create.
+    let resolved_target = canonicalize_linkname(anchor, processed_name, Path::new(symlink_to))?;
+    if resolved_target.as_std_path().is_dir() {
         symlink_from.symlink_to_dir(symlink_to)?;
     } else {
         symlink_from.symlink_to_file(symlink_to)?;
@@ -88,7 +90,7 @@ fn actually_restore_symlink<'a>(
 // system will be when linkname is restored verbatim.
 pub fn canonicalize_linkname(
     anchor: &AbsoluteSystemPath,
-    processed_name: &AnchoredSystemPathBuf,
+    processed_name: &AnchoredSystemPath,
     linkname: &std::path::Path,
 ) -> Result<AbsoluteSystemPathBuf, CacheError> {
     let linkname = linkname.try_into().map_err(|_| {
@@ -1,25 +1,75 @@
-use std::{backtrace::Backtrace, fs::OpenOptions};
+use std::{
+    backtrace::Backtrace,
+    fs::{File, OpenOptions},
+    io::{self, Read},
+    time::{SystemTime, UNIX_EPOCH},
+};
 
 use camino::Utf8Path;
 use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use tracing::{debug, warn};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
 use turborepo_analytics::AnalyticsSender;
 use turborepo_api_client::{analytics, analytics::AnalyticsEvent};
 
 use crate::{
-    cache_archive::{CacheReader, CacheWriter},
+    cache_archive::{CacheReader, CacheWriter, HashingReader},
     CacheError, CacheHitMetadata, CacheSource,
 };
 
+// Artifacts are written here first and then moved into the cache directory,
+// so that other processes never read a partially written artifact
+const TMP_DIR: &str = "tmp";
+
 pub struct FSCache {
     cache_directory: AbsoluteSystemPathBuf,
     analytics_recorder: Option<AnalyticsSender>,
+    // Maximum size in bytes of the cache directory. If set, `evict` removes the
+    // least recently used artifacts once the cache has grown past it. Putting
+    // an artifact doesn't evict, since that has to scan the whole directory.
+    max_size: Option<u64>,
+    compression_level: i32,
+    // Leaves restored files that haven't changed untouched
+    skip_unchanged: bool,
+    // See `CacheOpts::strict`
+    strict: bool,
+    // Read-only caches are shared with others, so corrupted artifacts are left
+    // for whoever writes to them
+    read_only: bool,
+}
+
+// An artifact on disk along with the information needed to decide
+// whether it should be evicted.
+struct CacheEntry {
+    hash: String,
+    archive_path: AbsoluteSystemPathBuf,
+    size: u64,
+    last_used: SystemTime,
 }
 
-#[derive(Debug, Deserialize, Serialize)]
+/// An artifact stored in the local cache
+#[derive(Debug, Clone)]
+pub struct LocalArtifact {
+    pub hash: String,
+    pub path: AbsoluteSystemPathBuf,
+    /// Size in bytes of the artifact and its metadata
+    pub size: u64,
+    pub last_used: SystemTime,
+    /// Time in milliseconds the task took to run when it was cached
+    pub time_saved: Option<u64>,
+}
+
+#[derive(Debug, PartialEq, Deserialize, Serialize)]
 struct CacheMetadata {
     hash: String,
     duration: u64,
+    // The size and SHA-256 of the archive, which catch archives that were
+    // truncated by an interrupted write. Older artifacts don't record them.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    size: Option<u64>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    sha256: Option<String>,
 }
 
 impl CacheMetadata {
@@ -27,6 +77,38 @@ impl CacheMetadata {
         serde_json::from_str(&path.read_to_string()?)
             .map_err(|e| CacheError::InvalidMetadata(e, Backtrace::capture()))
     }
+
+    // Checks the size and checksum of an archive against the ones it was
+    // written with
+    fn verify(&self, actual_size: u64, actual_sha256: &str) -> Result<(), CacheError> {
+        let (Some(size), Some(sha256)) = (self.size, &self.sha256) else {
+            return Ok(());
+        };
+        if actual_size != size || actual_sha256 != sha256 {
+            return Err(CacheError::ArchiveMismatch(
+                self.hash.clone(),
+                Backtrace::capture(),
+            ));
+        }
+
+        Ok(())
+    }
+}
+
+// Returns the size and hex encoded SHA-256 of a file
+fn checksum(file: &mut File) -> Result<(u64, String), io::Error> {
+    let mut hasher = Sha256::new();
+    let mut buffer = vec![0; 2usize.pow(16)];
+    let mut size = 0;
+    loop {
+        let read = file.read(&mut buffer)?;
+        if read == 0 {
+            break;
+        }
+        hasher.update(&buffer[..read]);
+        size += read as u64;
+    }
+    Ok((size, hex::encode(hasher.finalize())))
 }
 
 impl FSCache {
@@ -46,6 +128,9 @@ impl FSCache {
         override_dir: Option<&Utf8Path>,
         repo_root: &AbsoluteSystemPath,
         analytics_recorder: Option<AnalyticsSender>,
+        max_size: Option<u64>,
+        compression_level: i32,
+        skip_unchanged: bool,
     ) -> Result<Self, CacheError> {
         let cache_directory = Self::resolve_cache_dir(repo_root, override_dir);
         cache_directory.create_dir_all()?;
@@ -53,9 +138,33 @@ impl FSCache {
         Ok(FSCache {
             cache_directory,
             analytics_recorder,
+            max_size,
+            compression_level,
+            skip_unchanged,
+            strict: false,
+            read_only: false,
         })
     }
 
+    pub fn strict(mut self, strict: bool) -> Self {
+        self.strict = strict;
+        self
+    }
+
+    pub fn read_only(mut self, read_only: bool) -> Self {
+        self.read_only = read_only;
+        self
+    }
+
+    pub fn is_read_only(&self) -> bool {
+        self.read_only
+    }
+
+    fn metadata_path(&self, hash: &str) -> AbsoluteSystemPathBuf {
+        self.cache_directory
+            .join_component(&format!("{}-meta.json", hash))
+    }
+
     fn log_fetch(&self, event: analytics::CacheEvent, hash: &str, duration: u64) {
         // If analytics fails to record, it's not worth failing the cache
         if let Some(analytics_recorder) = &self.analytics_recorder {
@@ -71,7 +180,7 @@ impl FSCache {
         }
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub fn fetch(
         &self,
         anchor: &AbsoluteSystemPath,
@@ -93,15 +202,50 @@ impl FSCache {
             return Ok(None);
         };
 
-        let mut cache_reader = CacheReader::open(&cache_path)?;
+        // Archives are moved into place before their metadata, so the metadata is
+        // read first: if it's from a new write, so is the archive opened after it.
+        let meta = match CacheMetadata::read(&self.metadata_path(hash)) {
+            Ok(meta) => meta,
+            // The artifact is still being written
+            Err(CacheError::IO(e, _)) if e.kind() == io::ErrorKind::NotFound => {
+                self.log_fetch(analytics::CacheEvent::Miss, hash, 0);
+                return Ok(None);
+            }
+            Err(e) => return Err(e),
+        };
 
-        let restored_files = cache_reader.restore(anchor)?;
+        let restored_files = match self.restore(anchor, &cache_path, &meta) {
+            Ok(restored_files) => restored_files,
+            Err(e @ (CacheError::IntegrityMismatch(..) | CacheError::ArchiveMismatch(..))) => {
+                self.log_fetch(analytics::CacheEvent::Miss, hash, 0);
+                // The artifact is kept so that it can be inspected
+                if self.strict {
+                    return Err(e);
+                }
+                // If another process replaced the artifact while it was being
+                // restored, its metadata changed as well and the new artifact is
+                // fine. Otherwise a corrupted artifact would fail every future
+                // fetch, so remove it and let the task run again.
+                let replaced = CacheMetadata::read(&self.metadata_path(hash))
+                    .map_or(true, |current| current != meta);
+                if !replaced && !self.read_only {
+                    if let Err(e) = self.remove(hash, cache_path) {
+                        debug!("failed to remove corrupted artifact {}: {}", hash, e);
+                    }
+                }
+                warn!("{e}, treating {hash} as a cache miss");
+                return Ok(None);
+            }
+            Err(e) => return Err(e),
+        };
 
-        let meta = CacheMetadata::read(
-            &self
-                .cache_directory
-                .join_component(&format!("{}-meta.json", hash)),
-        )?;
+        if self.max_size.is_some() {
+            // Failing to record the access only makes the artifact a more
+            // likely eviction candidate, it shouldn't fail the fetch.
+            if let Err(e) = Self::mark_used(&cache_path) {
+                debug!("failed to update access time for {}: {}", cache_path, e);
+            }
+        }
 
         self.log_fetch(analytics::CacheEvent::Hit, hash, meta.duration);
 
@@ -114,7 +258,29 @@ impl FSCache {
         )))
     }
 
-    #[tracing::instrument(skip_all)]
+    // Restores the archive while hashing it, so that it's only read once. Its
+    // files are checked against the manifest as they're restored, the archive
+    // as a whole is checked once it has been read.
+    fn restore(
+        &self,
+        anchor: &AbsoluteSystemPath,
+        cache_path: &AbsoluteSystemPath,
+        meta: &CacheMetadata,
+    ) -> Result<Vec<AnchoredSystemPathBuf>, CacheError> {
+        let mut archive = HashingReader::new(cache_path.open()?);
+        let is_compressed = cache_path.extension() == Some("zst");
+        let restored = CacheReader::from_reader(&mut archive, is_compressed)
+            .and_then(|reader| reader.skip_unchanged(self.skip_unchanged).restore(anchor));
+        // A truncated archive fails to restore, but the reason is that it
+        // doesn't match what was written
+        io::copy(&mut archive, &mut io::sink())?;
+        let (size, sha256) = archive.finish();
+        meta.verify(size, &sha256)?;
+
+        restored
+    }
+
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub(crate) fn exists(&self, hash: &str) -> Result<Option<CacheHitMetadata>, CacheError> {
         let uncompressed_cache_path = self
             .cache_directory
@@ -127,13 +293,12 @@ impl FSCache {
             return Ok(None);
         }
 
-        let duration = CacheMetadata::read(
-            &self
-                .cache_directory
-                .join_component(&format!("{}-meta.json", hash)),
-        )
-        .map(|meta| meta.duration)
-        .unwrap_or(0);
+        let duration = match CacheMetadata::read(&self.metadata_path(hash)) {
+            Ok(meta) => meta.duration,
+            // The artifact is still being written
+            Err(CacheError::IO(e, _)) if e.kind() == io::ErrorKind::NotFound => return Ok(None),
+            Err(_) => 0,
+        };
 
         Ok(Some(CacheHitMetadata {
             time_saved: duration,
@@ -141,40 +306,201 @@ impl FSCache {
         }))
     }
 
-    #[tracing::instrument(skip_all)]
+    /// Writes the artifact to the cache. `compression_level` overrides the
+    /// level the cache was created with.
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub fn put(
         &self,
         anchor: &AbsoluteSystemPath,
         hash: &str,
         files: &[AnchoredSystemPathBuf],
         duration: u64,
+        compression_level: Option<i32>,
     ) -> Result<(), CacheError> {
         let cache_path = self
             .cache_directory
             .join_component(&format!("{}.tar.zst", hash));
-
-        let mut cache_item = CacheWriter::create(&cache_path)?;
+        let metadata_path = self.metadata_path(hash);
+
+        // Other turbo processes may be writing the same artifact, so each one
+        // writes its own temporary files
+        let tmp_dir = self.cache_directory.join_component(TMP_DIR);
+        tmp_dir.create_dir_all()?;
+        let tmp_name = format!("{}-{}", hash, std::process::id());
+        let tmp_cache_path = tmp_dir.join_component(&format!("{}.tar.zst", tmp_name));
+        let tmp_metadata_path = tmp_dir.join_component(&format!("{}-meta.json", tmp_name));
+
+        let mut cache_item = CacheWriter::create(
+            &tmp_cache_path,
+            compression_level.unwrap_or(self.compression_level),
+        )?;
 
         for file in files {
             cache_item.add_file(anchor, file)?;
         }
+        cache_item.finish()?;
 
-        let metadata_path = self
-            .cache_directory
-            .join_component(&format!("{}-meta.json", hash));
+        // The archive is flushed to disk before it's moved into place, otherwise
+        // a crash could leave a renamed but truncated archive behind
+        let mut archive_options = OpenOptions::new();
+        archive_options.read(true).write(true);
+        let mut archive_file = tmp_cache_path.open_with_options(archive_options)?;
+        let (size, sha256) = checksum(&mut archive_file)?;
+        archive_file.sync_all()?;
 
         let meta = CacheMetadata {
             hash: hash.to_string(),
             duration,
+            size: Some(size),
+            sha256: Some(sha256),
         };
 
         let mut metadata_options = OpenOptions::new();
         metadata_options.create(true).write(true).truncate(true);
 
-        let metadata_file = metadata_path.open_with_options(metadata_options)?;
+        let metadata_file = tmp_metadata_path.open_with_options(metadata_options)?;
 
-        serde_json::to_writer(metadata_file, &meta)
+        serde_json::to_writer(&metadata_file, &meta)
             .map_err(|e| CacheError::InvalidMetadata(e, Backtrace::capture()))?;
+        metadata_file.sync_all()?;
+
+        // An archive without metadata is a miss, so the archive is moved into
+        // place first. A fetch that reads the metadata of an earlier write of this
+        // hash before it's replaced sees it change afterwards, and won't remove
+        // the artifact as corrupted.
+        tmp_cache_path.rename(&cache_path)?;
+        tmp_metadata_path.rename(&metadata_path)?;
+        // The renames themselves only survive a crash once the directory is
+        // flushed. Windows can't open directories as files, and flushes them
+        // with the rename.
+        #[cfg(unix)]
+        File::open(self.cache_directory.as_std_path())?.sync_all()?;
+
+        Ok(())
+    }
+
+    // Bumps the modification time of an artifact so that it is treated as
+    // recently used when deciding what to evict.
+    fn mark_used(path: &AbsoluteSystemPath) -> Result<(), io::Error> {
+        let mut options = OpenOptions::new();
+        options.write(true);
+        path.open_with_options(options)?
+            .set_modified(SystemTime::now())
+    }
+
+    fn entries(&self) -> Result<Vec<CacheEntry>, CacheError> {
+        let mut entries = Vec::new();
+        for dir_entry in std::fs::read_dir(&self.cache_directory)? {
+            let dir_entry = dir_entry?;
+            let file_name = dir_entry.file_name();
+            let Some(file_name) = file_name.to_str() else {
+                continue;
+            };
+            let Some(hash) = file_name
+                .strip_suffix(".tar.zst")
+                .or_else(|| file_name.strip_suffix(".tar"))
+            else {
+                continue;
+            };
+
+            let metadata = match dir_entry.metadata() {
+                Ok(metadata) => metadata,
+                // Another process may have evicted this artifact in the meantime
+                Err(e) if e.kind() == io::ErrorKind::NotFound => continue,
+                Err(e) => return Err(e.into()),
+            };
+            let metadata_size = std::fs::metadata(self.metadata_path(hash))
+                .map(|metadata| metadata.len())
+                .unwrap_or_default();
+
+            entries.push(CacheEntry {
+                hash: hash.to_string(),
+                archive_path: self.cache_directory.join_component(file_name),
+                size: metadata.len() + metadata_size,
+                last_used: metadata.modified().unwrap_or(UNIX_EPOCH),
+            });
+        }
+
+        Ok(entries)
+    }
+
+    // Removes an artifact and its metadata, ignoring files that are already
+    // gone.
+    fn remove(&self, hash: &str, archive_path: AbsoluteSystemPathBuf) -> Result<(), CacheError> {
+        for path in [archive_path, self.metadata_path(hash)] {
+            match path.remove_file() {
+                Ok(()) => {}
+                Err(e) if e.kind() == io::ErrorKind::NotFound => {}
+                Err(e) => return Err(e.into()),
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Lists the artifacts in the cache directory, most recently used first.
+    pub fn artifacts(&self) -> Result<Vec<LocalArtifact>, CacheError> {
+        let mut artifacts = self
+            .entries()?
+            .into_iter()
+            .map(|entry| LocalArtifact {
+                time_saved: CacheMetadata::read(&self.metadata_path(&entry.hash))
+                    .ok()
+                    .map(|meta| meta.duration),
+                hash: entry.hash,
+                path: entry.archive_path,
+                size: entry.size,
+                last_used: entry.last_used,
+            })
+            .collect::<Vec<_>>();
+        artifacts.sort_by(|a, b| b.last_used.cmp(&a.last_used));
+
+        Ok(artifacts)
+    }
+
+    pub fn artifact(&self, hash: &str) -> Result<Option<LocalArtifact>, CacheError> {
+        Ok(self
+            .artifacts()?
+            .into_iter()
+            .find(|artifact| artifact.hash == hash))
+    }
+
+    /// Removes an artifact and its metadata. Returns false if there was no
+    /// artifact for `hash`.
+    pub fn remove_artifact(&self, hash: &str) -> Result<bool, CacheError> {
+        let Some(artifact) = self.artifact(hash)? else {
+            return Ok(false);
+        };
+        self.remove(&artifact.hash, artifact.path)?;
+
+        Ok(true)
+    }
+
+    /// Removes the least recently used artifacts until the cache directory
+    /// is no larger than the configured max size. Does nothing if no max size
+    /// is configured.
+    #[tracing::instrument(skip_all)]
+    pub(crate) fn evict(&self) -> Result<(), CacheError> {
+        let Some(max_size) = self.max_size else {
+            return Ok(());
+        };
+
+        let mut entries = self.entries()?;
+        let mut total_size: u64 = entries.iter().map(|entry| entry.size).sum();
+        if total_size <= max_size {
+            return Ok(());
+        }
+
+        entries.sort_by_key(|entry| entry.last_used);
+        for entry in entries {
+            if total_size <= max_size {
+                break;
+            }
+
+            debug!("evicting {} from local cache", entry.hash);
+            self.remove(&entry.hash, entry.archive_path)?;
+            total_size = total_size.saturating_sub(entry.size);
+        }
 
         Ok(())
     }
@@ -182,14 +508,14 @@ impl FSCache {
 
 #[cfg(test)]
 mod test {
-    use std::time::Duration;
+    use std::time::{Duration, SystemTime};
 
     use anyhow::Result;
     use futures::future::try_join_all;
     use tempfile::tempdir;
     use turbopath::AnchoredSystemPath;
     use turborepo_analytics::start_analytics;
-    use turborepo_api_client::{APIAuth, APIClient};
+    use turborepo_api_client::{APIAuth, APIClient, CacheTimeouts, TlsOptions};
     use turborepo_vercel_api_mock::start_test_server;
 
     use super::*;
@@ -221,9 +547,10 @@ mod test {
         let api_client = APIClient::new(
             format!("http://localhost:{}", port),
             Some(Duration::from_secs(200)),
-            None,
+            CacheTimeouts::default(),
             "2.0.0",
             true,
+            &TlsOptions::default(),
         )?;
         let api_auth = APIAuth {
             team_id: Some("my-team".to_string()),
@@ -233,7 +560,14 @@ mod test {
         let (analytics_sender, analytics_handle) =
             start_analytics(api_auth.clone(), api_client.clone());
 
-        let cache = FSCache::new(None, repo_root_path, Some(analytics_sender.clone()))?;
+        let cache = FSCache::new(
+            None,
+            repo_root_path,
+            Some(analytics_sender.clone()),
+            None,
+            0,
+            false,
+        )?;
 
         let expected_miss = cache.fetch(repo_root_path, test_case.hash)?;
         assert!(expected_miss.is_none());
@@ -243,7 +577,13 @@ mod test {
             .iter()
             .map(|f| f.path().to_owned())
             .collect();
-        cache.put(repo_root_path, test_case.hash, &files, test_case.duration)?;
+        cache.put(
+            repo_root_path,
+            test_case.hash,
+            &files,
+            test_case.duration,
+            None,
+        )?;
 
         let (status, files) = cache.fetch(repo_root_path, test_case.hash)?.unwrap();
 
@@ -270,4 +610,196 @@ mod test {
         analytics_handle.close_with_timeout().await;
         Ok(())
     }
+
+    #[test]
+    fn test_evicts_least_recently_used() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPath::from_std_path(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root_path
+            .resolve(&output)
+            .create_with_contents("some task output")?;
+
+        let hashes = ["oldest", "middle", "newest"];
+        let cache = FSCache::new(None, repo_root_path, None, None, 0, false)?;
+        let now = SystemTime::now();
+        for (i, hash) in hashes.iter().enumerate() {
+            cache.put(repo_root_path, hash, &[output.clone()], 0, None)?;
+            let archive_path = cache
+                .cache_directory
+                .join_component(&format!("{}.tar.zst", hash));
+            let mut options = OpenOptions::new();
+            options.write(true);
+            archive_path
+                .open_with_options(options)?
+                .set_modified(now - Duration::from_secs(60 * (hashes.len() - i) as u64))?;
+        }
+
+        let artifact_size = cache.entries()?[0].size;
+
+        // Room for two artifacts, so only the oldest one should be evicted
+        let cache = FSCache::new(
+            None,
+            repo_root_path,
+            None,
+            Some(2 * artifact_size),
+            0,
+            false,
+        )?;
+        cache.evict()?;
+        assert!(cache.exists("oldest")?.is_none());
+        assert!(cache.exists("middle")?.is_some());
+        assert!(cache.exists("newest")?.is_some());
+
+        // A cache hit marks the artifact as used, so it should outlive
+        // an artifact that was written more recently.
+        cache.fetch(repo_root_path, "middle")?;
+        let cache = FSCache::new(None, repo_root_path, None, Some(artifact_size), 0, false)?;
+        cache.evict()?;
+        assert!(cache.exists("middle")?.is_some());
+        assert!(cache.exists("newest")?.is_none());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_list_inspect_and_remove_artifacts() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPath::from_std_path(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root_path
+            .resolve(&output)
+            .create_with_contents("some task output")?;
+
+        let cache = FSCache::new(None, repo_root_path, None, None, 0, false)?;
+        cache.put(repo_root_path, "first", &[output.clone()], 1200, None)?;
+        cache.put(repo_root_path, "second", &[output.clone()], 0, None)?;
+
+        let mut hashes = cache
+            .artifacts()?
+            .into_iter()
+            .map(|artifact| artifact.hash)
+            .collect::<Vec<_>>();
+        hashes.sort();
+        assert_eq!(hashes, ["first", "second"]);
+
+        let artifact = cache.artifact("first")?.unwrap();
+        assert_eq!(artifact.time_saved, Some(1200));
+        let contents = CacheReader::open(&artifact.path)?.inspect()?;
+        assert!(contents.has_manifest());
+        assert!(contents.verify().is_ok());
+        assert_eq!(
+            contents
+                .files()
+                .iter()
+                .map(|file| (file.path.as_str(), file.size))
+                .collect::<Vec<_>>(),
+            [("output.txt", 16)]
+        );
+
+        assert!(cache.remove_artifact("first")?);
+        assert!(!cache.remove_artifact("first")?);
+        assert!(cache.artifact("first")?.is_none());
+        assert!(cache.exists("second")?.is_some());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_put_replaces_artifact() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPath::from_std_path(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        let output_path = repo_root_path.resolve(&output);
+
+        let cache = FSCache::new(None, repo_root_path, None, None, 0, false)?;
+        output_path.create_with_contents("first output")?;
+        cache.put(repo_root_path, "some-hash", &[output.clone()], 100, None)?;
+        output_path.create_with_contents("second output")?;
+        cache.put(repo_root_path, "some-hash", &[output.clone()], 200, None)?;
+
+        // Temporary files are moved into place, not left behind
+        let tmp_dir = cache.cache_directory.join_component(TMP_DIR);
+        assert_eq!(std::fs::read_dir(&tmp_dir)?.count(), 0);
+        assert_eq!(cache.artifacts()?.len(), 1);
+
+        output_path.remove_file()?;
+        let (status, _) = cache.fetch(repo_root_path, "some-hash")?.unwrap();
+        assert_eq!(status.time_saved, 200);
+        assert_eq!(output_path.read_to_string()?, "second output");
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_archive_without_metadata_is_a_miss() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPath::from_std_path(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root_path
+            .resolve(&output)
+            .create_with_contents("some task output")?;
+
+        let cache = FSCache::new(None, repo_root_path, None, None, 0, false)?;
+        cache.put(repo_root_path, "some-hash", &[output.clone()], 0, None)?;
+        // Another process has moved the archive into place but not its metadata
+        cache.metadata_path("some-hash").remove_file()?;
+
+        assert!(cache.exists("some-hash")?.is_none());
+        assert!(cache.fetch(repo_root_path, "some-hash")?.is_none());
+        assert!(cache.artifact("some-hash")?.is_some());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_truncated_archive_is_a_miss() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPath::from_std_path(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root_path
+            .resolve(&output)
+            .create_with_contents("some task output")?;
+
+        let cache = FSCache::new(None, repo_root_path, None, None, 0, false)?;
+        cache.put(repo_root_path, "some-hash", &[output.clone()], 0, None)?;
+        let archive_path = cache.artifact("some-hash")?.unwrap().path;
+        let size = archive_path.symlink_metadata()?.len();
+        let mut options = OpenOptions::new();
+        options.write(true);
+        archive_path.open_with_options(options)?.set_len(size / 2)?;
+
+        assert!(cache.fetch(repo_root_path, "some-hash")?.is_none());
+        // The truncated archive is removed so that the task can be cached again
+        assert!(cache.exists("some-hash")?.is_none());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_strict_truncated_archive_is_an_error() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root_path = AbsoluteSystemPath::from_std_path(repo_root.path())?;
+        let output = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root_path
+            .resolve(&output)
+            .create_with_contents("some task output")?;
+
+        let cache = FSCache::new(None, repo_root_path, None, None, 0, false)?.strict(true);
+        cache.put(repo_root_path, "some-hash", &[output.clone()], 0, None)?;
+        let archive_path = cache.artifact("some-hash")?.unwrap().path;
+        let size = archive_path.symlink_metadata()?.len();
+        let mut options = OpenOptions::new();
+        options.write(true);
+        archive_path.open_with_options(options)?.set_len(size / 2)?;
+
+        assert!(matches!(
+            cache.fetch(repo_root_path, "some-hash"),
+            Err(CacheError::ArchiveMismatch(..))
+        ));
+        // The artifact is kept so that it can be inspected
+        assert!(cache.exists("some-hash")?.is_some());
+
+        Ok(())
+    }
 }
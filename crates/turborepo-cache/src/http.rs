@@ -3,20 +3,22 @@ use std::{
     collections::HashMap,
     io::{Cursor, Write},
     sync::{Arc, Mutex},
+    time::Instant,
 };
 
 use tokio_stream::StreamExt;
-use tracing::debug;
+use tracing::{debug, warn};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
 use turborepo_analytics::AnalyticsSender;
 use turborepo_api_client::{
     analytics::{self, AnalyticsEvent},
-    APIAuth, APIClient, CacheClient, Response,
+    APIAuth, APIClient, Bytes, CacheClient, Response,
 };
 
 use crate::{
     cache_archive::{CacheReader, CacheWriter},
     signature_authentication::ArtifactSignatureAuthenticator,
+    transfer::{within_download_timeout, TransferTracker},
     upload_progress::{UploadProgress, UploadProgressQuery},
     CacheError, CacheHitMetadata, CacheOpts, CacheSource,
 };
@@ -30,6 +32,11 @@ pub struct HTTPCache {
     api_auth: APIAuth,
     analytics_recorder: Option<AnalyticsSender>,
     uploads: Arc<Mutex<UploadMap>>,
+    transfers: TransferTracker,
+    compression_level: i32,
+    skip_unchanged: bool,
+    // See `CacheOpts::strict`
+    strict: bool,
 }
 
 impl HTTPCache {
@@ -59,26 +66,38 @@ impl HTTPCache {
             None
         };
 
+        let transfers = TransferTracker::default();
         HTTPCache {
             client,
             signer_verifier,
             repo_root,
-            uploads: Arc::new(Mutex::new(HashMap::new())),
+            uploads: transfers.uploads(),
+            transfers,
             api_auth,
             analytics_recorder,
+            compression_level: opts.compression_level,
+            skip_unchanged: opts.skip_unchanged,
+            strict: opts.strict,
         }
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub async fn put(
         &self,
         anchor: &AbsoluteSystemPath,
         hash: &str,
         files: &[AnchoredSystemPathBuf],
         duration: u64,
+        compression_level: Option<i32>,
     ) -> Result<(), CacheError> {
         let mut artifact_body = Vec::new();
-        self.write(&mut artifact_body, anchor, files).await?;
+        self.write(
+            &mut artifact_body,
+            anchor,
+            files,
+            compression_level.unwrap_or(self.compression_level),
+        )
+        .await?;
         let bytes = artifact_body.len();
 
         let tag = self
@@ -87,29 +106,36 @@ impl HTTPCache {
             .map(|signer| signer.generate_tag(hash.as_bytes(), &artifact_body))
             .transpose()?;
 
-        let stream = tokio_util::codec::FramedRead::new(
-            Cursor::new(artifact_body),
-            tokio_util::codec::BytesCodec::new(),
-        )
-        .map(|res| {
-            res.map(|bytes| bytes.freeze())
-                .map_err(turborepo_api_client::Error::from)
-        });
+        let artifact_body = Bytes::from(artifact_body);
+        // Called once per upload attempt, so a retried upload restarts its progress
+        let upload_body = || {
+            let stream = tokio_util::codec::FramedRead::new(
+                Cursor::new(artifact_body.clone()),
+                tokio_util::codec::BytesCodec::new(),
+            )
+            .map(|res| {
+                res.map(|bytes| bytes.freeze())
+                    .map_err(turborepo_api_client::Error::from)
+            });
 
-        let (progress, query) = UploadProgress::<10, 100, _>::new(stream, Some(bytes));
+            let (progress, query) = UploadProgress::<10, 100, _>::new(stream, Some(bytes));
 
-        {
-            let mut uploads = self.uploads.lock().unwrap();
-            uploads.insert(hash.to_string(), query);
-        }
+            {
+                let mut uploads = self.uploads.lock().unwrap();
+                uploads.insert(hash.to_string(), query);
+            }
+
+            progress
+        };
 
         tracing::debug!("uploading {}", hash);
 
+        let start = Instant::now();
         match self
             .client
             .put_artifact(
                 hash,
-                progress,
+                upload_body,
                 duration,
                 tag.as_deref(),
                 &self.api_auth.token,
@@ -120,6 +146,7 @@ impl HTTPCache {
         {
             Ok(_) => {
                 tracing::debug!("uploaded {}", hash);
+                self.transfers.record_upload(bytes, start.elapsed());
                 Ok(())
             }
             Err(turborepo_api_client::Error::ReqwestError(e)) if e.is_timeout() => {
@@ -138,16 +165,18 @@ impl HTTPCache {
         writer: impl Write,
         anchor: &AbsoluteSystemPath,
         files: &[AnchoredSystemPathBuf],
+        compression_level: i32,
     ) -> Result<(), CacheError> {
-        let mut cache_archive = CacheWriter::from_writer(writer, true)?;
+        let mut cache_archive = CacheWriter::from_writer(writer, true, compression_level)?;
         for file in files {
             cache_archive.add_file(anchor, file)?;
         }
+        cache_archive.finish()?;
 
         Ok(())
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub async fn exists(&self, hash: &str) -> Result<Option<CacheHitMetadata>, CacheError> {
         let Some(response) = self
             .client
@@ -199,28 +228,30 @@ impl HTTPCache {
         }
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub async fn fetch(
         &self,
         hash: &str,
     ) -> Result<Option<(CacheHitMetadata, Vec<AnchoredSystemPathBuf>)>, CacheError> {
-        let Some(response) = self
-            .client
-            .fetch_artifact(
-                hash,
-                &self.api_auth.token,
-                self.api_auth.team_id.as_deref(),
-                self.api_auth.team_slug.as_deref(),
-            )
-            .await?
-        else {
+        let start = Instant::now();
+        let response = self.client.fetch_artifact(
+            hash,
+            &self.api_auth.token,
+            self.api_auth.team_id.as_deref(),
+            self.api_auth.team_slug.as_deref(),
+        );
+        // The size of the artifact isn't known until the server responds
+        let response =
+            within_download_timeout(&self.client.cache_timeouts(), start, None, hash, response)
+                .await?;
+        let Some(response) = response? else {
             self.log_fetch(analytics::CacheEvent::Miss, hash, 0);
             return Ok(None);
         };
 
         let duration = Self::get_duration_from_response(&response)?;
 
-        let body = if let Some(signer_verifier) = &self.signer_verifier {
+        let restored = if let Some(signer_verifier) = &self.signer_verifier {
             let expected_tag = response
                 .headers()
                 .get("x-artifact-tag")
@@ -231,29 +262,30 @@ impl HTTPCache {
                 .map_err(|_| CacheError::InvalidTag(Backtrace::capture()))?
                 .to_string();
 
-            let body = response.bytes().await.map_err(|e| {
-                CacheError::ApiClientError(
-                    Box::new(turborepo_api_client::Error::ReqwestError(e)),
-                    Backtrace::capture(),
-                )
-            })?;
+            let body = self.read_body(hash, response, start).await?;
             let is_valid = signer_verifier.validate(hash.as_bytes(), &body, &expected_tag)?;
 
             if !is_valid {
                 return Err(CacheError::InvalidTag(Backtrace::capture()));
             }
+            self.transfers.record_download(body.len(), start.elapsed());
 
-            body
+            Self::restore_tar(&self.repo_root, &body, self.skip_unchanged)
         } else {
-            response.bytes().await.map_err(|e| {
-                CacheError::ApiClientError(
-                    Box::new(turborepo_api_client::Error::ReqwestError(e)),
-                    Backtrace::capture(),
-                )
-            })?
+            // Without a signature to check first, the artifact can be restored
+            // as it downloads
+            self.restore_body(hash, response, start).await
         };
 
-        let files = Self::restore_tar(&self.repo_root, &body)?;
+        let files = match restored {
+            Ok(files) => files,
+            Err(e @ CacheError::IntegrityMismatch(..)) if !self.strict => {
+                warn!("{e}, treating {hash} as a cache miss");
+                self.log_fetch(analytics::CacheEvent::Miss, hash, 0);
+                return Ok(None);
+            }
+            Err(e) => return Err(e),
+        };
 
         self.log_fetch(analytics::CacheEvent::Hit, hash, duration);
         Ok(Some((
@@ -265,16 +297,73 @@ impl HTTPCache {
         )))
     }
 
+    // Streams the artifact so that its progress can be reported while it
+    // downloads
+    async fn read_body(
+        &self,
+        hash: &str,
+        response: Response,
+        start: Instant,
+    ) -> Result<Vec<u8>, CacheError> {
+        let size = response.content_length();
+        let download = self.transfers.download(
+            hash,
+            response.bytes_stream(),
+            size.and_then(|size| usize::try_from(size).ok()),
+        );
+        let body =
+            within_download_timeout(&self.client.cache_timeouts(), start, size, hash, download)
+                .await?;
+        body.map_err(|e| {
+            CacheError::ApiClientError(
+                Box::new(turborepo_api_client::Error::ReqwestError(e)),
+                Backtrace::capture(),
+            )
+        })
+    }
+
+    async fn restore_body(
+        &self,
+        hash: &str,
+        response: Response,
+        start: Instant,
+    ) -> Result<Vec<AnchoredSystemPathBuf>, CacheError> {
+        let size = response.content_length();
+        let repo_root = self.repo_root.clone();
+        let skip_unchanged = self.skip_unchanged;
+        let restore = self.transfers.restore_download(
+            hash,
+            response.bytes_stream(),
+            size.and_then(|size| usize::try_from(size).ok()),
+            move |body| {
+                CacheReader::from_reader(body, true)?
+                    .skip_unchanged(skip_unchanged)
+                    .restore(&repo_root)
+            },
+        );
+        let (files, downloaded) =
+            within_download_timeout(&self.client.cache_timeouts(), start, size, hash, restore)
+                .await??;
+        self.transfers.record_download(downloaded, start.elapsed());
+
+        Ok(files)
+    }
+
     pub fn requests(&self) -> Arc<Mutex<UploadMap>> {
         self.uploads.clone()
     }
 
+    pub fn transfers(&self) -> TransferTracker {
+        self.transfers.clone()
+    }
+
     #[tracing::instrument(skip_all)]
     pub(crate) fn restore_tar(
         root: &AbsoluteSystemPath,
         body: &[u8],
+        skip_unchanged: bool,
     ) -> Result<Vec<AnchoredSystemPathBuf>, CacheError> {
-        let mut cache_reader = CacheReader::from_reader(body, true)?;
+        let mut cache_reader = CacheReader::from_reader(body, true)?.skip_unchanged(skip_unchanged);
         cache_reader.restore(root)
     }
 }
@@ -288,7 +377,7 @@ mod test {
     use tempfile::tempdir;
     use turbopath::AbsoluteSystemPathBuf;
     use turborepo_analytics::start_analytics;
-    use turborepo_api_client::{analytics, APIClient};
+    use turborepo_api_client::{analytics, APIClient, CacheTimeouts, TlsOptions};
     use turborepo_vercel_api_mock::start_test_server;
 
     use crate::{
@@ -327,9 +416,10 @@ mod test {
         let api_client = APIClient::new(
             format!("http://localhost:{}", port),
             Some(Duration::from_secs(200)),
-            None,
+            CacheTimeouts::default(),
             "2.0.0",
             true,
+            &TlsOptions::default(),
         )?;
         let opts = CacheOpts::default();
         let api_auth = APIAuth {
@@ -354,7 +444,7 @@ mod test {
 
         let anchored_files: Vec<_> = files.iter().map(|f| f.path().to_owned()).collect();
         cache
-            .put(&repo_root_path, hash, &anchored_files, duration)
+            .put(&repo_root_path, hash, &anchored_files, duration, None)
             .await?;
 
         let cache_response = cache.exists(hash).await?.unwrap();
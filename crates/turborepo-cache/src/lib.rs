@@ -14,11 +14,17 @@ pub mod http;
 /// A wrapper that allows reads and writes from the file system and remote
 /// cache.
 mod multiplexer;
+/// The remote cache providers turbo can use
+pub mod remote;
+/// Remote cache backed by an S3 compatible object store
+pub mod s3;
 /// Cache signature authentication lets users provide a private key to sign
 /// their cache payloads.
 pub mod signature_authentication;
 #[cfg(test)]
 mod test_cases;
+/// Progress and totals of remote cache transfers
+pub mod transfer;
 mod upload_progress;
 
 use std::{backtrace, backtrace::Backtrace};
@@ -28,7 +34,7 @@ use camino::Utf8PathBuf;
 use serde::{Deserialize, Serialize};
 use thiserror::Error;
 
-use crate::signature_authentication::SignatureError;
+use crate::{s3::S3CacheOpts, signature_authentication::SignatureError};
 
 #[derive(Debug, Error)]
 pub enum CacheError {
@@ -47,6 +53,8 @@ pub enum CacheError {
     ApiClientError(Box<turborepo_api_client::Error>, #[backtrace] Backtrace),
     #[error("the cache artifact for {0} was too large to upload within the timeout")]
     TimeoutError(String),
+    #[error("the cache artifact for {0} could not be downloaded within the timeout")]
+    DownloadTimeout(String),
     #[error("could not connect to the cache")]
     ConnectError,
     #[error("signing artifact failed: {0}")]
@@ -73,24 +81,79 @@ pub enum CacheError {
     WindowsUnsafeName(String, #[backtrace] Backtrace),
     #[error("tar attempts to write outside of directory: {0}")]
     LinkOutsideOfDirectory(String, #[backtrace] Backtrace),
+    #[error("Invalid cache artifact manifest")]
+    InvalidManifest(serde_json::Error, #[backtrace] Backtrace),
+    #[error("cache artifact failed integrity check: {0} does not match the artifact manifest")]
+    IntegrityMismatch(String, #[backtrace] Backtrace),
+    #[error("{0} changed while it was being written to the cache")]
+    OutputChanged(String, #[backtrace] Backtrace),
+    #[error("cache artifact {0} doesn't match the size and checksum it was written with")]
+    ArchiveMismatch(String, #[backtrace] Backtrace),
     #[error("Invalid cache metadata file")]
     InvalidMetadata(serde_json::Error, #[backtrace] Backtrace),
     #[error("Failed to write cache metadata file")]
     MetadataWriteFailure(serde_json::Error, #[backtrace] Backtrace),
     #[error("Unable to perform write as cache is shutting down")]
     CacheShuttingDown,
+    #[error(
+        "could not find AWS credentials for the S3 remote cache. Set AWS_ACCESS_KEY_ID and \
+         AWS_SECRET_ACCESS_KEY or add the `{0}` profile to your AWS credentials file"
+    )]
+    S3CredentialsNotFound(String),
+    #[error("uploaded artifact {0} could not be downloaded from the remote cache")]
+    ArtifactNotFoundAfterUpload(String),
+    #[error("S3 remote cache request failed with status code {0}")]
+    S3RequestFailed(u16, #[backtrace] Backtrace),
     #[error("Unable to determine config cache base")]
     ConfigCacheInvalidBase,
     #[error("Unable to hash config cache inputs")]
     ConfigCacheError,
 }
 
+impl CacheError {
+    /// Whether the artifact being fetched can't be trusted, as opposed to the
+    /// cache being unavailable
+    pub fn is_untrusted_artifact(&self) -> bool {
+        matches!(
+            self,
+            CacheError::IntegrityMismatch(..)
+                | CacheError::ArchiveMismatch(..)
+                | CacheError::InvalidTag(..)
+                | CacheError::LinkOutsideOfDirectory(..)
+        )
+    }
+
+    /// Whether the cache couldn't be reached or didn't respond in time, as
+    /// opposed to rejecting the request
+    pub fn is_unreachable(&self) -> bool {
+        match self {
+            CacheError::ConnectError
+            | CacheError::TimeoutError(..)
+            | CacheError::DownloadTimeout(..) => true,
+            CacheError::ApiClientError(err, ..) => match err.as_ref() {
+                turborepo_api_client::Error::ReqwestError(err) => {
+                    err.is_connect() || err.is_timeout()
+                }
+                turborepo_api_client::Error::TooManyFailures(_) => true,
+                _ => false,
+            },
+            _ => false,
+        }
+    }
+}
+
 impl From<turborepo_api_client::Error> for CacheError {
     fn from(value: turborepo_api_client::Error) -> Self {
         CacheError::ApiClientError(Box::new(value), Backtrace::capture())
     }
 }
 
+impl From<reqwest::Error> for CacheError {
+    fn from(value: reqwest::Error) -> Self {
+        turborepo_api_client::Error::ReqwestError(value).into()
+    }
+}
+
 #[derive(Debug, Clone, PartialEq, Copy)]
 pub enum CacheSource {
     Local,
@@ -103,14 +166,55 @@ pub struct CacheHitMetadata {
     pub time_saved: u64,
 }
 
-#[derive(Debug, Default)]
+#[derive(Debug, Default, Clone)]
 pub struct CacheOpts {
     pub override_dir: Option<Utf8PathBuf>,
     pub remote_cache_read_only: bool,
+    /// Only upload artifacts to the remote cache, never read from it.
+    pub remote_cache_write_only: bool,
     pub skip_remote: bool,
     pub skip_filesystem: bool,
     pub workers: u32,
+    /// Maximum number of artifacts uploaded to the remote cache at the same
+    /// time. Defaults to `workers`.
+    pub upload_concurrency: Option<u32>,
     pub remote_cache_opts: Option<RemoteCacheOpts>,
+    /// Maximum size in bytes of the local filesystem cache. Least recently
+    /// used artifacts are evicted at the end of a run once this is exceeded.
+    pub max_size: Option<u64>,
+    /// If set, the remote cache is an S3 compatible bucket rather than
+    /// the Vercel Remote Cache.
+    pub s3_opts: Option<S3CacheOpts>,
+    /// zstd compression level used when writing artifacts. 0 uses zstd's
+    /// default level.
+    pub compression_level: i32,
+    /// Only write the restored files that differ from the ones already on
+    /// disk.
+    pub skip_unchanged: bool,
+    /// Local cache directories that are searched after the repository's own,
+    /// in order.
+    pub tiers: Vec<CacheTier>,
+    /// Fail fetches of artifacts that can't be trusted, instead of treating
+    /// them as cache misses. This covers artifacts that fail their integrity
+    /// checks, have an invalid signature or restore files outside of the
+    /// package directory. Corrupted local artifacts are kept for inspection.
+    pub strict: bool,
+    /// Number of remote cache requests in a row that have to fail to connect
+    /// or time out before the remote cache is skipped for the rest of the run.
+    /// Defaults to 3, and 0 never skips it.
+    pub remote_failure_limit: Option<u32>,
+}
+
+/// A local cache directory besides the repository's own, e.g. one shared by
+/// the machines of a build farm over a network mount
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+#[serde(rename_all = "camelCase")]
+pub struct CacheTier {
+    /// Relative paths are resolved from the repository root
+    pub dir: Utf8PathBuf,
+    /// Only read artifacts from the tier, never write them to it
+    #[serde(default)]
+    pub read_only: bool,
 }
 
 #[derive(Debug, Default, Clone, Serialize, Deserialize, PartialEq, Eq)]
@@ -1,31 +1,50 @@
-use std::sync::{
-    atomic::{AtomicBool, Ordering},
-    Arc, Mutex,
+use std::{
+    collections::HashSet,
+    sync::{
+        atomic::{AtomicBool, AtomicUsize, Ordering},
+        Arc, Mutex,
+    },
 };
 
+use tokio::sync::Semaphore;
 use tracing::{debug, warn};
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPathBuf};
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
 use turborepo_analytics::AnalyticsSender;
 use turborepo_api_client::{APIAuth, APIClient};
 
 use crate::{
-    fs::FSCache,
-    http::{HTTPCache, UploadMap},
-    CacheError, CacheHitMetadata, CacheOpts,
+    fs::FSCache, http::UploadMap, remote::RemoteCache, transfer::TransferTracker, CacheError,
+    CacheHitMetadata, CacheOpts,
 };
 
+// Number of remote cache requests in a row that have to fail before the remote
+// cache is skipped for the rest of the run, unless it's configured
+const DEFAULT_REMOTE_FAILURE_LIMIT: u32 = 3;
+
 pub struct CacheMultiplexer {
     // We use an `AtomicBool` instead of removing the cache because that would require
     // wrapping the cache in a `Mutex` which would cause a lot of contention.
     // This does create a mild race condition where we might use the cache
     // even though another thread might be removing it, but that's fine.
-    should_use_http_cache: AtomicBool,
+    should_use_remote_cache: AtomicBool,
     // Just for keeping track of whether we've already printed a warning about the remote cache
     // being read-only
     should_print_skipping_remote_put: AtomicBool,
+    // Consecutive remote cache requests that couldn't reach the cache, reset by
+    // any request that gets a response
+    remote_failures: AtomicUsize,
+    // See `CacheOpts::remote_failure_limit`
+    remote_failure_limit: usize,
     remote_cache_read_only: bool,
-    fs: Option<FSCache>,
-    http: Option<HTTPCache>,
+    remote_cache_write_only: bool,
+    // The local caches in the order they're searched, starting with the
+    // repository's cache directory
+    fs: Vec<FSCache>,
+    remote: Option<RemoteCache>,
+    // Limits how many artifacts are uploaded at once, if set
+    upload_permits: Option<Semaphore>,
+    // See `CacheOpts::strict`
+    strict: bool,
 }
 
 impl CacheMultiplexer {
@@ -38,76 +57,206 @@ impl CacheMultiplexer {
         analytics_recorder: Option<AnalyticsSender>,
     ) -> Result<Self, CacheError> {
         let use_fs_cache = !opts.skip_filesystem;
-        let use_http_cache = !opts.skip_remote;
+        let use_remote_cache = !opts.skip_remote;
 
         // Since the above two flags are not mutually exclusive it is possible to
         // configure yourself out of having a cache. We should tell you about it
         // but we shouldn't fail your build for that reason.
-        if !use_fs_cache && !use_http_cache {
+        if !use_fs_cache && !use_remote_cache {
             warn!("no caches are enabled");
         }
 
-        let fs_cache = use_fs_cache
-            .then(|| {
+        let mut fs_caches = Vec::new();
+        if use_fs_cache {
+            fs_caches.push(
                 FSCache::new(
                     opts.override_dir.as_deref(),
                     repo_root,
                     analytics_recorder.clone(),
-                )
-            })
-            .transpose()?;
-
-        let http_cache = use_http_cache
-            .then_some(api_auth)
-            .flatten()
-            .map(|api_auth| {
-                HTTPCache::new(
-                    api_client,
-                    opts,
-                    repo_root.to_owned(),
-                    api_auth,
-                    analytics_recorder.clone(),
-                )
-            });
+                    opts.max_size,
+                    opts.compression_level,
+                    opts.skip_unchanged,
+                )?
+                .strict(opts.strict),
+            );
+            for tier in &opts.tiers {
+                // Tiers are usually network mounts, creating the directory when the mount
+                // isn't there would write to the local disk instead.
+                if !AbsoluteSystemPathBuf::from_unknown(repo_root, &tier.dir).exists() {
+                    warn!("skipping cache tier {}: directory does not exist", tier.dir);
+                    continue;
+                }
+                // The max size only applies to the repository's cache, shared tiers are
+                // managed by whoever set them up. Only the repository's cache records
+                // analytics, so that a miss isn't counted once for every tier.
+                match FSCache::new(
+                    Some(tier.dir.as_path()),
+                    repo_root,
+                    None,
+                    None,
+                    opts.compression_level,
+                    opts.skip_unchanged,
+                ) {
+                    Ok(cache) => {
+                        fs_caches.push(cache.strict(opts.strict).read_only(tier.read_only))
+                    }
+                    // A network mount that isn't available shouldn't fail the build
+                    Err(err) => warn!("skipping cache tier {}: {err}", tier.dir),
+                }
+            }
+        }
+
+        let remote_cache =
+            RemoteCache::new(opts, repo_root, api_client, api_auth, analytics_recorder)?;
 
         Ok(CacheMultiplexer {
             should_print_skipping_remote_put: AtomicBool::new(true),
-            should_use_http_cache: AtomicBool::new(http_cache.is_some()),
+            should_use_remote_cache: AtomicBool::new(remote_cache.is_some()),
+            remote_failures: AtomicUsize::new(0),
+            remote_failure_limit: opts
+                .remote_failure_limit
+                .unwrap_or(DEFAULT_REMOTE_FAILURE_LIMIT) as usize,
             remote_cache_read_only: opts.remote_cache_read_only,
-            fs: fs_cache,
-            http: http_cache,
+            remote_cache_write_only: opts.remote_cache_write_only,
+            fs: fs_caches,
+            remote: remote_cache,
+            upload_permits: opts
+                .upload_concurrency
+                .map(|concurrency| Semaphore::new(concurrency.max(1) as usize)),
+            strict: opts.strict,
         })
     }
 
     // This is technically a TOCTOU bug, but at worst it'll cause
     // a few extra cache requests.
-    fn get_http_cache(&self) -> Option<&HTTPCache> {
-        if self.should_use_http_cache.load(Ordering::Relaxed) {
-            self.http.as_ref()
+    fn get_remote_cache(&self) -> Option<&RemoteCache> {
+        if self.should_use_remote_cache.load(Ordering::Relaxed) {
+            self.remote.as_ref()
         } else {
             None
         }
     }
 
+    // The remote cache to read artifacts from, if reads are allowed
+    fn get_readable_remote_cache(&self) -> Option<&RemoteCache> {
+        if self.remote_cache_write_only {
+            None
+        } else {
+            self.get_remote_cache()
+        }
+    }
+
+    // Stops using the remote cache once too many requests in a row can't reach
+    // it, so an unreachable cache doesn't make every task wait for a timeout.
+    // Errors the cache responded with, like a rejected artifact, show that it
+    // can be reached.
+    fn record_remote_result<T>(&self, result: &Result<T, CacheError>) {
+        if !result.as_ref().is_err_and(CacheError::is_unreachable) {
+            self.remote_failures.store(0, Ordering::Relaxed);
+            return;
+        }
+        let failures = self.remote_failures.fetch_add(1, Ordering::Relaxed) + 1;
+        // Requests that are already in flight can push this past the limit, only
+        // the one that reaches it warns
+        if failures == self.remote_failure_limit {
+            warn!(
+                "remote cache failed {failures} requests in a row, skipping it for the rest of \
+                 the run"
+            );
+            self.should_use_remote_cache.store(false, Ordering::Relaxed);
+            if let Some(remote) = &self.remote {
+                remote.transfers().record_disabled();
+            }
+        }
+    }
+
     pub fn requests(&self) -> Option<Arc<Mutex<UploadMap>>> {
-        self.http.as_ref().map(|http| http.requests())
+        self.remote.as_ref().and_then(|remote| remote.requests())
     }
 
-    #[tracing::instrument(skip_all)]
+    pub fn transfers(&self) -> Option<TransferTracker> {
+        self.remote.as_ref().map(|remote| remote.transfers())
+    }
+
+    /// Trims the filesystem cache down to its configured max size.
+    pub fn evict(&self) {
+        for fs in self.writable_fs_caches() {
+            if let Err(err) = fs.evict() {
+                warn!("failed to evict local cache artifacts: {err}");
+            }
+        }
+    }
+
+    fn writable_fs_caches(&self) -> impl Iterator<Item = &FSCache> {
+        self.fs.iter().filter(|fs| !fs.is_read_only())
+    }
+
+    #[tracing::instrument(skip_all, fields(hash = %key))]
     pub async fn put(
         &self,
         anchor: &AbsoluteSystemPath,
         key: &str,
         files: &[AnchoredSystemPathBuf],
+        local_only_files: &[AnchoredSystemPathBuf],
         duration: u64,
+        compression_level: Option<i32>,
     ) -> Result<(), CacheError> {
-        self.fs
-            .as_ref()
-            .map(|fs| fs.put(anchor, key, files, duration))
-            .transpose()?;
+        self.put_local(anchor, key, files, duration, compression_level)?;
+        self.put_remote(
+            anchor,
+            key,
+            files,
+            local_only_files,
+            duration,
+            compression_level,
+        )
+        .await
+    }
 
-        let http_result = match self.get_http_cache() {
-            Some(http) => {
+    /// Writes the artifact to the local caches. `compression_level` overrides
+    /// the level the caches were created with, e.g. for a task that sets its
+    /// own.
+    pub fn put_local(
+        &self,
+        anchor: &AbsoluteSystemPath,
+        key: &str,
+        files: &[AnchoredSystemPathBuf],
+        duration: u64,
+        compression_level: Option<i32>,
+    ) -> Result<(), CacheError> {
+        let mut fs_caches = self.writable_fs_caches();
+        let Some(first) = fs_caches.next() else {
+            return Ok(());
+        };
+        // Tiers are often network mounts, so they're written at the same time
+        // rather than one after another
+        std::thread::scope(|scope| {
+            for fs in fs_caches {
+                scope.spawn(move || {
+                    // Failing to write to a tier only makes the artifact unavailable
+                    // from it, the repository's cache is the one that has to succeed
+                    if let Err(err) = fs.put(anchor, key, files, duration, compression_level) {
+                        warn!("failed to write {key} to cache tier: {err}");
+                    }
+                });
+            }
+            first.put(anchor, key, files, duration, compression_level)
+        })
+    }
+
+    /// Uploads the artifact to the remote cache, without its
+    /// `local_only_files`
+    pub async fn put_remote(
+        &self,
+        anchor: &AbsoluteSystemPath,
+        key: &str,
+        files: &[AnchoredSystemPathBuf],
+        local_only_files: &[AnchoredSystemPathBuf],
+        duration: u64,
+        compression_level: Option<i32>,
+    ) -> Result<(), CacheError> {
+        let remote_result = match self.get_remote_cache() {
+            Some(remote) => {
                 if self.remote_cache_read_only {
                     if self
                         .should_print_skipping_remote_put
@@ -122,62 +271,96 @@ impl CacheMultiplexer {
                     // write to it
                     None
                 } else {
-                    let http_result = http.put(anchor, key, files, duration).await;
+                    // Local only files are written to the filesystem cache, but never uploaded
+                    let local_only_files = local_only_files.iter().collect::<HashSet<_>>();
+                    let remote_files = files
+                        .iter()
+                        .filter(|file| !local_only_files.contains(file))
+                        .cloned()
+                        .collect::<Vec<_>>();
+                    let _permit = match &self.upload_permits {
+                        Some(permits) => Some(
+                            permits
+                                .acquire()
+                                .await
+                                .expect("upload semaphore is never closed"),
+                        ),
+                        None => None,
+                    };
+                    let remote_result = remote
+                        .put(anchor, key, &remote_files, duration, compression_level)
+                        .await;
 
-                    Some(http_result)
+                    Some(remote_result)
                 }
             }
             _ => None,
         };
 
-        match http_result {
+        match remote_result {
             Some(Err(CacheError::ApiClientError(
                 box turborepo_api_client::Error::CacheDisabled { .. },
                 ..,
             ))) => {
                 warn!("failed to put to http cache: cache disabled");
-                self.should_use_http_cache.store(false, Ordering::Relaxed);
+                self.should_use_remote_cache.store(false, Ordering::Relaxed);
                 Ok(())
             }
-            Some(Err(e)) => Err(e),
-            None | Some(Ok(())) => Ok(()),
+            Some(result) => {
+                self.record_remote_result(&result);
+                result
+            }
+            None => Ok(()),
         }
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %key))]
     pub async fn fetch(
         &self,
         anchor: &AbsoluteSystemPath,
         key: &str,
     ) -> Result<Option<(CacheHitMetadata, Vec<AnchoredSystemPathBuf>)>, CacheError> {
-        if let Some(fs) = &self.fs {
-            if let response @ Ok(Some(_)) = fs.fetch(anchor, key) {
-                return response;
+        for (index, fs) in self.fs.iter().enumerate() {
+            match fs.fetch(anchor, key) {
+                Ok(Some((metadata, files))) => {
+                    // Copy the artifact into the tiers that are searched first, so it's
+                    // found sooner next time
+                    for earlier in self.fs[..index].iter().filter(|fs| !fs.is_read_only()) {
+                        let _ = earlier.put(anchor, key, &files, metadata.time_saved, None);
+                    }
+                    return Ok(Some((metadata, files)));
+                }
+                Err(err) if self.strict && err.is_untrusted_artifact() => return Err(err),
+                _ => {}
             }
         }
 
-        if let Some(http) = self.get_http_cache() {
-            if let Ok(Some((CacheHitMetadata { source, time_saved }, files))) =
-                http.fetch(key).await
-            {
-                // Store this into fs cache. We can ignore errors here because we know
-                // we have previously successfully stored in HTTP cache, and so the overall
-                // result is a success at fetching. Storing in lower-priority caches is an
-                // optimization.
-                if let Some(fs) = &self.fs {
-                    let _ = fs.put(anchor, key, &files, time_saved);
-                }
+        if let Some(remote) = self.get_readable_remote_cache() {
+            let response = remote.fetch(key).await;
+            self.record_remote_result(&response);
+            match response {
+                Ok(Some((CacheHitMetadata { source, time_saved }, files))) => {
+                    // Store this into fs cache. We can ignore errors here because we know
+                    // we have previously successfully stored in the remote cache, and so the
+                    // overall result is a success at fetching. Storing in
+                    // lower-priority caches is an optimization.
+                    for fs in self.writable_fs_caches() {
+                        let _ = fs.put(anchor, key, &files, time_saved, None);
+                    }
 
-                return Ok(Some((CacheHitMetadata { source, time_saved }, files)));
+                    return Ok(Some((CacheHitMetadata { source, time_saved }, files)));
+                }
+                Err(err) if self.strict && err.is_untrusted_artifact() => return Err(err),
+                _ => {}
             }
         }
 
         Ok(None)
     }
 
-    #[tracing::instrument(skip_all)]
+    #[tracing::instrument(skip_all, fields(hash = %key))]
     pub async fn exists(&self, key: &str) -> Result<Option<CacheHitMetadata>, CacheError> {
-        if let Some(fs) = &self.fs {
+        for fs in &self.fs {
             match fs.exists(key) {
                 cache_hit @ Ok(Some(_)) => {
                     return cache_hit;
@@ -187,16 +370,225 @@ impl CacheMultiplexer {
             }
         }
 
-        if let Some(http) = self.get_http_cache() {
-            match http.exists(key).await {
+        if let Some(remote) = self.get_readable_remote_cache() {
+            let response = remote.exists(key).await;
+            self.record_remote_result(&response);
+            match response {
                 cache_hit @ Ok(Some(_)) => {
                     return cache_hit;
                 }
                 Ok(None) => {}
-                Err(err) => debug!("failed to check http cache: {:?}", err),
+                Err(err) => debug!("failed to check remote cache: {:?}", err),
             }
         }
 
         Ok(None)
     }
 }
+
+#[cfg(test)]
+mod test {
+    use std::backtrace::Backtrace;
+
+    use anyhow::Result;
+    use camino::Utf8PathBuf;
+    use tempfile::tempdir;
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+    use turborepo_api_client::{APIAuth, APIClient, CacheTimeouts, TlsOptions};
+
+    use super::CacheMultiplexer;
+    use crate::{fs::FSCache, CacheError, CacheOpts, CacheTier};
+
+    #[tokio::test]
+    async fn test_cache_tiers() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let shared = tempdir()?;
+        let shared_dir = Utf8PathBuf::try_from(shared.path().to_path_buf())?;
+        let file = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root.resolve(&file).create_with_contents("shared")?;
+
+        let shared_cache =
+            FSCache::new(Some(shared_dir.as_path()), &repo_root, None, None, 0, false)?;
+        shared_cache.put(&repo_root, "abc", &[file.clone()], 100, None)?;
+
+        let opts = CacheOpts {
+            skip_remote: true,
+            tiers: vec![CacheTier {
+                dir: shared_dir.clone(),
+                read_only: true,
+            }],
+            ..CacheOpts::default()
+        };
+        let api_client = APIClient::new(
+            "http://localhost:0",
+            None,
+            CacheTimeouts::default(),
+            "2.0.0",
+            true,
+            &TlsOptions::default(),
+        )?;
+        let cache = CacheMultiplexer::new(&opts, &repo_root, api_client, None, None)?;
+
+        let (metadata, files) = cache
+            .fetch(&repo_root, "abc")
+            .await?
+            .expect("artifact is in the shared tier");
+        assert_eq!(metadata.time_saved, 100);
+        assert_eq!(files, vec![file.clone()]);
+        // Hits are copied into the repository's cache
+        let repo_cache = FSCache::new(None, &repo_root, None, None, 0, false)?;
+        assert!(repo_cache.exists("abc")?.is_some());
+
+        // Read-only tiers aren't written to
+        cache.put(&repo_root, "def", &[file], &[], 50, None).await?;
+        assert!(repo_cache.exists("def")?.is_some());
+        assert!(shared_cache.exists("def")?.is_none());
+
+        Ok(())
+    }
+
+    #[tokio::test]
+    async fn test_read_only_tier_keeps_corrupted_artifact() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let shared = tempdir()?;
+        let shared_dir = Utf8PathBuf::try_from(shared.path().to_path_buf())?;
+        let file = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root.resolve(&file).create_with_contents("shared")?;
+
+        let shared_cache =
+            FSCache::new(Some(shared_dir.as_path()), &repo_root, None, None, 0, false)?;
+        shared_cache.put(&repo_root, "abc", &[file.clone()], 100, None)?;
+        let archive_path = shared_cache.artifact("abc")?.unwrap().path;
+        let size = archive_path.symlink_metadata()?.len();
+        let mut options = std::fs::OpenOptions::new();
+        options.write(true);
+        archive_path.open_with_options(options)?.set_len(size / 2)?;
+
+        let opts = CacheOpts {
+            skip_remote: true,
+            tiers: vec![CacheTier {
+                dir: shared_dir.clone(),
+                read_only: true,
+            }],
+            ..CacheOpts::default()
+        };
+        let api_client = APIClient::new(
+            "http://localhost:0",
+            None,
+            CacheTimeouts::default(),
+            "2.0.0",
+            true,
+            &TlsOptions::default(),
+        )?;
+        let cache = CacheMultiplexer::new(&opts, &repo_root, api_client, None, None)?;
+
+        assert!(cache.fetch(&repo_root, "abc").await?.is_none());
+        // Whoever manages the shared tier has to remove it
+        assert!(shared_cache.artifact("abc")?.is_some());
+
+        Ok(())
+    }
+
+    #[tokio::test]
+    async fn test_missing_cache_tier_is_not_created() -> Result<()> {
+        let repo_root = tempdir()?;
+        let repo_root = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let shared = tempdir()?;
+        let missing = Utf8PathBuf::try_from(shared.path().join("unmounted"))?;
+
+        let opts = CacheOpts {
+            skip_remote: true,
+            tiers: vec![CacheTier {
+                dir: missing.clone(),
+                read_only: false,
+            }],
+            ..CacheOpts::default()
+        };
+        let api_client = APIClient::new(
+            "http://localhost:0",
+            None,
+            CacheTimeouts::default(),
+            "2.0.0",
+            true,
+            &TlsOptions::default(),
+        )?;
+        let cache = CacheMultiplexer::new(&opts, &repo_root, api_client, None, None)?;
+
+        let file = AnchoredSystemPathBuf::from_raw("output.txt")?;
+        repo_root.resolve(&file).create_with_contents("local")?;
+        cache.put(&repo_root, "abc", &[file], &[], 50, None).await?;
+        assert!(!missing.exists());
+
+        Ok(())
+    }
+
+    fn remote_cache(remote_failure_limit: Option<u32>) -> Result<CacheMultiplexer> {
+        let repo_root = tempdir()?;
+        let repo_root = AbsoluteSystemPathBuf::try_from(repo_root.path())?;
+        let opts = CacheOpts {
+            skip_filesystem: true,
+            remote_failure_limit,
+            ..CacheOpts::default()
+        };
+        let api_client = APIClient::new(
+            "http://localhost:0",
+            None,
+            CacheTimeouts::default(),
+            "2.0.0",
+            true,
+            &TlsOptions::default(),
+        )?;
+        let api_auth = APIAuth {
+            team_id: Some("my-team".to_string()),
+            token: "my-token".to_string(),
+            team_slug: None,
+        };
+        Ok(CacheMultiplexer::new(
+            &opts,
+            &repo_root,
+            api_client,
+            Some(api_auth),
+            None,
+        )?)
+    }
+
+    #[test]
+    fn test_unreachable_remote_cache_is_skipped() -> Result<()> {
+        let cache = remote_cache(None)?;
+        let unreachable = Err::<(), _>(CacheError::ConnectError);
+
+        cache.record_remote_result(&unreachable);
+        cache.record_remote_result(&unreachable);
+        // A response resets the count, even if it's an error
+        cache.record_remote_result(&Err::<(), _>(CacheError::S3RequestFailed(
+            403,
+            Backtrace::capture(),
+        )));
+        cache.record_remote_result(&unreachable);
+        cache.record_remote_result(&unreachable);
+        assert!(cache.get_remote_cache().is_some());
+
+        cache.record_remote_result(&Err::<(), _>(CacheError::TimeoutError("abc".to_string())));
+        assert!(cache.get_remote_cache().is_none());
+
+        Ok(())
+    }
+
+    #[test]
+    fn test_remote_failure_limit() -> Result<()> {
+        let cache = remote_cache(Some(1))?;
+        cache.record_remote_result(&Err::<(), _>(CacheError::ConnectError));
+        assert!(cache.get_remote_cache().is_none());
+
+        // A limit of 0 never skips the remote cache
+        let cache = remote_cache(Some(0))?;
+        for _ in 0..10 {
+            cache.record_remote_result(&Err::<(), _>(CacheError::ConnectError));
+        }
+        assert!(cache.get_remote_cache().is_some());
+
+        Ok(())
+    }
+}
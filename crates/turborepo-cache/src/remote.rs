@@ -0,0 +1,150 @@
+use std::{
+    sync::{Arc, Mutex},
+    time::{Duration, Instant},
+};
+
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPathBuf};
+use turborepo_analytics::AnalyticsSender;
+use turborepo_api_client::{APIAuth, APIClient};
+
+use crate::{
+    http::{HTTPCache, UploadMap},
+    s3::S3Cache,
+    transfer::TransferTracker,
+    CacheError, CacheHitMetadata, CacheOpts,
+};
+
+/// A remote cache, either the Vercel Remote Cache accessed through the
+/// API client or an S3 compatible bucket.
+pub enum RemoteCache {
+    Vercel(HTTPCache),
+    S3(S3Cache),
+}
+
+/// How long each half of a round trip through the remote cache took
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct RoundTrip {
+    pub upload: Duration,
+    pub download: Duration,
+}
+
+impl RemoteCache {
+    /// Creates the remote cache configured by `opts`. Returns `None` if the
+    /// remote cache is disabled, or if it's the Vercel Remote Cache and there
+    /// are no credentials for it.
+    pub fn new(
+        opts: &CacheOpts,
+        repo_root: &AbsoluteSystemPath,
+        api_client: APIClient,
+        api_auth: Option<APIAuth>,
+        analytics_recorder: Option<AnalyticsSender>,
+    ) -> Result<Option<Self>, CacheError> {
+        Ok(match (opts.skip_remote, &opts.s3_opts) {
+            (true, _) => None,
+            // S3 buckets don't require linking to Vercel, so we ignore `api_auth`,
+            // but the client is shared so the TLS options and retries apply to
+            // them too
+            (false, Some(s3_opts)) => Some(RemoteCache::S3(S3Cache::new(
+                s3_opts,
+                opts,
+                repo_root.to_owned(),
+                api_client,
+                analytics_recorder,
+            )?)),
+            (false, None) => api_auth.map(|api_auth| {
+                RemoteCache::Vercel(HTTPCache::new(
+                    api_client,
+                    opts,
+                    repo_root.to_owned(),
+                    api_auth,
+                    analytics_recorder,
+                ))
+            }),
+        })
+    }
+
+    /// Uploads an empty artifact under `hash`, downloads it again and then
+    /// deletes it. This checks that the remote cache can be reached and that
+    /// the credentials are allowed to both write and read artifacts.
+    pub async fn round_trip(
+        &self,
+        anchor: &AbsoluteSystemPath,
+        hash: &str,
+    ) -> Result<RoundTrip, CacheError> {
+        let start = Instant::now();
+        self.put(anchor, hash, &[], 0, None).await?;
+        let upload = start.elapsed();
+
+        let start = Instant::now();
+        let fetched = self.fetch(hash).await;
+        let download = start.elapsed();
+        // The artifact is deleted even if it couldn't be downloaded
+        let deleted = self.delete(hash).await;
+        if fetched?.is_none() {
+            return Err(CacheError::ArtifactNotFoundAfterUpload(hash.to_string()));
+        }
+        deleted?;
+
+        Ok(RoundTrip { upload, download })
+    }
+
+    /// Deletes the artifact of `hash`. The Vercel Remote Cache can't delete
+    /// artifacts, so they're left there until they expire.
+    pub async fn delete(&self, hash: &str) -> Result<(), CacheError> {
+        match self {
+            RemoteCache::Vercel(_) => Ok(()),
+            RemoteCache::S3(s3) => s3.delete(hash).await,
+        }
+    }
+
+    pub fn requests(&self) -> Option<Arc<Mutex<UploadMap>>> {
+        match self {
+            RemoteCache::Vercel(http) => Some(http.requests()),
+            RemoteCache::S3(s3) => Some(s3.requests()),
+        }
+    }
+
+    pub fn transfers(&self) -> TransferTracker {
+        match self {
+            RemoteCache::Vercel(http) => http.transfers(),
+            RemoteCache::S3(s3) => s3.transfers(),
+        }
+    }
+
+    pub async fn put(
+        &self,
+        anchor: &AbsoluteSystemPath,
+        key: &str,
+        files: &[AnchoredSystemPathBuf],
+        duration: u64,
+        compression_level: Option<i32>,
+    ) -> Result<(), CacheError> {
+        match self {
+            RemoteCache::Vercel(http) => {
+                http.put(anchor, key, files, duration, compression_level)
+                    .await
+            }
+            RemoteCache::S3(s3) => {
+                s3.put(anchor, key, files, duration, compression_level)
+                    .await
+            }
+        }
+    }
+
+    pub async fn fetch(
+        &self,
+        key: &str,
+    ) -> Result<Option<(CacheHitMetadata, Vec<AnchoredSystemPathBuf>)>, CacheError> {
+        match self {
+            RemoteCache::Vercel(http) => http.fetch(key).await,
+            RemoteCache::S3(s3) => s3.fetch(key).await,
+        }
+    }
+
+    pub async fn exists(&self, key: &str) -> Result<Option<CacheHitMetadata>, CacheError> {
+        match self {
+            RemoteCache::Vercel(http) => http.exists(key).await,
+            RemoteCache::S3(s3) => s3.exists(key).await,
+        }
+    }
+}
@@ -0,0 +1,577 @@
+use std::{
+    future::Future,
+    io::{self, Read},
+    sync::{
+        atomic::{AtomicBool, AtomicU64, AtomicUsize, Ordering},
+        Arc, Mutex,
+    },
+    time::{Duration, Instant},
+};
+
+use bytes::{Buf, Bytes};
+use futures::{Stream, StreamExt};
+use serde::{Serialize, Serializer};
+use tokio::sync::mpsc;
+use turborepo_api_client::CacheTimeouts;
+
+use crate::{http::UploadMap, upload_progress::UploadProgress, CacheError};
+
+// How many chunks of a download can be waiting to be restored, which bounds
+// the memory used by a streamed restore
+const BUFFERED_CHUNKS: usize = 16;
+
+/// How much of an artifact has been downloaded so far
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct TransferProgress {
+    pub bytes: usize,
+    /// The size of the artifact, if the server reported it
+    pub size: Option<usize>,
+    pub bytes_per_second: f64,
+}
+
+/// Totals of the artifacts downloaded from and uploaded to the remote cache
+#[derive(Debug, Default, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct TransferTotals {
+    pub downloads: usize,
+    pub downloaded_bytes: usize,
+    #[serde(rename = "downloadTimeMs", serialize_with = "serialize_millis")]
+    pub download_time: Duration,
+    pub uploads: usize,
+    pub uploaded_bytes: usize,
+    #[serde(rename = "uploadTimeMs", serialize_with = "serialize_millis")]
+    pub upload_time: Duration,
+    /// Whether the remote cache was skipped for the rest of the run after
+    /// failing repeatedly
+    #[serde(skip)]
+    pub disabled: bool,
+}
+
+fn serialize_millis<S: Serializer>(duration: &Duration, serializer: S) -> Result<S::Ok, S::Error> {
+    serializer.serialize_u64(duration.as_millis() as u64)
+}
+
+impl TransferTotals {
+    pub fn is_empty(&self) -> bool {
+        self.downloads == 0 && self.uploads == 0
+    }
+}
+
+#[derive(Debug, Default)]
+struct Counter {
+    artifacts: AtomicUsize,
+    bytes: AtomicUsize,
+    millis: AtomicU64,
+}
+
+impl Counter {
+    fn record(&self, bytes: usize, time: Duration) {
+        self.artifacts.fetch_add(1, Ordering::Relaxed);
+        self.bytes.fetch_add(bytes, Ordering::Relaxed);
+        self.millis
+            .fetch_add(time.as_millis() as u64, Ordering::Relaxed);
+    }
+
+    fn load(&self) -> (usize, usize, Duration) {
+        (
+            self.artifacts.load(Ordering::Relaxed),
+            self.bytes.load(Ordering::Relaxed),
+            Duration::from_millis(self.millis.load(Ordering::Relaxed)),
+        )
+    }
+}
+
+struct InFlight<'a> {
+    downloads: &'a Mutex<UploadMap>,
+    hash: &'a str,
+}
+
+impl Drop for InFlight<'_> {
+    fn drop(&mut self) {
+        self.downloads.lock().unwrap().remove(self.hash);
+    }
+}
+
+/// Tracks the progress of in-flight transfers and the totals of finished
+/// ones. Clones share the same state.
+#[derive(Debug, Default, Clone)]
+pub struct TransferTracker {
+    downloads: Arc<Mutex<UploadMap>>,
+    // Uploads stay in the map once they're done, which `UploadProgressQuery::done`
+    // tells apart
+    uploads: Arc<Mutex<UploadMap>>,
+    downloaded: Arc<Counter>,
+    uploaded: Arc<Counter>,
+    disabled: Arc<AtomicBool>,
+}
+
+impl TransferTracker {
+    fn track<'a, S: Stream>(
+        &'a self,
+        hash: &'a str,
+        body: S,
+        size: Option<usize>,
+    ) -> (UploadProgress<10, 100, S>, InFlight<'a>) {
+        let (progress, query) = UploadProgress::<10, 100, _>::new(body, size);
+        self.downloads
+            .lock()
+            .unwrap()
+            .insert(hash.to_string(), query);
+        // Stop tracking the download even if the caller's future is dropped
+        // before it finishes, e.g. because it timed out
+        let in_flight = InFlight {
+            downloads: &self.downloads,
+            hash,
+        };
+        (progress, in_flight)
+    }
+
+    /// Reads the body of an artifact, tracking its progress under `hash`
+    /// until it has been read.
+    pub(crate) async fn download<S, E>(
+        &self,
+        hash: &str,
+        body: S,
+        size: Option<usize>,
+    ) -> Result<Vec<u8>, E>
+    where
+        S: Stream<Item = Result<Bytes, E>>,
+    {
+        let (progress, _in_flight) = self.track(hash, body, size);
+
+        let mut progress = std::pin::pin!(progress);
+        let mut artifact = Vec::with_capacity(size.unwrap_or_default());
+        let mut result = Ok(());
+        while let Some(chunk) = progress.next().await {
+            match chunk {
+                Ok(chunk) => artifact.extend_from_slice(&chunk),
+                Err(e) => {
+                    result = Err(e);
+                    break;
+                }
+            }
+        }
+
+        result.map(|()| artifact)
+    }
+
+    /// Restores an artifact while its body is still downloading, rather than
+    /// after reading all of it, tracking its progress like `download`.
+    /// `restore` runs on a blocking thread and reads the body as it arrives,
+    /// and it's returned along with the number of bytes downloaded.
+    ///
+    /// If this future is dropped, e.g. because it timed out, `restore` fails
+    /// on its next read. Files that it already restored are left in place.
+    pub(crate) async fn restore_download<S, E, T>(
+        &self,
+        hash: &str,
+        body: S,
+        size: Option<usize>,
+        restore: impl FnOnce(ChunkReader) -> Result<T, CacheError> + Send + 'static,
+    ) -> Result<(T, usize), CacheError>
+    where
+        S: Stream<Item = Result<Bytes, E>>,
+        E: Into<CacheError>,
+        T: Send + 'static,
+    {
+        let (progress, _in_flight) = self.track(hash, body, size);
+        let (sender, receiver) = mpsc::channel(BUFFERED_CHUNKS);
+        let restore = tokio::task::spawn_blocking(move || restore(ChunkReader::new(receiver)));
+
+        let mut progress = std::pin::pin!(progress);
+        let mut downloaded = 0;
+        let mut result = Ok(());
+        while let Some(chunk) = progress.next().await {
+            let chunk = match chunk {
+                Ok(chunk) => chunk,
+                Err(e) => {
+                    let _ = sender
+                        .send(Err(io::Error::other("artifact download failed")))
+                        .await;
+                    result = Err(e.into());
+                    break;
+                }
+            };
+            downloaded += chunk.len();
+            // An error means that the restore stopped reading because it
+            // failed, which is returned below
+            if sender.send(Ok(Some(chunk))).await.is_err() {
+                break;
+            }
+        }
+        if result.is_ok() {
+            let _ = sender.send(Ok(None)).await;
+        }
+        drop(sender);
+
+        let restored = restore.await.map_err(io::Error::other)?;
+        // A failed download also fails the restore, but the download's error
+        // says what went wrong
+        result?;
+        restored.map(|restored| (restored, downloaded))
+    }
+
+    /// The uploads that are tracked, which the remote cache adds to as it
+    /// starts them
+    pub(crate) fn uploads(&self) -> Arc<Mutex<UploadMap>> {
+        self.uploads.clone()
+    }
+
+    pub(crate) fn record_download(&self, bytes: usize, time: Duration) {
+        self.downloaded.record(bytes, time);
+    }
+
+    pub(crate) fn record_upload(&self, bytes: usize, time: Duration) {
+        self.uploaded.record(bytes, time);
+    }
+
+    pub(crate) fn record_disabled(&self) {
+        self.disabled.store(true, Ordering::Relaxed);
+    }
+
+    /// Returns the progress of the download of `hash`, if it is in flight
+    pub fn download_progress(&self, hash: &str) -> Option<TransferProgress> {
+        let downloads = self.downloads.lock().unwrap();
+        let query = downloads.get(hash)?;
+        Some(TransferProgress {
+            bytes: query.bytes()?,
+            size: query.size(),
+            bytes_per_second: query.average_bps()?,
+        })
+    }
+
+    /// Returns how many uploads are in flight and their combined progress, if
+    /// any are
+    pub fn upload_progress(&self) -> Option<(usize, TransferProgress)> {
+        let uploads = self.uploads.lock().unwrap();
+        let in_flight = uploads
+            .values()
+            .filter(|query| !query.done())
+            .collect::<Vec<_>>();
+        if in_flight.is_empty() {
+            return None;
+        }
+        let progress = TransferProgress {
+            bytes: in_flight.iter().filter_map(|query| query.bytes()).sum(),
+            size: in_flight.iter().map(|query| query.size()).sum(),
+            bytes_per_second: in_flight
+                .iter()
+                .filter_map(|query| query.average_bps())
+                .sum(),
+        };
+        Some((in_flight.len(), progress))
+    }
+
+    pub fn totals(&self) -> TransferTotals {
+        let (downloads, downloaded_bytes, download_time) = self.downloaded.load();
+        let (uploads, uploaded_bytes, upload_time) = self.uploaded.load();
+        TransferTotals {
+            downloads,
+            downloaded_bytes,
+            download_time,
+            uploads,
+            uploaded_bytes,
+            upload_time,
+            disabled: self.disabled.load(Ordering::Relaxed),
+        }
+    }
+}
+
+/// Waits for part of the download of `hash` that started at `start`. Every
+/// part shares one deadline, which is extended to fit the artifact once
+/// `size` is known.
+pub(crate) async fn within_download_timeout<T>(
+    timeouts: &CacheTimeouts,
+    start: Instant,
+    size: Option<u64>,
+    hash: &str,
+    download: impl Future<Output = T>,
+) -> Result<T, CacheError> {
+    match timeouts.download_timeout(size) {
+        Some(timeout) => {
+            tokio::time::timeout_at(tokio::time::Instant::from_std(start + timeout), download)
+                .await
+                .map_err(|_| CacheError::DownloadTimeout(hash.to_string()))
+        }
+        None => Ok(download.await),
+    }
+}
+
+// `None` marks the end of the body, so that a download that stops part way
+// through isn't mistaken for a complete one
+type Chunk = io::Result<Option<Bytes>>;
+
+/// Reads the body of an artifact that's being downloaded, blocking until each
+/// chunk arrives
+pub(crate) struct ChunkReader {
+    chunks: mpsc::Receiver<Chunk>,
+    chunk: Bytes,
+    finished: bool,
+}
+
+impl ChunkReader {
+    fn new(chunks: mpsc::Receiver<Chunk>) -> Self {
+        Self {
+            chunks,
+            chunk: Bytes::new(),
+            finished: false,
+        }
+    }
+}
+
+impl Read for ChunkReader {
+    fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+        while self.chunk.is_empty() {
+            if self.finished {
+                return Ok(0);
+            }
+            match self.chunks.blocking_recv() {
+                Some(Ok(Some(chunk))) => self.chunk = chunk,
+                Some(Ok(None)) => self.finished = true,
+                Some(Err(e)) => return Err(e),
+                None => {
+                    return Err(io::Error::new(
+                        io::ErrorKind::UnexpectedEof,
+                        "artifact download was canceled",
+                    ))
+                }
+            }
+        }
+
+        let len = buf.len().min(self.chunk.len());
+        buf[..len].copy_from_slice(&self.chunk[..len]);
+        self.chunk.advance(len);
+        Ok(len)
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use std::{
+        assert_matches::assert_matches,
+        io::{self, Read},
+        time::{Duration, Instant},
+    };
+
+    use bytes::Bytes;
+    use futures::{stream, StreamExt};
+    use turborepo_api_client::CacheTimeouts;
+
+    use super::{within_download_timeout, TransferTotals, TransferTracker};
+    use crate::{upload_progress::UploadProgress, CacheError};
+
+    #[tokio::test]
+    async fn test_download_timeout_is_shared() {
+        let timeouts = CacheTimeouts {
+            download: Some(Duration::from_millis(200)),
+            ..CacheTimeouts::default()
+        };
+        let start = Instant::now();
+
+        // Waiting for the response uses part of the time allowed for the body
+        within_download_timeout(
+            &timeouts,
+            start,
+            None,
+            "hash",
+            tokio::time::sleep(Duration::from_millis(120)),
+        )
+        .await
+        .unwrap();
+        let result = within_download_timeout(
+            &timeouts,
+            start,
+            Some(10),
+            "hash",
+            tokio::time::sleep(Duration::from_millis(120)),
+        )
+        .await;
+        assert_matches!(result, Err(CacheError::DownloadTimeout(hash)) if hash == "hash");
+    }
+
+    #[tokio::test]
+    async fn test_download() {
+        let tracker = TransferTracker::default();
+        let chunks = vec![
+            Ok::<_, ()>(bytes::Bytes::from_static(b"hello ")),
+            Ok(bytes::Bytes::from_static(b"world")),
+        ];
+
+        let body = tracker
+            .download("abc", stream::iter(chunks), Some(11))
+            .await
+            .unwrap();
+
+        assert_eq!(body, b"hello world");
+        assert_eq!(tracker.download_progress("abc"), None);
+        // Totals are recorded separately once a transfer is done
+        assert!(tracker.totals().is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_failed_download() {
+        let tracker = TransferTracker::default();
+        let chunks = vec![Ok(bytes::Bytes::from_static(b"hello ")), Err("reset")];
+
+        let result = tracker.download("abc", stream::iter(chunks), None).await;
+
+        assert_eq!(result, Err("reset"));
+        assert_eq!(tracker.download_progress("abc"), None);
+    }
+
+    #[tokio::test]
+    async fn test_cancelled_download() {
+        let tracker = TransferTracker::default();
+        let body = stream::pending::<Result<bytes::Bytes, ()>>();
+
+        let download = tracker.download("abc", body, None);
+        let result = tokio::time::timeout(Duration::from_millis(10), download).await;
+
+        assert!(result.is_err());
+        assert_eq!(tracker.download_progress("abc"), None);
+    }
+
+    #[tokio::test]
+    async fn test_restore_download() {
+        let tracker = TransferTracker::default();
+        let chunks = vec![
+            Ok::<_, CacheError>(Bytes::from_static(b"hello ")),
+            Ok(Bytes::from_static(b"world")),
+        ];
+
+        let (body, downloaded) = tracker
+            .restore_download("abc", stream::iter(chunks), Some(11), |mut reader| {
+                let mut body = String::new();
+                reader.read_to_string(&mut body)?;
+                Ok(body)
+            })
+            .await
+            .unwrap();
+
+        assert_eq!(body, "hello world");
+        assert_eq!(downloaded, 11);
+        assert_eq!(tracker.download_progress("abc"), None);
+    }
+
+    #[tokio::test]
+    async fn test_failed_restore_download() {
+        let tracker = TransferTracker::default();
+        let chunks = vec![
+            Ok(Bytes::from_static(b"hello ")),
+            Err(CacheError::ConnectError),
+        ];
+
+        let result = tracker
+            .restore_download("abc", stream::iter(chunks), None, |mut reader| {
+                let mut body = Vec::new();
+                reader.read_to_end(&mut body)?;
+                Ok(body)
+            })
+            .await;
+
+        assert!(matches!(result, Err(CacheError::ConnectError)));
+    }
+
+    #[tokio::test]
+    async fn test_cancelled_restore_download() {
+        let tracker = TransferTracker::default();
+        let body = stream::iter(vec![Ok::<_, CacheError>(Bytes::from_static(b"hello "))])
+            .chain(stream::pending());
+        let (sender, receiver) = std::sync::mpsc::channel();
+
+        let download = tracker.restore_download("abc", body, None, move |mut reader| {
+            let mut body = Vec::new();
+            let result = reader.read_to_end(&mut body);
+            sender.send(result.map_err(|e| e.kind())).unwrap();
+            Ok(())
+        });
+        let result = tokio::time::timeout(Duration::from_millis(10), download).await;
+
+        assert!(result.is_err());
+        assert_eq!(tracker.download_progress("abc"), None);
+        // The restore fails instead of seeing a truncated body
+        let read = tokio::task::spawn_blocking(move || receiver.recv().unwrap())
+            .await
+            .unwrap();
+        assert_eq!(read, Err(io::ErrorKind::UnexpectedEof));
+    }
+
+    #[test]
+    fn test_clones_share_totals() {
+        let tracker = TransferTracker::default();
+        tracker
+            .clone()
+            .record_upload(1024, Duration::from_millis(20));
+
+        assert_eq!(
+            tracker.totals(),
+            TransferTotals {
+                uploads: 1,
+                uploaded_bytes: 1024,
+                upload_time: Duration::from_millis(20),
+                ..TransferTotals::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_disabled() {
+        let tracker = TransferTracker::default();
+        assert!(!tracker.totals().disabled);
+
+        tracker.clone().record_disabled();
+
+        assert!(tracker.totals().disabled);
+        assert!(tracker.totals().is_empty());
+    }
+
+    #[test]
+    fn test_serialize_totals() {
+        let totals = TransferTotals {
+            downloads: 2,
+            downloaded_bytes: 2048,
+            download_time: Duration::from_millis(150),
+            uploads: 1,
+            uploaded_bytes: 1024,
+            upload_time: Duration::from_secs(1),
+            disabled: true,
+        };
+
+        assert_eq!(
+            serde_json::to_value(totals).unwrap(),
+            serde_json::json!({
+                "downloads": 2,
+                "downloadedBytes": 2048,
+                "downloadTimeMs": 150,
+                "uploads": 1,
+                "uploadedBytes": 1024,
+                "uploadTimeMs": 1000,
+            })
+        );
+    }
+
+    #[tokio::test]
+    async fn test_upload_progress() {
+        let tracker = TransferTracker::default();
+        assert_eq!(tracker.upload_progress(), None);
+
+        let chunks = vec![Ok::<_, ()>(Bytes::from_static(b"hello "))];
+        let (mut progress, query) =
+            UploadProgress::<10, 100, _>::new(stream::iter(chunks), Some(11));
+        tracker
+            .uploads()
+            .lock()
+            .unwrap()
+            .insert("abc".to_string(), query);
+        progress.next().await.unwrap().unwrap();
+
+        let (uploads, progress_so_far) = tracker.upload_progress().unwrap();
+        assert_eq!(uploads, 1);
+        assert_eq!(progress_so_far.bytes, 6);
+        assert_eq!(progress_so_far.size, Some(11));
+
+        // Finished uploads are left out
+        drop(progress);
+        assert_eq!(tracker.upload_progress(), None);
+    }
+}
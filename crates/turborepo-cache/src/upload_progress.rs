@@ -101,7 +101,7 @@ impl<T: ProgressLen, E> ProgressLen for Result<T, E> {
     }
 }
 
-#[derive(Clone)]
+#[derive(Clone, Debug)]
 pub struct UploadProgressQuery<const BUCKETS: usize, const INTERVAL: usize> {
     start: Instant,
     state: Weak<State<BUCKETS>>,
@@ -91,6 +91,11 @@ impl Vendor {
     pub fn get_constant() -> Option<&'static str> {
         Self::infer().map(|v| v.constant)
     }
+
+    /// Whether the current CI vendor can collapse groups of log lines
+    pub fn supports_log_grouping() -> bool {
+        Self::infer().is_some_and(|v| v.behavior.is_some())
+    }
 }
 
 #[cfg(test)]
@@ -1,4 +1,9 @@
-use std::{collections::HashMap, fmt::Debug, sync::OnceLock};
+use std::{
+    collections::HashMap,
+    fmt::Debug,
+    sync::OnceLock,
+    time::{SystemTime, UNIX_EPOCH},
+};
 
 use crate::vendor_behavior::VendorBehavior;
 
@@ -145,7 +150,18 @@ pub(crate) fn get_vendors() -> &'static [Vendor] {
                     sha_env_var: None,
                     branch_env_var: None,
                     username_env_var: None,
-                    behavior: None,
+                    // Buildkite groups run until the next group header so there's no footer.
+                    // Failed tasks use an expanded group so the error is visible.
+                    behavior: Some(
+                        VendorBehavior::new(
+                            |group_name| format!("--- {group_name}\n"),
+                            |_| String::new(),
+                        )
+                        .with_error(
+                            |group_name| format!("+++ {group_name}\n"),
+                            |_| String::new(),
+                        ),
+                    ),
                 },
                 Vendor {
                     name: "CircleCI",
@@ -288,7 +304,16 @@ pub(crate) fn get_vendors() -> &'static [Vendor] {
                     sha_env_var: None,
                     branch_env_var: None,
                     username_env_var: None,
-                    behavior: None,
+                    behavior: Some(
+                        VendorBehavior::new(
+                            |group_name| gitlab_section_start(group_name, true),
+                            gitlab_section_end,
+                        )
+                        .with_error(
+                            |group_name| gitlab_section_start(group_name, false),
+                            gitlab_section_end,
+                        ),
+                    ),
                 },
                 Vendor {
                     name: "GoCD",
@@ -646,3 +671,75 @@ pub(crate) fn get_vendors() -> &'static [Vendor] {
         })
         .as_slice()
 }
+
+// GitLab section names may only contain letters, numbers, and `_`, `.`, or `-`
+fn gitlab_section_name(group_name: &str) -> String {
+    group_name
+        .chars()
+        .map(|c| match c {
+            'a'..='z' | 'A'..='Z' | '0'..='9' | '_' | '.' | '-' => c,
+            _ => '_',
+        })
+        .collect()
+}
+
+fn unix_timestamp() -> u64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|duration| duration.as_secs())
+        .unwrap_or_default()
+}
+
+fn gitlab_section_start(group_name: &str, collapsed: bool) -> String {
+    format!(
+        "\x1B[0Ksection_start:{}:{}[collapsed={collapsed}]\r\x1B[0K{group_name}\n",
+        unix_timestamp(),
+        gitlab_section_name(group_name),
+    )
+}
+
+fn gitlab_section_end(group_name: &str) -> String {
+    format!(
+        "\x1B[0Ksection_end:{}:{}\r\x1B[0K\n",
+        unix_timestamp(),
+        gitlab_section_name(group_name),
+    )
+}
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+
+    use super::*;
+
+    #[test_case("web:build", "web_build" ; "task id")]
+    #[test_case("@acme/ui#lint", "_acme_ui_lint" ; "scoped package")]
+    #[test_case("build.prod-1", "build.prod-1" ; "allowed characters")]
+    fn test_gitlab_section_name(group_name: &str, expected: &str) {
+        assert_eq!(gitlab_section_name(group_name), expected);
+    }
+
+    #[test]
+    fn test_buildkite_groups() {
+        let behavior = get_vendors()
+            .iter()
+            .find(|vendor| vendor.constant == "BUILDKITE")
+            .and_then(|vendor| vendor.behavior.as_ref())
+            .unwrap();
+        assert_eq!((behavior.group_prefix)("web:build"), "--- web:build\n");
+        assert_eq!(
+            behavior.error_group_prefix.map(|f| f("web:build")),
+            Some("+++ web:build\n".to_string())
+        );
+    }
+
+    #[test]
+    fn test_gitlab_section_markers() {
+        let start = gitlab_section_start("web:build", true);
+        assert!(start.starts_with("\x1B[0Ksection_start:"));
+        assert!(start.ends_with(":web_build[collapsed=true]\r\x1B[0Kweb:build\n"));
+        let end = gitlab_section_end("web:build");
+        assert!(end.starts_with("\x1B[0Ksection_end:"));
+        assert!(end.ends_with(":web_build\r\x1B[0K\n"));
+    }
+}
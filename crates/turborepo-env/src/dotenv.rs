@@ -0,0 +1,208 @@
+//! A parser for `.env` files. Supports comments, an optional `export` prefix,
+//! and single quoted, double quoted and unquoted values. Variables in values
+//! are not expanded.
+
+use std::{io, path::Path};
+
+use crate::{EnvironmentVariableMap, Error};
+
+/// Loads the variables from the dotenv files at `paths`, which are ordered
+/// from most to least significant: a variable in an earlier file takes
+/// precedence over the same variable in a later one. Files that don't exist
+/// are skipped since they are commonly left out of source control.
+pub fn load<P: AsRef<Path>>(paths: &[P]) -> Result<EnvironmentVariableMap, Error> {
+    let mut map = EnvironmentVariableMap::default();
+    for path in paths {
+        let path = path.as_ref();
+        let contents = match std::fs::read_to_string(path) {
+            Ok(contents) => contents,
+            Err(err) if err.kind() == io::ErrorKind::NotFound => continue,
+            Err(err) => {
+                return Err(Error::DotEnvRead {
+                    path: path.display().to_string(),
+                    reason: err.to_string(),
+                })
+            }
+        };
+        for (key, value) in parse(&path.display().to_string(), &contents)?.iter() {
+            map.entry(key.clone()).or_insert_with(|| value.clone());
+        }
+    }
+
+    Ok(map)
+}
+
+/// Parses the contents of a dotenv file. Later assignments to the same key
+/// take precedence.
+fn parse(path: &str, contents: &str) -> Result<EnvironmentVariableMap, Error> {
+    let mut map = EnvironmentVariableMap::default();
+    let mut lines = contents.lines().enumerate();
+    while let Some((index, line)) = lines.next() {
+        let line_number = index + 1;
+        let line = line.trim();
+        if line.is_empty() || line.starts_with('#') {
+            continue;
+        }
+        let line = line
+            .strip_prefix("export ")
+            .map(str::trim_start)
+            .unwrap_or(line);
+        let Some((key, value)) = line.split_once('=') else {
+            return Err(invalid_line(path, line_number, line));
+        };
+        let key = key.trim();
+        if !is_valid_key(key) {
+            return Err(invalid_line(path, line_number, line));
+        }
+        let value = value.trim_start();
+
+        let value = match value.chars().next() {
+            Some(quote @ ('"' | '\'')) => {
+                // Quoted values may span multiple lines
+                let mut raw = value[1..].to_string();
+                let end = loop {
+                    if let Some(end) = find_closing_quote(&raw, quote) {
+                        break end;
+                    }
+                    let Some((_, next)) = lines.next() else {
+                        return Err(invalid_line(path, line_number, line));
+                    };
+                    raw.push('\n');
+                    raw.push_str(next);
+                };
+                let rest = raw[end + 1..].trim();
+                if !rest.is_empty() && !rest.starts_with('#') {
+                    return Err(invalid_line(path, line_number, line));
+                }
+                let raw = &raw[..end];
+                if quote == '"' {
+                    unescape(raw)
+                } else {
+                    raw.to_string()
+                }
+            }
+            _ => {
+                // Unquoted values end at a comment preceded by whitespace
+                let value = match value.find(" #") {
+                    Some(comment) => &value[..comment],
+                    None => value,
+                };
+                value.trim_end().to_string()
+            }
+        };
+
+        map.insert(key.to_string(), value);
+    }
+
+    Ok(map)
+}
+
+fn invalid_line(path: &str, line: usize, text: &str) -> Error {
+    Error::DotEnv {
+        path: path.to_string(),
+        line,
+        text: text.to_string(),
+    }
+}
+
+fn is_valid_key(key: &str) -> bool {
+    let mut chars = key.chars();
+    chars
+        .next()
+        .is_some_and(|c| c.is_ascii_alphabetic() || c == '_')
+        && chars.all(|c| c.is_ascii_alphanumeric() || c == '_' || c == '.')
+}
+
+fn find_closing_quote(value: &str, quote: char) -> Option<usize> {
+    let mut escaped = false;
+    for (i, c) in value.char_indices() {
+        match c {
+            '\\' if quote == '"' && !escaped => escaped = true,
+            c if c == quote && !escaped => return Some(i),
+            _ => escaped = false,
+        }
+    }
+    None
+}
+
+fn unescape(value: &str) -> String {
+    let mut output = String::with_capacity(value.len());
+    let mut chars = value.chars();
+    while let Some(c) = chars.next() {
+        if c != '\\' {
+            output.push(c);
+            continue;
+        }
+        match chars.next() {
+            Some('n') => output.push('\n'),
+            Some('r') => output.push('\r'),
+            Some('t') => output.push('\t'),
+            Some(c @ ('"' | '\\' | '$')) => output.push(c),
+            Some(c) => {
+                output.push('\\');
+                output.push(c);
+            }
+            None => output.push('\\'),
+        }
+    }
+    output
+}
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+
+    use super::{load, parse};
+
+    #[test]
+    fn test_parse() {
+        let contents = r#"
+# comment
+API_URL=https://example.com # trailing comment
+export TOKEN = secret
+EMPTY=
+SINGLE='$NOT_EXPANDED # kept'
+DOUBLE="line one\nline \"two\""
+MULTILINE="first
+second"
+API_URL=https://example.com/v2
+"#;
+        let map = parse(".env", contents).unwrap();
+
+        assert_eq!(
+            map.to_hashable(),
+            vec![
+                "API_URL=https://example.com/v2",
+                "DOUBLE=line one\nline \"two\"",
+                "EMPTY=",
+                "MULTILINE=first\nsecond",
+                "SINGLE=$NOT_EXPANDED # kept",
+                "TOKEN=secret",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_load_precedence() {
+        let dir = tempfile::tempdir().unwrap();
+        let local = dir.path().join(".env.local");
+        let env = dir.path().join(".env");
+        std::fs::write(&local, "PORT=4000\n").unwrap();
+        std::fs::write(&env, "API_URL=https://example.com\nPORT=3000\n").unwrap();
+
+        let map = load(&[local, dir.path().join(".env.missing"), env]).unwrap();
+
+        assert_eq!(
+            map.to_hashable(),
+            vec!["API_URL=https://example.com", "PORT=4000"]
+        );
+    }
+
+    #[test_case("NO_EQUALS" ; "missing equals")]
+    #[test_case("1KEY=value" ; "invalid key")]
+    #[test_case("KEY=\"unterminated" ; "unterminated quote")]
+    #[test_case("KEY='value' extra" ; "trailing characters")]
+    fn test_parse_invalid(contents: &str) {
+        assert!(parse(".env", contents).is_err());
+    }
+}
@@ -11,12 +11,22 @@ use serde::Serialize;
 use sha2::{Digest, Sha256};
 use thiserror::Error;
 
+pub mod dotenv;
+
 const DEFAULT_ENV_VARS: [&str; 1] = ["VERCEL_ANALYTICS_ID"];
 
 #[derive(Clone, Debug, Error)]
 pub enum Error {
     #[error("Failed to parse regex: {0}")]
     Regex(#[from] regex::Error),
+    #[error("Invalid dotenv syntax in {path} on line {line}: {text}")]
+    DotEnv {
+        path: String,
+        line: usize,
+        text: String,
+    },
+    #[error("Failed to read dotenv file {path}: {reason}")]
+    DotEnvRead { path: String, reason: String },
 }
 
 // TODO: Consider using immutable data structures here
@@ -48,11 +58,23 @@ impl EnvironmentVariableMap {
     // This is the value used to print out the task hash input,
     // so the values are cryptographically hashed
     pub fn to_secret_hashable(&self) -> EnvironmentVariablePairs {
+        self.to_salted_hashable(None)
+    }
+
+    // Like to_secret_hashable, but each value is hashed together with the salt so
+    // that short values can't be found by hashing guesses without knowing it
+    pub fn to_salted_hashable(&self, salt: Option<&str>) -> EnvironmentVariablePairs {
         let mut pairs: Vec<String> = self
             .iter()
             .map(|(k, v)| {
                 if !v.is_empty() {
                     let mut hasher = Sha256::new();
+                    if let Some(salt) = salt {
+                        hasher.update(salt.as_bytes());
+                        // Separate the salt from the value so that moving characters
+                        // between them changes the hash
+                        hasher.update([0]);
+                    }
                     hasher.update(v.as_bytes());
                     let hash = hasher.finalize();
                     let hexed_hash = hex::encode(hash);
@@ -311,4 +333,28 @@ mod tests {
         let actual = super::wildcard_to_regex_pattern(pattern);
         assert_eq!(actual, expected);
     }
+
+    #[test]
+    fn test_salted_hashable() {
+        let map = super::EnvironmentVariableMap(
+            [("TOKEN", "abc"), ("EMPTY", "")]
+                .into_iter()
+                .map(|(k, v)| (k.to_string(), v.to_string()))
+                .collect(),
+        );
+
+        let unsalted = map.to_salted_hashable(None);
+        let abc_hash = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad";
+        assert_eq!(unsalted, map.to_secret_hashable());
+        assert_eq!(
+            unsalted,
+            vec!["EMPTY=".to_string(), format!("TOKEN={abc_hash}")]
+        );
+
+        let salted = map.to_salted_hashable(Some("pepper"));
+        assert_eq!(salted, map.to_salted_hashable(Some("pepper")));
+        assert_eq!(salted[0], "EMPTY=");
+        assert_ne!(salted[1], unsalted[1]);
+        assert_ne!(salted, map.to_salted_hashable(Some("salt")));
+    }
 }
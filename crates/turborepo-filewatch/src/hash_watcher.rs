@@ -1,7 +1,7 @@
 use std::{
     collections::{HashMap, HashSet},
     sync::{
-        atomic::{AtomicUsize, Ordering},
+        atomic::{AtomicU64, AtomicUsize, Ordering},
         Arc,
     },
     time::Duration,
@@ -15,11 +15,13 @@ use tokio::{
     sync::{broadcast, mpsc, oneshot, watch},
 };
 use tracing::{debug, trace};
-use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf};
+use turbopath::{
+    AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPathBuf,
+};
 use turborepo_repository::discovery::DiscoveryResponse;
 use turborepo_scm::{
     package_deps::{GitHashes, INPUT_INCLUDE_DEFAULT_FILES},
-    Error as SCMError, SCM,
+    Error as SCMError, TurboIgnore, SCM, TURBOIGNORE,
 };
 
 use crate::{
@@ -55,11 +57,13 @@ impl InputGlobs {
         }
     }
 
-    fn is_package_local(&self) -> bool {
+    /// The globs that match files outside of the package, if there are any
+    fn outside_package(&self) -> Option<&GlobSet> {
         match self {
-            InputGlobs::Default => true,
-            InputGlobs::DefaultWithExtras(glob_set) => glob_set.is_package_local(),
-            InputGlobs::Specific(glob_set) => glob_set.is_package_local(),
+            InputGlobs::Default => None,
+            InputGlobs::DefaultWithExtras(glob_set) | InputGlobs::Specific(glob_set) => {
+                (!glob_set.is_package_local()).then_some(glob_set)
+            }
         }
     }
 
@@ -82,12 +86,6 @@ pub struct HashSpec {
     pub inputs: InputGlobs,
 }
 
-impl HashSpec {
-    fn is_package_local(&self) -> bool {
-        self.inputs.is_package_local()
-    }
-}
-
 #[derive(Error, Debug)]
 pub enum Error {
     #[error("package hashing encountered an error: {0}")]
@@ -96,8 +94,6 @@ pub enum Error {
     Unavailable(String),
     #[error("package not found: {} {:?}", .0.package_path, .0.inputs)]
     UnknownPackage(HashSpec),
-    #[error("unsupported: glob traverses out of the package")]
-    UnsupportedGlob,
 }
 
 // Communication errors that all funnel to Unavailable
@@ -120,6 +116,23 @@ impl<T> From<mpsc::error::SendError<T>> for Error {
     }
 }
 
+/// A snapshot of what the hash watcher is currently tracking
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct HashWatcherStats {
+    /// Number of distinct files we have hashes for across all packages
+    pub tracked_files: usize,
+    /// Number of hash computations that haven't completed yet
+    pub pending_hashes: usize,
+    /// The most recent hashing or file watching error, if any
+    pub last_error: Option<String>,
+    /// Number of queries answered with already computed hashes
+    pub hash_hits: u64,
+    /// Number of queries that had to wait for, or couldn't get, hashes
+    pub hash_misses: u64,
+    /// Number of times a file change caused hashes to be recomputed
+    pub invalidations: u64,
+}
+
 impl HashWatcher {
     pub fn new(
         repo_root: AbsoluteSystemPathBuf,
@@ -149,6 +162,12 @@ impl HashWatcher {
         self.query_tx.send(Query::GetHash(hash_spec, tx)).await?;
         rx.await?
     }
+
+    pub async fn stats(&self) -> Result<HashWatcherStats, Error> {
+        let (tx, rx) = oneshot::channel();
+        self.query_tx.send(Query::Stats(tx)).await?;
+        Ok(rx.await?)
+    }
 }
 
 struct Subscriber {
@@ -157,11 +176,19 @@ struct Subscriber {
     query_rx: mpsc::Receiver<Query>,
     scm: SCM,
     next_version: AtomicUsize,
+    last_error: Option<String>,
+    hash_hits: AtomicU64,
+    hash_misses: AtomicU64,
+    invalidations: AtomicU64,
+    // The .turboignore patterns of each package, loaded when a file in the
+    // package first changes
+    turbo_ignores: HashMap<AnchoredSystemPathBuf, TurboIgnore>,
 }
 
 #[derive(Debug)]
 enum Query {
     GetHash(HashSpec, oneshot::Sender<Result<GitHashes, Error>>),
+    Stats(oneshot::Sender<HashWatcherStats>),
 }
 
 // Version is a type that exists to stamp an asynchronous hash computation
@@ -179,6 +206,40 @@ enum HashState {
     ),
     Unavailable(String),
 }
+// The paths from a package to a file outside of it. Globs for these files
+// either go through the root of the repository, e.g. `../../tsconfig.json`
+// for a `$TURBO_ROOT$/tsconfig.json` input, or through the closest common
+// directory, e.g. `../shared/tsconfig.json`.
+fn paths_from_package(
+    package_path: &AnchoredSystemPath,
+    file_path: &AnchoredSystemPath,
+) -> Vec<RelativeUnixPathBuf> {
+    let package_path = package_path.to_unix();
+    let file_path = file_path.to_unix();
+    let package_components = package_path
+        .as_str()
+        .split('/')
+        .filter(|component| !component.is_empty())
+        .collect::<Vec<_>>();
+    let file_components = file_path.as_str().split('/').collect::<Vec<_>>();
+    let common = package_components
+        .iter()
+        .zip(&file_components)
+        .take_while(|(package, file)| package == file)
+        .count();
+
+    let mut paths = [0, common]
+        .into_iter()
+        .map(|prefix| {
+            let mut components = vec![".."; package_components.len() - prefix];
+            components.extend(&file_components[prefix..]);
+            RelativeUnixPathBuf::new(components.join("/")).expect("path is relative")
+        })
+        .collect::<Vec<_>>();
+    paths.dedup();
+    paths
+}
+
 // We use a radix_trie to store hashes so that we can quickly match a file path
 // to a package without having to iterate over the set of all packages. We
 // expect file changes to be the highest volume of events that this service
@@ -229,7 +290,54 @@ impl FileHashes {
         }
     }
 
+    fn specs(&self) -> Vec<HashSpec> {
+        self.0
+            .iter()
+            .flat_map(|(package_path, specs)| {
+                let package_path = AnchoredSystemPath::new(package_path)
+                    .expect("keys are valid AnchoredSystemPaths");
+                specs.keys().map(move |inputs| HashSpec {
+                    package_path: package_path.to_owned(),
+                    inputs: inputs.clone(),
+                })
+            })
+            .collect()
+    }
+
     fn get_changed_specs(&self, file_path: &AnchoredSystemPath) -> HashSet<HashSpec> {
+        let mut changed_specs = self.get_changed_package_specs(file_path);
+        changed_specs.extend(self.get_changed_outside_specs(file_path));
+        changed_specs
+    }
+
+    // Finds the specs of inputs that are outside of their package, e.g. a
+    // tsconfig.json at the root of the repository, that match the change. Every
+    // package has to be checked since the change isn't in the package.
+    fn get_changed_outside_specs(&self, file_path: &AnchoredSystemPath) -> HashSet<HashSpec> {
+        let mut changed_specs = HashSet::new();
+        for (package_path, specs) in self.0.iter() {
+            let package_path =
+                AnchoredSystemPath::new(package_path).expect("keys are valid AnchoredSystemPaths");
+            if file_path.strip_prefix(package_path).is_some() {
+                continue;
+            }
+            let paths_from_package = paths_from_package(package_path, file_path);
+            for inputs in specs.keys() {
+                let Some(glob_set) = inputs.outside_package() else {
+                    continue;
+                };
+                if paths_from_package.iter().any(|path| glob_set.matches(path)) {
+                    changed_specs.insert(HashSpec {
+                        package_path: package_path.to_owned(),
+                        inputs: inputs.clone(),
+                    });
+                }
+            }
+        }
+        changed_specs
+    }
+
+    fn get_changed_package_specs(&self, file_path: &AnchoredSystemPath) -> HashSet<HashSpec> {
         self.0
             .get_ancestor(file_path.as_str())
             // verify we have a key
@@ -309,6 +417,26 @@ impl FileHashes {
             .get_mut(key.package_path.as_str())
             .and_then(|states| states.get_mut(&key.inputs))
     }
+
+    fn stats(&self, last_error: Option<String>) -> HashWatcherStats {
+        let mut stats = HashWatcherStats {
+            last_error,
+            ..Default::default()
+        };
+        for states in self.0.values() {
+            // Different sets of inputs for a package overlap, so only count each file once
+            let mut files = HashSet::new();
+            for state in states.values() {
+                match state {
+                    HashState::Hashes(hashes) => files.extend(hashes.keys()),
+                    HashState::Pending(..) => stats.pending_hashes += 1,
+                    HashState::Unavailable(_) => {}
+                }
+            }
+            stats.tracked_files += files.len();
+        }
+        stats
+    }
 }
 
 struct HashUpdate {
@@ -330,6 +458,11 @@ impl Subscriber {
             scm,
             query_rx,
             next_version: AtomicUsize::new(0),
+            last_error: None,
+            hash_hits: AtomicU64::new(0),
+            hash_misses: AtomicU64::new(0),
+            invalidations: AtomicU64::new(0),
+            turbo_ignores: HashMap::new(),
         }
     }
 
@@ -382,6 +515,7 @@ impl Subscriber {
                         },
                         Ok(Err(e)) => {
                             debug!("file watcher error: {:?}", e);
+                            self.last_error = Some(format!("file watcher error: {e}"));
                             self.flush_and_rehash(&mut hashes, &hash_update_tx, &package_data, &format!("file watcher error: {e}"));
                         },
                         Err(broadcast::error::RecvError::Closed) => {
@@ -397,7 +531,9 @@ impl Subscriber {
                 },
                 hash_update = hash_update_rx.recv() => {
                     if let Some(hash_update) = hash_update {
-                        self.handle_hash_update(hash_update, &mut hashes);
+                        if let Some(error) = self.handle_hash_update(hash_update, &mut hashes) {
+                            self.last_error = Some(error);
+                        }
                     } else {
                         // note that we only ever lend out hash_update_tx, so this should be impossible
                         unreachable!("hash update channel closed, but we have a live reference to it");
@@ -435,24 +571,18 @@ impl Subscriber {
         //trace!("handling query {query:?}");
         match query {
             Query::GetHash(spec, tx) => {
-                // We don't currently support inputs that are not package-local. Adding this
-                // support would require tracking arbitrary file paths and
-                // mapping them back to packages. It is doable if we want to
-                // attempt it in the future.
-                if !spec.is_package_local() {
-                    let _ = tx.send(Err(Error::UnsupportedGlob));
-                    trace!("unsupported glob in query {:?}", spec);
-                    return;
-                }
                 if let Some(state) = hashes.get_mut(&spec) {
                     match state {
                         HashState::Hashes(hashes) => {
+                            self.hash_hits.fetch_add(1, Ordering::Relaxed);
                             tx.send(Ok(hashes.clone())).unwrap();
                         }
                         HashState::Pending(_, _, txs) => {
+                            self.hash_misses.fetch_add(1, Ordering::Relaxed);
                             txs.push(tx);
                         }
                         HashState::Unavailable(e) => {
+                            self.hash_misses.fetch_add(1, Ordering::Relaxed);
                             let _ = tx.send(Err(Error::HashingError(e.clone())));
                         }
                     }
@@ -464,19 +594,30 @@ impl Subscriber {
                 {
                     // in this scenario, we know the package exists, but we aren't tracking these
                     // particular inputs. Queue a hash request for them.
+                    self.hash_misses.fetch_add(1, Ordering::Relaxed);
                     let (version, debouncer) = self.queue_package_hash(&spec, hash_update_tx, true);
                     // this request will likely time out. However, if the client has asked for
                     // this spec once, they might ask again, and we can start tracking it.
                     hashes.insert(spec, HashState::Pending(version, debouncer, vec![tx]));
                 } else {
                     // We don't know anything about this package.
+                    self.hash_misses.fetch_add(1, Ordering::Relaxed);
                     let _ = tx.send(Err(Error::UnknownPackage(spec)));
                 }
             }
+            Query::Stats(tx) => {
+                let _ = tx.send(HashWatcherStats {
+                    hash_hits: self.hash_hits.load(Ordering::Relaxed),
+                    hash_misses: self.hash_misses.load(Ordering::Relaxed),
+                    invalidations: self.invalidations.load(Ordering::Relaxed),
+                    ..hashes.stats(self.last_error.clone())
+                });
+            }
         }
     }
 
-    fn handle_hash_update(&self, update: HashUpdate, hashes: &mut FileHashes) {
+    /// Returns the error if the update was a failed hash computation
+    fn handle_hash_update(&self, update: HashUpdate, hashes: &mut FileHashes) -> Option<String> {
         let HashUpdate {
             spec,
             version,
@@ -504,12 +645,14 @@ impl Subscriber {
                                 // We don't care if the client has gone away
                                 let _ = pending_query.send(Err(Error::HashingError(error.clone())));
                             }
-                            *state = HashState::Unavailable(error);
+                            *state = HashState::Unavailable(error.clone());
+                            return Some(error);
                         }
                     }
                 }
             }
         }
+        None
     }
 
     fn queue_package_hash(
@@ -550,7 +693,7 @@ impl Subscriber {
     }
 
     fn handle_file_event(
-        &self,
+        &mut self,
         event: Event,
         hashes: &mut FileHashes,
         hash_update_tx: &mpsc::Sender<HashUpdate>,
@@ -564,7 +707,18 @@ impl Subscriber {
                 .expect("event path is in the repository");
             // If this change is not relevant to a package, ignore it
             trace!("file change at {:?}", repo_relative_change_path);
-            let changed_specs_for_path = hashes.get_changed_specs(&repo_relative_change_path);
+            if path.file_name() == Some(TURBOIGNORE) {
+                self.turbo_ignores.clear();
+                // The root .turboignore applies to every package
+                if repo_relative_change_path.as_str() == TURBOIGNORE {
+                    changed_specs.extend(hashes.specs());
+                }
+            }
+            let changed_specs_for_path = hashes
+                .get_changed_specs(&repo_relative_change_path)
+                .into_iter()
+                .filter(|spec| !self.is_turbo_ignored(spec, &repo_relative_change_path))
+                .collect::<HashSet<_>>();
             if !changed_specs_for_path.is_empty() {
                 // We have a file change in a package, and we haven't seen this package yet.
                 // Queue it for rehashing.
@@ -584,6 +738,8 @@ impl Subscriber {
         // Any rehashing we do was triggered by a file event, so don't do it
         // immediately. Wait for the debouncer to time out instead.
         let immediate = false;
+        self.invalidations
+            .fetch_add(changed_specs.len() as u64, Ordering::Relaxed);
         for spec in changed_specs {
             match hashes.get_mut(&spec) {
                 // Technically this shouldn't happen, the package_paths are sourced from keys in
@@ -617,6 +773,28 @@ impl Subscriber {
         }
     }
 
+    // Whether a change to `path` can't affect the hashes of `spec` because the
+    // path is ignored by a .turboignore
+    fn is_turbo_ignored(&mut self, spec: &HashSpec, path: &AnchoredSystemPath) -> bool {
+        let repo_root = &self.repo_root;
+        // Inputs can be outside of the package, which the root .turboignore
+        // applies to
+        let package_relative_path = AnchoredSystemPathBuf::relative_path_between(
+            &repo_root.resolve(&spec.package_path),
+            &repo_root.resolve(path),
+        );
+        let turbo_ignore = self
+            .turbo_ignores
+            .entry(spec.package_path.clone())
+            .or_insert_with(|| {
+                TurboIgnore::load(repo_root, &spec.package_path).unwrap_or_else(|e| {
+                    debug!("failed to load .turboignore: {e}");
+                    TurboIgnore::default()
+                })
+            });
+        turbo_ignore.is_ignored(&package_relative_path.to_unix())
+    }
+
     fn handle_package_data_update(
         &self,
         package_data: &Option<Result<DiscoveryResponse, String>>,
@@ -670,6 +848,7 @@ impl Subscriber {
 mod tests {
     use std::{
         assert_matches::assert_matches,
+        sync::Arc,
         time::{Duration, Instant},
     };
 
@@ -680,11 +859,12 @@ mod tests {
     };
     use turborepo_scm::{package_deps::GitHashes, SCM};
 
-    use super::{FileHashes, HashState};
+    use super::{paths_from_package, FileHashes, HashState, Version};
     use crate::{
         cookies::CookieWriter,
+        debouncer::Debouncer,
         globwatcher::GlobSet,
-        hash_watcher::{HashSpec, HashWatcher, InputGlobs},
+        hash_watcher::{HashSpec, HashWatcher, HashWatcherStats, InputGlobs},
         package_watcher::PackageWatcher,
         FileSystemWatcher,
     };
@@ -1083,6 +1263,98 @@ mod tests {
         assert!(result.is_empty());
     }
 
+    #[test]
+    fn test_file_hashes_outside_package() {
+        let mut hashes = FileHashes::new();
+
+        let root = AnchoredSystemPathBuf::try_from("").unwrap();
+        let foo_path = root.join_components(&["apps", "foo"]);
+        let foo_spec = HashSpec {
+            package_path: foo_path.clone(),
+            inputs: InputGlobs::Specific(
+                GlobSet::from_raw(vec!["src/**".into(), "../../tsconfig.json".into()], vec![])
+                    .unwrap(),
+            ),
+        };
+        hashes.insert(foo_spec.clone(), HashState::Hashes(GitHashes::new()));
+        let bar_path = root.join_components(&["apps", "bar"]);
+        let bar_spec = HashSpec {
+            package_path: bar_path.clone(),
+            inputs: InputGlobs::Default,
+        };
+        hashes.insert(bar_spec, HashState::Hashes(GitHashes::new()));
+
+        let tsconfig = root.join_component("tsconfig.json");
+        let result = hashes.get_changed_specs(&tsconfig);
+        assert_eq!(result.into_iter().collect::<Vec<_>>(), vec![foo_spec]);
+
+        let bar_tsconfig = bar_path.join_component("tsconfig.json");
+        let result = hashes.get_changed_specs(&bar_tsconfig);
+        assert_eq!(result.len(), 1);
+        assert_eq!(result.into_iter().next().unwrap().package_path, bar_path);
+    }
+
+    #[test]
+    fn test_paths_from_package() {
+        let root = AnchoredSystemPathBuf::try_from("").unwrap();
+        let foo_path = root.join_components(&["apps", "foo"]);
+
+        assert_eq!(
+            paths_from_package(&foo_path, &root.join_component("tsconfig.json")),
+            vec![RelativeUnixPathBuf::new("../../tsconfig.json").unwrap()]
+        );
+        assert_eq!(
+            paths_from_package(
+                &foo_path,
+                &root.join_components(&["apps", "shared", "a.ts"])
+            ),
+            vec![
+                RelativeUnixPathBuf::new("../../apps/shared/a.ts").unwrap(),
+                RelativeUnixPathBuf::new("../shared/a.ts").unwrap(),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_file_hashes_stats() {
+        let mut hashes = FileHashes::new();
+
+        let root = AnchoredSystemPathBuf::try_from("").unwrap();
+        let foo_path = root.join_components(&["apps", "foo"]);
+        hashes.insert(
+            HashSpec {
+                package_path: foo_path.clone(),
+                inputs: InputGlobs::Default,
+            },
+            HashState::Hashes(make_expected(vec![("a", "1"), ("b", "2")])),
+        );
+        // overlaps with the default inputs, "a" shouldn't be counted twice
+        hashes.insert(
+            HashSpec {
+                package_path: foo_path,
+                inputs: InputGlobs::Specific(GlobSet::from_raw(vec!["a".into()], vec![]).unwrap()),
+            },
+            HashState::Hashes(make_expected(vec![("a", "1")])),
+        );
+        hashes.insert(
+            HashSpec {
+                package_path: root.join_components(&["apps", "bar"]),
+                inputs: InputGlobs::Default,
+            },
+            HashState::Pending(Version(0), Arc::new(Debouncer::default()), vec![]),
+        );
+
+        assert_eq!(
+            hashes.stats(Some("oops".to_string())),
+            HashWatcherStats {
+                tracked_files: 2,
+                pending_hashes: 1,
+                last_error: Some("oops".to_string()),
+                ..Default::default()
+            }
+        );
+    }
+
     #[tokio::test]
     #[tracing_test::traced_test]
     async fn test_basic_file_changes_with_inputs() {
@@ -25,10 +25,11 @@
 #![feature(assert_matches)]
 
 use std::{
+    any::Any,
     fmt::{Debug, Display},
     future::IntoFuture,
-    path::Path,
-    sync::Arc,
+    path::{Path, PathBuf},
+    sync::{Arc, Mutex},
     time::Duration,
 };
 
@@ -37,25 +38,19 @@ use std::{
 // macos -> custom watcher impl in fsevents, no recursive watch, no watching ancestors
 #[cfg(target_os = "macos")]
 use fsevent::FsEventWatcher;
-#[cfg(any(feature = "manual_recursive_watch", feature = "watch_ancestors"))]
-use notify::event::EventKind;
+use notify::{
+    event::{CreateKind, EventAttributes},
+    Event, EventHandler, EventKind, PollWatcher, RecursiveMode, Watcher,
+};
 #[cfg(not(target_os = "macos"))]
 use notify::{Config, RecommendedWatcher};
-use notify::{Event, EventHandler, RecursiveMode, Watcher};
 use thiserror::Error;
 use tokio::sync::{broadcast, mpsc, watch::error::RecvError};
 use tracing::{debug, warn};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, PathRelation};
+use walkdir::{DirEntry, WalkDir};
 #[cfg(feature = "manual_recursive_watch")]
-use {
-    notify::{
-        event::{CreateKind, EventAttributes},
-        ErrorKind,
-    },
-    std::io,
-    tracing::trace,
-    walkdir::WalkDir,
-};
+use {notify::ErrorKind, std::io, tracing::trace};
 
 pub mod cookies;
 mod debouncer;
@@ -65,15 +60,26 @@ pub mod globwatcher;
 pub mod hash_watcher;
 mod optional_watch;
 pub mod package_watcher;
+mod watchman;
 
 pub use optional_watch::OptionalWatch;
+pub use watchman::WatchmanBackend;
 
 #[cfg(not(target_os = "macos"))]
 type Backend = RecommendedWatcher;
 #[cfg(target_os = "macos")]
 type Backend = FsEventWatcher;
 
-type EventResult = Result<Event, notify::Error>;
+pub type EventResult = Result<Event, notify::Error>;
+
+// How long file watching has to deliver the initial cookie, on top of the
+// latency of the backend
+const INITIAL_COOKIE_TIMEOUT: Duration = Duration::from_millis(2000);
+const DEFAULT_POLL_INTERVAL: Duration = Duration::from_secs(1);
+// Directories that polling doesn't scan. They're large and changes in them
+// aren't used by the daemon, but each of their files would be checked on
+// every poll.
+const POLLING_IGNORED_DIRS: &[&str] = &[".git", "node_modules"];
 
 #[derive(Debug, Error)]
 pub enum WatchError {
@@ -105,6 +111,199 @@ impl Display for NotifyError {
     }
 }
 
+/// A source of file system events for a [`FileSystemWatcher`]. Backends send
+/// an event for every change under the watched root until the handle returned
+/// by `watch` is dropped.
+pub trait WatchBackend: Send + 'static {
+    /// A short name for the backend, used in logs
+    fn name(&self) -> &'static str;
+
+    /// The longest it can take for a change to be reported
+    fn latency(&self) -> Duration {
+        Duration::ZERO
+    }
+
+    /// Starts watching `root`. This is called on a blocking thread, so slow
+    /// setup work is fine.
+    fn watch(
+        self: Box<Self>,
+        root: &AbsoluteSystemPath,
+        sender: mpsc::Sender<EventResult>,
+    ) -> Result<Box<dyn Any + Send>, WatchError>;
+}
+
+/// Watches with the platform's file watching API: FSEvents on macOS, inotify
+/// on Linux, and ReadDirectoryChangesW on Windows.
+#[derive(Debug, Default, Clone, Copy)]
+pub struct NativeBackend;
+
+impl WatchBackend for NativeBackend {
+    fn name(&self) -> &'static str {
+        "native"
+    }
+
+    fn watch(
+        self: Box<Self>,
+        root: &AbsoluteSystemPath,
+        sender: mpsc::Sender<EventResult>,
+    ) -> Result<Box<dyn Any + Send>, WatchError> {
+        Ok(Box::new(run_watcher(root, sender)?))
+    }
+}
+
+/// Periodically scans the root for changes. This is slower than watching
+/// natively, but it works on every file system and isn't limited by the
+/// number of watches the operating system allows. `.git` and `node_modules`
+/// directories aren't scanned.
+#[derive(Debug, Clone, Copy)]
+pub struct PollingBackend {
+    interval: Duration,
+}
+
+impl PollingBackend {
+    pub fn new(interval: Duration) -> Self {
+        Self { interval }
+    }
+}
+
+impl Default for PollingBackend {
+    fn default() -> Self {
+        Self::new(DEFAULT_POLL_INTERVAL)
+    }
+}
+
+impl WatchBackend for PollingBackend {
+    fn name(&self) -> &'static str {
+        "polling"
+    }
+
+    fn latency(&self) -> Duration {
+        self.interval
+    }
+
+    fn watch(
+        self: Box<Self>,
+        root: &AbsoluteSystemPath,
+        sender: mpsc::Sender<EventResult>,
+    ) -> Result<Box<dyn Any + Send>, WatchError> {
+        let (new_dirs_tx, new_dirs_rx) = std::sync::mpsc::channel();
+        let event_sender = sender.clone();
+        let mut watcher = PollWatcher::new(
+            move |res: EventResult| {
+                if let Ok(event) = &res {
+                    if matches!(event.kind, EventKind::Create(_)) {
+                        for path in event.paths.iter().filter(|path| path.is_dir()) {
+                            let _ = new_dirs_tx.send(path.clone());
+                        }
+                    }
+                }
+                let _ = event_sender.blocking_send(res);
+            },
+            notify::Config::default().with_poll_interval(self.interval),
+        )?;
+        // Each directory is polled on its own so that ignored directories can
+        // be skipped
+        for dir in polled_dirs(root.as_std_path()) {
+            watcher.watch(&dir?, RecursiveMode::NonRecursive)?;
+        }
+
+        let watcher = Arc::new(Mutex::new(watcher));
+        // The watcher can't be changed while it reports events, so new
+        // directories are watched from another thread
+        std::thread::spawn({
+            let watcher = Arc::downgrade(&watcher);
+            move || {
+                for new_dir in new_dirs_rx {
+                    let Some(watcher) = watcher.upgrade() else {
+                        break;
+                    };
+                    if let Err(e) = poll_new_dir(&new_dir, &watcher, &sender) {
+                        warn!("failed to poll {}: {}", new_dir.display(), e);
+                    }
+                }
+            }
+        });
+        Ok(Box::new(watcher))
+    }
+}
+
+fn is_polling_ignored(entry: &DirEntry) -> bool {
+    entry.file_type().is_dir()
+        && entry
+            .file_name()
+            .to_str()
+            .is_some_and(|name| POLLING_IGNORED_DIRS.contains(&name))
+}
+
+// The directories under `root` that polling scans, including `root`
+fn polled_dirs(root: &Path) -> impl Iterator<Item = Result<PathBuf, walkdir::Error>> {
+    WalkDir::new(root)
+        .follow_links(false)
+        .into_iter()
+        .filter_entry(|entry| entry.depth() == 0 || !is_polling_ignored(entry))
+        .filter_map(|entry| match entry {
+            Ok(entry) if entry.file_type().is_dir() => Some(Ok(entry.into_path())),
+            Ok(_) => None,
+            Err(e) => Some(Err(e)),
+        })
+}
+
+// Polls a directory that was created after polling started. Its contents may
+// have been written before it was polled, so they're reported as created.
+fn poll_new_dir(
+    new_dir: &Path,
+    watcher: &Mutex<PollWatcher>,
+    sender: &mpsc::Sender<EventResult>,
+) -> Result<(), WatchError> {
+    if new_dir
+        .file_name()
+        .and_then(|name| name.to_str())
+        .is_some_and(|name| POLLING_IGNORED_DIRS.contains(&name))
+    {
+        return Ok(());
+    }
+    for dir in polled_dirs(new_dir) {
+        watcher
+            .lock()
+            .expect("polling watcher lock poisoned")
+            .watch(&dir?, RecursiveMode::NonRecursive)?;
+    }
+    for entry in WalkDir::new(new_dir)
+        .min_depth(1)
+        .follow_links(false)
+        .into_iter()
+        .filter_entry(|entry| !is_polling_ignored(entry))
+    {
+        let entry = entry?;
+        let create_kind = if entry.file_type().is_dir() {
+            CreateKind::Folder
+        } else {
+            CreateKind::File
+        };
+        let event = Event {
+            paths: vec![entry.into_path()],
+            kind: EventKind::Create(create_kind),
+            attrs: EventAttributes::default(),
+        };
+        // It's ok if we fail to send, it means we're shutting down
+        let _ = sender.blocking_send(Ok(event));
+    }
+    Ok(())
+}
+
+// Why file watching stopped
+#[derive(Debug, Clone, Copy, PartialEq)]
+enum WatchEnd {
+    Exit,
+    // The operating system doesn't allow any more watches, e.g. inotify's
+    // `max_user_watches`
+    OutOfWatches,
+}
+
+fn is_out_of_watches(error: &notify::Error) -> bool {
+    matches!(error.kind, notify::ErrorKind::MaxFilesWatch)
+}
+
 pub struct FileSystemWatcher {
     receiver: OptionalWatch<broadcast::Receiver<Result<Event, NotifyError>>>,
     // _exit_ch exists to trigger a close on the receiver when an instance
@@ -113,6 +312,7 @@ pub struct FileSystemWatcher {
     // to be notified of a close.
     _exit_ch: tokio::sync::oneshot::Sender<()>,
     cookie_dir: AbsoluteSystemPathBuf,
+    latency: Duration,
 }
 
 impl FileSystemWatcher {
@@ -126,6 +326,22 @@ impl FileSystemWatcher {
     pub fn new(
         root: &AbsoluteSystemPath,
         cookie_dir: AbsoluteSystemPathBuf,
+    ) -> Result<Self, WatchError> {
+        Self::new_with_backend(
+            root,
+            cookie_dir,
+            Box::new(NativeBackend),
+            Some(Box::new(PollingBackend::default())),
+        )
+    }
+
+    /// Creates a watcher that gets its events from `backend`. If the backend
+    /// fails to start, file watching is set up with `fallback` instead.
+    pub fn new_with_backend(
+        root: &AbsoluteSystemPath,
+        cookie_dir: AbsoluteSystemPathBuf,
+        backend: Box<dyn WatchBackend>,
+        fallback: Option<Box<dyn WatchBackend>>,
     ) -> Result<Self, WatchError> {
         tracing::debug!("initing file-system watcher");
 
@@ -136,36 +352,41 @@ impl FileSystemWatcher {
             )));
         }
 
+        let latency = fallback.as_ref().map_or(backend.latency(), |fallback| {
+            backend.latency().max(fallback.latency())
+        });
         let (file_events_receiver_tx, file_events_receiver_lazy) = OptionalWatch::new();
-        let (send_file_events, mut recv_file_events) = mpsc::channel(1024);
-        let (exit_ch, exit_signal) = tokio::sync::oneshot::channel();
+        let (exit_ch, mut exit_signal) = tokio::sync::oneshot::channel();
 
         tokio::task::spawn({
             let cookie_dir = cookie_dir.clone();
             let watch_root = root.to_owned();
             async move {
-                // this task never yields, so run it in the blocking threadpool
-                let watch_root_task = watch_root.clone();
                 let cookie_dir_task = cookie_dir.clone();
-                let task = tokio::task::spawn_blocking(move || {
-                    setup_cookie_dir(&cookie_dir_task)?;
-                    run_watcher(&watch_root_task, send_file_events)
-                });
+                let setup = tokio::task::spawn_blocking(move || setup_cookie_dir(&cookie_dir_task));
+                if !matches!(setup.await, Ok(Ok(()))) {
+                    return;
+                }
 
-                let Ok(Ok(watcher)) = task.await else {
+                let mut fallback = fallback;
+                let name = backend.name();
+                let mut started = start_backend(&watch_root, &cookie_dir, backend).await;
+                if started.is_none() {
+                    if let Some(fallback) = fallback.take() {
+                        warn!(
+                            "{} file watching couldn't be started, falling back to {} file \
+                             watching",
+                            name,
+                            fallback.name()
+                        );
+                        started = start_backend(&watch_root, &cookie_dir, fallback).await;
+                    }
+                }
+                let Some((mut watcher, mut recv_file_events)) = started else {
                     // if the watcher fails, just return. we don't set the event sender, and other
                     // services will never start
                     return;
                 };
-
-                // Ensure we are ready to receive new events, not events for existing state
-                debug!("waiting for initial filesystem cookie");
-                if let Err(e) = wait_for_cookie(&cookie_dir, &mut recv_file_events).await {
-                    // if we can't get a cookie here, we should not make the file
-                    // watching available to downstream services
-                    warn!("failed to wait for initial filesystem cookie: {}", e);
-                    return;
-                }
                 debug!("filewatching ready");
 
                 let (sender, receiver) = broadcast::channel(1024);
@@ -178,7 +399,34 @@ impl FileSystemWatcher {
                     return;
                 }
 
-                watch_events(watcher, watch_root, recv_file_events, exit_signal, sender).await;
+                loop {
+                    let end = watch_events(
+                        watcher,
+                        watch_root.clone(),
+                        recv_file_events,
+                        &mut exit_signal,
+                        sender.clone(),
+                    )
+                    .await;
+                    if end == WatchEnd::Exit {
+                        return;
+                    }
+                    // The error was already sent to the subscribers, which
+                    // start over from the state on disk
+                    let Some(fallback) = fallback.take() else {
+                        warn!("file watching stopped: ran out of file watches");
+                        return;
+                    };
+                    warn!(
+                        "ran out of file watches, falling back to {} file watching",
+                        fallback.name()
+                    );
+                    let Some(started) = start_backend(&watch_root, &cookie_dir, fallback).await
+                    else {
+                        return;
+                    };
+                    (watcher, recv_file_events) = started;
+                }
             }
         });
 
@@ -186,6 +434,7 @@ impl FileSystemWatcher {
             receiver: file_events_receiver_lazy,
             _exit_ch: exit_ch,
             cookie_dir,
+            latency,
         })
     }
 
@@ -205,6 +454,51 @@ impl FileSystemWatcher {
     pub fn cookie_dir(&self) -> &AbsoluteSystemPath {
         &self.cookie_dir
     }
+
+    /// The longest it can take for a change to be reported, including when
+    /// falling back to another backend
+    pub fn latency(&self) -> Duration {
+        self.latency
+    }
+}
+
+/// Starts `backend` and waits for it to report the initial cookie. Returns
+/// `None` if either step fails.
+async fn start_backend(
+    root: &AbsoluteSystemPath,
+    cookie_dir: &AbsoluteSystemPath,
+    backend: Box<dyn WatchBackend>,
+) -> Option<(Box<dyn Any + Send>, mpsc::Receiver<EventResult>)> {
+    let name = backend.name();
+    let timeout = INITIAL_COOKIE_TIMEOUT + backend.latency();
+    let (send_file_events, mut recv_file_events) = mpsc::channel(1024);
+
+    // this task never yields, so run it in the blocking threadpool
+    let watch_root = root.to_owned();
+    let task = tokio::task::spawn_blocking(move || backend.watch(&watch_root, send_file_events));
+    let watcher = match task.await {
+        Ok(Ok(watcher)) => watcher,
+        Ok(Err(e)) => {
+            warn!("failed to start {} file watching: {}", name, e);
+            return None;
+        }
+        Err(e) => {
+            warn!("failed to start {} file watching: {}", name, e);
+            return None;
+        }
+    };
+
+    // Ensure we are ready to receive new events, not events for existing state
+    debug!("waiting for initial filesystem cookie");
+    if let Err(e) = wait_for_cookie(cookie_dir, &mut recv_file_events, timeout).await {
+        // if we can't get a cookie here, we should not make the file
+        // watching available to downstream services
+        warn!("failed to wait for initial filesystem cookie: {}", e);
+        return None;
+    }
+    debug!("{} file watching started", name);
+
+    Some((watcher, recv_file_events))
 }
 
 fn setup_cookie_dir(cookie_dir: &AbsoluteSystemPath) -> Result<(), WatchError> {
@@ -225,19 +519,22 @@ fn setup_cookie_dir(cookie_dir: &AbsoluteSystemPath) -> Result<(), WatchError> {
 
 #[cfg(not(any(feature = "watch_ancestors", feature = "manual_recursive_watch")))]
 async fn watch_events(
-    _watcher: Backend,
+    _watcher: Box<dyn Any + Send>,
     _watch_root: AbsoluteSystemPathBuf,
     mut recv_file_events: mpsc::Receiver<EventResult>,
-    exit_signal: tokio::sync::oneshot::Receiver<()>,
+    exit_signal: &mut tokio::sync::oneshot::Receiver<()>,
     broadcast_sender: broadcast::Sender<Result<Event, NotifyError>>,
-) {
-    let mut exit_signal = exit_signal;
-    'outer: loop {
+) -> WatchEnd {
+    loop {
         tokio::select! {
-            _ = &mut exit_signal => break 'outer,
+            _ = &mut *exit_signal => return WatchEnd::Exit,
             Some(event) = recv_file_events.recv().into_future() => {
+                let out_of_watches = event.as_ref().is_err_and(is_out_of_watches);
                 // we don't care if we fail to send, it just means no one is currently watching
                 let _ = broadcast_sender.send(event.map_err(NotifyError::from));
+                if out_of_watches {
+                    return WatchEnd::OutOfWatches;
+                }
             }
         }
     }
@@ -245,17 +542,16 @@ async fn watch_events(
 
 #[cfg(any(feature = "watch_ancestors", feature = "manual_recursive_watch"))]
 async fn watch_events(
-    #[cfg(feature = "manual_recursive_watch")] mut watcher: Backend,
-    #[cfg(not(feature = "manual_recursive_watch"))] _watcher: Backend,
+    #[cfg(feature = "manual_recursive_watch")] mut watcher: Box<dyn Any + Send>,
+    #[cfg(not(feature = "manual_recursive_watch"))] _watcher: Box<dyn Any + Send>,
     watch_root: AbsoluteSystemPathBuf,
     mut recv_file_events: mpsc::Receiver<EventResult>,
-    exit_signal: tokio::sync::oneshot::Receiver<()>,
+    exit_signal: &mut tokio::sync::oneshot::Receiver<()>,
     broadcast_sender: broadcast::Sender<Result<Event, NotifyError>>,
-) {
-    let mut exit_signal = exit_signal;
-    'outer: loop {
+) -> WatchEnd {
+    loop {
         tokio::select! {
-            _ = &mut exit_signal => break 'outer,
+            _ = &mut *exit_signal => return WatchEnd::Exit,
             Some(event) = recv_file_events.recv().into_future() => {
                 match event {
                     Ok(mut event) => {
@@ -266,13 +562,21 @@ async fn watch_events(
                         #[cfg(feature = "watch_ancestors")]
                         filter_relevant(&watch_root, &mut event);
 
+                        // Other backends watch new directories on their own
                         #[cfg(feature = "manual_recursive_watch")]
-                        {
+                        if let Some(watcher) = watcher.downcast_mut::<Backend>() {
                             if event.kind == EventKind::Create(CreateKind::Folder) {
                                 for new_path in &event.paths {
-                                    if let Err(err) = manually_add_recursive_watches(new_path, &mut watcher, Some(&broadcast_sender)) {
-                                        warn!("encountered error watching filesystem {}", err);
-                                        break 'outer;
+                                    match manually_add_recursive_watches(new_path, watcher, Some(&broadcast_sender)) {
+                                        Ok(()) => {}
+                                        Err(WatchError::Notify(err)) if is_out_of_watches(&err) => {
+                                            let _ = broadcast_sender.send(Err(NotifyError::from(err)));
+                                            return WatchEnd::OutOfWatches;
+                                        }
+                                        Err(err) => {
+                                            warn!("encountered error watching filesystem {}", err);
+                                            return WatchEnd::Exit;
+                                        }
                                     }
                                 }
                             }
@@ -281,8 +585,12 @@ async fn watch_events(
                         let _ = broadcast_sender.send(Ok(event));
                     },
                     Err(error) => {
+                        let out_of_watches = is_out_of_watches(&error);
                         // we don't care if we fail to send, it just means no one is currently watching
                         let _ = broadcast_sender.send(Err(NotifyError::from(error)));
+                        if out_of_watches {
+                            return WatchEnd::OutOfWatches;
+                        }
                     }
                 }
             }
@@ -439,6 +747,7 @@ fn make_watcher<F: EventHandler>(event_handler: F) -> Result<Backend, notify::Er
 async fn wait_for_cookie(
     cookie_dir: &AbsoluteSystemPath,
     recv: &mut mpsc::Receiver<EventResult>,
+    timeout: Duration,
 ) -> Result<(), WatchError> {
     // TODO: should this be passed in? Currently the caller guarantees that the
     // directory is empty, but it could be the responsibility of the
@@ -448,7 +757,7 @@ async fn wait_for_cookie(
         WatchError::Setup(format!("failed to write cookie to {}: {}", cookie_path, e))
     })?;
     loop {
-        let event = tokio::time::timeout(Duration::from_millis(2000), recv.recv())
+        let event = tokio::time::timeout(timeout, recv.recv())
             .await
             .map_err(|e| WatchError::Setup(format!("waiting for cookie timed out: {}", e)))?
             .ok_or_else(|| {
@@ -475,15 +784,19 @@ async fn wait_for_cookie(
 
 #[cfg(test)]
 mod test {
-    use std::{assert_matches::assert_matches, sync::atomic::AtomicUsize, time::Duration};
+    use std::{
+        any::Any, assert_matches::assert_matches, sync::atomic::AtomicUsize, time::Duration,
+    };
 
     #[cfg(not(target_os = "windows"))]
     use notify::event::RenameMode;
-    use notify::{event::ModifyKind, Event, EventKind};
-    use tokio::sync::broadcast;
+    use notify::{event::ModifyKind, ErrorKind, Event, EventKind};
+    use tokio::sync::{broadcast, mpsc};
     use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
 
-    use crate::{FileSystemWatcher, NotifyError};
+    use crate::{
+        EventResult, FileSystemWatcher, NotifyError, PollingBackend, WatchBackend, WatchError,
+    };
 
     fn temp_dir() -> (AbsoluteSystemPathBuf, tempfile::TempDir) {
         let tmp = tempfile::tempdir().unwrap();
@@ -585,6 +898,129 @@ mod test {
         // TODO: implement default filtering (.git, node_modules)
     }
 
+    #[tokio::test]
+    async fn test_polling_file_watching() {
+        let (repo_root, _tmp_repo_root) = temp_dir();
+        let repo_root = repo_root.to_realpath().unwrap();
+        let child_path = repo_root.join_components(&["parent", "child"]);
+        child_path.create_dir_all().unwrap();
+
+        let watcher = FileSystemWatcher::new_with_backend(
+            &repo_root,
+            repo_root.join_components(&[".turbo", "cookies"]),
+            Box::new(PollingBackend::new(Duration::from_millis(50))),
+            None,
+        )
+        .unwrap();
+        assert_eq!(watcher.latency(), Duration::from_millis(50));
+        let mut recv = watcher.subscribe().await.unwrap();
+
+        let foo_path = child_path.join_component("foo");
+        foo_path.create_with_contents("hello").unwrap();
+        expect_filesystem_event!(recv, foo_path, EventKind::Create(_));
+
+        foo_path.remove().unwrap();
+        expect_filesystem_event!(recv, foo_path, EventKind::Remove(_));
+    }
+
+    #[tokio::test]
+    async fn test_polling_ignored_dirs() {
+        let (repo_root, _tmp_repo_root) = temp_dir();
+        let repo_root = repo_root.to_realpath().unwrap();
+        let dep_path = repo_root.join_components(&["node_modules", "some-dep"]);
+        dep_path.create_dir_all().unwrap();
+        let child_path = repo_root.join_components(&["parent", "child"]);
+        child_path.create_dir_all().unwrap();
+
+        let watcher = FileSystemWatcher::new_with_backend(
+            &repo_root,
+            repo_root.join_components(&[".turbo", "cookies"]),
+            Box::new(PollingBackend::new(Duration::from_millis(50))),
+            None,
+        )
+        .unwrap();
+        let mut recv = watcher.subscribe().await.unwrap();
+
+        // Files in new directories are reported, even if they were written
+        // before the directory was polled
+        let deep_path = child_path.join_components(&["deep", "path"]);
+        deep_path.create_dir_all().unwrap();
+        let foo_path = deep_path.join_component("foo");
+        foo_path.create_with_contents("hello").unwrap();
+        expect_filesystem_event!(recv, foo_path, EventKind::Create(_));
+
+        let dep_file = dep_path.join_component("index.js");
+        dep_file.create_with_contents("hello").unwrap();
+        let bar_path = child_path.join_component("bar");
+        bar_path.create_with_contents("hello").unwrap();
+        'outer: loop {
+            let event = tokio::time::timeout(Duration::from_millis(3000), recv.recv())
+                .await
+                .expect("timed out waiting for filesystem event")
+                .expect("sender was dropped")
+                .expect("filewatching error");
+            for path in event.paths {
+                assert_ne!(path, dep_file.as_std_path(), "node_modules was polled");
+                if path == bar_path.as_std_path() {
+                    break 'outer;
+                }
+            }
+        }
+    }
+
+    // Polls the root, then reports that it ran out of watches
+    struct OutOfWatchesBackend;
+
+    impl WatchBackend for OutOfWatchesBackend {
+        fn name(&self) -> &'static str {
+            "out of watches"
+        }
+
+        fn latency(&self) -> Duration {
+            Duration::from_millis(50)
+        }
+
+        fn watch(
+            self: Box<Self>,
+            root: &AbsoluteSystemPath,
+            sender: mpsc::Sender<EventResult>,
+        ) -> Result<Box<dyn Any + Send>, WatchError> {
+            let watcher = Box::new(PollingBackend::new(Duration::from_millis(50)))
+                .watch(root, sender.clone())?;
+            std::thread::spawn(move || {
+                std::thread::sleep(Duration::from_millis(500));
+                let _ = sender.blocking_send(Err(notify::Error::new(ErrorKind::MaxFilesWatch)));
+            });
+            Ok(watcher)
+        }
+    }
+
+    #[tokio::test]
+    async fn test_fallback_when_out_of_watches() {
+        let (repo_root, _tmp_repo_root) = temp_dir();
+        let repo_root = repo_root.to_realpath().unwrap();
+
+        let watcher = FileSystemWatcher::new_with_backend(
+            &repo_root,
+            repo_root.join_components(&[".turbo", "cookies"]),
+            Box::new(OutOfWatchesBackend),
+            Some(Box::new(PollingBackend::new(Duration::from_millis(50)))),
+        )
+        .unwrap();
+        let mut recv = watcher.subscribe().await.unwrap();
+
+        // Subscribers are told about the error, then get events from polling
+        tokio::time::timeout(Duration::from_millis(3000), async {
+            while recv.recv().await.expect("sender was dropped").is_ok() {}
+        })
+        .await
+        .expect("timed out waiting for the error");
+
+        let foo_path = repo_root.join_component("foo");
+        foo_path.create_with_contents("hello").unwrap();
+        expect_filesystem_event!(recv, foo_path, EventKind::Create(_));
+    }
+
     #[tokio::test]
     async fn test_file_watching_subfolder_deletion() {
         // Directory layout:
@@ -0,0 +1,287 @@
+//! A file watching backend that subscribes to changes from
+//! [Watchman](https://facebook.github.io/watchman/). Watchman keeps watching
+//! a repository between daemon restarts and handles repositories that are too
+//! large for the native watcher.
+
+use std::{
+    any::Any,
+    fmt::Display,
+    io::{BufRead, BufReader, Write},
+    process::{Child, Command, Stdio},
+};
+
+use notify::{
+    event::{CreateKind, Flag, ModifyKind, RemoveKind},
+    Event, EventKind,
+};
+use serde::Deserialize;
+use serde_json::json;
+use tokio::sync::mpsc;
+use tracing::debug;
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, RelativeUnixPath};
+
+use crate::{EventResult, WatchBackend, WatchError};
+
+const SUBSCRIPTION_NAME: &str = "turbo";
+
+#[derive(Debug, Default, Clone, Copy)]
+pub struct WatchmanBackend;
+
+#[derive(Debug, Deserialize)]
+struct WatchProjectResponse {
+    watch: Option<String>,
+    relative_path: Option<String>,
+    error: Option<String>,
+}
+
+#[derive(Debug, Deserialize)]
+struct SubscriptionResponse {
+    #[serde(default)]
+    files: Vec<WatchmanFile>,
+    #[serde(default)]
+    is_fresh_instance: bool,
+    #[serde(default)]
+    canceled: bool,
+    error: Option<String>,
+}
+
+#[derive(Debug, Deserialize)]
+struct WatchmanFile {
+    name: String,
+    exists: bool,
+    new: bool,
+    #[serde(rename = "type")]
+    file_type: Option<String>,
+}
+
+// Stops the watchman client when file watching stops
+struct Subscription(Child);
+
+impl Drop for Subscription {
+    fn drop(&mut self) {
+        let _ = self.0.kill();
+        let _ = self.0.wait();
+    }
+}
+
+impl WatchBackend for WatchmanBackend {
+    fn name(&self) -> &'static str {
+        "watchman"
+    }
+
+    fn watch(
+        self: Box<Self>,
+        root: &AbsoluteSystemPath,
+        sender: mpsc::Sender<EventResult>,
+    ) -> Result<Box<dyn Any + Send>, WatchError> {
+        let bin = which::which("watchman").map_err(setup_error)?;
+
+        // Watchman may already be watching a parent of the root, such as the
+        // git repository, so changes are requested relative to our root
+        let output = Command::new(&bin)
+            .args(["--no-pretty", "watch-project"])
+            .arg(root.as_std_path())
+            .output()
+            .map_err(setup_error)?;
+        let response: WatchProjectResponse =
+            serde_json::from_slice(&output.stdout).map_err(setup_error)?;
+        if let Some(error) = response.error {
+            return Err(setup_error(error));
+        }
+        let watch = response
+            .watch
+            .ok_or_else(|| setup_error("no watch root in watch-project response"))?;
+        let mut options = json!({
+            "fields": ["name", "exists", "new", "type"],
+            "empty_on_fresh_instance": true,
+        });
+        if let Some(relative_path) = response.relative_path {
+            options["relative_root"] = relative_path.into();
+        }
+        let command = json!(["subscribe", watch, SUBSCRIPTION_NAME, options]);
+
+        let mut child = Command::new(&bin)
+            .args(["--no-pretty", "--json-command", "--persistent"])
+            .stdin(Stdio::piped())
+            .stdout(Stdio::piped())
+            .stderr(Stdio::null())
+            .spawn()
+            .map_err(setup_error)?;
+        // The command is read until stdin is closed
+        let mut stdin = child.stdin.take().expect("stdin is piped");
+        let stdout = child.stdout.take().expect("stdout is piped");
+        let subscription = Subscription(child);
+        stdin
+            .write_all(command.to_string().as_bytes())
+            .map_err(setup_error)?;
+        drop(stdin);
+
+        let mut lines = BufReader::new(stdout).lines();
+        // The first response confirms the subscription
+        let response: SubscriptionResponse = lines
+            .next()
+            .ok_or_else(|| setup_error("exited before subscribing"))?
+            .map_err(setup_error)
+            .and_then(|line| serde_json::from_str(&line).map_err(setup_error))?;
+        if let Some(error) = response.error {
+            return Err(setup_error(error));
+        }
+
+        let root = root.to_owned();
+        std::thread::spawn(move || {
+            // The first notification lists the existing files, which are
+            // omitted with `empty_on_fresh_instance`
+            let mut initial = true;
+            for line in lines {
+                let Ok(line) = line else {
+                    break;
+                };
+                let events = match serde_json::from_str::<SubscriptionResponse>(&line) {
+                    Ok(response) => to_events(&root, response, &mut initial),
+                    Err(e) => vec![Err(notify::Error::generic(&e.to_string()))],
+                };
+                for event in events {
+                    if sender.blocking_send(event).is_err() {
+                        // file watching has stopped
+                        return;
+                    }
+                }
+            }
+            debug!("watchman subscription closed");
+        });
+
+        Ok(Box::new(subscription))
+    }
+}
+
+fn to_events(
+    root: &AbsoluteSystemPath,
+    response: SubscriptionResponse,
+    initial: &mut bool,
+) -> Vec<EventResult> {
+    if let Some(error) = response.error {
+        return vec![Err(notify::Error::generic(&error))];
+    }
+    if response.canceled {
+        return vec![Err(notify::Error::generic(
+            "watchman subscription was canceled",
+        ))];
+    }
+    if response.is_fresh_instance {
+        // Watchman recrawled the repository and may have missed changes
+        let rescan = !*initial;
+        *initial = false;
+        return if rescan {
+            vec![Ok(Event::new(EventKind::Other)
+                .set_flag(Flag::Rescan)
+                .add_path(root.as_std_path().to_owned()))]
+        } else {
+            Vec::new()
+        };
+    }
+
+    response
+        .files
+        .into_iter()
+        .map(|file| {
+            let path = file_path(root, &file.name)?;
+            Ok(Event::new(event_kind(&file)).add_path(path.into()))
+        })
+        .collect()
+}
+
+fn file_path(
+    root: &AbsoluteSystemPath,
+    name: &str,
+) -> Result<AbsoluteSystemPathBuf, notify::Error> {
+    let path = RelativeUnixPath::new(name).map_err(|e| notify::Error::generic(&e.to_string()))?;
+    Ok(root.join_unix_path(path))
+}
+
+fn event_kind(file: &WatchmanFile) -> EventKind {
+    let is_dir = file.file_type.as_deref() == Some("d");
+    match (file.exists, file.new, is_dir) {
+        (false, _, true) => EventKind::Remove(RemoveKind::Folder),
+        (false, _, false) => EventKind::Remove(RemoveKind::File),
+        (true, true, true) => EventKind::Create(CreateKind::Folder),
+        (true, true, false) => EventKind::Create(CreateKind::File),
+        (true, false, _) => EventKind::Modify(ModifyKind::Any),
+    }
+}
+
+fn setup_error(error: impl Display) -> WatchError {
+    WatchError::Setup(format!("watchman: {error}"))
+}
+
+#[cfg(test)]
+mod test {
+    use notify::{
+        event::{CreateKind, ModifyKind, RemoveKind},
+        EventKind,
+    };
+    use turbopath::AbsoluteSystemPathBuf;
+
+    use super::{to_events, SubscriptionResponse};
+
+    #[test]
+    fn test_to_events() {
+        let root =
+            AbsoluteSystemPathBuf::new(if cfg!(windows) { "C:\\repo" } else { "/repo" }).unwrap();
+        let mut initial = true;
+
+        let fresh: SubscriptionResponse =
+            serde_json::from_str(r#"{"subscription": "turbo", "is_fresh_instance": true}"#)
+                .unwrap();
+        assert!(to_events(&root, fresh, &mut initial).is_empty());
+        assert!(!initial);
+
+        let response: SubscriptionResponse = serde_json::from_str(
+            r#"{
+                "subscription": "turbo",
+                "is_fresh_instance": false,
+                "files": [
+                    {"name": "apps/web/new.ts", "exists": true, "new": true, "type": "f"},
+                    {"name": "apps/web/src", "exists": true, "new": true, "type": "d"},
+                    {"name": "package.json", "exists": true, "new": false, "type": "f"},
+                    {"name": "old.ts", "exists": false, "new": false}
+                ]
+            }"#,
+        )
+        .unwrap();
+        let events = to_events(&root, response, &mut initial)
+            .into_iter()
+            .map(|event| {
+                let event = event.unwrap();
+                (event.kind, event.paths)
+            })
+            .collect::<Vec<_>>();
+
+        assert_eq!(
+            events,
+            vec![
+                (
+                    EventKind::Create(CreateKind::File),
+                    vec![root.join_components(&["apps", "web", "new.ts"]).into()]
+                ),
+                (
+                    EventKind::Create(CreateKind::Folder),
+                    vec![root.join_components(&["apps", "web", "src"]).into()]
+                ),
+                (
+                    EventKind::Modify(ModifyKind::Any),
+                    vec![root.join_component("package.json").into()]
+                ),
+                (
+                    EventKind::Remove(RemoveKind::File),
+                    vec![root.join_component("old.ts").into()]
+                ),
+            ]
+        );
+
+        let fresh: SubscriptionResponse =
+            serde_json::from_str(r#"{"subscription": "turbo", "is_fresh_instance": true}"#)
+                .unwrap();
+        let rescan = to_events(&root, fresh, &mut initial);
+        assert!(rescan[0].as_ref().unwrap().need_rescan());
+    }
+}
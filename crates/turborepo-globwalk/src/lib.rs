@@ -17,7 +17,10 @@ use rayon::prelude::*;
 use regex::Regex;
 use tracing::debug;
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, PathError, RelativeUnixPath};
-use wax::{walk::FileIterator, BuildError, Glob};
+use wax::{
+    walk::{FileIterator, LinkBehavior},
+    BuildError, Glob,
+};
 
 #[derive(Debug, PartialEq, Clone, Copy)]
 pub enum WalkType {
@@ -66,6 +69,7 @@ fn preprocess_paths_and_globs(
     base_path: &AbsoluteSystemPath,
     include: &[String],
     exclude: &[String],
+    link: LinkBehavior,
 ) -> Result<(PathBuf, Vec<String>, Vec<String>), WalkError> {
     debug!("processing includes: {include:?}");
     debug!("processing excludes: {exclude:?}");
@@ -85,7 +89,7 @@ fn preprocess_paths_and_globs(
         .map(|mut s| {
             // We need to check inclusion globs before the join
             // as to_slash doesn't preserve Windows drive names.
-            add_doublestar_to_dir(base_path, &mut s);
+            add_doublestar_to_dir(base_path, &mut s, link);
             s
         })
         .map(|s| join_unix_like_paths(&base_path_slash, &s))
@@ -231,7 +235,7 @@ fn add_trailing_double_star(exclude_paths: &mut Vec<String>, glob: &str) {
     }
 }
 
-fn add_doublestar_to_dir(base: &AbsoluteSystemPath, glob: &mut String) {
+fn add_doublestar_to_dir(base: &AbsoluteSystemPath, glob: &mut String, link: LinkBehavior) {
     // If the glob has a glob literal in it e.g. *
     // then skip trying to read it as a file path.
     if glob_literals().is_match(&*glob) {
@@ -247,7 +251,12 @@ fn add_doublestar_to_dir(base: &AbsoluteSystemPath, glob: &mut String) {
 
     let path = base.join_unix_path(glob_path);
 
-    let Ok(metadata) = path.symlink_metadata() else {
+    // A symlinked directory is only a directory to us if we're going to follow it
+    let metadata = match link {
+        LinkBehavior::ReadFile => path.symlink_metadata(),
+        LinkBehavior::ReadTarget => path.metadata(),
+    };
+    let Ok(metadata) = metadata else {
         debug!("'{path}' doesn't have metadata");
         return;
     };
@@ -289,12 +298,20 @@ pub struct GlobError {
 #[derive(Clone, Debug)]
 pub struct ValidatedGlob {
     inner: String,
+    follow_links: bool,
 }
 
 impl ValidatedGlob {
     pub fn as_str(&self) -> &str {
         self.inner.as_str()
     }
+
+    /// Descend into symlinked directories when walking this glob instead of
+    /// yielding the link itself. Links that form a cycle are not followed.
+    pub fn follow_links(mut self) -> Self {
+        self.follow_links = true;
+        self
+    }
 }
 
 impl FromStr for ValidatedGlob {
@@ -334,6 +351,7 @@ impl FromStr for ValidatedGlob {
 
         Ok(Self {
             inner: cross_platform,
+            follow_links: false,
         })
     }
 }
@@ -344,9 +362,22 @@ pub fn globwalk(
     exclude: &[ValidatedGlob],
     walk_type: WalkType,
 ) -> Result<HashSet<AbsoluteSystemPathBuf>, WalkError> {
-    let include = include.iter().map(|i| i.inner.clone()).collect::<Vec<_>>();
     let exclude = exclude.iter().map(|e| e.inner.clone()).collect::<Vec<_>>();
-    globwalk_internal(base_path, &include, &exclude, walk_type)
+    // Globs that follow links are walked separately as the link behavior
+    // applies to the entire traversal.
+    let (followed, unfollowed): (Vec<_>, Vec<_>) = include.iter().partition(|i| i.follow_links);
+    let mut paths = HashSet::new();
+    for (link, include) in [
+        (LinkBehavior::ReadFile, unfollowed),
+        (LinkBehavior::ReadTarget, followed),
+    ] {
+        if include.is_empty() {
+            continue;
+        }
+        let include = include.iter().map(|i| i.inner.clone()).collect::<Vec<_>>();
+        paths.extend(walk_globs(base_path, &include, &exclude, walk_type, link)?);
+    }
+    Ok(paths)
 }
 
 #[tracing::instrument]
@@ -356,8 +387,27 @@ pub fn globwalk_internal(
     exclude: &[String],
     walk_type: WalkType,
 ) -> Result<HashSet<AbsoluteSystemPathBuf>, WalkError> {
+    walk_globs(
+        base_path,
+        include,
+        exclude,
+        walk_type,
+        LinkBehavior::ReadFile,
+    )
+}
+
+#[tracing::instrument]
+fn walk_globs(
+    base_path: &AbsoluteSystemPath,
+    include: &[String],
+    exclude: &[String],
+    walk_type: WalkType,
+    link: LinkBehavior,
+) -> Result<HashSet<AbsoluteSystemPathBuf>, WalkError> {
+    // Verbatim prefixes can't be expressed as part of a glob
+    let base_path = base_path.to_simplified();
     let (base_path_new, include_paths, exclude_paths) =
-        preprocess_paths_and_globs(base_path, include, exclude)?;
+        preprocess_paths_and_globs(&base_path, include, exclude, link)?;
 
     let ex_patterns: Vec<_> = exclude_paths
         .into_iter()
@@ -374,18 +424,19 @@ pub fn globwalk_internal(
         // Use flat_map_iter as we only want parallelism for walking the globs and not iterating
         // over the results.
         // See https://docs.rs/rayon/latest/rayon/iter/trait.ParallelIterator.html#method.flat_map_iter
-        .flat_map_iter(|glob| walk_glob(walk_type, &base_path_new, ex_patterns.clone(), glob))
+        .flat_map_iter(|glob| walk_glob(walk_type, link, &base_path_new, ex_patterns.clone(), glob))
         .collect()
 }
 
 #[tracing::instrument(skip(ex_patterns), fields(glob=glob.to_string().as_str()))]
 fn walk_glob(
     walk_type: WalkType,
+    link: LinkBehavior,
     base_path_new: &Path,
     ex_patterns: Vec<Glob>,
     glob: Glob,
 ) -> Vec<Result<AbsoluteSystemPathBuf, WalkError>> {
-    glob.walk(base_path_new)
+    glob.walk_with_behavior(base_path_new, link)
         .not(ex_patterns)
         .unwrap_or_else(|e| {
             // Per docs, only fails if exclusion list is too large, since we're using
@@ -404,6 +455,18 @@ fn visit_file(
     match entry {
         Ok(entry) if walk_type == WalkType::Files && entry.file_type().is_dir() => None,
         Ok(entry) => Some(AbsoluteSystemPathBuf::try_from(entry.path()).map_err(|e| e.into())),
+        // Symlink cycles can only occur when following links, we stop descending
+        // at the link rather than failing the entire walk
+        Err(e) if e.is_link_cycle() => {
+            debug!("not following symlink cycle: {e}");
+            None
+        }
+        // When following links a broken symlink surfaces as an error, yield the link
+        // itself to match the behavior when links aren't followed
+        Err(e) if e.path().is_some_and(is_broken_symlink) => {
+            let path = e.path().expect("checked above");
+            Some(AbsoluteSystemPathBuf::try_from(path).map_err(|e| e.into()))
+        }
         Err(e) => {
             let io_err = std::io::Error::from(e);
             match io_err.kind() {
@@ -415,6 +478,12 @@ fn visit_file(
     }
 }
 
+fn is_broken_symlink(path: &Path) -> bool {
+    path.symlink_metadata()
+        .is_ok_and(|metadata| metadata.is_symlink())
+        && !path.exists()
+}
+
 #[cfg(test)]
 mod test {
     use std::{collections::HashSet, str::FromStr};
@@ -423,6 +492,7 @@ mod test {
     use tempdir::TempDir;
     use test_case::test_case;
     use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
+    use wax::walk::LinkBehavior;
 
     use crate::{
         add_doublestar_to_dir, collapse_path, escape_glob_literals, fix_glob_pattern, globwalk,
@@ -1480,6 +1550,111 @@ mod test {
         assert_eq!(paths, expected);
     }
 
+    #[cfg(unix)]
+    #[test]
+    fn test_long_paths() {
+        // Deeper than MAX_PATH on Windows
+        let segment = "a".repeat(50);
+        let nested = [segment.as_str(); 6].join("/");
+        let file = format!("{nested}/file.txt");
+        let tmp = setup_files(&[&file]);
+        let root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        assert!(root.as_str().len() + file.len() > 260);
+
+        let include = [ValidatedGlob::from_str("**/*.txt").unwrap()];
+        let paths = globwalk(&root, &include, &[], WalkType::Files).unwrap();
+        let paths = paths
+            .into_iter()
+            .map(|path| root.anchor(path).unwrap().to_string())
+            .collect::<Vec<_>>();
+        assert_eq!(
+            paths,
+            vec![file.replace('/', std::path::MAIN_SEPARATOR_STR)]
+        );
+    }
+
+    #[test]
+    #[cfg(windows)]
+    fn test_verbatim_base_path() {
+        let tmp = setup_files(&["apps/web/package.json"]);
+        let root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        let verbatim = format!(r"\\?\{root}");
+        let verbatim_root = AbsoluteSystemPath::new(&verbatim).unwrap();
+
+        let include = [ValidatedGlob::from_str("apps/*/package.json").unwrap()];
+        let paths = globwalk(verbatim_root, &include, &[], WalkType::Files).unwrap();
+        let paths = paths
+            .into_iter()
+            .map(|path| root.anchor(path).unwrap().to_string())
+            .collect::<Vec<_>>();
+        assert_eq!(paths, vec![r"apps\web\package.json".to_string()]);
+    }
+
+    #[cfg(unix)]
+    fn setup_symlinked_outputs() -> (tempdir::TempDir, AbsoluteSystemPathBuf) {
+        let tmp = setup_files(&["shared/index.js", "shared/index.js.map", "dist/main.js"]);
+        let root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        std::os::unix::fs::symlink("../shared", tmp.path().join("dist/shared")).unwrap();
+        std::os::unix::fs::symlink("..", tmp.path().join("shared/parent")).unwrap();
+        (tmp, root)
+    }
+
+    #[cfg(unix)]
+    fn walk_relative(
+        root: &AbsoluteSystemPath,
+        include: &[ValidatedGlob],
+        exclude: &[&str],
+    ) -> HashSet<String> {
+        let exclude = exclude
+            .iter()
+            .map(|e| ValidatedGlob::from_str(e))
+            .collect::<Result<Vec<_>, _>>()
+            .unwrap();
+        globwalk(root, include, &exclude, WalkType::Files)
+            .unwrap()
+            .into_iter()
+            .map(|path| root.anchor(path).unwrap().to_string())
+            .collect()
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_symlinked_dir_not_followed_by_default() {
+        let (_tmp, root) = setup_symlinked_outputs();
+        let include = [ValidatedGlob::from_str("dist/**").unwrap()];
+        let paths = walk_relative(&root, &include, &["dist/**/*.map"]);
+        let expected = HashSet::from_iter(["dist/main.js".to_string(), "dist/shared".to_string()]);
+        assert_eq!(paths, expected);
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_symlinked_dir_followed_with_exclusions() {
+        let (_tmp, root) = setup_symlinked_outputs();
+        let include = [ValidatedGlob::from_str("dist/**").unwrap().follow_links()];
+        // dist/shared/parent points back at the root, which would otherwise
+        // be walked forever
+        let paths = walk_relative(&root, &include, &["dist/**/*.map", "dist/shared/parent/**"]);
+        let expected = HashSet::from_iter([
+            "dist/main.js".to_string(),
+            "dist/shared/index.js".to_string(),
+        ]);
+        assert_eq!(paths, expected);
+    }
+
+    #[test]
+    #[cfg(unix)]
+    fn test_symlink_cycle_is_not_followed() {
+        let tmp = setup_files(&["dist/main.js"]);
+        let root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        std::os::unix::fs::symlink(".", tmp.path().join("dist/loop")).unwrap();
+        std::os::unix::fs::symlink("missing", tmp.path().join("dist/broken")).unwrap();
+        let include = [ValidatedGlob::from_str("dist/**").unwrap().follow_links()];
+        let paths = walk_relative(&root, &include, &[]);
+        let expected = HashSet::from_iter(["dist/main.js".to_string(), "dist/broken".to_string()]);
+        assert_eq!(paths, expected);
+    }
+
     #[test]
     fn test_escape_glob_literals() {
         assert_eq!(
@@ -1505,7 +1680,7 @@ mod test {
 
         let mut glob = glob.to_owned();
 
-        add_doublestar_to_dir(base, &mut glob);
+        add_doublestar_to_dir(base, &mut glob, LinkBehavior::ReadFile);
 
         assert_eq!(glob, expected);
     }
@@ -1,15 +1,17 @@
 use std::backtrace;
 
 use miette::Diagnostic;
+use serde::Serialize;
 use thiserror::Error;
 use turborepo_repository::package_graph;
 
 use crate::{
-    commands::{bin, generate, prune},
+    commands::{bin, cache, diff, exec_plan, generate, lint_config, ls, prune, remote_cache},
     daemon::DaemonError,
     rewrite_json::RewriteError,
     run,
     run::watch,
+    shim,
 };
 
 #[derive(Debug, Error, Diagnostic)]
@@ -34,13 +36,31 @@ pub enum Error {
     #[error(transparent)]
     Auth(#[from] turborepo_auth::Error),
     #[error(transparent)]
+    #[diagnostic(transparent)]
+    Cache(#[from] cache::Error),
+    #[error(transparent)]
     Daemon(#[from] DaemonError),
     #[error(transparent)]
+    #[diagnostic(transparent)]
+    Diff(#[from] diff::Error),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    ExecPlan(#[from] exec_plan::Error),
+    #[error(transparent)]
     Generate(#[from] generate::Error),
     #[error(transparent)]
     #[diagnostic(transparent)]
+    LintConfig(#[from] lint_config::Error),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Ls(#[from] ls::Error),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
     Prune(#[from] prune::Error),
     #[error(transparent)]
+    #[diagnostic(transparent)]
+    RemoteCache(#[from] remote_cache::Error),
+    #[error(transparent)]
     PackageJson(#[from] turborepo_repository::package_json::Error),
     #[error(transparent)]
     PackageManager(#[from] turborepo_repository::package_manager::Error),
@@ -53,3 +73,239 @@ pub enum Error {
     #[diagnostic(transparent)]
     Watch(#[from] watch::Error),
 }
+
+// Reported for errors that don't have a code of their own
+const UNKNOWN_ERROR_CODE: &str = "unknown";
+
+// Codes of errors in the arguments or the configuration, which turbo exits
+// with `USAGE_EXIT_CODE` for when errors are printed as JSON
+const USAGE_ERROR_CODES: &[&str] = &[
+    "invalid_arguments",
+    "turbo_json_parse_error",
+    "invalid_task_configuration",
+    "missing_tasks",
+    "missing_task",
+    "missing_package",
+    "cyclic_task_dependency",
+];
+const USAGE_EXIT_CODE: i32 = 2;
+const ERROR_EXIT_CODE: i32 = 1;
+
+/// An error in the format printed by `--error-format=json`
+#[derive(Debug, PartialEq, Serialize)]
+pub struct JsonError {
+    code: String,
+    #[serde(rename = "exitCode", skip_serializing_if = "Option::is_none")]
+    exit_code: Option<i32>,
+    message: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    help: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    location: Option<Location>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    related: Vec<JsonError>,
+}
+
+/// Where in a file, usually a `turbo.json`, the error is. Lines and columns
+/// start at 1.
+#[derive(Debug, PartialEq, Serialize)]
+struct Location {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    file: Option<String>,
+    line: usize,
+    column: usize,
+}
+
+#[derive(Debug, Serialize)]
+struct JsonErrorOutput<'a> {
+    error: &'a JsonError,
+}
+
+impl JsonError {
+    /// Uses the codes that turbo's errors have for JSON output, falling back
+    /// to their diagnostic codes
+    pub fn from_error(error: &shim::Error) -> Self {
+        let json = match error {
+            shim::Error::Cli(Error::Run(error)) => {
+                Self::with_code(error, error.json_code(), error.json_related())
+            }
+            error => Self::new(error),
+        };
+        json.with_exit_code()
+    }
+
+    pub fn new(diagnostic: &dyn Diagnostic) -> Self {
+        Self {
+            code: diagnostic
+                .code()
+                .map_or_else(|| UNKNOWN_ERROR_CODE.to_string(), |code| code.to_string()),
+            exit_code: None,
+            message: diagnostic.to_string(),
+            help: diagnostic.help().map(|help| help.to_string()),
+            location: Location::new(diagnostic),
+            related: diagnostic
+                .related()
+                .into_iter()
+                .flatten()
+                .map(JsonError::new)
+                .collect(),
+        }
+    }
+
+    pub fn from_message(code: &str, message: &str) -> Self {
+        Self {
+            code: code.to_string(),
+            exit_code: None,
+            message: message.to_string(),
+            help: None,
+            location: None,
+            related: Vec::new(),
+        }
+        .with_exit_code()
+    }
+
+    fn with_code(
+        diagnostic: &dyn Diagnostic,
+        code: Option<&str>,
+        related: Vec<(&dyn Diagnostic, Option<&'static str>)>,
+    ) -> Self {
+        let mut json = Self::new(diagnostic);
+        if let Some(code) = code {
+            json.code = code.to_string();
+        }
+        if !related.is_empty() {
+            json.related = related
+                .into_iter()
+                .map(|(diagnostic, code)| Self::with_code(diagnostic, code, Vec::new()))
+                .collect();
+        }
+        json
+    }
+
+    // Only the top level error has an exit code
+    fn with_exit_code(mut self) -> Self {
+        self.exit_code = Some(if USAGE_ERROR_CODES.contains(&self.code.as_str()) {
+            USAGE_EXIT_CODE
+        } else {
+            ERROR_EXIT_CODE
+        });
+        self
+    }
+
+    /// The code turbo exits with after printing the error
+    pub fn exit_code(&self) -> i32 {
+        self.exit_code.unwrap_or(ERROR_EXIT_CODE)
+    }
+}
+
+impl Location {
+    fn new(diagnostic: &dyn Diagnostic) -> Option<Self> {
+        let source_code = diagnostic.source_code()?;
+        let label = diagnostic.labels()?.next()?;
+        let contents = source_code.read_span(label.inner(), 0, 0).ok()?;
+        Some(Self {
+            file: contents.name().map(|name| name.to_string()),
+            line: contents.line() + 1,
+            column: contents.column() + 1,
+        })
+    }
+}
+
+/// Prints the error as a JSON object on stderr so that tools wrapping turbo
+/// don't have to parse the human readable output
+pub fn print_json_error(error: &JsonError) {
+    match serde_json::to_string(&JsonErrorOutput { error }) {
+        Ok(json) => eprintln!("{json}"),
+        Err(err) => eprintln!("{err}"),
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use miette::{Diagnostic, NamedSource, SourceSpan};
+    use serde_json::json;
+    use thiserror::Error;
+
+    use super::{Error, JsonError};
+    use crate::{engine::ValidateError, run, shim};
+
+    #[derive(Debug, Error, Diagnostic)]
+    #[error("invalid task configuration")]
+    #[diagnostic(code(invalid_task_configuration))]
+    struct Invalid {
+        #[related]
+        errors: Vec<Cycle>,
+    }
+
+    #[derive(Debug, Error, Diagnostic)]
+    #[error("cyclic task dependency detected: build -> test -> build")]
+    #[diagnostic(code(cyclic_task_dependency), help("remove a dependency"))]
+    struct Cycle {
+        #[label]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    }
+
+    #[test]
+    fn test_json_error() {
+        let text = "{\n  \"tasks\": {\n    \"build\": { \"dependsOn\": [\"test\"] }\n  }\n}";
+        let start = text.find("[\"test\"]").unwrap() + 1;
+        let error = Invalid {
+            errors: vec![Cycle {
+                span: Some((start, 6).into()),
+                text: NamedSource::new("turbo.json", text.to_string()),
+            }],
+        };
+
+        assert_eq!(
+            serde_json::to_value(JsonError::new(&error)).unwrap(),
+            json!({
+                "code": "invalid_task_configuration",
+                "message": "invalid task configuration",
+                "related": [{
+                    "code": "cyclic_task_dependency",
+                    "message": "cyclic task dependency detected: build -> test -> build",
+                    "help": "remove a dependency",
+                    "location": { "file": "turbo.json", "line": 3, "column": 30 }
+                }]
+            })
+        );
+    }
+
+    #[test]
+    fn test_json_error_without_code() {
+        #[derive(Debug, Error, Diagnostic)]
+        #[error("something went wrong")]
+        struct Unknown;
+
+        assert_eq!(
+            serde_json::to_value(JsonError::new(&Unknown)).unwrap(),
+            json!({ "code": "unknown", "message": "something went wrong" })
+        );
+    }
+
+    #[test]
+    fn test_json_error_codes() {
+        let error = shim::Error::Cli(Error::Run(run::Error::EngineValidation(vec![
+            ValidateError::PersistentTasksExceedConcurrency {
+                persistent_count: 2,
+                concurrency: 2,
+            },
+        ])));
+
+        assert_eq!(
+            serde_json::to_value(JsonError::from_error(&error)).unwrap(),
+            json!({
+                "code": "invalid_task_configuration",
+                "exitCode": 2,
+                "message": "invalid task configuration",
+                "related": [{
+                    "code": "persistent_tasks_exceed_concurrency",
+                    "message": "You have 2 persistent tasks but `turbo` is configured for \
+                                concurrency of 2. Set --concurrency to at least 3"
+                }]
+            })
+        );
+    }
+}
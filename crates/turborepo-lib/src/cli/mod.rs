@@ -1,17 +1,19 @@
-use std::{backtrace, backtrace::Backtrace, env, fmt, fmt::Display, io, mem, process};
+use std::{
+    backtrace, backtrace::Backtrace, env, fmt, fmt::Display, io, mem, process, time::Duration,
+};
 
 use biome_deserialize_macros::Deserializable;
 use camino::{Utf8Path, Utf8PathBuf};
 use clap::{
-    builder::NonEmptyStringValueParser, ArgAction, ArgGroup, CommandFactory, Parser, Subcommand,
-    ValueEnum,
+    builder::{NonEmptyStringValueParser, PossibleValue, TypedValueParser},
+    ArgAction, ArgGroup, CommandFactory, Parser, Subcommand, ValueEnum,
 };
 use clap_complete::{generate, Shell};
-pub use error::Error;
+pub use error::{print_json_error, Error, JsonError};
 use serde::Serialize;
 use tracing::{debug, error};
 use turbopath::AbsoluteSystemPathBuf;
-use turborepo_api_client::AnonAPIClient;
+use turborepo_api_client::{AnonAPIClient, TlsOptions};
 use turborepo_repository::inference::{RepoMode, RepoState};
 use turborepo_telemetry::{
     events::{
@@ -25,8 +27,8 @@ use turborepo_ui::UI;
 
 use crate::{
     commands::{
-        bin, daemon, generate, info, link, login, logout, prune, run, scan, telemetry, unlink,
-        CommandBase,
+        bin, cache, config, daemon, diff, exec_plan, generate, graph, info, link, lint_config,
+        login, logout, ls, prune, remote_cache, run, scan, telemetry, unlink, CommandBase,
     },
     get_version,
     run::watch::WatchClient,
@@ -57,6 +59,9 @@ pub enum OutputLogsMode {
     NewOnly,
     #[serde(rename = "errors-only")]
     ErrorsOnly,
+    // Like errors-only, but with a line for every task that succeeds
+    #[serde(rename = "summary-line")]
+    SummaryLine,
 }
 
 impl Default for OutputLogsMode {
@@ -73,6 +78,7 @@ impl Display for OutputLogsMode {
             OutputLogsMode::HashOnly => "hash-only",
             OutputLogsMode::NewOnly => "new-only",
             OutputLogsMode::ErrorsOnly => "errors-only",
+            OutputLogsMode::SummaryLine => "summary-line",
         })
     }
 }
@@ -111,6 +117,110 @@ impl LogOrder {
     }
 }
 
+/// The UI for task logs, set with `--ui` or `"ui"` in turbo.json
+#[derive(Copy, Clone, Debug, Default, PartialEq, Eq, Serialize, ValueEnum, Deserializable)]
+#[serde(rename_all = "lowercase")]
+pub enum UIMode {
+    #[default]
+    Tui,
+    Stream,
+}
+
+impl Display for UIMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            UIMode::Tui => "tui",
+            UIMode::Stream => "stream",
+        })
+    }
+}
+
+impl UIMode {
+    pub fn use_tui(&self) -> bool {
+        matches!(self, Self::Tui)
+    }
+}
+
+#[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "lowercase")]
+pub enum GraphFormat {
+    #[default]
+    Json,
+    Dot,
+    Mermaid,
+}
+
+#[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "kebab-case")]
+pub enum ContinueMode {
+    #[default]
+    Never,
+    // Continue running tasks whose dependencies all succeeded
+    DependenciesSuccessful,
+    Always,
+}
+
+#[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "kebab-case")]
+pub enum TimeBudgetMode {
+    // Fail the run before any tasks run
+    #[default]
+    Fail,
+    Warn,
+}
+
+impl Display for TimeBudgetMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            TimeBudgetMode::Fail => "fail",
+            TimeBudgetMode::Warn => "warn",
+        })
+    }
+}
+
+impl Display for ContinueMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            ContinueMode::Never => "never",
+            ContinueMode::DependenciesSuccessful => "dependencies-successful",
+            ContinueMode::Always => "always",
+        })
+    }
+}
+
+/// The tasks that ignore the cache with `--force`
+#[derive(Clone, Debug, PartialEq, Eq)]
+pub enum Force {
+    /// `--force` or `--force=true`
+    All,
+    /// `--force=false`
+    None,
+    /// `--force=web#build,lint`. Tasks without a package are forced in every
+    /// package.
+    Tasks(Vec<String>),
+}
+
+#[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "kebab-case")]
+pub enum DependencyMode {
+    #[default]
+    All,
+    // Skip dependencies on tasks in other packages, i.e. `^` dependencies
+    IgnoreTopology,
+    // Only follow the dependencies of the tasks that were asked for
+    OnlyDirect,
+}
+
+impl Display for DependencyMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            DependencyMode::All => "all",
+            DependencyMode::IgnoreTopology => "ignore-topology",
+            DependencyMode::OnlyDirect => "only-direct",
+        })
+    }
+}
+
 #[derive(Copy, Clone, Debug, PartialEq, ValueEnum)]
 pub enum DryRunMode {
     Text,
@@ -126,6 +236,37 @@ impl Display for DryRunMode {
     }
 }
 
+#[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "lowercase")]
+pub enum ErrorFormat {
+    #[default]
+    Human,
+    // A JSON object on stderr for tools that wrap turbo
+    Json,
+}
+
+impl ErrorFormat {
+    /// Reads `--error-format` from the arguments directly, since turbo can
+    /// fail before they're parsed
+    pub fn from_args(args: impl IntoIterator<Item = String>) -> Self {
+        let mut format = Self::Human;
+        let mut args = args.into_iter().take_while(|arg| arg != "--");
+        while let Some(arg) = args.next() {
+            let value = match arg.strip_prefix("--error-format") {
+                Some("") => args.next(),
+                Some(value) => value.strip_prefix('=').map(str::to_string),
+                None => None,
+            };
+            match value.as_deref() {
+                Some("json") => format = Self::Json,
+                Some(_) => format = Self::Human,
+                None => (),
+            }
+        }
+        format
+    }
+}
+
 #[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
 #[serde(rename_all = "lowercase")]
 pub enum EnvMode {
@@ -143,6 +284,27 @@ impl fmt::Display for EnvMode {
     }
 }
 
+#[derive(Copy, Clone, Debug, Default, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "lowercase")]
+pub enum DaemonWatcher {
+    // The file watching API of the operating system
+    #[default]
+    Native,
+    Watchman,
+    // Periodically scan the repository for changes
+    Polling,
+}
+
+impl fmt::Display for DaemonWatcher {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            DaemonWatcher::Native => "native",
+            DaemonWatcher::Watchman => "watchman",
+            DaemonWatcher::Polling => "polling",
+        })
+    }
+}
+
 #[derive(Parser, Clone, Default, Debug, PartialEq)]
 #[clap(author, about = "The build system that makes ship happen", long_about = None)]
 #[clap(disable_help_subcommand = true)]
@@ -168,6 +330,9 @@ pub struct Args {
     /// The directory in which to run turbo
     #[clap(long, global = true, value_parser)]
     pub cwd: Option<Utf8PathBuf>,
+    /// Print errors that make turbo exit in the given format
+    #[clap(long, global = true, value_enum, default_value_t = ErrorFormat::Human)]
+    pub error_format: ErrorFormat,
     /// Specify a file to save a pprof heap profile
     #[clap(long, global = true, value_parser)]
     pub heap: Option<String>,
@@ -181,9 +346,22 @@ pub struct Args {
     /// for authorization
     #[clap(long, global = true)]
     pub preflight: bool,
+    /// Sign artifacts uploaded to the remote cache and verify the signature
+    /// of artifacts downloaded from it. The signing key is read from
+    /// `TURBO_REMOTE_CACHE_SIGNATURE_KEY`.
+    #[clap(long, global = true)]
+    pub experimental_remote_cache_signature: bool,
     /// Set a timeout for all HTTP requests.
     #[clap(long, value_name = "TIMEOUT", global = true, value_parser)]
     pub remote_cache_timeout: Option<u64>,
+    /// Path to a PEM file of certificate authorities to trust for HTTP
+    /// requests, in addition to the system's
+    #[clap(long, global = true, value_name = "PATH")]
+    pub cacert: Option<Utf8PathBuf>,
+    /// Skip verification of TLS certificates for HTTP requests. Only use this
+    /// for debugging
+    #[clap(long, global = true)]
+    pub insecure_skip_tls_verify: bool,
     /// Set the team slug for API calls
     #[clap(long, global = true, value_parser)]
     pub team: Option<String>,
@@ -263,6 +441,45 @@ pub enum DaemonCommand {
     Logs,
 }
 
+#[derive(Subcommand, Clone, Debug, PartialEq)]
+pub enum ConfigCommand {
+    /// Print the JSON schema of turbo.json
+    Schema,
+}
+
+#[derive(Subcommand, Clone, Debug, PartialEq)]
+pub enum CacheCommand {
+    /// Lists the artifacts in the local cache, most recently used first
+    Ls {
+        /// Output the artifacts in JSON format
+        #[clap(long)]
+        json: bool,
+    },
+    /// Shows the files stored in an artifact and whether it is intact
+    Show {
+        /// The hash of the task that produced the artifact
+        hash: String,
+        /// Output the artifact in JSON format
+        #[clap(long)]
+        json: bool,
+    },
+    /// Checks artifacts against the manifest they were written with. Checks
+    /// every artifact if no hashes are given
+    Verify { hashes: Vec<String> },
+    /// Removes artifacts from the local cache
+    Rm {
+        #[clap(required = true)]
+        hashes: Vec<String>,
+    },
+}
+
+#[derive(Subcommand, Copy, Clone, Debug, PartialEq)]
+pub enum RemoteCacheCommand {
+    /// Uploads and downloads a test artifact to check that the remote cache
+    /// works
+    Verify,
+}
+
 #[derive(Subcommand, Copy, Clone, Debug, PartialEq)]
 pub enum TelemetryCommand {
     /// Enables anonymous telemetry
@@ -319,6 +536,14 @@ impl Args {
                 if let Some(Command::Run {
                     run_args: _,
                     ref mut execution_args,
+                })
+                | Some(Command::Graph {
+                    format: _,
+                    ref mut execution_args,
+                })
+                | Some(Command::ExecPlan {
+                    ref mut execution_args,
+                    ..
                 }) = args.command
                 {
                     execution_args.single_package = is_single_package;
@@ -338,6 +563,13 @@ impl Args {
             }
             Err(e) if e.use_stderr() => {
                 let err_str = e.to_string();
+                if ErrorFormat::from_args(env::args()) == ErrorFormat::Json {
+                    let message = err_str.lines().next().unwrap_or_default();
+                    let message = message.strip_prefix("error: ").unwrap_or(message);
+                    let error = JsonError::from_message("invalid_arguments", message);
+                    print_json_error(&error);
+                    process::exit(error.exit_code());
+                }
                 // A cleaner solution would be to implement our own clap::error::ErrorFormatter
                 // but that would require copying the default formatter just to remove this
                 // line: https://docs.rs/clap/latest/src/clap/error/format.rs.html#100
@@ -388,8 +620,12 @@ impl Args {
         track_usage!(tel, self.color, |val| val);
         track_usage!(tel, self.no_color, |val| val);
         track_usage!(tel, self.preflight, |val| val);
+        track_usage!(tel, self.experimental_remote_cache_signature, |val| val);
+        track_usage!(tel, self.insecure_skip_tls_verify, |val| val);
         track_usage!(tel, &self.login, Option::is_some);
+        track_usage!(tel, &self.cacert, Option::is_some);
         track_usage!(tel, &self.cwd, Option::is_some);
+        track_usage!(tel, self.error_format, |val| val == ErrorFormat::Json);
         track_usage!(tel, &self.heap, Option::is_some);
         track_usage!(tel, &self.team, Option::is_some);
         track_usage!(tel, &self.token, Option::is_some);
@@ -428,18 +664,59 @@ impl Args {
 pub enum Command {
     /// Get the path to the Turbo binary
     Bin,
+    /// Inspect and manage the artifacts in the local cache
+    Cache {
+        /// Override the filesystem cache directory.
+        #[clap(long, value_parser = path_non_empty, env = "TURBO_CACHE_DIR")]
+        cache_dir: Option<Utf8PathBuf>,
+        #[clap(subcommand)]
+        command: CacheCommand,
+    },
     /// Generate the autocompletion script for the specified shell
     Completion {
         shell: Shell,
     },
+    /// Inspect the configuration of turbo
+    Config {
+        #[clap(subcommand)]
+        command: ConfigCommand,
+    },
     /// Runs the Turborepo background daemon
     Daemon {
         /// Set the idle timeout for turbod
         #[clap(long, default_value_t = String::from("4h0m0s"))]
         idle_time: String,
+        /// How the daemon watches the repository for changes. Falls back to
+        /// polling if the watcher can't be started
+        #[clap(
+            long,
+            value_enum,
+            env = "TURBO_DAEMON_WATCHER",
+            default_value_t = DaemonWatcher::Native
+        )]
+        watcher: DaemonWatcher,
         #[clap(subcommand)]
         command: Option<DaemonCommand>,
     },
+    /// Explain why the task hashes of two run summaries differ
+    Diff {
+        /// A run summary written by `--summarize` or `--dry=json`
+        before: String,
+        /// The run summary to compare it to
+        after: String,
+        /// Output the differences in JSON format
+        #[clap(long)]
+        json: bool,
+    },
+    /// Run several sets of tasks from a JSON plan in a single process
+    ExecPlan {
+        /// The file to read the plan from, or `-` to read it from stdin
+        plan: String,
+        #[clap(flatten)]
+        run_args: Box<RunArgs>,
+        #[clap(flatten)]
+        execution_args: Box<ExecutionArgs>,
+    },
     /// Generate a new app / package
     #[clap(aliases = ["g", "gen"])]
     Generate {
@@ -466,6 +743,14 @@ pub enum Command {
         #[clap(subcommand)]
         command: Option<TelemetryCommand>,
     },
+    /// Print the task graph for the given tasks without running them
+    Graph {
+        /// The format to print the task graph in
+        #[clap(long, value_enum, default_value_t = GraphFormat::Json)]
+        format: GraphFormat,
+        #[clap(flatten)]
+        execution_args: Box<ExecutionArgs>,
+    },
     /// Turbo your monorepo by running a number of 'repo lints' to
     /// identify common issues, suggest fixes, and improve performance.
     Scan {},
@@ -486,6 +771,21 @@ pub enum Command {
         /// Specify what should be linked (default "remote cache")
         #[clap(long, value_enum, default_value_t = LinkTarget::RemoteCache)]
         target: LinkTarget,
+
+        /// Save the team as a link profile with this name, or switch to the
+        /// profile if it's already saved
+        #[clap(long, value_parser = NonEmptyStringValueParser::new())]
+        profile: Option<String>,
+
+        /// List the saved link profiles
+        #[clap(long, conflicts_with = "profile")]
+        list_profiles: bool,
+    },
+    /// Check turbo.json and package scripts for common configuration mistakes
+    LintConfig {
+        /// Output the issues in JSON format
+        #[clap(long)]
+        json: bool,
     },
     /// Login to your Vercel account
     Login {
@@ -495,6 +795,11 @@ pub enum Command {
         /// tokens for the given login url.
         #[clap(long = "force", short = 'f')]
         force: bool,
+        /// Login by entering a code on another device instead of through a
+        /// browser on this machine. Useful for remote machines and
+        /// containers.
+        #[clap(long)]
+        device: bool,
     },
     /// Logout to your Vercel account
     Logout {
@@ -502,6 +807,16 @@ pub enum Command {
         #[clap(long)]
         invalidate: bool,
     },
+    /// List the packages in your monorepo with their tasks and dependencies
+    Ls {
+        /// Only list the packages matching the filter. Uses the same syntax
+        /// as `turbo run --filter`
+        #[clap(short = 'F', long)]
+        filter: Vec<String>,
+        /// Output the packages in JSON format
+        #[clap(long)]
+        json: bool,
+    },
     /// Prepare a subset of your monorepo.
     Prune {
         #[clap(hide = true, long)]
@@ -515,8 +830,21 @@ pub enum Command {
         scope_arg: Option<Vec<String>>,
         #[clap(long)]
         docker: bool,
+        /// Write a multi-stage Dockerfile that installs and builds the pruned
+        /// output to the output directory. Requires --docker
+        #[clap(long, requires = "docker")]
+        emit_dockerfile: bool,
         #[clap(long = "out-dir", default_value_t = String::from(prune::DEFAULT_OUTPUT_DIR), value_parser)]
         output_dir: String,
+        /// Additional files or directories from the repository root to copy
+        /// into the output. Supports globs and can be passed multiple times
+        #[clap(long, value_name = "GLOB")]
+        include: Vec<String>,
+    },
+    /// Check the connection to your remote cache
+    RemoteCache {
+        #[clap(subcommand)]
+        command: RemoteCacheCommand,
     },
 
     /// Run tasks across projects in your monorepo
@@ -541,6 +869,14 @@ pub enum Command {
         /// Specify what should be unlinked (default "remote cache")
         #[clap(long, value_enum, default_value_t = LinkTarget::RemoteCache)]
         target: LinkTarget,
+
+        /// Also remove all saved link profiles
+        #[clap(long, conflicts_with = "profile")]
+        all: bool,
+
+        /// Remove the saved link profile with this name
+        #[clap(long, value_parser = NonEmptyStringValueParser::new())]
+        profile: Option<String>,
     },
 }
 
@@ -627,6 +963,127 @@ fn path_non_empty(s: &str) -> Result<Utf8PathBuf, String> {
     }
 }
 
+// Parses a human readable size such as `500MB` or `10GB` into bytes.
+// Units are powers of 1024 and a bare number is treated as bytes.
+fn parse_byte_size(s: &str) -> Result<u64, String> {
+    let s = s.trim();
+    let split = s
+        .find(|c: char| !c.is_ascii_digit() && c != '.')
+        .unwrap_or(s.len());
+    let (amount, unit) = s.split_at(split);
+    let multiplier: u64 = match unit.trim().to_ascii_lowercase().as_str() {
+        "" | "b" => 1,
+        "k" | "kb" => 1 << 10,
+        "m" | "mb" => 1 << 20,
+        "g" | "gb" => 1 << 30,
+        "t" | "tb" => 1 << 40,
+        _ => {
+            return Err(format!(
+                "invalid size unit '{unit}'. Allowed units are: B, KB, MB, GB, TB"
+            ))
+        }
+    };
+    let amount = amount
+        .parse::<f64>()
+        .map_err(|_| format!("invalid size: '{s}'"))?;
+    if amount <= 0.0 || !amount.is_finite() {
+        return Err("size must be greater than 0".to_string());
+    }
+
+    Ok((amount * multiplier as f64) as u64)
+}
+
+// Parses `--force` with `parse_force`. Only the booleans are listed as possible
+// values in the help, since a list of tasks can't be enumerated.
+#[derive(Clone)]
+struct ForceParser;
+
+impl TypedValueParser for ForceParser {
+    type Value = Force;
+
+    fn parse_ref(
+        &self,
+        cmd: &clap::Command,
+        arg: Option<&clap::Arg>,
+        value: &std::ffi::OsStr,
+    ) -> Result<Self::Value, clap::Error> {
+        let value = NonEmptyStringValueParser::new().parse_ref(cmd, arg, value)?;
+        parse_force(&value).map_err(|message| {
+            clap::Error::raw(
+                clap::error::ErrorKind::InvalidValue,
+                format!("invalid value '{value}' for '--force': {message}\n"),
+            )
+            .with_cmd(cmd)
+        })
+    }
+
+    fn possible_values(&self) -> Option<Box<dyn Iterator<Item = PossibleValue> + '_>> {
+        Some(Box::new(
+            ["true", "false"].into_iter().map(PossibleValue::new),
+        ))
+    }
+}
+
+fn parse_force(s: &str) -> Result<Force, String> {
+    match s {
+        // `TURBO_FORCE=1` and `TURBO_FORCE=0` keep working as booleans
+        "true" | "1" => Ok(Force::All),
+        "false" | "0" => Ok(Force::None),
+        tasks => {
+            let tasks = tasks
+                .split(',')
+                .map(str::trim)
+                .filter(|task| !task.is_empty())
+                .map(String::from)
+                .collect::<Vec<_>>();
+            if tasks.is_empty() {
+                return Err("expected true, false, or a comma-separated list of tasks".to_string());
+            }
+            Ok(Force::Tasks(tasks))
+        }
+    }
+}
+
+fn parse_log_prefix(s: &str) -> Result<LogPrefix, String> {
+    match s {
+        "auto" => Ok(LogPrefix::Auto),
+        "none" => Ok(LogPrefix::None),
+        "task" => Ok(LogPrefix::Task),
+        "package" => Ok(LogPrefix::Package),
+        _ => {
+            let Some(template) = s.strip_prefix("template:") else {
+                return Err(
+                    "expected auto, none, task, package, or template:<template>".to_string()
+                );
+            };
+            let mut rest = template;
+            while let Some(start) = rest.find('{') {
+                let Some(end) = rest[start..].find('}') else {
+                    return Err(format!("unclosed placeholder in template `{template}`"));
+                };
+                let placeholder = &rest[start..start + end + 1];
+                if !matches!(placeholder, "{package}" | "{task}") {
+                    return Err(format!(
+                        "unknown placeholder {placeholder} in template, expected {{package}} or \
+                         {{task}}"
+                    ));
+                }
+                rest = &rest[start + end + 1..];
+            }
+            Ok(LogPrefix::Template(template.to_string()))
+        }
+    }
+}
+
+// Parses a human readable duration such as `30m` or `1h 30m`.
+fn parse_duration(s: &str) -> Result<Duration, String> {
+    let duration = humantime::parse_duration(s).map_err(|e| e.to_string())?;
+    if duration.is_zero() {
+        return Err("duration must be greater than 0".to_string());
+    }
+    Ok(duration)
+}
+
 /// Arguments used in run and watch
 #[derive(Parser, Clone, Debug, Default, PartialEq)]
 #[command(groups = [
@@ -636,20 +1093,47 @@ pub struct ExecutionArgs {
     /// Override the filesystem cache directory.
     #[clap(long, value_parser = path_non_empty, env = "TURBO_CACHE_DIR")]
     pub cache_dir: Option<Utf8PathBuf>,
+    /// Set the maximum size of the filesystem cache (e.g. 500MB, 10GB).
+    /// Once the cache grows past this size, the least recently used
+    /// artifacts are removed.
+    #[clap(long, value_parser = parse_byte_size, env = "TURBO_CACHE_MAX_SIZE")]
+    pub cache_max_size: Option<u64>,
+    /// When restoring outputs from the cache, only write the files that
+    /// differ from the ones already on disk.
+    #[clap(long, env = "TURBO_CACHE_SKIP_UNCHANGED")]
+    pub cache_skip_unchanged: bool,
+    /// Mix a prefix into the hashes of tasks so that their cache artifacts
+    /// are only shared with runs that use the same prefix (e.g. a branch or
+    /// deployment environment).
+    #[clap(long, value_name = "PREFIX", env = "TURBO_CACHE_KEY_PREFIX")]
+    pub cache_key_prefix: Option<String>,
+    /// Fail tasks on cache problems that are otherwise only warned about:
+    /// artifacts that don't match their checksum or signature, artifacts that
+    /// would restore files outside of the repository, and outputs that a
+    /// task didn't write.
+    #[clap(long, env = "TURBO_STRICT_CACHE")]
+    pub strict_cache: bool,
     /// Limit the concurrency of task execution. Use 1 for serial (i.e.
     /// one-at-a-time) execution.
     #[clap(long)]
     pub concurrency: Option<String>,
     /// Continue execution even if a task exits with an error or non-zero
-    /// exit code. The default behavior is to bail
-    #[clap(long = "continue")]
-    pub continue_execution: bool,
+    /// exit code. Use "dependencies-successful" to only skip the tasks that
+    /// depend on a failed task. The default behavior is to bail
+    #[clap(long = "continue", value_enum, num_args = 0..=1, require_equals = true, default_value_t = ContinueMode::Never, default_missing_value = "always")]
+    pub continue_execution: ContinueMode,
+    /// Choose which task dependencies are run. Use "ignore-topology" to skip
+    /// dependencies on tasks in other packages declared with "^". Use
+    /// "only-direct" to run the direct dependencies of the specified tasks,
+    /// but not their dependencies
+    #[clap(long, value_enum, default_value_t = DependencyMode::All)]
+    pub dependency_mode: DependencyMode,
     /// Run turbo in single-package mode
     #[clap(long)]
     pub single_package: bool,
     /// Ignore the existing cache (to force execution)
-    #[clap(long, env = "TURBO_FORCE", default_missing_value = "true")]
-    pub force: Option<Option<bool>>,
+    #[clap(long, env = "TURBO_FORCE", value_parser = ForceParser, num_args = 0..=1, default_missing_value = "true")]
+    pub force: Option<Force>,
     /// Specify whether or not to do framework inference for tasks
     #[clap(long, value_name = "BOOL", action = ArgAction::Set, default_value = "true", default_missing_value = "true", num_args = 0..=1)]
     pub framework_inference: bool,
@@ -668,6 +1152,22 @@ pub struct ExecutionArgs {
     /// turbo's documentation https://turbo.build/repo/docs/reference/command-line-reference/run#--filter
     #[clap(short = 'F', long, group = "scope-filter-group")]
     pub filter: Vec<String>,
+    /// Run only the tasks of packages that have changed compared to the
+    /// base branch, along with the packages that depend on them. Uncommitted
+    /// changes are included.
+    #[clap(long, conflicts_with = "filter")]
+    pub affected: bool,
+    /// The git ref that --affected compares against (default origin/main)
+    #[clap(long, value_name = "REF", env = "TURBO_SCM_BASE")]
+    pub affected_base: Option<String>,
+    /// Print the packages each filter matched and the packages that were
+    /// selected once all filters were applied
+    #[clap(long)]
+    pub explain_filter: bool,
+    /// Fetch more history when a commit that a filter or --affected compares
+    /// against is missing from a shallow clone
+    #[clap(long)]
+    pub auto_deepen: bool,
 
     /// Set type of process output logging. Use "full" to show
     /// all output. Use "hash-only" to show only turbo-computed
@@ -682,6 +1182,10 @@ pub struct ExecutionArgs {
     /// turbo decide based on its own heuristics. (default auto)
     #[clap(long, env = "TURBO_LOG_ORDER", value_enum, default_value_t = LogOrder::Auto)]
     pub log_order: LogOrder,
+    /// Specify whether to use the terminal UI or stream task logs. The
+    /// terminal UI is only used if stdout is a TTY.
+    #[clap(long, value_enum)]
+    pub ui: Option<UIMode>,
     /// Only executes the tasks specified, does not execute parent tasks.
     #[clap(long)]
     pub only: bool,
@@ -691,14 +1195,52 @@ pub struct ExecutionArgs {
     /// allow reading and caching artifacts using the remote cache.
     #[clap(long, env = "TURBO_REMOTE_ONLY", value_name = "BOOL", action = ArgAction::Set, default_value = "false", default_missing_value = "true", num_args = 0..=1)]
     pub remote_only: bool,
+    /// How long, in milliseconds, to wait for tasks to exit after turbo is
+    /// interrupted before they are forcibly killed. (default 500)
+    #[clap(long, value_name = "MS", env = "TURBO_SHUTDOWN_GRACE_PERIOD")]
+    pub shutdown_grace_period: Option<u64>,
+    /// Terminate tasks that run for longer than the given duration (e.g. 30m,
+    /// 1h 30m) and mark them as failed. Overrides the `timeout` of every task
+    /// that isn't persistent.
+    #[clap(long, value_name = "DURATION", value_parser = parse_duration, env = "TURBO_TASK_TIMEOUT")]
+    pub task_timeout: Option<Duration>,
+    /// Forward turbo's stdin to the given task, e.g. to answer prompts from a
+    /// persistent task. Only used when the terminal UI isn't, since the UI
+    /// forwards input to whichever task is selected.
+    #[clap(long, value_name = "TASK")]
+    pub interactive: Option<String>,
+    /// Fail before running any tasks if the installed package manager or
+    /// Node.js don't match the `packageManager` field or `engines.node`
+    /// range in the root package.json.
+    #[clap(long)]
+    pub strict_engines: bool,
+    /// Layer the task options of the named profile in turbo.json on top of
+    /// the task definitions, e.g. to change caching and logging in CI.
+    #[clap(long, value_name = "NAME", env = "TURBO_PROFILE")]
+    pub profile_name: Option<String>,
+    /// Write the output of every task to <DIR>/<package>/<task>.log, even
+    /// if --output-logs hides it. Uses .turbo/logs in the repository root if
+    /// no directory is given.
+    #[clap(
+        long,
+        value_name = "DIR",
+        value_parser = path_non_empty,
+        env = "TURBO_LOG_DIR",
+        num_args = 0..=1,
+        require_equals = true,
+        default_missing_value = ".turbo/logs"
+    )]
+    pub log_dir: Option<Utf8PathBuf>,
     /// Use "none" to remove prefixes from task logs. Use "task" to get task id
-    /// prefixing. Use "auto" to let turbo decide how to prefix the logs
-    /// based on the execution environment. In most cases this will be the same
-    /// as "task". Note that tasks running in parallel interleave their
-    /// logs, so removing prefixes can make it difficult to associate logs
-    /// with tasks. Use --log-order=grouped to prevent interleaving. (default
-    /// auto)
-    #[clap(long, value_enum, default_value_t = LogPrefix::Auto)]
+    /// prefixing, or "package" to only prefix with the package name. Use
+    /// "template:<template>" to write your own prefix, where {package} and
+    /// {task} are replaced, e.g. --log-prefix='template:[{package}] '. Use
+    /// "auto" to let turbo decide how to prefix the logs based on the execution
+    /// environment. In most cases this will be the same as "task". Note that
+    /// tasks running in parallel interleave their logs, so removing prefixes
+    /// can make it difficult to associate logs with tasks. Use
+    /// --log-order=grouped to prevent interleaving. (default auto)
+    #[clap(long, value_parser = parse_log_prefix, default_value = "auto")]
     pub log_prefix: LogPrefix,
     // NOTE: The following two are hidden because clap displays them in the help text incorrectly:
     // > Usage: turbo [OPTIONS] [TASKS]... [-- <FORWARDED_ARGS>...] [COMMAND]
@@ -713,18 +1255,43 @@ impl ExecutionArgs {
         // default to false
         track_usage!(telemetry, self.framework_inference, |val: bool| !val);
 
-        track_usage!(telemetry, self.continue_execution, |val| val);
         track_usage!(telemetry, self.single_package, |val| val);
         track_usage!(telemetry, self.only, |val| val);
+        track_usage!(telemetry, self.affected, |val| val);
+        track_usage!(telemetry, self.explain_filter, |val| val);
+        track_usage!(telemetry, self.auto_deepen, |val| val);
         track_usage!(telemetry, self.remote_only, |val| val);
         track_usage!(telemetry, &self.cache_dir, Option::is_some);
+        track_usage!(telemetry, &self.cache_max_size, Option::is_some);
+        track_usage!(telemetry, self.cache_skip_unchanged, |val| val);
+        track_usage!(telemetry, &self.cache_key_prefix, Option::is_some);
+        track_usage!(telemetry, self.strict_cache, |val| val);
         track_usage!(telemetry, &self.force, Option::is_some);
         track_usage!(telemetry, &self.pkg_inference_root, Option::is_some);
+        track_usage!(telemetry, &self.affected_base, Option::is_some);
+        track_usage!(telemetry, &self.shutdown_grace_period, Option::is_some);
+        track_usage!(telemetry, &self.task_timeout, Option::is_some);
+        track_usage!(telemetry, &self.interactive, Option::is_some);
+        track_usage!(telemetry, self.strict_engines, |val| val);
+        track_usage!(telemetry, &self.profile_name, Option::is_some);
+        track_usage!(telemetry, &self.log_dir, Option::is_some);
 
         if let Some(concurrency) = &self.concurrency {
             telemetry.track_arg_value("concurrency", concurrency, EventType::NonSensitive);
         }
 
+        if self.continue_execution != ContinueMode::default() {
+            telemetry.track_arg_value("continue", self.continue_execution, EventType::NonSensitive);
+        }
+
+        if self.dependency_mode != DependencyMode::default() {
+            telemetry.track_arg_value(
+                "dependency-mode",
+                self.dependency_mode,
+                EventType::NonSensitive,
+            );
+        }
+
         if !self.global_deps.is_empty() {
             telemetry.track_arg_value(
                 "global-deps",
@@ -745,8 +1312,17 @@ impl ExecutionArgs {
             telemetry.track_arg_value("log-order", self.log_order, EventType::NonSensitive);
         }
 
+        if let Some(ui) = self.ui {
+            telemetry.track_arg_value("ui", ui, EventType::NonSensitive);
+        }
+
         if self.log_prefix != LogPrefix::default() {
-            telemetry.track_arg_value("log-prefix", self.log_prefix, EventType::NonSensitive);
+            // templates are written by users, so only track that one was used
+            let log_prefix = match &self.log_prefix {
+                LogPrefix::Template(_) => "template".to_string(),
+                log_prefix => log_prefix.to_string(),
+            };
+            telemetry.track_arg_value("log-prefix", log_prefix, EventType::NonSensitive);
         }
 
         // track sizes
@@ -766,6 +1342,14 @@ pub struct RunArgs {
     pub cache_workers: u32,
     #[clap(alias = "dry", long = "dry-run", num_args = 0..=1, default_missing_value = "text")]
     pub dry_run: Option<DryRunMode>,
+    /// Print the hash of each task and exit without running tasks or checking
+    /// the cache
+    #[clap(long, conflicts_with_all = ["dry_run", "graph"])]
+    pub hash_only: bool,
+    /// Check whether each task is cached and exit without restoring outputs
+    /// or running tasks. Exits with 1 if any task is not cached
+    #[clap(long, alias = "no-restore", conflicts_with_all = ["dry_run", "graph", "hash_only"])]
+    pub check_cache_only: bool,
     /// Generate a graph of the task execution and output to a file when a
     /// filename is specified (.svg, .png, .jpg, .pdf, .json,
     /// .html, .mermaid, .dot). Outputs dot graph to stdout when if no filename
@@ -801,19 +1385,77 @@ pub struct RunArgs {
     #[clap(long, value_parser=NonEmptyStringValueParser::new(), conflicts_with = "profile")]
     pub anon_profile: Option<String>,
     /// Treat remote cache as read only
-    #[clap(long, env = "TURBO_REMOTE_CACHE_READ_ONLY", value_name = "BOOL", action = ArgAction::Set, default_value = "false", default_missing_value = "true", num_args = 0..=1)]
-    pub remote_cache_read_only: bool,
+    #[clap(long, env = "TURBO_REMOTE_CACHE_READ_ONLY", value_name = "BOOL", action = ArgAction::Set, default_missing_value = "true", num_args = 0..=1)]
+    pub remote_cache_read_only: Option<bool>,
+    /// Treat remote cache as write only
+    #[clap(long, env = "TURBO_REMOTE_CACHE_WRITE_ONLY", value_name = "BOOL", action = ArgAction::Set, default_missing_value = "true", num_args = 0..=1)]
+    pub remote_cache_write_only: Option<bool>,
+    /// Set the maximum number of artifacts uploaded to the remote cache at
+    /// the same time. Uploads also count towards --cache-workers. (default
+    /// the number of cache workers)
+    #[clap(long, value_name = "N", env = "TURBO_REMOTE_CACHE_UPLOAD_CONCURRENCY", value_parser = clap::value_parser!(u32).range(1..))]
+    pub remote_cache_upload_concurrency: Option<u32>,
     /// Generate a summary of the turbo run
     #[clap(long, env = "TURBO_RUN_SUMMARY", default_missing_value = "true")]
     pub summarize: Option<Option<bool>>,
+    /// Send the number of cache hits and the time and bytes that the cache
+    /// saved to the remote cache after the run. No task names or hashes are
+    /// sent
+    #[clap(long, env = "TURBO_CACHE_ANALYTICS", value_name = "BOOL", action = ArgAction::Set, default_value = "false", default_missing_value = "true", num_args = 0..=1)]
+    pub cache_analytics: bool,
+    /// Salt the hashes of environment variable values in run summaries and
+    /// --dry=json. Values are comparable across machines that use the same
+    /// salt, without being guessable by anyone who doesn't know it
+    #[clap(
+        long,
+        value_name = "SALT",
+        env = "TURBO_ENV_HASH_SALT",
+        hide_env_values = true
+    )]
+    pub env_hash_salt: Option<String>,
+    /// Warn about the environment variables that tasks read without declaring
+    /// them. Only the variables read by Node.js processes are recorded
+    #[clap(long)]
+    pub env_audit: bool,
+
+    /// Export a trace of the run to an OpenTelemetry collector. Spans are
+    /// sent with OTLP over HTTP to <URL>/v1/traces
+    #[clap(long, value_name = "URL", env = "TURBO_OTEL_EXPORTER_ENDPOINT")]
+    pub otel_exporter_endpoint: Option<String>,
+
+    /// Send tasks to the executor at <URL> to run them on remote workers.
+    /// Outputs are shared through the remote cache. Experimental
+    #[clap(long, value_name = "URL", env = "TURBO_EXPERIMENTAL_EXECUTOR")]
+    pub experimental_executor: Option<String>,
 
     // Pass a string to enable posting Run Summaries to Vercel
     #[clap(long, hide = true)]
     pub experimental_space_id: Option<String>,
 
-    /// Execute all tasks in parallel.
+    /// Execute all tasks in parallel. Deprecated, use
+    /// --dependency-mode=ignore-topology instead.
     #[clap(long)]
     pub parallel: bool,
+
+    /// Skip the tasks that completed successfully in the previous run, e.g.
+    /// after it was interrupted. Tasks whose hash changed are run again.
+    #[clap(long)]
+    pub resume: bool,
+
+    /// Predict how long the run will take from the durations of tasks in
+    /// previous run summaries, and fail before running any tasks if it's
+    /// longer than <DURATION>
+    #[clap(long, value_name = "DURATION", value_parser = parse_duration)]
+    pub time_budget: Option<Duration>,
+    /// Whether to fail or only warn when the run is predicted to take longer
+    /// than --time-budget
+    #[clap(long, value_name = "MODE", default_value_t = TimeBudgetMode::Fail, requires = "time_budget")]
+    pub time_budget_mode: TimeBudgetMode,
+
+    /// Keep turbo running and re-run affected tasks when files change.
+    /// Equivalent to `turbo watch`.
+    #[clap(long, conflicts_with_all = ["dry_run", "graph", "hash_only", "check_cache_only"])]
+    pub watch: bool,
 }
 
 impl Default for RunArgs {
@@ -821,16 +1463,29 @@ impl Default for RunArgs {
         Self {
             cache_workers: DEFAULT_NUM_WORKERS,
             dry_run: None,
+            hash_only: false,
+            check_cache_only: false,
             graph: None,
             no_cache: false,
             daemon: false,
             no_daemon: false,
             profile: None,
             anon_profile: None,
-            remote_cache_read_only: false,
+            remote_cache_read_only: None,
+            remote_cache_write_only: None,
+            remote_cache_upload_concurrency: None,
             summarize: None,
+            cache_analytics: false,
+            env_hash_salt: None,
+            env_audit: false,
+            otel_exporter_endpoint: None,
+            experimental_executor: None,
             experimental_space_id: None,
             parallel: false,
+            resume: false,
+            time_budget: None,
+            time_budget_mode: TimeBudgetMode::Fail,
+            watch: false,
         }
     }
 }
@@ -860,15 +1515,28 @@ impl RunArgs {
     pub fn track(&self, telemetry: &CommandEventBuilder) {
         // default to true
         track_usage!(telemetry, self.no_cache, |val| val);
+        track_usage!(telemetry, self.hash_only, |val| val);
+        track_usage!(telemetry, self.check_cache_only, |val| val);
         track_usage!(telemetry, self.daemon, |val| val);
         track_usage!(telemetry, self.no_daemon, |val| val);
         track_usage!(telemetry, self.parallel, |val| val);
-        track_usage!(telemetry, self.remote_cache_read_only, |val| val);
+        track_usage!(telemetry, self.resume, |val| val);
+        track_usage!(telemetry, &self.time_budget, Option::is_some);
+        track_usage!(telemetry, self.env_audit, |val| val);
+        track_usage!(telemetry, self.cache_analytics, |val| val);
+        track_usage!(telemetry, self.remote_cache_read_only, |val| val
+            == Some(true));
+        track_usage!(telemetry, self.remote_cache_write_only, |val| val
+            == Some(true));
+        track_usage!(telemetry, self.watch, |val| val);
 
         // default to None
         track_usage!(telemetry, &self.profile, Option::is_some);
         track_usage!(telemetry, &self.anon_profile, Option::is_some);
         track_usage!(telemetry, &self.summarize, Option::is_some);
+        track_usage!(telemetry, &self.env_hash_salt, Option::is_some);
+        track_usage!(telemetry, &self.otel_exporter_endpoint, Option::is_some);
+        track_usage!(telemetry, &self.experimental_executor, Option::is_some);
         track_usage!(telemetry, &self.experimental_space_id, Option::is_some);
 
         // track values
@@ -880,6 +1548,14 @@ impl RunArgs {
             telemetry.track_arg_value("cache-workers", self.cache_workers, EventType::NonSensitive);
         }
 
+        if let Some(concurrency) = self.remote_cache_upload_concurrency {
+            telemetry.track_arg_value(
+                "remote-cache-upload-concurrency",
+                concurrency,
+                EventType::NonSensitive,
+            );
+        }
+
         if let Some(graph) = &self.graph {
             // track the extension used only
             let extension = Utf8Path::new(graph).extension().unwrap_or("stdout");
@@ -888,7 +1564,7 @@ impl RunArgs {
     }
 }
 
-#[derive(ValueEnum, Clone, Copy, Debug, PartialEq, Serialize)]
+#[derive(Clone, Debug, PartialEq, Serialize)]
 pub enum LogPrefix {
     #[serde(rename = "auto")]
     Auto,
@@ -896,6 +1572,11 @@ pub enum LogPrefix {
     None,
     #[serde(rename = "task")]
     Task,
+    #[serde(rename = "package")]
+    Package,
+    /// `template:<template>`, where `{package}` and `{task}` are replaced
+    #[serde(rename = "template")]
+    Template(String),
 }
 
 impl Default for LogPrefix {
@@ -910,6 +1591,8 @@ impl Display for LogPrefix {
             LogPrefix::Auto => write!(f, "auto"),
             LogPrefix::None => write!(f, "none"),
             LogPrefix::Task => write!(f, "task"),
+            LogPrefix::Package => write!(f, "package"),
+            LogPrefix::Template(template) => write!(f, "template:{template}"),
         }
     }
 }
@@ -942,25 +1625,6 @@ pub async fn run(
     let mut cli_args = Args::new();
     let version = get_version();
 
-    // track telemetry handle to close at the end of the run
-    let mut telemetry_handle: Option<TelemetryHandle> = None;
-
-    // initialize telemetry
-    match AnonAPIClient::new("https://telemetry.vercel.com", 250, version) {
-        Ok(anonymous_api_client) => {
-            let handle = init_telemetry(anonymous_api_client, ui);
-            match handle {
-                Ok(h) => telemetry_handle = Some(h),
-                Err(error) => {
-                    debug!("failed to start telemetry: {:?}", error)
-                }
-            }
-        }
-        Err(error) => {
-            debug!("Failed to create AnonAPIClient: {:?}", error);
-        }
-    }
-
     // If there is no command, we set the command to `Command::Run` with
     // `self.parsed_args.run_args` as arguments.
     let mut command = if let Some(command) = mem::take(&mut cli_args.command) {
@@ -985,11 +1649,24 @@ pub async fn run(
         }
     };
 
+    if let Command::Graph {
+        format: _,
+        execution_args,
+    } = &mut command
+    {
+        execution_args.single_package = execution_args.single_package
+            || repo_state
+                .as_ref()
+                .map(|repo_state| matches!(repo_state.mode, RepoMode::SinglePackage))
+                .unwrap_or(false);
+    }
+
     // Set some run flags if we have the data and are executing a Run
     if let Command::Run {
         run_args: _,
         execution_args,
-    } = &mut command
+    }
+    | Command::ExecPlan { execution_args, .. } = &mut command
     {
         // Don't overwrite the flag if it's already been set for whatever reason
         execution_args.single_package = execution_args.single_package
@@ -1040,6 +1717,32 @@ pub async fn run(
     cli_args.command = Some(command);
     cli_args.cwd = Some(repo_root.as_path().to_owned());
 
+    // track telemetry handle to close at the end of the run
+    let mut telemetry_handle: Option<TelemetryHandle> = None;
+
+    // initialize telemetry, with the same TLS settings as the other clients so
+    // that it works behind proxies that intercept TLS
+    let tls_options = CommandBase::new(cli_args.clone(), repo_root.clone(), version, ui)
+        .tls_options()
+        .unwrap_or_else(|error| {
+            debug!("failed to read TLS settings for telemetry: {:?}", error);
+            TlsOptions::default()
+        });
+    match AnonAPIClient::new("https://telemetry.vercel.com", 250, version, &tls_options) {
+        Ok(anonymous_api_client) => {
+            let handle = init_telemetry(anonymous_api_client, ui);
+            match handle {
+                Ok(h) => telemetry_handle = Some(h),
+                Err(error) => {
+                    debug!("failed to start telemetry: {:?}", error)
+                }
+            }
+        }
+        Err(error) => {
+            debug!("Failed to create AnonAPIClient: {:?}", error);
+        }
+    }
+
     let root_telemetry = GenericEventBuilder::new();
     root_telemetry.track_start();
 
@@ -1059,33 +1762,78 @@ pub async fn run(
 
             Ok(0)
         }
-        #[allow(unused_variables)]
-        Command::Daemon { command, idle_time } => {
-            CommandEventBuilder::new("daemon")
+        Command::Cache { cache_dir, command } => {
+            CommandEventBuilder::new("cache")
                 .with_parent(&root_telemetry)
                 .track_call();
             let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
 
-            match command {
-                Some(command) => daemon::daemon_client(command, &base).await,
-                None => daemon::daemon_server(&base, idle_time, logger).await,
-            }?;
+            Ok(cache::run(&base, cache_dir.as_deref(), command)?)
+        }
+        Command::Config { command } => {
+            CommandEventBuilder::new("config")
+                .with_parent(&root_telemetry)
+                .track_call();
+            config::run(command);
 
             Ok(0)
         }
-        Command::Generate {
-            tag,
-            generator_name,
-            config,
-            root,
-            args,
-            command,
+        Command::Diff {
+            before,
+            after,
+            json,
         } => {
-            let event = CommandEventBuilder::new("generate").with_parent(&root_telemetry);
+            CommandEventBuilder::new("diff")
+                .with_parent(&root_telemetry)
+                .track_call();
+            diff::run(ui, before, after, *json)?;
+
+            Ok(0)
+        }
+        Command::ExecPlan { plan, run_args, .. } => {
+            let event = CommandEventBuilder::new("exec-plan").with_parent(&root_telemetry);
             event.track_call();
-            // build GeneratorCustomArgs struct
-            let args = GeneratorCustomArgs {
-                generator_name: generator_name.clone(),
+            if let Some((file_path, include_args)) = run_args.profile_file_and_include_args() {
+                let _ = logger.enable_chrome_tracing(file_path, include_args);
+            }
+            let plan = plan.clone();
+            let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
+            run_args.track(&event);
+            event.track_run_code_path(CodePath::Rust);
+            let exit_code = exec_plan::run(base, &plan, event).await?;
+            Ok(exit_code)
+        }
+        #[allow(unused_variables)]
+        Command::Daemon {
+            command,
+            idle_time,
+            watcher,
+        } => {
+            CommandEventBuilder::new("daemon")
+                .with_parent(&root_telemetry)
+                .track_call();
+            let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
+
+            match command {
+                Some(command) => daemon::daemon_client(command, &base).await,
+                None => daemon::daemon_server(&base, idle_time, *watcher, logger).await,
+            }?;
+
+            Ok(0)
+        }
+        Command::Generate {
+            tag,
+            generator_name,
+            config,
+            root,
+            args,
+            command,
+        } => {
+            let event = CommandEventBuilder::new("generate").with_parent(&root_telemetry);
+            event.track_call();
+            // build GeneratorCustomArgs struct
+            let args = GeneratorCustomArgs {
+                generator_name: generator_name.clone(),
                 config: config.clone(),
                 root: root.clone(),
                 args: args.clone(),
@@ -1124,6 +1872,8 @@ pub async fn run(
         Command::Link {
             no_gitignore,
             target,
+            profile,
+            list_profiles,
         } => {
             CommandEventBuilder::new("link")
                 .with_parent(&root_telemetry)
@@ -1135,9 +1885,16 @@ pub async fn run(
 
             let modify_gitignore = !*no_gitignore;
             let to = *target;
+            let profile = profile.clone();
+            let list_profiles = *list_profiles;
             let mut base = CommandBase::new(cli_args, repo_root, version, ui);
 
-            if let Err(err) = link::link(&mut base, modify_gitignore, to).await {
+            let result = if list_profiles {
+                link::list_profiles(&base)
+            } else {
+                link::link(&mut base, modify_gitignore, to, profile.as_deref()).await
+            };
+            if let Err(err) = result {
                 error!("error: {}", err.to_string())
             }
 
@@ -1155,7 +1912,28 @@ pub async fn run(
 
             Ok(0)
         }
-        Command::Login { sso_team, force } => {
+        Command::LintConfig { json } => {
+            CommandEventBuilder::new("lint-config")
+                .with_parent(&root_telemetry)
+                .track_call();
+            let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
+
+            Ok(lint_config::run(&base, *json).await?)
+        }
+        Command::Ls { filter, json } => {
+            CommandEventBuilder::new("ls")
+                .with_parent(&root_telemetry)
+                .track_call();
+            let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
+            ls::run(&base, filter, *json).await?;
+
+            Ok(0)
+        }
+        Command::Login {
+            sso_team,
+            force,
+            device,
+        } => {
             let event = CommandEventBuilder::new("login").with_parent(&root_telemetry);
             event.track_call();
             if cli_args.test_run {
@@ -1165,11 +1943,14 @@ pub async fn run(
 
             let sso_team = sso_team.clone();
             let force = *force;
+            let device = *device;
 
             let mut base = CommandBase::new(cli_args, repo_root, version, ui);
             let event_child = event.child();
 
-            if let Some(sso_team) = sso_team {
+            if device {
+                login::device_login(&mut base, sso_team.as_deref(), event_child, force).await?;
+            } else if let Some(sso_team) = sso_team {
                 login::sso_login(&mut base, &sso_team, event_child, force).await?;
             } else {
                 login::login(&mut base, event_child, force).await?;
@@ -1177,7 +1958,11 @@ pub async fn run(
 
             Ok(0)
         }
-        Command::Unlink { target } => {
+        Command::Unlink {
+            target,
+            all,
+            profile,
+        } => {
             CommandEventBuilder::new("unlink")
                 .with_parent(&root_telemetry)
                 .track_call();
@@ -1187,9 +1972,11 @@ pub async fn run(
             }
 
             let from = *target;
+            let all = *all;
+            let profile = profile.clone();
             let mut base = CommandBase::new(cli_args, repo_root, version, ui);
 
-            unlink::unlink(&mut base, from)?;
+            unlink::unlink(&mut base, from, all, profile.as_deref())?;
 
             Ok(0)
         }
@@ -1205,6 +1992,24 @@ pub async fn run(
                 return Err(Error::NoTasks(backtrace::Backtrace::capture()));
             }
 
+            if run_args.watch {
+                run_args.track(&event);
+                // `turbo run --watch` is handled like `turbo watch`, with the
+                // run args applied to every run
+                let mut cli_args = cli_args.clone();
+                cli_args.command = Some(Command::Watch(execution_args.clone()));
+                let base = CommandBase::new(cli_args, repo_root, version, ui);
+                let run_args = Box::new(RunArgs {
+                    watch: false,
+                    ..(**run_args).clone()
+                });
+
+                let mut client = WatchClient::new(base, run_args, event).await?;
+                client.start().await?;
+                // We only exit if we get a signal, so we return a non-zero exit code
+                return Ok(1);
+            }
+
             if let Some((file_path, include_args)) = run_args.profile_file_and_include_args() {
                 // TODO: Do we want to handle the result / error?
                 let _ = logger.enable_chrome_tracing(file_path, include_args);
@@ -1219,12 +2024,27 @@ pub async fn run(
             })?;
             Ok(exit_code)
         }
+        Command::Graph {
+            format,
+            execution_args,
+        } => {
+            let event = CommandEventBuilder::new("graph").with_parent(&root_telemetry);
+            event.track_call();
+            if execution_args.tasks.is_empty() {
+                return Err(Error::NoTasks(backtrace::Backtrace::capture()));
+            }
+
+            let format = *format;
+            let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
+            let exit_code = graph::graph(base, format, event).await?;
+            Ok(exit_code)
+        }
         Command::Watch(_) => {
             let event = CommandEventBuilder::new("watch").with_parent(&root_telemetry);
             event.track_call();
             let base = CommandBase::new(cli_args, repo_root, version, ui);
 
-            let mut client = WatchClient::new(base, event).await?;
+            let mut client = WatchClient::new(base, Box::default(), event).await?;
             client.start().await?;
             // We only exit if we get a signal, so we return a non-zero exit code
             return Ok(1);
@@ -1233,7 +2053,9 @@ pub async fn run(
             scope,
             scope_arg,
             docker,
+            emit_dockerfile,
             output_dir,
+            include,
         } => {
             let event = CommandEventBuilder::new("prune").with_parent(&root_telemetry);
             event.track_call();
@@ -1246,9 +2068,26 @@ pub async fn run(
             let output_dir = output_dir.clone();
             let base = CommandBase::new(cli_args, repo_root, version, ui);
             let event_child = event.child();
-            prune::prune(&base, &scope, docker, &output_dir, event_child).await?;
+            prune::prune(
+                &base,
+                &scope,
+                docker,
+                *emit_dockerfile,
+                &output_dir,
+                include,
+                event_child,
+            )
+            .await?;
             Ok(0)
         }
+        Command::RemoteCache { command } => {
+            CommandEventBuilder::new("remote-cache")
+                .with_parent(&root_telemetry)
+                .track_call();
+            let base = CommandBase::new(cli_args.clone(), repo_root, version, ui);
+
+            Ok(remote_cache::run(&base, command).await?)
+        }
         Command::Completion { shell } => {
             CommandEventBuilder::new("completion")
                 .with_parent(&root_telemetry)
@@ -1274,7 +2113,7 @@ pub async fn run(
 
 #[cfg(test)]
 mod test {
-    use std::assert_matches::assert_matches;
+    use std::{assert_matches::assert_matches, time::Duration};
 
     use camino::Utf8PathBuf;
     use clap::Parser;
@@ -1338,7 +2177,11 @@ mod test {
         }
     }
 
-    use crate::cli::{Args, Command, DryRunMode, EnvMode, LogOrder, LogPrefix, OutputLogsMode};
+    use crate::cli::{
+        parse_force, Args, CacheCommand, Command, ConfigCommand, ContinueMode, DaemonWatcher,
+        DependencyMode, DryRunMode, EnvMode, ErrorFormat, Force, GraphFormat, LogOrder, LogPrefix,
+        OutputLogsMode, RemoteCacheCommand, UIMode,
+    };
 
     #[test_case::test_case(
         &["turbo", "run", "build"],
@@ -1504,6 +2347,21 @@ mod test {
         } ;
         "cache dir"
 	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--cache-key-prefix", "main"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    cache_key_prefix: Some("main".to_string()),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "cache key prefix"
+	)]
     #[test_case::test_case(
 		&["turbo", "run", "build", "--cache-workers", "100"],
         Args {
@@ -1542,7 +2400,7 @@ mod test {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
-                    continue_execution: true,
+                    continue_execution: ContinueMode::Always,
                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
@@ -1551,6 +2409,36 @@ mod test {
         } ;
         "continue flag"
 	)]
+    #[test_case::test_case(
+		&["turbo", "run", "--continue", "build"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    continue_execution: ContinueMode::Always,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "continue flag before task"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--continue=dependencies-successful"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    continue_execution: ContinueMode::DependenciesSuccessful,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "continue dependencies successful"
+	)]
     #[test_case::test_case(
 		&["turbo", "run", "build", "--dry-run"],
         Args {
@@ -1651,7 +2539,7 @@ mod test {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
-                    force: Some(Some(true)),
+                    force: Some(Force::All),
                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
@@ -1660,6 +2548,51 @@ mod test {
         } ;
         "force"
 	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--force=web#build, lint"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    force: Some(Force::Tasks(vec!["web#build".to_string(), "lint".to_string()])),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "force tasks"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "--force", "false", "build"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    force: Some(Force::None),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "force value before tasks"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--force", "web#build"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    force: Some(Force::Tasks(vec!["web#build".to_string()])),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "force separate tasks"
+	)]
     #[test_case::test_case(
 		&["turbo", "run", "build", "--global-deps", ".env"],
         Args {
@@ -1896,184 +2829,617 @@ mod test {
         "log prefix task"
 	)]
     #[test_case::test_case(
-		&["turbo", "run", "build"],
+		&["turbo", "run", "build", "--log-prefix=template:[{package}] "],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
-                    tasks: vec!["build".to_string()],
-                    log_order: LogOrder::Auto,
-                    ..get_default_execution_args()
+                     tasks: vec!["build".to_string()],
+                     log_prefix: LogPrefix::Template("[{package}] ".to_string()),
+                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
         } ;
-        "just build"
+        "log prefix template"
 	)]
     #[test_case::test_case(
-		&["turbo", "run", "build", "--parallel"],
+		&["turbo", "run", "build", "--ui", "tui"],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
+                    ui: Some(UIMode::Tui),
                     ..get_default_execution_args()
                 }),
-                run_args: Box::new(RunArgs {
-                    parallel: true,
-                    ..get_default_run_args()
-                })
+                run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
         } ;
-        "parallel"
+        "ui tui"
 	)]
     #[test_case::test_case(
-		&["turbo", "run", "build", "--profile", "profile_out"],
+		&["turbo", "run", "build", "--ui=stream"],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
+                    ui: Some(UIMode::Stream),
                     ..get_default_execution_args()
                 }),
-                run_args: Box::new(RunArgs {
-                  profile: Some("profile_out".to_string()),
-                  ..get_default_run_args()
-                })
+                run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
         } ;
-        "profile"
+        "ui stream"
 	)]
-    // remote-only flag tests
     #[test_case::test_case(
-		&["turbo", "run", "build"],
+		&["turbo", "run", "build", "--affected"],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
-                    remote_only: false,
+                    affected: true,
                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
-		} ;
-        "remote_only default to false"
+        } ;
+        "affected"
 	)]
     #[test_case::test_case(
-		&["turbo", "run", "build", "--remote-only"],
+		&["turbo", "run", "build", "--affected", "--affected-base", "origin/release"],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
-                    remote_only: true,
+                    affected: true,
+                    affected_base: Some("origin/release".to_string()),
                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
-		} ;
-        "remote_only with no value, means true"
+        } ;
+        "affected with base"
 	)]
     #[test_case::test_case(
-		&["turbo", "run", "build", "--remote-only", "true"],
+		&["turbo", "run", "build", "--filter", "!docs...[HEAD~5]", "--explain-filter"],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
-                    remote_only: true,
+                    filter: vec!["!docs...[HEAD~5]".to_string()],
+                    explain_filter: true,
                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
-		} ;
-        "remote_only=true works"
+        } ;
+        "explain filter"
 	)]
     #[test_case::test_case(
-		&["turbo", "run", "build", "--remote-only", "false"],
+		&["turbo", "run", "build", "--affected", "--auto-deepen"],
         Args {
             command: Some(Command::Run {
                 execution_args: Box::new(ExecutionArgs {
                     tasks: vec!["build".to_string()],
-                    remote_only: false,
+                    affected: true,
+                    auto_deepen: true,
                     ..get_default_execution_args()
                 }),
                 run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
-		} ;
-        "remote_only=false works"
+        } ;
+        "auto deepen"
 	)]
     #[test_case::test_case(
-		&["turbo", "build"],
+		&["turbo", "run", "dev", "--interactive", "web#dev"],
         Args {
-            execution_args: Some(ExecutionArgs {
-                tasks: vec!["build".to_string()],
-                ..get_default_execution_args()
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["dev".to_string()],
+                    interactive: Some("web#dev".to_string()),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
         } ;
-        "build no run prefix"
-    )]
+        "interactive"
+	)]
     #[test_case::test_case(
-    	&["turbo", "build", "lint", "test"],
+		&["turbo", "run", "build", "--strict-engines"],
         Args {
-            execution_args: Some(ExecutionArgs {
-                tasks: vec!["build".to_string(), "lint".to_string(), "test".to_string()],
-                ..get_default_execution_args()
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    strict_engines: true,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
             }),
             ..Args::default()
         } ;
-        "multiple tasks no run prefix"
-    )]
-    fn test_parse_run(args: &[&str], expected: Args) {
-        assert_eq!(Args::try_parse_from(args).unwrap(), expected);
-    }
-
+        "strict engines"
+	)]
     #[test_case::test_case(
-        &["turbo", "watch", "build"],
+		&["turbo", "run", "build", "--profile-name", "ci"],
         Args {
-            command: Some(Command::Watch(Box::new(ExecutionArgs {
-                tasks: vec!["build".to_string()],
-                ..get_default_execution_args()
-            }))),
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    profile_name: Some("ci".to_string()),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
             ..Args::default()
-        };
-        "default watch"
-    )]
+        } ;
+        "profile name"
+	)]
     #[test_case::test_case(
-        &["turbo", "watch", "build", "--cache-dir", "foobar"],
+		&["turbo", "run", "build", "--log-dir"],
         Args {
-            command: Some(Command::Watch(Box::new(ExecutionArgs {
-                tasks: vec!["build".to_string()],
-                cache_dir: Some(Utf8PathBuf::from("foobar")),
-                ..get_default_execution_args()
-            }))),
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    log_dir: Some(Utf8PathBuf::from(".turbo/logs")),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
             ..Args::default()
-        };
-        "with cache-dir"
-    )]
+        } ;
+        "log dir with no value"
+	)]
     #[test_case::test_case(
-        &["turbo", "watch", "build", "lint", "check"],
+		&["turbo", "run", "--log-dir", "build"],
         Args {
-            command: Some(Command::Watch(Box::new(ExecutionArgs {
-                tasks: vec!["build".to_string(), "lint".to_string(), "check".to_string()],
-                ..get_default_execution_args()
-            }))),
-            ..Args::default()
-        };
-        "with multiple tasks"
-    )]
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    log_dir: Some(Utf8PathBuf::from(".turbo/logs")),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "log dir before task"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--log-dir=ci-logs"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    log_dir: Some(Utf8PathBuf::from("ci-logs")),
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "log dir"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    log_order: LogOrder::Auto,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "just build"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--parallel"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    parallel: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "parallel"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--resume"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    resume: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "resume"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--hash-only"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    hash_only: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "hash only"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--no-restore"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    check_cache_only: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "check cache only"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--env-audit"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    env_audit: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "env audit"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--cache-analytics"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    cache_analytics: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "cache analytics"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--time-budget", "15m", "--time-budget-mode", "warn"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    time_budget: Some(Duration::from_secs(15 * 60)),
+                    time_budget_mode: TimeBudgetMode::Warn,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "time budget"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "dev", "--dependency-mode", "ignore-topology"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["dev".to_string()],
+                    dependency_mode: DependencyMode::IgnoreTopology,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "dependency mode ignore topology"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "dev", "--dependency-mode=only-direct"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["dev".to_string()],
+                    dependency_mode: DependencyMode::OnlyDirect,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+        } ;
+        "dependency mode only direct"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--profile", "profile_out"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                  profile: Some("profile_out".to_string()),
+                  ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        } ;
+        "profile"
+	)]
+    // remote-only flag tests
+    #[test_case::test_case(
+		&["turbo", "run", "build"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    remote_only: false,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+		} ;
+        "remote_only default to false"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--remote-only"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    remote_only: true,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+		} ;
+        "remote_only with no value, means true"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--remote-only", "true"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    remote_only: true,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+		} ;
+        "remote_only=true works"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--remote-only", "false"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    remote_only: false,
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(get_default_run_args())
+            }),
+            ..Args::default()
+		} ;
+        "remote_only=false works"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--remote-cache-read-only"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    remote_cache_read_only: Some(true),
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+		} ;
+        "remote_cache_read_only flag"
+	)]
+    #[test_case::test_case(
+		&["turbo", "run", "build", "--remote-cache-read-only=false"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    remote_cache_read_only: Some(false),
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+		} ;
+        "remote_cache_read_only=false overrides the config"
+	)]
+    #[test_case::test_case(
+		&["turbo", "build"],
+        Args {
+            execution_args: Some(ExecutionArgs {
+                tasks: vec!["build".to_string()],
+                ..get_default_execution_args()
+            }),
+            ..Args::default()
+        } ;
+        "build no run prefix"
+    )]
+    #[test_case::test_case(
+    	&["turbo", "build", "lint", "test"],
+        Args {
+            execution_args: Some(ExecutionArgs {
+                tasks: vec!["build".to_string(), "lint".to_string(), "test".to_string()],
+                ..get_default_execution_args()
+            }),
+            ..Args::default()
+        } ;
+        "multiple tasks no run prefix"
+    )]
+    fn test_parse_run(args: &[&str], expected: Args) {
+        assert_eq!(Args::try_parse_from(args).unwrap(), expected);
+    }
+
+    #[test]
+    fn test_hash_only_conflicts_with_dry_run() {
+        assert!(Args::try_parse_from(["turbo", "build", "--hash-only", "--dry"]).is_err());
+        assert!(
+            Args::try_parse_from(["turbo", "build", "--check-cache-only", "--hash-only"]).is_err()
+        );
+    }
+
+    #[test]
+    fn test_affected_conflicts_with_filter() {
+        assert!(Args::try_parse_from(["turbo", "build", "--affected", "--filter=web"]).is_err());
+    }
+
+    #[test_case::test_case(
+        &["turbo", "watch", "build"],
+        Args {
+            command: Some(Command::Watch(Box::new(ExecutionArgs {
+                tasks: vec!["build".to_string()],
+                ..get_default_execution_args()
+            }))),
+            ..Args::default()
+        };
+        "default watch"
+    )]
+    #[test_case::test_case(
+        &["turbo", "watch", "build", "--cache-dir", "foobar"],
+        Args {
+            command: Some(Command::Watch(Box::new(ExecutionArgs {
+                tasks: vec!["build".to_string()],
+                cache_dir: Some(Utf8PathBuf::from("foobar")),
+                ..get_default_execution_args()
+            }))),
+            ..Args::default()
+        };
+        "with cache-dir"
+    )]
+    #[test_case::test_case(
+        &["turbo", "watch", "build", "lint", "check"],
+        Args {
+            command: Some(Command::Watch(Box::new(ExecutionArgs {
+                tasks: vec!["build".to_string(), "lint".to_string(), "check".to_string()],
+                ..get_default_execution_args()
+            }))),
+            ..Args::default()
+        };
+        "with multiple tasks"
+    )]
+    #[test_case::test_case(
+        &["turbo", "run", "build", "--watch"],
+        Args {
+            command: Some(Command::Run {
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+                run_args: Box::new(RunArgs {
+                    watch: true,
+                    ..get_default_run_args()
+                })
+            }),
+            ..Args::default()
+        };
+        "run with watch flag"
+    )]
     fn test_parse_watch(args: &[&str], expected: Args) {
         assert_eq!(Args::try_parse_from(args).unwrap(), expected);
     }
 
+    #[test_case::test_case(
+        &["turbo", "graph", "build"],
+        Args {
+            command: Some(Command::Graph {
+                format: GraphFormat::Json,
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    ..get_default_execution_args()
+                }),
+            }),
+            ..Args::default()
+        };
+        "default graph"
+    )]
+    #[test_case::test_case(
+        &["turbo", "graph", "build", "--format", "mermaid", "--filter", "web"],
+        Args {
+            command: Some(Command::Graph {
+                format: GraphFormat::Mermaid,
+                execution_args: Box::new(ExecutionArgs {
+                    tasks: vec!["build".to_string()],
+                    filter: vec!["web".to_string()],
+                    ..get_default_execution_args()
+                }),
+            }),
+            ..Args::default()
+        };
+        "with format and filter"
+    )]
+    fn test_parse_graph(args: &[&str], expected: Args) {
+        assert_eq!(Args::try_parse_from(args).unwrap(), expected);
+    }
+
     #[test_case::test_case(
         &["turbo", "run", "build", "--daemon", "--no-daemon"],
         "cannot be used with '--no-daemon'" ;
         "daemon and no-daemon at the same time"
     )]
+    #[test_case::test_case(
+        &["turbo", "run", "build", "--watch", "--dry"],
+        "the argument '--watch' cannot be used with" ;
+        "watch and dry run at the same time"
+    )]
     #[test_case::test_case(
         &["turbo", "run", "build", "--since", "foo"],
         "unexpected argument '--since' found" ;
@@ -2119,6 +3485,50 @@ mod test {
         .test();
     }
 
+    #[test]
+    fn test_parse_cache() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "rm", "abc123", "def456"]).unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    cache_dir: None,
+                    command: CacheCommand::Rm {
+                        hashes: vec!["abc123".to_string(), "def456".to_string()],
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "--cache-dir", "foobar", "verify"]).unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    cache_dir: Some(Utf8PathBuf::from("foobar")),
+                    command: CacheCommand::Verify { hashes: vec![] },
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from(["turbo", "cache", "rm"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_config() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "config", "schema"]).unwrap(),
+            Args {
+                command: Some(Command::Config {
+                    command: ConfigCommand::Schema,
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from(["turbo", "config"]).is_err());
+    }
+
     #[test]
     fn test_parse_login() {
         assert_eq!(
@@ -2126,7 +3536,8 @@ mod test {
             Args {
                 command: Some(Command::Login {
                     sso_team: None,
-                    force: false
+                    force: false,
+                    device: false,
                 }),
                 ..Args::default()
             }
@@ -2140,6 +3551,7 @@ mod test {
                 command: Some(Command::Login {
                     sso_team: None,
                     force: false,
+                    device: false,
                 }),
                 cwd: Some(Utf8PathBuf::from("../examples/with-yarn")),
                 ..Args::default()
@@ -2155,6 +3567,23 @@ mod test {
                 command: Some(Command::Login {
                     sso_team: Some("my-team".to_string()),
                     force: false,
+                    device: false,
+                }),
+                cwd: Some(Utf8PathBuf::from("../examples/with-yarn")),
+                ..Args::default()
+            },
+        }
+        .test();
+
+        CommandTestCase {
+            command: "login",
+            command_args: vec![vec!["--device"], vec!["--sso-team", "my-team"]],
+            global_args: vec![vec!["--cwd", "../examples/with-yarn"]],
+            expected_output: Args {
+                command: Some(Command::Login {
+                    sso_team: Some("my-team".to_string()),
+                    force: false,
+                    device: true,
                 }),
                 cwd: Some(Utf8PathBuf::from("../examples/with-yarn")),
                 ..Args::default()
@@ -2186,13 +3615,136 @@ mod test {
         .test();
     }
 
+    #[test]
+    fn test_parse_diff() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "diff", "a.json", "b.json", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Diff {
+                    before: "a.json".to_string(),
+                    after: "b.json".to_string(),
+                    json: true
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "diff", "a.json"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_exec_plan() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "exec-plan", "-", "--filter=web", "--continue"])
+                .unwrap(),
+            Args {
+                command: Some(Command::ExecPlan {
+                    plan: "-".to_string(),
+                    run_args: Box::new(get_default_run_args()),
+                    execution_args: Box::new(ExecutionArgs {
+                        filter: vec!["web".to_string()],
+                        continue_execution: ContinueMode::Always,
+                        ..get_default_execution_args()
+                    }),
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "exec-plan"]).is_err());
+    }
+
+    #[test_case::test_case(
+        &["turbo", "build"],
+        ErrorFormat::Human ;
+        "default"
+    )]
+    #[test_case::test_case(
+        &["turbo", "build", "--error-format=json"],
+        ErrorFormat::Json ;
+        "equals"
+    )]
+    #[test_case::test_case(
+        &["turbo", "--error-format", "json", "build"],
+        ErrorFormat::Json ;
+        "separate value"
+    )]
+    #[test_case::test_case(
+        &["turbo", "--error-format=json", "--error-format=human"],
+        ErrorFormat::Human ;
+        "last wins"
+    )]
+    #[test_case::test_case(
+        &["turbo", "build", "--", "--error-format=json"],
+        ErrorFormat::Human ;
+        "task args"
+    )]
+    fn test_error_format_from_args(args: &[&str], expected: ErrorFormat) {
+        assert_eq!(
+            ErrorFormat::from_args(args.iter().map(|arg| arg.to_string())),
+            expected
+        );
+    }
+
+    #[test]
+    fn test_parse_lint_config() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "lint-config", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::LintConfig { json: true }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_ls() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "ls"]).unwrap(),
+            Args {
+                command: Some(Command::Ls {
+                    filter: vec![],
+                    json: false
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "ls", "-F", "web...", "--filter", "docs", "--json"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Ls {
+                    filter: vec!["web...".to_string(), "docs".to_string()],
+                    json: true
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_remote_cache() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "remote-cache", "verify"]).unwrap(),
+            Args {
+                command: Some(Command::RemoteCache {
+                    command: RemoteCacheCommand::Verify
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from(["turbo", "remote-cache"]).is_err());
+    }
+
     #[test]
     fn test_parse_unlink() {
         assert_eq!(
             Args::try_parse_from(["turbo", "unlink"]).unwrap(),
             Args {
                 command: Some(Command::Unlink {
-                    target: crate::cli::LinkTarget::RemoteCache
+                    target: crate::cli::LinkTarget::RemoteCache,
+                    all: false,
+                    profile: None,
                 }),
                 ..Args::default()
             }
@@ -2205,12 +3757,48 @@ mod test {
             expected_output: Args {
                 command: Some(Command::Unlink {
                     target: crate::cli::LinkTarget::RemoteCache,
+                    all: false,
+                    profile: None,
                 }),
                 cwd: Some(Utf8PathBuf::from("../examples/with-yarn")),
                 ..Args::default()
             },
         }
         .test();
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "unlink", "--all"]).unwrap(),
+            Args {
+                command: Some(Command::Unlink {
+                    target: crate::cli::LinkTarget::RemoteCache,
+                    all: true,
+                    profile: None,
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "unlink", "--all", "--profile", "work"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_link_profile() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "link", "--profile", "work"]).unwrap(),
+            Args {
+                command: Some(Command::Link {
+                    no_gitignore: false,
+                    target: crate::cli::LinkTarget::RemoteCache,
+                    profile: Some("work".to_string()),
+                    list_profiles: false,
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "link", "--profile", ""]).is_err());
+        assert!(
+            Args::try_parse_from(["turbo", "link", "--list-profiles", "--profile", "work"])
+                .is_err()
+        );
     }
 
     #[test]
@@ -2219,7 +3807,9 @@ mod test {
             scope: None,
             scope_arg: Some(vec!["foo".into()]),
             docker: false,
+            emit_dockerfile: false,
             output_dir: "out".to_string(),
+            include: vec![],
         };
 
         assert_eq!(
@@ -2249,7 +3839,24 @@ mod test {
                     scope: Some(vec!["bar".to_string()]),
                     scope_arg: None,
                     docker: false,
+                    emit_dockerfile: false,
+                    output_dir: "out".to_string(),
+                    include: vec![],
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "prune", "--scope", "foo", "--scope", "bar"]).unwrap(),
+            Args {
+                command: Some(Command::Prune {
+                    scope: Some(vec!["foo".to_string(), "bar".to_string()]),
+                    scope_arg: None,
+                    docker: false,
+                    emit_dockerfile: false,
                     output_dir: "out".to_string(),
+                    include: vec![],
                 }),
                 ..Args::default()
             }
@@ -2262,7 +3869,9 @@ mod test {
                     scope: None,
                     scope_arg: Some(vec!["foo".to_string(), "bar".to_string()]),
                     docker: false,
+                    emit_dockerfile: false,
                     output_dir: "out".to_string(),
+                    include: vec![],
                 }),
                 ..Args::default()
             }
@@ -2275,12 +3884,33 @@ mod test {
                     scope: None,
                     scope_arg: Some(vec!["foo".into()]),
                     docker: true,
+                    emit_dockerfile: false,
+                    output_dir: "out".to_string(),
+                    include: vec![],
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "prune", "--docker", "--emit-dockerfile", "foo"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Prune {
+                    scope: None,
+                    scope_arg: Some(vec!["foo".into()]),
+                    docker: true,
+                    emit_dockerfile: true,
                     output_dir: "out".to_string(),
+                    include: vec![],
                 }),
                 ..Args::default()
             }
         );
 
+        // The Dockerfile relies on the json and full directories
+        assert!(Args::try_parse_from(["turbo", "prune", "--emit-dockerfile", "foo"]).is_err());
+
         assert_eq!(
             Args::try_parse_from(["turbo", "prune", "--out-dir", "dist", "foo"]).unwrap(),
             Args {
@@ -2288,7 +3918,33 @@ mod test {
                     scope: None,
                     scope_arg: Some(vec!["foo".into()]),
                     docker: false,
+                    emit_dockerfile: false,
                     output_dir: "dist".to_string(),
+                    include: vec![],
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "prune",
+                "--include",
+                ".npmrc",
+                "--include",
+                "patches/**",
+                "foo"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Prune {
+                    scope: None,
+                    scope_arg: Some(vec!["foo".into()]),
+                    docker: false,
+                    emit_dockerfile: false,
+                    output_dir: "out".to_string(),
+                    include: vec![".npmrc".to_string(), "patches/**".to_string()],
                 }),
                 ..Args::default()
             }
@@ -2303,7 +3959,9 @@ mod test {
                     scope: None,
                     scope_arg: Some(vec!["foo".into()]),
                     docker: true,
+                    emit_dockerfile: false,
                     output_dir: "dist".to_string(),
+                    include: vec![],
                 }),
                 ..Args::default()
             },
@@ -2319,7 +3977,9 @@ mod test {
                     scope: None,
                     scope_arg: Some(vec!["foo".into()]),
                     docker: true,
+                    emit_dockerfile: false,
                     output_dir: "dist".to_string(),
+                    include: vec![],
                 }),
                 cwd: Some(Utf8PathBuf::from("../examples/with-yarn")),
                 ..Args::default()
@@ -2340,7 +4000,9 @@ mod test {
                     scope: Some(vec!["foo".to_string()]),
                     scope_arg: None,
                     docker: true,
+                    emit_dockerfile: false,
                     output_dir: "dist".to_string(),
+                    include: vec![],
                 }),
                 ..Args::default()
             },
@@ -2498,6 +4160,81 @@ mod test {
         assert!(Args::try_parse_from(["turbo", "build", "--cache-dir", ""]).is_err());
     }
 
+    #[test_case::test_case("1024", Ok(1024) ; "bare bytes")]
+    #[test_case::test_case("500MB", Ok(500 * 1024 * 1024) ; "megabytes")]
+    #[test_case::test_case("10gb", Ok(10 * 1024 * 1024 * 1024) ; "lowercase gigabytes")]
+    #[test_case::test_case("1.5 GB", Ok(3 * 512 * 1024 * 1024) ; "fractional with space")]
+    #[test_case::test_case("10XB", Err(()) ; "unknown unit")]
+    #[test_case::test_case("0GB", Err(()) ; "zero")]
+    #[test_case::test_case("GB", Err(()) ; "missing amount")]
+    fn test_parse_byte_size(input: &str, expected: Result<u64, ()>) {
+        assert_eq!(super::parse_byte_size(input).map_err(|_| ()), expected);
+    }
+
+    #[test]
+    fn test_cache_max_size() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "build", "--cache-max-size", "10GB"])
+                .unwrap()
+                .execution_args
+                .unwrap()
+                .cache_max_size,
+            Some(10 * 1024 * 1024 * 1024)
+        );
+        assert!(Args::try_parse_from(["turbo", "build", "--cache-max-size", "lots"]).is_err());
+    }
+
+    #[test]
+    fn test_shutdown_grace_period() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "build"])
+                .unwrap()
+                .execution_args
+                .unwrap()
+                .shutdown_grace_period,
+            None
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "build", "--shutdown-grace-period", "5000"])
+                .unwrap()
+                .execution_args
+                .unwrap()
+                .shutdown_grace_period,
+            Some(5000)
+        );
+        assert!(Args::try_parse_from(["turbo", "build", "--shutdown-grace-period", "5s"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_force() {
+        assert_eq!(parse_force("true"), Ok(Force::All));
+        assert_eq!(parse_force("1"), Ok(Force::All));
+        assert_eq!(parse_force("false"), Ok(Force::None));
+        assert_eq!(parse_force("0"), Ok(Force::None));
+        assert_eq!(
+            parse_force("web#build,lint"),
+            Ok(Force::Tasks(vec![
+                "web#build".to_string(),
+                "lint".to_string()
+            ]))
+        );
+        assert!(parse_force(" , ").is_err());
+    }
+
+    #[test]
+    fn test_task_timeout() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "build", "--task-timeout", "1h 30m"])
+                .unwrap()
+                .execution_args
+                .unwrap()
+                .task_timeout,
+            Some(Duration::from_secs(90 * 60))
+        );
+        assert!(Args::try_parse_from(["turbo", "build", "--task-timeout", "0s"]).is_err());
+        assert!(Args::try_parse_from(["turbo", "build", "--task-timeout", "soon"]).is_err());
+    }
+
     #[test]
     fn test_preflight() {
         assert!(!Args::try_parse_from(["turbo", "build",]).unwrap().preflight);
@@ -2509,6 +4246,59 @@ mod test {
         assert!(Args::try_parse_from(["turbo", "build", "--preflight=true"]).is_err());
     }
 
+    #[test]
+    fn test_daemon_watcher() {
+        assert_matches!(
+            Args::try_parse_from(["turbo", "daemon"]).unwrap().command,
+            Some(Command::Daemon {
+                watcher: DaemonWatcher::Native,
+                ..
+            })
+        );
+        assert_matches!(
+            Args::try_parse_from(["turbo", "daemon", "--watcher", "polling"])
+                .unwrap()
+                .command,
+            Some(Command::Daemon {
+                watcher: DaemonWatcher::Polling,
+                ..
+            })
+        );
+        assert!(Args::try_parse_from(["turbo", "daemon", "--watcher", "inotify"]).is_err());
+    }
+
+    #[test]
+    fn test_tls_args() {
+        let args = Args::try_parse_from(["turbo", "build"]).unwrap();
+        assert_eq!(args.cacert, None);
+        assert!(!args.insecure_skip_tls_verify);
+
+        let args = Args::try_parse_from([
+            "turbo",
+            "build",
+            "--cacert",
+            "corp-ca.pem",
+            "--insecure-skip-tls-verify",
+        ])
+        .unwrap();
+        assert_eq!(args.cacert, Some(Utf8PathBuf::from("corp-ca.pem")));
+        assert!(args.insecure_skip_tls_verify);
+    }
+
+    #[test]
+    fn test_experimental_remote_cache_signature() {
+        assert!(
+            !Args::try_parse_from(["turbo", "build"])
+                .unwrap()
+                .experimental_remote_cache_signature
+        );
+        assert!(
+            Args::try_parse_from(["turbo", "build", "--experimental-remote-cache-signature"])
+                .unwrap()
+                .experimental_remote_cache_signature
+        );
+    }
+
     #[test]
     fn test_log_stream_tui_compatibility() {
         assert!(LogOrder::Auto.compatible_with_tui());
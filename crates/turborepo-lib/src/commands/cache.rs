@@ -0,0 +1,355 @@
+//! `turbo cache` lists, inspects and removes the artifacts in the local
+//! filesystem cache.
+
+use std::{
+    io::{self, Write},
+    time::{Duration, SystemTime},
+};
+
+use camino::Utf8Path;
+use chrono::{DateTime, Utc};
+use miette::Diagnostic;
+use serde::Serialize;
+use tabwriter::TabWriter;
+use thiserror::Error;
+use turbopath::AbsoluteSystemPathBuf;
+use turborepo_cache::{
+    cache_archive::{ArtifactContents, ArtifactFile, ArtifactFileKind, CacheReader},
+    fs::{FSCache, LocalArtifact},
+    CacheError,
+};
+use turborepo_ui::{color, BOLD, BOLD_GREEN, BOLD_RED, GREY, UI};
+
+use super::CommandBase;
+use crate::{cli::CacheCommand, config};
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error("no artifact found for {0} in the local cache")]
+    NotFound(String),
+    #[error(transparent)]
+    Cache(#[from] CacheError),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Config(#[from] config::Error),
+    #[error(transparent)]
+    Io(#[from] io::Error),
+    #[error(transparent)]
+    SerdeJson(#[from] serde_json::Error),
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ArtifactSummary {
+    hash: String,
+    path: AbsoluteSystemPathBuf,
+    size: u64,
+    last_used: DateTime<Utc>,
+    time_saved_ms: Option<u64>,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(tag = "status", rename_all = "camelCase")]
+enum Verification {
+    Verified,
+    Corrupted {
+        path: String,
+    },
+    Unreadable {
+        error: String,
+    },
+    /// Artifacts written by older versions of turbo have no manifest
+    Unverified,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ArtifactDetails<'a> {
+    #[serde(flatten)]
+    artifact: ArtifactSummary,
+    verification: Verification,
+    files: &'a [ArtifactFile],
+}
+
+impl From<LocalArtifact> for ArtifactSummary {
+    fn from(artifact: LocalArtifact) -> Self {
+        Self {
+            hash: artifact.hash,
+            path: artifact.path,
+            size: artifact.size,
+            last_used: artifact.last_used.into(),
+            time_saved_ms: artifact.time_saved,
+        }
+    }
+}
+
+impl From<&ArtifactContents> for Verification {
+    fn from(contents: &ArtifactContents) -> Self {
+        if !contents.has_manifest() {
+            return Verification::Unverified;
+        }
+        match contents.verify() {
+            Ok(()) => Verification::Verified,
+            Err(CacheError::IntegrityMismatch(path, _)) => Verification::Corrupted { path },
+            Err(e) => Verification::Unreadable {
+                error: e.to_string(),
+            },
+        }
+    }
+}
+
+/// Runs a `turbo cache` subcommand and returns the exit code.
+pub fn run(
+    base: &CommandBase,
+    cache_dir: Option<&Utf8Path>,
+    command: &CacheCommand,
+) -> Result<i32, Error> {
+    let cache_dir = cache_dir.or(base.config()?.cache_dir());
+    let cache = FSCache::new(cache_dir, &base.repo_root, None, None, 0, false)?;
+    let ui = base.ui;
+
+    match command {
+        CacheCommand::Ls { json } => {
+            let artifacts = cache
+                .artifacts()?
+                .into_iter()
+                .map(ArtifactSummary::from)
+                .collect::<Vec<_>>();
+            if *json {
+                println!("{}", serde_json::to_string_pretty(&artifacts)?);
+            } else {
+                print_artifacts(ui, &artifacts)?;
+            }
+            Ok(0)
+        }
+        CacheCommand::Show { hash, json } => {
+            let artifact = cache
+                .artifact(hash)?
+                .ok_or_else(|| Error::NotFound(hash.clone()))?;
+            let contents = CacheReader::open(&artifact.path)?.inspect()?;
+            let details = ArtifactDetails {
+                artifact: artifact.into(),
+                verification: Verification::from(&contents),
+                files: contents.files(),
+            };
+            if *json {
+                println!("{}", serde_json::to_string_pretty(&details)?);
+            } else {
+                print_details(ui, &details)?;
+            }
+            Ok(0)
+        }
+        CacheCommand::Verify { hashes } => {
+            let artifacts = if hashes.is_empty() {
+                cache.artifacts()?
+            } else {
+                hashes
+                    .iter()
+                    .map(|hash| {
+                        cache
+                            .artifact(hash)?
+                            .ok_or_else(|| Error::NotFound(hash.clone()))
+                    })
+                    .collect::<Result<Vec<_>, _>>()?
+            };
+
+            let mut corrupted = 0;
+            for artifact in &artifacts {
+                let verification = CacheReader::open(&artifact.path)
+                    .and_then(|mut reader| reader.inspect())
+                    .map_or_else(
+                        |e| Verification::Unreadable {
+                            error: e.to_string(),
+                        },
+                        |contents| Verification::from(&contents),
+                    );
+                match verification {
+                    Verification::Verified => {
+                        println!("{} {}", color!(ui, BOLD_GREEN, "✓"), artifact.hash)
+                    }
+                    Verification::Unverified => println!(
+                        "{} {} {}",
+                        color!(ui, GREY, "-"),
+                        artifact.hash,
+                        color!(ui, GREY, "(no manifest)")
+                    ),
+                    Verification::Corrupted { path } => {
+                        corrupted += 1;
+                        println!(
+                            "{} {} {}",
+                            color!(ui, BOLD_RED, "x"),
+                            artifact.hash,
+                            color!(ui, GREY, "({path} does not match the manifest)")
+                        );
+                    }
+                    Verification::Unreadable { error } => {
+                        corrupted += 1;
+                        println!(
+                            "{} {} {}",
+                            color!(ui, BOLD_RED, "x"),
+                            artifact.hash,
+                            color!(ui, GREY, "({error})")
+                        );
+                    }
+                }
+            }
+
+            if corrupted > 0 {
+                println!(
+                    "\n{corrupted} of {} artifacts are corrupted, remove them with `turbo cache \
+                     rm <hash>`",
+                    artifacts.len()
+                );
+                return Ok(1);
+            }
+            Ok(0)
+        }
+        CacheCommand::Rm { hashes } => {
+            let mut exit_code = 0;
+            for hash in hashes {
+                if cache.remove_artifact(hash)? {
+                    println!("{} removed {hash}", color!(ui, BOLD_GREEN, "✓"));
+                } else {
+                    println!(
+                        "{} {}",
+                        color!(ui, BOLD_RED, "x"),
+                        Error::NotFound(hash.clone())
+                    );
+                    exit_code = 1;
+                }
+            }
+            Ok(exit_code)
+        }
+    }
+}
+
+fn print_artifacts(ui: UI, artifacts: &[ArtifactSummary]) -> Result<(), Error> {
+    if artifacts.is_empty() {
+        println!("The local cache is empty");
+        return Ok(());
+    }
+
+    let mut tab_writer = TabWriter::new(io::stdout()).minwidth(0).padding(2);
+    writeln!(tab_writer, "Hash\tSize\tLast used\tTime saved")?;
+    for artifact in artifacts {
+        writeln!(
+            tab_writer,
+            "{}\t{}\t{}\t{}",
+            artifact.hash,
+            format_size(artifact.size),
+            format_last_used(artifact.last_used),
+            format_time_saved(artifact.time_saved_ms),
+        )?;
+    }
+    tab_writer.flush()?;
+
+    let total: u64 = artifacts.iter().map(|artifact| artifact.size).sum();
+    println!(
+        "\n{} artifacts, {}",
+        artifacts.len(),
+        color!(ui, GREY, "{}", format_size(total))
+    );
+    Ok(())
+}
+
+fn print_details(ui: UI, details: &ArtifactDetails) -> Result<(), Error> {
+    let artifact = &details.artifact;
+    println!("{}", color!(ui, BOLD, "{}", artifact.hash));
+    println!("path: {}", color!(ui, GREY, "{}", artifact.path));
+    println!(
+        "size: {}",
+        color!(ui, GREY, "{}", format_size(artifact.size))
+    );
+    println!(
+        "last used: {}",
+        color!(ui, GREY, "{}", format_last_used(artifact.last_used))
+    );
+    println!(
+        "time saved: {}",
+        color!(ui, GREY, "{}", format_time_saved(artifact.time_saved_ms))
+    );
+    let verification = match &details.verification {
+        Verification::Verified => color!(ui, BOLD_GREEN, "verified").to_string(),
+        Verification::Corrupted { path } => color!(
+            ui,
+            BOLD_RED,
+            "corrupted ({path} does not match the manifest)"
+        )
+        .to_string(),
+        Verification::Unreadable { error } => {
+            color!(ui, BOLD_RED, "unreadable ({error})").to_string()
+        }
+        Verification::Unverified => color!(ui, GREY, "no manifest").to_string(),
+    };
+    println!("integrity: {verification}");
+
+    println!("\n{}", color!(ui, BOLD, "Files"));
+    let mut tab_writer = TabWriter::new(io::stdout()).minwidth(0).padding(2);
+    for file in details.files {
+        let kind = match file.kind {
+            ArtifactFileKind::File => "file",
+            ArtifactFileKind::Directory => "directory",
+            ArtifactFileKind::Symlink => "symlink",
+        };
+        writeln!(
+            tab_writer,
+            "  {}\t{}\t{}",
+            file.path,
+            kind,
+            format_size(file.size)
+        )?;
+    }
+    tab_writer.flush()?;
+    Ok(())
+}
+
+fn format_size(bytes: u64) -> String {
+    const UNITS: [&str; 4] = ["KB", "MB", "GB", "TB"];
+    if bytes < 1024 {
+        return format!("{bytes}B");
+    }
+    let mut size = bytes as f64;
+    let mut unit = "B";
+    for next in UNITS {
+        if size < 1024.0 {
+            break;
+        }
+        size /= 1024.0;
+        unit = next;
+    }
+    format!("{size:.1}{unit}")
+}
+
+fn format_last_used(last_used: DateTime<Utc>) -> String {
+    let elapsed = SystemTime::from(last_used)
+        .elapsed()
+        .unwrap_or_default()
+        .as_secs();
+    format!(
+        "{} ago",
+        humantime::format_duration(Duration::from_secs(elapsed))
+    )
+}
+
+fn format_time_saved(time_saved_ms: Option<u64>) -> String {
+    match time_saved_ms {
+        Some(ms) => humantime::format_duration(Duration::from_millis(ms)).to_string(),
+        None => "-".to_string(),
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+
+    use super::format_size;
+
+    #[test_case(0, "0B" ; "empty")]
+    #[test_case(1023, "1023B" ; "bytes")]
+    #[test_case(1536, "1.5KB" ; "kilobytes")]
+    #[test_case(10 * 1024 * 1024, "10.0MB" ; "megabytes")]
+    #[test_case(3 * 1024 * 1024 * 1024, "3.0GB" ; "gigabytes")]
+    fn test_format_size(bytes: u64, expected: &str) {
+        assert_eq!(format_size(bytes), expected);
+    }
+}
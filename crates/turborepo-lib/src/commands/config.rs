@@ -0,0 +1,9 @@
+//! `turbo config` inspects the configuration of turbo.
+
+use crate::{cli::ConfigCommand, turbo_json::schema};
+
+pub fn run(command: &ConfigCommand) {
+    match command {
+        ConfigCommand::Schema => println!("{:#}", schema::schema()),
+    }
+}
@@ -13,7 +13,7 @@ use which::which;
 
 use super::CommandBase;
 use crate::{
-    cli::DaemonCommand,
+    cli::{DaemonCommand, DaemonWatcher},
     daemon::{
         endpoint::SocketOpenError, CloseReason, DaemonConnector, DaemonConnectorError, DaemonError,
         Paths,
@@ -45,7 +45,19 @@ pub async fn daemon_client(command: &DaemonCommand, base: &CommandBase) -> Resul
             if let Err(e) = result {
                 tracing::debug!("failed to restart the daemon: {:?}", e);
                 tracing::debug!("falling back to clean");
-                clean(&connector.paths.pid_file, &connector.paths.sock_file)?;
+                match connector.kill_unresponsive_server().await {
+                    // Another process just started a daemon, so its files are kept
+                    // and we connect to it instead
+                    Err(DaemonConnectorError::Starting(pid)) => {
+                        tracing::debug!("daemon {} was just started, not cleaning", pid);
+                    }
+                    result => {
+                        if let Err(e) = result {
+                            tracing::debug!("unable to kill the daemon: {:?}", e);
+                        }
+                        clean(&connector.paths.pid_file, &connector.paths.sock_file)?;
+                    }
+                }
                 tracing::debug!("connecting for second time");
                 let _ = connector.connect().await?;
             }
@@ -95,16 +107,27 @@ pub async fn daemon_client(command: &DaemonCommand, base: &CommandBase) -> Resul
             let log_file = log_filename(&status.log_file)?;
             let paths = client.paths();
             let status = DaemonStatus {
+                pid: status.pid,
                 uptime_ms: status.uptime_msec,
+                repo_root: status.repo_root.into(),
                 log_file: log_file.into(),
                 pid_file: paths.pid_file.to_owned(),
                 sock_file: paths.sock_file.to_owned(),
+                metrics_file: paths.metrics_file.to_owned(),
+                tracked_files: status.tracked_files,
+                pending_hashes: status.pending_hashes,
+                last_error: status.last_error,
             };
 
             if *json {
                 println!("{}", serde_json::to_string_pretty(&status)?);
             } else {
                 println!("{} daemon is running", color!(base.ui, BOLD_GREEN, "✓"));
+                println!("pid: {}", color!(base.ui, GREY, "{}", status.pid));
+                println!(
+                    "watching: {}",
+                    color!(base.ui, GREY, "{}", status.repo_root)
+                );
                 println!("log file: {}", color!(base.ui, GREY, "{}", status.log_file));
                 println!(
                     "uptime: {}",
@@ -120,6 +143,29 @@ pub async fn daemon_client(command: &DaemonCommand, base: &CommandBase) -> Resul
                     "socket file: {}",
                     color!(base.ui, GREY, "{}", status.sock_file)
                 );
+                println!(
+                    "metrics file: {}",
+                    color!(base.ui, GREY, "{}", status.metrics_file)
+                );
+                match (status.tracked_files, status.pending_hashes) {
+                    (Some(tracked_files), Some(pending_hashes)) => {
+                        println!(
+                            "tracked files: {}",
+                            color!(base.ui, GREY, "{}", tracked_files)
+                        );
+                        println!(
+                            "pending hashes: {}",
+                            color!(base.ui, GREY, "{}", pending_hashes)
+                        );
+                    }
+                    _ => println!("file hashing: {}", color!(base.ui, GREY, "unavailable")),
+                }
+                if let Some(last_error) = &status.last_error {
+                    println!(
+                        "last error: {}",
+                        color!(base.ui, BOLD_RED, "{}", last_error)
+                    );
+                }
             }
         }
         DaemonCommand::Logs => {
@@ -142,18 +188,43 @@ pub async fn daemon_client(command: &DaemonCommand, base: &CommandBase) -> Resul
         } => {
             // try to connect and shutdown the daemon
             let paths = connector.paths.clone();
-            let client = connector.connect().await;
-            match client {
+            let client = connector.clone().connect().await;
+            let stopped = match client {
                 Ok(client) => match client.stop().await {
                     Ok(_) => {
                         tracing::trace!("successfully stopped the daemon");
+                        true
                     }
                     Err(e) => {
                         tracing::trace!("unable to stop the daemon: {:?}", e);
+                        false
                     }
                 },
                 Err(e) => {
                     tracing::trace!("unable to connect to the daemon: {:?}", e);
+                    false
+                }
+            };
+            // a daemon that doesn't respond would otherwise keep running after its
+            // files are removed
+            if !stopped {
+                match connector.kill_unresponsive_server().await {
+                    // A daemon that was just started gets another chance to stop
+                    // once it's listening
+                    Err(DaemonConnectorError::Starting(_)) => {
+                        match connector.clone().connect().await {
+                            Ok(client) => {
+                                if let Err(e) = client.stop().await {
+                                    tracing::trace!("unable to stop the daemon: {:?}", e);
+                                }
+                            }
+                            Err(e) => {
+                                tracing::trace!("unable to connect to the daemon: {:?}", e);
+                            }
+                        }
+                    }
+                    Err(e) => tracing::trace!("unable to kill the daemon: {:?}", e),
+                    Ok(()) => {}
                 }
             }
             clean(&paths.pid_file, &paths.sock_file)?;
@@ -254,6 +325,7 @@ fn log_filename(base_filename: &str) -> Result<String, time::Error> {
 pub async fn daemon_server(
     base: &CommandBase,
     idle_time: &String,
+    watcher: DaemonWatcher,
     logging: &TurboSubscriber,
 ) -> Result<(), DaemonError> {
     let paths = Paths::from_repo_root(&base.repo_root);
@@ -278,7 +350,8 @@ pub async fn daemon_server(
         CloseReason::Interrupt
     });
     let server =
-        crate::daemon::TurboGrpcService::new(base.repo_root.clone(), paths, timeout, exit_signal);
+        crate::daemon::TurboGrpcService::new(base.repo_root.clone(), paths, timeout, exit_signal)
+            .with_watcher(watcher);
 
     let reason = server.serve().await?;
 
@@ -302,10 +375,17 @@ pub async fn daemon_server(
 
 #[derive(serde::Serialize)]
 pub struct DaemonStatus {
+    pub pid: u32,
     pub uptime_ms: u64,
-    // this comes from the daemon server, so we trust that
-    // it is correct
+    // these come from the daemon server, so we trust that
+    // they are correct
+    pub repo_root: Utf8PathBuf,
     pub log_file: Utf8PathBuf,
     pub pid_file: turbopath::AbsoluteSystemPathBuf,
     pub sock_file: turbopath::AbsoluteSystemPathBuf,
+    pub metrics_file: turbopath::AbsoluteSystemPathBuf,
+    // None if the daemon isn't able to hash files yet
+    pub tracked_files: Option<u64>,
+    pub pending_hashes: Option<u64>,
+    pub last_error: Option<String>,
 }
@@ -0,0 +1,546 @@
+//! `turbo diff` compares the tasks of two run summaries, written by
+//! `--summarize` or `--dry=json`, and explains why their hashes differ.
+
+use std::{
+    collections::{BTreeMap, BTreeSet},
+    io,
+};
+
+use miette::Diagnostic;
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+use turbopath::{AbsoluteSystemPathBuf, PathError};
+use turborepo_ui::{color, BOLD, GREY, UI};
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error("unable to read run summary {path}: {source}")]
+    Read {
+        path: AbsoluteSystemPathBuf,
+        #[source]
+        source: io::Error,
+    },
+    #[error("{path} isn't a run summary: {source}")]
+    #[diagnostic(help("pass files written by `turbo run --summarize` or `turbo run --dry=json`"))]
+    Parse {
+        path: AbsoluteSystemPathBuf,
+        #[source]
+        source: serde_json::Error,
+    },
+    #[error(transparent)]
+    Path(#[from] PathError),
+    #[error(transparent)]
+    SerdeJson(#[from] serde_json::Error),
+}
+
+// Only the parts of a run summary that contribute to task hashes
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct RunSummary {
+    #[serde(rename = "globalCacheInputs", default)]
+    global: GlobalInputs,
+    tasks: Vec<TaskSummary>,
+}
+
+#[derive(Debug, Default, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct GlobalInputs {
+    #[serde(default)]
+    files: BTreeMap<String, String>,
+    #[serde(default)]
+    hash_of_external_dependencies: String,
+    #[serde(default)]
+    environment_variables: EnvironmentVariables,
+}
+
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct TaskSummary {
+    task_id: String,
+    hash: String,
+    #[serde(default)]
+    inputs: BTreeMap<String, String>,
+    #[serde(default)]
+    hash_of_external_dependencies: String,
+    #[serde(default)]
+    dependencies: Vec<String>,
+    #[serde(default)]
+    cli_arguments: Vec<String>,
+    #[serde(default)]
+    env_mode: serde_json::Value,
+    #[serde(default)]
+    resolved_task_definition: serde_json::Map<String, serde_json::Value>,
+    #[serde(default)]
+    environment_variables: EnvironmentVariables,
+}
+
+// Environment variables are listed as `NAME=<hash of value>`
+#[derive(Debug, Default, Deserialize)]
+struct EnvironmentVariables {
+    #[serde(default)]
+    configured: Option<Vec<String>>,
+    #[serde(default)]
+    inferred: Option<Vec<String>>,
+    #[serde(default)]
+    passthrough: Option<Vec<String>>,
+}
+
+impl EnvironmentVariables {
+    fn hashes(&self) -> BTreeMap<&str, &str> {
+        [&self.configured, &self.inferred, &self.passthrough]
+            .into_iter()
+            .flatten()
+            .flatten()
+            .map(|pair| pair.split_once('=').unwrap_or((pair.as_str(), "")))
+            .collect()
+    }
+}
+
+/// The files, keyed by path, whose hashes differ between two summaries
+#[derive(Debug, Default, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct FileChanges {
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    changed: Vec<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    added: Vec<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    removed: Vec<String>,
+}
+
+impl FileChanges {
+    fn new(before: &BTreeMap<String, String>, after: &BTreeMap<String, String>) -> Self {
+        let mut changes = FileChanges::default();
+        for (path, hash) in after {
+            match before.get(path) {
+                Some(before_hash) if before_hash != hash => changes.changed.push(path.clone()),
+                Some(_) => (),
+                None => changes.added.push(path.clone()),
+            }
+        }
+        changes.removed = before
+            .keys()
+            .filter(|path| !after.contains_key(*path))
+            .cloned()
+            .collect();
+        changes
+    }
+
+    fn is_empty(&self) -> bool {
+        self.changed.is_empty() && self.added.is_empty() && self.removed.is_empty()
+    }
+}
+
+fn changed_env_vars(before: &EnvironmentVariables, after: &EnvironmentVariables) -> Vec<String> {
+    let before = before.hashes();
+    let after = after.hashes();
+    before
+        .keys()
+        .chain(after.keys())
+        .collect::<BTreeSet<_>>()
+        .into_iter()
+        .filter(|name| before.get(**name) != after.get(**name))
+        .map(|name| name.to_string())
+        .collect()
+}
+
+#[derive(Debug, Default, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct GlobalDiff {
+    #[serde(skip_serializing_if = "FileChanges::is_empty")]
+    files: FileChanges,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    environment_variables: Vec<String>,
+    external_dependencies: bool,
+}
+
+impl GlobalDiff {
+    fn is_empty(&self) -> bool {
+        self.files.is_empty()
+            && self.environment_variables.is_empty()
+            && !self.external_dependencies
+    }
+}
+
+#[derive(Debug, Default, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct TaskDiff {
+    task_id: String,
+    /// The hash of the task in the first summary, if it was part of that run
+    before: Option<String>,
+    /// The hash of the task in the second summary, if it was part of that run
+    after: Option<String>,
+    #[serde(skip_serializing_if = "FileChanges::is_empty")]
+    files: FileChanges,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    environment_variables: Vec<String>,
+    /// Dependencies whose hash changed, or that were added or removed
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    dependencies: Vec<String>,
+    /// Keys of the resolved task definition that changed
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    task_definition: Vec<String>,
+    external_dependencies: bool,
+    cli_arguments: bool,
+    env_mode: bool,
+}
+
+#[derive(Debug, Default, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct SummaryDiff {
+    global: GlobalDiff,
+    tasks: Vec<TaskDiff>,
+    unchanged: usize,
+}
+
+fn diff_summaries(before: &RunSummary, after: &RunSummary) -> SummaryDiff {
+    let global = GlobalDiff {
+        files: FileChanges::new(&before.global.files, &after.global.files),
+        environment_variables: changed_env_vars(
+            &before.global.environment_variables,
+            &after.global.environment_variables,
+        ),
+        external_dependencies: before.global.hash_of_external_dependencies
+            != after.global.hash_of_external_dependencies,
+    };
+
+    let before_tasks = before
+        .tasks
+        .iter()
+        .map(|task| (task.task_id.as_str(), task))
+        .collect::<BTreeMap<_, _>>();
+    let after_tasks = after
+        .tasks
+        .iter()
+        .map(|task| (task.task_id.as_str(), task))
+        .collect::<BTreeMap<_, _>>();
+
+    let mut diff = SummaryDiff {
+        global,
+        ..Default::default()
+    };
+    let task_ids = before_tasks
+        .keys()
+        .chain(after_tasks.keys())
+        .collect::<BTreeSet<_>>();
+    for task_id in task_ids {
+        let before_task = before_tasks.get(task_id);
+        let after_task = after_tasks.get(task_id);
+        let (Some(before_task), Some(after_task)) = (before_task, after_task) else {
+            diff.tasks.push(TaskDiff {
+                task_id: task_id.to_string(),
+                before: before_task.map(|task| task.hash.clone()),
+                after: after_task.map(|task| task.hash.clone()),
+                ..Default::default()
+            });
+            continue;
+        };
+        if before_task.hash == after_task.hash {
+            diff.unchanged += 1;
+            continue;
+        }
+
+        let dependencies = before_task
+            .dependencies
+            .iter()
+            .chain(&after_task.dependencies)
+            .collect::<BTreeSet<_>>()
+            .into_iter()
+            .filter(|dependency| {
+                !before_task.dependencies.contains(dependency)
+                    || !after_task.dependencies.contains(dependency)
+                    || before_tasks.get(dependency.as_str()).map(|task| &task.hash)
+                        != after_tasks.get(dependency.as_str()).map(|task| &task.hash)
+            })
+            .cloned()
+            .collect();
+        let before_definition = &before_task.resolved_task_definition;
+        let after_definition = &after_task.resolved_task_definition;
+        let task_definition = before_definition
+            .keys()
+            .chain(after_definition.keys())
+            .collect::<BTreeSet<_>>()
+            .into_iter()
+            .filter(|key| before_definition.get(*key) != after_definition.get(*key))
+            .cloned()
+            .collect();
+
+        diff.tasks.push(TaskDiff {
+            task_id: task_id.to_string(),
+            before: Some(before_task.hash.clone()),
+            after: Some(after_task.hash.clone()),
+            files: FileChanges::new(&before_task.inputs, &after_task.inputs),
+            environment_variables: changed_env_vars(
+                &before_task.environment_variables,
+                &after_task.environment_variables,
+            ),
+            dependencies,
+            task_definition,
+            external_dependencies: before_task.hash_of_external_dependencies
+                != after_task.hash_of_external_dependencies,
+            cli_arguments: before_task.cli_arguments != after_task.cli_arguments,
+            env_mode: before_task.env_mode != after_task.env_mode,
+        });
+    }
+
+    diff
+}
+
+fn read_summary(path: &str) -> Result<RunSummary, Error> {
+    let path = AbsoluteSystemPathBuf::from_cwd(path)?;
+    let contents = path.read_to_string().map_err(|source| Error::Read {
+        path: path.clone(),
+        source,
+    })?;
+    serde_json::from_str(&contents).map_err(|source| Error::Parse { path, source })
+}
+
+/// Prints why the tasks in the summary at `after` have different hashes from
+/// the same tasks in the summary at `before`.
+pub fn run(ui: UI, before: &str, after: &str, json: bool) -> Result<(), Error> {
+    let diff = diff_summaries(&read_summary(before)?, &read_summary(after)?);
+    if json {
+        println!("{}", serde_json::to_string_pretty(&diff)?);
+        return Ok(());
+    }
+
+    if !diff.global.is_empty() {
+        println!("{}", color!(ui, BOLD, "Global hash inputs"));
+        print_file_changes(&diff.global.files);
+        print_list("environment variables", &diff.global.environment_variables);
+        if diff.global.external_dependencies {
+            println!("  root external dependencies changed");
+        }
+        println!();
+    }
+
+    for task in &diff.tasks {
+        match (&task.before, &task.after) {
+            (Some(before_hash), Some(after_hash)) => println!(
+                "{} {}",
+                color!(ui, BOLD, "{}", task.task_id),
+                color!(ui, GREY, "{before_hash} -> {after_hash}")
+            ),
+            (None, _) => println!(
+                "{} {}",
+                color!(ui, BOLD, "{}", task.task_id),
+                color!(ui, GREY, "only in {after}")
+            ),
+            (_, None) => println!(
+                "{} {}",
+                color!(ui, BOLD, "{}", task.task_id),
+                color!(ui, GREY, "only in {before}")
+            ),
+        }
+        if task.before.is_none() || task.after.is_none() {
+            continue;
+        }
+
+        print_file_changes(&task.files);
+        print_list("environment variables", &task.environment_variables);
+        print_list("dependencies", &task.dependencies);
+        print_list("task definition", &task.task_definition);
+        if task.external_dependencies {
+            println!("  external dependencies changed");
+        }
+        if task.cli_arguments {
+            println!("  arguments changed");
+        }
+        if task.env_mode {
+            println!("  environment mode changed");
+        }
+        let explained = !task.files.is_empty()
+            || !task.environment_variables.is_empty()
+            || !task.dependencies.is_empty()
+            || !task.task_definition.is_empty()
+            || task.external_dependencies
+            || task.cli_arguments
+            || task.env_mode;
+        if !explained && !diff.global.is_empty() {
+            println!("  global hash inputs changed");
+        } else if !explained {
+            println!("  no hashed inputs in the summaries differ");
+        }
+    }
+
+    if diff.unchanged > 0 {
+        println!();
+        println!(
+            "{}",
+            color!(ui, GREY, "{} tasks have the same hash", diff.unchanged)
+        );
+    }
+
+    Ok(())
+}
+
+fn print_file_changes(files: &FileChanges) {
+    print_list("files changed", &files.changed);
+    print_list("files added", &files.added);
+    print_list("files removed", &files.removed);
+}
+
+fn print_list(label: &str, items: &[String]) {
+    if !items.is_empty() {
+        println!("  {label}: {}", items.join(", "));
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use serde_json::json;
+
+    use super::{diff_summaries, FileChanges, GlobalDiff, RunSummary, TaskDiff};
+
+    fn summary(value: serde_json::Value) -> RunSummary {
+        serde_json::from_value(value).unwrap()
+    }
+
+    #[test]
+    fn test_diff_summaries() {
+        let before = summary(json!({
+            "globalCacheInputs": {
+                "files": { "turbo.json": "1" },
+                "hashOfExternalDependencies": "a",
+                "environmentVariables": { "configured": [], "inferred": [] }
+            },
+            "tasks": [
+                {
+                    "taskId": "ui#build",
+                    "hash": "ui1",
+                    "inputs": { "packages/ui/index.ts": "1" },
+                    "dependencies": [],
+                    "resolvedTaskDefinition": { "outputs": ["dist/**"] },
+                    "environmentVariables": { "configured": [], "inferred": [] }
+                },
+                {
+                    "taskId": "web#build",
+                    "hash": "web1",
+                    "inputs": { "apps/web/index.ts": "1", "apps/web/old.ts": "1" },
+                    "dependencies": ["ui#build"],
+                    "resolvedTaskDefinition": { "outputs": ["dist/**"] },
+                    "environmentVariables": { "configured": ["API_URL=1"], "inferred": [] }
+                },
+                {
+                    "taskId": "docs#build",
+                    "hash": "docs1",
+                    "dependencies": [],
+                    "environmentVariables": { "configured": [], "inferred": [] }
+                },
+                {
+                    "taskId": "web#lint",
+                    "hash": "lint1"
+                }
+            ]
+        }));
+        let after = summary(json!({
+            "globalCacheInputs": {
+                "files": { "turbo.json": "1" },
+                "hashOfExternalDependencies": "a",
+                "environmentVariables": { "configured": [], "inferred": [] }
+            },
+            "tasks": [
+                {
+                    "taskId": "ui#build",
+                    "hash": "ui2",
+                    "inputs": { "packages/ui/index.ts": "1" },
+                    "dependencies": [],
+                    "resolvedTaskDefinition": { "outputs": ["dist/**", "types/**"] },
+                    "environmentVariables": { "configured": [], "inferred": [] }
+                },
+                {
+                    "taskId": "web#build",
+                    "hash": "web2",
+                    "inputs": { "apps/web/index.ts": "2", "apps/web/new.ts": "1" },
+                    "dependencies": ["ui#build"],
+                    "resolvedTaskDefinition": { "outputs": ["dist/**"] },
+                    "environmentVariables": { "configured": ["API_URL=2"], "inferred": [] }
+                },
+                {
+                    "taskId": "docs#build",
+                    "hash": "docs1",
+                    "dependencies": [],
+                    "environmentVariables": { "configured": [], "inferred": [] }
+                },
+                {
+                    "taskId": "web#test",
+                    "hash": "test1"
+                }
+            ]
+        }));
+
+        let diff = diff_summaries(&before, &after);
+
+        assert_eq!(diff.global, GlobalDiff::default());
+        assert_eq!(diff.unchanged, 1);
+        assert_eq!(
+            diff.tasks,
+            vec![
+                TaskDiff {
+                    task_id: "ui#build".to_string(),
+                    before: Some("ui1".to_string()),
+                    after: Some("ui2".to_string()),
+                    task_definition: vec!["outputs".to_string()],
+                    ..Default::default()
+                },
+                TaskDiff {
+                    task_id: "web#build".to_string(),
+                    before: Some("web1".to_string()),
+                    after: Some("web2".to_string()),
+                    files: FileChanges {
+                        changed: vec!["apps/web/index.ts".to_string()],
+                        added: vec!["apps/web/new.ts".to_string()],
+                        removed: vec!["apps/web/old.ts".to_string()],
+                    },
+                    environment_variables: vec!["API_URL".to_string()],
+                    dependencies: vec!["ui#build".to_string()],
+                    ..Default::default()
+                },
+                TaskDiff {
+                    task_id: "web#lint".to_string(),
+                    before: Some("lint1".to_string()),
+                    after: None,
+                    ..Default::default()
+                },
+                TaskDiff {
+                    task_id: "web#test".to_string(),
+                    before: None,
+                    after: Some("test1".to_string()),
+                    ..Default::default()
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_diff_global_inputs() {
+        let before = summary(json!({
+            "globalCacheInputs": {
+                "files": { ".env": "1" },
+                "hashOfExternalDependencies": "a",
+                "environmentVariables": { "configured": ["CI=1"], "inferred": null }
+            },
+            "tasks": []
+        }));
+        let after = summary(json!({
+            "globalCacheInputs": {
+                "files": {},
+                "hashOfExternalDependencies": "b",
+                "environmentVariables": { "configured": ["CI=1", "NODE_ENV=2"], "inferred": null }
+            },
+            "tasks": []
+        }));
+
+        assert_eq!(
+            diff_summaries(&before, &after).global,
+            GlobalDiff {
+                files: FileChanges {
+                    removed: vec![".env".to_string()],
+                    ..Default::default()
+                },
+                environment_variables: vec!["NODE_ENV".to_string()],
+                external_dependencies: true,
+            }
+        );
+    }
+}
@@ -0,0 +1,240 @@
+//! `turbo exec-plan` runs several sets of tasks from a JSON plan in one
+//! process. The package graph, caches and daemon connection are set up once
+//! and shared by every entry of the plan, which is much faster than starting
+//! `turbo run` for each of them.
+
+use std::{
+    collections::{BTreeMap, HashMap},
+    io::{self, Read},
+};
+
+use miette::Diagnostic;
+use serde::Deserialize;
+use thiserror::Error;
+use turborepo_env::EnvironmentVariableMap;
+use turborepo_telemetry::events::command::CommandEventBuilder;
+
+use crate::{
+    cli::{Command, ContinueMode},
+    commands::{
+        run::{execute, get_signal},
+        CommandBase,
+    },
+    run,
+    run::{builder::RunBuilder, Run},
+    signal::SignalHandler,
+};
+
+// Reads the plan from stdin
+const STDIN: &str = "-";
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error("unable to read plan from {path}: {source}")]
+    Read {
+        path: String,
+        #[source]
+        source: io::Error,
+    },
+    #[error("invalid plan: {0}")]
+    Parse(#[source] serde_json::Error),
+    #[error("plan doesn't have any entries")]
+    NoEntries,
+    #[error("entry {index} of the plan doesn't have any tasks")]
+    NoTasks { index: usize },
+    #[error("tasks can't be passed to `turbo exec-plan`")]
+    #[diagnostic(help("list the tasks of each entry in the plan"))]
+    TasksArgument,
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Run(#[from] run::Error),
+}
+
+#[derive(Debug, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct Plan {
+    entries: Vec<PlanEntry>,
+}
+
+#[derive(Debug, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+struct PlanEntry {
+    tasks: Vec<String>,
+    // Uses the same syntax as `--filter`. The filters passed to `turbo exec-plan`
+    // are used for entries without any.
+    #[serde(default)]
+    filters: Vec<String>,
+    // Set for the entry's tasks, even in strict mode, and part of their
+    // global hash
+    #[serde(default)]
+    env: BTreeMap<String, String>,
+}
+
+fn parse_plan(contents: &str) -> Result<Plan, Error> {
+    let plan: Plan = serde_json::from_str(contents).map_err(Error::Parse)?;
+    if plan.entries.is_empty() {
+        return Err(Error::NoEntries);
+    }
+    if let Some(index) = plan.entries.iter().position(|entry| entry.tasks.is_empty()) {
+        return Err(Error::NoTasks { index });
+    }
+    Ok(plan)
+}
+
+fn read_plan(path: &str) -> Result<Plan, Error> {
+    let to_error = |source| Error::Read {
+        path: path.to_string(),
+        source,
+    };
+    let contents = if path == STDIN {
+        let mut contents = String::new();
+        io::stdin()
+            .read_to_string(&mut contents)
+            .map_err(to_error)?;
+        contents
+    } else {
+        std::fs::read_to_string(path).map_err(to_error)?
+    };
+    parse_plan(&contents)
+}
+
+/// Runs each entry of the plan at `path` in order and returns the highest exit
+/// code. Stops at the first entry that fails unless `--continue` is passed.
+pub async fn run(
+    base: CommandBase,
+    path: &str,
+    telemetry: CommandEventBuilder,
+) -> Result<i32, Error> {
+    let Some(Command::ExecPlan { execution_args, .. }) = &base.args().command else {
+        unreachable!()
+    };
+    if !execution_args.tasks.is_empty() {
+        return Err(Error::TasksArgument);
+    }
+    let continue_on_error = execution_args.continue_execution != ContinueMode::Never;
+    let plan = read_plan(path)?;
+
+    let signal = get_signal()?;
+    let handler = SignalHandler::new(signal);
+
+    let plan_fut = async {
+        let Some(Command::ExecPlan {
+            run_args,
+            execution_args,
+            ..
+        }) = &base.args().command
+        else {
+            unreachable!()
+        };
+        // The options are resolved once, as if the first entry was passed to
+        // `turbo run`, and each entry replaces its tasks and filters
+        let default_filters = execution_args.filter.clone();
+        let mut execution_args = execution_args.clone();
+        execution_args.tasks = plan.entries[0].tasks.clone();
+        let mut run_base = base.clone();
+        run_base.args_mut().command = Some(Command::Run {
+            run_args: run_args.clone(),
+            execution_args,
+        });
+        let run_builder = RunBuilder::new(run_base)?;
+        let (analytics_sender, analytics_handle) = run_builder.start_analytics();
+        let run_builder = run_builder.with_analytics_sender(analytics_sender);
+
+        let mut first_run: Option<Run> = None;
+        let mut exit_code = 0;
+        for entry in plan.entries {
+            let filters = if entry.filters.is_empty() {
+                default_filters.clone()
+            } else {
+                entry.filters
+            };
+            let entry_builder = run_builder
+                .clone()
+                .with_tasks(entry.tasks)
+                .with_filters(filters)
+                .with_env(EnvironmentVariableMap::from(
+                    entry.env.into_iter().collect::<HashMap<_, _>>(),
+                ));
+            let mut run = match &first_run {
+                Some(first_run) => entry_builder.build_from(first_run, &handler)?,
+                None => entry_builder.build(&handler, telemetry.clone()).await?,
+            };
+
+            let entry_exit_code = execute(&mut run).await?;
+            exit_code = exit_code.max(entry_exit_code);
+            first_run.get_or_insert(run);
+            if entry_exit_code != 0 && !continue_on_error {
+                break;
+            }
+        }
+
+        if let Some(analytics_handle) = analytics_handle {
+            analytics_handle.close_with_timeout().await;
+        }
+
+        Ok::<_, Error>(exit_code)
+    };
+
+    let handler_fut = handler.done();
+    tokio::select! {
+        biased;
+        _ = handler_fut => {
+            // We caught a signal, which already notified the subscribers
+            Ok(1)
+        }
+        result = plan_fut => {
+            handler.close().await;
+            result
+        },
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use std::collections::BTreeMap;
+
+    use super::{parse_plan, Error, PlanEntry};
+
+    #[test]
+    fn test_parse_plan() {
+        let plan = parse_plan(
+            r#"{"entries": [
+                {"tasks": ["build"], "filters": ["web..."], "env": {"NODE_ENV": "production"}},
+                {"tasks": ["lint", "test"]}
+            ]}"#,
+        )
+        .unwrap();
+
+        assert_eq!(
+            plan.entries,
+            vec![
+                PlanEntry {
+                    tasks: vec!["build".to_string()],
+                    filters: vec!["web...".to_string()],
+                    env: BTreeMap::from([("NODE_ENV".to_string(), "production".to_string())]),
+                },
+                PlanEntry {
+                    tasks: vec!["lint".to_string(), "test".to_string()],
+                    filters: vec![],
+                    env: BTreeMap::new(),
+                },
+            ]
+        );
+    }
+
+    #[test]
+    fn test_parse_invalid_plan() {
+        assert!(matches!(
+            parse_plan(r#"{"entries": []}"#),
+            Err(Error::NoEntries)
+        ));
+        assert!(matches!(
+            parse_plan(r#"{"entries": [{"tasks": ["build"]}, {"tasks": []}]}"#),
+            Err(Error::NoTasks { index: 1 })
+        ));
+        assert!(matches!(
+            parse_plan(r#"{"entries": [{"tasks": ["build"], "filter": ["web"]}]}"#),
+            Err(Error::Parse(_))
+        ));
+    }
+}
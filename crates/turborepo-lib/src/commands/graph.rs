@@ -0,0 +1,38 @@
+use turborepo_telemetry::events::command::CommandEventBuilder;
+
+use crate::{
+    cli::{Command, GraphFormat},
+    commands::{run::get_signal, CommandBase},
+    run,
+    run::builder::RunBuilder,
+    signal::SignalHandler,
+};
+
+pub async fn graph(
+    base: CommandBase,
+    format: GraphFormat,
+    telemetry: CommandEventBuilder,
+) -> Result<i32, run::Error> {
+    let signal = get_signal()?;
+    let handler = SignalHandler::new(signal);
+
+    let Some(Command::Graph { execution_args, .. }) = &base.args().command else {
+        unreachable!()
+    };
+
+    // We construct the task graph the same way a run would, we just print it
+    // instead of executing it.
+    let mut new_base = base.clone();
+    new_base.args_mut().command = Some(Command::Run {
+        run_args: Box::default(),
+        execution_args: execution_args.clone(),
+    });
+
+    let run = RunBuilder::new(new_base)?
+        .hide_prelude()
+        .build(&handler, telemetry)
+        .await?;
+    run.print_task_graph(format)?;
+
+    Ok(0)
+}
@@ -73,12 +73,13 @@ pub async fn run(
 ) -> Result<(), cli::Error> {
     let root_package_json = PackageJson::load(&base.repo_root.join_component("package.json"))?;
 
+    let config = base.config()?;
+
     let package_graph = PackageGraph::builder(&base.repo_root, root_package_json)
+        .with_workspace_providers(config.workspace_providers()?)
         .build()
         .await?;
 
-    let config = base.config()?;
-
     if let Some(workspace) = workspace {
         let workspace_details = WorkspaceDetails::new(&package_graph, workspace);
         if json {
@@ -17,7 +17,8 @@ use dirs_next::home_dir;
 #[cfg(test)]
 use rand::Rng;
 use thiserror::Error;
-use turborepo_api_client::{CacheClient, Client};
+use tracing::warn;
+use turborepo_api_client::{APIAuth, CacheClient, Client};
 #[cfg(not(test))]
 use turborepo_ui::CYAN;
 use turborepo_ui::{DialoguerTheme, BOLD, GREY};
@@ -25,8 +26,8 @@ use turborepo_vercel_api::{CachingStatus, Space, Team};
 
 use crate::{
     cli::LinkTarget,
-    commands::CommandBase,
-    config,
+    commands::{remote_cache, CommandBase},
+    config::{self, LinkProfile},
     gitignore::ensure_turbo_is_gitignored,
     rewrite_json::{self, set_path, unset_path},
 };
@@ -165,7 +166,28 @@ pub async fn link(
     base: &mut CommandBase,
     modify_gitignore: bool,
     target: LinkTarget,
+    profile: Option<&str>,
 ) -> Result<(), Error> {
+    // Switching to a saved profile doesn't need a login or any prompts
+    if let (LinkTarget::RemoteCache, Some(name)) = (target, profile) {
+        let profiles = config::read_link_profiles(&base.global_config_path()?)?;
+        if let Some(saved) = profiles.get(name) {
+            write_local_link(base, "linkProfile", name)?;
+            if modify_gitignore {
+                gitignore_turbo(base)?;
+            }
+            println!(
+                "
+    {}  Linked to profile {} ({})
+        ",
+                base.ui.rainbow(">>> Success!"),
+                base.ui.apply(BOLD.apply_to(name)),
+                saved.team_slug.as_deref().unwrap_or(&saved.team_id)
+            );
+            return Ok(());
+        }
+    }
+
     let homedir_path = home_dir().ok_or_else(|| Error::HomeDirectoryNotFound)?;
     let homedir = homedir_path.to_string_lossy();
     let repo_root_with_tilde = base.repo_root.to_string().replacen(&*homedir, "~", 1);
@@ -213,36 +235,25 @@ pub async fn link(
             verify_caching_enabled(&api_client, team_id, token, Some(selected_team.clone()))
                 .await?;
 
-            let local_config_path = base.local_config_path();
-            let before = local_config_path
-                .read_existing_to_string_or(Ok("{}"))
-                .map_err(|e| config::Error::FailedToReadConfig {
-                    config_path: local_config_path.clone(),
-                    error: e,
-                })?;
-
-            let no_preexisting_id = unset_path(&before, &["teamid"], false)?.unwrap_or(before);
-            let no_preexisting_slug =
-                unset_path(&no_preexisting_id, &["teamslug"], false)?.unwrap_or(no_preexisting_id);
-
-            let after = set_path(
-                &no_preexisting_slug,
-                &["teamId"],
-                &format!("\"{}\"", team_id),
-            )?;
-            let local_config_path = base.local_config_path();
-            local_config_path
-                .ensure_dir()
-                .map_err(|error| config::Error::FailedToSetConfig {
-                    config_path: local_config_path.clone(),
-                    error,
-                })?;
-            local_config_path
-                .create_with_contents(after)
-                .map_err(|error| config::Error::FailedToSetConfig {
-                    config_path: local_config_path.clone(),
-                    error,
-                })?;
+            match profile {
+                Some(profile) => {
+                    let team_slug = match selected_team {
+                        SelectedTeam::User => None,
+                        SelectedTeam::Team(team) => Some(team.slug.clone()),
+                    };
+                    save_link_profile(
+                        base,
+                        profile,
+                        &LinkProfile {
+                            team_id: team_id.to_string(),
+                            team_slug,
+                            token: token.to_string(),
+                        },
+                    )?;
+                    write_local_link(base, "linkProfile", profile)?;
+                }
+                None => write_local_link(base, "teamId", team_id)?,
+            }
 
             let chosen_team_name = match selected_team {
                 SelectedTeam::User => user_display_name,
@@ -250,12 +261,7 @@ pub async fn link(
             };
 
             if modify_gitignore {
-                ensure_turbo_is_gitignored(&base.repo_root).map_err(|error| {
-                    config::Error::FailedToSetConfig {
-                        config_path: base.repo_root.join_component(".gitignore"),
-                        error,
-                    }
-                })?;
+                gitignore_turbo(base)?;
             }
 
             println!(
@@ -268,6 +274,27 @@ pub async fn link(
                 base.ui.apply(BOLD.apply_to(chosen_team_name)),
                 GREY.apply_to("To disable Remote Caching, run `npx turbo unlink`")
             );
+            if let Some(profile) = profile {
+                println!(
+                    "{}",
+                    GREY.apply_to(format!(
+                        "Saved as link profile {profile}. Link other repositories to it with `npx \
+                         turbo link --profile={profile}`"
+                    ))
+                );
+            }
+
+            // The team was linked either way, so a failed check only warns about
+            // it. The loaded config predates the link, so the credentials are
+            // passed in directly.
+            let api_auth = APIAuth {
+                team_id: Some(team_id.to_string()),
+                token: token.to_string(),
+                team_slug: None,
+            };
+            if let Err(e) = remote_cache::verify_link(base, api_client.clone(), api_auth).await {
+                warn!("unable to verify Remote Caching: {e}");
+            }
             Ok(())
         }
         LinkTarget::Spaces => {
@@ -318,36 +345,7 @@ pub async fn link(
 
             add_space_id_to_turbo_json(base, &space.id)?;
 
-            let local_config_path = base.local_config_path();
-            let before = local_config_path
-                .read_existing_to_string_or(Ok("{}"))
-                .map_err(|error| config::Error::FailedToReadConfig {
-                    config_path: local_config_path.clone(),
-                    error,
-                })?;
-
-            let no_preexisting_id = unset_path(&before, &["teamid"], false)?.unwrap_or(before);
-            let no_preexisting_slug =
-                unset_path(&no_preexisting_id, &["teamslug"], false)?.unwrap_or(no_preexisting_id);
-
-            let after = set_path(
-                &no_preexisting_slug,
-                &["teamId"],
-                &format!("\"{}\"", team_id),
-            )?;
-            let local_config_path = base.local_config_path();
-            local_config_path
-                .ensure_dir()
-                .map_err(|error| config::Error::FailedToSetConfig {
-                    config_path: local_config_path.clone(),
-                    error,
-                })?;
-            local_config_path
-                .create_with_contents(after)
-                .map_err(|error| config::Error::FailedToSetConfig {
-                    config_path: local_config_path.clone(),
-                    error,
-                })?;
+            write_local_link(base, "teamId", team_id)?;
 
             println!(
                 "
@@ -368,6 +366,107 @@ pub async fn link(
     }
 }
 
+/// Prints the link profiles saved in the global config, marking the one
+/// that the repository is linked with
+pub fn list_profiles(base: &CommandBase) -> Result<(), Error> {
+    let profiles = config::read_link_profiles(&base.global_config_path()?)?;
+    if profiles.is_empty() {
+        println!(
+            "{}",
+            base.ui.apply(GREY.apply_to("> No link profiles found"))
+        );
+        return Ok(());
+    }
+
+    let linked_profile = base.config()?.link_profile();
+    for (name, profile) in &profiles {
+        let marker = if linked_profile == Some(name.as_str()) {
+            "*"
+        } else {
+            " "
+        };
+        println!(
+            "{marker} {} {}",
+            base.ui.apply(BOLD.apply_to(name)),
+            base.ui
+                .apply(GREY.apply_to(profile.team_slug.as_deref().unwrap_or(&profile.team_id)))
+        );
+    }
+
+    Ok(())
+}
+
+// Links the repository by setting `key` in the local config, removing any
+// team or profile that it was linked to before
+fn write_local_link(base: &CommandBase, key: &str, value: &str) -> Result<(), Error> {
+    let local_config_path = base.local_config_path();
+    let before = local_config_path
+        .read_existing_to_string_or(Ok("{}"))
+        .map_err(|error| config::Error::FailedToReadConfig {
+            config_path: local_config_path.clone(),
+            error,
+        })?;
+
+    let no_preexisting_id = unset_path(&before, &["teamid"], false)?.unwrap_or(before);
+    let no_preexisting_slug =
+        unset_path(&no_preexisting_id, &["teamslug"], false)?.unwrap_or(no_preexisting_id);
+    let no_preexisting_profile =
+        unset_path(&no_preexisting_slug, &["linkprofile"], false)?.unwrap_or(no_preexisting_slug);
+
+    let after = set_path(&no_preexisting_profile, &[key], &format!("\"{}\"", value))?;
+    local_config_path
+        .ensure_dir()
+        .map_err(|error| config::Error::FailedToSetConfig {
+            config_path: local_config_path.clone(),
+            error,
+        })?;
+    local_config_path
+        .create_with_contents(after)
+        .map_err(|error| config::Error::FailedToSetConfig {
+            config_path: local_config_path.clone(),
+            error,
+        })?;
+
+    Ok(())
+}
+
+fn save_link_profile(base: &CommandBase, name: &str, profile: &LinkProfile) -> Result<(), Error> {
+    let global_config_path = base.global_config_path()?;
+    let before = global_config_path
+        .read_existing_to_string_or(Ok("{}"))
+        .map_err(|error| config::Error::FailedToReadConfig {
+            config_path: global_config_path.clone(),
+            error,
+        })?;
+    let profile = serde_json::to_string(profile).map_err(config::Error::from)?;
+    let after = set_path(&before, &[config::LINK_PROFILES_KEY, name], &profile)?;
+
+    global_config_path
+        .ensure_dir()
+        .map_err(|error| config::Error::FailedToSetConfig {
+            config_path: global_config_path.clone(),
+            error,
+        })?;
+    global_config_path
+        .create_with_contents(after)
+        .map_err(|error| config::Error::FailedToSetConfig {
+            config_path: global_config_path.clone(),
+            error,
+        })?;
+
+    Ok(())
+}
+
+fn gitignore_turbo(base: &CommandBase) -> Result<(), Error> {
+    ensure_turbo_is_gitignored(&base.repo_root).map_err(|error| {
+        config::Error::FailedToSetConfig {
+            config_path: base.repo_root.join_component(".gitignore"),
+            error,
+        }
+    })?;
+    Ok(())
+}
+
 fn should_enable_caching() -> Result<bool, Error> {
     let theme = DialoguerTheme::default();
 
@@ -645,7 +744,7 @@ mod test {
             )
             .unwrap();
 
-        link::link(&mut base, false, LinkTarget::RemoteCache)
+        link::link(&mut base, false, LinkTarget::RemoteCache, None)
             .await
             .unwrap();
 
@@ -663,6 +762,82 @@ mod test {
         Ok(())
     }
 
+    #[tokio::test]
+    async fn test_link_remote_cache_profile() -> Result<()> {
+        // user config
+        let user_config_file = NamedTempFile::new().unwrap();
+        fs::write(user_config_file.path(), r#"{ "token": "hello" }"#).unwrap();
+        let global_config_path =
+            AbsoluteSystemPathBuf::try_from(user_config_file.path().to_path_buf()).unwrap();
+
+        // repo
+        let repo_root_tmp_dir = TempDir::new().unwrap();
+        let handle = repo_root_tmp_dir.path();
+        let repo_root = AbsoluteSystemPathBuf::try_from(handle).unwrap();
+        repo_root
+            .join_component("turbo.json")
+            .create_with_contents("{}")
+            .unwrap();
+        repo_root
+            .join_component("package.json")
+            .create_with_contents("{}")
+            .unwrap();
+
+        let repo_config_path = repo_root.join_components(&[".turbo", "config.json"]);
+        repo_config_path.ensure_dir().unwrap();
+        repo_config_path
+            .create_with_contents(r#"{ "apiurl": "http://localhost:3000", "teamId": "old" }"#)
+            .unwrap();
+
+        let port = port_scanner::request_open_port().unwrap();
+        let handle = tokio::spawn(start_test_server(port));
+        let mut base = CommandBase {
+            global_config_path: Some(global_config_path.clone()),
+            repo_root: repo_root.clone(),
+            ui: UI::new(false),
+            config: OnceCell::new(),
+            args: Args::default(),
+            version: "",
+        };
+        base.config
+            .set(
+                TurborepoConfigBuilder::new(&base)
+                    .with_api_url(Some(format!("http://localhost:{}", port)))
+                    .with_login_url(Some(format!("http://localhost:{}", port)))
+                    .with_token(Some("token".to_string()))
+                    .build()
+                    .unwrap(),
+            )
+            .unwrap();
+
+        link::link(&mut base, false, LinkTarget::RemoteCache, Some("work"))
+            .await
+            .unwrap();
+
+        handle.abort();
+
+        // the team and token are read from the profile
+        let updated_config = TurborepoConfigBuilder::new(&base).build().unwrap();
+        assert_eq!(updated_config.link_profile(), Some("work"));
+        assert_eq!(updated_config.token(), Some("token"));
+        let team_id = updated_config.team_id();
+        assert!(
+            team_id == Some(turborepo_vercel_api_mock::EXPECTED_USER_ID)
+                || team_id == Some(turborepo_vercel_api_mock::EXPECTED_TEAM_ID)
+        );
+
+        // switching to a saved profile doesn't need the API
+        repo_config_path.create_with_contents("{}").unwrap();
+        link::link(&mut base, false, LinkTarget::RemoteCache, Some("work"))
+            .await
+            .unwrap();
+        let updated_config = TurborepoConfigBuilder::new(&base).build().unwrap();
+        assert_eq!(updated_config.link_profile(), Some("work"));
+        assert_eq!(updated_config.team_id(), team_id);
+
+        Ok(())
+    }
+
     #[tokio::test]
     async fn test_link_spaces() {
         // user config
@@ -720,7 +895,7 @@ mod test {
         )
         .unwrap();
 
-        link::link(&mut base, false, LinkTarget::Spaces)
+        link::link(&mut base, false, LinkTarget::Spaces, None)
             .await
             .unwrap();
 
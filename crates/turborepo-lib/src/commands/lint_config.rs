@@ -0,0 +1,771 @@
+//! `turbo lint-config` statically checks turbo.json, including the turbo.json
+//! files of packages, and the scripts of the packages in the repository for
+//! configuration that makes task hashes unreliable or the task graph
+//! impossible to run.
+
+use std::{
+    collections::{BTreeMap, BTreeSet, HashMap},
+    fmt,
+};
+
+use miette::Diagnostic;
+use petgraph::Graph;
+use regex::Regex;
+use serde::Serialize;
+use thiserror::Error;
+use turbopath::AnchoredSystemPath;
+use turborepo_env::EnvironmentVariableMap;
+use turborepo_repository::{
+    package_graph::{self, PackageGraph, PackageName, PackageNode, ROOT_PKG_NAME},
+    package_json::{self, PackageJson},
+};
+use turborepo_ui::{color, BOLD, GREY, UI};
+
+use super::CommandBase;
+use crate::{
+    config,
+    engine::{find_cycle, TaskNode},
+    run::task_id::{TaskId, TaskName},
+    task_graph::TaskDefinition,
+    turbo_json::{validate_extends, validate_no_package_task_syntax, RawTaskDefinition, TurboJson},
+};
+
+// Set by the shell, the package manager or turbo itself, so scripts can use
+// them without declaring them
+const PROVIDED_ENV_VARS: &[&str] = &[
+    "HOME",
+    "INIT_CWD",
+    "OLDPWD",
+    "PATH",
+    "PWD",
+    "SHELL",
+    "TMPDIR",
+    "TURBO_HASH",
+    "TURBO_IS_CACHE_WRITE_ENABLED",
+    "TURBO_PACKAGE",
+    "TURBO_RUN_ID",
+    "TURBO_TASK",
+    "USER",
+];
+
+// Inputs that match every file of the package
+const MATCH_ALL: &[&str] = &["**", "**/*"];
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error(transparent)]
+    PackageJson(#[from] package_json::Error),
+    #[error(transparent)]
+    PackageGraph(#[from] package_graph::Error),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Config(#[from] config::Error),
+    #[error(transparent)]
+    Env(#[from] turborepo_env::Error),
+    #[error(transparent)]
+    SerdeJson(#[from] serde_json::Error),
+    #[error("invalid turbo.json")]
+    Validation {
+        #[related]
+        errors: Vec<config::Error>,
+    },
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "kebab-case")]
+enum Rule {
+    Cycle,
+    DependsOnPersistentTask,
+    MissingTask,
+    OutputsOverlapInputs,
+    UndeclaredEnvVar,
+}
+
+impl fmt::Display for Rule {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            Rule::Cycle => "cycle",
+            Rule::DependsOnPersistentTask => "depends-on-persistent-task",
+            Rule::MissingTask => "missing-task",
+            Rule::OutputsOverlapInputs => "outputs-overlap-inputs",
+            Rule::UndeclaredEnvVar => "undeclared-env-var",
+        })
+    }
+}
+
+#[derive(Debug, PartialEq, Eq, PartialOrd, Ord, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct Issue {
+    task: String,
+    rule: Rule,
+    message: String,
+}
+
+#[derive(Debug, Serialize)]
+struct Report<'a> {
+    issues: &'a [Issue],
+}
+
+// The parts of a package that the checks look at
+struct Package<'a> {
+    scripts: &'a BTreeMap<String, String>,
+    dependencies: Vec<PackageName>,
+}
+
+type Definitions = BTreeMap<TaskName<'static>, TaskDefinition>;
+
+/// Checks the configuration of the repository and returns the exit code,
+/// which is 1 if any issues were found.
+pub async fn run(base: &CommandBase, json: bool) -> Result<i32, Error> {
+    let root_package_json = PackageJson::load(&base.repo_root.join_component("package.json"))?;
+    let root_turbo_json = TurboJson::load(
+        &base.repo_root,
+        AnchoredSystemPath::empty(),
+        &root_package_json,
+        false,
+    )?;
+    let package_graph = PackageGraph::builder(&base.repo_root, root_package_json)
+        .with_workspace_providers(base.config()?.workspace_providers()?)
+        .build()
+        .await?;
+
+    let mut package_turbo_jsons = BTreeMap::new();
+    for (name, info) in package_graph.packages() {
+        if *name == PackageName::Root {
+            continue;
+        }
+        let Some(turbo_json) =
+            TurboJson::load_package(&base.repo_root, info.package_path(), &info.package_json)?
+        else {
+            continue;
+        };
+        let errors = turbo_json.validate(&[validate_no_package_task_syntax, validate_extends]);
+        if !errors.is_empty() {
+            return Err(Error::Validation { errors });
+        }
+        package_turbo_jsons.insert(name.clone(), turbo_json);
+    }
+
+    let packages = package_graph
+        .packages()
+        .map(|(name, info)| {
+            let package = Package {
+                scripts: &info.package_json.scripts,
+                dependencies: package_dependencies(&package_graph, name),
+            };
+            (name.clone(), package)
+        })
+        .collect::<BTreeMap<_, _>>();
+    let issues = lint(&root_turbo_json, &package_turbo_jsons, &packages)?;
+
+    if json {
+        let report = Report { issues: &issues };
+        println!("{}", serde_json::to_string_pretty(&report)?);
+    } else {
+        print_issues(base.ui, &issues);
+    }
+
+    Ok(if issues.is_empty() { 0 } else { 1 })
+}
+
+fn package_dependencies(package_graph: &PackageGraph, name: &PackageName) -> Vec<PackageName> {
+    package_graph
+        .immediate_dependencies(&PackageNode::Workspace(name.clone()))
+        .into_iter()
+        .flatten()
+        .filter_map(|dependency| match dependency {
+            PackageNode::Workspace(dependency @ PackageName::Other(_)) => Some(dependency.clone()),
+            PackageNode::Root | PackageNode::Workspace(PackageName::Root) => None,
+        })
+        .collect()
+}
+
+fn lint(
+    turbo_json: &TurboJson,
+    package_turbo_jsons: &BTreeMap<PackageName, TurboJson>,
+    packages: &BTreeMap<PackageName, Package>,
+) -> Result<Vec<Issue>, Error> {
+    let mut definitions = turbo_json
+        .tasks
+        .iter()
+        .map(|(name, definition)| -> Result<_, Error> {
+            Ok((
+                name.clone(),
+                TaskDefinition::try_from(definition.value.clone())?,
+            ))
+        })
+        .collect::<Result<Definitions, _>>()?;
+    let mut package_tasks = BTreeSet::new();
+    for (package_name, package_turbo_json) in package_turbo_jsons {
+        for (name, definition) in package_definitions(turbo_json, package_name, package_turbo_json)
+        {
+            package_tasks.insert(name.to_string());
+            definitions.insert(name, TaskDefinition::try_from(definition)?);
+        }
+    }
+
+    let mut issues = overlapping_outputs(&definitions);
+    issues.extend(persistent_dependencies(&definitions));
+    issues.extend(missing_tasks(&definitions, packages));
+    issues.extend(undeclared_env_vars(turbo_json, &definitions, packages)?);
+    issues.extend(cycles(&definitions, packages));
+
+    // The definitions from package configurations repeat the issues of the
+    // definitions they inherit
+    let inherited = issues
+        .iter()
+        .map(|issue| (issue.task.clone(), issue.rule, issue.message.clone()))
+        .collect::<BTreeSet<_>>();
+    issues.retain(|issue| {
+        let Some((_, task)) = issue
+            .task
+            .split_once('#')
+            .filter(|_| package_tasks.contains(&issue.task))
+        else {
+            return true;
+        };
+        !inherited.contains(&(task.to_string(), issue.rule, issue.message.clone()))
+    });
+    issues.sort();
+
+    Ok(issues)
+}
+
+// Resolves the tasks that a package's turbo.json configures the same way as
+// `turbo run`: the root definition of the task, then the shareable configs
+// the package extends and then the package's own definition, which replaces
+// everything before it if it sets `override`.
+fn package_definitions(
+    root_turbo_json: &TurboJson,
+    package_name: &PackageName,
+    package_turbo_json: &TurboJson,
+) -> Vec<(TaskName<'static>, RawTaskDefinition)> {
+    let task_names = package_turbo_json
+        .tasks
+        .keys()
+        .chain(
+            package_turbo_json
+                .extended
+                .iter()
+                .flat_map(|config| config.tasks.keys()),
+        )
+        .collect::<BTreeSet<_>>();
+
+    task_names
+        .into_iter()
+        .map(|task_name| {
+            let task_id =
+                TaskId::from_static(package_name.to_string(), task_name.task().to_string());
+            let mut chain = root_turbo_json
+                .task(&task_id, task_name)
+                .into_iter()
+                .collect::<Vec<_>>();
+            chain.extend(
+                package_turbo_json
+                    .extended_tasks(task_name)
+                    .map(|definition| definition.value.clone()),
+            );
+            if let Some(definition) = package_turbo_json.tasks.get(task_name) {
+                if definition.value.override_key().is_some() {
+                    chain.clear();
+                }
+                chain.push(definition.value.clone());
+            }
+            (
+                TaskName::from(task_id.to_string()),
+                RawTaskDefinition::from_iter(chain),
+            )
+        })
+        .collect()
+}
+
+// Package specific definitions take precedence over the definitions for every
+// package. The root package only runs tasks that are configured for it.
+fn lookup<'a>(
+    definitions: &'a Definitions,
+    package: Option<&str>,
+    task: &str,
+) -> Option<&'a TaskDefinition> {
+    package
+        .and_then(|package| definitions.get(&TaskName::from(format!("{package}#{task}"))))
+        .or_else(|| match package {
+            Some(ROOT_PKG_NAME) => None,
+            _ => definitions.get(&TaskName::from(task.to_string())),
+        })
+}
+
+fn overlapping_outputs(definitions: &Definitions) -> Vec<Issue> {
+    let mut issues = Vec::new();
+    for (name, definition) in definitions {
+        let inputs = definition
+            .inputs
+            .iter()
+            .filter(|input| !input.starts_with('!'));
+        for input in inputs {
+            for output in &definition.outputs.inclusions {
+                if globs_overlap(input, output) {
+                    issues.push(Issue {
+                        task: name.to_string(),
+                        rule: Rule::OutputsOverlapInputs,
+                        message: format!(
+                            "output `{output}` overlaps input `{input}`, so running the task \
+                             changes its own hash"
+                        ),
+                    });
+                }
+            }
+        }
+    }
+    issues
+}
+
+// Compares the directories that the globs start in. A glob that starts with
+// a wildcard only overlaps when it matches every file of the package.
+fn globs_overlap(input: &str, output: &str) -> bool {
+    // `$TURBO_DEFAULT$` and `$TURBO_ROOT$` inputs
+    if input.starts_with('$') {
+        return false;
+    }
+    match (glob_root(input), glob_root(output)) {
+        ("", _) => MATCH_ALL.contains(&input),
+        (_, "") => MATCH_ALL.contains(&output),
+        (input_root, output_root) => {
+            is_within(input_root, output_root) || is_within(output_root, input_root)
+        }
+    }
+}
+
+// Returns the literal directory that a glob starts in, or the whole path if
+// it isn't a glob
+fn glob_root(glob: &str) -> &str {
+    let glob = glob.trim_start_matches("./");
+    match glob.find(['*', '?', '[', '{']) {
+        Some(end) => glob[..end].rfind('/').map_or("", |slash| &glob[..slash]),
+        None => glob,
+    }
+}
+
+fn is_within(path: &str, dir: &str) -> bool {
+    path.strip_prefix(dir)
+        .is_some_and(|rest| rest.is_empty() || rest.starts_with('/'))
+}
+
+fn persistent_dependencies(definitions: &Definitions) -> Vec<Issue> {
+    let mut issues = Vec::new();
+    for (name, definition) in definitions {
+        let task_dependencies = definition.task_dependencies.iter().map(|dependency| {
+            let package = dependency.package().or(name.package());
+            (dependency, lookup(definitions, package, dependency.task()))
+        });
+        // Topological dependencies run in other packages
+        let topological_dependencies = definition
+            .topological_dependencies
+            .iter()
+            .map(|dependency| (dependency, lookup(definitions, None, dependency.task())));
+
+        for (dependency, dependency_definition) in task_dependencies.chain(topological_dependencies)
+        {
+            let Some(dependency_definition) = dependency_definition else {
+                continue;
+            };
+            if dependency_definition.persistent && dependency_definition.ready.is_none() {
+                issues.push(Issue {
+                    task: name.to_string(),
+                    rule: Rule::DependsOnPersistentTask,
+                    message: format!(
+                        "depends on persistent task `{}`, which never exits. Set `ready` on it so \
+                         that tasks depending on it can start",
+                        dependency.value
+                    ),
+                });
+            }
+        }
+    }
+    issues
+}
+
+fn missing_tasks(
+    definitions: &Definitions,
+    packages: &BTreeMap<PackageName, Package>,
+) -> Vec<Issue> {
+    let missing = |task_id: &TaskId| match packages.get(&task_id.to_workspace_name()) {
+        None => Some(format!("there is no package named `{}`", task_id.package())),
+        Some(package) if !package.scripts.contains_key(task_id.task()) => Some(format!(
+            "`{}` has no `{}` script",
+            task_id.package(),
+            task_id.task()
+        )),
+        Some(_) => None,
+    };
+
+    let mut issues = Vec::new();
+    for (name, definition) in definitions {
+        let message = match name.task_id() {
+            Some(task_id) => missing(&task_id),
+            // Tasks without a script that only depend on other tasks are a
+            // way to run several tasks at once
+            None if !definition.task_dependencies.is_empty()
+                || !definition.topological_dependencies.is_empty() =>
+            {
+                None
+            }
+            None => {
+                let has_script = packages.iter().any(|(package_name, package)| {
+                    *package_name != PackageName::Root && package.scripts.contains_key(name.task())
+                });
+                (!has_script).then(|| format!("no package has a `{}` script", name.task()))
+            }
+        };
+        if let Some(message) = message {
+            issues.push(Issue {
+                task: name.to_string(),
+                rule: Rule::MissingTask,
+                message,
+            });
+        }
+
+        for dependency in &definition.task_dependencies {
+            let Some(task_id) = dependency.task_id() else {
+                continue;
+            };
+            if let Some(reason) = missing(&task_id) {
+                issues.push(Issue {
+                    task: name.to_string(),
+                    rule: Rule::MissingTask,
+                    message: format!("depends on `{task_id}`, but {reason}"),
+                });
+            }
+        }
+    }
+    issues
+}
+
+fn undeclared_env_vars(
+    turbo_json: &TurboJson,
+    definitions: &Definitions,
+    packages: &BTreeMap<PackageName, Package>,
+) -> Result<Vec<Issue>, Error> {
+    let env_var = Regex::new(r"\$\{?([A-Z_][A-Z0-9_]*)").expect("env var pattern is valid");
+
+    let mut issues = Vec::new();
+    for (package_name, package) in packages {
+        let package_name = package_name.to_string();
+        for (script, command) in package.scripts {
+            let Some(definition) = lookup(definitions, Some(&package_name), script) else {
+                continue;
+            };
+            let referenced = env_var
+                .captures_iter(command)
+                .map(|captures| captures[1].to_string())
+                .filter(|name| !PROVIDED_ENV_VARS.contains(&name.as_str()))
+                .map(|name| (name, String::new()))
+                .collect::<HashMap<_, _>>();
+            if referenced.is_empty() {
+                continue;
+            }
+
+            let declared = definition
+                .env
+                .iter()
+                .chain(definition.pass_through_env.iter().flatten())
+                .chain(&turbo_json.global_env)
+                .chain(turbo_json.global_pass_through_env.iter().flatten())
+                .cloned()
+                .collect::<Vec<_>>();
+            let referenced = EnvironmentVariableMap::from(referenced);
+            let declared = referenced.from_wildcards(&declared)?;
+            let undeclared = referenced
+                .keys()
+                .filter(|name| !declared.contains_key(*name))
+                .collect::<BTreeSet<_>>();
+            for name in undeclared {
+                issues.push(Issue {
+                    task: format!("{package_name}#{script}"),
+                    rule: Rule::UndeclaredEnvVar,
+                    message: format!(
+                        "the script uses `{name}`, which isn't declared in `env` or `globalEnv`, \
+                         so changing it doesn't change the task's hash"
+                    ),
+                });
+            }
+        }
+    }
+    Ok(issues)
+}
+
+fn cycles(definitions: &Definitions, packages: &BTreeMap<PackageName, Package>) -> Vec<Issue> {
+    let mut task_graph = Graph::new();
+    let mut task_lookup = HashMap::new();
+    let mut node = |task_graph: &mut Graph<TaskNode, ()>, task_id: TaskId<'static>| {
+        *task_lookup
+            .entry(task_id.clone())
+            .or_insert_with(|| task_graph.add_node(TaskNode::Task(task_id)))
+    };
+
+    let mut issues = Vec::new();
+    for (package_name, package) in packages {
+        let package_name = package_name.to_string();
+        let tasks = definitions
+            .keys()
+            .filter(|name| match name.package() {
+                Some(package) => package == package_name,
+                None => package_name != ROOT_PKG_NAME,
+            })
+            .map(|name| name.task())
+            .collect::<BTreeSet<_>>();
+        for task in tasks {
+            let Some(definition) = lookup(definitions, Some(&package_name), task) else {
+                continue;
+            };
+            let task_id = TaskId::from_static(package_name.clone(), task.to_string());
+            let from = node(&mut task_graph, task_id.clone());
+
+            for dependency in &definition.task_dependencies {
+                let dependency_id = match dependency.task_id() {
+                    Some(dependency_id) => dependency_id.into_owned(),
+                    None => TaskId::from_static(package_name.clone(), dependency.task().into()),
+                };
+                if dependency_id == task_id {
+                    issues.push(Issue {
+                        task: task_id.to_string(),
+                        rule: Rule::Cycle,
+                        message: "depends on itself".to_string(),
+                    });
+                    continue;
+                }
+                let to = node(&mut task_graph, dependency_id);
+                task_graph.add_edge(from, to, ());
+            }
+            for dependency in &definition.topological_dependencies {
+                for dependency_package in &package.dependencies {
+                    let dependency_id = TaskId::from_static(
+                        dependency_package.to_string(),
+                        dependency.task().into(),
+                    );
+                    let to = node(&mut task_graph, dependency_id);
+                    task_graph.add_edge(from, to, ());
+                }
+            }
+        }
+    }
+
+    if let Some(cycle) = find_cycle(&task_graph) {
+        let mut tasks = cycle
+            .into_iter()
+            .map(|index| task_graph[index].to_string())
+            .collect::<Vec<_>>();
+        let task = tasks[0].clone();
+        tasks.push(task.clone());
+        issues.push(Issue {
+            task,
+            rule: Rule::Cycle,
+            message: format!(
+                "tasks depend on each other in a cycle: {}",
+                tasks.join(" -> ")
+            ),
+        });
+    }
+    issues
+}
+
+fn print_issues(ui: UI, issues: &[Issue]) {
+    if issues.is_empty() {
+        println!("No issues found");
+        return;
+    }
+
+    for issue in issues {
+        println!(
+            "{} {} {}",
+            color!(ui, BOLD, "{}", issue.task),
+            color!(ui, GREY, "[{}]", issue.rule),
+            issue.message
+        );
+    }
+    let count = issues.len();
+    println!();
+    println!(
+        "{count} {} found",
+        if count == 1 { "issue" } else { "issues" }
+    );
+}
+
+#[cfg(test)]
+mod test {
+    use std::collections::BTreeMap;
+
+    use serde_json::json;
+    use turborepo_repository::package_graph::PackageName;
+
+    use super::{globs_overlap, lint, Package, Rule};
+    use crate::turbo_json::{RawTurboJson, TurboJson};
+
+    fn scripts(scripts: &[(&str, &str)]) -> BTreeMap<String, String> {
+        scripts
+            .iter()
+            .map(|(name, command)| (name.to_string(), command.to_string()))
+            .collect()
+    }
+
+    fn turbo_json(value: serde_json::Value) -> TurboJson {
+        TurboJson::try_from(RawTurboJson::parse_from_serde(value).unwrap()).unwrap()
+    }
+
+    #[test]
+    fn test_lint() {
+        let raw = RawTurboJson::parse_from_serde(json!({
+            "globalEnv": ["CI"],
+            "tasks": {
+                "build": {
+                    "dependsOn": ["^build"],
+                    "env": ["NEXT_PUBLIC_*"],
+                    "inputs": ["src/**"],
+                    "outputs": ["dist/**", "src/generated/**"]
+                },
+                "dev": { "persistent": true, "cache": false },
+                "test": { "dependsOn": ["dev", "lint"] },
+                "lint": { "dependsOn": ["test"] },
+                "docs#deploy": {},
+                "ui#publish": { "dependsOn": ["ui#release"] },
+                "ci": { "dependsOn": ["build", "test"] }
+            }
+        }))
+        .unwrap();
+        let turbo_json = TurboJson::try_from(raw).unwrap();
+
+        let root_scripts = scripts(&[]);
+        let web_scripts = scripts(&[
+            (
+                "build",
+                "next build --env $NEXT_PUBLIC_URL $API_TOKEN ${CI} $PATH",
+            ),
+            ("dev", "next dev"),
+            ("test", "jest"),
+            ("lint", "eslint"),
+        ]);
+        let ui_scripts = scripts(&[("build", "tsc"), ("publish", "npm publish")]);
+        let packages = BTreeMap::from([
+            (
+                PackageName::Root,
+                Package {
+                    scripts: &root_scripts,
+                    dependencies: vec![],
+                },
+            ),
+            (
+                PackageName::from("web"),
+                Package {
+                    scripts: &web_scripts,
+                    dependencies: vec![PackageName::from("ui")],
+                },
+            ),
+            (
+                PackageName::from("ui"),
+                Package {
+                    scripts: &ui_scripts,
+                    dependencies: vec![],
+                },
+            ),
+        ]);
+
+        let issues = lint(&turbo_json, &BTreeMap::new(), &packages)
+            .unwrap()
+            .into_iter()
+            .map(|issue| (issue.task, issue.rule))
+            .collect::<Vec<_>>();
+
+        assert_eq!(
+            issues,
+            vec![
+                ("build".to_string(), Rule::OutputsOverlapInputs),
+                ("docs#deploy".to_string(), Rule::MissingTask),
+                ("test".to_string(), Rule::DependsOnPersistentTask),
+                ("ui#lint".to_string(), Rule::Cycle),
+                ("ui#publish".to_string(), Rule::MissingTask),
+                ("web#build".to_string(), Rule::UndeclaredEnvVar),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_lint_package_turbo_json() {
+        let root_turbo_json = turbo_json(json!({
+            "tasks": {
+                "build": { "inputs": ["src/**"], "outputs": ["src/generated/**"] },
+                "dev": { "persistent": true, "cache": false }
+            }
+        }));
+        let package_turbo_jsons = BTreeMap::from([
+            (
+                PackageName::from("web"),
+                turbo_json(json!({
+                    "extends": ["//"],
+                    "tasks": {
+                        "build": { "outputs": ["dist/**"], "env": ["API_TOKEN"] },
+                        "test": { "dependsOn": ["dev"] }
+                    }
+                })),
+            ),
+            (
+                PackageName::from("ui"),
+                turbo_json(json!({
+                    "extends": ["//"],
+                    "tasks": { "build": { "cache": false } }
+                })),
+            ),
+        ]);
+
+        let root_scripts = scripts(&[]);
+        let package_scripts = scripts(&[
+            ("build", "tsc --env $API_TOKEN"),
+            ("dev", "tsc --watch"),
+            ("test", "jest"),
+        ]);
+        let packages = ["web", "ui"]
+            .into_iter()
+            .map(|name| {
+                let package = Package {
+                    scripts: &package_scripts,
+                    dependencies: vec![],
+                };
+                (PackageName::from(name), package)
+            })
+            .chain(std::iter::once((
+                PackageName::Root,
+                Package {
+                    scripts: &root_scripts,
+                    dependencies: vec![],
+                },
+            )))
+            .collect::<BTreeMap<_, _>>();
+
+        let issues = lint(&root_turbo_json, &package_turbo_jsons, &packages)
+            .unwrap()
+            .into_iter()
+            .map(|issue| (issue.task, issue.rule))
+            .collect::<Vec<_>>();
+
+        // `ui#build` inherits the overlapping outputs of `build`, which are only
+        // reported once, and `web#build` replaces them
+        assert_eq!(
+            issues,
+            vec![
+                ("build".to_string(), Rule::OutputsOverlapInputs),
+                ("ui#build".to_string(), Rule::UndeclaredEnvVar),
+                ("web#test".to_string(), Rule::DependsOnPersistentTask),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_globs_overlap() {
+        assert!(globs_overlap("src/**", "src/generated/**"));
+        assert!(globs_overlap("src/generated/index.ts", "src/**"));
+        assert!(globs_overlap("**", "dist/**"));
+        assert!(!globs_overlap("src/**", "dist/**"));
+        assert!(!globs_overlap("**/*.ts", "dist/**"));
+        assert!(!globs_overlap("src/**", "*.tsbuildinfo"));
+        assert!(!globs_overlap("$TURBO_DEFAULT$", "dist/**"));
+        assert!(!globs_overlap("source/**", "src/**"));
+    }
+}
@@ -1,6 +1,7 @@
 use turborepo_api_client::APIClient;
 use turborepo_auth::{
-    login as auth_login, sso_login as auth_sso_login, DefaultLoginServer, LoginOptions, Token,
+    device_login as auth_device_login, login as auth_login, sso_login as auth_sso_login,
+    DefaultLoginServer, LoginOptions, Token,
 };
 use turborepo_telemetry::events::command::{CommandEventBuilder, LoginMethod};
 
@@ -30,56 +31,70 @@ pub async fn sso_login(
         return Ok(());
     }
 
-    let global_config_path = base.global_config_path()?;
-    let before = global_config_path
-        .read_existing_to_string_or(Ok("{}"))
-        .map_err(|e| config::Error::FailedToReadConfig {
-            config_path: global_config_path.clone(),
-            error: e,
-        })?;
+    write_token(base, &token)
+}
 
-    let after = set_path(&before, &["token"], &format!("\"{}\"", token.into_inner()))?;
+pub async fn login(
+    base: &mut CommandBase,
+    telemetry: CommandEventBuilder,
+    force: bool,
+) -> Result<(), Error> {
+    let mut login_telemetry = LoginTelemetry::new(&telemetry, LoginMethod::Standard);
 
-    global_config_path
-        .ensure_dir()
-        .map_err(|e| config::Error::FailedToSetConfig {
-            config_path: global_config_path.clone(),
-            error: e,
-        })?;
+    let api_client: APIClient = base.api_client()?;
+    let ui = base.ui;
+    let login_url_config = base.config()?.login_url().to_string();
+    let options = LoginOptions {
+        existing_token: base.config()?.token(),
+        force,
+        ..LoginOptions::new(&ui, &login_url_config, &api_client, &DefaultLoginServer)
+    };
 
-    global_config_path
-        .create_with_contents(after)
-        .map_err(|e| config::Error::FailedToSetConfig {
-            config_path: global_config_path.clone(),
-            error: e,
-        })?;
+    let token = auth_login(&options).await?;
+
+    // Don't write to disk if the token is already there
+    if matches!(token, Token::Existing(..)) {
+        return Ok(());
+    }
 
+    write_token(base, &token)?;
+
+    login_telemetry.set_success(true);
     Ok(())
 }
 
-pub async fn login(
+pub async fn device_login(
     base: &mut CommandBase,
+    sso_team: Option<&str>,
     telemetry: CommandEventBuilder,
     force: bool,
 ) -> Result<(), Error> {
-    let mut login_telemetry = LoginTelemetry::new(&telemetry, LoginMethod::Standard);
+    let mut login_telemetry = LoginTelemetry::new(&telemetry, LoginMethod::Device);
 
     let api_client: APIClient = base.api_client()?;
     let ui = base.ui;
     let login_url_config = base.config()?.login_url().to_string();
     let options = LoginOptions {
         existing_token: base.config()?.token(),
+        sso_team,
         force,
         ..LoginOptions::new(&ui, &login_url_config, &api_client, &DefaultLoginServer)
     };
 
-    let token = auth_login(&options).await?;
+    let token = auth_device_login(&options).await?;
 
     // Don't write to disk if the token is already there
     if matches!(token, Token::Existing(..)) {
         return Ok(());
     }
 
+    write_token(base, &token)?;
+
+    login_telemetry.set_success(true);
+    Ok(())
+}
+
+fn write_token(base: &CommandBase, token: &Token) -> Result<(), Error> {
     let global_config_path = base.global_config_path()?;
     let before = global_config_path
         .read_existing_to_string_or(Ok("{}"))
@@ -103,7 +118,6 @@ pub async fn login(
             error: e,
         })?;
 
-    login_telemetry.set_success(true);
     Ok(())
 }
 
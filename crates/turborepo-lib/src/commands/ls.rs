@@ -0,0 +1,269 @@
+//! `turbo ls` lists the packages in the repository along with their
+//! directories, tasks and the packages they depend on.
+
+use std::io::{self, Write};
+
+use miette::Diagnostic;
+use serde::Serialize;
+use tabwriter::TabWriter;
+use thiserror::Error;
+use turbopath::AnchoredSystemPath;
+use turborepo_repository::{
+    package_graph::{self, PackageGraph, PackageName, PackageNode},
+    package_json::{self, PackageJson},
+    package_manager::PackageManager,
+};
+use turborepo_scm::SCM;
+use turborepo_ui::{color, GREY, UI};
+
+use super::CommandBase;
+use crate::{
+    config,
+    opts::ScopeOpts,
+    run::{scope, scope::ResolutionError, task_id::TaskName},
+    turbo_json::TurboJson,
+};
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error(transparent)]
+    PackageJson(#[from] package_json::Error),
+    #[error(transparent)]
+    PackageGraph(#[from] package_graph::Error),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Config(#[from] config::Error),
+    #[error(transparent)]
+    Resolution(#[from] ResolutionError),
+    #[error(transparent)]
+    Io(#[from] io::Error),
+    #[error(transparent)]
+    SerdeJson(#[from] serde_json::Error),
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct RepositoryPackages<'a> {
+    package_manager: &'a PackageManager,
+    packages: Vec<PackageDetails>,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct PackageDetails {
+    name: String,
+    path: String,
+    /// Scripts of the package that are configured as tasks in turbo.json
+    tasks: Vec<String>,
+    /// Packages in the repository that the package depends on directly
+    dependencies: Vec<String>,
+}
+
+/// Lists the packages matching `filter`, or every package if no filter is
+/// given.
+pub async fn run(base: &CommandBase, filter: &[String], json: bool) -> Result<(), Error> {
+    let root_package_json = PackageJson::load(&base.repo_root.join_component("package.json"))?;
+    let root_turbo_json = TurboJson::load(
+        &base.repo_root,
+        AnchoredSystemPath::empty(),
+        &root_package_json,
+        false,
+    )?;
+    let package_graph = PackageGraph::builder(&base.repo_root, root_package_json)
+        .with_workspace_providers(base.config()?.workspace_providers()?)
+        .build()
+        .await?;
+
+    let scope_opts = ScopeOpts::from_filters(filter.to_vec());
+    let scm = SCM::new(&base.repo_root);
+    let (selected, _) = scope::resolve_packages(
+        &scope_opts,
+        &base.repo_root,
+        &package_graph,
+        &scm,
+        &root_turbo_json,
+    )?;
+
+    let mut packages = Vec::new();
+    for (name, info) in package_graph.packages() {
+        if *name == PackageName::Root || !selected.contains(name) {
+            continue;
+        }
+        let package_turbo_json =
+            TurboJson::load_package(&base.repo_root, info.package_path(), &info.package_json)?;
+        packages.push(PackageDetails {
+            name: name.to_string(),
+            path: info.package_path().to_unix().to_string(),
+            tasks: package_tasks(
+                name,
+                &info.package_json,
+                &root_turbo_json,
+                package_turbo_json.as_ref(),
+            ),
+            dependencies: package_dependencies(&package_graph, name),
+        });
+    }
+    packages.sort_by(|a, b| a.name.cmp(&b.name));
+
+    let repository = RepositoryPackages {
+        package_manager: package_graph.package_manager(),
+        packages,
+    };
+    if json {
+        println!("{}", serde_json::to_string_pretty(&repository)?);
+    } else {
+        print_packages(base.ui, &repository)?;
+    }
+
+    Ok(())
+}
+
+// A script is a task if the root turbo.json configures it for every package or
+// for this one, or if the package's own turbo.json or a config it extends
+// configures it
+fn package_tasks(
+    name: &PackageName,
+    package_json: &PackageJson,
+    root_turbo_json: &TurboJson,
+    package_turbo_json: Option<&TurboJson>,
+) -> Vec<String> {
+    // Scripts are already sorted since they're stored in a BTreeMap
+    package_json
+        .scripts
+        .keys()
+        .filter(|script| {
+            let task_name = TaskName::from(script.to_string());
+            root_turbo_json.tasks.contains_key(&task_name)
+                || root_turbo_json
+                    .tasks
+                    .contains_key(&TaskName::from(format!("{name}#{script}")))
+                || package_turbo_json.map_or(false, |turbo_json| {
+                    turbo_json.tasks.contains_key(&task_name)
+                        || turbo_json.extended_tasks(&task_name).next().is_some()
+                })
+        })
+        .cloned()
+        .collect()
+}
+
+fn package_dependencies(package_graph: &PackageGraph, name: &PackageName) -> Vec<String> {
+    let mut dependencies = package_graph
+        .immediate_dependencies(&PackageNode::Workspace(name.clone()))
+        .into_iter()
+        .flatten()
+        .filter_map(|dependency| match dependency {
+            PackageNode::Workspace(PackageName::Other(name)) => Some(name.clone()),
+            PackageNode::Root | PackageNode::Workspace(PackageName::Root) => None,
+        })
+        .collect::<Vec<_>>();
+    dependencies.sort();
+    dependencies
+}
+
+fn print_packages(ui: UI, repository: &RepositoryPackages) -> Result<(), Error> {
+    if repository.packages.is_empty() {
+        println!("No packages found");
+        return Ok(());
+    }
+
+    let count = repository.packages.len();
+    println!(
+        "{count} {} {}\n",
+        if count == 1 { "package" } else { "packages" },
+        color!(ui, GREY, "({})", repository.package_manager)
+    );
+
+    let mut tab_writer = TabWriter::new(io::stdout()).minwidth(0).padding(2);
+    writeln!(tab_writer, "Name\tPath\tTasks\tDependencies")?;
+    for package in &repository.packages {
+        writeln!(
+            tab_writer,
+            "{}\t{}\t{}\t{}",
+            package.name,
+            package.path,
+            package.tasks.join(", "),
+            package.dependencies.join(", "),
+        )?;
+    }
+    tab_writer.flush()?;
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod test {
+    use serde_json::json;
+    use turborepo_repository::{package_graph::PackageName, package_json::PackageJson};
+
+    use super::package_tasks;
+    use crate::turbo_json::{RawTurboJson, TurboJson};
+
+    fn turbo_json(value: serde_json::Value) -> TurboJson {
+        TurboJson::try_from(RawTurboJson::parse_from_serde(value).unwrap()).unwrap()
+    }
+
+    fn package_json() -> PackageJson {
+        PackageJson::from_value(json!({
+            "scripts": {
+                "build": "tsc",
+                "dev": "tsc --watch",
+                "lint": "eslint ."
+            }
+        }))
+        .unwrap()
+    }
+
+    #[test]
+    fn test_package_tasks() {
+        let root_turbo_json = turbo_json(json!({
+            "tasks": {
+                "build": {},
+                "web#dev": {}
+            }
+        }));
+
+        assert_eq!(
+            package_tasks(
+                &PackageName::from("web"),
+                &package_json(),
+                &root_turbo_json,
+                None
+            ),
+            vec!["build", "dev"]
+        );
+        assert_eq!(
+            package_tasks(
+                &PackageName::from("docs"),
+                &package_json(),
+                &root_turbo_json,
+                None
+            ),
+            vec!["build"]
+        );
+    }
+
+    #[test]
+    fn test_package_tasks_from_package_turbo_json() {
+        let root_turbo_json = turbo_json(json!({
+            "tasks": {
+                "build": {}
+            }
+        }));
+        let package_turbo_json = turbo_json(json!({
+            "extends": ["//"],
+            "tasks": {
+                "lint": {}
+            }
+        }));
+
+        assert_eq!(
+            package_tasks(
+                &PackageName::from("web"),
+                &package_json(),
+                &root_turbo_json,
+                Some(&package_turbo_json)
+            ),
+            vec!["build", "lint"]
+        );
+    }
+}
@@ -1,7 +1,7 @@
 use std::{cell::OnceCell, time::Duration};
 
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
-use turborepo_api_client::{APIAuth, APIClient};
+use turborepo_api_client::{APIAuth, APIClient, CacheTimeouts, RetryPolicy, TlsOptions};
 use turborepo_auth::{TURBO_TOKEN_DIR, TURBO_TOKEN_FILE};
 use turborepo_dirs::config_dir;
 use turborepo_ui::UI;
@@ -12,13 +12,21 @@ use crate::{
 };
 
 pub(crate) mod bin;
+pub(crate) mod cache;
+pub(crate) mod config;
 pub(crate) mod daemon;
+pub(crate) mod diff;
+pub(crate) mod exec_plan;
 pub(crate) mod generate;
+pub(crate) mod graph;
 pub(crate) mod info;
 pub(crate) mod link;
+pub(crate) mod lint_config;
 pub(crate) mod login;
 pub(crate) mod logout;
+pub(crate) mod ls;
 pub(crate) mod prune;
+pub(crate) mod remote_cache;
 pub(crate) mod run;
 pub(crate) mod scan;
 pub(crate) mod telemetry;
@@ -68,8 +76,18 @@ impl CommandBase {
             .with_token(self.args.token.clone())
             .with_timeout(self.args.remote_cache_timeout)
             .with_preflight(self.args.preflight.then_some(true))
+            .with_ca_cert(self.args.cacert.as_ref().map(|path| path.to_string()))
+            .with_insecure_skip_tls_verify(self.args.insecure_skip_tls_verify.then_some(true))
+            .with_signature(
+                self.args
+                    .experimental_remote_cache_signature
+                    .then_some(true),
+            )
             .with_ui(self.args.execution_args.as_ref().and_then(|args| {
-                if !args.log_order.compatible_with_tui() {
+                if let Some(ui) = args.ui {
+                    // An explicitly requested UI takes precedence over other configs
+                    Some(ui.use_tui())
+                } else if !args.log_order.compatible_with_tui() {
                     Some(false)
                 } else {
                     // If the argument is compatible with the TUI this does not mean we should
@@ -129,27 +147,55 @@ impl CommandBase {
         &mut self.args
     }
 
+    /// The certificate authorities to trust and whether to verify
+    /// certificates, for every client that makes requests for the user
+    pub fn tls_options(&self) -> Result<TlsOptions, ConfigError> {
+        let config = self.config()?;
+        let ca_cert = config
+            .ca_cert()
+            .map(|path| {
+                std::fs::read_to_string(path).map_err(|error| ConfigError::FailedToReadCaCert {
+                    path: path.to_string(),
+                    error,
+                })
+            })
+            .transpose()?;
+        Ok(TlsOptions {
+            ca_cert,
+            insecure_skip_verify: config.insecure_skip_tls_verify(),
+        })
+    }
+
     pub fn api_client(&self) -> Result<APIClient, ConfigError> {
         let config = self.config()?;
         let api_url = config.api_url();
-        let timeout = config.timeout();
-        let upload_timeout = config.upload_timeout();
+        // 0 disables a timeout
+        let timeout = |seconds: u64| (seconds > 0).then(|| Duration::from_secs(seconds));
 
         APIClient::new(
             api_url,
-            if timeout > 0 {
-                Some(Duration::from_secs(timeout))
-            } else {
-                None
-            },
-            if upload_timeout > 0 {
-                Some(Duration::from_secs(upload_timeout))
-            } else {
-                None
+            timeout(config.timeout()),
+            CacheTimeouts {
+                connect: timeout(config.connect_timeout()),
+                upload: timeout(config.upload_timeout()),
+                download: timeout(config.download_timeout()),
             },
             self.version,
             config.preflight(),
+            &self.tls_options()?,
         )
+        .map(|api_client| {
+            let default_policy = RetryPolicy::default();
+            let min_backoff = Duration::from_secs(config.retry_backoff());
+            let retry_max_elapsed = config.retry_max_elapsed();
+            api_client.with_retry_policy(RetryPolicy {
+                max_attempts: config.retry_attempts(),
+                min_backoff,
+                max_backoff: default_policy.max_backoff.max(min_backoff),
+                max_elapsed: (retry_max_elapsed > 0)
+                    .then(|| Duration::from_secs(retry_max_elapsed)),
+            })
+        })
         .map_err(ConfigError::ApiClient)
     }
 
@@ -1,16 +1,19 @@
 #[cfg(unix)]
 use std::os::unix::fs::PermissionsExt;
-use std::sync::OnceLock;
+use std::{str::FromStr, sync::OnceLock};
 
+use globwalk::{ValidatedGlob, WalkType};
 use lazy_static::lazy_static;
 use miette::Diagnostic;
 use tracing::trace;
 use turbopath::{
     AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPath,
 };
+use turborepo_lockfiles::Lockfile;
 use turborepo_repository::{
     package_graph::{self, PackageGraph, PackageName, PackageNode},
     package_json::PackageJson,
+    package_manager::PackageManager,
 };
 use turborepo_telemetry::events::command::CommandEventBuilder;
 use turborepo_ui::BOLD;
@@ -19,6 +22,7 @@ use super::CommandBase;
 use crate::turbo_json::RawTurboJson;
 
 pub const DEFAULT_OUTPUT_DIR: &str = "out";
+const DOCKERFILE: &str = "Dockerfile";
 
 #[derive(Debug, thiserror::Error, Diagnostic)]
 pub enum Error {
@@ -38,6 +42,9 @@ pub enum Error {
     #[error(transparent)]
     PackageGraph(#[from] package_graph::Error),
     #[error(transparent)]
+    #[diagnostic(transparent)]
+    Config(#[from] crate::config::Error),
+    #[error(transparent)]
     Lockfile(#[from] turborepo_lockfiles::Error),
     #[error("turbo doesn't support workspaces at file system root")]
     WorkspaceAtFilesystemRoot,
@@ -49,6 +56,10 @@ pub enum Error {
     MissingLockfile,
     #[error("Prune is not supported for Bun")]
     BunUnsupported,
+    #[error("invalid include glob: {0}")]
+    Glob(#[from] globwalk::GlobError),
+    #[error("failed to find files to include: {0}")]
+    Walk(#[from] globwalk::WalkError),
 }
 
 // Files that should be copied from root and if they're required for install
@@ -90,18 +101,19 @@ pub async fn prune(
     base: &CommandBase,
     scope: &[String],
     docker: bool,
+    emit_dockerfile: bool,
     output_dir: &str,
+    include: &[String],
     telemetry: CommandEventBuilder,
 ) -> Result<(), Error> {
     telemetry.track_arg_usage("docker", docker);
+    telemetry.track_arg_usage("emit-dockerfile", emit_dockerfile);
     telemetry.track_arg_usage("out-dir", output_dir != DEFAULT_OUTPUT_DIR);
+    telemetry.track_arg_usage("include", !include.is_empty());
 
     let prune = Prune::new(base, scope, docker, output_dir).await?;
 
-    if matches!(
-        prune.package_graph.package_manager(),
-        turborepo_repository::package_manager::PackageManager::Bun
-    ) {
+    if matches!(prune.package_graph.package_manager(), PackageManager::Bun) {
         return Err(Error::BunUnsupported);
     }
 
@@ -172,6 +184,9 @@ pub async fn prune(
             .join_component(lockfile_name)
             .create_with_contents(&lockfile_contents)?;
     }
+    if matches!(prune.package_graph.package_manager(), PackageManager::Berry) {
+        prune.copy_yarn_cache(lockfile.as_ref())?;
+    }
 
     for (relative_path, required_for_install) in ADDITIONAL_FILES.as_slice() {
         let path = relative_path.to_anchored_system_path_buf();
@@ -185,6 +200,17 @@ pub async fn prune(
 
     prune.copy_turbo_json(&workspace_names)?;
 
+    let configured_include = prune
+        .turbo_json
+        .as_ref()
+        .and_then(|turbo_json| turbo_json.prune.as_ref())
+        .and_then(|prune| prune.include.as_deref())
+        .unwrap_or_default();
+    prune.copy_included(configured_include.iter().chain(include))?;
+
+    let package_manager = prune.package_graph.package_manager();
+    let root_package_json = prune.package_graph.root_package_json();
+    let mut pruned_json = None;
     let original_patches = prune
         .package_graph
         .lockfile()
@@ -197,10 +223,29 @@ pub async fn prune(
             original_patches,
             pruned_patches
         );
-        let pruned_json = prune
-            .package_graph
-            .package_manager()
-            .prune_patched_packages(prune.package_graph.root_package_json(), &pruned_patches);
+        pruned_json =
+            Some(package_manager.prune_patched_packages(root_package_json, &pruned_patches));
+
+        for patch in pruned_patches {
+            prune.copy_file(
+                &patch.to_anchored_system_path_buf(),
+                Some(CopyDestination::Docker),
+            )?;
+        }
+    }
+
+    // Overrides for packages that were pruned are left out, since pnpm won't
+    // install if they don't match the ones in the pruned lockfile
+    if let Some(package_names) = lockfile.package_names() {
+        let json = pruned_json.as_ref().unwrap_or(root_package_json);
+        let pruned_overrides = package_manager.prune_overrides(json, &package_names);
+        if &pruned_overrides != json {
+            trace!("pruned overrides of root package.json");
+            pruned_json = Some(pruned_overrides);
+        }
+    }
+
+    if let Some(pruned_json) = pruned_json {
         let mut pruned_json_contents = serde_json::to_string_pretty(&pruned_json)?;
         // Add trailing newline to match Go behavior
         pruned_json_contents.push('\n');
@@ -221,20 +266,78 @@ pub async fn prune(
                 prune.docker_directory().resolve(package_json()),
             )?;
         }
-
-        for patch in pruned_patches {
-            prune.copy_file(
-                &patch.to_anchored_system_path_buf(),
-                Some(CopyDestination::Docker),
-            )?;
-        }
     } else {
         prune.copy_file(package_json(), Some(CopyDestination::Docker))?;
     }
 
+    if emit_dockerfile {
+        let contents = dockerfile(prune.package_graph.package_manager(), scope, output_dir);
+        prune
+            .out_directory
+            .join_component(DOCKERFILE)
+            .create_with_contents(contents)?;
+        println!(" - Wrote {output_dir}/{DOCKERFILE}");
+    }
+
     Ok(())
 }
 
+// A multi-stage Dockerfile that installs dependencies from the `json` layer,
+// so that they're only reinstalled when a package.json or the lockfile changes,
+// and then builds `scope` from the `full` layer. It's meant to be built with
+// the output directory as its context.
+fn dockerfile(package_manager: &PackageManager, scope: &[String], output_dir: &str) -> String {
+    // The node images don't come with bun
+    let image = match package_manager {
+        PackageManager::Bun => "oven/bun:1-alpine",
+        _ => "node:20-alpine",
+    };
+    let (setup, install, turbo) = match package_manager {
+        PackageManager::Npm => ("", "npm ci", "npx turbo"),
+        PackageManager::Yarn => ("", "yarn install --frozen-lockfile", "yarn turbo"),
+        PackageManager::Berry => (
+            "RUN corepack enable\n",
+            "yarn install --immutable",
+            "yarn turbo",
+        ),
+        PackageManager::Pnpm | PackageManager::Pnpm6 | PackageManager::Pnpm9 => (
+            "RUN corepack enable\n",
+            "pnpm install --frozen-lockfile",
+            "pnpm turbo",
+        ),
+        PackageManager::Bun => ("", "bun install --frozen-lockfile", "bunx turbo"),
+    };
+    let filters = scope
+        .iter()
+        .map(|package| format!("--filter={package}..."))
+        .collect::<Vec<_>>()
+        .join(" ");
+
+    format!(
+        r#"# Generated by `turbo prune {scope} --docker --emit-dockerfile`
+#
+# Build it from the pruned output:
+#   docker build -f {output_dir}/{DOCKERFILE} {output_dir}
+
+FROM {image} AS base
+RUN apk add --no-cache libc6-compat
+{setup}WORKDIR /app
+
+# Only the package.json files and the lockfile, so that dependencies are only
+# reinstalled when they change
+FROM base AS installer
+COPY json/ .
+RUN {install}
+
+FROM base AS builder
+COPY --from=installer /app/ .
+COPY full/ .
+RUN {turbo} run build {filters}
+"#,
+        scope = scope.join(" "),
+    )
+}
+
 struct Prune<'a> {
     package_graph: PackageGraph,
     root: AbsoluteSystemPathBuf,
@@ -242,6 +345,7 @@ struct Prune<'a> {
     full_directory: AbsoluteSystemPathBuf,
     docker: bool,
     scope: &'a [String],
+    turbo_json: Option<RawTurboJson>,
 }
 
 #[derive(Copy, Clone, PartialEq, Eq)]
@@ -268,6 +372,7 @@ impl<'a> Prune<'a> {
         let root_package_json = PackageJson::load(&root_package_json_path)?;
 
         let package_graph = PackageGraph::builder(&base.repo_root, root_package_json)
+            .with_workspace_providers(base.config()?.workspace_providers()?)
             .build()
             .await?;
 
@@ -302,6 +407,13 @@ impl<'a> Prune<'a> {
             return Err(Error::MissingLockfile);
         }
 
+        let raw_turbo_json = match base.repo_root.resolve(turbo_json()).read_to_string() {
+            Ok(contents) => Some(RawTurboJson::parse(&contents, turbo_json())?),
+            // If turbo.json doesn't exist there's nothing to copy
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => None,
+            Err(e) => return Err(e.into()),
+        };
+
         full_directory.resolve(package_json()).ensure_dir()?;
         if docker {
             out_directory
@@ -317,6 +429,7 @@ impl<'a> Prune<'a> {
             full_directory,
             docker,
             scope,
+            turbo_json: raw_turbo_json,
         })
     }
 
@@ -380,6 +493,33 @@ impl<'a> Prune<'a> {
         Ok(())
     }
 
+    /// Copies the archives of the pruned packages from Yarn's offline cache.
+    /// Plug'n'Play installs often commit the cache to skip downloading
+    /// packages, and the whole cache shouldn't end up in the output. The
+    /// `.pnp.cjs` loader and install state aren't copied as they list every
+    /// package, `yarn install` regenerates them from the pruned lockfile.
+    fn copy_yarn_cache(&self, lockfile: &dyn Lockfile) -> Result<(), Error> {
+        let cache_dir = AnchoredSystemPath::empty().join_components(&[".yarn", "cache"]);
+        let file_names = match std::fs::read_dir(self.root.resolve(&cache_dir)) {
+            Ok(entries) => entries
+                .map(|entry| Ok(entry?.file_name().to_string_lossy().into_owned()))
+                .collect::<Result<Vec<_>, std::io::Error>>()?,
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {
+                trace!("no yarn cache in repository, skipping copying");
+                return Ok(());
+            }
+            Err(e) => return Err(e.into()),
+        };
+
+        for file_name in lockfile.cache_files(file_names) {
+            self.copy_file(
+                &cache_dir.join_component(&file_name),
+                Some(CopyDestination::Docker),
+            )?;
+        }
+        Ok(())
+    }
+
     fn copy_workspace(&self, package_json_path: &AnchoredSystemPath) -> Result<(), Error> {
         let package_json_path = self.root.resolve(package_json_path);
         let original_dir = package_json_path
@@ -426,24 +566,85 @@ impl<'a> Prune<'a> {
     }
 
     fn copy_turbo_json(&self, workspaces: &[String]) -> Result<(), Error> {
-        let anchored_turbo_path = turbo_json();
-        let original_turbo_path = self.root.resolve(anchored_turbo_path);
-        let new_turbo_path = self.full_directory.resolve(anchored_turbo_path);
-
-        let turbo_json_contents = match original_turbo_path.read_to_string() {
-            Ok(contents) => contents,
-            Err(e) if e.kind() == std::io::ErrorKind::NotFound => {
-                // If turbo.json doesn't exist skip copying
-                return Ok(());
-            }
-            Err(e) => return Err(e.into()),
+        let Some(raw_turbo_json) = &self.turbo_json else {
+            return Ok(());
         };
+        let new_turbo_path = self.full_directory.resolve(turbo_json());
 
-        let turbo_json = RawTurboJson::parse(&turbo_json_contents, anchored_turbo_path)?;
-
-        let pruned_turbo_json = turbo_json.prune_tasks(workspaces);
+        let pruned_turbo_json = raw_turbo_json.prune_tasks(workspaces);
         new_turbo_path.create_with_contents(serde_json::to_string_pretty(&pruned_turbo_json)?)?;
 
         Ok(())
     }
+
+    /// Copies the files matching the `include` globs into the full output. A
+    /// path to a directory copies everything in it.
+    fn copy_included<'b>(&self, include: impl Iterator<Item = &'b String>) -> Result<(), Error> {
+        let globs = include
+            .map(|glob| {
+                let glob = glob.trim_end_matches('/');
+                let is_dir = self
+                    .root
+                    .join_unix_path(RelativeUnixPath::new(glob)?)
+                    .as_std_path()
+                    .is_dir();
+                let glob = match is_dir {
+                    true => format!("{glob}/**"),
+                    false => glob.to_string(),
+                };
+                Ok(ValidatedGlob::from_str(&glob)?)
+            })
+            .collect::<Result<Vec<_>, Error>>()?;
+        if globs.is_empty() {
+            return Ok(());
+        }
+
+        let mut files = globwalk::globwalk(&self.root, &globs, &[], WalkType::Files)?
+            .into_iter()
+            .map(|path| AnchoredSystemPathBuf::new(&self.root, path))
+            .collect::<Result<Vec<_>, _>>()?;
+        files.sort();
+        for file in files {
+            trace!("including {file}");
+            // Included files are often needed to install, e.g. patches, so
+            // with `--docker` they also go into the json directory
+            self.copy_file(&file, Some(CopyDestination::Docker))?;
+        }
+
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use turborepo_repository::package_manager::PackageManager;
+
+    use super::dockerfile;
+
+    #[test]
+    fn test_bun_dockerfile() {
+        assert_eq!(
+            dockerfile(&PackageManager::Bun, &["web".to_string()], "out"),
+            r#"# Generated by `turbo prune web --docker --emit-dockerfile`
+#
+# Build it from the pruned output:
+#   docker build -f out/Dockerfile out
+
+FROM oven/bun:1-alpine AS base
+RUN apk add --no-cache libc6-compat
+WORKDIR /app
+
+# Only the package.json files and the lockfile, so that dependencies are only
+# reinstalled when they change
+FROM base AS installer
+COPY json/ .
+RUN bun install --frozen-lockfile
+
+FROM base AS builder
+COPY --from=installer /app/ .
+COPY full/ .
+RUN bunx turbo run build --filter=web...
+"#
+        );
+    }
 }
@@ -0,0 +1,119 @@
+//! `turbo remote-cache` checks that the configured remote cache works before
+//! a run depends on it.
+
+use miette::Diagnostic;
+use thiserror::Error;
+use turbopath::AbsoluteSystemPath;
+use turborepo_api_client::{APIAuth, APIClient};
+use turborepo_cache::{
+    remote::{RemoteCache, RoundTrip},
+    CacheError, CacheOpts, RemoteCacheOpts,
+};
+use turborepo_ui::{color, BOLD_GREEN, BOLD_RED, GREY, UI};
+
+use super::CommandBase;
+use crate::{
+    cli::RemoteCacheCommand,
+    config::{self, ConfigurationOptions},
+};
+
+// Task hashes are hexadecimal, so this can't replace the artifact of a task.
+// Every verification uses the same hash so that caches that can't delete the
+// test artifact keep a single one of them.
+const VERIFICATION_HASH: &str = "turbo-remote-cache-verification";
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Config(#[from] config::Error),
+    #[error(transparent)]
+    Cache(#[from] CacheError),
+    #[error("no remote cache is configured, run `npx turbo link` to use Remote Caching")]
+    NotConfigured,
+}
+
+/// Runs a `turbo remote-cache` subcommand and returns the exit code.
+pub async fn run(base: &CommandBase, command: &RemoteCacheCommand) -> Result<i32, Error> {
+    match command {
+        RemoteCacheCommand::Verify => {
+            let config = base.config()?;
+            let api_auth = base.api_auth()?;
+            let opts = cache_opts(config)?;
+            let location = match &opts.s3_opts {
+                Some(s3_opts) => format!("s3://{}", s3_opts.bucket),
+                None if turborepo_api_client::is_linked(&api_auth) => config.api_url().to_string(),
+                None => return Err(Error::NotConfigured),
+            };
+            let remote_cache =
+                RemoteCache::new(&opts, &base.repo_root, base.api_client()?, api_auth, None)?
+                    .ok_or(Error::NotConfigured)?;
+
+            let verified = verify(base.ui, &remote_cache, &base.repo_root, &location).await;
+            Ok(if verified { 0 } else { 1 })
+        }
+    }
+}
+
+/// Verifies the Vercel Remote Cache with credentials that were just created
+/// by `turbo link`, which aren't in the loaded config yet.
+pub async fn verify_link(
+    base: &CommandBase,
+    api_client: APIClient,
+    api_auth: APIAuth,
+) -> Result<bool, Error> {
+    let config = base.config()?;
+    let opts = CacheOpts {
+        s3_opts: None,
+        ..cache_opts(config)?
+    };
+    let remote_cache = RemoteCache::new(&opts, &base.repo_root, api_client, Some(api_auth), None)?
+        .ok_or(Error::NotConfigured)?;
+
+    Ok(verify(base.ui, &remote_cache, &base.repo_root, config.api_url()).await)
+}
+
+fn cache_opts(config: &ConfigurationOptions) -> Result<CacheOpts, config::Error> {
+    Ok(CacheOpts {
+        s3_opts: config.s3_opts()?,
+        compression_level: config.cache_compression_level()?,
+        remote_cache_opts: Some(RemoteCacheOpts::new(
+            config.team_id().map(|team_id| team_id.to_string()),
+            config.signature(),
+        )),
+        ..CacheOpts::default()
+    })
+}
+
+/// Uploads an empty artifact, downloads it again and deletes it, printing how
+/// long the upload and download took. Returns whether they succeeded.
+async fn verify(
+    ui: UI,
+    remote_cache: &RemoteCache,
+    repo_root: &AbsoluteSystemPath,
+    location: &str,
+) -> bool {
+    println!(
+        "Verifying remote cache at {}",
+        color!(ui, GREY, "{location}")
+    );
+    match remote_cache.round_trip(repo_root, VERIFICATION_HASH).await {
+        Ok(RoundTrip { upload, download }) => {
+            println!(
+                "{} uploaded a test artifact in {}ms",
+                color!(ui, BOLD_GREEN, "✓"),
+                upload.as_millis()
+            );
+            println!(
+                "{} downloaded the test artifact in {}ms",
+                color!(ui, BOLD_GREEN, "✓"),
+                download.as_millis()
+            );
+            true
+        }
+        Err(e) => {
+            println!("{} {e}", color!(ui, BOLD_RED, "x"));
+            false
+        }
+    }
+}
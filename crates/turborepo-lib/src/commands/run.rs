@@ -3,7 +3,12 @@ use std::future::Future;
 use tracing::error;
 use turborepo_telemetry::events::command::CommandEventBuilder;
 
-use crate::{commands::CommandBase, run, run::builder::RunBuilder, signal::SignalHandler};
+use crate::{
+    commands::CommandBase,
+    run,
+    run::{builder::RunBuilder, Run},
+    signal::SignalHandler,
+};
 
 #[cfg(windows)]
 pub fn get_signal() -> Result<impl Future<Output = Option<()>>, run::Error> {
@@ -44,21 +49,12 @@ pub async fn run(base: CommandBase, telemetry: CommandEventBuilder) -> Result<i3
             .build(&handler, telemetry)
             .await?;
 
-        let (sender, handle) = run.start_experimental_ui()?.unzip();
-
-        let result = run.run(sender.clone()).await;
+        let result = execute(&mut run).await;
 
         if let Some(analytics_handle) = analytics_handle {
             analytics_handle.close_with_timeout().await;
         }
 
-        if let (Some(handle), Some(sender)) = (handle, sender) {
-            sender.stop();
-            if let Err(e) = handle.await.expect("render thread panicked") {
-                error!("error encountered rendering tui: {e}");
-            }
-        }
-
         result
     };
 
@@ -78,3 +74,38 @@ pub async fn run(base: CommandBase, telemetry: CommandEventBuilder) -> Result<i3
         },
     }
 }
+
+/// Runs the tasks of a built run, rendering them with the experimental UI if
+/// it's enabled
+pub async fn execute(run: &mut Run) -> Result<i32, run::Error> {
+    let (sender, handle) = run.start_experimental_ui()?.unzip();
+    let processes = run.processes();
+
+    let run_fut = run.run(sender.clone());
+    let Some(mut handle) = handle else {
+        return run_fut.await;
+    };
+
+    tokio::pin!(run_fut);
+    let (result, rendered) = tokio::select! {
+        result = &mut run_fut => {
+            if let Some(sender) = &sender {
+                sender.stop();
+            }
+            (result, handle.await)
+        }
+        rendered = &mut handle => {
+            // The UI only stops on its own when it's cancelled but can't interrupt us, or
+            // when it can't read input. Either way the tasks would otherwise keep running
+            // without anything to stop them.
+            processes.stop().await;
+            (run_fut.await, rendered)
+        }
+    };
+
+    if let Err(e) = rendered.expect("render thread panicked") {
+        error!("error encountered rendering tui: {e}");
+    }
+
+    result
+}
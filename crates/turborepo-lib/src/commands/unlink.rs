@@ -16,8 +16,9 @@ enum UnlinkSpacesResult {
 }
 
 fn unlink_remote_caching(base: &mut CommandBase) -> Result<(), cli::Error> {
-    let needs_disabling =
-        base.config()?.team_id().is_some() || base.config()?.team_slug().is_some();
+    let needs_disabling = base.config()?.team_id().is_some()
+        || base.config()?.team_slug().is_some()
+        || base.config()?.link_profile().is_some();
 
     let output = if needs_disabling {
         let local_config_path = base.local_config_path();
@@ -30,6 +31,7 @@ fn unlink_remote_caching(base: &mut CommandBase) -> Result<(), cli::Error> {
             })?;
         let no_id = unset_path(&before, &["teamid"], false)?.unwrap_or(before);
         let no_slug = unset_path(&no_id, &["teamslug"], false)?.unwrap_or(no_id);
+        let no_profile = unset_path(&no_slug, &["linkprofile"], false)?.unwrap_or(no_slug);
 
         local_config_path
             .ensure_dir()
@@ -39,7 +41,7 @@ fn unlink_remote_caching(base: &mut CommandBase) -> Result<(), cli::Error> {
             })?;
 
         local_config_path
-            .create_with_contents(no_slug)
+            .create_with_contents(no_profile)
             .map_err(|error| config::Error::FailedToSetConfig {
                 config_path: local_config_path.clone(),
                 error,
@@ -99,10 +101,68 @@ fn unlink_spaces(base: &mut CommandBase) -> Result<(), cli::Error> {
     Ok(())
 }
 
-pub fn unlink(base: &mut CommandBase, target: LinkTarget) -> Result<(), cli::Error> {
+// Removes the link profile named `profile` from the global config, or all of
+// them if no name is given
+fn remove_link_profiles(base: &CommandBase, profile: Option<&str>) -> Result<(), cli::Error> {
+    let global_config_path = base.global_config_path()?;
+    let profiles = config::read_link_profiles(&global_config_path)?;
+    let output = match profile {
+        Some(profile) if !profiles.contains_key(profile) => {
+            format!("> No link profile named {profile} found")
+        }
+        _ if profiles.is_empty() => "> No link profiles found".to_string(),
+        _ => {
+            let before = global_config_path
+                .read_existing_to_string_or(Ok("{}"))
+                .map_err(|error| config::Error::FailedToReadConfig {
+                    config_path: global_config_path.clone(),
+                    error,
+                })?;
+            let path = match profile {
+                Some(profile) => vec![config::LINK_PROFILES_KEY, profile],
+                None => vec![config::LINK_PROFILES_KEY],
+            };
+            let after = unset_path(&before, &path, true)?.unwrap_or(before);
+            global_config_path
+                .create_with_contents(after)
+                .map_err(|error| config::Error::FailedToSetConfig {
+                    config_path: global_config_path.clone(),
+                    error,
+                })?;
+
+            match profile {
+                Some(profile) => format!("> Removed link profile {profile}"),
+                None => format!("> Removed {} link profiles", profiles.len()),
+            }
+        }
+    };
+
+    println!("{}", base.ui.apply(GREY.apply_to(output)));
+
+    Ok(())
+}
+
+/// Unlinks the repository from `target`. With `all`, every saved link profile
+/// is removed as well. With `profile`, only that profile is removed, and the
+/// repository is unlinked only if it's linked with it.
+pub fn unlink(
+    base: &mut CommandBase,
+    target: LinkTarget,
+    all: bool,
+    profile: Option<&str>,
+) -> Result<(), cli::Error> {
     match target {
         LinkTarget::RemoteCache => {
-            unlink_remote_caching(base)?;
+            let uses_profile = match profile {
+                Some(profile) => base.config()?.link_profile() == Some(profile),
+                None => true,
+            };
+            if uses_profile {
+                unlink_remote_caching(base)?;
+            }
+            if all || profile.is_some() {
+                remove_link_profiles(base, profile)?;
+            }
         }
         LinkTarget::Spaces => {
             unlink_spaces(base)?;
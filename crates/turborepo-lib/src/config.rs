@@ -1,21 +1,27 @@
 use std::{
-    collections::HashMap,
+    collections::{BTreeMap, HashMap},
     ffi::{OsStr, OsString},
     io,
 };
 
+use camino::{Utf8Path, Utf8PathBuf};
 use convert_case::{Case, Casing};
 use miette::{Diagnostic, NamedSource, SourceSpan};
-use serde::Deserialize;
+use serde::{Deserialize, Serialize};
 use struct_iterable::Iterable;
 use thiserror::Error;
-use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPath};
+use tracing::warn;
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath};
 use turborepo_auth::{TURBO_TOKEN_DIR, TURBO_TOKEN_FILE, VERCEL_TOKEN_DIR, VERCEL_TOKEN_FILE};
+use turborepo_cache::{s3::S3CacheOpts, CacheTier};
 use turborepo_dirs::{config_dir, vercel_config_dir};
 use turborepo_errors::TURBO_SITE;
+use turborepo_repository::package_graph::{
+    provider::Error as WorkspaceProviderError, WorkspaceProvider,
+};
 
 pub use crate::turbo_json::RawTurboJson;
-use crate::{commands::CommandBase, turbo_json};
+use crate::{commands::CommandBase, turbo_json, turbo_json::RemoteCacheProvider};
 
 #[derive(Debug, Error, Diagnostic)]
 #[error("Environment variables should not be prefixed with \"{env_pipeline_delimiter}\"")]
@@ -99,13 +105,35 @@ pub enum Error {
         #[source_code]
         text: NamedSource,
     },
-    #[error("You can only extend from the root workspace")]
+    #[error("You can only extend from the root workspace and shareable configs")]
+    #[diagnostic(help("\"//\" must be the first entry in \"extends\" and can't be repeated"))]
     ExtendFromNonRoot {
         #[label("non-root workspace found here")]
         span: Option<SourceSpan>,
         #[source_code]
         text: NamedSource,
     },
+    #[error("Could not find a turbo.json for \"{package_name}\" in node_modules")]
+    #[diagnostic(help(
+        "make sure the package is installed and has a turbo.json file next to its package.json"
+    ))]
+    ExtendedConfigNotFound {
+        package_name: String,
+        #[label("extended here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Could not find profile \"{name}\" in turbo.json")]
+    #[diagnostic(help("profiles are defined under \"profiles\" in the root turbo.json"))]
+    MissingProfile { name: String },
+    #[error("Shareable configs cannot extend other configs")]
+    ExtendFromSharedConfig {
+        #[label("extends found here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
     #[error("`{field}` cannot contain an environment variable")]
     InvalidDependsOnValue {
         field: &'static str,
@@ -122,6 +150,14 @@ pub enum Error {
         #[source_code]
         text: NamedSource,
     },
+    #[error("`$TURBO_ROOT$` can only be used at the start of an input")]
+    #[diagnostic(help("use `$TURBO_ROOT$/` followed by a path relative to the repository root"))]
+    InvalidTurboRootUse {
+        #[label("`$TURBO_ROOT$` used here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
     #[error("No \"extends\" key found")]
     NoExtends {
         #[label("add extends key here")]
@@ -136,6 +172,111 @@ pub enum Error {
         #[source_code]
         text: NamedSource,
     },
+    #[error("Persistent tasks cannot be part of a concurrency group")]
+    #[diagnostic(help(
+        "persistent tasks never exit, so no other task in the group would be able to run"
+    ))]
+    PersistentConcurrencyGroup {
+        #[label("concurrency group set here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("`ready` can only be set on persistent tasks")]
+    #[diagnostic(help(
+        "tasks that aren't persistent are ready once they exit, set `\"persistent\": true` if \
+         this task doesn't exit"
+    ))]
+    ReadyWithoutPersistent {
+        #[label("readiness probe set here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("`ready` must set exactly one of `port`, `logPattern` or `command`")]
+    InvalidReadyDefinition {
+        #[label("readiness probe set here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Invalid `logPattern` in `ready`: {err}")]
+    InvalidReadyPattern {
+        err: regex::Error,
+        #[label("invalid pattern")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Unknown input transform `{name}`")]
+    #[diagnostic(help("available transforms are: {valid}"))]
+    UnknownInputTransform {
+        name: String,
+        valid: String,
+        #[label("unknown transform")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Tool dependencies cannot be empty")]
+    #[diagnostic(help("provide a command that prints a version, e.g. `node --version`"))]
+    EmptyToolDependency {
+        #[label("empty command")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("`override` can only be used in a package's turbo.json")]
+    #[diagnostic(help(
+        "tasks in the root turbo.json don't inherit a definition, so there is nothing to override"
+    ))]
+    OverrideInRootTurboJson {
+        #[label("remove `override`")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Invalid task `timeout`: {reason}")]
+    #[diagnostic(help("use a duration such as `30s`, `10m` or `1h 30m`"))]
+    InvalidTaskTimeout {
+        reason: String,
+        #[label("invalid timeout")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Invalid task `compression`")]
+    #[diagnostic(help("use `zstd` or `zstd:<level>` with a level between 1 and 22"))]
+    InvalidTaskCompression {
+        #[label("invalid compression")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Task `runner` cannot be empty")]
+    #[diagnostic(help("provide a command that runs scripts, e.g. `bun run`"))]
+    EmptyTaskRunner {
+        #[label("empty runner")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Task `shell` must have a program")]
+    #[diagnostic(help("provide a shell such as `bash` or `pwsh`, and close any quotes"))]
+    InvalidTaskShell {
+        #[label("invalid shell")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("Task weight must be at least 1")]
+    #[diagnostic(help("use 1 for tasks that can share the concurrency limit with others"))]
+    ZeroTaskWeight {
+        #[label("weight of 0")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
     #[error("found `pipeline` field instead of `tasks`")]
     #[diagnostic(help("changed in 2.0: `pipeline` has been renamed to `tasks`"))]
     PipelineField {
@@ -156,8 +297,39 @@ pub enum Error {
     InvalidRemoteCacheTimeout(#[source] std::num::ParseIntError),
     #[error("TURBO_REMOTE_CACHE_UPLOAD_TIMEOUT: error parsing timeout.")]
     InvalidUploadTimeout(#[source] std::num::ParseIntError),
+    #[error("TURBO_REMOTE_CACHE_DOWNLOAD_TIMEOUT: error parsing timeout.")]
+    InvalidDownloadTimeout(#[source] std::num::ParseIntError),
+    #[error("TURBO_REMOTE_CACHE_CONNECT_TIMEOUT: error parsing timeout.")]
+    InvalidConnectTimeout(#[source] std::num::ParseIntError),
+    #[error("TURBO_REMOTE_CACHE_RETRY_ATTEMPTS: error parsing retry attempts.")]
+    InvalidRetryAttempts(#[source] std::num::ParseIntError),
+    #[error("TURBO_REMOTE_CACHE_RETRY_BACKOFF: error parsing retry backoff.")]
+    InvalidRetryBackoff(#[source] std::num::ParseIntError),
+    #[error("TURBO_REMOTE_CACHE_RETRY_MAX_ELAPSED: error parsing retry max elapsed time.")]
+    InvalidRetryMaxElapsed(#[source] std::num::ParseIntError),
+    #[error("TURBO_REMOTE_CACHE_FAILURE_LIMIT: error parsing failure limit.")]
+    InvalidFailureLimit(#[source] std::num::ParseIntError),
     #[error("TURBO_PREFLIGHT should be either 1 or 0.")]
     InvalidPreflight,
+    #[error("Failed to read certificate authorities from {path}: {error}")]
+    FailedToReadCaCert {
+        path: String,
+        #[source]
+        error: std::io::Error,
+    },
+    #[error("`remoteCache.bucket` must be set when using the S3 remote cache provider")]
+    MissingS3Bucket,
+    #[error("The remote cache cannot be both read-only and write-only")]
+    RemoteCacheReadAndWriteOnly,
+    #[error(
+        "invalid cache compression `{0}`. Expected `zstd` or `zstd:<level>` with a level between \
+         1 and 22"
+    )]
+    InvalidCacheCompression(String),
+    #[error("every cache tier in `cacheOptions.tiers` needs a `dir`")]
+    MissingCacheTierDir,
+    #[error(transparent)]
+    InvalidWorkspaceProvider(#[from] WorkspaceProviderError),
     #[error(transparent)]
     #[diagnostic(transparent)]
     TurboJsonParseError(#[from] turbo_json::parser::Error),
@@ -176,6 +348,9 @@ const DEFAULT_API_URL: &str = "https://vercel.com/api";
 const DEFAULT_LOGIN_URL: &str = "https://vercel.com";
 const DEFAULT_TIMEOUT: u64 = 30;
 const DEFAULT_UPLOAD_TIMEOUT: u64 = 60;
+const DEFAULT_DOWNLOAD_TIMEOUT: u64 = 60;
+const DEFAULT_RETRY_ATTEMPTS: u32 = 2;
+const DEFAULT_RETRY_BACKOFF: u64 = 2;
 
 // We intentionally don't derive Serialize so that different parts
 // of the code that want to display the config can tune how they
@@ -204,10 +379,69 @@ pub struct ConfigurationOptions {
     pub(crate) preflight: Option<bool>,
     pub(crate) timeout: Option<u64>,
     pub(crate) upload_timeout: Option<u64>,
+    pub(crate) download_timeout: Option<u64>,
+    pub(crate) connect_timeout: Option<u64>,
+    pub(crate) ca_cert: Option<String>,
+    pub(crate) insecure_skip_tls_verify: Option<bool>,
+    pub(crate) retry_attempts: Option<u32>,
+    pub(crate) retry_backoff: Option<u64>,
+    pub(crate) retry_max_elapsed: Option<u64>,
+    pub(crate) failure_limit: Option<u32>,
     pub(crate) enabled: Option<bool>,
+    pub(crate) read_only: Option<bool>,
+    pub(crate) write_only: Option<bool>,
     pub(crate) spaces_id: Option<String>,
     #[serde(rename = "ui")]
     pub(crate) ui: Option<bool>,
+    pub(crate) provider: Option<RemoteCacheProvider>,
+    pub(crate) bucket: Option<String>,
+    pub(crate) region: Option<String>,
+    pub(crate) endpoint: Option<String>,
+    pub(crate) prefix: Option<String>,
+    pub(crate) cache_compression: Option<String>,
+    pub(crate) cache_dir: Option<Utf8PathBuf>,
+    pub(crate) cache_tiers: Option<Vec<CacheTier>>,
+    pub(crate) workspace_providers: Option<Vec<String>>,
+    // The link profile from the global config that the repository is linked with
+    pub(crate) link_profile: Option<String>,
+}
+
+/// A team and the token to use for it, saved in the global config with
+/// `turbo link --profile` so that repositories can be linked to teams of
+/// different accounts
+#[derive(Serialize, Deserialize, Debug, PartialEq, Eq, Clone)]
+#[serde(rename_all = "camelCase")]
+pub struct LinkProfile {
+    pub team_id: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub team_slug: Option<String>,
+    pub token: String,
+}
+
+pub(crate) const LINK_PROFILES_KEY: &str = "linkProfiles";
+
+#[derive(Deserialize, Default)]
+#[serde(rename_all = "camelCase")]
+struct GlobalLinkProfiles {
+    #[serde(default)]
+    link_profiles: BTreeMap<String, LinkProfile>,
+}
+
+/// Reads the link profiles saved in the global config, keyed by their names
+pub fn read_link_profiles(
+    global_config_path: &AbsoluteSystemPath,
+) -> Result<BTreeMap<String, LinkProfile>, Error> {
+    let contents = global_config_path
+        .read_existing_to_string_or(Ok("{}"))
+        .map_err(|error| Error::FailedToReadConfig {
+            config_path: global_config_path.to_owned(),
+            error,
+        })?;
+    if contents.is_empty() {
+        return Ok(BTreeMap::new());
+    }
+    let global_config: GlobalLinkProfiles = serde_json::from_str(&contents)?;
+    Ok(global_config.link_profiles)
 }
 
 #[derive(Default)]
@@ -245,6 +479,10 @@ impl ConfigurationOptions {
         non_empty_str(self.token.as_deref())
     }
 
+    pub fn link_profile(&self) -> Option<&str> {
+        non_empty_str(self.link_profile.as_deref())
+    }
+
     pub fn signature(&self) -> bool {
         self.signature.unwrap_or_default()
     }
@@ -257,6 +495,16 @@ impl ConfigurationOptions {
         self.preflight.unwrap_or_default()
     }
 
+    /// Only read artifacts from the remote cache, never upload them
+    pub fn read_only(&self) -> bool {
+        self.read_only.unwrap_or_default()
+    }
+
+    /// Only upload artifacts to the remote cache, never read them
+    pub fn write_only(&self) -> bool {
+        self.write_only.unwrap_or_default()
+    }
+
     /// Note: 0 implies no timeout
     pub fn timeout(&self) -> u64 {
         self.timeout.unwrap_or(DEFAULT_TIMEOUT)
@@ -267,6 +515,51 @@ impl ConfigurationOptions {
         self.upload_timeout.unwrap_or(DEFAULT_UPLOAD_TIMEOUT)
     }
 
+    /// Time allowed to download every started 100MB of an artifact
+    /// Note: 0 implies no timeout
+    pub fn download_timeout(&self) -> u64 {
+        self.download_timeout.unwrap_or(DEFAULT_DOWNLOAD_TIMEOUT)
+    }
+
+    /// Time allowed to connect to the remote cache, defaults to `timeout`
+    /// Note: 0 implies no timeout
+    pub fn connect_timeout(&self) -> u64 {
+        self.connect_timeout.unwrap_or_else(|| self.timeout())
+    }
+
+    /// Path to a PEM file of certificate authorities to trust in addition to
+    /// the system's
+    pub fn ca_cert(&self) -> Option<&str> {
+        non_empty_str(self.ca_cert.as_deref())
+    }
+
+    pub fn insecure_skip_tls_verify(&self) -> bool {
+        self.insecure_skip_tls_verify.unwrap_or_default()
+    }
+
+    /// Total number of attempts for a remote cache request, including the
+    /// first one
+    pub fn retry_attempts(&self) -> u32 {
+        self.retry_attempts.unwrap_or(DEFAULT_RETRY_ATTEMPTS).max(1)
+    }
+
+    /// Delay in seconds before the first retry
+    pub fn retry_backoff(&self) -> u64 {
+        self.retry_backoff.unwrap_or(DEFAULT_RETRY_BACKOFF)
+    }
+
+    /// Note: 0 implies no limit
+    pub fn retry_max_elapsed(&self) -> u64 {
+        self.retry_max_elapsed.unwrap_or_default()
+    }
+
+    /// Number of remote cache requests in a row that have to be unable to
+    /// reach it before it's skipped for the rest of the run. `None` uses the
+    /// cache's default, and 0 never skips it.
+    pub fn failure_limit(&self) -> Option<u32> {
+        self.failure_limit
+    }
+
     pub fn spaces_id(&self) -> Option<&str> {
         self.spaces_id.as_deref()
     }
@@ -274,6 +567,59 @@ impl ConfigurationOptions {
     pub fn ui(&self) -> bool {
         self.ui.unwrap_or(true) && atty::is(atty::Stream::Stdout)
     }
+
+    pub fn provider(&self) -> RemoteCacheProvider {
+        self.provider.unwrap_or_default()
+    }
+
+    /// Returns the zstd level that cache artifacts should be compressed with.
+    /// A level of 0 uses zstd's default.
+    pub fn cache_compression_level(&self) -> Result<i32, Error> {
+        let Some(compression) = non_empty_str(self.cache_compression.as_deref()) else {
+            return Ok(0);
+        };
+
+        compression_level(compression)
+            .ok_or_else(|| Error::InvalidCacheCompression(compression.to_string()))
+    }
+
+    /// The directory of the local cache, relative to the repository root
+    pub fn cache_dir(&self) -> Option<&Utf8Path> {
+        self.cache_dir
+            .as_deref()
+            .filter(|cache_dir| !cache_dir.as_str().is_empty())
+    }
+
+    /// Local cache directories that are searched after `cache_dir`, in order
+    pub fn cache_tiers(&self) -> &[CacheTier] {
+        self.cache_tiers.as_deref().unwrap_or_default()
+    }
+
+    /// Returns the providers of non-JavaScript workspaces whose members are
+    /// added to the package graph
+    pub fn workspace_providers(&self) -> Result<Vec<WorkspaceProvider>, Error> {
+        self.workspace_providers
+            .iter()
+            .flatten()
+            .map(|provider| Ok(provider.parse::<WorkspaceProvider>()?))
+            .collect()
+    }
+
+    /// Returns the options for the S3 remote cache if it is the configured
+    /// provider.
+    pub fn s3_opts(&self) -> Result<Option<S3CacheOpts>, Error> {
+        if self.provider() != RemoteCacheProvider::S3 {
+            return Ok(None);
+        }
+
+        let bucket = non_empty_str(self.bucket.as_deref()).ok_or(Error::MissingS3Bucket)?;
+        Ok(Some(S3CacheOpts {
+            bucket: bucket.to_string(),
+            region: non_empty_str(self.region.as_deref()).map(|s| s.to_string()),
+            endpoint: non_empty_str(self.endpoint.as_deref()).map(|s| s.to_string()),
+            prefix: non_empty_str(self.prefix.as_deref()).map(|s| s.to_string()),
+        }))
+    }
 }
 
 // Maps Some("") to None to emulate how Go handles empty strings
@@ -299,6 +645,29 @@ impl ResolvedConfigurationOptions for RawTurboJson {
             .and_then(|spaces| spaces.id)
             .map(|spaces_id| spaces_id.into());
         opts.ui = self.ui.map(|ui| ui.use_tui());
+        opts.cache_dir = self.cache_dir.map(Utf8PathBuf::from);
+        if let Some(cache_options) = self.cache_options {
+            opts.cache_compression = cache_options.compression;
+            opts.cache_tiers = cache_options
+                .tiers
+                .map(|tiers| {
+                    tiers
+                        .into_iter()
+                        .map(|tier| {
+                            Ok(CacheTier {
+                                dir: tier
+                                    .dir
+                                    .filter(|dir| !dir.is_empty())
+                                    .map(Utf8PathBuf::from)
+                                    .ok_or(Error::MissingCacheTierDir)?,
+                                read_only: tier.read_only.unwrap_or_default(),
+                            })
+                        })
+                        .collect::<Result<Vec<_>, Error>>()
+                })
+                .transpose()?;
+        }
+        opts.workspace_providers = self.experimental_workspace_providers;
         Ok(opts)
     }
 }
@@ -316,6 +685,19 @@ fn get_lowercased_env_vars() -> HashMap<OsString, OsString> {
         .collect()
 }
 
+/// Parses a compression setting such as `zstd:3` into a zstd level. `zstd`
+/// on its own uses zstd's default, which is level 0.
+pub(crate) fn compression_level(compression: &str) -> Option<i32> {
+    match compression.split_once(':') {
+        None if compression == "zstd" => Some(0),
+        Some(("zstd", level)) => level
+            .parse::<i32>()
+            .ok()
+            .filter(|level| (1..=22).contains(level)),
+        _ => None,
+    }
+}
+
 fn get_env_var_config(
     environment: &HashMap<OsString, OsString>,
 ) -> Result<ConfigurationOptions, Error> {
@@ -330,8 +712,37 @@ fn get_env_var_config(
         OsString::from("turbo_remote_cache_upload_timeout"),
         "upload_timeout",
     );
+    turbo_mapping.insert(
+        OsString::from("turbo_remote_cache_download_timeout"),
+        "download_timeout",
+    );
+    turbo_mapping.insert(
+        OsString::from("turbo_remote_cache_connect_timeout"),
+        "connect_timeout",
+    );
+    turbo_mapping.insert(
+        OsString::from("turbo_remote_cache_retry_attempts"),
+        "retry_attempts",
+    );
+    turbo_mapping.insert(
+        OsString::from("turbo_remote_cache_retry_backoff"),
+        "retry_backoff",
+    );
+    turbo_mapping.insert(
+        OsString::from("turbo_remote_cache_retry_max_elapsed"),
+        "retry_max_elapsed",
+    );
+    turbo_mapping.insert(
+        OsString::from("turbo_remote_cache_failure_limit"),
+        "failure_limit",
+    );
+    turbo_mapping.insert(OsString::from("turbo_ca_cert"), "ca_cert");
     turbo_mapping.insert(OsString::from("turbo_ui"), "ui");
     turbo_mapping.insert(OsString::from("turbo_preflight"), "preflight");
+    turbo_mapping.insert(
+        OsString::from("turbo_cache_compression"),
+        "cache_compression",
+    );
 
     // We do not enable new config sources:
     // turbo_mapping.insert(String::from("turbo_signature"), "signature"); // new
@@ -411,6 +822,42 @@ fn get_env_var_config(
         None
     };
 
+    let download_timeout = output_map
+        .get("download_timeout")
+        .map(|download_timeout| download_timeout.parse::<u64>())
+        .transpose()
+        .map_err(Error::InvalidDownloadTimeout)?;
+
+    let connect_timeout = output_map
+        .get("connect_timeout")
+        .map(|connect_timeout| connect_timeout.parse::<u64>())
+        .transpose()
+        .map_err(Error::InvalidConnectTimeout)?;
+
+    let retry_attempts = output_map
+        .get("retry_attempts")
+        .map(|retry_attempts| retry_attempts.parse::<u32>())
+        .transpose()
+        .map_err(Error::InvalidRetryAttempts)?;
+
+    let retry_backoff = output_map
+        .get("retry_backoff")
+        .map(|retry_backoff| retry_backoff.parse::<u64>())
+        .transpose()
+        .map_err(Error::InvalidRetryBackoff)?;
+
+    let retry_max_elapsed = output_map
+        .get("retry_max_elapsed")
+        .map(|retry_max_elapsed| retry_max_elapsed.parse::<u64>())
+        .transpose()
+        .map_err(Error::InvalidRetryMaxElapsed)?;
+
+    let failure_limit = output_map
+        .get("failure_limit")
+        .map(|failure_limit| failure_limit.parse::<u32>())
+        .transpose()
+        .map_err(Error::InvalidFailureLimit)?;
+
     // Process experimentalUI
     let ui = output_map.get("ui").and_then(|val| match val.as_str() {
         "true" | "1" => Some(true),
@@ -429,6 +876,7 @@ fn get_env_var_config(
         team_slug: output_map.get("team_slug").cloned(),
         team_id: output_map.get("team_id").cloned(),
         token: output_map.get("token").cloned(),
+        ca_cert: output_map.get("ca_cert").cloned(),
 
         // Processed booleans
         signature,
@@ -439,7 +887,37 @@ fn get_env_var_config(
         // Processed numbers
         timeout,
         upload_timeout,
+        download_timeout,
+        connect_timeout,
+        retry_attempts,
+        retry_backoff,
+        retry_max_elapsed,
+        failure_limit,
         spaces_id,
+
+        // Skipping TLS verification is only allowed from the CLI
+        insecure_skip_tls_verify: None,
+
+        // Remote cache provider settings are only read from turbo.json
+        read_only: None,
+        write_only: None,
+        provider: None,
+        bucket: None,
+        region: None,
+        endpoint: None,
+        prefix: None,
+
+        cache_compression: output_map.get("cache_compression").cloned(),
+
+        // The cache directory is read from `--cache-dir` and `TURBO_CACHE_DIR` by the CLI
+        cache_dir: None,
+        cache_tiers: None,
+
+        // Workspace providers are only read from turbo.json
+        workspace_providers: None,
+
+        // Link profiles are only read from the local config
+        link_profile: None,
     };
 
     Ok(output)
@@ -494,10 +972,30 @@ fn get_override_env_var_config(
         signature: None,
         preflight: None,
         enabled: None,
+        read_only: None,
+        write_only: None,
         ui,
         timeout: None,
         upload_timeout: None,
+        download_timeout: None,
+        connect_timeout: None,
+        ca_cert: None,
+        insecure_skip_tls_verify: None,
+        retry_attempts: None,
+        retry_backoff: None,
+        retry_max_elapsed: None,
+        failure_limit: None,
         spaces_id: None,
+        provider: None,
+        bucket: None,
+        region: None,
+        endpoint: None,
+        prefix: None,
+        cache_compression: None,
+        cache_dir: None,
+        cache_tiers: None,
+        workspace_providers: None,
+        link_profile: None,
     };
 
     Ok(output)
@@ -623,6 +1121,27 @@ impl TurborepoConfigBuilder {
         Ok(global_auth)
     }
 
+    // The team and token of the link profile that the repository is linked
+    // with, which take precedence over the logged in user's token
+    fn get_link_profile(&self, name: Option<&str>) -> Result<ConfigurationOptions, Error> {
+        let Some(name) = non_empty_str(name) else {
+            return Ok(ConfigurationOptions::default());
+        };
+        let profiles = read_link_profiles(&self.global_config_path()?)?;
+        let Some(profile) = profiles.get(name) else {
+            warn!(
+                "link profile {name} not found, run `turbo link --profile={name}` to save it again"
+            );
+            return Ok(ConfigurationOptions::default());
+        };
+        Ok(ConfigurationOptions {
+            team_id: Some(profile.team_id.clone()),
+            team_slug: profile.team_slug.clone(),
+            token: Some(profile.token.clone()),
+            ..Default::default()
+        })
+    }
+
     create_builder!(with_api_url, api_url, Option<String>);
     create_builder!(with_login_url, login_url, Option<String>);
     create_builder!(with_team_slug, team_slug, Option<String>);
@@ -632,6 +1151,12 @@ impl TurborepoConfigBuilder {
     create_builder!(with_enabled, enabled, Option<bool>);
     create_builder!(with_preflight, preflight, Option<bool>);
     create_builder!(with_timeout, timeout, Option<u64>);
+    create_builder!(with_ca_cert, ca_cert, Option<String>);
+    create_builder!(
+        with_insecure_skip_tls_verify,
+        insecure_skip_tls_verify,
+        Option<bool>
+    );
     create_builder!(with_ui, ui, Option<bool>);
 
     pub fn build(&self) -> Result<ConfigurationOptions, Error> {
@@ -639,6 +1164,7 @@ impl TurborepoConfigBuilder {
         // - shared configuration (turbo.json)
         // - global configuration (~/.turbo/config.json)
         // - local configuration (<REPO_ROOT>/.turbo/config.json)
+        // - the link profile selected in the local configuration
         // - environment variables
         // - CLI arguments
         // - builder pattern overrides.
@@ -659,6 +1185,7 @@ impl TurborepoConfigBuilder {
         let global_config = self.get_global_config()?;
         let global_auth = self.get_global_auth()?;
         let local_config = self.get_local_config()?;
+        let link_profile = self.get_link_profile(local_config.link_profile.as_deref())?;
         let env_vars = self.get_environment();
         let env_var_config = get_env_var_config(&env_vars)?;
         let override_env_var_config = get_override_env_var_config(&env_vars)?;
@@ -668,6 +1195,7 @@ impl TurborepoConfigBuilder {
             global_config.get_configuration_options(),
             global_auth.get_configuration_options(),
             local_config.get_configuration_options(),
+            link_profile.get_configuration_options(),
             env_var_config.get_configuration_options(),
             Ok(self.override_config.clone()),
             override_env_var_config.get_configuration_options(),
@@ -701,15 +1229,80 @@ impl TurborepoConfigBuilder {
                     if let Some(preflight) = current_source_config.preflight {
                         acc.preflight = Some(preflight);
                     }
+                    if let Some(read_only) = current_source_config.read_only {
+                        acc.read_only = Some(read_only);
+                    }
+                    if let Some(write_only) = current_source_config.write_only {
+                        acc.write_only = Some(write_only);
+                    }
                     if let Some(timeout) = current_source_config.timeout {
                         acc.timeout = Some(timeout);
                     }
+                    if let Some(upload_timeout) = current_source_config.upload_timeout {
+                        acc.upload_timeout = Some(upload_timeout);
+                    }
+                    if let Some(download_timeout) = current_source_config.download_timeout {
+                        acc.download_timeout = Some(download_timeout);
+                    }
+                    if let Some(connect_timeout) = current_source_config.connect_timeout {
+                        acc.connect_timeout = Some(connect_timeout);
+                    }
+                    if let Some(ca_cert) = current_source_config.ca_cert.clone() {
+                        acc.ca_cert = Some(ca_cert);
+                    }
+                    if let Some(insecure_skip_tls_verify) =
+                        current_source_config.insecure_skip_tls_verify
+                    {
+                        acc.insecure_skip_tls_verify = Some(insecure_skip_tls_verify);
+                    }
+                    if let Some(retry_attempts) = current_source_config.retry_attempts {
+                        acc.retry_attempts = Some(retry_attempts);
+                    }
+                    if let Some(retry_backoff) = current_source_config.retry_backoff {
+                        acc.retry_backoff = Some(retry_backoff);
+                    }
+                    if let Some(retry_max_elapsed) = current_source_config.retry_max_elapsed {
+                        acc.retry_max_elapsed = Some(retry_max_elapsed);
+                    }
+                    if let Some(failure_limit) = current_source_config.failure_limit {
+                        acc.failure_limit = Some(failure_limit);
+                    }
                     if let Some(spaces_id) = current_source_config.spaces_id {
                         acc.spaces_id = Some(spaces_id);
                     }
                     if let Some(ui) = current_source_config.ui {
                         acc.ui = Some(ui);
                     }
+                    if let Some(provider) = current_source_config.provider {
+                        acc.provider = Some(provider);
+                    }
+                    if let Some(bucket) = current_source_config.bucket {
+                        acc.bucket = Some(bucket);
+                    }
+                    if let Some(region) = current_source_config.region {
+                        acc.region = Some(region);
+                    }
+                    if let Some(endpoint) = current_source_config.endpoint {
+                        acc.endpoint = Some(endpoint);
+                    }
+                    if let Some(prefix) = current_source_config.prefix {
+                        acc.prefix = Some(prefix);
+                    }
+                    if let Some(cache_compression) = current_source_config.cache_compression {
+                        acc.cache_compression = Some(cache_compression);
+                    }
+                    if let Some(cache_dir) = current_source_config.cache_dir {
+                        acc.cache_dir = Some(cache_dir);
+                    }
+                    if let Some(cache_tiers) = current_source_config.cache_tiers {
+                        acc.cache_tiers = Some(cache_tiers);
+                    }
+                    if let Some(workspace_providers) = current_source_config.workspace_providers {
+                        acc.workspace_providers = Some(workspace_providers);
+                    }
+                    if let Some(link_profile) = current_source_config.link_profile {
+                        acc.link_profile = Some(link_profile);
+                    }
 
                     acc
                 })
@@ -722,12 +1315,19 @@ impl TurborepoConfigBuilder {
 mod test {
     use std::{collections::HashMap, ffi::OsString};
 
+    use camino::{Utf8Path, Utf8PathBuf};
     use tempfile::TempDir;
     use turbopath::AbsoluteSystemPathBuf;
-
-    use crate::config::{
-        get_env_var_config, get_override_env_var_config, ConfigurationOptions,
-        TurborepoConfigBuilder, DEFAULT_API_URL, DEFAULT_LOGIN_URL, DEFAULT_TIMEOUT,
+    use turborepo_cache::CacheTier;
+    use turborepo_repository::package_graph::WorkspaceProvider;
+
+    use crate::{
+        config::{
+            get_env_var_config, get_override_env_var_config, ConfigurationOptions, Error,
+            TurborepoConfigBuilder, DEFAULT_API_URL, DEFAULT_LOGIN_URL, DEFAULT_RETRY_ATTEMPTS,
+            DEFAULT_RETRY_BACKOFF, DEFAULT_TIMEOUT,
+        },
+        turbo_json::RemoteCacheProvider,
     };
 
     #[test]
@@ -779,6 +1379,69 @@ mod test {
         assert_eq!(Some(true), config.ui);
     }
 
+    #[test]
+    fn test_ca_cert_env_setting() {
+        let defaults = ConfigurationOptions::default();
+        assert_eq!(defaults.ca_cert(), None);
+        assert!(!defaults.insecure_skip_tls_verify());
+
+        let mut env: HashMap<OsString, OsString> = HashMap::new();
+        env.insert("turbo_ca_cert".into(), "/etc/ssl/corp-ca.pem".into());
+
+        let config = get_env_var_config(&env).unwrap();
+        assert_eq!(config.ca_cert(), Some("/etc/ssl/corp-ca.pem"));
+        assert!(!config.insecure_skip_tls_verify());
+
+        env.insert("turbo_ca_cert".into(), "".into());
+        let config = get_env_var_config(&env).unwrap();
+        assert_eq!(config.ca_cert(), None);
+    }
+
+    #[test]
+    fn test_retry_env_setting() {
+        let defaults = ConfigurationOptions::default();
+        assert_eq!(defaults.retry_attempts(), DEFAULT_RETRY_ATTEMPTS);
+        assert_eq!(defaults.retry_backoff(), DEFAULT_RETRY_BACKOFF);
+        assert_eq!(defaults.retry_max_elapsed(), 0);
+
+        let mut env: HashMap<OsString, OsString> = HashMap::new();
+        env.insert("turbo_remote_cache_retry_attempts".into(), "5".into());
+        env.insert("turbo_remote_cache_retry_backoff".into(), "1".into());
+        env.insert("turbo_remote_cache_retry_max_elapsed".into(), "30".into());
+
+        let config = get_env_var_config(&env).unwrap();
+        assert_eq!(config.retry_attempts(), 5);
+        assert_eq!(config.retry_backoff(), 1);
+        assert_eq!(config.retry_max_elapsed(), 30);
+
+        // At least one attempt is always made
+        env.insert("turbo_remote_cache_retry_attempts".into(), "0".into());
+        let config = get_env_var_config(&env).unwrap();
+        assert_eq!(config.retry_attempts(), 1);
+    }
+
+    #[test]
+    fn test_failure_limit_env_setting() {
+        assert_eq!(ConfigurationOptions::default().failure_limit(), None);
+
+        let mut env: HashMap<OsString, OsString> = HashMap::new();
+        env.insert("turbo_remote_cache_failure_limit".into(), "0".into());
+        let config = get_env_var_config(&env).unwrap();
+        assert_eq!(config.failure_limit(), Some(0));
+
+        env.insert("turbo_remote_cache_failure_limit".into(), "-1".into());
+        assert!(matches!(
+            get_env_var_config(&env),
+            Err(Error::InvalidFailureLimit(_))
+        ));
+
+        env.insert("turbo_remote_cache_retry_attempts".into(), "many".into());
+        assert!(matches!(
+            get_env_var_config(&env),
+            Err(Error::InvalidRetryAttempts(_))
+        ));
+    }
+
     #[test]
     fn test_empty_env_setting() {
         let mut env: HashMap<OsString, OsString> = HashMap::new();
@@ -872,4 +1535,284 @@ mod test {
         assert_eq!(config.token().unwrap(), vercel_artifacts_token);
         assert_eq!(config.spaces_id().unwrap(), "my-spaces-id");
     }
+
+    #[test]
+    fn test_link_profile() {
+        let tmp_dir = TempDir::new().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let global_tmp_dir = TempDir::new().unwrap();
+        let global_config_path =
+            AbsoluteSystemPathBuf::try_from(global_tmp_dir.path().join("config.json")).unwrap();
+        global_config_path
+            .create_with_contents(
+                r#"{
+                    "token": "login-token",
+                    "linkProfiles": {
+                        "acme": { "teamId": "team_acme", "teamSlug": "acme", "token": "acme-token" }
+                    }
+                }"#,
+            )
+            .unwrap();
+        let local_config_path = repo_root.join_components(&[".turbo", "config.json"]);
+        local_config_path.ensure_dir().unwrap();
+
+        let build = |environment: HashMap<OsString, OsString>| {
+            TurborepoConfigBuilder {
+                repo_root: repo_root.clone(),
+                override_config: Default::default(),
+                global_config_path: Some(global_config_path.clone()),
+                environment,
+            }
+            .build()
+            .unwrap()
+        };
+
+        local_config_path
+            .create_with_contents(r#"{ "teamId": "team_other", "linkProfile": "acme" }"#)
+            .unwrap();
+        let config = build(HashMap::new());
+        assert_eq!(config.link_profile(), Some("acme"));
+        assert_eq!(config.team_id(), Some("team_acme"));
+        assert_eq!(config.team_slug(), Some("acme"));
+        assert_eq!(config.token(), Some("acme-token"));
+
+        // Environment variables still take precedence over the profile
+        let config = build(HashMap::from([(
+            OsString::from("turbo_token"),
+            OsString::from("env-token"),
+        )]));
+        assert_eq!(config.token(), Some("env-token"));
+
+        // A profile that was removed falls back to the rest of the config
+        local_config_path
+            .create_with_contents(r#"{ "teamId": "team_other", "linkProfile": "removed" }"#)
+            .unwrap();
+        let config = build(HashMap::new());
+        assert_eq!(config.team_id(), Some("team_other"));
+        assert_eq!(config.token(), Some("login-token"));
+    }
+
+    #[test]
+    fn test_s3_remote_cache() {
+        let tmp_dir = TempDir::new().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let global_config_path = AbsoluteSystemPathBuf::try_from(
+            TempDir::new().unwrap().path().join("nonexistent.json"),
+        )
+        .unwrap();
+
+        repo_root
+            .join_component("turbo.json")
+            .create_with_contents(
+                r#"{"remoteCache": {"provider": "s3", "bucket": "my-bucket", "prefix": "turbo"}}"#,
+            )
+            .unwrap();
+
+        let builder = TurborepoConfigBuilder {
+            repo_root,
+            override_config: Default::default(),
+            global_config_path: Some(global_config_path),
+            environment: HashMap::new(),
+        };
+
+        let config = builder.build().unwrap();
+        let s3_opts = config.s3_opts().unwrap().unwrap();
+        assert_eq!(s3_opts.bucket, "my-bucket");
+        assert_eq!(s3_opts.prefix.as_deref(), Some("turbo"));
+        assert_eq!(s3_opts.region, None);
+        assert_eq!(s3_opts.endpoint, None);
+    }
+
+    #[test]
+    fn test_remote_cache_read_only() {
+        let tmp_dir = TempDir::new().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let global_config_path = AbsoluteSystemPathBuf::try_from(
+            TempDir::new().unwrap().path().join("nonexistent.json"),
+        )
+        .unwrap();
+
+        repo_root
+            .join_component("turbo.json")
+            .create_with_contents(r#"{"remoteCache": {"readOnly": true}}"#)
+            .unwrap();
+
+        let builder = TurborepoConfigBuilder {
+            repo_root,
+            override_config: Default::default(),
+            global_config_path: Some(global_config_path),
+            environment: HashMap::new(),
+        };
+
+        let config = builder.build().unwrap();
+        assert!(config.read_only());
+        assert!(!config.write_only());
+    }
+
+    #[test]
+    fn test_remote_cache_timeouts() {
+        let tmp_dir = TempDir::new().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let global_config_path = AbsoluteSystemPathBuf::try_from(
+            TempDir::new().unwrap().path().join("nonexistent.json"),
+        )
+        .unwrap();
+
+        repo_root
+            .join_component("turbo.json")
+            .create_with_contents(
+                r#"{"remoteCache": {"timeout": 10, "uploadTimeout": 300, "downloadTimeout": 0}}"#,
+            )
+            .unwrap();
+
+        let mut env = HashMap::new();
+        env.insert("turbo_remote_cache_upload_timeout".into(), "600".into());
+        env.insert("turbo_remote_cache_download_timeout".into(), "120".into());
+
+        let builder = TurborepoConfigBuilder {
+            repo_root,
+            override_config: Default::default(),
+            global_config_path: Some(global_config_path),
+            environment: env,
+        };
+
+        let config = builder.build().unwrap();
+        // Connecting uses the general timeout unless it's set separately
+        assert_eq!(config.connect_timeout(), 10);
+        assert_eq!(config.upload_timeout(), 600);
+        assert_eq!(config.download_timeout(), 120);
+    }
+
+    #[test]
+    fn test_cache_compression_level() {
+        let with_compression = |compression: &str| ConfigurationOptions {
+            cache_compression: Some(compression.to_string()),
+            ..Default::default()
+        };
+
+        assert_eq!(
+            ConfigurationOptions::default()
+                .cache_compression_level()
+                .unwrap(),
+            0
+        );
+        assert_eq!(
+            with_compression("zstd").cache_compression_level().unwrap(),
+            0
+        );
+        assert_eq!(
+            with_compression("zstd:3")
+                .cache_compression_level()
+                .unwrap(),
+            3
+        );
+        assert_eq!(
+            with_compression("zstd:19")
+                .cache_compression_level()
+                .unwrap(),
+            19
+        );
+        for invalid in ["gzip", "zstd:0", "zstd:23", "zstd:fast"] {
+            assert!(matches!(
+                with_compression(invalid).cache_compression_level(),
+                Err(Error::InvalidCacheCompression(_))
+            ));
+        }
+    }
+
+    #[test]
+    fn test_workspace_providers() {
+        let tmp_dir = TempDir::new().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let global_config_path = AbsoluteSystemPathBuf::try_from(
+            TempDir::new().unwrap().path().join("nonexistent.json"),
+        )
+        .unwrap();
+        repo_root
+            .join_component("turbo.json")
+            .create_with_contents(r#"{"experimentalWorkspaceProviders": ["go", "cargo"]}"#)
+            .unwrap();
+
+        let builder = TurborepoConfigBuilder {
+            repo_root,
+            override_config: ConfigurationOptions::default(),
+            global_config_path: Some(global_config_path),
+            environment: HashMap::new(),
+        };
+
+        let config = builder.build().unwrap();
+        assert_eq!(
+            config.workspace_providers().unwrap(),
+            vec![WorkspaceProvider::Go, WorkspaceProvider::Cargo]
+        );
+        assert!(ConfigurationOptions::default()
+            .workspace_providers()
+            .unwrap()
+            .is_empty());
+        let unknown = ConfigurationOptions {
+            workspace_providers: Some(vec!["maven".to_string()]),
+            ..Default::default()
+        };
+        assert!(matches!(
+            unknown.workspace_providers(),
+            Err(Error::InvalidWorkspaceProvider(_))
+        ));
+    }
+
+    #[test]
+    fn test_cache_tiers() {
+        let tmp_dir = TempDir::new().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let global_config_path = AbsoluteSystemPathBuf::try_from(
+            TempDir::new().unwrap().path().join("nonexistent.json"),
+        )
+        .unwrap();
+        repo_root
+            .join_component("turbo.json")
+            .create_with_contents(
+                r#"{
+                    "cacheDir": ".cache/turbo",
+                    "cacheOptions": {
+                        "tiers": [{ "dir": "/mnt/turbo-cache", "readOnly": true }, { "dir": "../shared" }]
+                    }
+                }"#,
+            )
+            .unwrap();
+
+        let builder = TurborepoConfigBuilder {
+            repo_root,
+            override_config: ConfigurationOptions::default(),
+            global_config_path: Some(global_config_path),
+            environment: HashMap::new(),
+        };
+
+        let config = builder.build().unwrap();
+        assert_eq!(config.cache_dir(), Some(Utf8Path::new(".cache/turbo")));
+        assert_eq!(
+            config.cache_tiers(),
+            &[
+                CacheTier {
+                    dir: Utf8PathBuf::from("/mnt/turbo-cache"),
+                    read_only: true,
+                },
+                CacheTier {
+                    dir: Utf8PathBuf::from("../shared"),
+                    read_only: false,
+                },
+            ]
+        );
+        assert!(ConfigurationOptions::default().cache_tiers().is_empty());
+    }
+
+    #[test]
+    fn test_s3_remote_cache_requires_bucket() {
+        let config = ConfigurationOptions {
+            provider: Some(RemoteCacheProvider::S3),
+            ..Default::default()
+        };
+        assert!(matches!(config.s3_opts(), Err(Error::MissingS3Bucket)));
+
+        let defaults: ConfigurationOptions = Default::default();
+        assert_eq!(defaults.s3_opts().unwrap(), None);
+    }
 }
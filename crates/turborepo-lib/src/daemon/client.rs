@@ -1,4 +1,4 @@
-use std::{io, time::Duration};
+use std::{collections::HashMap, io, time::Duration};
 
 use globwalk::ValidatedGlob;
 use miette::Diagnostic;
@@ -6,10 +6,12 @@ use thiserror::Error;
 use tonic::{Code, IntoRequest, Status};
 use tracing::info;
 use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPath};
+use turborepo_repository::package_graph::CachedDependencies;
 
 use super::{
     connector::{DaemonConnector, DaemonConnectorError},
     endpoint::SocketOpenError,
+    package_graph,
     proto::{DiscoverPackagesResponse, GetFileHashesResponse},
     Paths,
 };
@@ -18,6 +20,8 @@ use crate::{
     globwatcher::HashGlobSetupError,
 };
 
+const PACKAGE_GRAPH_TIMEOUT: Duration = Duration::from_secs(1);
+
 #[derive(Debug, Clone)]
 pub struct DaemonClient<T> {
     client: proto::turbod_client::TurbodClient<tonic::transport::Channel>,
@@ -74,6 +78,7 @@ impl<T> DaemonClient<T> {
         Ok(self.connect_settings)
     }
 
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub async fn get_changed_outputs(
         &mut self,
         hash: String,
@@ -91,6 +96,7 @@ impl<T> DaemonClient<T> {
             .changed_output_globs)
     }
 
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
     pub async fn notify_outputs_written(
         &mut self,
         hash: String,
@@ -118,7 +124,35 @@ impl<T> DaemonClient<T> {
         Ok(())
     }
 
+    /// Locks the task with `hash` for this process. Returns false if another
+    /// turbo process holds the lock.
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
+    pub async fn acquire_task_lock(&mut self, hash: String) -> Result<bool, DaemonError> {
+        Ok(self
+            .client
+            .acquire_task_lock(proto::AcquireTaskLockRequest {
+                hash,
+                pid: std::process::id(),
+            })
+            .await?
+            .into_inner()
+            .acquired)
+    }
+
+    #[tracing::instrument(skip_all, fields(hash = %hash))]
+    pub async fn release_task_lock(&mut self, hash: String) -> Result<(), DaemonError> {
+        self.client
+            .release_task_lock(proto::ReleaseTaskLockRequest {
+                hash,
+                pid: std::process::id(),
+            })
+            .await?;
+
+        Ok(())
+    }
+
     /// Get the status of the daemon.
+    #[tracing::instrument(skip_all)]
     pub async fn status(&mut self) -> Result<proto::DaemonStatus, DaemonError> {
         self.client
             .status(proto::StatusRequest {})
@@ -128,6 +162,7 @@ impl<T> DaemonClient<T> {
             .ok_or(DaemonError::MalformedResponse)
     }
 
+    #[tracing::instrument(skip_all)]
     pub async fn discover_packages(&mut self) -> Result<DiscoverPackagesResponse, DaemonError> {
         let req = proto::DiscoverPackagesRequest {};
         let mut req = req.into_request();
@@ -137,6 +172,7 @@ impl<T> DaemonClient<T> {
         Ok(response)
     }
 
+    #[tracing::instrument(skip_all)]
     pub async fn discover_packages_blocking(
         &mut self,
     ) -> Result<DiscoverPackagesResponse, DaemonError> {
@@ -149,6 +185,7 @@ impl<T> DaemonClient<T> {
         Ok(response)
     }
 
+    #[tracing::instrument(skip_all)]
     pub async fn package_changes(
         &mut self,
     ) -> Result<tonic::codec::Streaming<PackageChangeEvent>, DaemonError> {
@@ -160,6 +197,7 @@ impl<T> DaemonClient<T> {
         Ok(response)
     }
 
+    #[tracing::instrument(skip_all, fields(package = %package_path))]
     pub async fn get_file_hashes(
         &mut self,
         package_path: &AnchoredSystemPath,
@@ -175,6 +213,68 @@ impl<T> DaemonClient<T> {
             .into_inner();
         Ok(response)
     }
+
+    /// Fetches the transitive dependencies the daemon resolved for the
+    /// lockfile and workspace globs identified by `key`, see
+    /// `package_graph_key`.
+    #[tracing::instrument(skip_all)]
+    pub async fn get_package_graph(
+        &mut self,
+        key: String,
+    ) -> Result<HashMap<String, CachedDependencies>, DaemonError> {
+        let mut req = proto::GetPackageGraphRequest { key }.into_request();
+        // The dependencies of a large repository can take longer than the
+        // default timeout to send, but waiting on them longer than this would
+        // be slower than resolving them ourselves
+        req.set_timeout(PACKAGE_GRAPH_TIMEOUT);
+        let response = self.client.get_package_graph(req).await?.into_inner();
+        Ok(package_graph::dependencies_from_proto(response.packages))
+    }
+
+    /// Fetches a hash of the files in each package, keyed by package name
+    #[tracing::instrument(skip_all)]
+    pub async fn get_package_hashes_blocking(
+        &mut self,
+    ) -> Result<HashMap<String, String>, DaemonError> {
+        let response = self
+            .client
+            .get_package_hashes_blocking(proto::GetPackageHashesRequest {})
+            .await?
+            .into_inner();
+        Ok(response.package_hashes)
+    }
+
+    /// Fetches the packages that changed between `since` and `until`, or the
+    /// working tree if `until` isn't given
+    #[tracing::instrument(skip_all)]
+    pub async fn get_changed_packages_blocking(
+        &mut self,
+        since: String,
+        until: Option<String>,
+    ) -> Result<Vec<String>, DaemonError> {
+        let response = self
+            .client
+            .get_changed_packages_blocking(proto::GetChangedPackagesRequest { since, until })
+            .await?
+            .into_inner();
+        Ok(response.packages)
+    }
+
+    /// Fetches the tasks that `turbo run` would execute for `tasks` in the
+    /// packages selected by `filters`
+    #[tracing::instrument(skip_all)]
+    pub async fn get_task_graph_blocking(
+        &mut self,
+        tasks: Vec<String>,
+        filters: Vec<String>,
+    ) -> Result<Vec<proto::GraphTask>, DaemonError> {
+        let response = self
+            .client
+            .get_task_graph_blocking(proto::GetTaskGraphRequest { tasks, filters })
+            .await?
+            .into_inner();
+        Ok(response.tasks)
+    }
 }
 
 impl DaemonClient<DaemonConnector> {
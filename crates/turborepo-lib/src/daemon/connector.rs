@@ -2,7 +2,7 @@ use std::{
     ffi::OsStr,
     process::Stdio,
     sync::Arc,
-    time::{Duration, Instant},
+    time::{Duration, Instant, SystemTime, UNIX_EPOCH},
 };
 
 use command_group::AsyncCommandGroup;
@@ -47,6 +47,10 @@ pub enum DaemonConnectorError {
 
     #[error("unable to use pid file: {0}")]
     PidFile(#[from] PidFileError),
+
+    /// The daemon was started too recently to be considered unresponsive.
+    #[error("daemon ({0}) was just started and may not be listening yet")]
+    Starting(Pid),
 }
 
 #[derive(Error, Debug)]
@@ -87,6 +91,9 @@ impl DaemonConnector {
     const SHUTDOWN_TIMEOUT: Duration = Duration::from_secs(1);
     const SOCKET_TIMEOUT: Duration = Duration::from_secs(1);
     const SOCKET_ERROR_WAIT: Duration = Duration::from_millis(50);
+    // How long a daemon has after writing its pid file to start listening. A
+    // daemon that doesn't respond within it is considered unresponsive.
+    const STARTUP_TIMEOUT: Duration = Duration::from_secs(2);
 
     /// Attempt, with retries, to:
     /// 1. find (or start) the daemon process
@@ -103,11 +110,19 @@ impl DaemonConnector {
     pub async fn connect(self) -> Result<DaemonClient<DaemonConnector>, DaemonConnectorError> {
         let time = Instant::now();
         for _ in 0..Self::CONNECT_RETRY_MAX {
-            let pid = self.get_or_start_daemon().await?;
+            let (pid, started) = self.get_or_start_daemon().await?;
             debug!("got daemon with pid: {}", pid);
 
             let conn = match self.get_connection(self.paths.sock_file.clone()).await {
                 Err(DaemonConnectorError::Watcher(_)) => continue,
+                // A daemon we didn't just start has had plenty of time to open its socket,
+                // so it's hung or its socket file was deleted
+                Err(DaemonConnectorError::Timeout(_)) if !started && self.can_kill_server => {
+                    debug!("daemon with pid {} isn't listening, killing it", pid);
+                    self.kill_dead_server(pid).await?;
+                    self.remove_pid_file()?;
+                    continue;
+                }
                 Err(DaemonConnectorError::Socket(e)) => {
                     // assume the server is not yet ready
                     debug!("socket error: {}", e);
@@ -139,27 +154,100 @@ impl DaemonConnector {
         ))
     }
 
-    /// Gets the PID of the daemon process.
+    /// Gets the PID of the daemon process, and whether it was just started.
     ///
     /// If a daemon is not running, it starts one.
-    async fn get_or_start_daemon(&self) -> Result<sysinfo::Pid, DaemonConnectorError> {
+    async fn get_or_start_daemon(&self) -> Result<(sysinfo::Pid, bool), DaemonConnectorError> {
         debug!("looking for pid in lockfile: {:?}", self.paths.pid_file);
 
-        let pidfile = self.pid_lock();
-
-        match pidfile.get_owner()? {
+        match self.get_owner()? {
             Some(pid) => {
                 debug!("found pid: {}", pid);
-                Ok(sysinfo::Pid::from(pid as usize))
+                Ok((pid, false))
             }
             None if self.can_start_server => {
                 debug!("no pid found, starting daemon");
-                Self::start_daemon().await
+                Ok((Self::start_daemon().await?, true))
             }
             None => Err(DaemonConnectorError::NotRunning),
         }
     }
 
+    /// Gets the PID of the daemon that owns the pid file, removing the pid file
+    /// if it was left behind.
+    ///
+    /// After a reboot or a crash, the pid in the file can belong to an
+    /// unrelated process, so the owner also has to have been started before
+    /// the file was written.
+    fn get_owner(&self) -> Result<Option<sysinfo::Pid>, DaemonConnectorError> {
+        let owner = match self.pid_lock().get_owner() {
+            // The daemon crashed while writing the pid file
+            Err(PidFileError::Invalid { .. }) if self.can_kill_server => {
+                debug!("removing invalid pid file");
+                self.remove_pid_file()?;
+                return Ok(None);
+            }
+            result => result?.map(|pid| sysinfo::Pid::from(pid as usize)),
+        };
+
+        let Some(pid) = owner else {
+            return Ok(None);
+        };
+        let system = sysinfo::System::new_with_specifics(
+            RefreshKind::new().with_processes(ProcessRefreshKind::new()),
+        );
+        let pid_file_modified = self
+            .paths
+            .pid_file
+            .symlink_metadata()
+            .ok()
+            .and_then(|metadata| metadata.modified().ok());
+        match (system.process(pid), pid_file_modified) {
+            (Some(process), Some(modified)) if !started_before(process.start_time(), modified) => {
+                debug!("pid {} was reused after the daemon exited", pid);
+                self.remove_pid_file()?;
+                Ok(None)
+            }
+            _ => Ok(Some(pid)),
+        }
+    }
+
+    fn remove_pid_file(&self) -> Result<(), PidFileError> {
+        match self.paths.pid_file.remove_file() {
+            Ok(()) => Ok(()),
+            Err(e) if e.kind() == std::io::ErrorKind::NotFound => Ok(()),
+            Err(e) => Err(PidFileError::FailedDelete(
+                e,
+                self.paths.pid_file.to_string(),
+            )),
+        }
+    }
+
+    /// Kills the daemon that owns the pid file without connecting to it, for
+    /// when it doesn't respond.
+    ///
+    /// Another process can start a daemon between our failed attempt to
+    /// connect and this call, so a daemon that wrote its pid file within
+    /// `STARTUP_TIMEOUT` is left running and `DaemonConnectorError::Starting`
+    /// is returned.
+    pub async fn kill_unresponsive_server(&self) -> Result<(), DaemonConnectorError> {
+        let Some(pid) = self.get_owner()? else {
+            return Ok(());
+        };
+        let pid_file_age = self
+            .paths
+            .pid_file
+            .symlink_metadata()
+            .ok()
+            .and_then(|metadata| metadata.modified().ok())
+            .and_then(|modified| SystemTime::now().duration_since(modified).ok());
+        if pid_file_age.is_some_and(|age| age < Self::STARTUP_TIMEOUT) {
+            debug!("daemon with pid {} was just started, not killing it", pid);
+            return Err(DaemonConnectorError::Starting(pid));
+        }
+        self.kill_dead_server(pid).await
+    }
+
     /// Starts the daemon process, returning its PID.
     async fn start_daemon() -> Result<sysinfo::Pid, DaemonConnectorError> {
         let binary_path =
@@ -302,6 +390,15 @@ impl DaemonConnector {
     }
 }
 
+/// Returns if a process started at `process_start`, in seconds since the
+/// epoch, could have written a file last modified at `file_modified`. The start
+/// time only has a resolution of seconds, so it's rounded up.
+fn started_before(process_start: u64, file_modified: SystemTime) -> bool {
+    file_modified
+        .duration_since(UNIX_EPOCH)
+        .map_or(true, |modified| process_start <= modified.as_secs() + 1)
+}
+
 #[cfg(target_os = "windows")]
 fn win(
     path: Arc<turbopath::AbsoluteSystemPathBuf>,
@@ -451,6 +548,34 @@ mod test {
         );
     }
 
+    #[tokio::test]
+    async fn removes_invalid_pid() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+
+        let connector = DaemonConnector::new(false, true, &repo_root);
+        connector.paths.pid_file.ensure_dir().unwrap();
+        connector
+            .paths
+            .pid_file
+            .create_with_contents("not a pid")
+            .unwrap();
+
+        assert_matches!(
+            connector.get_or_start_daemon().await,
+            Err(DaemonConnectorError::NotRunning)
+        );
+        assert!(!connector.paths.pid_file.exists());
+    }
+
+    #[test]
+    fn test_started_before() {
+        let modified = UNIX_EPOCH + Duration::from_millis(10_500);
+        assert!(started_before(9, modified));
+        assert!(started_before(11, modified));
+        assert!(!started_before(12, modified));
+    }
+
     #[tokio::test]
     async fn handles_missing_server_connect() {
         let tmp_dir = tempfile::tempdir().unwrap();
@@ -574,10 +699,93 @@ mod test {
         );
     }
 
+    #[tokio::test]
+    async fn kill_unresponsive_server_skips_starting_daemon() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let connector = DaemonConnector::new(false, true, &repo_root);
+
+        let mut proc = tokio::process::Command::new(NODE_EXE)
+            .stdout(Stdio::null())
+            .stderr(Stdio::null())
+            .arg("-e")
+            .arg("setInterval(() => {}, 1000)")
+            .spawn()
+            .unwrap();
+        let proc_id = Pid::from(proc.id().unwrap() as usize);
+
+        connector.paths.pid_file.ensure_dir().unwrap();
+        connector
+            .paths
+            .pid_file
+            .create_with_contents(proc.id().unwrap().to_string())
+            .unwrap();
+
+        // The pid file was just written, as if another process started a daemon
+        assert_matches!(
+            connector.kill_unresponsive_server().await,
+            Err(DaemonConnectorError::Starting(pid)) if pid == proc_id
+        );
+        assert!(
+            proc.try_wait().unwrap().is_none(),
+            "process should be running"
+        );
+
+        proc.kill().await.unwrap();
+    }
+
+    // Longer than the default timeout of non-blocking calls
+    const SLOW_RESPONSE: Duration = Duration::from_millis(200);
+
     struct DummyServer {
         shutdown: Mutex<Option<Sender<bool>>>,
     }
 
+    // Serves `server` over an in-memory stream, returning a connected client
+    // and the server's task
+    async fn serve(
+        server: DummyServer,
+    ) -> (
+        TurbodClient<tonic::transport::Channel>,
+        tokio::task::JoinHandle<Result<(), tonic::transport::Error>>,
+    ) {
+        let (tx, mut rx) = tokio::sync::mpsc::channel(1);
+
+        // set up the server
+        let stream = async_stream::stream! {
+            while let Some(item) = rx.recv().await {
+                yield item;
+            }
+        };
+
+        let service = ServiceBuilder::new()
+            .layer(DefaultTimeoutLayer)
+            .service(proto::turbod_server::TurbodServer::new(server));
+
+        let server_fut = tonic::transport::Server::builder()
+            .add_service(service)
+            .serve_with_incoming(stream);
+
+        let client = Endpoint::try_from("http://[::]:50051")
+            .expect("this is a valid uri")
+            .connect_with_connector(tower::service_fn(move |_| {
+                // when a connection is made, create a duplex stream and send it to the server
+                let tx = tx.clone();
+                async move {
+                    let (client, server) = tokio::io::duplex(1024);
+                    let server: Result<_, anyhow::Error> = Ok(server);
+                    let client: Result<_, anyhow::Error> = Ok(client);
+                    tx.send(server).await.unwrap();
+                    client
+                }
+            }))
+            .await
+            .map(TurbodClient::new)
+            .unwrap();
+
+        (client, tokio::spawn(server_fut))
+    }
+
     #[tonic::async_trait]
     impl proto::turbod_server::Turbod for DummyServer {
         async fn shutdown(
@@ -655,56 +863,74 @@ mod test {
         ) -> Result<tonic::Response<proto::GetFileHashesResponse>, tonic::Status> {
             unimplemented!()
         }
+
+        async fn get_package_graph(
+            &self,
+            _req: tonic::Request<proto::GetPackageGraphRequest>,
+        ) -> Result<tonic::Response<proto::GetPackageGraphResponse>, tonic::Status> {
+            tokio::time::sleep(SLOW_RESPONSE).await;
+            Ok(tonic::Response::new(
+                proto::GetPackageGraphResponse::default(),
+            ))
+        }
+
+        async fn acquire_task_lock(
+            &self,
+            _req: tonic::Request<proto::AcquireTaskLockRequest>,
+        ) -> Result<tonic::Response<proto::AcquireTaskLockResponse>, tonic::Status> {
+            unimplemented!()
+        }
+
+        async fn release_task_lock(
+            &self,
+            _req: tonic::Request<proto::ReleaseTaskLockRequest>,
+        ) -> Result<tonic::Response<proto::ReleaseTaskLockResponse>, tonic::Status> {
+            unimplemented!()
+        }
+
+        async fn get_package_hashes_blocking(
+            &self,
+            _req: tonic::Request<proto::GetPackageHashesRequest>,
+        ) -> Result<tonic::Response<proto::GetPackageHashesResponse>, tonic::Status> {
+            tokio::time::sleep(SLOW_RESPONSE).await;
+            Ok(tonic::Response::new(
+                proto::GetPackageHashesResponse::default(),
+            ))
+        }
+
+        async fn get_changed_packages_blocking(
+            &self,
+            _req: tonic::Request<proto::GetChangedPackagesRequest>,
+        ) -> Result<tonic::Response<proto::GetChangedPackagesResponse>, tonic::Status> {
+            tokio::time::sleep(SLOW_RESPONSE).await;
+            Ok(tonic::Response::new(
+                proto::GetChangedPackagesResponse::default(),
+            ))
+        }
+
+        async fn get_task_graph_blocking(
+            &self,
+            _req: tonic::Request<proto::GetTaskGraphRequest>,
+        ) -> Result<tonic::Response<proto::GetTaskGraphResponse>, tonic::Status> {
+            tokio::time::sleep(SLOW_RESPONSE).await;
+            Ok(tonic::Response::new(proto::GetTaskGraphResponse::default()))
+        }
     }
 
     #[tokio::test]
     async fn handles_kill_live_server() {
         let (shutdown_tx, shutdown_rx) = tokio::sync::oneshot::channel();
 
-        let (tx, mut rx) = tokio::sync::mpsc::channel(1);
-
-        // set up the server
-        let stream = async_stream::stream! {
-            while let Some(item) = rx.recv().await {
-                yield item;
-            }
-        };
-
-        let service = ServiceBuilder::new().layer(DefaultTimeoutLayer).service(
-            proto::turbod_server::TurbodServer::new(DummyServer {
-                shutdown: Mutex::new(Some(shutdown_tx)),
-            }),
-        );
-
-        let server_fut = tonic::transport::Server::builder()
-            .add_service(service)
-            .serve_with_incoming(stream);
+        // the server is spawned so that it responds to the hello request
+        let (mut client, server_fut) = serve(DummyServer {
+            shutdown: Mutex::new(Some(shutdown_tx)),
+        })
+        .await;
 
         let tmp_dir = tempfile::tempdir().unwrap();
         let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
         let connector = DaemonConnector::new(false, false, &repo_root);
 
-        let mut client = Endpoint::try_from("http://[::]:50051")
-            .expect("this is a valid uri")
-            .connect_with_connector(tower::service_fn(move |_| {
-                // when a connection is made, create a duplex stream and send it to the server
-                let tx = tx.clone();
-                async move {
-                    let (client, server) = tokio::io::duplex(1024);
-                    let server: Result<_, anyhow::Error> = Ok(server);
-                    let client: Result<_, anyhow::Error> = Ok(client);
-                    tx.send(server).await.unwrap();
-                    client
-                }
-            }))
-            .await
-            .map(TurbodClient::new)
-            .unwrap();
-
-        // spawn the future for the server so that it responds to
-        // the hello request
-        let server_fut = tokio::spawn(server_fut);
-
         let hello_resp: DaemonError = client
             .hello(proto::HelloRequest {
                 version: "version-mismatch".to_string(),
@@ -729,4 +955,28 @@ mod test {
             "shutdown should have been received"
         )
     }
+
+    #[tokio::test]
+    async fn slow_queries_outlast_default_timeout() {
+        let (client, _server) = serve(DummyServer {
+            shutdown: Mutex::new(None),
+        })
+        .await;
+        let mut client = DaemonClient::new(client);
+
+        assert_matches!(client.get_package_graph("key".to_string()).await, Ok(_));
+        assert_matches!(client.get_package_hashes_blocking().await, Ok(_));
+        assert_matches!(
+            client
+                .get_changed_packages_blocking("main".to_string(), None)
+                .await,
+            Ok(_)
+        );
+        assert_matches!(
+            client
+                .get_task_graph_blocking(vec!["build".to_string()], Vec::new())
+                .await,
+            Ok(_)
+        );
+    }
 }
@@ -91,6 +91,7 @@ mod test {
     use super::*;
 
     #[test_case("/ExampleBlocking", None, None ; "no default for blocking calls")]
+    #[test_case("/turbodprotocol.Turbod/GetTaskGraphBlocking", None, None ; "no default for queries")]
     #[test_case("/Example", None, Some("100000u") ; "default for non-blocking calls")]
     #[test_case("/Example", Some("200u"), Some("200u") ; "respect client preference")]
     #[tokio::test]
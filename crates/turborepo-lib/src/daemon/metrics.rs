@@ -0,0 +1,281 @@
+//! Daemon metrics
+//!
+//! The daemon keeps counters about file watching, hashing, and the RPCs it
+//! serves, and periodically writes them to a file in the Prometheus text
+//! format. This is meant to help diagnose a slow daemon on a developer's
+//! machine, so we write a file rather than opening another port.
+
+use std::{
+    collections::BTreeMap,
+    fmt::Write,
+    future::Future,
+    pin::Pin,
+    sync::{
+        atomic::{AtomicU64, Ordering},
+        Arc, Mutex,
+    },
+    time::{Duration, Instant},
+};
+
+use tonic::{codegen::http::Request, server::NamedService};
+use tower::{Layer, Service};
+use tracing::debug;
+use turbopath::AbsoluteSystemPathBuf;
+use turborepo_filewatch::hash_watcher::{HashWatcher, HashWatcherStats};
+
+/// How often the metrics file is rewritten
+const WRITE_INTERVAL: Duration = Duration::from_secs(5);
+/// How long we wait for the hash watcher to report its stats. The hash
+/// watcher doesn't answer until file watching is ready.
+pub(super) const STATS_TIMEOUT: Duration = Duration::from_millis(100);
+
+#[derive(Debug, Default, Clone, Copy, PartialEq)]
+struct RequestStats {
+    count: u64,
+    total: Duration,
+    max: Duration,
+}
+
+#[derive(Debug)]
+pub struct DaemonMetrics {
+    start_time: Instant,
+    file_events: AtomicU64,
+    requests: Mutex<BTreeMap<String, RequestStats>>,
+}
+
+impl Default for DaemonMetrics {
+    fn default() -> Self {
+        Self {
+            start_time: Instant::now(),
+            file_events: AtomicU64::new(0),
+            requests: Mutex::default(),
+        }
+    }
+}
+
+impl DaemonMetrics {
+    pub fn record_file_event(&self) {
+        self.file_events.fetch_add(1, Ordering::Relaxed);
+    }
+
+    pub fn record_request(&self, method: &str, duration: Duration) {
+        let mut requests = self.requests.lock().expect("metrics lock poisoned");
+        let stats = requests.entry(method.to_string()).or_default();
+        stats.count += 1;
+        stats.total += duration;
+        stats.max = stats.max.max(duration);
+    }
+
+    /// Renders the metrics in the Prometheus text exposition format
+    fn render(&self, hash_stats: Option<&HashWatcherStats>) -> String {
+        let mut out = String::new();
+        // Writing to a String can't fail
+        let mut metric = |name: &str, kind: &str, help: &str, value: &dyn std::fmt::Display| {
+            writeln!(out, "# HELP {name} {help}").unwrap();
+            writeln!(out, "# TYPE {name} {kind}").unwrap();
+            writeln!(out, "{name} {value}").unwrap();
+        };
+
+        metric(
+            "turbod_uptime_seconds",
+            "gauge",
+            "Time since the daemon started.",
+            &self.start_time.elapsed().as_secs_f64(),
+        );
+        metric(
+            "turbod_file_events_total",
+            "counter",
+            "File system events received from the file watcher.",
+            &self.file_events.load(Ordering::Relaxed),
+        );
+        // The hash watcher isn't available until file watching is ready
+        if let Some(stats) = hash_stats {
+            metric(
+                "turbod_hash_tracked_files",
+                "gauge",
+                "Files the daemon currently has hashes for.",
+                &stats.tracked_files,
+            );
+            metric(
+                "turbod_hash_pending",
+                "gauge",
+                "Hash computations that haven't completed yet.",
+                &stats.pending_hashes,
+            );
+            metric(
+                "turbod_hash_hits_total",
+                "counter",
+                "File hash queries answered with already computed hashes.",
+                &stats.hash_hits,
+            );
+            metric(
+                "turbod_hash_misses_total",
+                "counter",
+                "File hash queries that had to wait for, or couldn't get, hashes.",
+                &stats.hash_misses,
+            );
+            metric(
+                "turbod_hash_invalidations_total",
+                "counter",
+                "Times a file change caused hashes to be recomputed.",
+                &stats.invalidations,
+            );
+        }
+
+        let requests = self.requests.lock().expect("metrics lock poisoned");
+        let name = "turbod_grpc_request_duration_seconds";
+        writeln!(out, "# HELP {name} Time taken to handle gRPC requests.").unwrap();
+        writeln!(out, "# TYPE {name} summary").unwrap();
+        for (method, stats) in requests.iter() {
+            let sum = stats.total.as_secs_f64();
+            writeln!(out, "{name}_sum{{method=\"{method}\"}} {sum}").unwrap();
+            writeln!(out, "{name}_count{{method=\"{method}\"}} {}", stats.count).unwrap();
+        }
+        let name = "turbod_grpc_request_duration_max_seconds";
+        writeln!(out, "# HELP {name} Slowest gRPC request handled.").unwrap();
+        writeln!(out, "# TYPE {name} gauge").unwrap();
+        for (method, stats) in requests.iter() {
+            let max = stats.max.as_secs_f64();
+            writeln!(out, "{name}{{method=\"{method}\"}} {max}").unwrap();
+        }
+
+        out
+    }
+
+    /// Rewrites the metrics file every few seconds until the task is
+    /// aborted.
+    pub async fn write_periodically(
+        self: Arc<Self>,
+        hash_watcher: Arc<HashWatcher>,
+        metrics_file: AbsoluteSystemPathBuf,
+    ) {
+        let mut interval = tokio::time::interval(WRITE_INTERVAL);
+        loop {
+            interval.tick().await;
+            let hash_stats = tokio::time::timeout(STATS_TIMEOUT, hash_watcher.stats())
+                .await
+                .ok()
+                .and_then(|stats| stats.ok());
+            let contents = self.render(hash_stats.as_ref());
+            if let Err(e) = metrics_file
+                .ensure_dir()
+                .and_then(|_| metrics_file.create_with_contents(contents))
+            {
+                debug!("failed to write metrics to {metrics_file}: {e}");
+            }
+        }
+    }
+}
+
+/// A layer that records how long each request takes to handle. For streaming
+/// RPCs this only covers setting up the stream.
+pub struct MetricsLayer(Arc<DaemonMetrics>);
+
+impl MetricsLayer {
+    pub fn new(metrics: Arc<DaemonMetrics>) -> Self {
+        Self(metrics)
+    }
+}
+
+impl<S> Layer<S> for MetricsLayer {
+    type Service = MetricsService<S>;
+
+    fn layer(&self, inner: S) -> Self::Service {
+        MetricsService {
+            inner,
+            metrics: self.0.clone(),
+        }
+    }
+}
+
+#[derive(Clone)]
+pub struct MetricsService<S> {
+    inner: S,
+    metrics: Arc<DaemonMetrics>,
+}
+
+impl<S, B> Service<Request<B>> for MetricsService<S>
+where
+    S: Service<Request<B>>,
+    S::Future: Send + 'static,
+{
+    type Response = S::Response;
+    type Error = S::Error;
+    type Future = Pin<Box<dyn Future<Output = Result<S::Response, S::Error>> + Send>>;
+
+    fn poll_ready(
+        &mut self,
+        cx: &mut std::task::Context<'_>,
+    ) -> std::task::Poll<Result<(), Self::Error>> {
+        self.inner.poll_ready(cx)
+    }
+
+    fn call(&mut self, req: Request<B>) -> Self::Future {
+        // Paths look like /turbodprotocol.Turbod/GetFileHashes
+        let method = req.uri().path().rsplit('/').next().unwrap_or_default();
+        let method = method.to_string();
+        let metrics = self.metrics.clone();
+        let start = Instant::now();
+        let response = self.inner.call(req);
+        Box::pin(async move {
+            let response = response.await;
+            metrics.record_request(&method, start.elapsed());
+            response
+        })
+    }
+}
+
+impl<T: NamedService> NamedService for MetricsService<T> {
+    const NAME: &'static str = T::NAME;
+}
+
+#[cfg(test)]
+mod test {
+    use std::time::Duration;
+
+    use turborepo_filewatch::hash_watcher::HashWatcherStats;
+
+    use super::DaemonMetrics;
+
+    #[test]
+    fn test_render_metrics() {
+        let metrics = DaemonMetrics::default();
+        metrics.record_file_event();
+        metrics.record_file_event();
+        metrics.record_request("GetFileHashes", Duration::from_millis(250));
+        metrics.record_request("GetFileHashes", Duration::from_millis(750));
+        metrics.record_request("Status", Duration::from_millis(2));
+
+        let rendered = metrics.render(Some(&HashWatcherStats {
+            tracked_files: 12,
+            hash_hits: 3,
+            invalidations: 1,
+            ..Default::default()
+        }));
+
+        for line in [
+            "# TYPE turbod_file_events_total counter",
+            "turbod_file_events_total 2",
+            "turbod_hash_tracked_files 12",
+            "turbod_hash_hits_total 3",
+            "turbod_hash_misses_total 0",
+            "turbod_hash_invalidations_total 1",
+            "turbod_grpc_request_duration_seconds_sum{method=\"GetFileHashes\"} 1",
+            "turbod_grpc_request_duration_seconds_count{method=\"GetFileHashes\"} 2",
+            "turbod_grpc_request_duration_seconds_count{method=\"Status\"} 1",
+            "turbod_grpc_request_duration_max_seconds{method=\"GetFileHashes\"} 0.75",
+        ] {
+            assert!(
+                rendered.lines().any(|l| l == line),
+                "missing {line:?} in:\n{rendered}"
+            );
+        }
+    }
+
+    #[test]
+    fn test_render_without_hash_watcher() {
+        let rendered = DaemonMetrics::default().render(None);
+        assert!(!rendered.contains("turbod_hash_"));
+        assert!(rendered.contains("turbod_file_events_total 0"));
+    }
+}
@@ -26,10 +26,15 @@ mod client;
 mod connector;
 mod default_timeout_layer;
 pub(crate) mod endpoint;
+mod metrics;
+mod package_graph;
+mod query;
 mod server;
+mod task_locks;
 
 pub use client::{DaemonClient, DaemonError};
 pub use connector::{DaemonConnector, DaemonConnectorError};
+pub use package_graph::package_graph_key;
 pub use server::{CloseReason, TurboGrpcService};
 use sha2::{Digest, Sha256};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
@@ -42,6 +47,7 @@ pub struct Paths {
     pub lsp_pid_file: AbsoluteSystemPathBuf,
     pub log_file: AbsoluteSystemPathBuf,
     pub log_folder: AbsoluteSystemPathBuf,
+    pub metrics_file: AbsoluteSystemPathBuf,
 }
 
 fn repo_hash(repo_root: &AbsoluteSystemPath) -> String {
@@ -72,6 +78,8 @@ impl Paths {
         let repo_hash = repo_hash(repo_root);
         let daemon_root = daemon_file_root(&repo_hash);
         let (log_file, log_folder) = daemon_log_file_and_folder(repo_root, &repo_hash);
+        let metrics_file =
+            log_folder.join_component(format!("{}-turbo.metrics", repo_hash).as_str());
         Self {
             pid_file: daemon_root.join_component("turbod.pid"),
             lock_file: daemon_root.join_component("turbod.lock"),
@@ -79,6 +87,7 @@ impl Paths {
             lsp_pid_file: daemon_root.join_component("lsp.pid"),
             log_file,
             log_folder,
+            metrics_file,
         }
     }
 }
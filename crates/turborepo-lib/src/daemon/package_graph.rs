@@ -0,0 +1,242 @@
+//! Package graph cache
+//!
+//! Resolving the transitive dependencies of every package against the lockfile
+//! is the slowest part of building the package graph in large repos. The daemon
+//! keeps the dependencies from its last build in memory, so `turbo run` only
+//! has to resolve the packages whose dependencies changed since then.
+
+use std::{
+    collections::HashMap,
+    path::PathBuf,
+    sync::{Arc, Mutex},
+};
+
+use sha2::{Digest, Sha256};
+use tracing::debug;
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath};
+use turborepo_repository::{
+    package_graph::{CachedDependencies, PackageGraph},
+    package_json::PackageJson,
+    package_manager::PackageManager,
+};
+
+use super::proto;
+use crate::turbo_json::TurboJson;
+
+/// Identifies the lockfile and workspace globs that dependencies are resolved
+/// from. Returns `None` if either of them can't be read.
+pub fn package_graph_key(
+    repo_root: &AbsoluteSystemPath,
+    root_package_json: &PackageJson,
+) -> Option<String> {
+    let package_manager = PackageManager::get_package_manager(root_package_json).ok()?;
+    let globs = package_manager.get_workspace_globs(repo_root).ok()?;
+    let lockfile = package_manager.lockfile_path(repo_root).read().ok()?;
+
+    let mut hasher = Sha256::new();
+    hasher.update(&lockfile);
+    for glob in globs.raw_inclusions.iter().chain(&globs.raw_exclusions) {
+        hasher.update(glob.as_bytes());
+        hasher.update([0]);
+    }
+    Some(hex::encode(hasher.finalize()))
+}
+
+pub fn dependencies_from_proto(
+    packages: Vec<proto::PackageDependencies>,
+) -> HashMap<String, CachedDependencies> {
+    packages
+        .into_iter()
+        .map(|package| {
+            let transitive_dependencies = package
+                .transitive_dependencies
+                .into_iter()
+                .map(|dependency| {
+                    turborepo_lockfiles::Package::new(dependency.key, dependency.version)
+                })
+                .collect();
+            (
+                package.package_path,
+                CachedDependencies {
+                    external_dependencies: package.external_dependencies,
+                    transitive_dependencies,
+                },
+            )
+        })
+        .collect()
+}
+
+fn dependencies_to_proto(
+    dependencies: HashMap<String, CachedDependencies>,
+) -> Vec<proto::PackageDependencies> {
+    dependencies
+        .into_iter()
+        .map(|(package_path, dependencies)| proto::PackageDependencies {
+            package_path,
+            external_dependencies: dependencies.external_dependencies,
+            transitive_dependencies: dependencies
+                .transitive_dependencies
+                .into_iter()
+                .map(|dependency| proto::LockfilePackage {
+                    key: dependency.key,
+                    version: dependency.version,
+                })
+                .collect(),
+        })
+        .collect()
+}
+
+struct CachedGraph {
+    key: String,
+    packages: Vec<proto::PackageDependencies>,
+}
+
+#[derive(Default)]
+struct State {
+    graph: Option<Arc<CachedGraph>>,
+    building: bool,
+    // Bumped on every invalidation so that a build that raced with a change
+    // isn't cached
+    generation: u64,
+}
+
+pub struct PackageGraphCache {
+    repo_root: AbsoluteSystemPathBuf,
+    state: Arc<Mutex<State>>,
+}
+
+impl PackageGraphCache {
+    pub fn new(repo_root: AbsoluteSystemPathBuf) -> Self {
+        Self {
+            repo_root,
+            state: Arc::default(),
+        }
+    }
+
+    /// Returns the cached dependencies if they were resolved for `key`.
+    /// Otherwise they're resolved in the background so that they're ready
+    /// for the next run.
+    pub fn get(&self, key: &str) -> Option<Vec<proto::PackageDependencies>> {
+        let mut state = self.state.lock().expect("package graph lock poisoned");
+        if let Some(graph) = state.graph.as_ref().filter(|graph| graph.key == key) {
+            return Some(graph.packages.clone());
+        }
+        if !state.building {
+            state.building = true;
+            tokio::spawn(build(
+                self.repo_root.clone(),
+                self.state.clone(),
+                state.generation,
+            ));
+        }
+        None
+    }
+
+    /// Drops the cached dependencies if any of the changed paths could affect
+    /// them
+    pub fn invalidate(&self, paths: &[PathBuf]) {
+        let affects_dependencies = paths.iter().any(|path| {
+            path.file_name()
+                .and_then(|name| name.to_str())
+                .map_or(false, |name| {
+                    name == "package.json"
+                        || name == "pnpm-workspace.yaml"
+                        || PackageManager::supported_managers()
+                            .iter()
+                            .any(|manager| manager.lockfile_name() == name)
+                })
+        });
+        if affects_dependencies {
+            let mut state = self.state.lock().expect("package graph lock poisoned");
+            state.graph = None;
+            state.generation += 1;
+        }
+    }
+}
+
+async fn build(repo_root: AbsoluteSystemPathBuf, state: Arc<Mutex<State>>, generation: u64) {
+    let graph = build_graph(&repo_root).await;
+    let mut state = state.lock().expect("package graph lock poisoned");
+    state.building = false;
+    if state.generation == generation {
+        state.graph = graph.map(Arc::new);
+    }
+}
+
+async fn build_graph(repo_root: &AbsoluteSystemPath) -> Option<CachedGraph> {
+    let root_package_json = PackageJson::load(&repo_root.join_component("package.json")).ok()?;
+    let key = package_graph_key(repo_root, &root_package_json)?;
+    let workspace_providers = TurboJson::load(
+        repo_root,
+        AnchoredSystemPath::empty(),
+        &root_package_json,
+        false,
+    )
+    .map(|turbo_json| turbo_json.workspace_providers)
+    .unwrap_or_default();
+    let pkg_dep_graph = PackageGraph::builder(repo_root, root_package_json)
+        .with_workspace_providers(workspace_providers)
+        .build()
+        .await
+        .map_err(|e| debug!("unable to build package graph: {e}"))
+        .ok()?;
+    Some(CachedGraph {
+        key,
+        packages: dependencies_to_proto(pkg_dep_graph.cached_dependencies()),
+    })
+}
+
+#[cfg(test)]
+mod test {
+    use std::{
+        collections::{HashMap, HashSet},
+        sync::Arc,
+    };
+
+    use turbopath::AbsoluteSystemPathBuf;
+    use turborepo_repository::package_graph::CachedDependencies;
+
+    use super::{dependencies_from_proto, dependencies_to_proto, CachedGraph, PackageGraphCache};
+
+    #[test]
+    fn test_dependencies_roundtrip() {
+        let dependencies = HashMap::from([(
+            "packages/a".to_string(),
+            CachedDependencies {
+                external_dependencies: HashMap::from([("lodash".to_string(), "^4".to_string())]),
+                transitive_dependencies: HashSet::from([turborepo_lockfiles::Package::new(
+                    "lodash@4.17.21",
+                    "4.17.21",
+                )]),
+            },
+        )]);
+        assert_eq!(
+            dependencies_from_proto(dependencies_to_proto(dependencies.clone())),
+            dependencies
+        );
+    }
+
+    #[test]
+    fn test_invalidate() {
+        let root =
+            AbsoluteSystemPathBuf::new(if cfg!(windows) { r"C:\repo" } else { "/repo" }).unwrap();
+        let cache = PackageGraphCache::new(root.clone());
+        let cache_graph = || {
+            cache.state.lock().unwrap().graph = Some(Arc::new(CachedGraph {
+                key: "key".to_string(),
+                packages: vec![],
+            }));
+        };
+
+        cache_graph();
+        let changed = root.join_components(&["apps", "web", "index.ts"]);
+        cache.invalidate(&[changed.as_std_path().to_owned()]);
+        assert!(cache.get("key").is_some());
+
+        for changed in ["package.json", "pnpm-lock.yaml", "yarn.lock"] {
+            cache_graph();
+            cache.invalidate(&[root.join_component(changed).as_std_path().to_owned()]);
+            assert!(cache.state.lock().unwrap().graph.is_none(), "{changed}");
+        }
+    }
+}
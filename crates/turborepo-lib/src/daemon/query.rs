@@ -0,0 +1,406 @@
+//! Queries for tooling built on top of turbo
+//!
+//! Editor extensions and CI schedulers often need the same information that
+//! `turbo run --dry=json` prints. Answering these queries from the daemon
+//! reuses its file hashes and resolved dependencies instead of recomputing
+//! them for every invocation.
+
+use std::collections::{BTreeMap, HashSet};
+
+use futures::future::try_join_all;
+use itertools::Itertools;
+use thiserror::Error;
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath};
+use turborepo_errors::Spanned;
+use turborepo_filewatch::hash_watcher::{
+    Error as HashWatcherError, HashSpec, HashWatcher, InputGlobs,
+};
+use turborepo_repository::{
+    package_graph::{self, PackageGraph, PackageName},
+    package_json::{self, PackageJson},
+};
+use turborepo_scm::SCM;
+
+use super::{
+    package_graph::{dependencies_from_proto, package_graph_key, PackageGraphCache},
+    proto,
+};
+use crate::{
+    config,
+    engine::{self, EngineBuilder, TaskNode},
+    hash::{FileHashes, TurboHash},
+    opts::ScopeOpts,
+    run::{scope, scope::ResolutionError, task_id::TaskName},
+    turbo_json::TurboJson,
+};
+
+#[derive(Debug, Error)]
+pub enum Error {
+    #[error("unable to load package.json: {0}")]
+    PackageJson(#[from] package_json::Error),
+    #[error(transparent)]
+    PackageGraph(#[from] package_graph::Error),
+    #[error(transparent)]
+    Config(#[from] config::Error),
+    #[error(transparent)]
+    Resolution(#[from] ResolutionError),
+    #[error(transparent)]
+    Engine(#[from] engine::BuilderError),
+    #[error("file hashing failed: {0}")]
+    FileHashing(#[from] HashWatcherError),
+}
+
+impl From<Error> for tonic::Status {
+    fn from(value: Error) -> Self {
+        match value {
+            // Filters and task names come from the client
+            e @ (Error::Resolution(_) | Error::Engine(_)) => {
+                tonic::Status::invalid_argument(e.to_string())
+            }
+            e => tonic::Status::failed_precondition(e.to_string()),
+        }
+    }
+}
+
+/// Hashes the files of every package, keyed by package name
+pub async fn package_hashes(
+    repo_root: &AbsoluteSystemPath,
+    package_graph_cache: &PackageGraphCache,
+    hash_watcher: &HashWatcher,
+) -> Result<BTreeMap<String, String>, Error> {
+    let (pkg_dep_graph, _) = load(repo_root, package_graph_cache).await?;
+    let packages = pkg_dep_graph
+        .packages()
+        .filter(|(name, _)| **name != PackageName::Root)
+        .map(|(name, info)| (name.to_string(), info.package_path().to_owned()))
+        .collect::<Vec<_>>();
+
+    // The hash watcher answers queries for packages whose files are already
+    // hashed without waiting on the others
+    let package_hashes = try_join_all(packages.into_iter().map(|(name, package_path)| async {
+        let file_hashes = hash_watcher
+            .get_file_hashes(HashSpec {
+                package_path,
+                inputs: InputGlobs::Default,
+            })
+            .await?;
+        Ok::<_, Error>((name, FileHashes(file_hashes).hash()))
+    }))
+    .await?;
+    Ok(package_hashes.into_iter().collect())
+}
+
+/// Lists the packages that changed between `since` and `until`, or the
+/// working tree if `until` isn't given
+pub async fn changed_packages(
+    repo_root: &AbsoluteSystemPath,
+    package_graph_cache: &PackageGraphCache,
+    since: &str,
+    until: Option<&str>,
+) -> Result<Vec<String>, Error> {
+    let filter = match until {
+        Some(until) => format!("[{since}...{until}]"),
+        None => format!("[{since}]"),
+    };
+    let (pkg_dep_graph, root_turbo_json) = load(repo_root, package_graph_cache).await?;
+    let (packages, _) =
+        resolve_packages(repo_root, &pkg_dep_graph, &root_turbo_json, vec![filter])?;
+    Ok(packages
+        .iter()
+        .map(PackageName::to_string)
+        .sorted()
+        .collect())
+}
+
+/// Builds the task graph that `turbo run` would execute
+pub async fn task_graph(
+    repo_root: &AbsoluteSystemPath,
+    package_graph_cache: &PackageGraphCache,
+    tasks: Vec<String>,
+    filters: Vec<String>,
+) -> Result<Vec<proto::GraphTask>, Error> {
+    let (pkg_dep_graph, root_turbo_json) = load(repo_root, package_graph_cache).await?;
+    let (mut packages, is_all_packages) =
+        resolve_packages(repo_root, &pkg_dep_graph, &root_turbo_json, filters)?;
+    // Root tasks are only included by filters that select every package, this
+    // matches what `turbo run` does
+    if is_all_packages
+        && tasks.iter().any(|task| {
+            let task_name = TaskName::from(task.as_str());
+            let task_name = if task_name.is_package_task() {
+                task_name
+            } else {
+                task_name.into_root_task()
+            };
+            root_turbo_json.tasks.contains_key(&task_name)
+        })
+    {
+        packages.insert(PackageName::Root);
+    }
+
+    let engine = EngineBuilder::new(repo_root, &pkg_dep_graph, false)
+        .with_root_tasks(root_turbo_json.tasks.keys().cloned())
+        .with_turbo_jsons(Some(
+            Some((PackageName::Root, root_turbo_json.clone()))
+                .into_iter()
+                .collect(),
+        ))
+        .with_workspaces(packages.into_iter().collect())
+        .with_tasks(
+            tasks
+                .iter()
+                .map(|task| Spanned::new(TaskName::from(task.as_str()).into_owned())),
+        )
+        .build()?;
+
+    Ok(engine
+        .tasks()
+        .filter_map(|node| match node {
+            TaskNode::Task(task_id) => Some(task_id),
+            TaskNode::Root => None,
+        })
+        .map(|task_id| proto::GraphTask {
+            task_id: task_id.to_string(),
+            dependencies: engine
+                .dependencies(task_id)
+                .into_iter()
+                .flatten()
+                .filter_map(|node| match node {
+                    TaskNode::Task(dependency) => Some(dependency.to_string()),
+                    TaskNode::Root => None,
+                })
+                .sorted()
+                .collect(),
+        })
+        .sorted_by(|a, b| a.task_id.cmp(&b.task_id))
+        .collect())
+}
+
+async fn load(
+    repo_root: &AbsoluteSystemPath,
+    package_graph_cache: &PackageGraphCache,
+) -> Result<(PackageGraph, TurboJson), Error> {
+    let root_package_json = PackageJson::load(&repo_root.join_component("package.json"))?;
+    let root_turbo_json = TurboJson::load(
+        repo_root,
+        AnchoredSystemPath::empty(),
+        &root_package_json,
+        false,
+    )?;
+    let cached_dependencies = package_graph_key(repo_root, &root_package_json)
+        .and_then(|key| package_graph_cache.get(&key))
+        .map(dependencies_from_proto);
+    let pkg_dep_graph = PackageGraph::builder(repo_root, root_package_json)
+        .with_cached_dependencies(cached_dependencies)
+        .with_workspace_providers(root_turbo_json.workspace_providers.clone())
+        .build()
+        .await?;
+    pkg_dep_graph.validate()?;
+    Ok((pkg_dep_graph, root_turbo_json))
+}
+
+fn resolve_packages(
+    repo_root: &AbsoluteSystemPath,
+    pkg_dep_graph: &PackageGraph,
+    root_turbo_json: &TurboJson,
+    filter_patterns: Vec<String>,
+) -> Result<(HashSet<PackageName>, bool), Error> {
+    let scope_opts = ScopeOpts::from_filters(filter_patterns);
+    let scm = SCM::new(repo_root);
+    Ok(scope::resolve_packages(
+        &scope_opts,
+        repo_root,
+        pkg_dep_graph,
+        &scm,
+        root_turbo_json,
+    )?)
+}
+
+#[cfg(test)]
+mod test {
+    use std::{process::Command, time::Duration};
+
+    use turbopath::{AbsoluteSystemPathBuf, RelativeUnixPath};
+    use turborepo_scm::SCM;
+
+    use super::{changed_packages, package_hashes, task_graph};
+    use crate::{
+        cli::DaemonWatcher,
+        daemon::{package_graph::PackageGraphCache, proto, server::FileWatching},
+        hash::{FileHashes, TurboHash},
+    };
+
+    fn git(repo_root: &AbsoluteSystemPathBuf, args: &[&str]) {
+        let output = Command::new("git")
+            .args(["-c", "user.name=test", "-c", "user.email=test@example.com"])
+            .args(args)
+            .current_dir(repo_root)
+            .output()
+            .unwrap();
+        assert!(output.status.success(), "git {args:?} failed: {output:?}");
+    }
+
+    // Sets up a committed repository where `b` depends on `a`
+    fn setup_repo() -> (tempfile::TempDir, AbsoluteSystemPathBuf) {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        for (path, contents) in [
+            (
+                "package.json",
+                r#"{"name": "root", "packageManager": "npm@10.5.0", "workspaces": ["packages/*"]}"#,
+            ),
+            (
+                "package-lock.json",
+                r#"{"name": "root", "lockfileVersion": 3, "requires": true, "packages": {}}"#,
+            ),
+            (
+                "turbo.json",
+                r#"{"tasks": {"build": {"dependsOn": ["^build"]}, "lint": {}}}"#,
+            ),
+            (
+                "packages/a/package.json",
+                r#"{"name": "a", "scripts": {"build": "echo a", "lint": "echo a"}}"#,
+            ),
+            ("packages/a/index.js", "export const a = 1;"),
+            (
+                "packages/b/package.json",
+                r#"{"name": "b", "dependencies": {"a": "*"}, "scripts": {"build": "echo b"}}"#,
+            ),
+            ("packages/b/index.js", "export const b = 2;"),
+        ] {
+            let file = repo_root.join_unix_path(RelativeUnixPath::new(path).unwrap());
+            file.ensure_dir().unwrap();
+            file.create_with_contents(contents).unwrap();
+        }
+        git(&repo_root, &["init", "."]);
+        git(&repo_root, &["add", "."]);
+        git(&repo_root, &["commit", "-m", "init"]);
+        (tmp_dir, repo_root)
+    }
+
+    fn graph_task(task_id: &str, dependencies: &[&str]) -> proto::GraphTask {
+        proto::GraphTask {
+            task_id: task_id.to_string(),
+            dependencies: dependencies.iter().map(|d| d.to_string()).collect(),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_task_graph() {
+        let (_tmp_dir, repo_root) = setup_repo();
+        let cache = PackageGraphCache::new(repo_root.clone());
+
+        let tasks = task_graph(&repo_root, &cache, vec!["build".to_string()], vec![])
+            .await
+            .unwrap();
+        assert_eq!(
+            tasks,
+            vec![
+                graph_task("a#build", &[]),
+                graph_task("b#build", &["a#build"]),
+            ]
+        );
+
+        // Filters select the packages, and dependencies are still included
+        let tasks = task_graph(
+            &repo_root,
+            &cache,
+            vec!["build".to_string(), "lint".to_string()],
+            vec!["a".to_string()],
+        )
+        .await
+        .unwrap();
+        assert_eq!(
+            tasks,
+            vec![graph_task("a#build", &[]), graph_task("a#lint", &[])]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_task_graph_missing_task() {
+        let (_tmp_dir, repo_root) = setup_repo();
+        let cache = PackageGraphCache::new(repo_root.clone());
+
+        let status: tonic::Status =
+            task_graph(&repo_root, &cache, vec!["missing".to_string()], vec![])
+                .await
+                .unwrap_err()
+                .into();
+        assert_eq!(status.code(), tonic::Code::InvalidArgument);
+    }
+
+    #[tokio::test]
+    async fn test_changed_packages() {
+        let (_tmp_dir, repo_root) = setup_repo();
+        let cache = PackageGraphCache::new(repo_root.clone());
+
+        assert_eq!(
+            changed_packages(&repo_root, &cache, "HEAD", None)
+                .await
+                .unwrap(),
+            Vec::<String>::new()
+        );
+
+        repo_root
+            .join_components(&["packages", "a", "index.js"])
+            .create_with_contents("export const a = 3;")
+            .unwrap();
+        assert_eq!(
+            changed_packages(&repo_root, &cache, "HEAD", None)
+                .await
+                .unwrap(),
+            vec!["a".to_string()]
+        );
+
+        git(&repo_root, &["commit", "-am", "change a"]);
+        assert_eq!(
+            changed_packages(&repo_root, &cache, "HEAD~1", Some("HEAD"))
+                .await
+                .unwrap(),
+            vec!["a".to_string()]
+        );
+    }
+
+    // the hash watcher needs a file watcher, which runs on its own threads
+    #[tokio::test(flavor = "multi_thread")]
+    async fn test_package_hashes() {
+        let (_tmp_dir, repo_root) = setup_repo();
+        let cache = PackageGraphCache::new(repo_root.clone());
+        let file_watching = FileWatching::new(repo_root.clone(), DaemonWatcher::Native).unwrap();
+
+        // Files are hashed once package discovery has finished
+        let mut hashes = None;
+        for _ in 0..50 {
+            match package_hashes(&repo_root, &cache, &file_watching.hash_watcher).await {
+                Ok(result) => {
+                    hashes = Some(result);
+                    break;
+                }
+                Err(_) => tokio::time::sleep(Duration::from_millis(100)).await,
+            }
+        }
+        let hashes = hashes.expect("package hashes");
+
+        let scm = SCM::new(&repo_root);
+        let expected = |package: &str| {
+            let package_path = repo_root
+                .anchor(&repo_root.join_components(&["packages", package]))
+                .unwrap();
+            FileHashes(
+                scm.get_package_file_hashes::<&str>(&repo_root, &package_path, &[], None)
+                    .unwrap(),
+            )
+            .hash()
+        };
+        assert_eq!(
+            hashes.into_iter().collect::<Vec<_>>(),
+            vec![
+                ("a".to_string(), expected("a")),
+                ("b".to_string(), expected("b")),
+            ]
+        );
+    }
+}
@@ -31,16 +31,23 @@ use turborepo_filewatch::{
     globwatcher::{Error as GlobWatcherError, GlobError, GlobSet, GlobWatcher},
     hash_watcher::{Error as HashWatcherError, HashSpec, HashWatcher, InputGlobs},
     package_watcher::{PackageWatchError, PackageWatcher},
-    FileSystemWatcher, WatchError,
+    FileSystemWatcher, NativeBackend, PollingBackend, WatchBackend, WatchError, WatchmanBackend,
 };
 use turborepo_repository::package_manager;
 use turborepo_scm::SCM;
 
-use super::{bump_timeout::BumpTimeout, endpoint::SocketOpenError, proto};
+use super::{bump_timeout::BumpTimeout, endpoint::SocketOpenError, metrics::STATS_TIMEOUT, proto};
 use crate::{
+    cli::DaemonWatcher,
     daemon::{
-        bump_timeout_layer::BumpTimeoutLayer, default_timeout_layer::DefaultTimeoutLayer,
-        endpoint::listen_socket, Paths,
+        bump_timeout_layer::BumpTimeoutLayer,
+        default_timeout_layer::DefaultTimeoutLayer,
+        endpoint::listen_socket,
+        metrics::{DaemonMetrics, MetricsLayer},
+        package_graph::PackageGraphCache,
+        query,
+        task_locks::{self, TaskLocks},
+        Paths,
     },
     package_changes_watcher::{PackageChangeEvent, PackageChangesWatcher},
 };
@@ -110,13 +117,35 @@ impl FileWatching {
     /// waiting for the filewatcher to be ready. Using `OptionalWatch`,
     /// dependent services can wait for resources they need to become
     /// available, and the server can start up without waiting for them.
-    pub fn new(repo_root: AbsoluteSystemPathBuf) -> Result<FileWatching, WatchError> {
-        let watcher = Arc::new(FileSystemWatcher::new_with_default_cookie_dir(&repo_root)?);
+    pub fn new(
+        repo_root: AbsoluteSystemPathBuf,
+        watcher: DaemonWatcher,
+    ) -> Result<FileWatching, WatchError> {
+        let backend: Box<dyn WatchBackend> = match watcher {
+            DaemonWatcher::Native => Box::new(NativeBackend),
+            DaemonWatcher::Watchman => Box::new(WatchmanBackend),
+            DaemonWatcher::Polling => Box::new(PollingBackend::default()),
+        };
+        // Polling works everywhere, so it's used if the other backends can't
+        // be started or run out of inotify watches while they're running
+        let fallback: Option<Box<dyn WatchBackend>> = match watcher {
+            DaemonWatcher::Native | DaemonWatcher::Watchman => {
+                Some(Box::new(PollingBackend::default()))
+            }
+            DaemonWatcher::Polling => None,
+        };
+        let watcher = Arc::new(FileSystemWatcher::new_with_backend(
+            &repo_root,
+            repo_root.join_components(&[".turbo", "cookies"]),
+            backend,
+            fallback,
+        )?);
         let recv = watcher.watch();
 
+        // Cookies can't be observed before the backend reports changes
         let cookie_writer = CookieWriter::new(
             watcher.cookie_dir(),
-            Duration::from_millis(100),
+            Duration::from_millis(100) + watcher.latency(),
             recv.clone(),
         );
         let glob_watcher = Arc::new(GlobWatcher::new(
@@ -159,6 +188,7 @@ pub struct TurboGrpcService<S> {
     repo_root: AbsoluteSystemPathBuf,
     paths: Paths,
     timeout: Duration,
+    watcher: DaemonWatcher,
     external_shutdown: S,
 }
 
@@ -185,16 +215,24 @@ where
             repo_root,
             paths,
             timeout,
+            watcher: DaemonWatcher::default(),
             external_shutdown,
         }
     }
 
+    /// Sets how the server watches the repository for changes
+    pub fn with_watcher(mut self, watcher: DaemonWatcher) -> Self {
+        self.watcher = watcher;
+        self
+    }
+
     pub async fn serve(self) -> Result<CloseReason, package_manager::Error> {
         let Self {
             external_shutdown,
             paths,
             repo_root,
             timeout,
+            watcher,
         } = self;
 
         // A channel to trigger the shutdown of the gRPC server. This is handed out
@@ -202,8 +240,18 @@ where
         // well as available to the gRPC server itself to handle the shutdown RPC.
         let (trigger_shutdown, mut shutdown_signal) = mpsc::channel::<()>(1);
 
-        let (service, exit_root_watch, watch_root_handle) =
-            TurboGrpcServiceInner::new(repo_root.clone(), trigger_shutdown, paths.log_file);
+        let metrics = Arc::new(DaemonMetrics::default());
+        let (service, exit_root_watch, watch_root_handle) = TurboGrpcServiceInner::new(
+            repo_root.clone(),
+            watcher,
+            trigger_shutdown,
+            paths.log_file,
+            metrics.clone(),
+        );
+        let metrics_handle = tokio::task::spawn(metrics.clone().write_periodically(
+            service.file_watching.hash_watcher.clone(),
+            paths.metrics_file,
+        ));
 
         let running = Arc::new(AtomicBool::new(true));
         let (_pid_lock, stream) =
@@ -228,6 +276,7 @@ where
 
         let server_fut = {
             let service = ServiceBuilder::new()
+                .layer(MetricsLayer::new(metrics))
                 .layer(BumpTimeoutLayer::new(bump_timeout.clone()))
                 .layer(DefaultTimeoutLayer)
                 .service(crate::daemon::proto::turbod_server::TurbodServer::new(
@@ -251,6 +300,7 @@ where
         tracing::debug!("server exited");
         // Ensure our timer will exit
         running.store(false, Ordering::SeqCst);
+        metrics_handle.abort();
         // We expect to have a signal from the grpc server on what triggered the exit
         let close_reason = shutdown_reason.await.unwrap_or(CloseReason::ServerClosed);
         // Now that the server has exited, the TurboGrpcService instance should be
@@ -265,12 +315,15 @@ where
 }
 
 struct TurboGrpcServiceInner {
+    repo_root: AbsoluteSystemPathBuf,
     shutdown: mpsc::Sender<()>,
     file_watching: FileWatching,
     times_saved: Arc<Mutex<HashMap<String, u64>>>,
     start_time: Instant,
     log_file: AbsoluteSystemPathBuf,
     package_watcher: Arc<PackageWatcher>,
+    package_graph_cache: Arc<PackageGraphCache>,
+    task_locks: Arc<TaskLocks>,
 }
 
 // we have a grpc service that uses watching package discovery, and where the
@@ -279,18 +332,21 @@ struct TurboGrpcServiceInner {
 impl TurboGrpcServiceInner {
     pub fn new(
         repo_root: AbsoluteSystemPathBuf,
+        watcher: DaemonWatcher,
         trigger_shutdown: mpsc::Sender<()>,
         log_file: AbsoluteSystemPathBuf,
+        metrics: Arc<DaemonMetrics>,
     ) -> (
         Self,
         oneshot::Sender<()>,
         JoinHandle<Result<(), WatchError>>,
     ) {
-        let file_watching = FileWatching::new(repo_root.clone()).unwrap();
+        let file_watching = FileWatching::new(repo_root.clone(), watcher).unwrap();
 
         tracing::debug!("initing package discovery");
         // Note that we're cloning the Arc, not the package watcher itself
         let package_watcher = Arc::clone(&file_watching.package_watcher);
+        let package_graph_cache = Arc::new(PackageGraphCache::new(repo_root.clone()));
 
         // exit_root_watch delivers a signal to the root watch loop to exit.
         // In the event that the server shuts down via some other mechanism, this
@@ -301,14 +357,19 @@ impl TurboGrpcServiceInner {
             repo_root.clone(),
             trigger_shutdown.clone(),
             root_watch_exit_signal,
+            metrics,
+            package_graph_cache.clone(),
         ));
 
         (
             TurboGrpcServiceInner {
+                repo_root,
                 package_watcher,
+                package_graph_cache,
                 shutdown: trigger_shutdown,
                 file_watching,
                 times_saved: Arc::new(Mutex::new(HashMap::new())),
+                task_locks: Arc::new(TaskLocks::default()),
                 start_time: Instant::now(),
                 log_file,
             },
@@ -389,6 +450,8 @@ async fn watch_root(
     root: AbsoluteSystemPathBuf,
     trigger_shutdown: mpsc::Sender<()>,
     mut exit_signal: oneshot::Receiver<()>,
+    metrics: Arc<DaemonMetrics>,
+    package_graph_cache: Arc<PackageGraphCache>,
 ) -> Result<(), WatchError> {
     let mut recv_events = filewatching_access
         .watcher
@@ -409,6 +472,10 @@ async fn watch_root(
                     break;
                 };
                 tracing::debug!("root watcher received event: {:?}", event);
+                metrics.record_file_event();
+                if let Ok(event) = &event {
+                    package_graph_cache.invalidate(&event.paths);
+                }
                 let should_trigger_shutdown = match event {
                     // filewatching can throw some weird events, so check that the root is actually gone
                     // before triggering a shutdown
@@ -479,10 +546,22 @@ impl proto::turbod_server::Turbod for TurboGrpcServiceInner {
         &self,
         _request: tonic::Request<proto::StatusRequest>,
     ) -> Result<tonic::Response<proto::StatusResponse>, tonic::Status> {
+        // File hashing may not be ready yet or be busy, that shouldn't fail or
+        // hold up the status check
+        let hash_stats =
+            tokio::time::timeout(STATS_TIMEOUT, self.file_watching.hash_watcher.stats())
+                .await
+                .ok()
+                .and_then(|stats| stats.ok());
         Ok(tonic::Response::new(proto::StatusResponse {
             daemon_status: Some(proto::DaemonStatus {
                 uptime_msec: self.start_time.elapsed().as_millis() as u64,
                 log_file: self.log_file.to_string(),
+                pid: std::process::id(),
+                repo_root: self.repo_root.to_string(),
+                tracked_files: hash_stats.as_ref().map(|stats| stats.tracked_files as u64),
+                pending_hashes: hash_stats.as_ref().map(|stats| stats.pending_hashes as u64),
+                last_error: hash_stats.and_then(|stats| stats.last_error),
             }),
         }))
     }
@@ -533,6 +612,90 @@ impl proto::turbod_server::Turbod for TurboGrpcServiceInner {
         }))
     }
 
+    async fn get_package_graph(
+        &self,
+        request: tonic::Request<proto::GetPackageGraphRequest>,
+    ) -> Result<tonic::Response<proto::GetPackageGraphResponse>, tonic::Status> {
+        let key = request.into_inner().key;
+        match self.package_graph_cache.get(&key) {
+            Some(packages) => Ok(tonic::Response::new(proto::GetPackageGraphResponse {
+                packages,
+            })),
+            None => Err(tonic::Status::unavailable(
+                "package graph not available for this lockfile yet",
+            )),
+        }
+    }
+
+    async fn acquire_task_lock(
+        &self,
+        request: tonic::Request<proto::AcquireTaskLockRequest>,
+    ) -> Result<tonic::Response<proto::AcquireTaskLockResponse>, tonic::Status> {
+        let inner = request.into_inner();
+        let acquired = self
+            .task_locks
+            .acquire(inner.hash, inner.pid, task_locks::start_time);
+        Ok(tonic::Response::new(proto::AcquireTaskLockResponse {
+            acquired,
+        }))
+    }
+
+    async fn release_task_lock(
+        &self,
+        request: tonic::Request<proto::ReleaseTaskLockRequest>,
+    ) -> Result<tonic::Response<proto::ReleaseTaskLockResponse>, tonic::Status> {
+        let inner = request.into_inner();
+        self.task_locks.release(&inner.hash, inner.pid);
+        Ok(tonic::Response::new(proto::ReleaseTaskLockResponse {}))
+    }
+
+    async fn get_package_hashes_blocking(
+        &self,
+        _request: tonic::Request<proto::GetPackageHashesRequest>,
+    ) -> Result<tonic::Response<proto::GetPackageHashesResponse>, tonic::Status> {
+        let package_hashes = query::package_hashes(
+            &self.repo_root,
+            &self.package_graph_cache,
+            &self.file_watching.hash_watcher,
+        )
+        .await?;
+        Ok(tonic::Response::new(proto::GetPackageHashesResponse {
+            package_hashes: package_hashes.into_iter().collect(),
+        }))
+    }
+
+    async fn get_changed_packages_blocking(
+        &self,
+        request: tonic::Request<proto::GetChangedPackagesRequest>,
+    ) -> Result<tonic::Response<proto::GetChangedPackagesResponse>, tonic::Status> {
+        let inner = request.into_inner();
+        let packages = query::changed_packages(
+            &self.repo_root,
+            &self.package_graph_cache,
+            &inner.since,
+            inner.until.as_deref(),
+        )
+        .await?;
+        Ok(tonic::Response::new(proto::GetChangedPackagesResponse {
+            packages,
+        }))
+    }
+
+    async fn get_task_graph_blocking(
+        &self,
+        request: tonic::Request<proto::GetTaskGraphRequest>,
+    ) -> Result<tonic::Response<proto::GetTaskGraphResponse>, tonic::Status> {
+        let inner = request.into_inner();
+        let tasks = query::task_graph(
+            &self.repo_root,
+            &self.package_graph_cache,
+            inner.tasks,
+            inner.filters,
+        )
+        .await?;
+        Ok(tonic::Response::new(proto::GetTaskGraphResponse { tasks }))
+    }
+
     async fn discover_packages(
         &self,
         _request: tonic::Request<proto::DiscoverPackagesRequest>,
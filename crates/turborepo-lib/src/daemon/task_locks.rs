@@ -0,0 +1,117 @@
+//! Locks on task hashes that keep turbo processes on the same machine from
+//! running the same task at once. A process that finds a task locked waits
+//! until the lock is released, and then uses the result of the process that
+//! held it from the cache instead of running the task again.
+
+use std::{collections::HashMap, sync::Mutex};
+
+use sysinfo::{Pid, ProcessExt, ProcessRefreshKind, SystemExt};
+
+#[derive(Debug, Default)]
+pub struct TaskLocks {
+    // task hash -> the process that holds the lock
+    owners: Mutex<HashMap<String, Owner>>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq)]
+struct Owner {
+    pid: u32,
+    // Pids are reused once a process exits, so the lock is only held as long
+    // as a process with the same pid and start time is running
+    start_time: Option<u64>,
+}
+
+impl TaskLocks {
+    /// Locks `hash` for the process `pid`. The lock is taken over if the
+    /// process that holds it is no longer running, since it can't release it.
+    /// `start_time` returns when a process started, or `None` if it isn't
+    /// running.
+    pub fn acquire(&self, hash: String, pid: u32, start_time: impl Fn(u32) -> Option<u64>) -> bool {
+        let mut owners = self.owners.lock().expect("lock poisoned");
+        match owners.get(&hash) {
+            Some(owner) if owner.pid != pid && Self::is_running(owner, &start_time) => false,
+            _ => {
+                owners.insert(
+                    hash,
+                    Owner {
+                        pid,
+                        start_time: start_time(pid),
+                    },
+                );
+                true
+            }
+        }
+    }
+
+    fn is_running(owner: &Owner, start_time: impl Fn(u32) -> Option<u64>) -> bool {
+        match (start_time(owner.pid), owner.start_time) {
+            (Some(current), Some(recorded)) => current == recorded,
+            // The start time of the owner couldn't be read when it took the lock,
+            // so all we know is that some process has its pid
+            (Some(_), None) => true,
+            (None, _) => false,
+        }
+    }
+
+    /// Releases `hash` if it's locked by the process `pid`
+    pub fn release(&self, hash: &str, pid: u32) {
+        let mut owners = self.owners.lock().expect("lock poisoned");
+        if owners.get(hash).map(|owner| owner.pid) == Some(pid) {
+            owners.remove(hash);
+        }
+    }
+}
+
+/// Returns when the process `pid` started, in seconds since the epoch, or
+/// `None` if it isn't running.
+pub fn start_time(pid: u32) -> Option<u64> {
+    let pid = Pid::from(pid as usize);
+    let mut system = sysinfo::System::new();
+    system.refresh_process_specifics(pid, ProcessRefreshKind::new());
+    system.process(pid).map(|process| process.start_time())
+}
+
+#[cfg(test)]
+mod test {
+    use super::TaskLocks;
+
+    #[test]
+    fn test_task_locks() {
+        let locks = TaskLocks::default();
+        let running = |_| Some(100);
+
+        assert!(locks.acquire("abc".to_string(), 1, running));
+        // Acquiring a lock that's already held by the same process succeeds
+        assert!(locks.acquire("abc".to_string(), 1, running));
+        assert!(!locks.acquire("abc".to_string(), 2, running));
+        assert!(locks.acquire("def".to_string(), 2, running));
+
+        // Only the process holding the lock can release it
+        locks.release("abc", 2);
+        assert!(!locks.acquire("abc".to_string(), 2, running));
+        locks.release("abc", 1);
+        assert!(locks.acquire("abc".to_string(), 2, running));
+    }
+
+    #[test]
+    fn test_task_lock_owner_exited() {
+        let locks = TaskLocks::default();
+
+        assert!(locks.acquire("abc".to_string(), 1, |_| Some(100)));
+        assert!(locks.acquire("abc".to_string(), 2, |pid| (pid != 1).then_some(100)));
+        assert!(!locks.acquire("abc".to_string(), 1, |_| Some(100)));
+    }
+
+    #[test]
+    fn test_task_lock_owner_pid_reused() {
+        let locks = TaskLocks::default();
+
+        assert!(locks.acquire("abc".to_string(), 1, |_| Some(100)));
+        // The owner exited and another process started with its pid
+        assert!(locks.acquire("abc".to_string(), 2, |pid| Some(if pid == 1 {
+            200
+        } else {
+            100
+        })));
+    }
+}
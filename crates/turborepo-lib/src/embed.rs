@@ -0,0 +1,247 @@
+//! A library API for build tools that embed turbo instead of running the CLI.
+//!
+//! It covers the steps that `turbo run` starts with: building the package
+//! graph, resolving the task graph and hashing its tasks. The types only hold
+//! plain data and are `#[non_exhaustive]`, so fields can be added to them
+//! without breaking the tools that read them.
+
+use clap::Parser;
+use itertools::Itertools;
+use miette::Diagnostic;
+use serde::Serialize;
+use thiserror::Error;
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath};
+use turborepo_repository::{
+    package_graph::{self, PackageGraph, PackageNode},
+    package_json::{self, PackageJson},
+};
+use turborepo_telemetry::events::command::CommandEventBuilder;
+use turborepo_ui::UI;
+
+use crate::{
+    cli::Args, commands::CommandBase, config, get_version, run, run::builder::RunBuilder,
+    signal::SignalHandler, turbo_json::TurboJson,
+};
+
+#[derive(Debug, Error, Diagnostic)]
+pub enum Error {
+    #[error("unable to load package.json: {0}")]
+    PackageJson(#[from] package_json::Error),
+    #[error(transparent)]
+    PackageGraph(#[from] package_graph::Error),
+    #[error(transparent)]
+    Config(#[from] config::Error),
+    #[error("invalid tasks or filters: {0}")]
+    Arguments(#[from] clap::Error),
+    #[error(transparent)]
+    #[diagnostic(transparent)]
+    Run(#[from] run::Error),
+}
+
+/// A package of the repository. The root package is named `//`.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+#[non_exhaustive]
+pub struct Package {
+    pub name: String,
+    /// The directory of the package relative to the repository root, with `/`
+    /// as the separator
+    pub path: String,
+    /// The packages of the repository that the package depends on directly
+    pub dependencies: Vec<String>,
+}
+
+/// A task of the task graph
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "camelCase")]
+#[non_exhaustive]
+pub struct Task {
+    /// The id of the task, `<package>#<task>`
+    pub id: String,
+    pub package: String,
+    pub task: String,
+    /// The hash that the task's outputs are cached under
+    pub hash: String,
+    /// The ids of the tasks that have to finish before the task runs
+    pub dependencies: Vec<String>,
+}
+
+/// The tasks and filters to resolve the task graph for, as they would be
+/// passed to `turbo run`
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+#[non_exhaustive]
+pub struct TaskGraphOptions {
+    pub tasks: Vec<String>,
+    pub filters: Vec<String>,
+}
+
+impl TaskGraphOptions {
+    pub fn new(tasks: Vec<String>) -> Self {
+        Self {
+            tasks,
+            filters: Vec::new(),
+        }
+    }
+
+    pub fn with_filters(mut self, filters: Vec<String>) -> Self {
+        self.filters = filters;
+        self
+    }
+
+    fn args(&self) -> Result<Args, clap::Error> {
+        let mut args = vec!["turbo".to_string(), "run".to_string()];
+        args.extend(self.tasks.iter().cloned());
+        args.extend(
+            self.filters
+                .iter()
+                .map(|filter| format!("--filter={filter}")),
+        );
+        Args::try_parse_from(args)
+    }
+}
+
+/// Builds the package graph of the repository at `repo_root`. Packages are
+/// sorted by name.
+pub async fn package_graph(repo_root: &AbsoluteSystemPath) -> Result<Vec<Package>, Error> {
+    let root_package_json = PackageJson::load(&repo_root.join_component("package.json"))?;
+    let root_turbo_json = TurboJson::load(
+        repo_root,
+        AnchoredSystemPath::empty(),
+        &root_package_json,
+        false,
+    )?;
+    let pkg_dep_graph = PackageGraph::builder(repo_root, root_package_json)
+        .with_workspace_providers(root_turbo_json.workspace_providers)
+        .build()
+        .await?;
+    pkg_dep_graph.validate()?;
+
+    Ok(pkg_dep_graph
+        .packages()
+        .map(|(name, info)| Package {
+            name: name.to_string(),
+            path: info.package_path().to_unix().to_string(),
+            dependencies: pkg_dep_graph
+                .immediate_dependencies(&PackageNode::Workspace(name.clone()))
+                .into_iter()
+                .flatten()
+                .filter_map(|node| match node {
+                    PackageNode::Workspace(dependency) => Some(dependency.to_string()),
+                    PackageNode::Root => None,
+                })
+                .sorted()
+                .collect(),
+        })
+        .sorted_by(|a, b| a.name.cmp(&b.name))
+        .collect())
+}
+
+/// Resolves the task graph that `turbo run` would execute in `repo_root` and
+/// hashes its tasks without running them. Tasks are sorted by id.
+///
+/// The run is configured like `turbo run`, from turbo.json, the environment
+/// and the user's config, so the hashes match the ones of the CLI.
+pub async fn task_graph(
+    repo_root: &AbsoluteSystemPath,
+    options: &TaskGraphOptions,
+) -> Result<Vec<Task>, Error> {
+    let base = CommandBase::new(
+        options.args()?,
+        repo_root.to_owned(),
+        get_version(),
+        UI::new(true),
+    );
+    // Nothing is run, so there aren't any processes to stop on a signal
+    let handler = SignalHandler::new(std::future::pending::<Option<()>>());
+    let result = async {
+        let mut run = RunBuilder::new(base)?
+            .hide_prelude()
+            .build(&handler, CommandEventBuilder::new("embed"))
+            .await?;
+        let task_hashes = run.task_hashes().await?;
+        Ok::<_, run::Error>(
+            task_hashes
+                .into_iter()
+                .map(|(task_id, hash)| Task {
+                    id: task_id.to_string(),
+                    package: task_id.package().to_string(),
+                    task: task_id.task().to_string(),
+                    hash,
+                    dependencies: run
+                        .task_dependencies(&task_id)
+                        .iter()
+                        .map(|dependency| dependency.to_string())
+                        .collect(),
+                })
+                .collect(),
+        )
+    }
+    .await;
+    handler.close().await;
+    Ok(result?)
+}
+
+#[cfg(test)]
+mod test {
+    use turbopath::{AbsoluteSystemPathBuf, RelativeUnixPath};
+
+    use super::{package_graph, Package, TaskGraphOptions};
+
+    fn package(name: &str, path: &str, dependencies: &[&str]) -> Package {
+        Package {
+            name: name.to_string(),
+            path: path.to_string(),
+            dependencies: dependencies.iter().map(|d| d.to_string()).collect(),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_package_graph() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        for (path, contents) in [
+            (
+                "package.json",
+                r#"{"name": "root", "packageManager": "npm@10.5.0", "workspaces": ["packages/*"]}"#,
+            ),
+            (
+                "package-lock.json",
+                r#"{"name": "root", "lockfileVersion": 3, "requires": true, "packages": {}}"#,
+            ),
+            ("turbo.json", r#"{"tasks": {"build": {}}}"#),
+            ("packages/a/package.json", r#"{"name": "a"}"#),
+            (
+                "packages/b/package.json",
+                r#"{"name": "b", "dependencies": {"a": "*"}}"#,
+            ),
+        ] {
+            let file = repo_root.join_unix_path(RelativeUnixPath::new(path).unwrap());
+            file.ensure_dir().unwrap();
+            file.create_with_contents(contents).unwrap();
+        }
+
+        assert_eq!(
+            package_graph(&repo_root).await.unwrap(),
+            vec![
+                package("//", "", &[]),
+                package("a", "packages/a", &[]),
+                package("b", "packages/b", &["a"]),
+            ]
+        );
+    }
+
+    #[test]
+    fn test_task_graph_args() {
+        let options = TaskGraphOptions::new(vec!["build".to_string(), "lint".to_string()])
+            .with_filters(vec!["web...".to_string()]);
+        let args = options.args().unwrap();
+        let Some(crate::cli::Command::Run { execution_args, .. }) = args.command else {
+            panic!("expected a run command, got {:?}", args.command);
+        };
+        assert_eq!(execution_args.tasks, vec!["build", "lint"]);
+        assert_eq!(execution_args.filter, vec!["web..."]);
+    }
+}
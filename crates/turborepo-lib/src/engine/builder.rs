@@ -3,17 +3,22 @@ use std::collections::{HashMap, HashSet, VecDeque};
 use convert_case::{Case, Casing};
 use itertools::Itertools;
 use miette::{Diagnostic, NamedSource, SourceSpan};
+use petgraph::{graph::NodeIndex, Direction, Graph};
 use turbopath::AbsoluteSystemPath;
 use turborepo_errors::{Spanned, TURBO_SITE};
 use turborepo_graph_utils as graph;
 use turborepo_repository::package_graph::{PackageGraph, PackageName, PackageNode, ROOT_PKG_NAME};
 
-use super::Engine;
+use super::{Engine, TaskNode};
 use crate::{
+    cli::DependencyMode,
     config,
     run::task_id::{TaskId, TaskName},
     task_graph::TaskDefinition,
-    turbo_json::{validate_extends, validate_no_package_task_syntax, RawTaskDefinition, TurboJson},
+    turbo_json::{
+        validate_extends, validate_no_override, validate_no_package_task_syntax, RawTaskDefinition,
+        TurboJson,
+    },
 };
 
 #[derive(Debug, thiserror::Error, Diagnostic)]
@@ -68,6 +73,20 @@ pub enum Error {
         task_id: String,
         task_name: String,
     },
+    #[error(
+        "`override` is set for \"{task_name}\", but it has no inherited definition to replace"
+    )]
+    #[diagnostic(
+        code(override_without_inherited_task),
+        help("remove `override`, tasks that are only defined in a package don't need it")
+    )]
+    OverrideWithoutInheritedTask {
+        #[label]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+        task_name: String,
+    },
     #[error(transparent)]
     #[diagnostic(transparent)]
     Config(#[from] crate::config::Error),
@@ -78,6 +97,35 @@ pub enum Error {
     },
     #[error(transparent)]
     Graph(#[from] graph::Error),
+    #[error("cyclic task dependency detected: {cycle}")]
+    #[diagnostic(help(
+        "remove the dependency of {from} on {to}, or another dependency in the cycle"
+    ))]
+    CyclicTaskDependency {
+        cycle: String,
+        from: String,
+        to: String,
+        #[label("this dependency creates a cycle")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+    },
+    #[error("`shell` has no effect on {task_id}")]
+    #[diagnostic(
+        code(shell_without_runner),
+        help(
+            "`shell` only applies to tasks with a `runner` command and to packages found by a \
+             workspace provider. Scripts run by the package manager use its shell, and runner \
+             arrays are run without one."
+        )
+    )]
+    ShellWithoutRunner {
+        #[label("`shell` is set here")]
+        span: Option<SourceSpan>,
+        #[source_code]
+        text: NamedSource,
+        task_id: String,
+    },
     #[error("invalid task name: {reason}")]
     InvalidTaskName {
         #[label]
@@ -89,6 +137,32 @@ pub enum Error {
     },
 }
 
+impl Error {
+    /// Identifies the error in `--error-format=json` output. These aren't
+    /// diagnostic codes, since those are also printed in the human readable
+    /// report.
+    pub fn json_code(&self) -> Option<&'static str> {
+        match self {
+            Error::MissingTasks(_) => Some("missing_tasks"),
+            Error::MissingPackageFromTask { .. } => Some("missing_package"),
+            Error::MissingPackageTask { .. } => Some("missing_task"),
+            Error::CyclicTaskDependency { .. } => Some("cyclic_task_dependency"),
+            _ => None,
+        }
+    }
+
+    /// The errors that make up this one, along with their codes
+    pub fn json_related(&self) -> Vec<(&dyn Diagnostic, Option<&'static str>)> {
+        match self {
+            Error::MissingTasks(errors) => errors
+                .iter()
+                .map(|error| (error as &dyn Diagnostic, Some("missing_task")))
+                .collect(),
+            _ => Vec::new(),
+        }
+    }
+}
+
 pub struct EngineBuilder<'a> {
     repo_root: &'a AbsoluteSystemPath,
     package_graph: &'a PackageGraph,
@@ -98,6 +172,7 @@ pub struct EngineBuilder<'a> {
     tasks: Vec<Spanned<TaskName<'static>>>,
     root_enabled_tasks: HashSet<TaskName<'static>>,
     tasks_only: bool,
+    dependency_mode: DependencyMode,
 }
 
 impl<'a> EngineBuilder<'a> {
@@ -115,6 +190,7 @@ impl<'a> EngineBuilder<'a> {
             tasks: Vec::new(),
             root_enabled_tasks: HashSet::new(),
             tasks_only: false,
+            dependency_mode: DependencyMode::All,
         }
     }
 
@@ -131,6 +207,11 @@ impl<'a> EngineBuilder<'a> {
         self
     }
 
+    pub fn with_dependency_mode(mut self, dependency_mode: DependencyMode) -> Self {
+        self.dependency_mode = dependency_mode;
+        self
+    }
+
     pub fn with_root_tasks<I: IntoIterator<Item = TaskName<'static>>>(mut self, tasks: I) -> Self {
         self.root_enabled_tasks = tasks
             .into_iter()
@@ -228,9 +309,16 @@ impl<'a> EngineBuilder<'a> {
         }
 
         let allowed_tasks = self.allowed_tasks();
+        let entry_points = traversal_queue
+            .iter()
+            .map(|task_id| task_id.as_inner().clone())
+            .collect::<HashSet<_>>();
 
         let mut visited = HashSet::new();
         let mut engine = Engine::default();
+        // Where each edge of the task graph was declared, used to point at the
+        // offending `dependsOn` entry if the graph contains a cycle
+        let mut dependency_spans = HashMap::new();
 
         while let Some(task_id) = traversal_queue.pop_front() {
             {
@@ -276,8 +364,13 @@ impl<'a> EngineBuilder<'a> {
                 &task_id,
                 &task_id.as_non_workspace_task_name(),
             )?);
+            let shell_span = raw_task_definition
+                .shell
+                .as_ref()
+                .map(|shell| shell.span_and_text("turbo.json"));
 
             let task_definition = TaskDefinition::try_from(raw_task_definition)?;
+            self.validate_shell(&task_id, &task_definition, shell_span)?;
 
             // Skip this iteration of the loop if we've already seen this taskID
             if visited.contains(task_id.as_inner()) {
@@ -289,16 +382,27 @@ impl<'a> EngineBuilder<'a> {
             // Note that the Go code has a whole if/else statement for putting stuff into
             // deps or calling e.AddDep the bool is cannot be true so we skip to
             // just doing deps
-            let deps = task_definition
+            let mut deps = task_definition
                 .task_dependencies
                 .iter()
                 .map(|spanned| spanned.as_ref().split())
                 .collect::<HashMap<_, _>>();
-            let topo_deps = task_definition
+            let mut topo_deps = task_definition
                 .topological_dependencies
                 .iter()
                 .map(|spanned| spanned.as_ref().split())
                 .collect::<HashMap<_, _>>();
+            match self.dependency_mode {
+                DependencyMode::All => (),
+                DependencyMode::IgnoreTopology => topo_deps.clear(),
+                // Tasks that were only added as a dependency don't pull in their own
+                // dependencies
+                DependencyMode::OnlyDirect if !entry_points.contains(task_id.as_inner()) => {
+                    deps.clear();
+                    topo_deps.clear();
+                }
+                DependencyMode::OnlyDirect => (),
+            }
 
             // Don't ask why, but for some reason we refer to the source as "to"
             // and the target node as "from"
@@ -329,6 +433,7 @@ impl<'a> EngineBuilder<'a> {
                         engine
                             .task_graph
                             .add_edge(to_task_index, from_task_index, ());
+                        dependency_spans.insert((to_task_index, from_task_index), span.clone());
                         let from_task_id = span.to(from_task_id);
                         traversal_queue.push_back(from_task_id);
                     }
@@ -349,6 +454,7 @@ impl<'a> EngineBuilder<'a> {
                 engine
                     .task_graph
                     .add_edge(to_task_index, from_task_index, ());
+                dependency_spans.insert((to_task_index, from_task_index), span.clone());
                 let from_task_id = span.to(from_task_id);
                 traversal_queue.push_back(from_task_id);
             }
@@ -359,6 +465,27 @@ impl<'a> EngineBuilder<'a> {
             }
         }
 
+        if let Some(cycle) = find_cycle(&engine.task_graph) {
+            let last = *cycle.last().expect("cycles contain at least two tasks");
+            let first = cycle[0];
+            let (span, text) = dependency_spans
+                .remove(&(last, first))
+                .unwrap_or_default()
+                .span_and_text("turbo.json");
+            let display = |index| engine.task_graph[index].to_string();
+            return Err(Error::CyclicTaskDependency {
+                cycle: cycle
+                    .iter()
+                    .chain(std::iter::once(&first))
+                    .map(|index| display(*index))
+                    .join(" -> "),
+                from: display(last),
+                to: display(first),
+                span,
+                text,
+            });
+        }
+
         graph::validate_graph(&engine.task_graph)?;
 
         Ok(engine.seal())
@@ -391,6 +518,7 @@ impl<'a> EngineBuilder<'a> {
         let task_id_as_name = task_id.as_task_name();
         if turbo_json.tasks.contains_key(&task_id_as_name)
             || turbo_json.tasks.contains_key(task_name)
+            || turbo_json.extended_tasks(task_name).next().is_some()
         {
             Ok(true)
         } else if !matches!(workspace, PackageName::Root) {
@@ -412,9 +540,19 @@ impl<'a> EngineBuilder<'a> {
             .turbo_json(turbo_jsons, &PackageName::Root)?
             .ok_or(Error::Config(crate::config::Error::NoTurboJSON))?;
 
+        let validation_errors = root_turbo_json.validate(&[validate_no_override]);
+        if !validation_errors.is_empty() {
+            return Err(Error::Validation {
+                errors: validation_errors,
+            });
+        }
+
         if let Some(root_definition) = root_turbo_json.task(task_id, task_name) {
             task_definitions.push(root_definition)
         }
+        // The selected profile only changes options of tasks that are defined
+        // elsewhere, so it's added last
+        let profile_definition = root_turbo_json.profile_task(task_id, task_name);
 
         if self.is_single {
             return match task_definitions.is_empty() {
@@ -426,7 +564,10 @@ impl<'a> EngineBuilder<'a> {
                         task_id: task_id.to_string(),
                     })
                 }
-                false => Ok(task_definitions),
+                false => {
+                    task_definitions.extend(profile_definition);
+                    Ok(task_definitions)
+                }
             };
         }
 
@@ -441,7 +582,27 @@ impl<'a> EngineBuilder<'a> {
                         });
                     }
 
+                    // Shareable configs are merged between the root and the workspace's own
+                    // definitions in the order they're listed in `extends`
+                    task_definitions.extend(
+                        workspace_json
+                            .extended_tasks(task_name)
+                            .map(|extended_def| extended_def.value.clone()),
+                    );
+
                     if let Some(workspace_def) = workspace_json.tasks.get(task_name) {
+                        // An overriding definition replaces everything it would inherit
+                        if let Some(r#override) = workspace_def.value.override_key() {
+                            if task_definitions.is_empty() {
+                                let (span, text) = r#override.span_and_text("turbo.json");
+                                return Err(Error::OverrideWithoutInheritedTask {
+                                    span,
+                                    text,
+                                    task_name: task_name.to_string(),
+                                });
+                            }
+                            task_definitions.clear();
+                        }
                         task_definitions.push(workspace_def.value.clone());
                     }
                 }
@@ -464,6 +625,7 @@ impl<'a> EngineBuilder<'a> {
             });
         }
 
+        task_definitions.extend(profile_definition);
         Ok(task_definitions)
     }
 
@@ -491,12 +653,46 @@ impl<'a> EngineBuilder<'a> {
                 .ok_or_else(|| Error::MissingPackageJson {
                     workspace: workspace.clone(),
                 })?;
-        Ok(TurboJson::load(
-            self.repo_root,
-            workspace_dir,
-            package_json,
-            self.is_single,
-        )?)
+        let mut turbo_json =
+            TurboJson::load(self.repo_root, workspace_dir, package_json, self.is_single)?;
+        if !matches!(workspace, PackageName::Root) {
+            turbo_json.load_extended(self.repo_root, workspace_dir)?;
+        }
+        Ok(turbo_json)
+    }
+
+    // `shell` is part of the task's hash, so setting it where it doesn't change
+    // how the task runs would only miss the cache
+    fn validate_shell(
+        &self,
+        task_id: &Spanned<TaskId<'static>>,
+        task_definition: &TaskDefinition,
+        shell_span: Option<(Option<SourceSpan>, NamedSource)>,
+    ) -> Result<(), Error> {
+        if task_definition.shell.is_default() {
+            return Ok(());
+        }
+        let Some(package) = self
+            .package_graph
+            .package_info(&PackageName::from(task_id.package()))
+        else {
+            return Ok(());
+        };
+        // Packages without the script don't run the task
+        let has_script = package
+            .package_json
+            .scripts
+            .get(task_id.task())
+            .is_some_and(|script| !script.is_empty());
+        if !has_script || task_definition.uses_shell(package) {
+            return Ok(());
+        }
+        let (span, text) = shell_span.unwrap_or_else(|| task_id.span_and_text("turbo.json"));
+        Err(Error::ShellWithoutRunner {
+            span,
+            text,
+            task_id: task_id.to_string(),
+        })
     }
 }
 
@@ -510,6 +706,47 @@ impl Error {
 // we can expand the patterns here.
 const INVALID_TOKENS: &[&str] = &["$colon$"];
 
+/// Finds a cycle in the task graph if there is one. The tasks are returned in
+/// dependency order, each task depending on the next and the last task
+/// depending on the first. Self dependencies are left to `validate_graph`.
+pub(crate) fn find_cycle(task_graph: &Graph<TaskNode, ()>) -> Option<Vec<NodeIndex>> {
+    let display = |index: &NodeIndex| task_graph[*index].to_string();
+    let component = petgraph::algo::tarjan_scc(task_graph)
+        .into_iter()
+        .filter(|component| component.len() > 1)
+        .min_by_key(|component| component.iter().map(display).min())?;
+    let members = component.iter().copied().collect::<HashSet<_>>();
+    // Start from the first task alphabetically so the reported cycle is stable
+    let start = component.into_iter().min_by_key(display)?;
+
+    // Breadth first search for the shortest path back to the start
+    let mut parents = HashMap::new();
+    let mut queue = VecDeque::from([start]);
+    while let Some(node) = queue.pop_front() {
+        let mut neighbors = task_graph
+            .neighbors_directed(node, Direction::Outgoing)
+            .filter(|neighbor| members.contains(neighbor))
+            .collect::<Vec<_>>();
+        neighbors.sort_by_key(display);
+        for neighbor in neighbors {
+            if neighbor == start && node != start {
+                let mut cycle = vec![node];
+                while let Some(parent) = parents.get(cycle.last().expect("cycle is not empty")) {
+                    cycle.push(*parent);
+                }
+                cycle.reverse();
+                return Some(cycle);
+            }
+            if neighbor != node && !parents.contains_key(&neighbor) {
+                parents.insert(neighbor, node);
+                queue.push_back(neighbor);
+            }
+        }
+    }
+
+    None
+}
+
 fn validate_task_name(task: Spanned<&str>) -> Result<(), Error> {
     INVALID_TOKENS
         .iter()
@@ -678,6 +915,197 @@ mod test {
         assert_eq!(turbo_json.tasks.len(), 1);
     }
 
+    #[test]
+    fn test_turbo_json_extends_package() {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "a" => []
+            },
+        );
+        let engine_builder = EngineBuilder::new(&repo_root, &package_graph, false);
+
+        let a_turbo_json = repo_root.join_components(&["packages", "a", "turbo.json"]);
+        a_turbo_json.ensure_dir().unwrap();
+        a_turbo_json
+            .create_with_contents(
+                r#"{"extends": ["//", "@acme/turbo-config"], "tasks": {"build": {"inputs": ["a"]}}}"#,
+            )
+            .unwrap();
+
+        let shared_turbo_json =
+            repo_root.join_components(&["node_modules", "@acme", "turbo-config", "turbo.json"]);
+        shared_turbo_json.ensure_dir().unwrap();
+        shared_turbo_json
+            .create_with_contents(
+                r#"{"tasks": {"build": {"inputs": ["shared"], "outputs": ["dist/**"]}, "lint": {}}}"#,
+            )
+            .unwrap();
+
+        let mut turbo_jsons = vec![(
+            PackageName::Root,
+            turbo_json(json!({
+                "tasks": {
+                    "build": { "inputs": ["root"], "env": ["ROOT_VAR"] },
+                }
+            })),
+        )]
+        .into_iter()
+        .collect();
+
+        let task_id = Spanned::new(TaskId::try_from("a#build").unwrap());
+        let chain = engine_builder
+            .task_definition_chain(&mut turbo_jsons, &task_id, &TaskName::from("build"))
+            .unwrap();
+        assert_eq!(chain.len(), 3, "root, shared, and workspace definitions");
+        let task_definition =
+            TaskDefinition::try_from(RawTaskDefinition::from_iter(chain)).unwrap();
+        assert_eq!(task_definition.env, vec!["ROOT_VAR".to_string()]);
+        assert_eq!(
+            task_definition.outputs.inclusions,
+            vec!["dist/**".to_string()]
+        );
+        assert_eq!(
+            task_definition.inputs,
+            vec!["a".to_string()],
+            "workspace definition takes precedence over shared config"
+        );
+
+        let has_lint = engine_builder
+            .has_task_definition(
+                &mut turbo_jsons,
+                &PackageName::from("a"),
+                &TaskName::from("lint"),
+                &TaskId::try_from("a#lint").unwrap(),
+            )
+            .unwrap();
+        assert!(has_lint, "tasks only defined in a shared config are found");
+    }
+
+    #[test]
+    fn test_turbo_json_override() {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "a" => []
+            },
+        );
+        let engine_builder = EngineBuilder::new(&repo_root, &package_graph, false);
+        let mut turbo_jsons = vec![
+            (
+                PackageName::Root,
+                turbo_json(json!({
+                    "tasks": {
+                        "build": { "inputs": ["root"], "env": ["ROOT_VAR"] },
+                    }
+                })),
+            ),
+            (
+                PackageName::from("a"),
+                turbo_json(json!({
+                    "extends": ["//"],
+                    "tasks": {
+                        "build": { "override": true, "inputs": ["a"] },
+                        "codegen": { "outputs": ["generated/**"] },
+                        "lint": { "override": true },
+                    }
+                })),
+            ),
+        ]
+        .into_iter()
+        .collect();
+
+        let task_id = Spanned::new(TaskId::try_from("a#build").unwrap());
+        let chain = engine_builder
+            .task_definition_chain(&mut turbo_jsons, &task_id, &TaskName::from("build"))
+            .unwrap();
+        assert_eq!(chain.len(), 1, "root definition is replaced");
+        let task_definition =
+            TaskDefinition::try_from(RawTaskDefinition::from_iter(chain)).unwrap();
+        assert!(task_definition.env.is_empty());
+        assert_eq!(task_definition.inputs, vec!["a".to_string()]);
+
+        let task_id = Spanned::new(TaskId::try_from("a#codegen").unwrap());
+        let chain = engine_builder
+            .task_definition_chain(&mut turbo_jsons, &task_id, &TaskName::from("codegen"))
+            .unwrap();
+        assert_eq!(chain.len(), 1, "tasks can be added by packages");
+
+        let task_id = Spanned::new(TaskId::try_from("a#lint").unwrap());
+        let result = engine_builder.task_definition_chain(
+            &mut turbo_jsons,
+            &task_id,
+            &TaskName::from("lint"),
+        );
+        assert_matches!(result, Err(Error::OverrideWithoutInheritedTask { .. }));
+    }
+
+    #[test]
+    fn test_turbo_json_override_in_root() {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "a" => []
+            },
+        );
+        let engine_builder = EngineBuilder::new(&repo_root, &package_graph, false);
+        let mut turbo_jsons = vec![(
+            PackageName::Root,
+            turbo_json(json!({
+                "tasks": {
+                    "build": { "override": true },
+                }
+            })),
+        )]
+        .into_iter()
+        .collect();
+
+        let task_id = Spanned::new(TaskId::try_from("a#build").unwrap());
+        let result = engine_builder.task_definition_chain(
+            &mut turbo_jsons,
+            &task_id,
+            &TaskName::from("build"),
+        );
+        assert_matches!(result, Err(Error::Validation { .. }));
+    }
+
+    #[test]
+    fn test_turbo_json_extends_missing_package() {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "a" => []
+            },
+        );
+        let engine_builder = EngineBuilder::new(&repo_root, &package_graph, false);
+
+        let a_turbo_json = repo_root.join_components(&["packages", "a", "turbo.json"]);
+        a_turbo_json.ensure_dir().unwrap();
+        a_turbo_json
+            .create_with_contents(r#"{"extends": ["//", "@acme/turbo-config"], "tasks": {}}"#)
+            .unwrap();
+
+        let result = engine_builder.load_turbo_json(&PackageName::from("a"));
+        assert_matches!(
+            result,
+            Err(Error::Config(
+                crate::config::Error::ExtendedConfigNotFound { .. }
+            ))
+        );
+    }
+
     fn turbo_json(value: serde_json::Value) -> TurboJson {
         let json_text = serde_json::to_string(&value).unwrap();
         let raw = RawTurboJson::parse(&json_text, AnchoredSystemPath::new("").unwrap()).unwrap();
@@ -1084,6 +1512,51 @@ mod test {
         assert_eq!(all_dependencies(&engine), expected);
     }
 
+    #[test]
+    fn test_cyclic_package_task_dependency() {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "app1" => ["libA"],
+                "libA" => []
+            },
+        );
+        let turbo_jsons = vec![(
+            PackageName::Root,
+            turbo_json(json!({
+                "tasks": {
+                    "build": { "dependsOn": ["^build"] },
+                    "libA#build": { "dependsOn": ["app1#codegen"] },
+                    "app1#codegen": { "dependsOn": ["app1#build"] },
+                }
+            })),
+        )]
+        .into_iter()
+        .collect();
+        let engine = EngineBuilder::new(&repo_root, &package_graph, false)
+            .with_turbo_jsons(Some(turbo_jsons))
+            .with_tasks(Some(Spanned::new(TaskName::from("build"))))
+            .with_workspaces(vec![PackageName::from("app1")])
+            .with_root_tasks(vec![TaskName::from("build")])
+            .build();
+
+        let Err(Error::CyclicTaskDependency {
+            cycle, from, to, ..
+        }) = engine
+        else {
+            panic!("expected a cyclic dependency error");
+        };
+        assert_eq!(
+            cycle,
+            "app1#build -> libA#build -> app1#codegen -> app1#build"
+        );
+        assert_eq!(from, "app1#codegen");
+        assert_eq!(to, "app1#build");
+    }
+
     #[test]
     fn test_depends_on_disabled_root_task() {
         let repo_root_dir = TempDir::new("repo").unwrap();
@@ -1172,6 +1645,101 @@ mod test {
         assert_eq!(all_dependencies(&engine), expected);
     }
 
+    #[test_case(json!({ "shell": false }), false ; "package manager")]
+    #[test_case(json!({ "shell": true }), true ; "default shell")]
+    #[test_case(json!({ "shell": false, "runner": "{script}" }), true ; "runner command")]
+    #[test_case(json!({ "shell": false, "runner": ["node", "{script}"] }), false ; "runner arguments")]
+    fn test_shell_without_runner(task: serde_json::Value, valid: bool) {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_json = PackageJson {
+            name: Some("a".to_string()),
+            scripts: [("build".to_string(), "tsc".to_string())]
+                .into_iter()
+                .collect(),
+            ..Default::default()
+        };
+        let package_graph = mock_package_graph(
+            &repo_root,
+            [(
+                repo_root.join_components(&["packages", "a", "package.json"]),
+                package_json,
+            )]
+            .into_iter()
+            .collect(),
+        );
+        let turbo_jsons = vec![(
+            PackageName::Root,
+            turbo_json(json!({ "tasks": { "build": task } })),
+        )]
+        .into_iter()
+        .collect();
+        let engine = EngineBuilder::new(&repo_root, &package_graph, false)
+            .with_turbo_jsons(Some(turbo_jsons))
+            .with_tasks(Some(Spanned::new(TaskName::from("build"))))
+            .with_workspaces(vec![PackageName::from("a")])
+            .build();
+
+        if valid {
+            assert!(engine.is_ok());
+        } else {
+            assert_matches!(engine, Err(Error::ShellWithoutRunner { .. }));
+        }
+    }
+
+    #[test_case(DependencyMode::IgnoreTopology, deps! {
+        "c#test" => ["c#prepare"],
+        "c#prepare" => ["___ROOT___"]
+    } ; "ignore topology")]
+    #[test_case(DependencyMode::OnlyDirect, deps! {
+        "c#test" => ["a#build", "b#build", "c#prepare"],
+        "a#build" => ["___ROOT___"],
+        "b#build" => ["___ROOT___"],
+        "c#prepare" => ["___ROOT___"]
+    } ; "only direct")]
+    fn test_engine_dependency_mode(
+        dependency_mode: DependencyMode,
+        expected: HashMap<TaskId<'static>, HashSet<TaskNode>>,
+    ) {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "a" => [],
+                "b" => [],
+                "c" => ["a", "b"]
+            },
+        );
+        let turbo_jsons = vec![(
+            PackageName::Root,
+            turbo_json(json!({
+                "tasks": {
+                    "build": { "dependsOn": ["^build", "prepare"] },
+                    "test": { "dependsOn": ["^build", "prepare"] },
+                    "prepare": {},
+                }
+            })),
+        )]
+        .into_iter()
+        .collect();
+        let engine = EngineBuilder::new(&repo_root, &package_graph, false)
+            .with_turbo_jsons(Some(turbo_jsons))
+            .with_dependency_mode(dependency_mode)
+            .with_tasks(Some(Spanned::new(TaskName::from("test"))))
+            .with_workspaces(vec![PackageName::from("c")])
+            .with_root_tasks(vec![
+                TaskName::from("build"),
+                TaskName::from("test"),
+                TaskName::from("prepare"),
+            ])
+            .build()
+            .unwrap();
+
+        assert_eq!(all_dependencies(&engine), expected);
+    }
+
     #[test]
     fn test_engine_tasks_only_package_deps() {
         let repo_root_dir = TempDir::new("repo").unwrap();
@@ -1210,6 +1778,56 @@ mod test {
         assert_eq!(all_dependencies(&engine), expected);
     }
 
+    #[test]
+    fn test_engine_tasks_only_keeps_order_of_selected_tasks() {
+        let repo_root_dir = TempDir::new("repo").unwrap();
+        let repo_root = AbsoluteSystemPathBuf::new(repo_root_dir.path().to_str().unwrap()).unwrap();
+        let package_graph = mock_package_graph(
+            &repo_root,
+            package_jsons! {
+                repo_root,
+                "a" => [],
+                "b" => ["a"]
+            },
+        );
+        let turbo_jsons = vec![(
+            PackageName::Root,
+            turbo_json(json!({
+                "tasks": {
+                    "build": { "dependsOn": ["^build", "prepare"] },
+                    "test": { "dependsOn": ["build"] },
+                    "prepare": {},
+                }
+            })),
+        )]
+        .into_iter()
+        .collect();
+        let engine = EngineBuilder::new(&repo_root, &package_graph, false)
+            .with_turbo_jsons(Some(turbo_jsons))
+            .with_tasks_only(true)
+            .with_tasks(vec![
+                Spanned::new(TaskName::from("build")),
+                Spanned::new(TaskName::from("test")),
+            ])
+            .with_workspaces(vec![PackageName::from("a"), PackageName::from("b")])
+            .with_root_tasks(vec![
+                TaskName::from("build"),
+                TaskName::from("test"),
+                TaskName::from("prepare"),
+            ])
+            .build()
+            .unwrap();
+
+        // The selected tasks still wait on each other, but `prepare` isn't run
+        let expected = deps! {
+            "a#build" => ["___ROOT___"],
+            "b#build" => ["a#build"],
+            "a#test" => ["a#build"],
+            "b#test" => ["b#build"]
+        };
+        assert_eq!(all_dependencies(&engine), expected);
+    }
+
     #[test]
     fn test_engine_tasks_only_task_dep() {
         let repo_root_dir = TempDir::new("repo").unwrap();
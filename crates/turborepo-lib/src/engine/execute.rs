@@ -1,6 +1,10 @@
-use std::sync::{Arc, Mutex};
+use std::{
+    collections::{HashMap, HashSet},
+    sync::{Arc, Mutex},
+};
 
 use futures::{stream::FuturesUnordered, StreamExt};
+use petgraph::Direction;
 use tokio::sync::{mpsc, oneshot, Semaphore};
 use tracing::log::debug;
 use turborepo_graph_utils::Walker;
@@ -11,6 +15,9 @@ use crate::run::task_id::TaskId;
 pub struct Message<T, U> {
     pub info: T,
     pub callback: oneshot::Sender<U>,
+    /// Set for persistent tasks with a readiness probe. Sending on it lets
+    /// the task's dependents start while the task keeps running.
+    pub ready: Option<oneshot::Sender<()>>,
 }
 
 // Type alias used just to make altering the data sent to the visitor easier in
@@ -49,8 +56,13 @@ impl From<mpsc::error::SendError<Message<VisitorData, VisitorResult>>> for Execu
     }
 }
 
-#[derive(Debug, Clone, Copy)]
-pub struct StopExecution;
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum StopExecution {
+    /// Stop scheduling any further tasks
+    AllTasks,
+    /// Only stop scheduling tasks that depend on this task
+    DependentTasks,
+}
 
 impl Engine {
     /// Execute a task graph by sending task ids to the visitor
@@ -60,6 +72,8 @@ impl Engine {
     /// type which will stop any further execution of tasks.
     /// This will not stop any task which is currently running, simply it will
     /// stop scheduling new tasks.
+    /// Returns the tasks that were skipped because a task they depend on
+    /// stopped its dependents from running.
     // (olszewski) The current impl requires that the visitor receiver is read until
     // finish even once a task sends back the stop signal. This is suboptimal
     // since it would mean the visitor would need to also track if
@@ -68,22 +82,36 @@ impl Engine {
         self: Arc<Self>,
         options: ExecutionOptions,
         visitor: mpsc::Sender<Message<VisitorData, VisitorResult>>,
-    ) -> Result<(), ExecuteError> {
+    ) -> Result<Vec<TaskId<'static>>, ExecuteError> {
         let ExecutionOptions {
             parallel,
             concurrency,
         } = options;
         let sema = Arc::new(Semaphore::new(concurrency));
+        // Tasks that share a concurrency group are run one at a time
+        let group_semas: Arc<HashMap<_, _>> = Arc::new(
+            self.task_definitions
+                .values()
+                .filter_map(|definition| definition.concurrency_group.clone())
+                .map(|group| (group, Semaphore::new(1)))
+                .collect(),
+        );
         let mut tasks: FuturesUnordered<tokio::task::JoinHandle<Result<(), ExecuteError>>> =
             FuturesUnordered::new();
 
         let (walker, mut nodes) = Walker::new(&self.task_graph).walk();
         let walker = Arc::new(Mutex::new(walker));
+        // Tasks that failed or were skipped, their dependents won't be run
+        let stopped = Arc::new(Mutex::new(HashSet::new()));
+        let skipped = Arc::new(Mutex::new(Vec::new()));
 
         while let Some((node_id, done)) = nodes.recv().await {
             let visitor = visitor.clone();
             let sema = sema.clone();
+            let group_semas = group_semas.clone();
             let walker = walker.clone();
+            let stopped = stopped.clone();
+            let skipped = skipped.clone();
             let this = self.clone();
 
             tasks.push(tokio::spawn(async move {
@@ -102,34 +130,125 @@ impl Engine {
                     return Ok(());
                 };
 
+                let has_stopped_dependency = {
+                    let mut stopped = stopped.lock().expect("stopped tasks mutex poisoned");
+                    let has_stopped_dependency = this
+                        .task_graph
+                        .neighbors_directed(node_id, Direction::Outgoing)
+                        .any(|dependency| stopped.contains(&dependency));
+                    if has_stopped_dependency {
+                        // Mark this task as stopped so its dependents get skipped too
+                        stopped.insert(node_id);
+                    }
+                    has_stopped_dependency
+                };
+                if has_stopped_dependency {
+                    skipped
+                        .lock()
+                        .expect("skipped tasks mutex poisoned")
+                        .push(task_id.clone());
+                    if done.send(()).is_err() {
+                        debug!(
+                            "Graph walk done receiver closed before node was finished processing"
+                        );
+                    }
+                    return Ok(());
+                }
+
+                // Acquire the group permit before the concurrency permit so a task waiting on
+                // its group doesn't take up a concurrency slot
+                let _group_permit = match this
+                    .task_definitions
+                    .get(task_id)
+                    .and_then(|definition| definition.concurrency_group.as_ref())
+                {
+                    Some(group) => Some(
+                        group_semas
+                            .get(group)
+                            .expect("semaphore exists for every concurrency group")
+                            .acquire()
+                            .await?,
+                    ),
+                    None => None,
+                };
+
+                // A task takes up as many permits as its weight, capped at the concurrency
+                // so that it can always start
+                let weight = this
+                    .task_definitions
+                    .get(task_id)
+                    .filter(|definition| !definition.persistent)
+                    .map_or(1, |definition| definition.weight)
+                    .min(concurrency as u32);
+
                 // Acquire the semaphore unless parallel
                 let _permit = match parallel {
-                    false => Some(sema.acquire().await.expect(
+                    false => Some(sema.acquire_many(weight).await.expect(
                         "Graph concurrency semaphore closed while tasks are still attempting to \
                          acquire permits",
                     )),
                     true => None,
                 };
 
-                let (message, result) = Message::new(task_id.clone());
+                let (mut message, mut result) = Message::new(task_id.clone());
+                let ready = this
+                    .task_definitions
+                    .get(task_id)
+                    .and_then(|definition| definition.ready.as_ref())
+                    .map(|_| message.ready());
                 visitor.send(message).await?;
 
-                if let Err(StopExecution) = result.await.unwrap_or_else(|_| {
+                // A task with a readiness probe lets its dependents start once it's ready,
+                // but it keeps its concurrency permits until it exits
+                let mut done = Some(done);
+                let result = match ready {
+                    Some(ready) => tokio::select! {
+                        result = &mut result => result,
+                        Ok(()) = ready => {
+                            debug!("{task_id} is ready, starting its dependents");
+                            if let Some(done) = done.take() {
+                                if done.send(()).is_err() {
+                                    debug!(
+                                        "Graph walk done receiver closed before node was finished \
+                                         processing"
+                                    );
+                                }
+                            }
+                            result.await
+                        }
+                    },
+                    None => result.await,
+                };
+
+                match result.unwrap_or_else(|_| {
                     // If the visitor doesn't send a callback, then we assume the task finished
                     debug!("Engine visitor dropped callback sender without sending result");
                     Ok(())
                 }) {
-                    if walker
-                        .lock()
-                        .expect("Walker mutex poisoned")
-                        .cancel()
-                        .is_err()
-                    {
-                        debug!("Unable to cancel graph walk");
+                    Ok(()) => (),
+                    Err(StopExecution::AllTasks) => {
+                        if walker
+                            .lock()
+                            .expect("Walker mutex poisoned")
+                            .cancel()
+                            .is_err()
+                        {
+                            debug!("Unable to cancel graph walk");
+                        }
+                    }
+                    Err(StopExecution::DependentTasks) => {
+                        stopped
+                            .lock()
+                            .expect("stopped tasks mutex poisoned")
+                            .insert(node_id);
                     }
                 }
-                if done.send(()).is_err() {
-                    debug!("Graph walk done receiver closed before node was finished processing");
+                if let Some(done) = done {
+                    if done.send(()).is_err() {
+                        debug!(
+                            "Graph walk done receiver closed before node was finished processing"
+                        );
+                    }
                 }
                 Ok(())
             }));
@@ -139,13 +258,30 @@ impl Engine {
             res.expect("unable to join task")?;
         }
 
-        Ok(())
+        let mut skipped =
+            std::mem::take(&mut *skipped.lock().expect("skipped tasks mutex poisoned"));
+        skipped.sort();
+        Ok(skipped)
     }
 }
 
 impl<T, U> Message<T, U> {
     pub fn new(info: T) -> (Self, oneshot::Receiver<U>) {
         let (callback, receiver) = oneshot::channel();
-        (Self { info, callback }, receiver)
+        (
+            Self {
+                info,
+                callback,
+                ready: None,
+            },
+            receiver,
+        )
+    }
+
+    /// Asks the visitor to report when the task is ready
+    pub fn ready(&mut self) -> oneshot::Receiver<()> {
+        let (ready, receiver) = oneshot::channel();
+        self.ready = Some(ready);
+        receiver
     }
 }
@@ -0,0 +1,133 @@
+use std::{collections::HashSet, io};
+
+use serde::Serialize;
+
+use super::{Built, Engine, TaskNode};
+
+#[derive(Debug, Serialize, PartialEq)]
+#[serde(rename_all = "camelCase")]
+struct TaskGraphSummary {
+    tasks: Vec<TaskNodeSummary>,
+}
+
+#[derive(Debug, Serialize, PartialEq)]
+#[serde(rename_all = "camelCase")]
+struct TaskNodeSummary {
+    task_id: String,
+    task: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    package: Option<String>,
+    cache: bool,
+    persistent: bool,
+    dependencies: Vec<String>,
+    dependents: Vec<String>,
+}
+
+impl Engine<Built> {
+    pub fn json_graph<W: io::Write>(
+        &self,
+        mut writer: W,
+        is_single: bool,
+    ) -> Result<(), io::Error> {
+        serde_json::to_writer_pretty(&mut writer, &self.task_graph_summary(is_single))?;
+        writeln!(writer)
+    }
+
+    fn task_graph_summary(&self, is_single: bool) -> TaskGraphSummary {
+        let display_node = |node: &TaskNode| match node {
+            TaskNode::Root => None,
+            TaskNode::Task(task) if is_single => Some(task.task().to_string()),
+            TaskNode::Task(task) => Some(task.to_string()),
+        };
+        let collect_nodes = |nodes: Option<HashSet<&TaskNode>>| {
+            let mut nodes = nodes
+                .unwrap_or_default()
+                .into_iter()
+                .filter_map(display_node)
+                .collect::<Vec<_>>();
+            nodes.sort();
+            nodes
+        };
+
+        let mut tasks = self
+            .tasks()
+            .filter_map(|node| match node {
+                TaskNode::Root => None,
+                TaskNode::Task(task_id) => Some(task_id),
+            })
+            .map(|task_id| {
+                let definition = self.task_definition(task_id).cloned().unwrap_or_default();
+                TaskNodeSummary {
+                    task_id: display_node(&TaskNode::Task(task_id.clone()))
+                        .expect("task nodes are always displayed"),
+                    task: task_id.task().to_string(),
+                    package: (!is_single).then(|| task_id.package().to_string()),
+                    cache: definition.cache,
+                    persistent: definition.persistent,
+                    dependencies: collect_nodes(self.dependencies(task_id)),
+                    dependents: collect_nodes(self.dependents(task_id)),
+                }
+            })
+            .collect::<Vec<_>>();
+        tasks.sort_by(|a, b| a.task_id.cmp(&b.task_id));
+
+        TaskGraphSummary { tasks }
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use pretty_assertions::assert_eq;
+    use serde_json::json;
+
+    use crate::{engine::Engine, run::task_id::TaskId, task_graph::TaskDefinition};
+
+    #[test]
+    fn test_json_graph_output() {
+        let mut engine = Engine::new();
+        let a_build = TaskId::new("a", "build");
+        let b_build = TaskId::new("b", "build");
+        let a_build_idx = engine.get_index(&a_build);
+        let b_build_idx = engine.get_index(&b_build);
+        engine.add_definition(a_build.clone(), TaskDefinition::default());
+        engine.add_definition(
+            b_build.clone(),
+            TaskDefinition {
+                cache: false,
+                ..Default::default()
+            },
+        );
+        engine.task_graph.add_edge(b_build_idx, a_build_idx, ());
+        engine.connect_to_root(&a_build);
+        let engine = engine.seal();
+
+        let mut bytes = Vec::new();
+        engine.json_graph(&mut bytes, false).unwrap();
+        let actual: serde_json::Value = serde_json::from_slice(&bytes).unwrap();
+        assert_eq!(
+            actual,
+            json!({
+                "tasks": [
+                    {
+                        "taskId": "a#build",
+                        "task": "build",
+                        "package": "a",
+                        "cache": true,
+                        "persistent": false,
+                        "dependencies": [],
+                        "dependents": ["b#build"],
+                    },
+                    {
+                        "taskId": "b#build",
+                        "task": "build",
+                        "package": "b",
+                        "cache": false,
+                        "persistent": false,
+                        "dependencies": ["a#build"],
+                        "dependents": [],
+                    },
+                ]
+            })
+        );
+    }
+}
@@ -2,6 +2,7 @@ mod builder;
 mod execute;
 
 mod dot;
+mod json;
 mod mermaid;
 
 use std::{
@@ -9,6 +10,7 @@ use std::{
     fmt,
 };
 
+pub(crate) use builder::find_cycle;
 pub use builder::{EngineBuilder, Error as BuilderError};
 pub use execute::{ExecuteError, ExecutionOptions, Message, StopExecution};
 use miette::{Diagnostic, NamedSource, SourceSpan};
@@ -425,7 +427,10 @@ impl Engine<Built> {
                         .ok_or_else(|| ValidateError::MissingPackageJson {
                             package: dep_id.package().to_string(),
                         })?;
+                    // A persistent task with a readiness probe unblocks its dependents once
+                    // it's ready, rather than when it exits
                     if task_definition.persistent
+                        && task_definition.ready.is_none()
                         && package_json.scripts.contains_key(dep_id.task())
                     {
                         let (span, text) = self
@@ -520,6 +525,9 @@ pub enum ValidateError {
     #[error("Cannot find package {package}")]
     MissingPackageJson { package: String },
     #[error("\"{persistent_task}\" is a persistent task, \"{dependant}\" cannot depend on it")]
+    #[diagnostic(help(
+        "set `ready` on the persistent task so tasks that depend on it start once it's ready"
+    ))]
     DependencyOnPersistentTask {
         #[label("persistent task")]
         span: Option<SourceSpan>,
@@ -543,6 +551,22 @@ pub enum ValidateError {
     InteractiveNeedsUI { task: String },
 }
 
+impl ValidateError {
+    /// Identifies the error in `--error-format=json` output
+    pub fn json_code(&self) -> Option<&'static str> {
+        match self {
+            ValidateError::MissingTask { .. } => Some("missing_task"),
+            ValidateError::DependencyOnPersistentTask { .. } => {
+                Some("dependency_on_persistent_task")
+            }
+            ValidateError::PersistentTasksExceedConcurrency { .. } => {
+                Some("persistent_tasks_exceed_concurrency")
+            }
+            _ => None,
+        }
+    }
+}
+
 impl fmt::Display for TaskNode {
     fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
         match self {
@@ -555,9 +579,10 @@ impl fmt::Display for TaskNode {
 #[cfg(test)]
 mod test {
 
-    use std::collections::BTreeMap;
+    use std::{collections::BTreeMap, sync::Arc, time::Duration};
 
     use tempdir::TempDir;
+    use tokio::sync::{mpsc, oneshot};
     use turbopath::AbsoluteSystemPath;
     use turborepo_repository::{
         discovery::{DiscoveryResponse, PackageDiscovery, WorkspaceData},
@@ -565,7 +590,7 @@ mod test {
     };
 
     use super::*;
-    use crate::run::task_id::TaskName;
+    use crate::{run::task_id::TaskName, task_graph::ReadyProbe};
 
     struct DummyDiscovery<'a>(&'a TempDir);
 
@@ -725,6 +750,109 @@ mod test {
         }
     }
 
+    #[tokio::test]
+    async fn test_dependency_on_ready_persistent_task() {
+        let tmp = tempdir::TempDir::new("ready_persistent_task").unwrap();
+        let graph = PackageGraph::builder(
+            AbsoluteSystemPath::from_std_path(tmp.path()).unwrap(),
+            PackageJson::default(),
+        )
+        .with_package_discovery(DummyDiscovery(&tmp))
+        .build()
+        .await
+        .unwrap();
+
+        let engine = |ready| {
+            let mut engine = Engine::new();
+            let a_dev_task_id = TaskId::new("a", "dev");
+            let b_build_task_id = TaskId::new("b", "build");
+
+            let a_dev_idx = engine.get_index(&a_dev_task_id);
+            engine.add_definition(
+                a_dev_task_id.clone(),
+                TaskDefinition {
+                    persistent: true,
+                    ready,
+                    ..Default::default()
+                },
+            );
+            let b_build_idx = engine.get_index(&b_build_task_id);
+            engine.add_definition(
+                b_build_task_id,
+                TaskDefinition {
+                    task_dependencies: vec![Spanned::new(TaskName::from(a_dev_task_id))],
+                    ..Default::default()
+                },
+            );
+            engine.task_graph.add_edge(b_build_idx, a_dev_idx, ());
+            engine.seal()
+        };
+
+        let errors = engine(None)
+            .validate(&graph, 10, false)
+            .expect_err("can't depend on a persistent task");
+        assert!(matches!(
+            errors.as_slice(),
+            [ValidateError::DependencyOnPersistentTask { .. }]
+        ));
+
+        engine(Some(ReadyProbe::Port(3000)))
+            .validate(&graph, 10, false)
+            .expect("can depend on a persistent task with a readiness probe");
+    }
+
+    #[tokio::test]
+    async fn test_weight_takes_concurrency_slots() {
+        let engine = |weight| {
+            let mut engine = Engine::new();
+            for (package, weight) in [("a", weight), ("b", 1)] {
+                let task_id = TaskId::new(package, "build");
+                engine.get_index(&task_id);
+                engine.connect_to_root(&task_id);
+                engine.add_definition(
+                    task_id,
+                    TaskDefinition {
+                        weight,
+                        ..Default::default()
+                    },
+                );
+            }
+            Arc::new(engine.seal())
+        };
+
+        // Runs the engine with a concurrency of 2, finishing a task only once no
+        // other task can start, and returns how many tasks ran at the same time
+        async fn max_running(engine: Arc<Engine>) -> usize {
+            let (sender, mut receiver) = mpsc::channel(10);
+            let execution = tokio::spawn(engine.execute(ExecutionOptions::new(false, 2), sender));
+            let mut running = Vec::new();
+            let mut max_running = 0;
+            loop {
+                match tokio::time::timeout(Duration::from_millis(100), receiver.recv()).await {
+                    Ok(Some(message)) => {
+                        running.push(message.callback);
+                        max_running = max_running.max(running.len());
+                    }
+                    Ok(None) => break,
+                    Err(_) => {
+                        let callback: oneshot::Sender<Result<(), StopExecution>> =
+                            running.pop().expect("a task should be running");
+                        callback.send(Ok(())).unwrap();
+                    }
+                }
+            }
+            execution.await.unwrap().unwrap();
+            max_running
+        }
+
+        assert_eq!(max_running(engine(1)).await, 2);
+        // The weighted task takes both slots, so the other task has to wait for it,
+        // or it has to wait for the other task to free up a slot
+        assert_eq!(max_running(engine(2)).await, 1);
+        // The weight is capped at the concurrency
+        assert_eq!(max_running(engine(3)).await, 1);
+    }
+
     #[tokio::test]
     async fn test_get_subgraph_for_package() {
         // Verifies that we can prune the `Engine` to include only the persistent tasks
@@ -6,7 +6,7 @@
 
 mod traits;
 
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
 
 use capnp::message::{Builder, HeapAllocator};
 pub use traits::TurboHash;
@@ -58,6 +58,21 @@ pub struct TaskHashable<'a> {
     pub(crate) resolved_env_vars: EnvVarPairs,
     pub(crate) pass_through_env: &'a [String],
     pub(crate) env_mode: EnvMode,
+
+    // tools, keyed by the command that printed their version
+    pub(crate) tool_versions: &'a BTreeMap<String, String>,
+
+    // variables from the task's dotenv files
+    pub(crate) dot_env: EnvVarPairs,
+
+    // the command that runs the task's script instead of the package manager
+    pub(crate) runner: Option<&'a str>,
+
+    // the shell the task's command is run with, if it isn't the default
+    pub(crate) shell: Option<String>,
+
+    // whether the outputs include the contents of symlinked directories
+    pub(crate) follow_symlinks: bool,
 }
 
 #[derive(Debug, Clone)]
@@ -73,6 +88,8 @@ pub struct GlobalHashable<'a> {
     pub pass_through_env: &'a [String],
     pub env_mode: EnvMode,
     pub framework_inference: bool,
+    pub cache_key_prefix: Option<&'a str>,
+    pub dot_env: EnvironmentVariablePairs,
 }
 
 pub struct LockFilePackages(pub Vec<turborepo_lockfiles::Package>);
@@ -260,6 +277,40 @@ impl From<TaskHashable<'_>> for Builder<HeapAllocator> {
             }
         }
 
+        // Only set when tools are declared so that the hashes of other tasks
+        // don't change
+        if !task_hashable.tool_versions.is_empty() {
+            let mut entries = builder
+                .reborrow()
+                .init_tool_versions(task_hashable.tool_versions.len() as u32);
+            for (i, (command, version)) in task_hashable.tool_versions.iter().enumerate() {
+                let mut entry = entries.reborrow().get(i as u32);
+                entry.set_key(command);
+                entry.set_value(version);
+            }
+        }
+
+        // Only set when dotenv files are declared so that the hashes of other
+        // tasks don't change
+        if !task_hashable.dot_env.is_empty() {
+            let mut dot_env = builder
+                .reborrow()
+                .init_dot_env(task_hashable.dot_env.len() as u32);
+            for (i, env) in task_hashable.dot_env.iter().enumerate() {
+                dot_env.set(i as u32, env);
+            }
+        }
+
+        if let Some(runner) = task_hashable.runner {
+            builder.set_runner(runner);
+        }
+
+        if let Some(shell) = &task_hashable.shell {
+            builder.set_shell(shell);
+        }
+
+        builder.set_follow_symlinks(task_hashable.follow_symlinks);
+
         // We're okay to unwrap here because we haven't hit the nesting
         // limit and the message will not have cycles.
         let size = builder
@@ -362,6 +413,20 @@ impl From<GlobalHashable<'_>> for Builder<HeapAllocator> {
 
         builder.set_framework_inference(hashable.framework_inference);
 
+        // Only set when given so that hashes without a prefix are unchanged
+        if let Some(cache_key_prefix) = hashable.cache_key_prefix {
+            builder.set_cache_key_prefix(cache_key_prefix);
+        }
+
+        if !hashable.dot_env.is_empty() {
+            let mut dot_env = builder
+                .reborrow()
+                .init_dot_env(hashable.dot_env.len() as u32);
+            for (i, env) in hashable.dot_env.iter().enumerate() {
+                dot_env.set(i as u32, env);
+            }
+        }
+
         // We're okay to unwrap here because we haven't hit the nesting
         // limit and the message will not have cycles.
         let size = builder
@@ -381,6 +446,8 @@ impl From<GlobalHashable<'_>> for Builder<HeapAllocator> {
 
 #[cfg(test)]
 mod test {
+    use std::collections::{BTreeMap, HashMap};
+
     use test_case::test_case;
     use turborepo_lockfiles::Package;
 
@@ -407,11 +474,47 @@ mod test {
             resolved_env_vars: vec![],
             pass_through_env: &["pass_thru_env".to_string()],
             env_mode: EnvMode::Loose,
+            tool_versions: &BTreeMap::new(),
+            dot_env: vec![],
+            runner: None,
+            shell: None,
+            follow_symlinks: false,
         };
 
         assert_eq!(task_hashable.hash(), "1f8b13161f57fca1");
     }
 
+    #[test]
+    fn task_hashable_tool_versions() {
+        let hash = |tool_versions: &BTreeMap<String, String>| {
+            TaskHashable {
+                global_hash: "global_hash",
+                task_dependency_hashes: vec![],
+                package_dir: None,
+                hash_of_files: "hash_of_files",
+                external_deps_hash: None,
+                task: "task",
+                outputs: TaskOutputs::default(),
+                pass_through_args: &[],
+                env: &[],
+                resolved_env_vars: vec![],
+                pass_through_env: &[],
+                env_mode: EnvMode::Loose,
+                tool_versions,
+                dot_env: vec![],
+                runner: None,
+                shell: None,
+                follow_symlinks: false,
+            }
+            .hash()
+        };
+        let node_20 = BTreeMap::from([("node --version".to_string(), "v20.11.0".to_string())]);
+        let node_22 = BTreeMap::from([("node --version".to_string(), "v22.1.0".to_string())]);
+
+        assert_ne!(hash(&node_20), hash(&BTreeMap::new()));
+        assert_ne!(hash(&node_20), hash(&node_22));
+    }
+
     #[test]
     fn global_hashable() {
         let global_file_hash_map = vec![(
@@ -432,11 +535,38 @@ mod test {
             pass_through_env: &["pass_through_env".to_string()],
             env_mode: EnvMode::Strict,
             framework_inference: true,
+            cache_key_prefix: None,
+            dot_env: vec![],
         };
 
         assert_eq!(global_hash.hash(), "5072bd005ec02799");
     }
 
+    #[test]
+    fn global_hashable_cache_key_prefix() {
+        let global_file_hash_map = HashMap::new();
+        let hashable = |cache_key_prefix| GlobalHashable {
+            global_cache_key: "global_cache_key",
+            global_file_hash_map: &global_file_hash_map,
+            root_external_dependencies_hash: None,
+            root_internal_dependencies_hash: None,
+            engines: Default::default(),
+            env: &[],
+            resolved_env_vars: vec![],
+            pass_through_env: &[],
+            env_mode: EnvMode::Strict,
+            framework_inference: true,
+            cache_key_prefix,
+            dot_env: vec![],
+        };
+
+        let unprefixed = hashable(None).hash();
+        let main = hashable(Some("main")).hash();
+        assert_ne!(unprefixed, main);
+        assert_ne!(main, hashable(Some("staging")).hash());
+        assert_eq!(main, hashable(Some("main")).hash());
+    }
+
     #[test_case(vec![], "459c029558afe716" ; "empty")]
     #[test_case(vec![Package {
         key: "key".to_string(),
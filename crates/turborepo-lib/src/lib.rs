@@ -18,6 +18,7 @@ mod commands;
 mod config;
 mod daemon;
 mod diagnostics;
+pub mod embed;
 mod engine;
 
 mod framework;
@@ -52,7 +53,17 @@ pub fn get_version() -> &'static str {
 }
 
 pub fn main() -> Result<i32, shim::Error> {
-    shim::run()
+    // Turbo can fail before the arguments are parsed, so the error format is
+    // read from them directly
+    let error_format = cli::ErrorFormat::from_args(std::env::args());
+    match shim::run() {
+        Err(err) if error_format == cli::ErrorFormat::Json => {
+            let error = cli::JsonError::from_error(&err);
+            cli::print_json_error(&error);
+            Ok(error.exit_code())
+        }
+        result => result,
+    }
 }
 
 #[cfg(all(feature = "native-tls", feature = "rustls-tls"))]
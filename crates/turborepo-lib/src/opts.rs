@@ -1,12 +1,14 @@
-use std::{backtrace, backtrace::Backtrace};
+use std::{backtrace, backtrace::Backtrace, time::Duration};
 
+use camino::Utf8PathBuf;
 use thiserror::Error;
 use turbopath::AnchoredSystemPathBuf;
 use turborepo_cache::CacheOpts;
 
 use crate::{
     cli::{
-        Command, DryRunMode, EnvMode, ExecutionArgs, LogOrder, LogPrefix, OutputLogsMode, RunArgs,
+        Command, ContinueMode, DependencyMode, DryRunMode, EnvMode, ExecutionArgs, Force, LogOrder,
+        LogPrefix, OutputLogsMode, RunArgs, TimeBudgetMode,
     },
     run::task_id::TaskId,
     Args,
@@ -32,7 +34,7 @@ pub enum Error {
     Path(#[from] turbopath::PathError),
 }
 
-#[derive(Debug)]
+#[derive(Debug, Clone)]
 pub struct Opts {
     pub cache_opts: CacheOpts,
     pub run_opts: RunOpts,
@@ -48,12 +50,24 @@ impl Opts {
             cmd.push_str(pattern);
         }
 
+        if let Some(base) = &self.scope_opts.affected_base {
+            cmd.push_str(" --affected");
+            if base != DEFAULT_AFFECTED_BASE {
+                cmd.push_str(" --affected-base=");
+                cmd.push_str(base);
+            }
+        }
+
         if self.run_opts.parallel {
             cmd.push_str(" --parallel");
         }
 
-        if self.run_opts.continue_on_error {
-            cmd.push_str(" --continue");
+        match self.run_opts.continue_on_error {
+            ContinueMode::Never => (),
+            ContinueMode::Always => cmd.push_str(" --continue"),
+            ContinueMode::DependenciesSuccessful => {
+                cmd.push_str(" --continue=dependencies-successful")
+            }
         }
 
         if let Some(dry) = self.run_opts.dry_run {
@@ -67,6 +81,11 @@ impl Opts {
             cmd.push_str(" --only");
         }
 
+        if self.run_opts.dependency_mode != DependencyMode::All {
+            cmd.push_str(" --dependency-mode=");
+            cmd.push_str(&self.run_opts.dependency_mode.to_string());
+        }
+
         if !self.run_opts.pass_through_args.is_empty() {
             cmd.push_str(" -- ");
             cmd.push_str(&self.run_opts.pass_through_args.join(" "));
@@ -112,24 +131,37 @@ struct RunAndExecutionArgs<'a> {
     execution_args: &'a ExecutionArgs,
 }
 
-#[derive(Debug, Default)]
+#[derive(Debug, Default, Clone)]
 pub struct RunCacheOpts {
     pub(crate) skip_reads: bool,
+    // Tasks that skip reads when the rest of the run doesn't
+    pub(crate) forced_tasks: Vec<String>,
     pub(crate) skip_writes: bool,
     pub(crate) task_output_logs_override: Option<OutputLogsMode>,
+    pub(crate) log_dir: Option<Utf8PathBuf>,
+    // Fail tasks on cache problems instead of warning about them
+    pub(crate) strict: bool,
 }
 
 impl<'a> From<RunAndExecutionArgs<'a>> for RunCacheOpts {
     fn from(args: RunAndExecutionArgs<'a>) -> Self {
+        let (skip_reads, forced_tasks) = match &args.execution_args.force {
+            Some(Force::All) => (true, Vec::new()),
+            Some(Force::Tasks(tasks)) => (false, tasks.clone()),
+            Some(Force::None) | None => (false, Vec::new()),
+        };
         RunCacheOpts {
-            skip_reads: args.execution_args.force.flatten().is_some_and(|f| f),
+            skip_reads,
+            forced_tasks,
             skip_writes: args.run_args.no_cache,
             task_output_logs_override: args.execution_args.output_logs,
+            log_dir: args.execution_args.log_dir.clone(),
+            strict: args.execution_args.strict_cache,
         }
     }
 }
 
-#[derive(Debug)]
+#[derive(Debug, Clone)]
 pub struct RunOpts {
     pub(crate) tasks: Vec<String>,
     pub(crate) concurrency: u32,
@@ -138,9 +170,10 @@ pub struct RunOpts {
     // Whether or not to infer the framework for each workspace.
     pub(crate) framework_inference: bool,
     pub profile: Option<String>,
-    pub(crate) continue_on_error: bool,
+    pub(crate) continue_on_error: ContinueMode,
     pub(crate) pass_through_args: Vec<String>,
     pub(crate) only: bool,
+    pub(crate) dependency_mode: DependencyMode,
     pub(crate) dry_run: Option<DryRunMode>,
     pub graph: Option<GraphOpts>,
     pub(crate) daemon: Option<bool>,
@@ -148,8 +181,37 @@ pub struct RunOpts {
     pub log_prefix: ResolvedLogPrefix,
     pub log_order: ResolvedLogOrder,
     pub summarize: Option<Option<bool>>,
+    // Salt for the hashes of environment variable values shown in summaries
+    pub(crate) env_hash_salt: Option<String>,
+    // Mixed into the global hash to isolate cache artifacts
+    pub(crate) cache_key_prefix: Option<String>,
+    // Skip the tasks that completed in the previous run
+    pub(crate) resume: bool,
+    // Print task hashes without running tasks
+    pub(crate) hash_only: bool,
+    // Look up tasks in the cache without restoring outputs or running tasks
+    pub(crate) check_cache_only: bool,
+    // The longest the run is predicted to take before it fails or warns
+    pub(crate) time_budget: Option<Duration>,
+    pub(crate) time_budget_mode: TimeBudgetMode,
+    // Warn about environment variables that tasks read without declaring them
+    pub(crate) env_audit: bool,
+    // Send the cache usage of the run to the remote cache
+    pub(crate) cache_analytics: bool,
+    pub(crate) otel_exporter_endpoint: Option<String>,
+    // Where tasks are sent to run remotely
+    pub(crate) experimental_executor: Option<String>,
     pub(crate) experimental_space_id: Option<String>,
     pub is_github_actions: bool,
+    // How long tasks are given to exit after being interrupted
+    pub(crate) shutdown_grace_period: Duration,
+    // Overrides the timeout of every task that isn't persistent
+    pub(crate) task_timeout: Option<Duration>,
+    // The task that turbo's stdin is forwarded to
+    pub(crate) interactive: Option<String>,
+    pub(crate) strict_engines: bool,
+    // The turbo.json profile layered on top of the task definitions
+    pub(crate) profile_name: Option<String>,
 }
 
 impl RunOpts {
@@ -165,9 +227,17 @@ impl RunOpts {
             None
         }
     }
+
+    /// Whether stdin should be forwarded to the task. In single package mode
+    /// tasks can be referred to without the package.
+    pub fn is_interactive_task(&self, task_id: &TaskId) -> bool {
+        self.interactive.as_deref().map_or(false, |task| {
+            task == task_id.to_string() || (self.single_package && task == task_id.task())
+        })
+    }
 }
 
-#[derive(Debug)]
+#[derive(Debug, Clone)]
 pub enum GraphOpts {
     Stdout,
     File(String),
@@ -179,13 +249,16 @@ pub enum ResolvedLogOrder {
     Grouped,
 }
 
-#[derive(Debug, Clone, Copy)]
+#[derive(Debug, Clone)]
 pub enum ResolvedLogPrefix {
     Task,
+    Package,
+    Template(String),
     None,
 }
 
 const DEFAULT_CONCURRENCY: u32 = 10;
+const DEFAULT_SHUTDOWN_GRACE_PERIOD: Duration = Duration::from_millis(500);
 
 impl<'a> TryFrom<RunAndExecutionArgs<'a>> for RunOpts {
     type Error = self::Error;
@@ -208,22 +281,31 @@ impl<'a> TryFrom<RunAndExecutionArgs<'a>> for RunOpts {
             LogOrder::Auto if turborepo_ci::Vendor::get_constant() == Some("GITHUB_ACTIONS") => (
                 true,
                 ResolvedLogOrder::Grouped,
-                match args.execution_args.log_prefix {
-                    LogPrefix::Task => ResolvedLogPrefix::Task,
-                    _ => ResolvedLogPrefix::None,
+                match &args.execution_args.log_prefix {
+                    LogPrefix::Auto | LogPrefix::None => ResolvedLogPrefix::None,
+                    log_prefix => log_prefix.clone().into(),
                 },
             ),
 
-            // Streaming is the default behavior except when running on GitHub Actions
+            // Other CI providers that can collapse log groups also get grouped output so each
+            // task's logs are kept together
+            LogOrder::Auto if turborepo_ci::Vendor::supports_log_grouping() => (
+                false,
+                ResolvedLogOrder::Grouped,
+                args.execution_args.log_prefix.clone().into(),
+            ),
+
+            // Streaming is the default behavior except when running on a CI provider that
+            // supports log grouping
             LogOrder::Auto | LogOrder::Stream => (
                 false,
                 ResolvedLogOrder::Stream,
-                args.execution_args.log_prefix.into(),
+                args.execution_args.log_prefix.clone().into(),
             ),
             LogOrder::Grouped => (
                 false,
                 ResolvedLogOrder::Grouped,
-                args.execution_args.log_prefix.into(),
+                args.execution_args.log_prefix.clone().into(),
             ),
         };
 
@@ -232,6 +314,17 @@ impl<'a> TryFrom<RunAndExecutionArgs<'a>> for RunOpts {
             log_prefix,
             log_order,
             summarize: args.run_args.summarize,
+            env_hash_salt: args.run_args.env_hash_salt.clone(),
+            cache_key_prefix: args.execution_args.cache_key_prefix.clone(),
+            resume: args.run_args.resume,
+            hash_only: args.run_args.hash_only,
+            check_cache_only: args.run_args.check_cache_only,
+            time_budget: args.run_args.time_budget,
+            time_budget_mode: args.run_args.time_budget_mode,
+            env_audit: args.run_args.env_audit,
+            cache_analytics: args.run_args.cache_analytics,
+            otel_exporter_endpoint: args.run_args.otel_exporter_endpoint.clone(),
+            experimental_executor: args.run_args.experimental_executor.clone(),
             experimental_space_id: args.run_args.experimental_space_id.clone(),
             framework_inference: args.execution_args.framework_inference,
             env_mode: args.execution_args.env_mode,
@@ -241,11 +334,20 @@ impl<'a> TryFrom<RunAndExecutionArgs<'a>> for RunOpts {
             continue_on_error: args.execution_args.continue_execution,
             pass_through_args: args.execution_args.pass_through_args.clone(),
             only: args.execution_args.only,
+            dependency_mode: args.execution_args.dependency_mode,
             daemon: args.run_args.daemon(),
             single_package: args.execution_args.single_package,
             graph,
             dry_run: args.run_args.dry_run,
             is_github_actions,
+            shutdown_grace_period: args
+                .execution_args
+                .shutdown_grace_period
+                .map_or(DEFAULT_SHUTDOWN_GRACE_PERIOD, Duration::from_millis),
+            task_timeout: args.execution_args.task_timeout,
+            interactive: args.execution_args.interactive.clone(),
+            strict_engines: args.execution_args.strict_engines,
+            profile_name: args.execution_args.profile_name.clone(),
         })
     }
 }
@@ -276,16 +378,27 @@ impl From<LogPrefix> for ResolvedLogPrefix {
         match value {
             // We default to task-prefixed logs
             LogPrefix::Auto | LogPrefix::Task => ResolvedLogPrefix::Task,
+            LogPrefix::Package => ResolvedLogPrefix::Package,
+            LogPrefix::Template(template) => ResolvedLogPrefix::Template(template),
             LogPrefix::None => ResolvedLogPrefix::None,
         }
     }
 }
 
-#[derive(Debug)]
+/// The git ref that `--affected` compares against unless one is provided
+pub const DEFAULT_AFFECTED_BASE: &str = "origin/main";
+
+#[derive(Debug, Clone)]
 pub struct ScopeOpts {
     pub pkg_inference_root: Option<AnchoredSystemPathBuf>,
     pub global_deps: Vec<String>,
     pub filter_patterns: Vec<String>,
+    // The git ref to find changed packages against, if running with `--affected`
+    pub affected_base: Option<String>,
+    // Print how the filters were resolved before running
+    pub explain_filter: bool,
+    // Fetch missing history of a shallow clone instead of erroring
+    pub auto_deepen: bool,
 }
 
 impl<'a> TryFrom<RunAndExecutionArgs<'a>> for ScopeOpts {
@@ -303,6 +416,14 @@ impl<'a> TryFrom<RunAndExecutionArgs<'a>> for ScopeOpts {
             global_deps: args.execution_args.global_deps.clone(),
             pkg_inference_root,
             filter_patterns: args.execution_args.filter.clone(),
+            affected_base: args.execution_args.affected.then(|| {
+                args.execution_args
+                    .affected_base
+                    .clone()
+                    .unwrap_or_else(|| DEFAULT_AFFECTED_BASE.to_string())
+            }),
+            explain_filter: args.execution_args.explain_filter,
+            auto_deepen: args.execution_args.auto_deepen,
         })
     }
 }
@@ -312,8 +433,13 @@ impl<'a> From<RunAndExecutionArgs<'a>> for CacheOpts {
         CacheOpts {
             override_dir: args.execution_args.cache_dir.clone(),
             skip_filesystem: args.execution_args.remote_only,
-            remote_cache_read_only: args.run_args.remote_cache_read_only,
+            remote_cache_read_only: args.run_args.remote_cache_read_only.unwrap_or_default(),
+            remote_cache_write_only: args.run_args.remote_cache_write_only.unwrap_or_default(),
             workers: args.run_args.cache_workers,
+            upload_concurrency: args.run_args.remote_cache_upload_concurrency,
+            max_size: args.execution_args.cache_max_size,
+            skip_unchanged: args.execution_args.cache_skip_unchanged,
+            strict: args.execution_args.strict_cache,
             ..CacheOpts::default()
         }
     }
@@ -327,7 +453,64 @@ impl RunOpts {
     }
 }
 
+#[cfg(test)]
+impl RunOpts {
+    /// The options of `turbo run` for `tasks` when no other flags are passed
+    pub(crate) fn for_tasks(tasks: Vec<String>) -> Self {
+        Self {
+            tasks,
+            concurrency: DEFAULT_CONCURRENCY,
+            parallel: false,
+            env_mode: EnvMode::Loose,
+            framework_inference: true,
+            profile: None,
+            continue_on_error: ContinueMode::Never,
+            pass_through_args: vec![],
+            only: false,
+            dependency_mode: DependencyMode::All,
+            dry_run: None,
+            graph: None,
+            daemon: None,
+            single_package: false,
+            log_prefix: ResolvedLogPrefix::Task,
+            log_order: ResolvedLogOrder::Stream,
+            summarize: None,
+            env_hash_salt: None,
+            cache_key_prefix: None,
+            resume: false,
+            hash_only: false,
+            check_cache_only: false,
+            time_budget: None,
+            time_budget_mode: TimeBudgetMode::Fail,
+            env_audit: false,
+            cache_analytics: false,
+            otel_exporter_endpoint: None,
+            experimental_executor: None,
+            experimental_space_id: None,
+            is_github_actions: false,
+            shutdown_grace_period: DEFAULT_SHUTDOWN_GRACE_PERIOD,
+            task_timeout: None,
+            interactive: None,
+            strict_engines: false,
+            profile_name: None,
+        }
+    }
+}
+
 impl ScopeOpts {
+    /// Options that select the packages matching `filter_patterns`, for
+    /// commands that resolve packages without running tasks
+    pub fn from_filters(filter_patterns: Vec<String>) -> Self {
+        Self {
+            pkg_inference_root: None,
+            global_deps: vec![],
+            filter_patterns,
+            affected_base: None,
+            explain_filter: false,
+            auto_deepen: false,
+        }
+    }
+
     pub fn get_filters(&self) -> Vec<String> {
         self.filter_patterns.clone()
     }
@@ -340,7 +523,7 @@ mod test {
 
     use super::RunOpts;
     use crate::{
-        cli::DryRunMode,
+        cli::{ContinueMode, DependencyMode, DryRunMode},
         opts::{Opts, RunCacheOpts, ScopeOpts},
     };
 
@@ -349,10 +532,12 @@ mod test {
         filter_patterns: Vec<String>,
         tasks: Vec<String>,
         only: bool,
+        dependency_mode: DependencyMode,
         pass_through_args: Vec<String>,
         parallel: bool,
-        continue_on_error: bool,
+        continue_on_error: ContinueMode,
         dry_run: Option<DryRunMode>,
+        affected_base: Option<String>,
     }
 
     #[test_case(TestCaseOpts {
@@ -369,6 +554,30 @@ mod test {
         },
         "turbo run build --only"
     )]
+    #[test_case(
+        TestCaseOpts {
+            tasks: vec!["dev".to_string()],
+            dependency_mode: DependencyMode::IgnoreTopology,
+            ..Default::default()
+        },
+        "turbo run dev --dependency-mode=ignore-topology"
+    )]
+    #[test_case(
+        TestCaseOpts {
+            tasks: vec!["build".to_string()],
+            affected_base: Some("origin/main".to_string()),
+            ..Default::default()
+        },
+        "turbo run build --affected"
+    )]
+    #[test_case(
+        TestCaseOpts {
+            tasks: vec!["build".to_string()],
+            affected_base: Some("origin/release".to_string()),
+            ..Default::default()
+        },
+        "turbo run build --affected --affected-base=origin/release"
+    )]
     #[test_case(
         TestCaseOpts {
             filter_patterns: vec!["my-app".to_string()],
@@ -392,11 +601,19 @@ mod test {
             filter_patterns: vec!["my-app".to_string()],
             tasks: vec!["build".to_string()],
             parallel: true,
-            continue_on_error: true,
+            continue_on_error: ContinueMode::Always,
             ..Default::default()
         },
         "turbo run build --filter=my-app --parallel --continue"
     )]
+    #[test_case    (
+        TestCaseOpts {
+            tasks: vec!["build".to_string()],
+            continue_on_error: ContinueMode::DependenciesSuccessful,
+            ..Default::default()
+        },
+        "turbo run build --continue=dependencies-successful"
+    )]
     #[test_case    (
         TestCaseOpts {
             filter_patterns: vec!["my-app".to_string()],
@@ -417,31 +634,19 @@ mod test {
     )]
     fn test_synthesize_command(opts_input: TestCaseOpts, expected: &str) {
         let run_opts = RunOpts {
-            tasks: opts_input.tasks,
-            concurrency: 10,
             parallel: opts_input.parallel,
-            env_mode: crate::cli::EnvMode::Loose,
-            framework_inference: true,
-            profile: None,
             continue_on_error: opts_input.continue_on_error,
             pass_through_args: opts_input.pass_through_args,
             only: opts_input.only,
+            dependency_mode: opts_input.dependency_mode,
             dry_run: opts_input.dry_run,
-            graph: None,
-            daemon: None,
-            single_package: false,
-            log_prefix: crate::opts::ResolvedLogPrefix::Task,
-            log_order: crate::opts::ResolvedLogOrder::Stream,
-            summarize: None,
-            experimental_space_id: None,
-            is_github_actions: false,
+            ..RunOpts::for_tasks(opts_input.tasks)
         };
         let cache_opts = CacheOpts::default();
         let runcache_opts = RunCacheOpts::default();
         let scope_opts = ScopeOpts {
-            pkg_inference_root: None,
-            global_deps: vec![],
-            filter_patterns: opts_input.filter_patterns,
+            affected_base: opts_input.affected_base,
+            ..ScopeOpts::from_filters(opts_input.filter_patterns)
         };
         let opts = Opts {
             run_opts,
@@ -15,7 +15,10 @@ use turborepo_filewatch::{
     NotifyError, OptionalWatch,
 };
 use turborepo_repository::{
-    change_mapper::{ChangeMapper, GlobalDepsPackageChangeMapper, PackageChanges},
+    change_mapper::{
+        ChangeMapper, DefaultPackageChangeMapper, GlobalDepsPackageChangeMapper, LockfileChange,
+        PackageChanges,
+    },
     package_graph::{PackageGraph, PackageGraphBuilder, PackageName, WorkspacePackage},
     package_json::PackageJson,
 };
@@ -90,6 +93,24 @@ impl Default for ChangedFiles {
     }
 }
 
+// Changed files that we failed to map to packages. They're retried along with
+// the next batch of changes rather than straight away, so that an error that
+// persists doesn't make us rediscover the packages in a loop.
+#[derive(Debug, Default)]
+struct PendingChanges {
+    files: HashSet<AnchoredSystemPathBuf>,
+}
+
+impl PendingChanges {
+    fn requeue(&mut self, files: HashSet<AnchoredSystemPathBuf>) {
+        self.files.extend(files);
+    }
+
+    fn merge_into(&mut self, changed_files: &mut HashSet<AnchoredSystemPathBuf>) {
+        changed_files.extend(self.files.drain());
+    }
+}
+
 struct Subscriber {
     file_events_lazy: OptionalWatch<broadcast::Receiver<Result<Event, NotifyError>>>,
     changed_files: Mutex<RefCell<ChangedFiles>>,
@@ -115,6 +136,9 @@ fn is_in_git_folder(path: &AnchoredSystemPath) -> bool {
 struct RepoState {
     root_turbo_json: Option<TurboJson>,
     pkg_dep_graph: PackageGraph,
+    // The lockfile the package graph was built from, so that we can tell which
+    // packages a change to it affects
+    lockfile_contents: Option<Vec<u8>>,
 }
 
 impl RepoState {
@@ -173,7 +197,12 @@ impl Subscriber {
         let gitignore_path = self.repo_root.join_component(".gitignore");
         let (root_gitignore, _) = Gitignore::new(&gitignore_path);
 
+        let workspace_providers = root_turbo_json
+            .as_ref()
+            .map(|turbo_json| turbo_json.workspace_providers.clone())
+            .unwrap_or_default();
         let Ok(pkg_dep_graph) = PackageGraphBuilder::new(&self.repo_root, root_package_json)
+            .with_workspace_providers(workspace_providers)
             .build()
             .await
         else {
@@ -181,10 +210,17 @@ impl Subscriber {
             return None;
         };
 
+        let lockfile_contents = pkg_dep_graph
+            .package_manager()
+            .lockfile_path(&self.repo_root)
+            .read()
+            .ok();
+
         Some((
             RepoState {
                 root_turbo_json,
                 pkg_dep_graph,
+                lockfile_contents,
             },
             root_gitignore,
         ))
@@ -284,6 +320,7 @@ impl Subscriber {
                 .send(PackageChangeEvent::Rediscover)
                 .ok();
             let mut interval = tokio::time::interval(std::time::Duration::from_millis(100));
+            let mut pending_changes = PendingChanges::default();
 
             loop {
                 interval.tick().await;
@@ -325,7 +362,7 @@ impl Subscriber {
                     root_gitignore = new_root_gitignore;
                 }
 
-                let changed_files: HashSet<_> = trie
+                let mut changed_files: HashSet<_> = trie
                     .keys()
                     .filter_map(|p| {
                         let p = AbsoluteSystemPathBuf::new(p)
@@ -341,8 +378,69 @@ impl Subscriber {
                 if changed_files.is_empty() {
                     continue;
                 }
+                pending_changes.merge_into(&mut changed_files);
+
+                let lockfile_path = repo_state
+                    .pkg_dep_graph
+                    .package_manager()
+                    .lockfile_path(&self.repo_root);
+                let lockfile_changed = ChangeMapper::<DefaultPackageChangeMapper>::lockfile_changed(
+                    &self.repo_root,
+                    &changed_files,
+                    &lockfile_path,
+                );
+                // The lockfile the packages were last checked against, restored if we fail
+                // to map this change so that retrying it still compares against it
+                let mut previous_lockfile = None;
+                let lockfile_change = if lockfile_changed {
+                    // The resolved dependencies of each package live in the package graph, so
+                    // it has to be rebuilt before we can compare them to the previous lockfile
+                    let previous_contents = repo_state.lockfile_contents.clone();
+                    previous_lockfile = Some(previous_contents.clone());
+                    match self.initialize_repo_state().await {
+                        Some((new_repo_state, new_gitignore)) => {
+                            repo_state = new_repo_state;
+                            root_gitignore = new_gitignore;
+                            change_mapper = match repo_state.get_change_mapper() {
+                                Some(change_mapper) => change_mapper,
+                                None => {
+                                    break;
+                                }
+                            };
+                        }
+                        None => {
+                            break;
+                        }
+                    }
+                    Some(
+                        previous_contents
+                            .map_or(LockfileChange::Empty, LockfileChange::WithContent),
+                    )
+                } else {
+                    None
+                };
 
                 let changed_packages = change_mapper.changed_packages(changed_files.clone(), None);
+                // Packages whose external dependencies changed need to be rerun even though
+                // none of their own files changed, so we track them separately
+                let (changed_packages, lockfile_packages) = match lockfile_change
+                    .map(|change| change_mapper.changed_packages(HashSet::new(), Some(change)))
+                {
+                    Some(Ok(PackageChanges::Some(lockfile_pkgs))) => {
+                        let names: HashSet<_> =
+                            lockfile_pkgs.iter().map(|pkg| pkg.name.clone()).collect();
+                        let changed_packages = changed_packages.map(|changes| match changes {
+                            PackageChanges::Some(mut pkgs) => {
+                                pkgs.extend(lockfile_pkgs);
+                                PackageChanges::Some(pkgs)
+                            }
+                            PackageChanges::All => PackageChanges::All,
+                        });
+                        (changed_packages, names)
+                    }
+                    Some(lockfile_changes) => (lockfile_changes, HashSet::new()),
+                    None => (changed_packages, HashSet::new()),
+                };
 
                 tracing::warn!("changed_files: {:?}", changed_files);
                 tracing::warn!("changed_packages: {:?}", changed_packages);
@@ -385,7 +483,9 @@ impl Subscriber {
                         }
 
                         for pkg in filtered_pkgs {
-                            if !self.is_same_hash(&pkg, &mut package_file_hashes).await {
+                            if lockfile_packages.contains(&pkg.name)
+                                || !self.is_same_hash(&pkg, &mut package_file_hashes).await
+                            {
                                 let _ = self.package_change_events_tx.send(
                                     PackageChangeEvent::Package {
                                         name: pkg.name.clone(),
@@ -395,8 +495,10 @@ impl Subscriber {
                         }
                     }
                     Err(err) => {
-                        // Log the error, rediscover the packages and try again
+                        // Log the error, rediscover the packages and try again with the next
+                        // changes
                         tracing::error!("error: {:?}", err);
+                        pending_changes.requeue(changed_files);
 
                         let _ = self
                             .package_change_events_tx
@@ -405,6 +507,9 @@ impl Subscriber {
                             Some((new_repo_state, new_gitignore)) => {
                                 repo_state = new_repo_state;
                                 root_gitignore = new_gitignore;
+                                if let Some(lockfile_contents) = previous_lockfile {
+                                    repo_state.lockfile_contents = lockfile_contents;
+                                }
                                 change_mapper = match repo_state.get_change_mapper() {
                                     Some(change_mapper) => change_mapper,
                                     None => {
@@ -435,3 +540,37 @@ impl Subscriber {
         }
     }
 }
+
+#[cfg(test)]
+mod test {
+    use std::collections::HashSet;
+
+    use turbopath::AnchoredSystemPathBuf;
+
+    use super::PendingChanges;
+
+    fn files(paths: &[&str]) -> HashSet<AnchoredSystemPathBuf> {
+        paths
+            .iter()
+            .map(|path| AnchoredSystemPathBuf::from_raw(path).unwrap())
+            .collect()
+    }
+
+    #[test]
+    fn test_pending_changes_are_retried_once() {
+        let mut pending = PendingChanges::default();
+        pending.requeue(files(&["pnpm-lock.yaml", "apps/web/index.ts"]));
+
+        let mut changed = files(&["apps/docs/index.ts"]);
+        pending.merge_into(&mut changed);
+        assert_eq!(
+            changed,
+            files(&["pnpm-lock.yaml", "apps/web/index.ts", "apps/docs/index.ts"])
+        );
+
+        // Once merged, the changes aren't retried again unless they're requeued
+        let mut changed = files(&["apps/docs/index.ts"]);
+        pending.merge_into(&mut changed);
+        assert_eq!(changed, files(&["apps/docs/index.ts"]));
+    }
+}
@@ -26,7 +26,7 @@ use std::{
 
 use portable_pty::{native_pty_system, Child as PtyChild, MasterPty as PtyController};
 use tokio::{
-    io::{AsyncBufRead, AsyncBufReadExt, BufReader},
+    io::{AsyncBufRead, AsyncBufReadExt, AsyncWriteExt, BufReader},
     join,
     process::Command as TokioCommand,
     sync::{mpsc, watch, RwLock},
@@ -68,6 +68,10 @@ pub enum ShutdownStyle {
     /// `Kill`.
     Graceful(Duration),
 
+    /// Like `Graceful`, but sends a SIGTERM on posix systems. Used to stop
+    /// tasks that have run for longer than their timeout.
+    Terminate(Duration),
+
     Kill,
 }
 
@@ -309,17 +313,21 @@ impl ShutdownStyle {
             // Windows doesn't give the ability to send a signal to a process so we
             // can't make use of the graceful shutdown timeout.
             #[allow(unused)]
-            ShutdownStyle::Graceful(timeout) => {
+            ShutdownStyle::Graceful(timeout) | ShutdownStyle::Terminate(timeout) => {
                 // try ro run the command for the given timeout
                 #[cfg(unix)]
                 {
+                    let (signal, signal_name) = match self {
+                        ShutdownStyle::Terminate(_) => (libc::SIGTERM, "SIGTERM"),
+                        _ => (libc::SIGINT, "SIGINT"),
+                    };
                     let fut = async {
                         if let Some(pid) = child.pid() {
-                            debug!("sending SIGINT to child {}", pid);
+                            debug!("sending {} to child {}", signal_name, pid);
                             // kill takes negative pid to indicate that you want to use gpid
                             let pgid = -(pid as i32);
                             unsafe {
-                                libc::kill(pgid, libc::SIGINT);
+                                libc::kill(pgid, signal);
                             }
                             debug!("waiting for child {}", pid);
                             child.wait().await
@@ -333,7 +341,7 @@ impl ShutdownStyle {
 
                     let result = tokio::time::timeout(*timeout, fut).await;
                     match result {
-                        // We ignore the exit code and mark it as killed since we sent a SIGINT
+                        // We ignore the exit code and mark it as killed since we sent a signal
                         // This avoids reliance on an underlying process exiting with
                         // no exit code or a non-zero in order for turbo to operate correctly.
                         Ok(Ok(_exit_code)) => ChildState::Exited(ChildExit::Killed),
@@ -404,10 +412,15 @@ impl ChildCommandChannel {
     pub async fn stop(&self) -> Result<(), mpsc::error::SendError<ChildCommand>> {
         self.0.send(ChildCommand::Stop).await
     }
+
+    pub async fn terminate(&self) -> Result<(), mpsc::error::SendError<ChildCommand>> {
+        self.0.send(ChildCommand::Terminate).await
+    }
 }
 
 pub enum ChildCommand {
     Stop,
+    Terminate,
     Kill,
 }
 
@@ -515,6 +528,37 @@ impl Child {
         code
     }
 
+    /// Terminate the `Child` process, killing it if it hasn't exited once the
+    /// shutdown grace period has elapsed.
+    pub async fn terminate(&mut self) -> Option<ChildExit> {
+        let mut watch = self.exit_channel.clone();
+
+        let fut = async {
+            let child = {
+                let state = self.state.read().await;
+
+                match state.command_channel() {
+                    Some(child) => child,
+                    None => return,
+                }
+            };
+
+            // if this fails, it's because the channel is dropped (toctou)
+            // we can just ignore it
+            child.terminate().await.ok();
+        };
+
+        let (_, code) = join! {
+            fut,
+            async {
+                watch.changed().await.ok()?;
+                *watch.borrow()
+            }
+        };
+
+        code
+    }
+
     /// Kill the `Child` process immediately.
     pub async fn kill(&mut self) -> Option<ChildExit> {
         let mut watch = self.exit_channel.clone();
@@ -563,6 +607,50 @@ impl Child {
         }
     }
 
+    /// Forward turbo's stdin to the child until either side closes. Returns
+    /// `false` if the child's stdin has already been taken.
+    pub fn forward_stdin(&mut self) -> bool {
+        let Some(stdin) = self.stdin_inner() else {
+            return false;
+        };
+        // Reads from stdin block until there's input, so they happen on a
+        // dedicated thread. Using one of tokio's blocking threads would keep
+        // the runtime from shutting down while waiting on input.
+        match stdin {
+            ChildInput::Pty(mut child_stdin) => {
+                std::thread::spawn(move || {
+                    io::copy(&mut io::stdin(), &mut child_stdin).ok();
+                });
+            }
+            ChildInput::Std(mut child_stdin) => {
+                let (input_tx, mut input_rx) = mpsc::channel::<Vec<u8>>(1);
+                std::thread::spawn(move || {
+                    let mut buffer = [0; 1024];
+                    loop {
+                        match io::stdin().read(&mut buffer) {
+                            Ok(0) | Err(_) => break,
+                            Ok(n) => {
+                                if input_tx.blocking_send(buffer[..n].to_vec()).is_err() {
+                                    break;
+                                }
+                            }
+                        }
+                    }
+                });
+                tokio::spawn(async move {
+                    while let Some(input) = input_rx.recv().await {
+                        if let Err(e) = child_stdin.write_all(&input).await {
+                            debug!("failed to forward stdin: {e}");
+                            break;
+                        }
+                        child_stdin.flush().await.ok();
+                    }
+                });
+            }
+        }
+        true
+    }
+
     /// Wait for the `Child` to exit and pipe any stdout and stderr to the
     /// provided writer.
     #[tracing::instrument(skip_all)]
@@ -744,6 +832,16 @@ impl ChildStateManager {
                 debug!("stopping child process");
                 self.shutdown_style.process(child).await
             }
+            // we received a command to terminate the child process, it's given the same
+            // grace period as a stop
+            Some(ChildCommand::Terminate) => {
+                debug!("terminating child process");
+                let grace_period = match self.shutdown_style {
+                    ShutdownStyle::Graceful(timeout) | ShutdownStyle::Terminate(timeout) => timeout,
+                    ShutdownStyle::Kill => Duration::ZERO,
+                };
+                ShutdownStyle::Terminate(grace_period).process(child).await
+            }
             // we received a command to kill the child process
             Some(ChildCommand::Kill) => {
                 debug!("killing child process");
@@ -993,6 +1091,47 @@ mod test {
         assert_matches!(&*state, ChildState::Exited(ChildExit::Killed));
     }
 
+    #[test_case(false)]
+    #[test_case(TEST_PTY)]
+    #[tokio::test]
+    #[traced_test]
+    async fn test_terminate(use_pty: bool) {
+        let cmd = {
+            let script = find_script_dir().join_component("sleep_5_ignore.js");
+            let mut cmd = Command::new("node");
+            cmd.args([script.as_std_path()]);
+            cmd
+        };
+
+        let mut child = Child::spawn(
+            cmd,
+            ShutdownStyle::Graceful(Duration::from_secs(10)),
+            use_pty,
+        )
+        .unwrap();
+
+        let mut buf = vec![0; 4];
+        // wait for the process to print "here"
+        match child.outputs().unwrap() {
+            ChildOutput::Std { mut stdout, .. } => {
+                stdout.read_exact(&mut buf).await.unwrap();
+            }
+            ChildOutput::Pty(mut stdout) => {
+                stdout.read_exact(&mut buf).unwrap();
+            }
+        };
+        // SIGINT is ignored, but SIGTERM isn't. The grace period is longer than
+        // we wait, so the process must have exited from the SIGTERM rather than
+        // being killed once the grace period elapsed or finishing its sleep.
+        let start = std::time::Instant::now();
+        child.terminate().await;
+
+        let state = child.state.read().await;
+
+        assert_matches!(&*state, ChildState::Exited(ChildExit::Killed));
+        assert!(start.elapsed() < Duration::from_secs(2));
+    }
+
     #[test_case(false)]
     #[test_case(TEST_PTY)]
     #[tokio::test]
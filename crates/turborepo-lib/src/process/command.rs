@@ -12,6 +12,7 @@ use turbopath::AbsoluteSystemPathBuf;
 pub struct Command {
     program: OsString,
     args: Vec<OsString>,
+    raw_arg: Option<OsString>,
     cwd: Option<AbsoluteSystemPathBuf>,
     env: BTreeMap<OsString, OsString>,
     open_stdin: bool,
@@ -24,6 +25,7 @@ impl Command {
         Self {
             program,
             args: Vec::new(),
+            raw_arg: None,
             cwd: None,
             env: BTreeMap::new(),
             open_stdin: false,
@@ -43,6 +45,14 @@ impl Command {
         self
     }
 
+    /// Appends `arg` to the command line after the other arguments. On Windows
+    /// it isn't quoted, for programs like `cmd` that don't split their command
+    /// line the way most programs do. It's a regular argument elsewhere.
+    pub fn raw_arg(&mut self, arg: impl AsRef<OsStr>) -> &mut Self {
+        self.raw_arg = Some(arg.as_ref().to_os_string());
+        self
+    }
+
     pub fn current_dir(&mut self, dir: AbsoluteSystemPathBuf) -> &mut Self {
         self.cwd = Some(dir);
         self
@@ -92,7 +102,11 @@ impl Command {
                 .map(|dir| dir.as_str())
                 .unwrap_or_default(),
             self.program.to_string_lossy(),
-            self.args.iter().map(|s| s.to_string_lossy()).join(" ")
+            self.args
+                .iter()
+                .chain(&self.raw_arg)
+                .map(|s| s.to_string_lossy())
+                .join(" ")
         )
     }
 
@@ -107,6 +121,7 @@ impl From<Command> for tokio::process::Command {
         let Command {
             program,
             args,
+            raw_arg,
             cwd,
             env,
             open_stdin,
@@ -117,8 +132,14 @@ impl From<Command> for tokio::process::Command {
         if env_clear {
             cmd.env_clear();
         }
-        cmd.args(args)
-            .envs(env)
+        cmd.args(args);
+        if let Some(raw_arg) = raw_arg {
+            #[cfg(windows)]
+            cmd.raw_arg(raw_arg);
+            #[cfg(not(windows))]
+            cmd.arg(raw_arg);
+        }
+        cmd.envs(env)
             // We always pipe stdout/stderr to allow us to capture task output
             .stdout(Stdio::piped())
             .stderr(Stdio::piped())
@@ -140,6 +161,7 @@ impl From<Command> for portable_pty::CommandBuilder {
         let Command {
             program,
             args,
+            raw_arg,
             cwd,
             env,
             env_clear,
@@ -150,6 +172,11 @@ impl From<Command> for portable_pty::CommandBuilder {
             cmd.env_clear();
         }
         cmd.args(args);
+        // A PTY is never used on Windows, so the argument doesn't need special
+        // handling
+        if let Some(raw_arg) = raw_arg {
+            cmd.arg(raw_arg);
+        }
         if let Some(cwd) = cwd {
             cmd.cwd(cwd.as_std_path());
         } else if let Ok(cwd) = std::env::current_dir() {
@@ -90,6 +90,12 @@ impl ProcessManager {
         Some(child)
     }
 
+    /// Returns true once the process manager has been closed and won't spawn
+    /// new children
+    pub fn is_closing(&self) -> bool {
+        self.state.lock().unwrap().is_closing
+    }
+
     /// Stop the process manager, closing all child processes. On posix
     /// systems this will send a SIGINT, and on windows it will just kill
     /// the process immediately.
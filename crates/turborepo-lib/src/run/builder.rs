@@ -5,8 +5,9 @@ use std::{
     time::SystemTime,
 };
 
+use camino::Utf8Path;
 use chrono::Local;
-use tracing::debug;
+use tracing::{debug, warn};
 use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPath};
 use turborepo_analytics::{start_analytics, AnalyticsHandle, AnalyticsSender};
 use turborepo_api_client::{APIAuth, APIClient};
@@ -14,7 +15,8 @@ use turborepo_cache::{AsyncCache, RemoteCacheOpts};
 use turborepo_env::EnvironmentVariableMap;
 use turborepo_errors::Spanned;
 use turborepo_repository::{
-    package_graph::{PackageGraph, PackageName},
+    engines,
+    package_graph::{PackageGraph, PackageName, WorkspaceProvider},
     package_json,
     package_json::PackageJson,
 };
@@ -37,18 +39,23 @@ use {
 };
 
 use crate::{
-    cli::DryRunMode,
+    cli::{Command, DryRunMode},
     commands::CommandBase,
+    config::Error as ConfigError,
+    daemon::package_graph_key,
     engine::{Engine, EngineBuilder},
     opts::Opts,
     process::ProcessManager,
-    run::{scope, task_access::TaskAccess, task_id::TaskName, Error, Run, RunCache},
+    run::{
+        cache::forces_task, scope, task_access::TaskAccess, task_id::TaskName, Error, Run, RunCache,
+    },
     shim::TurboState,
     signal::{SignalHandler, SignalSubscriber},
     turbo_json::TurboJson,
     DaemonConnector,
 };
 
+#[derive(Clone)]
 pub struct RunBuilder {
     processes: ProcessManager,
     opts: Opts,
@@ -64,6 +71,9 @@ pub struct RunBuilder {
     // this package.
     entrypoint_packages: Option<HashSet<PackageName>>,
     should_print_prelude_override: Option<bool>,
+    workspace_providers: Vec<WorkspaceProvider>,
+    // Set for the tasks in addition to the environment turbo was started with
+    env: EnvironmentVariableMap,
 }
 
 impl RunBuilder {
@@ -74,7 +84,12 @@ impl RunBuilder {
         let mut opts: Opts = base.args().try_into()?;
         let config = base.config()?;
         let is_linked = turborepo_api_client::is_linked(&api_auth);
-        if !is_linked {
+        let s3_opts = config.s3_opts()?;
+        if s3_opts.is_some() {
+            // An S3 remote cache doesn't need the repo to be linked to Vercel
+            opts.cache_opts.skip_remote = !config.enabled.unwrap_or(true);
+            opts.cache_opts.s3_opts = s3_opts;
+        } else if !is_linked {
             opts.cache_opts.skip_remote = true;
         } else if let Some(enabled) = config.enabled {
             // We're linked, but if the user has explicitly enabled or disabled, use that
@@ -86,6 +101,26 @@ impl RunBuilder {
         // configured team_id matches the final resolved team_id.
         let unused_remote_cache_opts_team_id = config.team_id().map(|team_id| team_id.to_string());
         let signature = config.signature();
+        // The flags, which can also be set with their environment variables, take
+        // precedence over the config
+        if let Some(Command::Run { run_args, .. }) = &base.args().command {
+            opts.cache_opts.remote_cache_read_only = run_args
+                .remote_cache_read_only
+                .unwrap_or_else(|| config.read_only());
+            opts.cache_opts.remote_cache_write_only = run_args
+                .remote_cache_write_only
+                .unwrap_or_else(|| config.write_only());
+        }
+        if opts.cache_opts.remote_cache_read_only && opts.cache_opts.remote_cache_write_only {
+            return Err(ConfigError::RemoteCacheReadAndWriteOnly.into());
+        }
+        opts.cache_opts.compression_level = config.cache_compression_level()?;
+        opts.cache_opts.remote_failure_limit = config.failure_limit();
+        // `--cache-dir` takes precedence over the configured directory
+        if opts.cache_opts.override_dir.is_none() {
+            opts.cache_opts.override_dir = config.cache_dir().map(Utf8Path::to_owned);
+        }
+        opts.cache_opts.tiers = config.cache_tiers().to_vec();
         opts.cache_opts.remote_cache_opts = Some(RemoteCacheOpts::new(
             unused_remote_cache_opts_team_id,
             signature,
@@ -95,6 +130,7 @@ impl RunBuilder {
         }
         let version = base.version();
         let experimental_ui = config.ui();
+        let workspace_providers = config.workspace_providers()?;
         let processes = ProcessManager::new(
             // We currently only use a pty if the following are met:
             // - we're attached to a tty
@@ -116,6 +152,8 @@ impl RunBuilder {
             analytics_sender: None,
             entrypoint_packages: None,
             should_print_prelude_override: None,
+            workspace_providers,
+            env: EnvironmentVariableMap::default(),
         })
     }
 
@@ -124,6 +162,23 @@ impl RunBuilder {
         self
     }
 
+    pub fn with_env(mut self, env: EnvironmentVariableMap) -> Self {
+        self.env = env;
+        self
+    }
+
+    /// Replaces the tasks to run, e.g. for another entry of a plan
+    pub fn with_tasks(mut self, tasks: Vec<String>) -> Self {
+        self.opts.run_opts.tasks = tasks;
+        self
+    }
+
+    /// Replaces the `--filter` patterns of the run
+    pub fn with_filters(mut self, filters: Vec<String>) -> Self {
+        self.opts.scope_opts.filter_patterns = filters;
+        self
+    }
+
     pub fn hide_prelude(mut self) -> Self {
         self.should_print_prelude_override = Some(false);
         self
@@ -180,6 +235,9 @@ impl RunBuilder {
         };
         let package_json_path = self.repo_root.join_component("package.json");
         let root_package_json = PackageJson::load(&package_json_path)?;
+        if self.opts.run_opts.strict_engines {
+            engines::check(&self.repo_root, &root_package_json)?;
+        }
         let run_telemetry = GenericEventBuilder::new().with_parent(&telemetry);
         let repo_telemetry =
             RepoEventBuilder::new(&self.repo_root.to_string()).with_parent(&telemetry);
@@ -244,9 +302,28 @@ impl RunBuilder {
             }
         };
 
+        // Resolving the transitive dependencies of each package is the slowest part
+        // of building the package graph, so we reuse the daemon's if it has them
+        let cached_dependencies = match &daemon {
+            Some(daemon) if !self.opts.run_opts.single_package => {
+                match package_graph_key(&self.repo_root, &root_package_json) {
+                    Some(key) => daemon
+                        .clone()
+                        .get_package_graph(key)
+                        .await
+                        .map_err(|e| debug!("not reusing package graph from daemon: {e}"))
+                        .ok(),
+                    None => None,
+                }
+            }
+            _ => None,
+        };
+
         let mut pkg_dep_graph = {
             let builder = PackageGraph::builder(&self.repo_root, root_package_json.clone())
-                .with_single_package_mode(self.opts.run_opts.single_package);
+                .with_single_package_mode(self.opts.run_opts.single_package)
+                .with_cached_dependencies(cached_dependencies)
+                .with_workspace_providers(self.workspace_providers.clone());
 
             #[cfg(feature = "daemon-package-discovery")]
             let graph = {
@@ -331,46 +408,25 @@ impl RunBuilder {
         let task_access = TaskAccess::new(self.repo_root.clone(), async_cache.clone(), &scm);
         task_access.restore_config().await;
 
-        let root_turbo_json = TurboJson::load(
+        let mut root_turbo_json = TurboJson::load(
             &self.repo_root,
             AnchoredSystemPath::empty(),
             &root_package_json,
             is_single_package,
         )?;
+        if let Some(profile_name) = &self.opts.run_opts.profile_name {
+            root_turbo_json.select_profile(profile_name)?;
+        }
 
         pkg_dep_graph.validate()?;
 
-        let filtered_pkgs = {
-            let (mut filtered_pkgs, is_all_packages) = scope::resolve_packages(
-                &self.opts.scope_opts,
-                &self.repo_root,
-                &pkg_dep_graph,
-                &scm,
-                &root_turbo_json,
-            )?;
+        let filtered_pkgs = self.filtered_packages(&pkg_dep_graph, &scm, &root_turbo_json)?;
 
-            if is_all_packages {
-                for target in self.opts.run_opts.tasks.iter() {
-                    let mut task_name = TaskName::from(target.as_str());
-                    // If it's not a package task, we convert to a root task
-                    if !task_name.is_package_task() {
-                        task_name = task_name.into_root_task()
-                    }
-
-                    if root_turbo_json.tasks.contains_key(&task_name) {
-                        filtered_pkgs.insert(PackageName::Root);
-                        break;
-                    }
-                }
-            };
-
-            filtered_pkgs
-        };
-
-        let env_at_execution_start = EnvironmentVariableMap::infer();
+        let env_at_execution_start = self.env_at_execution_start();
         let mut engine = self.build_engine(&pkg_dep_graph, &root_turbo_json, &filtered_pkgs)?;
 
         if self.opts.run_opts.parallel {
+            warn!("--parallel is deprecated, use --dependency-mode=ignore-topology instead");
             pkg_dep_graph.remove_package_dependencies();
             engine = self.build_engine(&pkg_dep_graph, &root_turbo_json, &filtered_pkgs)?;
         }
@@ -383,13 +439,12 @@ impl RunBuilder {
             &self.opts.runcache_opts,
             color_selector,
             daemon.clone(),
+            scm.clone(),
             self.ui,
-            self.opts.run_opts.dry_run.is_some(),
+            self.opts.run_opts.dry_run.is_some() || self.opts.run_opts.check_cache_only,
         ));
 
-        let should_print_prelude = self.should_print_prelude_override.unwrap_or_else(|| {
-            self.opts.run_opts.dry_run.is_none() && self.opts.run_opts.graph.is_none()
-        });
+        let should_print_prelude = self.should_print_prelude();
 
         Ok(Run {
             version: self.version,
@@ -404,6 +459,7 @@ impl RunBuilder {
             api_client: self.api_client,
             api_auth: self.api_auth,
             env_at_execution_start,
+            extra_env: self.env,
             filtered_pkgs,
             pkg_dep_graph: Arc::new(pkg_dep_graph),
             root_turbo_json,
@@ -416,6 +472,109 @@ impl RunBuilder {
         })
     }
 
+    /// Builds a run of other tasks or packages that reuses the package graph,
+    /// caches and daemon connection of `run`, so that they're only set up
+    /// once when running several sets of tasks in the same process
+    pub fn build_from(mut self, run: &Run, signal_handler: &SignalHandler) -> Result<Run, Error> {
+        let start_at = Local::now();
+        if let Some(subscriber) = signal_handler.subscribe() {
+            self.connect_process_manager(subscriber);
+        }
+
+        let filtered_pkgs =
+            self.filtered_packages(&run.pkg_dep_graph, &run.scm, &run.root_turbo_json)?;
+        let engine = self.build_engine(&run.pkg_dep_graph, &run.root_turbo_json, &filtered_pkgs)?;
+        let env_at_execution_start = self.env_at_execution_start();
+        let should_print_prelude = self.should_print_prelude();
+
+        Ok(Run {
+            version: self.version,
+            ui: self.ui,
+            experimental_ui: self.experimental_ui,
+            start_at,
+            processes: self.processes,
+            run_telemetry: run.run_telemetry.clone(),
+            task_access: run.task_access.clone(),
+            repo_root: self.repo_root,
+            opts: Arc::new(self.opts),
+            api_client: self.api_client,
+            api_auth: self.api_auth,
+            env_at_execution_start,
+            extra_env: self.env,
+            filtered_pkgs,
+            pkg_dep_graph: run.pkg_dep_graph.clone(),
+            root_turbo_json: run.root_turbo_json.clone(),
+            scm: run.scm.clone(),
+            engine: Arc::new(engine),
+            run_cache: run.run_cache.clone(),
+            signal_handler: signal_handler.clone(),
+            daemon: run.daemon.clone(),
+            should_print_prelude,
+        })
+    }
+
+    #[tracing::instrument(skip_all)]
+    fn filtered_packages(
+        &self,
+        pkg_dep_graph: &PackageGraph,
+        scm: &SCM,
+        root_turbo_json: &TurboJson,
+    ) -> Result<HashSet<PackageName>, Error> {
+        let (mut filtered_pkgs, is_all_packages) = if self.opts.scope_opts.explain_filter {
+            let (explanation, is_all_packages) = scope::explain_packages(
+                &self.opts.scope_opts,
+                &self.repo_root,
+                pkg_dep_graph,
+                scm,
+                root_turbo_json,
+            )?;
+            // stderr so it doesn't end up in the output of `--dry=json`
+            eprintln!("{explanation}\n");
+            (explanation.packages, is_all_packages)
+        } else {
+            scope::resolve_packages(
+                &self.opts.scope_opts,
+                &self.repo_root,
+                pkg_dep_graph,
+                scm,
+                root_turbo_json,
+            )?
+        };
+
+        if is_all_packages {
+            for target in self.opts.run_opts.tasks.iter() {
+                let mut task_name = TaskName::from(target.as_str());
+                // If it's not a package task, we convert to a root task
+                if !task_name.is_package_task() {
+                    task_name = task_name.into_root_task()
+                }
+
+                if root_turbo_json.tasks.contains_key(&task_name) {
+                    filtered_pkgs.insert(PackageName::Root);
+                    break;
+                }
+            }
+        };
+
+        Ok(filtered_pkgs)
+    }
+
+    fn env_at_execution_start(&self) -> EnvironmentVariableMap {
+        let mut env = EnvironmentVariableMap::infer();
+        env.union(&self.env);
+        env
+    }
+
+    fn should_print_prelude(&self) -> bool {
+        self.should_print_prelude_override.unwrap_or_else(|| {
+            self.opts.run_opts.dry_run.is_none()
+                && self.opts.run_opts.graph.is_none()
+                && !self.opts.run_opts.hash_only
+                && !self.opts.run_opts.check_cache_only
+        })
+    }
+
+    #[tracing::instrument(skip_all)]
     fn build_engine(
         &self,
         pkg_dep_graph: &PackageGraph,
@@ -434,6 +593,7 @@ impl RunBuilder {
                 .collect(),
         ))
         .with_tasks_only(self.opts.run_opts.only)
+        .with_dependency_mode(self.opts.run_opts.dependency_mode)
         .with_workspaces(filtered_pkgs.clone().into_iter().collect())
         .with_tasks(self.opts.run_opts.tasks.iter().map(|task| {
             // TODO: Pull span info from command
@@ -457,6 +617,28 @@ impl RunBuilder {
                 .map_err(Error::EngineValidation)?;
         }
 
+        if let Some(interactive) = &self.opts.run_opts.interactive {
+            let run_opts = &self.opts.run_opts;
+            if !engine
+                .task_definitions()
+                .keys()
+                .any(|task_id| run_opts.is_interactive_task(task_id))
+            {
+                return Err(Error::UnknownInteractiveTask(interactive.clone()));
+            }
+        }
+
+        // A typo in --force would otherwise silently leave the task cached
+        for forced in &self.opts.runcache_opts.forced_tasks {
+            if !engine
+                .task_definitions()
+                .keys()
+                .any(|task_id| forces_task(forced, task_id))
+            {
+                return Err(Error::UnknownForcedTask(forced.clone()));
+            }
+        }
+
         Ok(engine)
     }
 }
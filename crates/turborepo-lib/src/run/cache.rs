@@ -4,16 +4,22 @@ use std::{
     time::Duration,
 };
 
+use globwalk::ValidatedGlob;
 use tokio::sync::oneshot;
-use tracing::{debug, error};
+use tracing::{debug, error, warn};
 use turbopath::{
     AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
 };
-use turborepo_cache::{http::UploadMap, AsyncCache, CacheError, CacheHitMetadata, CacheSource};
-use turborepo_repository::package_graph::PackageInfo;
-use turborepo_scm::SCM;
+use turborepo_cache::{
+    http::UploadMap,
+    transfer::{TransferProgress, TransferTracker},
+    AsyncCache, CacheError, CacheHitMetadata, CacheSource,
+};
+use turborepo_repository::package_graph::{PackageInfo, ROOT_PKG_NAME};
+use turborepo_scm::{IgnoredFiles, SCM};
 use turborepo_telemetry::events::{task::PackageTaskEventBuilder, TrackedErrors};
 use turborepo_ui::{color, ColorSelector, LogWriter, GREY, UI};
+use wax::Program;
 
 use crate::{
     cli::OutputLogsMode,
@@ -24,6 +30,12 @@ use crate::{
     task_graph::{TaskDefinition, TaskOutputs},
 };
 
+// How often the progress of a slow artifact download is reported
+const DOWNLOAD_PROGRESS_INTERVAL: Duration = Duration::from_secs(3);
+// How often the progress of the uploads that are left at the end of a run is
+// reported
+const UPLOAD_PROGRESS_INTERVAL: Duration = Duration::from_secs(1);
+
 #[derive(Debug, thiserror::Error)]
 pub enum Error {
     #[error("Error replaying logs: {0}")]
@@ -42,17 +54,33 @@ pub enum Error {
     Scm(#[from] turborepo_scm::Error),
     #[error(transparent)]
     Path(#[from] turbopath::PathError),
+    #[error("Invalid output exclusion: {0}")]
+    OutputExclusion(#[from] wax::BuildError),
+    #[error("Error writing log file: {0}")]
+    LogFile(std::io::Error),
+    #[error("{task_id} didn't write any files matching its outputs: {}", globs.join(", "))]
+    MissingOutputs { task_id: String, globs: Vec<String> },
+    #[error(
+        "cached artifact for {task_id} restored files outside of its package: {}",
+        files.join(", ")
+    )]
+    OutsidePackage { task_id: String, files: Vec<String> },
 }
 
 pub struct RunCache {
     task_output_logs: Option<OutputLogsMode>,
     cache: AsyncCache,
+    transfers: TransferTracker,
     reads_disabled: bool,
+    forced_tasks: Vec<String>,
     writes_disabled: bool,
     repo_root: AbsoluteSystemPathBuf,
+    log_dir: Option<AbsoluteSystemPathBuf>,
     color_selector: ColorSelector,
     daemon_client: Option<DaemonClient<DaemonConnector>>,
+    scm: SCM,
     ui: UI,
+    strict: bool,
 }
 
 /// Trait used to output cache information to user
@@ -69,6 +97,7 @@ impl RunCache {
         opts: &RunCacheOpts,
         color_selector: ColorSelector,
         daemon_client: Option<DaemonClient<DaemonConnector>>,
+        scm: SCM,
         ui: UI,
         is_dry_run: bool,
     ) -> Self {
@@ -79,13 +108,21 @@ impl RunCache {
         };
         RunCache {
             task_output_logs,
+            transfers: cache.transfers().unwrap_or_default(),
             cache,
             reads_disabled: opts.skip_reads,
+            forced_tasks: opts.forced_tasks.clone(),
             writes_disabled: opts.skip_writes,
             repo_root: repo_root.to_owned(),
+            log_dir: opts
+                .log_dir
+                .as_deref()
+                .map(|log_dir| AbsoluteSystemPathBuf::from_unknown(repo_root, log_dir)),
             color_selector,
             daemon_client,
+            scm,
             ui,
+            strict: opts.strict,
         }
     }
 
@@ -103,6 +140,8 @@ impl RunCache {
             .resolve(&TaskDefinition::workspace_relative_log_file(task_id.task()));
         let repo_relative_globs =
             task_definition.repo_relative_hashable_outputs(&task_id, workspace_info.package_path());
+        let local_only_globs =
+            task_definition.repo_relative_local_only_outputs(workspace_info.package_path());
 
         let mut task_output_logs = task_definition.output_logs;
         if let Some(task_output_logs_override) = self.task_output_logs {
@@ -110,17 +149,32 @@ impl RunCache {
         }
 
         let caching_disabled = !task_definition.cache;
+        let reads_disabled = self.reads_disabled || is_forced(&self.forced_tasks, &task_id);
+        let checkpoint = task_definition.is_checkpoint();
+        let log_dir_file = self
+            .log_dir
+            .as_deref()
+            .map(|log_dir| task_log_dir_file(log_dir, &task_id));
 
         TaskCache {
             expanded_outputs: Vec::new(),
             run_cache: self.clone(),
             repo_relative_globs,
+            local_only_globs,
+            package_path: workspace_info.package_path().to_owned(),
+            ignored_files: None,
             hash: hash.to_owned(),
             task_id,
             task_output_logs,
             caching_disabled,
+            reads_disabled,
+            checkpoint,
             log_file_path,
+            log_dir_file,
             daemon_client: self.daemon_client.clone(),
+            locked: false,
+            follow_symlinks: task_definition.follow_symlinks,
+            compression_level: task_definition.compression_level,
             ui: self.ui,
         }
     }
@@ -131,17 +185,72 @@ impl RunCache {
         // Ignore errors coming from cache already shutting down
         self.cache.start_shutdown().await
     }
+
+    /// Waits for the cache writes that are still running, so that their
+    /// uploads are part of the run's totals. If `report_progress` is set and
+    /// the uploads take a while, their progress is shown with a spinner.
+    pub async fn wait_for_writes(&self, report_progress: bool) {
+        let wait = self.cache.wait();
+        tokio::pin!(wait);
+        let mut progress_interval = tokio::time::interval_at(
+            tokio::time::Instant::now() + UPLOAD_PROGRESS_INTERVAL,
+            UPLOAD_PROGRESS_INTERVAL,
+        );
+        let mut spinner = None;
+        loop {
+            tokio::select! {
+                // The cache can only fail to wait if it's already shutting down
+                _ = &mut wait => break,
+                _ = progress_interval.tick(), if report_progress => {
+                    if let Some((uploads, progress)) = self.transfers.upload_progress() {
+                        spinner
+                            .get_or_insert_with(|| {
+                                turborepo_ui::start_spinner("...Finishing writing to cache...")
+                            })
+                            .set_message(upload_status(uploads, progress));
+                    }
+                }
+            }
+        }
+        if let Some(spinner) = spinner {
+            spinner.finish_and_clear();
+        }
+    }
+
+    /// Returns the tracker for remote cache transfers. Without a remote cache
+    /// it never records any transfers.
+    pub fn transfers(&self) -> TransferTracker {
+        self.transfers.clone()
+    }
 }
 
 pub struct TaskCache {
     expanded_outputs: Vec<AnchoredSystemPathBuf>,
     run_cache: Arc<RunCache>,
     repo_relative_globs: TaskOutputs,
+    // Outputs that are kept out of the remote cache
+    local_only_globs: TaskOutputs,
+    package_path: AnchoredSystemPathBuf,
+    // The package's gitignored files before the task ran, listed when its
+    // outputs are inferred
+    ignored_files: Option<IgnoredFiles>,
     hash: String,
     task_output_logs: OutputLogsMode,
     caching_disabled: bool,
+    // Set by `--force`, for every task or just this one
+    reads_disabled: bool,
+    // The task has no outputs, so its log marks that it succeeded
+    checkpoint: bool,
     log_file_path: AbsoluteSystemPathBuf,
+    // Where the task's logs are written for `--log-dir`
+    log_dir_file: Option<AbsoluteSystemPathBuf>,
     daemon_client: Option<DaemonClient<DaemonConnector>>,
+    // Whether this process holds the daemon's lock on the task's hash
+    locked: bool,
+    // Whether the outputs are walked through symlinked directories
+    follow_symlinks: bool,
+    // Overrides the cache's compression level for the task's artifact
+    compression_level: Option<i32>,
     ui: UI,
     task_id: TaskId<'static>,
 }
@@ -157,7 +266,10 @@ impl TaskCache {
     }
 
     pub fn on_error(&self, terminal_output: &mut impl CacheOutput) -> Result<(), Error> {
-        if self.task_output_logs == OutputLogsMode::ErrorsOnly {
+        if matches!(
+            self.task_output_logs,
+            OutputLogsMode::ErrorsOnly | OutputLogsMode::SummaryLine
+        ) {
             terminal_output.status(&format!(
                 "cache miss, executing {}",
                 color!(self.ui, GREY, "{}", self.hash)
@@ -168,9 +280,31 @@ impl TaskCache {
         Ok(())
     }
 
+    /// Prints a single line for a task that succeeded, so that a run with
+    /// `summary-line` doesn't look stalled while every task's logs are hidden
+    pub fn on_success(
+        &self,
+        terminal_output: &mut impl CacheOutput,
+        task: &str,
+        outcome: &str,
+        duration: Duration,
+    ) {
+        if self.task_output_logs == OutputLogsMode::SummaryLine {
+            let duration = Duration::from_millis(duration.as_millis() as u64);
+            terminal_output.status(&format!(
+                "{task} completed in {} ({outcome})",
+                humantime::format_duration(duration)
+            ));
+        }
+    }
+
     pub fn output_writer<W: Write>(&self, writer: W) -> Result<LogWriter<W>, Error> {
         let mut log_writer = LogWriter::default();
 
+        if let Some(log_dir_file) = &self.log_dir_file {
+            log_writer.with_log_file(log_dir_file)?;
+        }
+
         if self.caching_disabled || self.run_cache.writes_disabled {
             log_writer.with_writer(writer);
             return Ok(log_writer);
@@ -180,7 +314,10 @@ impl TaskCache {
 
         if !matches!(
             self.task_output_logs,
-            OutputLogsMode::None | OutputLogsMode::HashOnly | OutputLogsMode::ErrorsOnly
+            OutputLogsMode::None
+                | OutputLogsMode::HashOnly
+                | OutputLogsMode::ErrorsOnly
+                | OutputLogsMode::SummaryLine
         ) {
             log_writer.with_writer(writer);
         }
@@ -188,19 +325,86 @@ impl TaskCache {
         Ok(log_writer)
     }
 
+    pub fn reads_disabled(&self) -> bool {
+        self.caching_disabled || self.reads_disabled
+    }
+
+    pub fn writes_disabled(&self) -> bool {
+        self.caching_disabled || self.run_cache.writes_disabled
+    }
+
+    /// Whether cache problems fail the task, set by `--strict-cache`
+    pub fn strict(&self) -> bool {
+        self.run_cache.strict
+    }
+
+    /// Takes the daemon's lock on the task's hash, which other turbo processes
+    /// running the same task wait on. Returns false if another process holds
+    /// the lock. Without the daemon, or if the task's result won't be shared
+    /// through the cache, the task is run without a lock.
+    pub async fn try_lock(&mut self) -> bool {
+        if self.locked || self.reads_disabled() || self.writes_disabled() {
+            return true;
+        }
+        let Some(daemon_client) = self.daemon_client.as_mut() else {
+            return true;
+        };
+        match daemon_client.acquire_task_lock(self.hash.clone()).await {
+            Ok(acquired) => {
+                self.locked = acquired;
+                acquired
+            }
+            Err(err) => {
+                debug!("unable to lock {}: {err}", self.task_id);
+                true
+            }
+        }
+    }
+
+    /// Releases the lock taken by `try_lock`, letting processes waiting on
+    /// the task use its result
+    pub async fn unlock(&mut self) {
+        if !self.locked {
+            return;
+        }
+        self.locked = false;
+        if let Some(daemon_client) = self.daemon_client.as_mut() {
+            if let Err(err) = daemon_client.release_task_lock(self.hash.clone()).await {
+                debug!("unable to unlock {}: {err}", self.task_id);
+            }
+        }
+    }
+
     pub async fn exists(&self) -> Result<Option<CacheHitMetadata>, CacheError> {
         self.run_cache.cache.exists(&self.hash).await
     }
 
+    /// Lists the package's gitignored files before the task runs so that the
+    /// ones it writes can be cached as its inferred outputs
+    pub fn record_ignored_files(&mut self) {
+        if self.writes_disabled() || !self.repo_relative_globs.infers_outputs() {
+            return;
+        }
+        match self
+            .run_cache
+            .scm
+            .ignored_files(&self.run_cache.repo_root, &self.package_path)
+        {
+            Ok(ignored_files) => self.ignored_files = Some(ignored_files),
+            Err(e) => warn!("unable to infer the outputs of {}: {e}", self.task_id),
+        }
+    }
+
+    #[tracing::instrument(skip_all, fields(task = %self.task_id))]
     pub async fn restore_outputs(
         &mut self,
         terminal_output: &mut impl CacheOutput,
         telemetry: &PackageTaskEventBuilder,
     ) -> Result<Option<CacheHitMetadata>, Error> {
-        if self.caching_disabled || self.run_cache.reads_disabled {
+        if self.reads_disabled() {
             if !matches!(
                 self.task_output_logs,
-                OutputLogsMode::None | OutputLogsMode::ErrorsOnly
+                OutputLogsMode::None | OutputLogsMode::ErrorsOnly | OutputLogsMode::SummaryLine
             ) {
                 terminal_output.status(&format!(
                     "cache bypass, force executing {}",
@@ -212,8 +416,14 @@ impl TaskCache {
         }
 
         let validated_inclusions = self.repo_relative_globs.validated_inclusions()?;
+        // The daemon only watches output globs, so it can't tell whether
+        // inferred outputs are still on disk
+        let daemon_client = self
+            .daemon_client
+            .as_mut()
+            .filter(|_| !self.repo_relative_globs.infers_outputs());
 
-        let changed_output_count = if let Some(daemon_client) = &mut self.daemon_client {
+        let changed_output_count = if let Some(daemon_client) = daemon_client {
             match daemon_client
                 .get_changed_outputs(self.hash.to_string(), &validated_inclusions)
                 .await
@@ -239,16 +449,38 @@ impl TaskCache {
             // Note that we currently don't use the output globs when restoring, but we
             // could in the future to avoid doing unnecessary file I/O. We also
             // need to pass along the exclusion globs as well.
-            let cache_status = self
+            let fetch = self
                 .run_cache
                 .cache
-                .fetch(&self.run_cache.repo_root, &self.hash)
-                .await?;
+                .fetch(&self.run_cache.repo_root, &self.hash);
+            tokio::pin!(fetch);
+            let mut progress_interval = tokio::time::interval_at(
+                tokio::time::Instant::now() + DOWNLOAD_PROGRESS_INTERVAL,
+                DOWNLOAD_PROGRESS_INTERVAL,
+            );
+            // Report on downloads that take a while so that the run doesn't
+            // look like it's stuck
+            let report_progress = !matches!(
+                self.task_output_logs,
+                OutputLogsMode::None | OutputLogsMode::ErrorsOnly | OutputLogsMode::SummaryLine
+            );
+            let cache_status = loop {
+                tokio::select! {
+                    cache_status = &mut fetch => break cache_status?,
+                    _ = progress_interval.tick(), if report_progress => {
+                        if let Some(progress) =
+                            self.run_cache.transfers.download_progress(&self.hash)
+                        {
+                            terminal_output.status(&download_status(progress));
+                        }
+                    }
+                }
+            };
 
             let Some((cache_hit_metadata, restored_files)) = cache_status else {
                 if !matches!(
                     self.task_output_logs,
-                    OutputLogsMode::None | OutputLogsMode::ErrorsOnly
+                    OutputLogsMode::None | OutputLogsMode::ErrorsOnly | OutputLogsMode::SummaryLine
                 ) {
                     terminal_output.status(&format!(
                         "cache miss, executing {}",
@@ -259,6 +491,18 @@ impl TaskCache {
                 return Ok(None);
             };
 
+            // The cache only makes sure that files stay inside of the repository
+            if self.run_cache.strict {
+                let files =
+                    outside_package(&self.package_path, &validated_inclusions, &restored_files)?;
+                if !files.is_empty() {
+                    return Err(Error::OutsidePackage {
+                        task_id: self.task_id.to_string(),
+                        files,
+                    });
+                }
+            }
+
             self.expanded_outputs = restored_files;
 
             if let Some(daemon_client) = &mut self.daemon_client {
@@ -290,10 +534,24 @@ impl TaskCache {
             })
         };
 
-        let more_context = if has_changed_outputs {
-            ""
-        } else {
+        self.restore_log_file().await;
+        self.copy_log_to_log_dir();
+
+        // Local only outputs are never uploaded, so they aren't restored from the
+        // remote cache
+        let partial = matches!(
+            cache_status,
+            Some(CacheHitMetadata {
+                source: CacheSource::Remote,
+                ..
+            })
+        ) && !self.local_only_globs.inclusions.is_empty();
+        let more_context = if !has_changed_outputs {
             " (outputs already on disk)"
+        } else if partial {
+            " (local only outputs not restored)"
+        } else {
+            ""
         };
 
         match self.task_output_logs {
@@ -315,48 +573,182 @@ impl TaskCache {
             }
             // Note that if we're restoring from cache, the task succeeded
             // so we know we don't need to print anything for errors
-            OutputLogsMode::ErrorsOnly | OutputLogsMode::None => {}
+            OutputLogsMode::ErrorsOnly | OutputLogsMode::SummaryLine | OutputLogsMode::None => {}
         }
 
         Ok(cache_status)
     }
 
+    /// Restores the outputs that a remote executor saved to the cache after
+    /// running the task, and replays their logs. Returns `None` if the
+    /// executor didn't save any outputs.
+    pub async fn restore_executed_outputs(
+        &mut self,
+        terminal_output: &mut impl CacheOutput,
+    ) -> Result<Option<CacheHitMetadata>, Error> {
+        let Some((cache_hit_metadata, restored_files)) = self
+            .run_cache
+            .cache
+            .fetch(&self.run_cache.repo_root, &self.hash)
+            .await?
+        else {
+            return Ok(None);
+        };
+        self.expanded_outputs = restored_files;
+        self.restore_log_file().await;
+        self.copy_log_to_log_dir();
+        if matches!(
+            self.task_output_logs,
+            OutputLogsMode::Full | OutputLogsMode::NewOnly
+        ) {
+            self.replay_log_file(terminal_output)?;
+        }
+
+        Ok(Some(cache_hit_metadata))
+    }
+
+    // The outputs of a task can exclude its log, e.g. with `!**/*.log`, in which
+    // case the log is restored from its own artifact. Logs are only replayed,
+    // so failing to restore one doesn't fail the task.
+    async fn restore_log_file(&self) {
+        if self.log_file_path.exists() {
+            return;
+        }
+        match self
+            .run_cache
+            .cache
+            .fetch(&self.run_cache.repo_root, &log_artifact_hash(&self.hash))
+            .await
+        {
+            Ok(Some(_)) => {}
+            Ok(None) => debug!("no log artifact for {}", self.task_id),
+            Err(e) => warn!("unable to restore logs of {}: {e}", self.task_id),
+        }
+    }
+
+    // A cache hit doesn't run the task, so its restored log is copied instead
+    fn copy_log_to_log_dir(&self) {
+        let Some(log_dir_file) = &self.log_dir_file else {
+            return;
+        };
+        if !self.log_file_path.exists() {
+            return;
+        }
+        if let Err(e) = log_dir_file
+            .ensure_dir()
+            .and_then(|()| std::fs::copy(&self.log_file_path, log_dir_file).map(|_| ()))
+        {
+            warn!(
+                "unable to write logs of {} to {log_dir_file}: {e}",
+                self.task_id
+            );
+        }
+    }
+
+    /// Saves the task's outputs to the cache in the background. Returns a
+    /// receiver that's notified once they've been written to every cache, or
+    /// `None` if the outputs aren't cached.
+    #[tracing::instrument(skip_all, fields(task = %self.task_id))]
     pub async fn save_outputs(
         &mut self,
         duration: Duration,
         telemetry: &PackageTaskEventBuilder,
-    ) -> Result<(), Error> {
+    ) -> Result<Option<oneshot::Receiver<()>>, Error> {
         if self.caching_disabled || self.run_cache.writes_disabled {
-            return Ok(());
+            return Ok(None);
         }
 
         debug!("caching outputs: outputs: {:?}", &self.repo_relative_globs);
 
-        let validated_inclusions = self.repo_relative_globs.validated_inclusions()?;
-        let validated_exclusions = self.repo_relative_globs.validated_exclusions()?;
-        let files_to_be_cached = globwalk::globwalk(
+        let (validated_inclusions, validated_exclusions) = self.validated_output_globs()?;
+        let mut relative_paths = self.glob_outputs(&validated_inclusions, &validated_exclusions)?;
+        if let Some(ignored_files) = &self.ignored_files {
+            let exclusions = wax::any(validated_exclusions.iter().map(|glob| glob.as_str()))?;
+            let written_files = self
+                .run_cache
+                .scm
+                .ignored_files(&self.run_cache.repo_root, &self.package_path)?;
+            let inferred_outputs = written_files
+                .written_since(ignored_files)
+                .filter(|path| {
+                    !relative_paths.contains(path) && !exclusions.is_match(path.to_unix().as_str())
+                })
+                .cloned()
+                .collect::<Vec<_>>();
+            debug!("inferred outputs: {:?}", inferred_outputs);
+            relative_paths.extend(inferred_outputs);
+        }
+        let log_file = AnchoredSystemPathBuf::relative_path_between(
             &self.run_cache.repo_root,
-            &validated_inclusions,
-            &validated_exclusions,
-            globwalk::WalkType::All,
-        )?;
-
-        let mut relative_paths = files_to_be_cached
-            .into_iter()
-            .map(|path| {
-                AnchoredSystemPathBuf::relative_path_between(&self.run_cache.repo_root, &path)
-            })
-            .collect::<Vec<_>>();
+            &self.log_file_path,
+        );
+        // A task without outputs can remove its own log, e.g. by cleaning `.turbo`.
+        // An empty log is cached instead so that the artifact isn't empty.
+        if self.checkpoint && !self.log_file_path.exists() {
+            self.log_file_path
+                .ensure_dir()
+                .and_then(|()| self.log_file_path.create_with_contents(""))
+                .map_err(Error::LogFile)?;
+        }
+        if self.checkpoint && !relative_paths.contains(&log_file) {
+            relative_paths.push(log_file.clone());
+        }
         relative_paths.sort();
-        self.run_cache
-            .cache
-            .put(
-                self.run_cache.repo_root.clone(),
-                self.hash.clone(),
-                relative_paths.clone(),
-                duration.as_millis() as u64,
-            )
-            .await?;
+        if self.run_cache.strict {
+            let globs = missing_outputs(&validated_inclusions, &relative_paths, &log_file)?;
+            if !globs.is_empty() {
+                return Err(Error::MissingOutputs {
+                    task_id: self.task_id.to_string(),
+                    globs,
+                });
+            }
+        }
+        let local_only_files = self.local_only_files(&relative_paths)?;
+        if !local_only_files.is_empty() {
+            debug!("local only outputs: {:?}", local_only_files);
+        }
+        let repo_root = self.run_cache.repo_root.clone();
+        let duration_ms = duration.as_millis() as u64;
+        // The log is also cached as its own artifact, so that it can be replayed
+        // even if the outputs exclude it or are already on disk
+        if self.log_file_path.exists() {
+            self.run_cache
+                .cache
+                .put(
+                    repo_root.clone(),
+                    log_artifact_hash(&self.hash),
+                    vec![log_file],
+                    duration_ms,
+                )
+                .await?;
+        }
+        let written = if self.locked {
+            // Processes waiting on the lock restore the task from the local cache
+            // as soon as it's released, so the artifact has to be written by then
+            self.run_cache
+                .cache
+                .put_locally_and_wait(
+                    repo_root,
+                    self.hash.clone(),
+                    relative_paths.clone(),
+                    local_only_files,
+                    duration_ms,
+                    self.compression_level,
+                )
+                .await?
+        } else {
+            self.run_cache
+                .cache
+                .put_with_local_only(
+                    repo_root,
+                    self.hash.clone(),
+                    relative_paths.clone(),
+                    local_only_files,
+                    duration_ms,
+                    self.compression_level,
+                )
+                .await?
+        };
 
         if let Some(daemon_client) = self.daemon_client.as_mut() {
             let notify_result = daemon_client
@@ -364,7 +756,7 @@ impl TaskCache {
                     self.hash.to_string(),
                     &validated_inclusions,
                     &validated_exclusions,
-                    duration.as_millis() as u64,
+                    duration_ms,
                 )
                 .await
                 .map_err(Error::from);
@@ -378,12 +770,202 @@ impl TaskCache {
 
         self.expanded_outputs = relative_paths;
 
-        Ok(())
+        Ok(Some(written))
     }
 
     pub fn expanded_outputs(&self) -> &[AnchoredSystemPathBuf] {
         &self.expanded_outputs
     }
+
+    /// The files on disk that match the task's outputs. A dry run uses these
+    /// in place of the outputs a real run would restore or save. Outputs
+    /// inferred from ignored files aren't included as the task doesn't run.
+    pub fn outputs_on_disk(&self) -> Result<Vec<AnchoredSystemPathBuf>, Error> {
+        let (validated_inclusions, validated_exclusions) = self.validated_output_globs()?;
+        let mut relative_paths = self.glob_outputs(&validated_inclusions, &validated_exclusions)?;
+        relative_paths.sort();
+        Ok(relative_paths)
+    }
+
+    fn validated_output_globs(&self) -> Result<(Vec<ValidatedGlob>, Vec<ValidatedGlob>), Error> {
+        let mut validated_inclusions = self.repo_relative_globs.validated_inclusions()?;
+        let validated_exclusions = self.repo_relative_globs.validated_exclusions()?;
+        if self.follow_symlinks {
+            validated_inclusions = validated_inclusions
+                .into_iter()
+                .map(|glob| glob.follow_links())
+                .collect();
+        }
+        Ok((validated_inclusions, validated_exclusions))
+    }
+
+    fn glob_outputs(
+        &self,
+        validated_inclusions: &[ValidatedGlob],
+        validated_exclusions: &[ValidatedGlob],
+    ) -> Result<Vec<AnchoredSystemPathBuf>, Error> {
+        // A followed link is walked like a directory, so only files are
+        // collected. Otherwise the link itself would be cached along with the
+        // files inside it.
+        let walk_type = if self.follow_symlinks {
+            globwalk::WalkType::Files
+        } else {
+            globwalk::WalkType::All
+        };
+        let files = globwalk::globwalk(
+            &self.run_cache.repo_root,
+            validated_inclusions,
+            validated_exclusions,
+            walk_type,
+        )?;
+        Ok(files
+            .into_iter()
+            .map(|path| {
+                AnchoredSystemPathBuf::relative_path_between(&self.run_cache.repo_root, &path)
+            })
+            .collect())
+    }
+
+    // The files being cached that match the task's `localOnlyOutputs`
+    fn local_only_files(
+        &self,
+        files: &[AnchoredSystemPathBuf],
+    ) -> Result<Vec<AnchoredSystemPathBuf>, Error> {
+        if self.local_only_globs.inclusions.is_empty() {
+            return Ok(Vec::new());
+        }
+        let inclusions = self.local_only_globs.validated_inclusions()?;
+        let exclusions = self.local_only_globs.validated_exclusions()?;
+        let inclusions = wax::any(inclusions.iter().map(|glob| glob.as_str()))?;
+        let exclusions = wax::any(exclusions.iter().map(|glob| glob.as_str()))?;
+        Ok(files
+            .iter()
+            .filter(|file| {
+                let file = file.to_unix();
+                inclusions.is_match(file.as_str()) && !exclusions.is_match(file.as_str())
+            })
+            .cloned()
+            .collect())
+    }
+}
+
+// The output globs that don't match any of the files being cached. The glob
+// of the log file is skipped, since the task doesn't write it.
+// The key of the artifact that only holds a task's log
+fn log_artifact_hash(hash: &str) -> String {
+    format!("{hash}-log")
+}
+
+fn missing_outputs(
+    inclusions: &[ValidatedGlob],
+    files: &[AnchoredSystemPathBuf],
+    log_file: &AnchoredSystemPath,
+) -> Result<Vec<String>, Error> {
+    let files = files.iter().map(|file| file.to_unix()).collect::<Vec<_>>();
+    let log_file = log_file.to_unix();
+    let mut missing = Vec::new();
+    for inclusion in inclusions {
+        let glob = wax::Glob::new(inclusion.as_str())?;
+        if !glob.is_match(log_file.as_str())
+            && !files.iter().any(|file| glob.is_match(file.as_str()))
+        {
+            missing.push(inclusion.as_str().to_string());
+        }
+    }
+    Ok(missing)
+}
+
+// The restored files that are neither in the package's directory nor matched
+// by one of the task's outputs, which may point outside of it.
+fn outside_package(
+    package_path: &AnchoredSystemPath,
+    inclusions: &[ValidatedGlob],
+    files: &[AnchoredSystemPathBuf],
+) -> Result<Vec<String>, Error> {
+    let globs = inclusions
+        .iter()
+        .map(|inclusion| wax::Glob::new(inclusion.as_str()))
+        .collect::<Result<Vec<_>, _>>()?;
+    Ok(files
+        .iter()
+        .filter(|file| !file.as_path().starts_with(package_path.as_path()))
+        .map(|file| file.to_unix())
+        .filter(|file| !globs.iter().any(|glob| glob.is_match(file.as_str())))
+        .map(|file| file.to_string())
+        .collect())
+}
+
+// Root tasks are written to the top of the log directory and package tasks to
+// a directory for their package. Scoped names like `@acme/ui` are nested.
+fn task_log_dir_file(log_dir: &AbsoluteSystemPath, task_id: &TaskId) -> AbsoluteSystemPathBuf {
+    let file_name = format!("{}.log", task_id.task().replace(':', "$colon$"));
+    let mut components = Vec::new();
+    if task_id.package() != ROOT_PKG_NAME {
+        components.extend(task_id.package().split('/'));
+    }
+    components.push(&file_name);
+    log_dir.join_components(&components)
+}
+
+// Whether `--force` lists the task, either with its package or on its own to
+// force it in every package
+fn is_forced(forced_tasks: &[String], task_id: &TaskId) -> bool {
+    forced_tasks
+        .iter()
+        .any(|forced| forces_task(forced, task_id))
+}
+
+/// Whether a task given to `--force` matches `task_id`
+pub(crate) fn forces_task(forced: &str, task_id: &TaskId) -> bool {
+    match forced.split_once('#') {
+        Some((package, task)) => task_id.package() == package && task_id.task() == task,
+        None => task_id.task() == forced,
+    }
+}
+
+fn download_status(progress: TransferProgress) -> String {
+    let downloaded = match progress.size {
+        Some(size) => format!(
+            "{} of {}",
+            format_bytes(progress.bytes as f64, "B"),
+            format_bytes(size as f64, "B")
+        ),
+        None => format_bytes(progress.bytes as f64, "B"),
+    };
+    format!(
+        "downloading from remote cache, {downloaded} ({})",
+        format_bytes(progress.bytes_per_second, "B/s")
+    )
+}
+
+/// Describes the uploads that are in flight for the spinner that's shown while
+/// they finish
+pub(crate) fn upload_status(uploads: usize, progress: TransferProgress) -> String {
+    let uploaded = match progress.size {
+        Some(size) => format!(
+            "{} remaining",
+            format_bytes(size.saturating_sub(progress.bytes) as f64, "B")
+        ),
+        None => format!("{} uploaded", format_bytes(progress.bytes as f64, "B")),
+    };
+    format!(
+        "...Finishing writing to cache... ({uploaded} in {uploads} {}, {})",
+        if uploads == 1 {
+            "artifact"
+        } else {
+            "artifacts"
+        },
+        format_bytes(progress.bytes_per_second, "B/s")
+    )
+}
+
+/// Formats an amount of bytes for humans, e.g. `12.30MB`
+pub fn format_bytes(bytes: f64, units: &str) -> String {
+    human_format::Formatter::new()
+        .with_decimals(2)
+        .with_separator("")
+        .with_units(units)
+        .format(bytes)
 }
 
 #[derive(Clone)]
@@ -477,3 +1059,94 @@ fn fallible_write(mut writer: impl Write, message: &str) {
         error!("cannot write to logs: {:?}", err);
     }
 }
+
+#[cfg(test)]
+mod test {
+    use std::str::FromStr;
+
+    use globwalk::ValidatedGlob;
+    use test_case::test_case;
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+
+    use super::{is_forced, missing_outputs, outside_package, task_log_dir_file};
+    use crate::run::task_id::TaskId;
+
+    #[test_case("web", "build", true ; "package task")]
+    #[test_case("docs", "build", false ; "other package")]
+    #[test_case("docs", "lint", true ; "task in every package")]
+    #[test_case("//", "build", true ; "root task")]
+    fn test_is_forced(package: &str, task: &str, expected: bool) {
+        let forced_tasks = [
+            "web#build".to_string(),
+            "lint".to_string(),
+            "//#build".to_string(),
+        ];
+        assert_eq!(
+            is_forced(&forced_tasks, &TaskId::new(package, task)),
+            expected
+        );
+    }
+
+    #[test_case("//", "build", &["build.log"] ; "root task")]
+    #[test_case("web", "build", &["web", "build.log"] ; "package task")]
+    #[test_case("@acme/ui", "build", &["@acme", "ui", "build.log"] ; "scoped package")]
+    #[test_case("web", "build:prod", &["web", "build$colon$prod.log"] ; "task with colon")]
+    fn test_task_log_dir_file(package: &str, task: &str, expected: &[&str]) {
+        let log_dir = AbsoluteSystemPathBuf::new(if cfg!(windows) {
+            r"C:\repo\.turbo\logs"
+        } else {
+            "/repo/.turbo/logs"
+        })
+        .unwrap();
+
+        assert_eq!(
+            task_log_dir_file(&log_dir, &TaskId::new(package, task)),
+            log_dir.join_components(expected)
+        );
+    }
+
+    #[test]
+    fn test_missing_outputs() {
+        let inclusions = ["web/.turbo/turbo-build.log", "web/dist/**", "web/types/**"]
+            .into_iter()
+            .map(|glob| ValidatedGlob::from_str(glob).unwrap())
+            .collect::<Vec<_>>();
+        let files = ["web/dist/index.js"]
+            .into_iter()
+            .map(|file| AnchoredSystemPathBuf::from_raw(file).unwrap())
+            .collect::<Vec<_>>();
+        let log_file = AnchoredSystemPathBuf::from_raw("web/.turbo/turbo-build.log").unwrap();
+
+        assert_eq!(
+            missing_outputs(&inclusions, &files, &log_file).unwrap(),
+            vec!["web/types/**".to_string()]
+        );
+    }
+
+    #[test]
+    fn test_outside_package() {
+        let package_path = AnchoredSystemPathBuf::from_raw("apps/web").unwrap();
+        let inclusions = ["apps/web/dist/**", "packages/shared/dist/**"]
+            .into_iter()
+            .map(|glob| ValidatedGlob::from_str(glob).unwrap())
+            .collect::<Vec<_>>();
+        let files = [
+            "apps/web/dist/index.js",
+            "apps/web/.env",
+            "packages/shared/dist/index.js",
+            "apps/web-admin/dist/index.js",
+            "package.json",
+        ]
+        .into_iter()
+        .map(|file| AnchoredSystemPathBuf::from_raw(file).unwrap())
+        .collect::<Vec<_>>();
+
+        assert_eq!(
+            outside_package(&package_path, &inclusions, &files).unwrap(),
+            vec![
+                "apps/web-admin/dist/index.js".to_string(),
+                "package.json".to_string()
+            ]
+        );
+    }
+}
@@ -0,0 +1,217 @@
+//! Finds the environment variables that tasks read without declaring them,
+//! which lets them change a task's output without changing its hash. Node.js
+//! processes are preloaded with a shim that records the variables read from
+//! `process.env`, including by any Node.js processes they spawn.
+
+use std::{collections::BTreeSet, io};
+
+use tracing::warn;
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
+use turborepo_env::EnvironmentVariableMap;
+
+use crate::process::Command;
+
+// Environment variable key that enables the shim and sets where it records the
+// variables that were read
+const ENV_AUDIT_FILE_ENV_KEY: &str = "TURBO_ENV_AUDIT_FILE";
+const ENV_AUDIT_DIR: [&str; 2] = [".turbo", "env-audit"];
+const PRELOAD_NAME: &str = "preload.cjs";
+
+// Copies of `process.env`, such as the environment passed to child processes,
+// enumerate every variable, so reads made while enumerating aren't recorded
+const PRELOAD: &str = r#"const file = process.env.TURBO_ENV_AUDIT_FILE;
+if (file) {
+  const fs = require("fs");
+  const read = new Set();
+  let enumerating = false;
+  const record = (key) => {
+    if (typeof key === "string" && !enumerating) {
+      read.add(key);
+    }
+  };
+  process.env = new Proxy(process.env, {
+    get(target, key) {
+      record(key);
+      return Reflect.get(target, key);
+    },
+    has(target, key) {
+      record(key);
+      return Reflect.has(target, key);
+    },
+    ownKeys(target) {
+      if (!enumerating) {
+        enumerating = true;
+        queueMicrotask(() => {
+          enumerating = false;
+        });
+      }
+      return Reflect.ownKeys(target);
+    },
+  });
+  process.on("exit", () => {
+    try {
+      fs.appendFileSync(file, [...read].map((key) => key + "\n").join(""));
+    } catch {}
+  });
+}
+"#;
+
+#[derive(Debug, Clone)]
+pub struct EnvAudit {
+    dir: AbsoluteSystemPathBuf,
+    preload: AbsoluteSystemPathBuf,
+}
+
+impl EnvAudit {
+    /// Writes the preload shim to `.turbo/env-audit`
+    pub fn new(repo_root: &AbsoluteSystemPath) -> io::Result<Self> {
+        let dir = repo_root.join_components(&ENV_AUDIT_DIR);
+        dir.create_dir_all()?;
+        let preload = dir.join_component(PRELOAD_NAME);
+        preload.create_with_contents(PRELOAD)?;
+        Ok(Self { dir, preload })
+    }
+
+    /// Audits the task with `task_hash`, which declares the variables in
+    /// `declared`
+    pub fn task(&self, task_hash: &str, declared: &EnvironmentVariableMap) -> TaskEnvAudit {
+        TaskEnvAudit {
+            file: self.dir.join_component(&format!("{task_hash}.txt")),
+            preload: self.preload.clone(),
+            declared: declared.keys().cloned().collect(),
+        }
+    }
+}
+
+#[derive(Debug, Clone)]
+pub struct TaskEnvAudit {
+    file: AbsoluteSystemPathBuf,
+    preload: AbsoluteSystemPathBuf,
+    declared: BTreeSet<String>,
+}
+
+impl TaskEnvAudit {
+    /// Adds the shim to the `NODE_OPTIONS` of the task's command
+    pub fn prepare(&self, cmd: &mut Command, execution_env: &EnvironmentVariableMap) {
+        // Any variables recorded by a previous run of the task are stale
+        if let Err(err) = self.file.remove_file() {
+            if err.kind() != io::ErrorKind::NotFound {
+                warn!("unable to remove {}: {err}", self.file);
+            }
+        }
+        let require = format!(
+            "--require \"{}\"",
+            self.preload.as_str().replace('\\', "\\\\")
+        );
+        let node_options = match execution_env.get("NODE_OPTIONS") {
+            Some(node_options) if !node_options.is_empty() => {
+                format!("{node_options} {require}")
+            }
+            _ => require,
+        };
+        cmd.env("NODE_OPTIONS", node_options);
+        cmd.env(ENV_AUDIT_FILE_ENV_KEY, self.file.as_str());
+    }
+
+    /// Returns the variables that the task read which are set in its
+    /// environment, but aren't declared
+    pub fn undeclared(&self, execution_env: &EnvironmentVariableMap) -> io::Result<Vec<String>> {
+        let contents = match self.file.read_to_string() {
+            Ok(contents) => contents,
+            // Nothing was recorded, the task might not have run Node.js
+            Err(err) if err.kind() == io::ErrorKind::NotFound => return Ok(Vec::new()),
+            Err(err) => return Err(err),
+        };
+        Ok(undeclared(&contents, execution_env, &self.declared))
+    }
+}
+
+fn undeclared(
+    contents: &str,
+    execution_env: &EnvironmentVariableMap,
+    declared: &BTreeSet<String>,
+) -> Vec<String> {
+    contents
+        .lines()
+        .filter(|key| execution_env.contains_key(*key) && !declared.contains(*key))
+        .map(|key| key.to_string())
+        .collect::<BTreeSet<_>>()
+        .into_iter()
+        .collect()
+}
+
+#[cfg(test)]
+mod test {
+    use std::collections::BTreeSet;
+
+    use turbopath::AbsoluteSystemPathBuf;
+    use turborepo_env::EnvironmentVariableMap;
+
+    use super::{undeclared, EnvAudit};
+    use crate::process::Command;
+
+    fn find_script_dir() -> AbsoluteSystemPathBuf {
+        let cwd = AbsoluteSystemPathBuf::cwd().unwrap();
+        let mut root = cwd;
+        while !root.join_component(".git").exists() {
+            root = root.parent().unwrap().to_owned();
+        }
+        root.join_components(&["crates", "turborepo-lib", "test", "scripts"])
+    }
+
+    #[test]
+    fn test_undeclared() {
+        let mut execution_env = EnvironmentVariableMap::default();
+        for key in ["API_URL", "NODE_ENV", "PATH", "SECRET"] {
+            execution_env.insert(key.to_string(), "value".to_string());
+        }
+        let declared = BTreeSet::from(["API_URL".to_string(), "PATH".to_string()]);
+
+        // Variables that aren't set can't affect the output
+        let contents = "SECRET\nPATH\nNODE_ENV\nMISSING\nSECRET\n";
+
+        assert_eq!(
+            undeclared(contents, &execution_env, &declared),
+            vec!["NODE_ENV".to_string(), "SECRET".to_string()]
+        );
+    }
+
+    #[tokio::test]
+    async fn test_preload() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let mut execution_env = EnvironmentVariableMap::default();
+        for key in [
+            "API_URL",
+            "CHILD_VAR",
+            "DEBUG",
+            "DECLARED",
+            "LATER",
+            "UNUSED",
+        ] {
+            execution_env.insert(key.to_string(), "value".to_string());
+        }
+        let mut declared = EnvironmentVariableMap::default();
+        declared.insert("DECLARED".to_string(), "value".to_string());
+
+        let task_env_audit = EnvAudit::new(&repo_root).unwrap().task("abc", &declared);
+        let script = find_script_dir().join_component("env_audit.js");
+        let mut cmd = Command::new("node");
+        cmd.args([script.as_std_path()]);
+        cmd.envs(execution_env.iter());
+        task_env_audit.prepare(&mut cmd, &execution_env);
+        let status = tokio::process::Command::from(cmd).status().await.unwrap();
+        assert!(status.success());
+
+        // `UNUSED` is only enumerated when the environment is copied
+        assert_eq!(
+            task_env_audit.undeclared(&execution_env).unwrap(),
+            vec![
+                "API_URL".to_string(),
+                "CHILD_VAR".to_string(),
+                "DEBUG".to_string(),
+                "LATER".to_string(),
+            ]
+        );
+    }
+}
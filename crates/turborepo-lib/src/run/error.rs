@@ -16,6 +16,12 @@ use crate::{
 pub enum Error {
     #[error("invalid task configuration")]
     EngineValidation(#[related] Vec<ValidateError>),
+    #[error("--interactive was given {0}, which isn't a task in this run")]
+    #[diagnostic(help("pass the full task id, e.g. web#dev"))]
+    UnknownInteractiveTask(String),
+    #[error("--force was given {0}, which isn't a task in this run")]
+    #[diagnostic(help("pass a task name, e.g. build, or a task id, e.g. web#build"))]
+    UnknownForcedTask(String),
     #[error(transparent)]
     Graph(#[from] graph_visualizer::Error),
     #[error(transparent)]
@@ -28,6 +34,8 @@ pub enum Error {
     #[error(transparent)]
     PackageJson(#[from] turborepo_repository::package_json::Error),
     #[error(transparent)]
+    Engines(#[from] turborepo_repository::engines::Error),
+    #[error(transparent)]
     PackageManager(#[from] turborepo_repository::package_manager::Error),
     #[error(transparent)]
     #[diagnostic(transparent)]
@@ -50,8 +58,57 @@ pub enum Error {
     Visitor(#[from] task_graph::VisitorError),
     #[error("error registering signal handler: {0}")]
     SignalHandler(std::io::Error),
+    #[error("unable to set up environment variable auditing: {0}")]
+    EnvAudit(std::io::Error),
+    #[error("--experimental-executor requires a remote cache that can be read from")]
+    #[diagnostic(help("enable remote caching with `turbo login` and `turbo link`"))]
+    ExecutorWithoutRemoteCache,
+    #[error("--experimental-executor requires a commit for the executor to check out")]
+    #[diagnostic(help("run turbo in a git repository with at least one commit"))]
+    ExecutorWithoutCommit,
+    #[error("unable to check for uncommitted changes before sending tasks to the executor")]
+    ExecutorChanges(#[source] turborepo_scm::Error),
+    #[error("--experimental-executor can't be used with {0} uncommitted changes")]
+    #[diagnostic(help(
+        "commit or stash your changes, the executor only sees the files of the current commit"
+    ))]
+    ExecutorUncommittedChanges(usize),
+    #[error("--experimental-executor won't send tasks to {0} without TLS")]
+    #[diagnostic(help(
+        "use an https:// URL, or set TURBO_EXECUTOR_ALLOW_HTTP=1 to send tasks and the executor \
+         token in plain text"
+    ))]
+    ExecutorWithoutTls(String),
+    #[error(
+        "run is predicted to take {predicted}, which is longer than the time budget of {budget}"
+    )]
+    #[diagnostic(help("use `--time-budget-mode=warn` to run the tasks anyway"))]
+    TimeBudgetExceeded { predicted: String, budget: String },
     #[error(transparent)]
     Daemon(#[from] daemon::DaemonError),
     #[error(transparent)]
     Tui(#[from] tui::Error),
 }
+
+impl Error {
+    /// Identifies the error in `--error-format=json` output
+    pub fn json_code(&self) -> Option<&'static str> {
+        match self {
+            Error::EngineValidation(_) => Some("invalid_task_configuration"),
+            Error::Builder(error) => error.json_code(),
+            _ => None,
+        }
+    }
+
+    /// The errors that make up this one, along with their codes
+    pub fn json_related(&self) -> Vec<(&dyn Diagnostic, Option<&'static str>)> {
+        match self {
+            Error::EngineValidation(errors) => errors
+                .iter()
+                .map(|error| (error as &dyn Diagnostic, error.json_code()))
+                .collect(),
+            Error::Builder(error) => error.json_related(),
+            _ => Vec::new(),
+        }
+    }
+}
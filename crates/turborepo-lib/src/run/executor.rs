@@ -0,0 +1,280 @@
+//! Dispatches tasks to remote workers with `--experimental-executor`. Each
+//! task that's ready to run is sent to the executor, which checks out the
+//! same commit, runs the task and saves its outputs to the remote cache under
+//! the task's hash. Once the executor responds, the outputs are restored from
+//! the remote cache as if the task had been a cache hit.
+
+use std::collections::BTreeMap;
+
+use serde::{Deserialize, Serialize};
+use thiserror::Error;
+use tracing::debug;
+use turborepo_env::EnvironmentVariableMap;
+
+use crate::run::task_id::TaskId;
+
+const TASKS_PATH: &str = "v1/tasks";
+
+/// The variable with the token that requests to the executor are
+/// authenticated with
+pub const EXECUTOR_TOKEN_ENV: &str = "TURBO_EXECUTOR_TOKEN";
+/// The variable that allows sending tasks to an executor over plain http
+pub const EXECUTOR_ALLOW_HTTP_ENV: &str = "TURBO_EXECUTOR_ALLOW_HTTP";
+
+#[derive(Debug, Error)]
+pub enum Error {
+    #[error("failed to send task to executor at {url}: {source}")]
+    Request {
+        url: String,
+        #[source]
+        source: reqwest::Error,
+    },
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ExecuteRequest {
+    task_id: String,
+    package: String,
+    task: String,
+    // Path of the package relative to the repository root
+    directory: String,
+    hash: String,
+    // The commit the executor checks out. Tasks are only sent when there are
+    // no uncommitted changes, so the executor sees the same files.
+    git_sha: String,
+    args: Vec<String>,
+    env: BTreeMap<String, String>,
+}
+
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct ExecuteResponse {
+    pub exit_code: i32,
+    // The task's logs, which are only shown if it failed. A task that
+    // succeeded has its logs restored from the remote cache.
+    #[serde(default)]
+    pub logs: String,
+}
+
+#[derive(Debug, Clone)]
+pub struct RemoteExecutor {
+    client: reqwest::Client,
+    url: String,
+    git_sha: String,
+    token: Option<String>,
+}
+
+impl RemoteExecutor {
+    /// `client` should trust the same certificate authorities as the remote
+    /// cache, and shouldn't time out requests since tasks take a while to
+    /// run. `token` is sent as a bearer token with every request.
+    pub fn new(
+        endpoint: &str,
+        git_sha: String,
+        client: reqwest::Client,
+        token: Option<String>,
+    ) -> Self {
+        Self {
+            client,
+            url: format!("{}/{TASKS_PATH}", endpoint.trim_end_matches('/')),
+            git_sha,
+            token,
+        }
+    }
+
+    /// Returns the request for a task. Only the variables in `env` are sent,
+    /// which should be the ones the task declares, so that pass through
+    /// secrets and the rest of the machine's environment stay local.
+    pub fn task(
+        &self,
+        task_id: &TaskId,
+        directory: String,
+        hash: &str,
+        args: Vec<String>,
+        env: &EnvironmentVariableMap,
+    ) -> RemoteTask {
+        RemoteTask {
+            executor: self.clone(),
+            request: ExecuteRequest {
+                task_id: task_id.to_string(),
+                package: task_id.package().to_string(),
+                task: task_id.task().to_string(),
+                directory,
+                hash: hash.to_string(),
+                git_sha: self.git_sha.clone(),
+                args,
+                // Sorted so that requests are deterministic
+                env: env
+                    .iter()
+                    .map(|(key, value)| (key.clone(), value.clone()))
+                    .collect(),
+            },
+        }
+    }
+}
+
+/// Returns whether requests to `endpoint` are encrypted. Tasks include their
+/// environment and the executor token, so they shouldn't be sent in plain
+/// text unless the user allows it.
+pub fn uses_tls(endpoint: &str) -> bool {
+    endpoint
+        .get(.."https://".len())
+        .is_some_and(|scheme| scheme.eq_ignore_ascii_case("https://"))
+}
+
+/// A task that's run by the executor instead of locally
+#[derive(Debug, Clone)]
+pub struct RemoteTask {
+    executor: RemoteExecutor,
+    request: ExecuteRequest,
+}
+
+impl RemoteTask {
+    /// Sends the task to the executor and waits for it to finish. Tasks are
+    /// expected to take a while, so the request doesn't time out.
+    pub async fn execute(&self) -> Result<ExecuteResponse, Error> {
+        let url = &self.executor.url;
+        debug!("sending {} to executor at {url}", self.request.task_id);
+        let to_error = |source| Error::Request {
+            url: url.clone(),
+            source,
+        };
+        let mut request = self.executor.client.post(url).json(&self.request);
+        if let Some(token) = &self.executor.token {
+            request = request.bearer_auth(token);
+        }
+        request
+            .send()
+            .await
+            .and_then(|response| response.error_for_status())
+            .map_err(to_error)?
+            .json()
+            .await
+            .map_err(to_error)
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use std::sync::{Arc, Mutex};
+
+    use axum::{http::HeaderMap, routing::post, Json, Router};
+    use serde_json::{json, Value};
+    use test_case::test_case;
+
+    use super::*;
+
+    #[test_case("https://executor.example.com", true ; "https")]
+    #[test_case("HTTPS://executor.example.com", true ; "uppercase scheme")]
+    #[test_case("http://executor.example.com", false ; "http")]
+    #[test_case("executor.example.com", false ; "no scheme")]
+    fn test_uses_tls(endpoint: &str, expected: bool) {
+        assert_eq!(uses_tls(endpoint), expected);
+    }
+
+    #[test]
+    fn test_request_serialization() {
+        let mut execution_env = EnvironmentVariableMap::default();
+        execution_env.insert("NODE_ENV".to_string(), "production".to_string());
+        execution_env.insert("API_URL".to_string(), "https://example.com".to_string());
+        let executor = RemoteExecutor::new(
+            "https://executor.example.com/",
+            "c0ffee".into(),
+            reqwest::Client::new(),
+            None,
+        );
+        let task = executor.task(
+            &TaskId::new("web", "build"),
+            "apps/web".to_string(),
+            "0123456789abcdef",
+            vec!["--verbose".to_string()],
+            &execution_env,
+        );
+
+        assert_eq!(task.executor.url, "https://executor.example.com/v1/tasks");
+        assert_eq!(
+            serde_json::to_value(&task.request).unwrap(),
+            json!({
+                "taskId": "web#build",
+                "package": "web",
+                "task": "build",
+                "directory": "apps/web",
+                "hash": "0123456789abcdef",
+                "gitSha": "c0ffee",
+                "args": ["--verbose"],
+                "env": {
+                    "API_URL": "https://example.com",
+                    "NODE_ENV": "production",
+                },
+            })
+        );
+    }
+
+    #[test]
+    fn test_response_deserialization() {
+        let response: ExecuteResponse = serde_json::from_str(r#"{"exitCode": 0}"#).unwrap();
+        assert_eq!(
+            response,
+            ExecuteResponse {
+                exit_code: 0,
+                logs: String::new(),
+            }
+        );
+    }
+
+    #[tokio::test]
+    async fn test_execute() -> anyhow::Result<()> {
+        let requests = Arc::new(Mutex::new(Vec::new()));
+        let received = requests.clone();
+        let app = Router::new().route(
+            "/v1/tasks",
+            post(|headers: HeaderMap, Json(body): Json<Value>| async move {
+                let authorization = headers
+                    .get("authorization")
+                    .and_then(|value| value.to_str().ok())
+                    .map(|value| value.to_string());
+                received.lock().unwrap().push((authorization, body));
+                Json(json!({ "exitCode": 1, "logs": "failed" }))
+            }),
+        );
+        let listener = std::net::TcpListener::bind("127.0.0.1:0")?;
+        let port = listener.local_addr()?.port();
+        tokio::spawn(axum::Server::from_tcp(listener)?.serve(app.into_make_service()));
+
+        let mut env = EnvironmentVariableMap::default();
+        env.insert("NODE_ENV".to_string(), "production".to_string());
+        let executor = RemoteExecutor::new(
+            &format!("http://localhost:{port}"),
+            "c0ffee".into(),
+            reqwest::Client::new(),
+            Some("secret-token".into()),
+        );
+        let response = executor
+            .task(
+                &TaskId::new("web", "build"),
+                "apps/web".to_string(),
+                "0123456789abcdef",
+                Vec::new(),
+                &env,
+            )
+            .execute()
+            .await?;
+
+        assert_eq!(
+            response,
+            ExecuteResponse {
+                exit_code: 1,
+                logs: "failed".to_string(),
+            }
+        );
+        let requests = requests.lock().unwrap();
+        assert_eq!(requests.len(), 1);
+        let (authorization, body) = &requests[0];
+        assert_eq!(authorization.as_deref(), Some("Bearer secret-token"));
+        assert_eq!(body["gitSha"], "c0ffee");
+        assert_eq!(body["env"], json!({ "NODE_ENV": "production" }));
+
+        Ok(())
+    }
+}
@@ -8,14 +8,16 @@ use globwalk::{ValidatedGlob, WalkType};
 use itertools::Itertools;
 use thiserror::Error;
 use tracing::debug;
-use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, RelativeUnixPathBuf};
-use turborepo_env::{get_global_hashable_env_vars, DetailedMap, EnvironmentVariableMap};
+use turbopath::{
+    AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, RelativeUnixPathBuf,
+};
+use turborepo_env::{dotenv, get_global_hashable_env_vars, DetailedMap, EnvironmentVariableMap};
 use turborepo_lockfiles::Lockfile;
 use turborepo_repository::{
-    package_graph::PackageInfo,
+    package_graph::{PackageInfo, WorkspaceProvider},
     package_manager::{self, PackageManager},
 };
-use turborepo_scm::SCM;
+use turborepo_scm::{TurboIgnore, SCM};
 
 use crate::{
     cli::EnvMode,
@@ -38,6 +40,8 @@ pub enum Error {
     Scm(#[from] turborepo_scm::Error),
     #[error(transparent)]
     PackageManager(#[from] turborepo_repository::package_manager::Error),
+    #[error(transparent)]
+    Lockfile(#[from] turborepo_lockfiles::Error),
 }
 
 #[derive(Debug)]
@@ -54,10 +58,14 @@ pub struct GlobalHashableInputs<'a> {
     pub pass_through_env: Option<&'a [String]>,
     pub env_mode: EnvMode,
     pub framework_inference: bool,
+    pub cache_key_prefix: Option<&'a str>,
     pub env_at_execution_start: &'a EnvironmentVariableMap,
+    // Variables from the files listed in `globalDotEnv`
+    pub dot_env: EnvironmentVariableMap,
 }
 
 #[allow(clippy::too_many_arguments)]
+#[tracing::instrument(skip_all)]
 pub fn get_global_hash_inputs<'a, L: ?Sized + Lockfile>(
     root_external_dependencies_hash: Option<&'a str>,
     root_internal_dependencies_hash: Option<&'a str>,
@@ -65,12 +73,15 @@ pub fn get_global_hash_inputs<'a, L: ?Sized + Lockfile>(
     root_path: &AbsoluteSystemPath,
     package_manager: &PackageManager,
     lockfile: Option<&L>,
+    workspace_providers: &[WorkspaceProvider],
     global_file_dependencies: &'a [String],
+    global_dot_env: &[String],
     env_at_execution_start: &'a EnvironmentVariableMap,
     global_env: &'a [String],
     global_pass_through_env: Option<&'a [String]>,
     env_mode: EnvMode,
     framework_inference: bool,
+    cache_key_prefix: Option<&'a str>,
     hasher: &SCM,
 ) -> Result<GlobalHashableInputs<'a>, Error> {
     let engines = root_package.package_json.engines();
@@ -86,9 +97,30 @@ pub fn get_global_hash_inputs<'a, L: ?Sized + Lockfile>(
     let mut global_deps =
         collect_global_deps(package_manager, root_path, global_file_dependencies)?;
 
-    if lockfile.is_none() {
-        global_deps.insert(root_path.join_component("package.json"));
-        let lockfile_path = package_manager.lockfile_path(root_path);
+    match lockfile {
+        // Editing a patch doesn't change the lockfile until it's applied by an
+        // install, so the patch files themselves are hashed
+        Some(lockfile) => {
+            for patch in lockfile.patches()? {
+                let patch_path = root_path.join_unix_path(&patch);
+                if patch_path.exists() {
+                    global_deps.insert(patch_path);
+                }
+            }
+        }
+        None => {
+            global_deps.insert(root_path.join_component("package.json"));
+            let lockfile_path = package_manager.lockfile_path(root_path);
+            if lockfile_path.exists() {
+                global_deps.insert(lockfile_path);
+            }
+        }
+    }
+    // The external dependencies of packages from workspace providers aren't
+    // parsed, so their lockfiles are hashed like an unparsable package manager
+    // lockfile
+    for provider in workspace_providers {
+        let lockfile_path = root_path.join_component(provider.lockfile());
         if lockfile_path.exists() {
             global_deps.insert(lockfile_path);
         }
@@ -101,6 +133,14 @@ pub fn get_global_hash_inputs<'a, L: ?Sized + Lockfile>(
 
     let global_file_hash_map = hasher.get_hashes_for_files(root_path, &global_deps_paths, false)?;
 
+    let dot_env = dotenv::load(
+        &global_dot_env
+            .iter()
+            .map(|file| root_path.as_std_path().join(file))
+            .collect::<Vec<_>>(),
+    )?;
+    debug!("global dotenv vars {:?}", dot_env.names());
+
     debug!(
         "external deps hash: {}",
         root_external_dependencies_hash.unwrap_or("no hash (single package)")
@@ -117,7 +157,9 @@ pub fn get_global_hash_inputs<'a, L: ?Sized + Lockfile>(
         pass_through_env: global_pass_through_env,
         env_mode,
         framework_inference,
+        cache_key_prefix,
         env_at_execution_start,
+        dot_env,
     })
 }
 
@@ -173,12 +215,17 @@ fn collect_global_deps(
         .map(|s| ValidatedGlob::from_str(&s.replace(":", "")))
         .collect::<Result<Vec<_>, _>>()?;
 
-    Ok(globwalk::globwalk(
-        root_path,
-        &inclusions,
-        &exclusions,
-        WalkType::Files,
-    )?)
+    let files = globwalk::globwalk(root_path, &inclusions, &exclusions, WalkType::Files)?;
+    // Global dependencies exclude the same files as package inputs
+    let turbo_ignore = TurboIgnore::load(root_path, AnchoredSystemPath::empty())?;
+    Ok(files
+        .into_iter()
+        .filter(|file| {
+            root_path
+                .anchor(file)
+                .map_or(true, |path| !turbo_ignore.is_ignored(&path.to_unix()))
+        })
+        .collect())
 }
 
 impl<'a> GlobalHashableInputs<'a> {
@@ -198,6 +245,8 @@ impl<'a> GlobalHashableInputs<'a> {
             pass_through_env: self.pass_through_env.unwrap_or_default(),
             env_mode: self.env_mode,
             framework_inference: self.framework_inference,
+            cache_key_prefix: self.cache_key_prefix,
+            dot_env: self.dot_env.to_hashable(),
         };
 
         global_hashable.hash()
@@ -206,10 +255,13 @@ impl<'a> GlobalHashableInputs<'a> {
 
 #[cfg(test)]
 mod tests {
-    use turbopath::AbsoluteSystemPathBuf;
+    use turbopath::{AbsoluteSystemPathBuf, RelativeUnixPathBuf};
     use turborepo_env::EnvironmentVariableMap;
-    use turborepo_lockfiles::Lockfile;
-    use turborepo_repository::{package_graph::PackageInfo, package_manager::PackageManager};
+    use turborepo_lockfiles::{Lockfile, PnpmLockfile};
+    use turborepo_repository::{
+        package_graph::{PackageInfo, WorkspaceProvider},
+        package_manager::PackageManager,
+    };
     use turborepo_scm::SCM;
 
     use super::get_global_hash_inputs;
@@ -244,17 +296,130 @@ mod tests {
             &root,
             &PackageManager::Pnpm,
             lockfile,
+            &[],
             &file_deps,
+            &[],
             &env_var_map,
             &[],
             None,
             EnvMode::Strict,
             false,
+            None,
             &SCM::new(&root),
         );
         assert!(result.is_ok());
     }
 
+    #[test]
+    fn test_patches_are_hashed() {
+        let tempdir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tempdir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        root.join_component("package.json")
+            .create_with_contents("{}")
+            .unwrap();
+        let patches = root.join_component("patches");
+        patches.create_dir_all().unwrap();
+        patches
+            .join_component("is-odd@3.0.1.patch")
+            .create_with_contents("diff --git a/index.js b/index.js")
+            .unwrap();
+
+        let lockfile = PnpmLockfile::from_bytes(
+            b"lockfileVersion: '6.0'
+
+patchedDependencies:
+  is-odd@3.0.1:
+    hash: nrrwwz7lemethtlvvm75r5bmhq
+    path: patches/is-odd@3.0.1.patch
+  is-even@1.0.0:
+    hash: 5pk7ojv7qbqha75ozglk4y4f74
+    path: patches/is-even@1.0.0.patch
+
+importers: {}
+",
+        )
+        .unwrap();
+
+        let env_var_map = EnvironmentVariableMap::default();
+        let package_info = PackageInfo::default();
+        let inputs = get_global_hash_inputs(
+            None,
+            None,
+            &package_info,
+            &root,
+            &PackageManager::Pnpm,
+            Some(&lockfile),
+            &[],
+            &[],
+            &[],
+            &env_var_map,
+            &[],
+            None,
+            EnvMode::Strict,
+            false,
+            None,
+            &SCM::new(&root),
+        )
+        .unwrap();
+
+        // Patches that don't exist are skipped
+        assert_eq!(
+            inputs.global_file_hash_map.keys().collect::<Vec<_>>(),
+            vec![&RelativeUnixPathBuf::new("patches/is-odd@3.0.1.patch").unwrap()]
+        );
+    }
+
+    #[test]
+    fn test_workspace_provider_lockfiles_are_hashed() {
+        let tempdir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tempdir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        root.join_component("package.json")
+            .create_with_contents("{}")
+            .unwrap();
+        root.join_component("Cargo.lock")
+            .create_with_contents("version = 3")
+            .unwrap();
+        // Only the lockfiles of the providers in use are hashed
+        root.join_component("go.work.sum")
+            .create_with_contents("")
+            .unwrap();
+
+        let env_var_map = EnvironmentVariableMap::default();
+        let package_info = PackageInfo::default();
+        let lockfile =
+            PnpmLockfile::from_bytes(b"lockfileVersion: '6.0'\nimporters: {}\n").unwrap();
+        let inputs = get_global_hash_inputs(
+            None,
+            None,
+            &package_info,
+            &root,
+            &PackageManager::Pnpm,
+            Some(&lockfile),
+            &[WorkspaceProvider::Cargo],
+            &[],
+            &[],
+            &env_var_map,
+            &[],
+            None,
+            EnvMode::Strict,
+            false,
+            None,
+            &SCM::new(&root),
+        )
+        .unwrap();
+
+        assert_eq!(
+            inputs.global_file_hash_map.keys().collect::<Vec<_>>(),
+            vec![&RelativeUnixPathBuf::new("Cargo.lock").unwrap()]
+        );
+    }
+
     /// get_global_hash_inputs should not yield any folders when walking since
     /// turbo does not consider changes to folders when evaluating hashes,
     /// only to files
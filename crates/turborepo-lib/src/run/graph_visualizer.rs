@@ -9,7 +9,7 @@ use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
 use turborepo_ui::{cprintln, cwrite, cwriteln, BOLD, BOLD_YELLOW_REVERSE, UI, YELLOW};
 use which::which;
 
-use crate::{engine::Engine, opts::GraphOpts, spawn_child};
+use crate::{cli::GraphFormat, engine::Engine, opts::GraphOpts, spawn_child};
 
 #[derive(Debug, Error)]
 pub enum Error {
@@ -59,6 +59,20 @@ pub(crate) fn write_graph(
     Ok(())
 }
 
+pub(crate) fn print_graph(
+    format: GraphFormat,
+    engine: &Engine,
+    single_package: bool,
+) -> Result<(), Error> {
+    let stdout = io::stdout();
+    match format {
+        GraphFormat::Json => engine.json_graph(stdout, single_package),
+        GraphFormat::Dot => engine.dot_graph(stdout, single_package),
+        GraphFormat::Mermaid => engine.mermaid_graph(stdout, single_package),
+    }
+    .map_err(Error::GraphOutput)
+}
+
 fn write_graphviz_warning(ui: UI) -> Result<(), io::Error> {
     let stderr = io::stderr();
     cwrite!(&stderr, ui, BOLD_YELLOW_REVERSE, " WARNING ")?;
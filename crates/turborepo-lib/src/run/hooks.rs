@@ -0,0 +1,399 @@
+//! Hooks let users run their own executables at points during a run, e.g. to
+//! report metrics or mirror cache artifacts. They're configured under `hooks`
+//! in the root `turbo.json` and receive a JSON description of the event on
+//! stdin.
+//!
+//! A failing hook is reported as a warning and never fails the run. Hooks
+//! that don't exit within their timeout are killed.
+
+use std::{
+    io,
+    path::PathBuf,
+    process::Stdio,
+    sync::{Arc, Mutex},
+    time::Duration,
+};
+
+use serde::Serialize;
+use thiserror::Error;
+use tokio::{io::AsyncWriteExt, process::Command, sync::oneshot, task::JoinHandle};
+use tracing::{debug, warn};
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+use turborepo_cache::{CacheHitMetadata, CacheSource};
+
+use crate::{run::task_id::TaskId, turbo_json::HooksJson};
+
+const DEFAULT_TIMEOUT: Duration = Duration::from_secs(30);
+
+#[derive(Debug, Error)]
+enum Error {
+    #[error("unable to start {command}: {source}")]
+    Spawn { command: String, source: io::Error },
+    #[error("{command} exited with {status}: {stderr}")]
+    Failed {
+        command: String,
+        status: std::process::ExitStatus,
+        stderr: String,
+    },
+    #[error("{command} didn't exit within {timeout}")]
+    TimedOut { command: String, timeout: String },
+    #[error("unable to serialize event: {0}")]
+    Serialize(#[from] serde_json::Error),
+    #[error(transparent)]
+    Io(#[from] io::Error),
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "SCREAMING_SNAKE_CASE")]
+pub enum TaskCacheStatus {
+    Hit,
+    Miss,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum CacheEventKind {
+    Hit,
+    Miss,
+    Save,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(tag = "event", rename_all = "camelCase")]
+pub enum HookEvent {
+    /// Sent once before any tasks are run
+    #[serde(rename_all = "camelCase")]
+    PreRun { tasks: Vec<String> },
+    /// Sent after each task finishes, whether it ran or was restored
+    #[serde(rename_all = "camelCase")]
+    PostTask {
+        task_id: String,
+        package: String,
+        task: String,
+        hash: String,
+        duration_ms: u128,
+        cache_status: TaskCacheStatus,
+        exit_code: Option<i32>,
+    },
+    /// Sent after the cache is checked for a task and after its outputs are
+    /// saved to the cache
+    #[serde(rename_all = "camelCase")]
+    CacheEvent {
+        task_id: String,
+        hash: String,
+        #[serde(rename = "type")]
+        kind: CacheEventKind,
+        #[serde(skip_serializing_if = "Option::is_none")]
+        source: Option<&'static str>,
+        #[serde(skip_serializing_if = "Option::is_none")]
+        time_saved_ms: Option<u64>,
+        outputs: Vec<String>,
+    },
+}
+
+impl HookEvent {
+    pub fn cache_fetch(
+        task_id: &TaskId,
+        hash: &str,
+        hit: Option<CacheHitMetadata>,
+        outputs: &[AnchoredSystemPathBuf],
+    ) -> Self {
+        let (kind, source, time_saved_ms) = match hit {
+            Some(CacheHitMetadata { source, time_saved }) => {
+                let source = match source {
+                    CacheSource::Local => "LOCAL",
+                    CacheSource::Remote => "REMOTE",
+                };
+                (CacheEventKind::Hit, Some(source), Some(time_saved))
+            }
+            None => (CacheEventKind::Miss, None, None),
+        };
+        HookEvent::CacheEvent {
+            task_id: task_id.to_string(),
+            hash: hash.to_string(),
+            kind,
+            source,
+            time_saved_ms,
+            outputs: Self::outputs(outputs),
+        }
+    }
+
+    pub fn cache_save(task_id: &TaskId, hash: &str, outputs: &[AnchoredSystemPathBuf]) -> Self {
+        HookEvent::CacheEvent {
+            task_id: task_id.to_string(),
+            hash: hash.to_string(),
+            kind: CacheEventKind::Save,
+            source: None,
+            time_saved_ms: None,
+            outputs: Self::outputs(outputs),
+        }
+    }
+
+    fn outputs(outputs: &[AnchoredSystemPathBuf]) -> Vec<String> {
+        outputs.iter().map(|output| output.to_string()).collect()
+    }
+
+    fn name(&self) -> &'static str {
+        match self {
+            HookEvent::PreRun { .. } => "preRun",
+            HookEvent::PostTask { .. } => "postTask",
+            HookEvent::CacheEvent { .. } => "cacheEvent",
+        }
+    }
+}
+
+#[derive(Debug, Clone)]
+pub struct Hooks {
+    repo_root: AbsoluteSystemPathBuf,
+    config: HooksJson,
+    timeout: Duration,
+    // Hooks that run in the background once their event happens
+    pending: Arc<Mutex<Vec<JoinHandle<()>>>>,
+}
+
+impl Hooks {
+    pub fn new(repo_root: &AbsoluteSystemPath, config: HooksJson) -> Self {
+        Self {
+            repo_root: repo_root.to_owned(),
+            timeout: config.timeout.map_or(DEFAULT_TIMEOUT, Duration::from_secs),
+            config,
+            pending: Arc::default(),
+        }
+    }
+
+    /// Runs the hook configured for `event`, if there is one, and waits for
+    /// it to exit.
+    pub async fn run(&self, event: HookEvent) {
+        let Some(command) = self.command(&event) else {
+            return;
+        };
+
+        if let Err(e) = self.execute(command, &event).await {
+            warn!("{} hook failed: {}", event.name(), e);
+        }
+    }
+
+    /// Runs the hook configured for `event` in the background once `happened`
+    /// is notified. If its sender is dropped instead, the event didn't
+    /// happen and the hook isn't run. Use `wait` to wait for these hooks.
+    pub fn run_when(&self, happened: oneshot::Receiver<()>, event: HookEvent) {
+        if self.command(&event).is_none() {
+            return;
+        }
+        let hooks = self.clone();
+        let hook = tokio::spawn(async move {
+            if happened.await.is_ok() {
+                hooks.run(event).await;
+            }
+        });
+        self.pending.lock().expect("lock poisoned").push(hook);
+    }
+
+    /// Waits for the hooks started by `run_when` to finish
+    pub async fn wait(&self) {
+        let pending = std::mem::take(&mut *self.pending.lock().expect("lock poisoned"));
+        for hook in pending {
+            hook.await.ok();
+        }
+    }
+
+    fn command(&self, event: &HookEvent) -> Option<&String> {
+        match event {
+            HookEvent::PreRun { .. } => self.config.pre_run.as_ref(),
+            HookEvent::PostTask { .. } => self.config.post_task.as_ref(),
+            HookEvent::CacheEvent { .. } => self.config.cache_event.as_ref(),
+        }
+    }
+
+    async fn execute(&self, command: &str, event: &HookEvent) -> Result<(), Error> {
+        let payload = serde_json::to_vec(event)?;
+        debug!("running {} hook: {}", event.name(), command);
+
+        let mut child = Command::new(self.program(command))
+            .current_dir(self.repo_root.as_std_path())
+            .stdin(Stdio::piped())
+            .stdout(Stdio::null())
+            .stderr(Stdio::piped())
+            // The hook is killed if it doesn't exit before the timeout
+            .kill_on_drop(true)
+            .spawn()
+            .map_err(|source| Error::Spawn {
+                command: command.to_string(),
+                source,
+            })?;
+
+        let wait = async {
+            if let Some(mut stdin) = child.stdin.take() {
+                // A hook is free to exit without reading the event
+                if let Err(e) = stdin.write_all(&payload).await {
+                    debug!("unable to write event to {} hook: {}", event.name(), e);
+                }
+            }
+            child.wait_with_output().await
+        };
+        let output = tokio::time::timeout(self.timeout, wait)
+            .await
+            .map_err(|_| Error::TimedOut {
+                command: command.to_string(),
+                timeout: humantime::format_duration(self.timeout).to_string(),
+            })??;
+        if !output.status.success() {
+            return Err(Error::Failed {
+                command: command.to_string(),
+                status: output.status,
+                stderr: String::from_utf8_lossy(&output.stderr).trim().to_string(),
+            });
+        }
+
+        Ok(())
+    }
+
+    // Paths are relative to the repository root, bare names are looked up on
+    // the PATH.
+    fn program(&self, command: &str) -> PathBuf {
+        if command.contains('/') || command.contains(std::path::MAIN_SEPARATOR) {
+            AbsoluteSystemPathBuf::from_unknown(&self.repo_root, command).into()
+        } else {
+            PathBuf::from(command)
+        }
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use serde_json::json;
+
+    use super::*;
+
+    #[test]
+    fn test_cache_event_serialization() {
+        let task_id = TaskId::new("web", "build");
+        let hit = CacheHitMetadata {
+            source: CacheSource::Remote,
+            time_saved: 1200,
+        };
+        let outputs = [AnchoredSystemPathBuf::from_raw("dist").unwrap()];
+
+        let event = HookEvent::cache_fetch(&task_id, "abc123", Some(hit), &outputs);
+        assert_eq!(
+            serde_json::to_value(event).unwrap(),
+            json!({
+                "event": "cacheEvent",
+                "taskId": "web#build",
+                "hash": "abc123",
+                "type": "hit",
+                "source": "REMOTE",
+                "timeSavedMs": 1200,
+                "outputs": ["dist"],
+            })
+        );
+
+        let event = HookEvent::cache_fetch(&task_id, "abc123", None, &[]);
+        assert_eq!(
+            serde_json::to_value(event).unwrap(),
+            json!({
+                "event": "cacheEvent",
+                "taskId": "web#build",
+                "hash": "abc123",
+                "type": "miss",
+                "outputs": [],
+            })
+        );
+    }
+
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_hook_receives_event() {
+        use std::os::unix::fs::PermissionsExt;
+
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        let script = repo_root.join_components(&["scripts", "hook.sh"]);
+        script.ensure_dir().unwrap();
+        script
+            .create_with_contents("#!/bin/sh\ncat > event.json\n")
+            .unwrap();
+        std::fs::set_permissions(script.as_std_path(), std::fs::Permissions::from_mode(0o755))
+            .unwrap();
+
+        let hooks = Hooks::new(
+            &repo_root,
+            HooksJson {
+                pre_run: Some("./scripts/hook.sh".to_string()),
+                ..HooksJson::default()
+            },
+        );
+        hooks
+            .run(HookEvent::PreRun {
+                tasks: vec!["web#build".to_string()],
+            })
+            .await;
+
+        let event = repo_root
+            .join_component("event.json")
+            .read_to_string()
+            .unwrap();
+        assert_eq!(
+            serde_json::from_str::<serde_json::Value>(&event).unwrap(),
+            json!({ "event": "preRun", "tasks": ["web#build"] })
+        );
+    }
+
+    #[cfg(unix)]
+    #[tokio::test]
+    async fn test_hook_timeout() {
+        use std::os::unix::fs::PermissionsExt;
+
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        let script = repo_root.join_components(&["scripts", "hook.sh"]);
+        script.ensure_dir().unwrap();
+        script
+            .create_with_contents("#!/bin/sh\nsleep 30\n")
+            .unwrap();
+        std::fs::set_permissions(script.as_std_path(), std::fs::Permissions::from_mode(0o755))
+            .unwrap();
+
+        let hooks = Hooks::new(
+            &repo_root,
+            HooksJson {
+                pre_run: Some("./scripts/hook.sh".to_string()),
+                timeout: Some(1),
+                ..HooksJson::default()
+            },
+        );
+        let event = HookEvent::PreRun {
+            tasks: vec!["web#build".to_string()],
+        };
+        let start = std::time::Instant::now();
+        let result = hooks.execute("./scripts/hook.sh", &event).await;
+
+        assert!(matches!(result, Err(Error::TimedOut { .. })), "{result:?}");
+        assert!(start.elapsed() < Duration::from_secs(10));
+    }
+
+    #[tokio::test]
+    async fn test_run_when_skips_events_that_dont_happen() {
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        let hooks = Hooks::new(
+            &repo_root,
+            HooksJson {
+                pre_run: Some("./scripts/missing.sh".to_string()),
+                ..HooksJson::default()
+            },
+        );
+
+        let (tx, rx) = oneshot::channel();
+        hooks.run_when(
+            rx,
+            HookEvent::PreRun {
+                tasks: vec!["web#build".to_string()],
+            },
+        );
+        drop(tx);
+        hooks.wait().await;
+
+        assert!(hooks.pending.lock().unwrap().is_empty());
+    }
+}
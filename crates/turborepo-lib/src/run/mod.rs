@@ -2,25 +2,32 @@
 
 pub mod builder;
 mod cache;
+pub(crate) mod env_audit;
 mod error;
+pub(crate) mod executor;
 pub(crate) mod global_hash;
 mod graph_visualizer;
+pub(crate) mod hooks;
 pub(crate) mod package_discovery;
-mod scope;
+pub(crate) mod resume;
+pub(crate) mod scope;
 pub(crate) mod summary;
 pub mod task_access;
 pub mod task_id;
+pub(crate) mod time_budget;
 pub mod watch;
 
 use std::{collections::HashSet, io::Write, sync::Arc, time::Duration};
 
 pub use cache::{CacheOutput, ConfigCache, Error as CacheError, RunCache, TaskCache};
 use chrono::{DateTime, Local};
+use itertools::Itertools;
 use rayon::iter::ParallelBridge;
-use tokio::{select, task::JoinHandle};
-use tracing::debug;
+use tokio::{select, sync::oneshot, task::JoinHandle};
+use tracing::{debug, warn};
 use turbopath::AbsoluteSystemPathBuf;
 use turborepo_api_client::{APIAuth, APIClient};
+use turborepo_cache::CacheSource;
 use turborepo_ci::Vendor;
 use turborepo_env::EnvironmentVariableMap;
 use turborepo_repository::package_graph::{PackageGraph, PackageName, PackageNode};
@@ -30,11 +37,19 @@ use turborepo_ui::{cprint, cprintln, tui, tui::AppSender, BOLD_GREY, GREY, UI};
 
 pub use crate::run::error::Error;
 use crate::{
-    cli::EnvMode,
-    engine::Engine,
+    cli::{EnvMode, GraphFormat, TimeBudgetMode},
+    engine::{Engine, TaskNode},
     opts::Opts,
     process::ProcessManager,
-    run::{global_hash::get_global_hash_inputs, summary::RunTracker, task_access::TaskAccess},
+    run::{
+        env_audit::EnvAudit,
+        executor::{uses_tls, RemoteExecutor, EXECUTOR_ALLOW_HTTP_ENV, EXECUTOR_TOKEN_ENV},
+        global_hash::get_global_hash_inputs,
+        hooks::{HookEvent, Hooks},
+        summary::RunTracker,
+        task_access::TaskAccess,
+        task_id::TaskId,
+    },
     signal::SignalHandler,
     task_graph::Visitor,
     task_hash::{get_external_deps_hash, get_internal_deps_hash, PackageInputsHashes},
@@ -42,6 +57,17 @@ use crate::{
     DaemonClient, DaemonConnector,
 };
 
+// How long to wait for the run summary to be written after the tasks have been
+// given their grace period to shut down
+const SUMMARY_FLUSH_TIMEOUT: Duration = Duration::from_secs(5);
+
+// What executing a run produced, which `Run::run` reports to the terminal
+enum RunOutcome {
+    Exited(i32),
+    // The hashes of `--hash-only`, sorted by task id
+    TaskHashes(Vec<(TaskId<'static>, String)>),
+}
+
 #[derive(Clone)]
 pub struct Run {
     version: &'static str,
@@ -54,6 +80,9 @@ pub struct Run {
     api_client: APIClient,
     api_auth: Option<APIAuth>,
     env_at_execution_start: EnvironmentVariableMap,
+    // Variables set for this run on top of the environment turbo was started
+    // with, such as the `env` of a plan entry
+    extra_env: EnvironmentVariableMap,
     filtered_pkgs: HashSet<PackageName>,
     pkg_dep_graph: Arc<PackageGraph>,
     root_turbo_json: TurboJson,
@@ -102,6 +131,67 @@ impl Run {
         }
     }
 
+    /// Predicts how long the run will take and prints its critical path,
+    /// failing if it's longer than `budget`. `cache_hits` are the tasks that
+    /// are in the cache.
+    fn check_time_budget(
+        &self,
+        budget: Duration,
+        cache_hits: &HashSet<TaskId<'static>>,
+    ) -> Result<(), Error> {
+        let durations = time_budget::load_durations(&self.repo_root);
+        let prediction = time_budget::predict(
+            &self.engine,
+            &durations,
+            cache_hits,
+            self.opts.run_opts.concurrency,
+        );
+        // Sub-second precision is just noise in a prediction
+        let format = |duration: Duration| {
+            humantime::format_duration(Duration::from_secs(duration.as_secs())).to_string()
+        };
+
+        cprintln!(
+            self.ui,
+            GREY,
+            "• Predicted duration: {} (budget {})",
+            format(prediction.duration),
+            format(budget)
+        );
+        if !prediction.critical_path.is_empty() {
+            cprintln!(self.ui, GREY, "• Predicted critical path:");
+            for (task_id, duration) in &prediction.critical_path {
+                cprintln!(self.ui, GREY, "    {} ({})", task_id, format(*duration));
+            }
+        }
+        if prediction.unknown_tasks > 0 {
+            cprintln!(
+                self.ui,
+                GREY,
+                "• {} tasks haven't run with --summarize before and aren't part of the prediction",
+                prediction.unknown_tasks
+            );
+        }
+
+        if prediction.duration <= budget {
+            return Ok(());
+        }
+        match self.opts.run_opts.time_budget_mode {
+            TimeBudgetMode::Fail => Err(Error::TimeBudgetExceeded {
+                predicted: format(prediction.duration),
+                budget: format(budget),
+            }),
+            TimeBudgetMode::Warn => {
+                warn!(
+                    "run is predicted to take {}, which is longer than the time budget of {}",
+                    format(prediction.duration),
+                    format(budget)
+                );
+                Ok(())
+            }
+        }
+    }
+
     pub fn create_run_for_persistent_tasks(&self) -> Self {
         let mut new_run = self.clone();
         let new_engine = new_run.engine.create_engine_for_persistent_tasks();
@@ -136,6 +226,18 @@ impl Run {
             .collect()
     }
 
+    /// Prints the task graph for this run to stdout without executing any
+    /// tasks.
+    pub fn print_task_graph(&self, format: GraphFormat) -> Result<(), Error> {
+        graph_visualizer::print_graph(format, &self.engine, self.opts.run_opts.single_package)?;
+        Ok(())
+    }
+
+    /// The process manager that runs the tasks' commands
+    pub fn processes(&self) -> ProcessManager {
+        self.processes.clone()
+    }
+
     pub fn has_experimental_ui(&self) -> bool {
         self.experimental_ui
     }
@@ -143,6 +245,8 @@ impl Run {
     pub fn should_start_ui(&self) -> Result<bool, Error> {
         Ok(self.experimental_ui
             && self.opts.run_opts.dry_run.is_none()
+            && !self.opts.run_opts.hash_only
+            && !self.opts.run_opts.check_cache_only
             && tui::terminal_big_enough()?)
     }
 
@@ -167,8 +271,53 @@ impl Run {
     }
 
     pub async fn run(&mut self, experimental_ui_sender: Option<AppSender>) -> Result<i32, Error> {
+        match self.execute(experimental_ui_sender).await? {
+            RunOutcome::Exited(exit_code) => Ok(exit_code),
+            RunOutcome::TaskHashes(task_hashes) => {
+                let mut stdout = std::io::stdout().lock();
+                for (task_id, hash) in task_hashes {
+                    writeln!(stdout, "{task_id} => {hash}").ok();
+                }
+                Ok(0)
+            }
+        }
+    }
+
+    /// Hashes the tasks of the run without running them, like `--hash-only`.
+    /// The hashes are sorted by task id.
+    pub async fn task_hashes(&mut self) -> Result<Vec<(TaskId<'static>, String)>, Error> {
+        let run_opts = &mut Arc::make_mut(&mut self.opts).run_opts;
+        run_opts.hash_only = true;
+        run_opts.graph = None;
+        match self.execute(None).await? {
+            RunOutcome::TaskHashes(task_hashes) => Ok(task_hashes),
+            RunOutcome::Exited(_) => unreachable!("hash only runs return their task hashes"),
+        }
+    }
+
+    /// The tasks that `task_id` depends on directly
+    pub fn task_dependencies(&self, task_id: &TaskId) -> Vec<TaskId<'static>> {
+        self.engine
+            .dependencies(task_id)
+            .into_iter()
+            .flatten()
+            .filter_map(|node| match node {
+                TaskNode::Task(dependency) => Some(dependency.clone()),
+                TaskNode::Root => None,
+            })
+            .sorted_by_key(|dependency| dependency.to_string())
+            .collect()
+    }
+
+    async fn execute(
+        &mut self,
+        experimental_ui_sender: Option<AppSender>,
+    ) -> Result<RunOutcome, Error> {
+        let hooks = Hooks::new(&self.repo_root, self.root_turbo_json.hooks.clone());
+
         if let Some(subscriber) = self.signal_handler.subscribe() {
             let run_cache = self.run_cache.clone();
+            let hooks = hooks.clone();
             tokio::spawn(async move {
                 let _guard = subscriber.listen().await;
                 let spinner = turborepo_ui::start_spinner("...Finishing writing to cache...");
@@ -176,41 +325,11 @@ impl Run {
                     let fut = async {
                         loop {
                             tokio::time::sleep(std::time::Duration::from_secs(1)).await;
-                            // loop through hashmap, extract items that are still running,
-                            // sum up bit per second
-                            let (bytes_per_second, bytes_uploaded, bytes_total) = {
-                                let status = status.lock().unwrap();
-                                let total_bps: f64 = status
-                                    .iter()
-                                    .filter_map(|(_hash, task)| task.average_bps())
-                                    .sum();
-                                let bytes_uploaded: usize =
-                                    status.iter().filter_map(|(_hash, task)| task.bytes()).sum();
-                                let bytes_total: usize = status
-                                    .iter()
-                                    .filter(|(_hash, task)| !task.done())
-                                    .filter_map(|(_hash, task)| task.size())
-                                    .sum();
-                                (total_bps, bytes_uploaded, bytes_total)
-                            };
-
-                            if bytes_total == 0 {
-                                continue;
+                            if let Some((uploads, progress)) =
+                                run_cache.transfers().upload_progress()
+                            {
+                                spinner.set_message(cache::upload_status(uploads, progress));
                             }
-
-                            // convert to human readable
-                            let mut formatter = human_format::Formatter::new();
-                            let formatter = formatter.with_decimals(2).with_separator("");
-                            let bytes_per_second =
-                                formatter.with_units("B/s").format(bytes_per_second);
-                            let bytes_remaining = formatter
-                                .with_units("B")
-                                .format(bytes_total.saturating_sub(bytes_uploaded) as f64);
-
-                            spinner.set_message(format!(
-                                "...Finishing writing to cache... ({} remaining, {})",
-                                bytes_remaining, bytes_per_second
-                            ));
                         }
                     };
 
@@ -227,15 +346,48 @@ impl Run {
                     select! {
                         _ = closed => {}
                         _ = fut => {}
-                        _ = interrupt => {tracing::debug!("received interrupt, exiting");}
+                        _ = interrupt => {
+                            let pending = status
+                                .lock()
+                                .unwrap()
+                                .values()
+                                .filter(|task| !task.done())
+                                .count();
+                            if pending > 0 {
+                                tracing::warn!(
+                                    "received interrupt, cancelling {pending} pending cache \
+                                     upload(s)"
+                                );
+                            } else {
+                                tracing::debug!("received interrupt, exiting");
+                            }
+                        }
                     }
                 } else {
                     tracing::warn!("could not start shutdown, exiting");
                 }
                 spinner.finish_and_clear();
+                // Cache save hooks are waiting on the writes that were just finished
+                hooks.wait().await;
             });
         }
 
+        // If we're interrupted, hold off the shutdown until the tasks have been
+        // stopped and the partial run summary has been written. Dropping
+        // `_summary_written` signals that we're done, whether the run completes or
+        // exits early with an error.
+        let _summary_written = self.signal_handler.subscribe().map(|subscriber| {
+            let (tx, rx) = oneshot::channel::<()>();
+            let timeout = self.opts.run_opts.shutdown_grace_period + SUMMARY_FLUSH_TIMEOUT;
+            tokio::spawn(async move {
+                let _guard = subscriber.listen().await;
+                if tokio::time::timeout(timeout, rx).await.is_err() {
+                    tracing::warn!("timed out waiting for run summary to be written");
+                }
+            });
+            tx
+        });
+
         if let Some(graph_opts) = &self.opts.run_opts.graph {
             graph_visualizer::write_graph(
                 self.ui,
@@ -246,7 +398,7 @@ impl Run {
                 // as the repo root.
                 &self.repo_root,
             )?;
-            return Ok(0);
+            return Ok(RunOutcome::Exited(0));
         }
 
         let workspaces = self.pkg_dep_graph.packages().collect();
@@ -291,6 +443,25 @@ impl Run {
                 EnvMode::Strict => self.root_turbo_json.global_pass_through_env.as_deref(),
             };
 
+            // Only the providers that found packages affect the hash
+            let workspace_providers = self
+                .pkg_dep_graph
+                .packages()
+                .filter_map(|(_, info)| info.provider)
+                .unique()
+                .collect::<Vec<_>>();
+            // Variables set for this run are passed to every task, even in strict
+            // mode, so they're part of the global hash
+            let global_env_patterns = self
+                .root_turbo_json
+                .global_env
+                .iter()
+                .cloned()
+                .chain(self.extra_env.keys().cloned())
+                .sorted()
+                .dedup()
+                .collect::<Vec<_>>();
+
             get_global_hash_inputs(
                 root_external_dependencies_hash.as_deref(),
                 root_internal_dependencies_hash.as_deref(),
@@ -298,12 +469,15 @@ impl Run {
                 &self.repo_root,
                 self.pkg_dep_graph.package_manager(),
                 self.pkg_dep_graph.lockfile(),
+                &workspace_providers,
                 &self.root_turbo_json.global_deps,
+                &self.root_turbo_json.global_dot_env,
                 &self.env_at_execution_start,
-                &self.root_turbo_json.global_env,
+                &global_env_patterns,
                 pass_through_env,
                 env_mode,
                 self.opts.run_opts.framework_inference,
+                self.opts.run_opts.cache_key_prefix.as_deref(),
                 &self.scm,
             )?
         };
@@ -320,24 +494,36 @@ impl Run {
             env
         };
 
-        let run_tracker = RunTracker::new(
-            self.start_at,
-            self.opts.synthesize_command(),
-            self.opts.scope_opts.pkg_inference_root.as_deref(),
-            &self.env_at_execution_start,
-            &self.repo_root,
-            self.version,
-            self.opts.run_opts.experimental_space_id.clone(),
-            self.api_client.clone(),
-            self.api_auth.clone(),
-            Vendor::get_user(),
-            &self.scm,
-        );
+        let new_run_tracker = || {
+            RunTracker::new(
+                self.start_at,
+                self.opts.synthesize_command(),
+                self.opts.scope_opts.pkg_inference_root.as_deref(),
+                &self.env_at_execution_start,
+                &self.repo_root,
+                self.version,
+                self.opts.run_opts.experimental_space_id.clone(),
+                self.api_client.clone(),
+                self.api_auth.clone(),
+                self.run_cache.transfers(),
+                self.opts.run_opts.cache_analytics,
+                Vendor::get_user(),
+                &self.scm,
+            )
+        };
+        // The time budget looks up every task in the cache with a dry run
+        // before the real one, which needs its own copy of the hashes
+        let budget_inputs_hashes = self
+            .opts
+            .run_opts
+            .time_budget
+            .is_some()
+            .then(|| package_inputs_hashes.clone());
 
         let mut visitor = Visitor::new(
             self.pkg_dep_graph.clone(),
             self.run_cache.clone(),
-            run_tracker,
+            new_run_tracker(),
             &self.task_access,
             &self.opts.run_opts,
             package_inputs_hashes,
@@ -347,12 +533,168 @@ impl Run {
             self.ui,
             self.processes.clone(),
             &self.repo_root,
-            global_env,
+            global_env.clone(),
             experimental_ui_sender,
+            hooks.clone(),
         );
+        visitor.inject_global_dot_env(global_hash_inputs.dot_env.clone());
+        if self.opts.run_opts.env_audit {
+            visitor.audit_env(EnvAudit::new(&self.repo_root).map_err(Error::EnvAudit)?);
+        }
+        if let Some(endpoint) = &self.opts.run_opts.experimental_executor {
+            let cache_opts = &self.opts.cache_opts;
+            if cache_opts.skip_remote || cache_opts.remote_cache_write_only {
+                return Err(Error::ExecutorWithoutRemoteCache);
+            }
+            let allow_http = self
+                .env_at_execution_start
+                .get(EXECUTOR_ALLOW_HTTP_ENV)
+                .is_some_and(|value| matches!(value.as_str(), "1" | "true"));
+            if !uses_tls(endpoint) && !allow_http {
+                return Err(Error::ExecutorWithoutTls(endpoint.clone()));
+            }
+            // The executor checks out the current commit, so it wouldn't see
+            // uncommitted changes and would cache outputs that don't match them
+            let git_sha = self
+                .scm
+                .get_current_sha(&self.repo_root)
+                .map_err(|_| Error::ExecutorWithoutCommit)?;
+            let changes = self
+                .scm
+                .changed_files(&self.repo_root, &git_sha, None)
+                .map_err(Error::ExecutorChanges)?;
+            if !changes.is_empty() {
+                return Err(Error::ExecutorUncommittedChanges(changes.len()));
+            }
+            let token = self.env_at_execution_start.get(EXECUTOR_TOKEN_ENV).cloned();
+            visitor.execute_remotely(RemoteExecutor::new(
+                endpoint,
+                git_sha,
+                self.api_client.cache_client().clone(),
+                token,
+            ));
+        }
+
+        if self.opts.run_opts.hash_only {
+            visitor.hash_only();
+            visitor
+                .visit(self.engine.clone(), &self.run_telemetry)
+                .await?;
+            let task_hashes = self
+                .engine
+                .tasks()
+                .filter_map(|task| match task {
+                    TaskNode::Task(task_id) => visitor
+                        .task_hash(task_id)
+                        .map(|hash| (task_id.clone(), hash)),
+                    TaskNode::Root => None,
+                })
+                .sorted_by_key(|(task_id, _)| task_id.to_string())
+                .collect();
+            return Ok(RunOutcome::TaskHashes(task_hashes));
+        }
+
+        if self.opts.run_opts.check_cache_only {
+            // A dry run looks up each task in the cache without restoring it
+            visitor.dry_run();
+            let errors = visitor
+                .visit(self.engine.clone(), &self.run_telemetry)
+                .await?;
+            for err in &errors {
+                writeln!(std::io::stderr(), "{err}").ok();
+            }
+            let mut statuses = self
+                .engine
+                .tasks()
+                .filter_map(|task| match task {
+                    TaskNode::Task(task_id) => {
+                        // Tasks without a script or with caching disabled are
+                        // never in the cache, so they aren't looked up
+                        let has_command = self
+                            .pkg_dep_graph
+                            .package_json(&PackageName::from(task_id.package()))
+                            .and_then(|json| json.command(task_id.task()))
+                            .is_some();
+                        let cacheable = has_command
+                            && self
+                                .engine
+                                .task_definition(task_id)
+                                .map_or(false, |definition| definition.cache);
+                        let source = cacheable
+                            .then(|| visitor.cache_status(task_id).map(|status| status.source));
+                        Some((task_id.to_string(), source))
+                    }
+                    TaskNode::Root => None,
+                })
+                .collect::<Vec<_>>();
+            statuses.sort_by(|(a, _), (b, _)| a.cmp(b));
+            let mut stdout = std::io::stdout().lock();
+            for (task_id, source) in &statuses {
+                let status = match source {
+                    Some(Some(CacheSource::Local)) => "hit (local)",
+                    Some(Some(CacheSource::Remote)) => "hit (remote)",
+                    Some(None) => "miss",
+                    None => "uncacheable",
+                };
+                writeln!(stdout, "{task_id} => {status}").ok();
+            }
+            let all_cached =
+                errors.is_empty() && statuses.iter().all(|(_, s)| !matches!(s, Some(None)));
+            return Ok(RunOutcome::Exited(if all_cached { 0 } else { 1 }));
+        }
 
         if self.opts.run_opts.dry_run.is_some() {
             visitor.dry_run();
+        } else {
+            if let Some((budget, package_inputs_hashes)) =
+                self.opts.run_opts.time_budget.zip(budget_inputs_hashes)
+            {
+                // Tasks that will hit the cache only take as long as restoring
+                // their outputs
+                let mut budget_visitor = Visitor::new(
+                    self.pkg_dep_graph.clone(),
+                    self.run_cache.clone(),
+                    new_run_tracker(),
+                    &self.task_access,
+                    &self.opts.run_opts,
+                    package_inputs_hashes,
+                    &self.env_at_execution_start,
+                    &global_hash,
+                    self.opts.run_opts.env_mode,
+                    self.ui,
+                    self.processes.clone(),
+                    &self.repo_root,
+                    global_env,
+                    None,
+                    hooks.clone(),
+                );
+                budget_visitor.dry_run();
+                budget_visitor
+                    .visit(self.engine.clone(), &self.run_telemetry)
+                    .await?;
+                let cache_hits = self
+                    .engine
+                    .tasks()
+                    .filter_map(|task| match task {
+                        TaskNode::Task(task_id) => budget_visitor
+                            .cache_status(task_id)
+                            .is_some()
+                            .then(|| task_id.clone()),
+                        TaskNode::Root => None,
+                    })
+                    .collect();
+                self.check_time_budget(budget, &cache_hits)?;
+            }
+            let mut tasks: Vec<_> = self
+                .engine
+                .tasks()
+                .filter_map(|task| match task {
+                    TaskNode::Task(task_id) => Some(task_id.to_string()),
+                    TaskNode::Root => None,
+                })
+                .collect();
+            tasks.sort();
+            hooks.run(HookEvent::PreRun { tasks }).await;
         }
 
         // we look for this log line to mark the start of the run
@@ -379,6 +721,11 @@ impl Run {
             writeln!(std::io::stderr(), "{error_prefix}{err}").ok();
         }
 
+        // The summary reports how much was uploaded, so the uploads have to
+        // finish first. The experimental UI is still drawing, so it can't
+        // show a spinner.
+        self.run_cache.wait_for_writes(!self.experimental_ui).await;
+
         visitor
             .finish(
                 exit_code,
@@ -391,6 +738,6 @@ impl Run {
             )
             .await?;
 
-        Ok(exit_code)
+        Ok(RunOutcome::Exited(exit_code))
     }
 }
@@ -0,0 +1,207 @@
+//! Records the tasks that completed successfully during a run, so that the
+//! next invocation can skip them with `--resume`. Tasks are keyed by their
+//! hash, so a task whose inputs changed since is run again.
+//!
+//! Each completed task is appended to the record as a line of JSON, so
+//! completing a task doesn't rewrite the tasks recorded before it.
+
+use std::{
+    collections::BTreeMap,
+    fs::{File, OpenOptions},
+    io::Write,
+    sync::{Arc, Mutex},
+};
+
+use serde::{Deserialize, Serialize};
+use tracing::{debug, warn};
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
+
+use crate::run::task_id::TaskId;
+
+const COMPLETED_TASKS_PATH: [&str; 2] = [".turbo", "completed-tasks.jsonl"];
+
+#[derive(Debug, thiserror::Error)]
+enum Error {
+    #[error(transparent)]
+    Io(#[from] std::io::Error),
+    #[error(transparent)]
+    Json(#[from] serde_json::Error),
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct CompletedTask {
+    task_id: String,
+    hash: String,
+}
+
+#[derive(Debug, Clone)]
+pub struct ResumeTracker {
+    path: AbsoluteSystemPathBuf,
+    resume: bool,
+    // Task ids of the previous run mapped to the hash they completed with,
+    // only read with `--resume`
+    previous: Arc<BTreeMap<String, String>>,
+    // Opened on the first completed task
+    record: Arc<Mutex<Option<File>>>,
+}
+
+impl ResumeTracker {
+    pub fn new(repo_root: &AbsoluteSystemPath, resume: bool) -> Self {
+        let path = repo_root.join_components(&COMPLETED_TASKS_PATH);
+        let previous = if resume {
+            read(&path).unwrap_or_else(|err| {
+                warn!("unable to read the tasks that completed in the previous run: {err}");
+                BTreeMap::default()
+            })
+        } else {
+            BTreeMap::default()
+        };
+
+        Self {
+            path,
+            resume,
+            previous: Arc::new(previous),
+            record: Arc::default(),
+        }
+    }
+
+    /// Forgets the tasks of the previous run, unless this run resumes it. A
+    /// resumed run appends to the record it read, so that it can be resumed
+    /// as well.
+    pub fn start(&self) {
+        if self.resume {
+            return;
+        }
+        if let Err(err) = self.path.remove_file() {
+            if err.kind() != std::io::ErrorKind::NotFound {
+                warn!("unable to remove {}: {err}", self.path);
+            }
+        }
+    }
+
+    pub fn is_completed(&self, task_id: &TaskId, hash: &str) -> bool {
+        self.previous
+            .get(&task_id.to_string())
+            .map_or(false, |completed_hash| completed_hash == hash)
+    }
+
+    pub fn complete(&self, task_id: &TaskId, hash: &str) {
+        // Each task is recorded as soon as it completes so that the record is up
+        // to date if the run gets interrupted
+        match self.append(task_id, hash) {
+            Ok(()) => debug!("recorded {task_id} as completed"),
+            Err(err) => warn!("unable to record that {task_id} completed: {err}"),
+        }
+    }
+
+    fn append(&self, task_id: &TaskId, hash: &str) -> Result<(), Error> {
+        let mut line = serde_json::to_vec(&CompletedTask {
+            task_id: task_id.to_string(),
+            hash: hash.to_string(),
+        })?;
+        line.push(b'\n');
+
+        let mut record = self.record.lock().expect("completed tasks mutex poisoned");
+        if record.is_none() {
+            self.path.ensure_dir()?;
+            let mut options = OpenOptions::new();
+            options.create(true).append(true);
+            let mut file = self.path.open_with_options(options)?;
+            // The record we're appending to may end with a line that was cut
+            // short, so start on a new line. Empty lines are skipped when reading.
+            if file.metadata()?.len() > 0 {
+                file.write_all(b"\n")?;
+            }
+            *record = Some(file);
+        }
+        if let Some(file) = &mut *record {
+            file.write_all(&line)?;
+        }
+        Ok(())
+    }
+}
+
+fn read(path: &AbsoluteSystemPath) -> Result<BTreeMap<String, String>, Error> {
+    let mut completed = BTreeMap::new();
+    if !path.exists() {
+        return Ok(completed);
+    }
+    let contents = path.read_to_string()?;
+    for line in contents.lines().filter(|line| !line.trim().is_empty()) {
+        // The last line can be cut short if the run was killed while writing it
+        match serde_json::from_str::<CompletedTask>(line) {
+            Ok(task) => {
+                completed.insert(task.task_id, task.hash);
+            }
+            Err(err) => debug!("skipping invalid completed task record: {err}"),
+        }
+    }
+    Ok(completed)
+}
+
+#[cfg(test)]
+mod test {
+    use turbopath::AbsoluteSystemPathBuf;
+
+    use super::{ResumeTracker, COMPLETED_TASKS_PATH};
+    use crate::run::task_id::TaskId;
+
+    #[test]
+    fn test_resume() {
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        let build = TaskId::new("web", "build");
+        let lint = TaskId::new("web", "lint");
+
+        let first = ResumeTracker::new(&repo_root, false);
+        first.start();
+        first.complete(&build, "hash1");
+
+        let resumed = ResumeTracker::new(&repo_root, true);
+        resumed.start();
+        assert!(resumed.is_completed(&build, "hash1"));
+        assert!(!resumed.is_completed(&build, "hash2"));
+        assert!(!resumed.is_completed(&lint, "hash3"));
+        resumed.complete(&lint, "hash3");
+
+        // Resuming again skips the tasks of both runs
+        let resumed_again = ResumeTracker::new(&repo_root, true);
+        assert!(resumed_again.is_completed(&build, "hash1"));
+        assert!(resumed_again.is_completed(&lint, "hash3"));
+
+        // A run without `--resume` starts over
+        let fresh = ResumeTracker::new(&repo_root, false);
+        fresh.start();
+        assert!(!ResumeTracker::new(&repo_root, true).is_completed(&build, "hash1"));
+        assert!(!fresh.is_completed(&build, "hash1"));
+    }
+
+    #[test]
+    fn test_resume_after_interrupted_write() {
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path()).unwrap();
+        let build = TaskId::new("web", "build");
+        let lint = TaskId::new("web", "lint");
+
+        let first = ResumeTracker::new(&repo_root, false);
+        first.start();
+        first.complete(&build, "hash1");
+
+        // The run was killed partway through recording the next task
+        let record = repo_root.join_components(&COMPLETED_TASKS_PATH);
+        let mut contents = record.read_to_string().unwrap();
+        contents.push_str(r#"{"taskId":"web#li"#);
+        record.create_with_contents(contents).unwrap();
+
+        let resumed = ResumeTracker::new(&repo_root, true);
+        assert!(resumed.is_completed(&build, "hash1"));
+        assert!(!resumed.is_completed(&lint, "hash2"));
+
+        // Tasks completed after the cut off line are still recorded
+        resumed.complete(&lint, "hash2");
+        let resumed_again = ResumeTracker::new(&repo_root, true);
+        assert!(resumed_again.is_completed(&build, "hash1"));
+        assert!(resumed_again.is_completed(&lint, "hash2"));
+    }
+}
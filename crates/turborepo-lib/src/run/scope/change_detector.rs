@@ -1,5 +1,6 @@
 use std::collections::HashSet;
 
+use tracing::warn;
 use turbopath::{AbsoluteSystemPath, AnchoredSystemPathBuf};
 use turborepo_repository::{
     change_mapper::{ChangeMapper, DefaultPackageChangeMapper, LockfileChange, PackageChanges},
@@ -26,6 +27,7 @@ pub struct ScopeChangeDetector<'a> {
     change_mapper: ChangeMapper<'a, GlobalDepsPackageChangeMapper<'a>>,
     scm: &'a SCM,
     pkg_graph: &'a PackageGraph,
+    auto_deepen: bool,
 }
 
 impl<'a> ScopeChangeDetector<'a> {
@@ -35,6 +37,7 @@ impl<'a> ScopeChangeDetector<'a> {
         pkg_graph: &'a PackageGraph,
         global_deps: impl Iterator<Item = &'a str>,
         ignore_patterns: Vec<String>,
+        auto_deepen: bool,
     ) -> Result<Self, Error> {
         let pkg_detector = GlobalDepsPackageChangeMapper::new(pkg_graph, global_deps)?;
         let change_mapper = ChangeMapper::new(pkg_graph, ignore_patterns, pkg_detector);
@@ -44,6 +47,7 @@ impl<'a> ScopeChangeDetector<'a> {
             change_mapper,
             scm,
             pkg_graph,
+            auto_deepen,
         })
     }
 
@@ -79,6 +83,13 @@ impl<'a> ScopeChangeDetector<'a> {
 
         Some(LockfileChange::WithContent(content))
     }
+
+    fn all_packages(&self) -> HashSet<PackageName> {
+        self.pkg_graph
+            .packages()
+            .map(|(name, _)| name.to_owned())
+            .collect()
+    }
 }
 
 impl<'a> GitChangeDetector for ScopeChangeDetector<'a> {
@@ -87,8 +98,19 @@ impl<'a> GitChangeDetector for ScopeChangeDetector<'a> {
         from_ref: &str,
         to_ref: Option<&str>,
     ) -> Result<HashSet<PackageName>, ResolutionError> {
+        if !from_ref.is_empty() && !self.scm.has_history() {
+            warn!(
+                "{} is not part of a git or mercurial repository, considering all packages \
+                 changed since {from_ref}",
+                self.turbo_root
+            );
+            return Ok(self.all_packages());
+        }
+
         let mut changed_files = HashSet::new();
         if !from_ref.is_empty() {
+            self.scm
+                .ensure_history(from_ref, to_ref, self.auto_deepen)?;
             changed_files = self.scm.changed_files(self.turbo_root, from_ref, to_ref)?;
         }
 
@@ -98,11 +120,7 @@ impl<'a> GitChangeDetector for ScopeChangeDetector<'a> {
             .change_mapper
             .changed_packages(changed_files, lockfile_contents)?
         {
-            PackageChanges::All => Ok(self
-                .pkg_graph
-                .packages()
-                .map(|(name, _)| name.to_owned())
-                .collect()),
+            PackageChanges::All => Ok(self.all_packages()),
             PackageChanges::Some(packages) => Ok(packages
                 .iter()
                 .map(|package| package.name.to_owned())
@@ -1,11 +1,14 @@
 use std::{
     collections::{HashMap, HashSet},
+    fmt,
     path::Path,
     str::FromStr,
 };
 
 use tracing::debug;
-use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+use turbopath::{
+    AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
+};
 use turborepo_repository::{
     change_mapper::ChangeMapError,
     package_graph::{self, PackageGraph, PackageName},
@@ -16,7 +19,9 @@ use wax::Program;
 use super::{
     change_detector::GitChangeDetector,
     simple_glob::{Match, SimpleGlob},
-    target_selector::{GitRange, InvalidSelectorError, TargetSelector},
+    target_selector::{
+        clean_directory_glob, expand_braces, GitRange, InvalidSelectorError, TargetSelector,
+    },
 };
 use crate::{
     global_deps_package_change_mapper, run::scope::change_detector::ScopeChangeDetector,
@@ -72,8 +77,8 @@ impl PackageInference {
     }
 
     pub fn apply(&self, selector: &mut TargetSelector) {
-        // if the name pattern is provided, do not attempt inference
-        if !selector.name_pattern.is_empty() {
+        // if the name pattern or a tag is provided, do not attempt inference
+        if !selector.name_pattern.is_empty() || selector.tag.is_some() {
             return;
         };
 
@@ -82,13 +87,8 @@ impl PackageInference {
         }
 
         if let Some(parent_dir) = selector.parent_dir.as_deref() {
-            let repo_relative_parent_dir = self.directory_root.join(parent_dir);
-            let clean_parent_dir = path_clean::clean(Path::new(repo_relative_parent_dir.as_path()))
-                .into_os_string()
-                .into_string()
-                .expect("path was valid utf8 before cleaning");
             selector.parent_dir = Some(
-                AnchoredSystemPathBuf::try_from(clean_parent_dir.as_str())
+                clean_directory_glob(Path::new(self.directory_root.as_str()), parent_dir.as_str())
                     .expect("path wasn't absolute before cleaning"),
             );
         } else if self.package_name.is_none() {
@@ -101,6 +101,51 @@ impl PackageInference {
     }
 }
 
+/// How a single `--filter` selector contributed to the resolved packages
+#[derive(Debug)]
+pub struct SelectorResolution {
+    pub raw: String,
+    pub exclude: bool,
+    /// Packages matching the selector's name, directory, tag and git range
+    pub matched: HashSet<PackageName>,
+    /// The matched packages along with any dependencies or dependents the
+    /// selector asked for
+    pub selected: HashSet<PackageName>,
+}
+
+/// The packages selected by a set of filters, and how each filter was
+/// resolved
+#[derive(Debug, Default)]
+pub struct FilterExplanation {
+    pub selectors: Vec<SelectorResolution>,
+    pub packages: HashSet<PackageName>,
+}
+
+impl fmt::Display for FilterExplanation {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        fn names(packages: &HashSet<PackageName>) -> String {
+            if packages.is_empty() {
+                return "(none)".to_string();
+            }
+            let mut names = packages.iter().map(|p| p.to_string()).collect::<Vec<_>>();
+            names.sort();
+            names.join(", ")
+        }
+
+        for selector in &self.selectors {
+            let raw = if selector.raw.is_empty() {
+                "(inferred from the current directory)"
+            } else {
+                &selector.raw
+            };
+            writeln!(f, "{raw}")?;
+            writeln!(f, "  matched:  {}", names(&selector.matched))?;
+            writeln!(f, "  selected: {}", names(&selector.selected))?;
+        }
+        write!(f, "packages: {}", names(&self.packages))
+    }
+}
+
 pub struct FilterResolver<'a, T: GitChangeDetector> {
     pkg_graph: &'a PackageGraph,
     turbo_root: &'a AbsoluteSystemPath,
@@ -124,8 +169,14 @@ impl<'a> FilterResolver<'a, ScopeChangeDetector<'a>> {
             .map(|s| s.as_str())
             .chain(root_turbo_json.global_deps.iter().map(|s| s.as_str()));
 
-        let change_detector =
-            ScopeChangeDetector::new(turbo_root, scm, pkg_graph, global_deps, vec![])?;
+        let change_detector = ScopeChangeDetector::new(
+            turbo_root,
+            scm,
+            pkg_graph,
+            global_deps,
+            vec![],
+            opts.auto_deepen,
+        )?;
 
         Ok(Self::new_with_change_detector(
             pkg_graph,
@@ -165,39 +216,52 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
         &self,
         patterns: &[String],
     ) -> Result<(HashSet<PackageName>, bool), ResolutionError> {
+        let (explanation, is_all_packages) = self.explain(patterns)?;
+        Ok((explanation.packages, is_all_packages))
+    }
+
+    /// Resolves `patterns` like [`FilterResolver::resolve`], but also
+    /// reports how each selector contributed to the result.
+    pub(crate) fn explain(
+        &self,
+        patterns: &[String],
+    ) -> Result<(FilterExplanation, bool), ResolutionError> {
         // inference is None only if we are in the root
         let is_all_packages = patterns.is_empty() && self.inference.is_none();
 
-        let filter_patterns = if is_all_packages {
+        let explanation = if is_all_packages {
             // return all packages in the workspace
-            self.pkg_graph
-                .packages()
-                .filter(|(name, _)| matches!(name, PackageName::Other(_)))
-                .map(|(name, _)| name.to_owned())
-                .collect()
+            FilterExplanation {
+                selectors: Vec::new(),
+                packages: self
+                    .pkg_graph
+                    .packages()
+                    .filter(|(name, _)| matches!(name, PackageName::Other(_)))
+                    .map(|(name, _)| name.to_owned())
+                    .collect(),
+            }
         } else {
-            self.get_packages_from_patterns(patterns)?
+            let selectors = patterns
+                .iter()
+                .map(|pattern| TargetSelector::from_str(pattern))
+                .collect::<Result<Vec<_>, _>>()?;
+            self.explain_selectors(selectors)?
         };
 
-        Ok((filter_patterns, is_all_packages))
+        Ok((explanation, is_all_packages))
     }
 
-    fn get_packages_from_patterns(
+    fn get_filtered_packages(
         &self,
-        patterns: &[String],
+        selectors: Vec<TargetSelector>,
     ) -> Result<HashSet<PackageName>, ResolutionError> {
-        let selectors = patterns
-            .iter()
-            .map(|pattern| TargetSelector::from_str(pattern))
-            .collect::<Result<Vec<_>, _>>()?;
-
-        self.get_filtered_packages(selectors)
+        Ok(self.explain_selectors(selectors)?.packages)
     }
 
-    fn get_filtered_packages(
+    fn explain_selectors(
         &self,
         selectors: Vec<TargetSelector>,
-    ) -> Result<HashSet<PackageName>, ResolutionError> {
+    ) -> Result<FilterExplanation, ResolutionError> {
         let (_prod_selectors, all_selectors) = self
             .apply_inference(selectors)
             .into_iter()
@@ -230,15 +294,39 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
         selectors
     }
 
+    /// Selectors are evaluated in a fixed order so that combining them is
+    /// predictable:
+    ///
+    /// 1. each selector is matched against the workspace on its own. A package
+    ///    has to match the selector's name, directory, tag and git range to be
+    ///    matched.
+    /// 2. the matched packages are expanded to their dependencies and
+    ///    dependents if the selector asks for them.
+    /// 3. the packages selected by every include selector are combined. If
+    ///    there are no include selectors, every package except the root is
+    ///    included.
+    /// 4. the packages selected by every exclude selector are removed.
+    ///
+    /// Negation is always applied last, so `!docs...[HEAD~5]` removes `docs`
+    /// if it or one of its dependencies changed since `HEAD~5`.
     fn filter_graph(
         &self,
         selectors: Vec<TargetSelector>,
-    ) -> Result<HashSet<PackageName>, ResolutionError> {
-        let (include_selectors, exclude_selectors) =
-            selectors.into_iter().partition::<Vec<_>, _>(|t| !t.exclude);
+    ) -> Result<FilterExplanation, ResolutionError> {
+        let selectors = selectors
+            .into_iter()
+            .map(|selector| self.resolve_selector(selector))
+            .collect::<Result<Vec<_>, _>>()?;
+
+        let (include_selectors, exclude_selectors) = selectors
+            .iter()
+            .partition::<Vec<_>, _>(|selector| !selector.exclude);
 
-        let mut include = if !include_selectors.is_empty() {
-            self.filter_graph_with_selectors(include_selectors)?
+        let mut packages: HashSet<PackageName> = if !include_selectors.is_empty() {
+            include_selectors
+                .iter()
+                .flat_map(|selector| selector.selected.iter().cloned())
+                .collect()
         } else {
             self.pkg_graph
                 .packages()
@@ -248,91 +336,83 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
                 .collect()
         };
 
-        let exclude = self.filter_graph_with_selectors(exclude_selectors)?;
-
-        include.retain(|i| !exclude.contains(i));
+        for selector in exclude_selectors {
+            packages.retain(|package| !selector.selected.contains(package));
+        }
 
-        Ok(include)
+        Ok(FilterExplanation {
+            selectors,
+            packages,
+        })
     }
 
-    fn filter_graph_with_selectors(
+    fn resolve_selector(
         &self,
-        selectors: Vec<TargetSelector>,
-    ) -> Result<HashSet<PackageName>, ResolutionError> {
-        let mut unmatched_selectors = Vec::new();
-        let mut walked_dependencies = HashSet::new();
-        let mut walked_dependents = HashSet::new();
-        let mut walked_dependent_dependencies = HashSet::new();
-        let mut cherry_picked_packages = HashSet::new();
-
-        for selector in selectors {
-            let selector_packages = self.filter_graph_with_selector(&selector)?;
+        selector: TargetSelector,
+    ) -> Result<SelectorResolution, ResolutionError> {
+        let matched = self.filter_graph_with_selector(&selector)?;
+        let selected = self.expand_selector(&selector, &matched);
+
+        Ok(SelectorResolution {
+            raw: selector.raw,
+            exclude: selector.exclude,
+            matched,
+            selected,
+        })
+    }
 
-            if selector_packages.is_empty() {
-                unmatched_selectors.push(selector);
-                continue;
-            }
+    /// Follows the dependencies and dependents of the packages a selector
+    /// matched
+    fn expand_selector(
+        &self,
+        selector: &TargetSelector,
+        matched: &HashSet<PackageName>,
+    ) -> HashSet<PackageName> {
+        let mut selected = HashSet::new();
 
-            for package in selector_packages {
-                let node = package_graph::PackageNode::Workspace(package.clone());
+        for package in matched {
+            let node = package_graph::PackageNode::Workspace(package.clone());
 
-                if selector.include_dependencies {
-                    let dependencies = self.pkg_graph.dependencies(&node);
-                    let dependencies = dependencies
+            if selector.include_dependencies {
+                let dependencies = self.pkg_graph.dependencies(&node);
+                selected.extend(
+                    dependencies
                         .iter()
                         .filter(|node| !matches!(node, package_graph::PackageNode::Root))
-                        .map(|i| i.as_package_name().to_owned())
-                        .collect::<Vec<_>>();
-
-                    // flatmap through the option, the set, and then the optional package name
-                    walked_dependencies.extend(dependencies);
-                }
-
-                if selector.include_dependents {
-                    let dependents = self.pkg_graph.ancestors(&node);
-                    for dependent in dependents.iter().map(|i| i.as_package_name()) {
-                        walked_dependents.insert(dependent.clone());
+                        .map(|i| i.as_package_name().to_owned()),
+                );
+            }
 
-                        // get the dependent's dependencies
-                        if selector.include_dependencies {
-                            let dependent_node =
-                                package_graph::PackageNode::Workspace(dependent.to_owned());
+            if selector.include_dependents {
+                let dependents = self.pkg_graph.ancestors(&node);
+                for dependent in dependents.iter().map(|i| i.as_package_name()) {
+                    selected.insert(dependent.clone());
 
-                            let dependent_dependencies =
-                                self.pkg_graph.dependencies(&dependent_node);
+                    // get the dependent's dependencies
+                    if selector.include_dependencies {
+                        let dependent_node =
+                            package_graph::PackageNode::Workspace(dependent.to_owned());
 
-                            let dependent_dependencies = dependent_dependencies
+                        let dependent_dependencies = self.pkg_graph.dependencies(&dependent_node);
+                        selected.extend(
+                            dependent_dependencies
                                 .iter()
                                 .filter(|node| !matches!(node, package_graph::PackageNode::Root))
-                                .map(|i| i.as_package_name().to_owned())
-                                .collect::<HashSet<_>>();
-
-                            walked_dependent_dependencies.extend(dependent_dependencies);
-                        }
+                                .map(|i| i.as_package_name().to_owned()),
+                        );
                     }
                 }
+            }
 
-                if (selector.include_dependents || selector.include_dependencies)
-                    && !selector.exclude_self
-                {
-                    // if we are including dependents or dependencies, and we are not excluding
-                    // ourselves, then we should add ourselves to the list of packages
-                    walked_dependencies.insert(package);
-                } else if !selector.include_dependencies && !selector.include_dependents {
-                    // if we are neither including dependents or dependencies, then
-                    // add  to the list of cherry picked packages
-                    cherry_picked_packages.insert(package);
-                }
+            // `^` only drops the matched package when we're following
+            // dependencies or dependents, otherwise there'd be nothing left
+            let walked = selector.include_dependents || selector.include_dependencies;
+            if !walked || !selector.exclude_self {
+                selected.insert(package.clone());
             }
         }
 
-        let mut all_packages = HashSet::new();
-        all_packages.extend(walked_dependencies);
-        all_packages.extend(walked_dependents);
-        all_packages.extend(walked_dependent_dependencies);
-        all_packages.extend(cherry_picked_packages);
-
-        Ok(all_packages)
+        selected
     }
 
     fn filter_graph_with_selector(
@@ -360,12 +440,16 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
         selector: &TargetSelector,
     ) -> Result<HashSet<PackageName>, ResolutionError> {
         let mut entry_packages = HashSet::new();
+        let parent_dir_globs = selector
+            .parent_dir
+            .as_deref()
+            .map(directory_globs)
+            .transpose()?;
 
         for (name, info) in self.pkg_graph.packages() {
-            if let Some(parent_dir) = selector.parent_dir.as_deref() {
-                let path = parent_dir.to_unix();
-                let parent_dir_matcher = wax::Glob::new(path.as_str())?;
-                let matches = parent_dir_matcher.is_match(info.package_path().as_path());
+            if let Some(parent_dir_globs) = parent_dir_globs.as_ref() {
+                let path = info.package_path().as_path();
+                let matches = parent_dir_globs.iter().any(|glob| glob.is_match(path));
 
                 if matches {
                     entry_packages.insert(name.to_owned());
@@ -376,7 +460,11 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
         }
 
         // if we have a filter, use it to filter the entry packages
-        let filtered_entry_packages = if !selector.name_pattern.is_empty() {
+        let filtered_entry_packages = if let Some(tag) = selector.tag.as_deref() {
+            let tagged = self.packages_with_tag(tag);
+            entry_packages.retain(|package| tagged.contains(package));
+            entry_packages
+        } else if !selector.name_pattern.is_empty() {
             match_package_names(&selector.name_pattern, entry_packages)?
         } else {
             entry_packages
@@ -423,22 +511,27 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
         &self,
         selector: &TargetSelector,
     ) -> Result<HashSet<PackageName>, ResolutionError> {
+        if let Some(tag) = selector.tag.as_deref() {
+            let mut tagged = self.packages_with_tag(tag);
+            if let Some(git_range) = selector.git_range.as_ref() {
+                let changed_packages = self.packages_changed_in_range(git_range)?;
+                tagged.retain(|package| changed_packages.contains(package));
+            }
+            return Ok(tagged);
+        }
+
         let mut entry_packages = HashSet::new();
         let mut selector_valid = false;
 
-        let parent_dir_unix = selector.parent_dir.as_deref().map(|path| path.to_unix());
-        let parent_dir_globber = parent_dir_unix
+        let parent_dir_globs = selector
+            .parent_dir
             .as_deref()
-            .map(|path| {
-                wax::Glob::new(path.as_str()).map_err(|err| ResolutionError::InvalidDirectoryGlob {
-                    glob: path.as_str().to_string(),
-                    err: Box::new(err),
-                })
-            })
+            .map(directory_globs)
             .transpose()?;
 
-        if let Some(globber) = parent_dir_globber.clone() {
-            let (base, _) = globber.partition();
+        // Each brace alternative has its own base directory, which has to exist
+        for glob in parent_dir_globs.iter().flatten().cloned() {
+            let (base, _) = glob.partition();
             // wax takes a unix-like glob, but partition will return a system path
             // TODO: it would be more proper to use
             // `AnchoredSystemPathBuf::from_system_path` but that function
@@ -464,11 +557,14 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
                 .collect::<HashMap<_, _>>();
 
             for package in changed_packages {
-                if let Some(parent_dir_globber) = parent_dir_globber.as_ref() {
+                if let Some(parent_dir_globs) = parent_dir_globs.as_ref() {
                     if package == PackageName::Root {
                         // The root package changed, only add it if
                         // the parentDir is equivalent to the root
-                        if parent_dir_globber.matched(&Path::new(".").into()).is_some() {
+                        if parent_dir_globs
+                            .iter()
+                            .any(|glob| glob.is_match(Path::new(".")))
+                        {
                             entry_packages.insert(package);
                         }
                     } else {
@@ -476,7 +572,10 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
                             .get(&package)
                             .ok_or(ResolutionError::MissingPackageInfo(package.to_string()))?;
 
-                        if parent_dir_globber.is_match(path.as_path()) {
+                        if parent_dir_globs
+                            .iter()
+                            .any(|glob| glob.is_match(path.as_path()))
+                        {
                             entry_packages.insert(package);
                         }
                     }
@@ -484,10 +583,10 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
                     entry_packages.insert(package);
                 }
             }
-        } else if let Some((parent_dir, parent_dir_globber)) = selector
+        } else if let Some((parent_dir, parent_dir_globs)) = selector
             .parent_dir
             .as_deref()
-            .zip(parent_dir_globber.as_ref())
+            .zip(parent_dir_globs.as_ref())
         {
             selector_valid = true;
             if parent_dir == &*AnchoredSystemPathBuf::from_raw(".").expect("valid anchored") {
@@ -496,7 +595,7 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
                 let packages = self.pkg_graph.packages();
                 for (name, _) in packages.filter(|(_name, info)| {
                     let path = info.package_path().as_path();
-                    parent_dir_globber.is_match(path)
+                    parent_dir_globs.iter().any(|glob| glob.is_match(path))
                 }) {
                     entry_packages.insert(name.to_owned());
                 }
@@ -529,6 +628,14 @@ impl<'a, T: GitChangeDetector> FilterResolver<'a, T> {
         }
     }
 
+    fn packages_with_tag(&self, tag: &str) -> HashSet<PackageName> {
+        self.pkg_graph
+            .packages()
+            .filter(|(_, info)| info.package_json.tags().any(|t| t == tag))
+            .map(|(name, _)| name.to_owned())
+            .collect()
+    }
+
     fn packages_changed_in_range(
         &self,
         git_range: &GitRange,
@@ -572,6 +679,23 @@ fn match_package_names(
     }
 }
 
+/// Returns a glob for each brace alternative of a directory glob
+fn directory_globs(
+    parent_dir: &AnchoredSystemPath,
+) -> Result<Vec<wax::Glob<'static>>, ResolutionError> {
+    expand_braces(parent_dir.to_unix().as_str())
+        .into_iter()
+        .map(|glob| {
+            wax::Glob::new(&glob)
+                .map(|parsed| parsed.into_owned())
+                .map_err(|err| ResolutionError::InvalidDirectoryGlob {
+                    glob: glob.clone(),
+                    err: Box::new(err),
+                })
+        })
+        .collect()
+}
+
 #[derive(Debug, thiserror::Error)]
 pub enum ResolutionError {
     #[error("missing info for package")]
@@ -592,6 +716,12 @@ pub enum ResolutionError {
     InvalidGlob(#[from] wax::BuildError),
     #[error("Unable to query SCM: {0}")]
     Scm(#[from] turborepo_scm::Error),
+    #[error("Unable to find where the current branch diverged from '{base}': {err}")]
+    AffectedBase {
+        base: String,
+        #[source]
+        err: turborepo_scm::Error,
+    },
     #[error("Unable to calculate changes: {0}")]
     ChangeDetectError(#[from] ChangeMapError),
     #[error("'Invalid directory filter '{glob}': {err}")]
@@ -698,7 +828,15 @@ mod test {
         let package_jsons = package_dirs
             .iter()
             .map(|package_path| {
-                let (_, name) = get_name(package_path);
+                let (parent, name) = get_name(package_path);
+                // Packages are tagged with the directory they're in
+                let other = parent
+                    .map(|parent| {
+                        let turbo = serde_json::json!({ "tags": [parent] });
+                        ("turbo".to_string(), turbo)
+                    })
+                    .into_iter()
+                    .collect();
                 (
                     turbo_root.join_unix_path(
                         RelativeUnixPathBuf::new(format!("{package_path}/package.json")).unwrap(),
@@ -710,6 +848,7 @@ mod test {
                                 .map(|name| (name.to_string(), "*".to_string()))
                                 .collect()
                         }),
+                        other,
                         ..Default::default()
                     },
                 )
@@ -916,6 +1055,17 @@ mod test {
         &["project-5"] ;
         "select by parentDir with no glob"
     )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                parent_dir: Some(AnchoredSystemPathBuf::try_from(if cfg!(windows) { "{packages\\*,project-5}" } else { "{packages/*,project-5}" }).unwrap()),
+                ..Default::default()
+            }
+        ],
+        None,
+        &["project-0", "project-1", "project-5"] ;
+        "select by parentDir with multiple directories"
+    )]
     #[test_case(
         vec![
             TargetSelector {
@@ -982,6 +1132,56 @@ mod test {
         &["project-0"] ;
         "infer single package from subdirectory"
     )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                tag: Some("packages".to_string()),
+                ..Default::default()
+            }
+        ],
+        None,
+        &["project-0", "project-1"] ;
+        "select by tag"
+    )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                tag: Some("packages".to_string()),
+                include_dependencies: true,
+                exclude_self: true,
+                ..Default::default()
+            }
+        ],
+        None,
+        &["project-1", "project-2", "project-4", "project-5"] ;
+        "select dependencies of tag"
+    )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                tag: Some("packages".to_string()),
+                exclude: true,
+                ..Default::default()
+            }
+        ],
+        None,
+        &["project-2", "project-3", "project-4", "project-5", "project-6"] ;
+        "exclude tag"
+    )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                tag: Some("packages".to_string()),
+                ..Default::default()
+            }
+        ],
+        Some(PackageInference{
+            package_name: None,
+            directory_root: AnchoredSystemPathBuf::try_from("project-5").unwrap(),
+        }),
+        &["project-0", "project-1"] ;
+        "tag ignores inference"
+    )]
     fn filter(
         selectors: Vec<TargetSelector>,
         package_inference: Option<PackageInference>,
@@ -1187,6 +1387,48 @@ mod test {
         &["package-1", "package-2"] ;
         "match dependency subtree"
     )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                git_range: Some(GitRange { from_ref: "HEAD~2".to_string(), to_ref: None }),
+                ..Default::default()
+            },
+            TargetSelector {
+                git_range: Some(GitRange { from_ref: "HEAD~2".to_string(), to_ref: None }),
+                name_pattern: "package-3".to_string(),
+                match_dependencies: true,
+                exclude: true,
+                ..Default::default()
+            }
+        ],
+        &["package-1", "package-2", ROOT_PKG_NAME] ;
+        "exclude changed package from changed packages"
+    )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                git_range: Some(GitRange { from_ref: "HEAD~3".to_string(), to_ref: None }),
+                name_pattern: "package-3".to_string(),
+                match_dependencies: true,
+                exclude: true,
+                ..Default::default()
+            }
+        ],
+        &["package-1", "package-2", "package-20"] ;
+        "exclude package whose dependency changed"
+    )]
+    #[test_case(
+        vec![
+            TargetSelector {
+                git_range: Some(GitRange { from_ref: "HEAD~2".to_string(), to_ref: Some("HEAD~1".to_string()) }),
+                include_dependencies: true,
+                exclude: true,
+                ..Default::default()
+            }
+        ],
+        &["package-1", "package-2"] ;
+        "exclude changed packages and their dependencies"
+    )]
     fn scm(selectors: Vec<TargetSelector>, expected: &[&str]) {
         let scm_resolver = TestChangeDetector::new(&[
             ("HEAD~1", None, &["package-1", "package-2", ROOT_PKG_NAME]),
@@ -1196,6 +1438,7 @@ mod test {
                 None,
                 &["package-1", "package-2", "package-3", ROOT_PKG_NAME],
             ),
+            ("HEAD~3", None, &["package-20"]),
         ]);
 
         let (_tempdir, resolver) = make_project(
@@ -1212,6 +1455,56 @@ mod test {
         );
     }
 
+    #[test_case(&["tag:apps[HEAD~1]"], &["app-1"] ; "changed packages with tag")]
+    #[test_case(&["tag:apps...[HEAD~1]"], &["app-0", "app-1"] ; "tag with changed dependencies")]
+    #[test_case(&["tag:packages[HEAD~1]", "lib-1"], &["lib-0", "lib-1"] ; "tag with other selectors")]
+    #[test_case(&["!tag:apps[HEAD~1]", "tag:apps"], &["app-0", "app-2"] ; "exclude changed packages with tag")]
+    fn scm_tag(selectors: &[&str], expected: &[&str]) {
+        let scm_resolver = TestChangeDetector::new(&[("HEAD~1", None, &["lib-0", "app-1"])]);
+
+        let (_tempdir, resolver) = make_project(
+            &[
+                ("apps/app-0", "packages/lib-0"),
+                ("apps/app-2", "packages/lib-1"),
+            ],
+            &["apps/app-1"],
+            None,
+            scm_resolver,
+        );
+
+        let selectors = selectors
+            .iter()
+            .map(|selector| selector.parse().unwrap())
+            .collect();
+        let packages = resolver.get_filtered_packages(selectors).unwrap();
+        assert_eq!(
+            packages,
+            expected.iter().map(|s| PackageName::from(*s)).collect()
+        );
+    }
+
+    #[test]
+    fn test_explain() {
+        let (_tempdir, resolver) = make_project(
+            &[("package-3", "package-20")],
+            &["package-1", "package-2"],
+            None,
+            TestChangeDetector::new(&[("HEAD~1", None, &["package-1", "package-20"])]),
+        );
+
+        let (explanation, is_all_packages) = resolver
+            .explain(&["[HEAD~1]".to_string(), "!...^package-20".to_string()])
+            .unwrap();
+        assert!(!is_all_packages);
+
+        assert_eq!(
+            explanation.to_string(),
+            "[HEAD~1]\n  matched:  package-1, package-20\n  selected: package-1, \
+             package-20\n!...^package-20\n  matched:  package-20\n  selected: \
+             package-3\npackages: package-1, package-20"
+        );
+    }
+
     struct TestChangeDetector<'a>(HashMap<(&'a str, Option<&'a str>), HashSet<PackageName>>);
 
     impl<'a> TestChangeDetector<'a> {
@@ -5,12 +5,13 @@ pub mod target_selector;
 
 use std::collections::HashSet;
 
+use change_detector::ScopeChangeDetector;
 use filter::{FilterResolver, PackageInference};
 use turbopath::AbsoluteSystemPath;
 use turborepo_repository::package_graph::{PackageGraph, PackageName};
 use turborepo_scm::SCM;
 
-pub use crate::run::scope::filter::ResolutionError;
+pub use crate::run::scope::filter::{FilterExplanation, ResolutionError};
 use crate::{opts::ScopeOpts, turbo_json::TurboJson};
 
 #[tracing::instrument(skip(opts, pkg_graph, scm))]
@@ -21,6 +22,62 @@ pub fn resolve_packages(
     scm: &SCM,
     root_turbo_json: &TurboJson,
 ) -> Result<(HashSet<PackageName>, bool), ResolutionError> {
+    let filters = filters(opts, turbo_root, scm)?;
+    filter_resolver(opts, turbo_root, pkg_graph, scm, root_turbo_json)?.resolve(&filters)
+}
+
+/// Resolves the filters like `resolve_packages`, keeping track of the
+/// packages each filter matched. The explanation's packages are the resolved
+/// ones, so the filters only have to be resolved once.
+#[tracing::instrument(skip(opts, pkg_graph, scm))]
+pub fn explain_packages(
+    opts: &ScopeOpts,
+    turbo_root: &AbsoluteSystemPath,
+    pkg_graph: &PackageGraph,
+    scm: &SCM,
+    root_turbo_json: &TurboJson,
+) -> Result<(FilterExplanation, bool), ResolutionError> {
+    let filters = filters(opts, turbo_root, scm)?;
+    filter_resolver(opts, turbo_root, pkg_graph, scm, root_turbo_json)?.explain(&filters)
+}
+
+fn filters(
+    opts: &ScopeOpts,
+    turbo_root: &AbsoluteSystemPath,
+    scm: &SCM,
+) -> Result<Vec<String>, ResolutionError> {
+    let mut filters = opts.get_filters();
+    if let Some(base) = &opts.affected_base {
+        // Without history there is no merge base, every package is considered changed
+        if !scm.has_history() {
+            filters.push(format!("...[{base}]"));
+            return Ok(filters);
+        }
+        scm.ensure_history(base, Some("HEAD"), opts.auto_deepen)
+            .map_err(|err| ResolutionError::AffectedBase {
+                base: base.clone(),
+                err,
+            })?;
+        // Compare against the point the current branch forked from the base so that
+        // changes that only landed on the base branch aren't considered
+        let merge_base = scm.merge_base(turbo_root, base, "HEAD").map_err(|err| {
+            ResolutionError::AffectedBase {
+                base: base.clone(),
+                err,
+            }
+        })?;
+        filters.push(format!("...[{merge_base}]"));
+    }
+    Ok(filters)
+}
+
+fn filter_resolver<'a>(
+    opts: &'a ScopeOpts,
+    turbo_root: &'a AbsoluteSystemPath,
+    pkg_graph: &'a PackageGraph,
+    scm: &'a SCM,
+    root_turbo_json: &'a TurboJson,
+) -> Result<FilterResolver<'a, ScopeChangeDetector<'a>>, ResolutionError> {
     let pkg_inference = opts.pkg_inference_root.as_ref().map(|pkg_inference_path| {
         PackageInference::calculate(turbo_root, pkg_inference_path, pkg_graph)
     });
@@ -32,6 +89,5 @@ pub fn resolve_packages(
         pkg_inference,
         scm,
         root_turbo_json,
-    )?
-    .resolve(&opts.get_filters())
+    )
 }
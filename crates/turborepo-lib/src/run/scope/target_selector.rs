@@ -1,8 +1,8 @@
-use std::str::FromStr;
+use std::{path::Path, str::FromStr};
 
 use regex::Regex;
 use thiserror::Error;
-use turbopath::AnchoredSystemPathBuf;
+use turbopath::{AnchoredSystemPathBuf, PathError};
 
 #[derive(Debug, PartialEq)]
 pub struct GitRange {
@@ -20,6 +20,9 @@ pub struct TargetSelector {
     pub follow_prod_deps_only: bool,
     pub parent_dir: Option<AnchoredSystemPathBuf>,
     pub name_pattern: String,
+    /// Selects packages that list this tag under `turbo.tags` in their
+    /// package.json
+    pub tag: Option<String>,
     pub git_range: Option<GitRange>,
     pub raw: String,
 }
@@ -66,9 +69,44 @@ impl FromStr for TargetSelector {
             (false, selector)
         };
 
+        // Package names can't contain a colon, so this can't be confused with a
+        // name pattern. Like a name pattern, a tag can be followed by a git range.
+        if let Some(tag_selector) = selector.strip_prefix("tag:") {
+            let re = Regex::new(r"^(?P<tag>[^\[\]{}]*?)(?P<commits>(?:\.{3})?\[[^\]]*\])?$")
+                .expect("valid");
+            let captures = re
+                .captures(tag_selector)
+                .ok_or_else(|| InvalidSelectorError::InvalidTag(tag_selector.to_string()))?;
+            let tag = &captures["tag"];
+            if tag.is_empty() {
+                return Err(InvalidSelectorError::EmptyTag);
+            }
+            let (match_dependencies, git_range) = match captures.name("commits") {
+                Some(commits) => {
+                    let (match_dependencies, commits) = match commits.as_str().strip_prefix("...") {
+                        Some(commits) => (true, commits),
+                        None => (false, commits.as_str()),
+                    };
+                    (match_dependencies, Some(parse_git_range(commits)?))
+                }
+                None => (false, None),
+            };
+            return Ok(TargetSelector {
+                exclude,
+                exclude_self,
+                include_dependencies,
+                include_dependents,
+                match_dependencies,
+                tag: Some(tag.to_string()),
+                git_range,
+                raw: raw_selector.to_string(),
+                ..Default::default()
+            });
+        }
+
         // We explicitly allow empty git ranges so we can return a more targeted error
         // below
-        let re = Regex::new(r"^(?P<name>[^.](?:[^{}\[\]]*[^{}\[\].])?)?(\{(?P<directory>[^}]*)})?(?P<commits>(?:\.{3})?\[[^\]]*\])?$").expect("valid");
+        let re = Regex::new(r"^(?P<name>[^.](?:[^{}\[\]]*[^{}\[\].])?)?(\{(?P<directory>.*)})?(?P<commits>(?:\.{3})?\[[^\]]*\])?$").expect("valid");
         let captures = re.captures(selector);
 
         let captures = match captures {
@@ -110,12 +148,10 @@ impl FromStr for TargetSelector {
             if directory.is_empty() {
                 return Err(InvalidSelectorError::EmptyPathSpecification);
             } else {
-                let clean_directory = path_clean::clean(std::path::Path::new(directory.as_str()))
-                    .into_os_string()
-                    .into_string()
-                    .expect("directory was valid utf8 before cleaning");
+                // Commas separate directories the same way as in a brace expansion, so
+                // `{./apps/*,./services/*}` selects packages in either directory
                 parent_dir = Some(
-                    AnchoredSystemPathBuf::try_from(clean_directory.as_str())
+                    clean_directory_glob(Path::new(""), &format!("{{{directory}}}"))
                         .map_err(|_| InvalidSelectorError::InvalidAnchoredPath(directory))?,
                 );
             }
@@ -131,35 +167,7 @@ impl FromStr for TargetSelector {
             } else {
                 commits.as_str()
             };
-
-            // strip the square brackets
-            let commits_str = commits_str
-                .strip_prefix('[')
-                .and_then(|s| s.strip_suffix(']'))
-                .expect("regex guarantees square brackets");
-            if commits_str.is_empty() {
-                return Err(InvalidSelectorError::InvalidGitRange(
-                    commits_str.to_string(),
-                ));
-            }
-
-            let git_range = if let Some((a, b)) = commits_str.split_once("...") {
-                if a.is_empty() || b.is_empty() {
-                    return Err(InvalidSelectorError::InvalidGitRange(
-                        commits_str.to_string(),
-                    ));
-                }
-                GitRange {
-                    from_ref: a.to_string(),
-                    to_ref: Some(b.to_string()),
-                }
-            } else {
-                GitRange {
-                    from_ref: commits_str.to_string(),
-                    to_ref: None,
-                }
-            };
-            Some(git_range)
+            Some(parse_git_range(commits_str)?)
         } else {
             None
         };
@@ -179,6 +187,37 @@ impl FromStr for TargetSelector {
     }
 }
 
+// Parses a git range in square brackets, e.g. `[main]` or `[main...HEAD]`
+fn parse_git_range(commits: &str) -> Result<GitRange, InvalidSelectorError> {
+    // strip the square brackets
+    let commits_str = commits
+        .strip_prefix('[')
+        .and_then(|s| s.strip_suffix(']'))
+        .expect("regex guarantees square brackets");
+    if commits_str.is_empty() {
+        return Err(InvalidSelectorError::InvalidGitRange(
+            commits_str.to_string(),
+        ));
+    }
+
+    if let Some((a, b)) = commits_str.split_once("...") {
+        if a.is_empty() || b.is_empty() {
+            return Err(InvalidSelectorError::InvalidGitRange(
+                commits_str.to_string(),
+            ));
+        }
+        Ok(GitRange {
+            from_ref: a.to_string(),
+            to_ref: Some(b.to_string()),
+        })
+    } else {
+        Ok(GitRange {
+            from_ref: commits_str.to_string(),
+            to_ref: None,
+        })
+    }
+}
+
 #[derive(Debug, Error, PartialEq)]
 pub enum InvalidSelectorError {
     #[error("cannot use match dependencies without specifying either a directory or package")]
@@ -189,6 +228,10 @@ pub enum InvalidSelectorError {
     EmptyPathSpecification,
     #[error("invalid git range selector: {0}")]
     InvalidGitRange(String),
+    #[error("tag selector must specify a tag")]
+    EmptyTag,
+    #[error("invalid tag selector: {0}")]
+    InvalidTag(String),
 
     #[error("selector \"{0}\" must have a reference, directory, or name pattern")]
     InvalidSelector(String),
@@ -206,12 +249,8 @@ pub fn is_selector_by_location(
             .iter()
             .any(|prefix| raw_selector.starts_with(prefix))
     {
-        let cleaned_selector = path_clean::clean(std::path::Path::new(raw_selector))
-            .into_os_string()
-            .into_string()
-            .expect("raw selector was valid utf8");
         Some(
-            AnchoredSystemPathBuf::try_from(cleaned_selector.as_str())
+            clean_directory_glob(Path::new(""), raw_selector)
                 .map_err(|_| InvalidSelectorError::InvalidAnchoredPath(raw_selector.to_string())),
         )
     } else {
@@ -219,6 +258,80 @@ pub fn is_selector_by_location(
     }
 }
 
+/// Joins each brace alternative of a directory glob to `root` and cleans it,
+/// so `./{apps,../services}/*` becomes `{apps/*,../services/*}`. Cleaning
+/// the glob as a whole would leave the `.` and `..` components inside the
+/// braces.
+pub fn clean_directory_glob(root: &Path, glob: &str) -> Result<AnchoredSystemPathBuf, PathError> {
+    let mut alternatives = Vec::new();
+    for alternative in expand_braces(glob) {
+        let clean_alternative = path_clean::clean(root.join(alternative))
+            .into_os_string()
+            .into_string()
+            .expect("glob was valid utf8 before cleaning");
+        if !alternatives.contains(&clean_alternative) {
+            alternatives.push(clean_alternative);
+        }
+    }
+    match alternatives.as_slice() {
+        [alternative] => AnchoredSystemPathBuf::try_from(alternative.as_str()),
+        _ => AnchoredSystemPathBuf::try_from(format!("{{{}}}", alternatives.join(",")).as_str()),
+    }
+}
+
+/// Expands the brace alternatives of a glob, e.g. `{apps,services}/*` expands
+/// to `apps/*` and `services/*`. Braces can be nested, and a brace without a
+/// matching closing brace is left as is.
+pub fn expand_braces(glob: &str) -> Vec<String> {
+    let Some((start, end)) = find_braces(glob) else {
+        return vec![glob.to_string()];
+    };
+    let (prefix, suffix) = (&glob[..start], &glob[end + 1..]);
+    split_alternatives(&glob[start + 1..end])
+        .into_iter()
+        .flat_map(|alternative| expand_braces(&format!("{prefix}{alternative}{suffix}")))
+        .collect()
+}
+
+// Returns the positions of the first opening brace and its closing brace
+fn find_braces(glob: &str) -> Option<(usize, usize)> {
+    let start = glob.find('{')?;
+    let mut depth = 0;
+    for (i, c) in glob[start..].char_indices() {
+        match c {
+            '{' => depth += 1,
+            '}' => {
+                depth -= 1;
+                if depth == 0 {
+                    return Some((start, start + i));
+                }
+            }
+            _ => {}
+        }
+    }
+    None
+}
+
+// Splits on the commas that aren't inside nested braces
+fn split_alternatives(alternatives: &str) -> Vec<&str> {
+    let mut depth = 0;
+    let mut start = 0;
+    let mut split = Vec::new();
+    for (i, c) in alternatives.char_indices() {
+        match c {
+            '{' => depth += 1,
+            '}' => depth -= 1,
+            ',' if depth == 0 => {
+                split.push(&alternatives[start..i]);
+                start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    split.push(&alternatives[start..]);
+    split
+}
+
 #[cfg(test)]
 mod test {
     use std::str::FromStr;
@@ -226,7 +339,7 @@ mod test {
     use test_case::test_case;
     use turbopath::AnchoredSystemPathBuf;
 
-    use super::TargetSelector;
+    use super::{expand_braces, TargetSelector};
     use crate::run::scope::target_selector::GitRange;
 
     #[test_case("foo", TargetSelector { name_pattern: "foo".to_string(), raw: "foo".to_string(), ..Default::default() }; "foo")]
@@ -239,6 +352,8 @@ mod test {
     #[test_case("./foo", TargetSelector { raw: "./foo".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from("foo").unwrap()), ..Default::default() }; "dot slash foo")]
     #[test_case("./foo/*", TargetSelector { raw: "./foo/*".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from(if cfg!(windows) { "foo\\*" } else { "foo/*" }).unwrap()), ..Default::default() }; "dot slash foo star")]
     #[test_case("...{./foo}", TargetSelector { raw: "...{./foo}".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from("foo").unwrap()), include_dependents: true, ..Default::default() }; "dot dot dot curly bracket foo")]
+    #[test_case("./{apps,services}/**", TargetSelector { raw: "./{apps,services}/**".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from(if cfg!(windows) { "{apps\\**,services\\**}" } else { "{apps/**,services/**}" }).unwrap()), ..Default::default() }; "dot slash brace expansion")]
+    #[test_case("{./apps/*,../services/*}", TargetSelector { raw: "{./apps/*,../services/*}".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from(if cfg!(windows) { "{apps\\*,..\\services\\*}" } else { "{apps/*,../services/*}" }).unwrap()), ..Default::default() }; "curly brackets multiple directories")]
     #[test_case(".", TargetSelector { raw: ".".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from(".").unwrap()), ..Default::default() }; "parent dir dot")]
     #[test_case("..", TargetSelector { raw: "..".to_string(), parent_dir: Some(AnchoredSystemPathBuf::try_from("..").unwrap()), ..Default::default() }; "parent dir dot dot")]
     #[test_case("[master]", TargetSelector { raw: "[master]".to_string(), git_range: Some(GitRange { from_ref: "master".to_string(), to_ref: None }), ..Default::default() }; "square brackets master")]
@@ -252,6 +367,11 @@ mod test {
     #[test_case("foo...[master]", TargetSelector { raw: "foo...[master]".to_string(), git_range: Some(GitRange { from_ref: "master".to_string(), to_ref: None }), name_pattern: "foo".to_string(), match_dependencies: true, ..Default::default() }; "foo...[master]")]
     #[test_case("foo...[master]...", TargetSelector { raw: "foo...[master]...".to_string(), git_range: Some(GitRange { from_ref: "master".to_string(), to_ref: None }), name_pattern: "foo".to_string(), match_dependencies: true, include_dependencies: true, ..Default::default() }; "foo...[master] dot dot dot")]
     #[test_case("{foo}...[master]", TargetSelector { raw: "{foo}...[master]".to_string(), git_range: Some(GitRange { from_ref: "master".to_string(), to_ref: None }), parent_dir: Some(AnchoredSystemPathBuf::try_from("foo").unwrap()), match_dependencies: true, ..Default::default() }; "curly brackets foo...[master]")]
+    #[test_case("tag:frontend", TargetSelector { raw: "tag:frontend".to_string(), tag: Some("frontend".to_string()), ..Default::default() }; "tag")]
+    #[test_case("!tag:frontend", TargetSelector { raw: "!tag:frontend".to_string(), tag: Some("frontend".to_string()), exclude: true, ..Default::default() }; "exclude tag")]
+    #[test_case("...^tag:frontend", TargetSelector { raw: "...^tag:frontend".to_string(), tag: Some("frontend".to_string()), include_dependents: true, exclude_self: true, ..Default::default() }; "dependents of tag")]
+    #[test_case("tag:frontend[main]", TargetSelector { raw: "tag:frontend[main]".to_string(), tag: Some("frontend".to_string()), git_range: Some(GitRange { from_ref: "main".to_string(), to_ref: None }), ..Default::default() }; "tag with git range")]
+    #[test_case("tag:frontend...[main...HEAD]", TargetSelector { raw: "tag:frontend...[main...HEAD]".to_string(), tag: Some("frontend".to_string()), git_range: Some(GitRange { from_ref: "main".to_string(), to_ref: Some("HEAD".to_string()) }), match_dependencies: true, ..Default::default() }; "tag with dependencies in git range")]
     fn parse_target_selector(raw_selector: &str, want: TargetSelector) {
         let result = TargetSelector::from_str(raw_selector);
 
@@ -269,12 +389,24 @@ mod test {
         }
     }
 
+    #[test_case("a/{b,c}/d", &["a/b/d", "a/c/d"] ; "single")]
+    #[test_case("{apps,services/{api,web}}/*", &["apps/*", "services/api/*", "services/web/*"] ; "nested")]
+    #[test_case("{a,b}/{c,d}", &["a/c", "a/d", "b/c", "b/d"] ; "multiple")]
+    #[test_case("a/{b", &["a/{b"] ; "unclosed")]
+    fn test_expand_braces(glob: &str, expected: &[&str]) {
+        assert_eq!(expand_braces(glob), expected);
+    }
+
     #[test_case("{}" ; "curly brackets")]
     #[test_case("......[master]" ; "......[master]")]
     #[test_case("[]" ; "empty git range")]
     #[test_case("[...some-ref]" ; "missing git range start")]
     #[test_case("[some-ref...]" ; "missing git range end")]
     #[test_case("[...]" ; "missing entire git range")]
+    #[test_case("tag:" ; "empty tag")]
+    #[test_case("tag:[main]" ; "tag with only a git range")]
+    #[test_case("tag:web{apps}" ; "tag with a directory")]
+    #[test_case("tag:web[]" ; "tag with empty git range")]
     fn parse_target_selector_invalid(raw_selector: &str) {
         let result = TargetSelector::from_str(raw_selector);
 
@@ -0,0 +1,106 @@
+//! Sends how much a run used the cache to the remote cache for
+//! `--cache-analytics`. Only counts are sent, so neither the tasks nor the
+//! repository can be identified from them.
+
+use std::fmt::{self, Debug, Formatter};
+
+use turborepo_api_client::{
+    analytics::{AnalyticsClient, RunCacheAnalytics},
+    APIAuth, APIClient,
+};
+use turborepo_cache::transfer::TransferTotals;
+
+use super::task::TaskCacheSummary;
+
+pub struct CacheAnalyticsClient {
+    api_client: APIClient,
+    api_auth: APIAuth,
+}
+
+impl Debug for CacheAnalyticsClient {
+    fn fmt(&self, f: &mut Formatter<'_>) -> fmt::Result {
+        f.debug_struct("CacheAnalyticsClient").finish()
+    }
+}
+
+impl CacheAnalyticsClient {
+    pub fn new(api_client: APIClient, api_auth: APIAuth) -> Self {
+        Self {
+            api_client,
+            api_auth,
+        }
+    }
+
+    pub async fn send(
+        &self,
+        analytics: &RunCacheAnalytics,
+    ) -> Result<(), turborepo_api_client::Error> {
+        self.api_client
+            .record_run_analytics(&self.api_auth, analytics)
+            .await
+    }
+}
+
+pub fn run_cache_analytics<'a>(
+    caches: impl Iterator<Item = &'a TaskCacheSummary>,
+    totals: &TransferTotals,
+) -> RunCacheAnalytics {
+    let mut analytics = RunCacheAnalytics {
+        downloaded_bytes: totals.downloaded_bytes,
+        uploaded_bytes: totals.uploaded_bytes,
+        ..Default::default()
+    };
+    for cache in caches {
+        analytics.tasks += 1;
+        if cache.is_remote_hit() {
+            analytics.remote_hits += 1;
+        } else if cache.is_hit() {
+            analytics.local_hits += 1;
+        }
+        analytics.time_saved += cache.time_saved();
+    }
+    analytics
+}
+
+#[cfg(test)]
+mod test {
+    use turborepo_api_client::analytics::RunCacheAnalytics;
+    use turborepo_cache::{transfer::TransferTotals, CacheHitMetadata, CacheSource};
+
+    use super::run_cache_analytics;
+    use crate::run::summary::task::TaskCacheSummary;
+
+    #[test]
+    fn test_run_cache_analytics() {
+        let caches = [
+            TaskCacheSummary::from(Some(CacheHitMetadata {
+                source: CacheSource::Local,
+                time_saved: 1000,
+            })),
+            TaskCacheSummary::from(Some(CacheHitMetadata {
+                source: CacheSource::Remote,
+                time_saved: 500,
+            })),
+            TaskCacheSummary::cache_miss(),
+        ];
+        let totals = TransferTotals {
+            downloads: 1,
+            downloaded_bytes: 2048,
+            uploads: 1,
+            uploaded_bytes: 1024,
+            ..Default::default()
+        };
+
+        assert_eq!(
+            run_cache_analytics(caches.iter(), &totals),
+            RunCacheAnalytics {
+                tasks: 3,
+                local_hits: 1,
+                remote_hits: 1,
+                time_saved: 1500,
+                downloaded_bytes: 2048,
+                uploaded_bytes: 1024,
+            }
+        );
+    }
+}
@@ -21,6 +21,12 @@ impl From<Duration> for TurboDuration {
     }
 }
 
+impl From<std::time::Duration> for TurboDuration {
+    fn from(duration: std::time::Duration) -> Self {
+        Self(Duration::milliseconds(duration.as_millis() as i64))
+    }
+}
+
 impl fmt::Display for TurboDuration {
     fn fmt(&self, f: &mut Formatter<'_>) -> fmt::Result {
         let duration = &self.0;
@@ -61,6 +67,7 @@ mod test {
     #[test_case(TurboDuration::from(Duration::milliseconds(1500)), "1.5s")]
     #[test_case(TurboDuration::from(Duration::milliseconds(1234)), "1.234s")]
     #[test_case(TurboDuration::from(Duration::seconds(90)), "1m30s")]
+    #[test_case(TurboDuration::from(std::time::Duration::from_millis(2500)), "2.5s")]
     fn duration_formatting(duration: TurboDuration, expected: &str) {
         assert_eq!(duration.to_string(), expected);
     }
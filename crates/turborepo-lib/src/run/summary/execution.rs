@@ -1,13 +1,15 @@
-use std::fmt;
+use std::{fmt, time::Duration};
 
 use chrono::{DateTime, Local};
 use serde::Serialize;
 use tokio::sync::mpsc;
 use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPath};
+use turborepo_api_client::ArtifactTransferStats;
+use turborepo_cache::transfer::TransferTotals;
 use turborepo_ui::{color, cprintln, BOLD, BOLD_GREEN, BOLD_RED, MAGENTA, UI, YELLOW};
 
 use super::TurboDuration;
-use crate::run::{summary::task::TaskSummary, task_id::TaskId};
+use crate::run::{cache::format_bytes, summary::task::TaskSummary, task_id::TaskId};
 
 // Just used to make changing the type that gets passed to the state management
 // thread easy
@@ -38,11 +40,26 @@ pub struct ExecutionSummary<'a> {
     cached: usize,
     // number of tasks that started
     attempted: usize,
+    // tasks that weren't run because a task they depend on failed
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    skipped_tasks: Vec<String>,
     pub(crate) start_time: i64,
     pub(crate) end_time: i64,
     #[serde(skip)]
     duration: TurboDuration,
     pub(crate) exit_code: i32,
+    // remote cache transfers that needed a retry or failed outright
+    #[serde(skip_serializing_if = "ArtifactTransferStats::is_empty")]
+    remote_cache_transfers: ArtifactTransferStats,
+    // whether the remote cache was skipped for part of the run because it kept failing
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    remote_cache_disabled: bool,
+    // bytes moved to and from the remote cache and the time it took
+    #[serde(skip_serializing_if = "TransferTotals::is_empty")]
+    remote_cache_totals: TransferTotals,
+    // milliseconds that the cached tasks took when they ran
+    #[serde(skip)]
+    time_saved: u64,
 }
 
 impl<'a> ExecutionSummary<'a> {
@@ -53,23 +70,71 @@ impl<'a> ExecutionSummary<'a> {
         exit_code: i32,
         start_time: DateTime<Local>,
         end_time: DateTime<Local>,
+        remote_cache_transfers: ArtifactTransferStats,
+        remote_cache_totals: TransferTotals,
+        time_saved: u64,
     ) -> Self {
         let duration = TurboDuration::new(&start_time, &end_time);
+        let mut skipped_tasks = state
+            .skipped
+            .iter()
+            .map(|task_id| task_id.to_string())
+            .collect::<Vec<_>>();
+        skipped_tasks.sort();
         Self {
             command,
             success: state.success,
             failed: state.failed,
             cached: state.cached,
             attempted: state.attempted,
+            skipped_tasks,
             // We're either at some path in the repo, or at the root, which is an empty path
             repo_path: package_inference_root.unwrap_or_else(|| AnchoredSystemPath::empty()),
             start_time: start_time.timestamp_millis(),
             end_time: end_time.timestamp_millis(),
             duration,
             exit_code,
+            remote_cache_transfers,
+            remote_cache_disabled: remote_cache_totals.disabled,
+            remote_cache_totals,
+            time_saved,
         }
     }
 
+    pub fn remote_cache_totals(&self) -> &TransferTotals {
+        &self.remote_cache_totals
+    }
+
+    // e.g. "42 cached, 57 total, saved ~13m0s, downloaded 210.00MB, uploaded
+    // 35.00MB"
+    fn cached_line(&self, ui: UI) -> String {
+        let totals = &self.remote_cache_totals;
+        let mut cached = format!(
+            "{}, {} total",
+            color!(ui, BOLD, "{} cached", self.cached),
+            self.attempted
+        );
+        if self.time_saved > 0 {
+            cached.push_str(&format!(
+                ", saved ~{}",
+                TurboDuration::from(Duration::from_millis(self.time_saved))
+            ));
+        }
+        if totals.downloads > 0 {
+            cached.push_str(&format!(
+                ", downloaded {}",
+                format_bytes(totals.downloaded_bytes as f64, "B")
+            ));
+        }
+        if totals.uploads > 0 {
+            cached.push_str(&format!(
+                ", uploaded {}",
+                format_bytes(totals.uploaded_bytes as f64, "B")
+            ));
+        }
+        cached
+    }
+
     /// We implement this on `ExecutionSummary` and not `RunSummary` because
     /// the `execution` field is nullable (due to normalize).
     pub fn print(&self, ui: UI, path: AbsoluteSystemPathBuf, failed_tasks: Vec<&TaskSummary>) {
@@ -91,15 +156,7 @@ impl<'a> ExecutionSummary<'a> {
                     self.attempted
                 ),
             ),
-            (
-                "Cached",
-                format!(
-                    "{}, {} total",
-                    color!(ui, BOLD, "{} cached", self.cached),
-                    self.attempted
-                )
-                .to_string(),
-            ),
+            ("Cached", self.cached_line(ui)),
             (
                 "Time",
                 format!(
@@ -110,6 +167,34 @@ impl<'a> ExecutionSummary<'a> {
             ),
         ];
 
+        let totals = &self.remote_cache_totals;
+        let mut remote = Vec::new();
+        if totals.downloads > 0 {
+            remote.push(format!(
+                "{} downloading",
+                TurboDuration::from(totals.download_time)
+            ));
+        }
+        if totals.uploads > 0 {
+            remote.push(format!(
+                "{} uploading",
+                TurboDuration::from(totals.upload_time)
+            ));
+        }
+        if !self.remote_cache_transfers.is_empty() {
+            remote.push(format!(
+                "{} retried, {} failed cache transfers",
+                self.remote_cache_transfers.retried, self.remote_cache_transfers.failed
+            ));
+        }
+        if self.remote_cache_disabled {
+            let disabled = color!(ui, YELLOW, "disabled after repeated failures");
+            remote.push(disabled.to_string());
+        }
+        if !remote.is_empty() {
+            line_data.push(("Remote", remote.join(", ")));
+        }
+
         if path.exists() {
             line_data.push(("Summary", path.to_string()));
         }
@@ -123,6 +208,15 @@ impl<'a> ExecutionSummary<'a> {
             line_data.push(("Failed", formatted.join(", ")));
         }
 
+        if !self.skipped_tasks.is_empty() {
+            let formatted: Vec<_> = self
+                .skipped_tasks
+                .iter()
+                .map(|task_id| color!(ui, YELLOW, "{}", task_id).to_string())
+                .collect();
+            line_data.push(("Skipped", formatted.join(", ")));
+        }
+
         let max_length = line_data
             .iter()
             .map(|(header, _)| header.len())
@@ -169,6 +263,8 @@ pub struct SummaryState {
     pub cached: usize,
     pub success: usize,
     pub tasks: Vec<TaskState>,
+    // Tasks that were skipped because a dependency failed
+    pub skipped: Vec<TaskId<'static>>,
 }
 
 #[derive(Debug, Clone)]
@@ -184,7 +280,7 @@ impl SummaryState {
             Event::BuildFailed => self.failed += 1,
             Event::Cached => self.cached += 1,
             Event::Built => self.success += 1,
-            Event::Canceled => (),
+            Event::Canceled | Event::Skipped => (),
         }
     }
 }
@@ -212,6 +308,8 @@ enum Event {
     Built,
     // Canceled due to external signal or internal failure
     Canceled,
+    // Not run because a dependency failed
+    Skipped,
 }
 
 #[derive(Debug, Serialize, Clone)]
@@ -219,12 +317,35 @@ enum Event {
 pub struct TaskExecutionSummary {
     pub start_time: i64,
     pub end_time: i64,
+    // milliseconds between start and end
+    pub duration: i64,
     #[serde(skip_serializing_if = "Option::is_none")]
     pub error: Option<String>,
     pub exit_code: Option<i32>,
+    // Whether the task was terminated for running longer than its timeout
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    pub timed_out: bool,
 }
 
 impl TaskExecutionSummary {
+    fn new(
+        started_at: DateTime<Local>,
+        ended_at: DateTime<Local>,
+        exit_code: Option<i32>,
+        error: Option<String>,
+    ) -> Self {
+        let start_time = started_at.timestamp_millis();
+        let end_time = ended_at.timestamp_millis();
+        Self {
+            start_time,
+            end_time,
+            duration: end_time - start_time,
+            exit_code,
+            error,
+            timed_out: false,
+        }
+    }
+
     pub fn is_failure(&self) -> bool {
         // We consider None as a failure as it indicates the task failed to start
         // or was killed in a manner where we didn't collect an exit code.
@@ -245,8 +366,12 @@ impl ExecutionTracker {
             }) = receiver.recv().await
             {
                 state.handle_event(event);
-                if let Some(task_state) = task_state {
-                    state.tasks.push(task_state);
+                match task_state {
+                    Some(task_state) if matches!(event, Event::Skipped) => {
+                        state.skipped.push(task_state.task_id);
+                    }
+                    Some(task_state) => state.tasks.push(task_state),
+                    None => (),
                 }
             }
             state
@@ -322,6 +447,24 @@ impl TaskTracker<()> {
             .await
             .expect("execution summary state thread finished")
     }
+
+    // Track that the task wasn't run because one of its dependencies failed
+    pub async fn skipped(self) {
+        let Self {
+            sender, task_id, ..
+        } = self;
+
+        sender
+            .send(TrackerMessage {
+                event: Event::Skipped,
+                state: Some(TaskState {
+                    task_id,
+                    execution: None,
+                }),
+            })
+            .await
+            .expect("execution summary state thread finished")
+    }
 }
 
 impl TaskTracker<chrono::DateTime<Local>> {
@@ -337,13 +480,8 @@ impl TaskTracker<chrono::DateTime<Local>> {
         } = self;
 
         let ended_at = Local::now();
-        let execution = TaskExecutionSummary {
-            start_time: started_at.timestamp_millis(),
-            end_time: ended_at.timestamp_millis(),
-            // Go synthesizes a zero exit code on cache hits
-            exit_code: Some(0),
-            error: None,
-        };
+        // Go synthesizes a zero exit code on cache hits
+        let execution = TaskExecutionSummary::new(started_at, ended_at, Some(0), None);
 
         let state = TaskState {
             task_id,
@@ -367,12 +505,7 @@ impl TaskTracker<chrono::DateTime<Local>> {
         } = self;
 
         let ended_at = Local::now();
-        let execution = TaskExecutionSummary {
-            start_time: started_at.timestamp_millis(),
-            end_time: ended_at.timestamp_millis(),
-            exit_code: Some(exit_code),
-            error: None,
-        };
+        let execution = TaskExecutionSummary::new(started_at, ended_at, Some(exit_code), None);
 
         let state = TaskState {
             task_id,
@@ -392,6 +525,20 @@ impl TaskTracker<chrono::DateTime<Local>> {
         self,
         exit_code: Option<i32>,
         error: impl fmt::Display,
+    ) -> TaskExecutionSummary {
+        self.failed(exit_code, error, false).await
+    }
+
+    /// Records a task that was terminated for running longer than its timeout
+    pub async fn build_timed_out(self, error: impl fmt::Display) -> TaskExecutionSummary {
+        self.failed(None, error, true).await
+    }
+
+    async fn failed(
+        self,
+        exit_code: Option<i32>,
+        error: impl fmt::Display,
+        timed_out: bool,
     ) -> TaskExecutionSummary {
         let Self {
             sender,
@@ -400,12 +547,9 @@ impl TaskTracker<chrono::DateTime<Local>> {
         } = self;
 
         let ended_at = Local::now();
-        let execution = TaskExecutionSummary {
-            start_time: started_at.timestamp_millis(),
-            end_time: ended_at.timestamp_millis(),
-            exit_code,
-            error: Some(error.to_string()),
-        };
+        let mut execution =
+            TaskExecutionSummary::new(started_at, ended_at, exit_code, Some(error.to_string()));
+        execution.timed_out = timed_out;
 
         let state = TaskState {
             task_id,
@@ -488,6 +632,27 @@ mod test {
         );
     }
 
+    #[tokio::test]
+    async fn test_skipped_tasks() {
+        let summary = ExecutionTracker::new();
+        let lib = TaskId::new("lib", "build");
+        let app = TaskId::new("app", "build");
+        {
+            let tracker = summary.task_tracker(lib.clone()).start().await;
+            tracker.build_failed(Some(1), "big bad error").await;
+        }
+        summary.task_tracker(app.clone()).skipped().await;
+
+        let state = summary.finish().await.unwrap();
+        assert_eq!(state.attempted, 1);
+        assert_eq!(state.failed, 1);
+        assert_eq!(state.skipped, vec![app.clone()]);
+        assert!(
+            state.tasks.iter().all(|task| task.task_id != app),
+            "skipped tasks don't produce execution data"
+        );
+    }
+
     #[tokio::test]
     async fn test_timing() {
         let summary = ExecutionTracker::new();
@@ -513,26 +678,90 @@ mod test {
         );
     }
 
+    #[test]
+    fn test_cached_line() {
+        let state = SummaryState {
+            attempted: 57,
+            cached: 42,
+            ..SummaryState::default()
+        };
+        let totals = TransferTotals {
+            downloads: 30,
+            downloaded_bytes: 210_000_000,
+            download_time: std::time::Duration::from_secs(12),
+            uploads: 4,
+            uploaded_bytes: 35_000_000,
+            upload_time: std::time::Duration::from_secs(3),
+            disabled: false,
+        };
+        let now = Local::now();
+        let summary = ExecutionSummary::new(
+            "turbo run build".to_string(),
+            state,
+            None,
+            0,
+            now,
+            now,
+            ArtifactTransferStats::default(),
+            totals,
+            13 * 60 * 1000,
+        );
+
+        assert_eq!(
+            summary.cached_line(UI::new(true)),
+            "42 cached, 57 total, saved ~13m0s, downloaded 210.00MB, uploaded 35.00MB"
+        );
+    }
+
     #[test_case(
         TaskExecutionSummary {
             start_time: 123,
             end_time: 234,
+            duration: 111,
             exit_code: Some(0),
-            error: None
+            error: None,
+            timed_out: false,
         },
-        json!({ "startTime": 123, "endTime": 234, "exitCode": 0 })
+        json!({ "startTime": 123, "endTime": 234, "duration": 111, "exitCode": 0 })
         ; "success"
     )]
     #[test_case(
         TaskExecutionSummary {
             start_time: 123,
             end_time: 234,
+            duration: 111,
             exit_code: Some(1),
             error: Some("cannot find anything".into()),
+            timed_out: false,
         },
-        json!({ "startTime": 123, "endTime": 234, "exitCode": 1, "error": "cannot find anything" })
+        json!({
+            "startTime": 123,
+            "endTime": 234,
+            "duration": 111,
+            "exitCode": 1,
+            "error": "cannot find anything"
+        })
         ; "failure"
     )]
+    #[test_case(
+        TaskExecutionSummary {
+            start_time: 123,
+            end_time: 234,
+            duration: 111,
+            exit_code: None,
+            error: Some("task timed out after 1m".into()),
+            timed_out: true,
+        },
+        json!({
+            "startTime": 123,
+            "endTime": 234,
+            "duration": 111,
+            "exitCode": null,
+            "error": "task timed out after 1m",
+            "timedOut": true
+        })
+        ; "timed out"
+    )]
     fn test_serialization(value: impl serde::Serialize, expected: serde_json::Value) {
         assert_eq!(serde_json::to_value(value).unwrap(), expected);
     }
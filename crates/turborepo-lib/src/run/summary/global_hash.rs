@@ -23,6 +23,9 @@ pub struct GlobalEnvVarSummary<'a> {
     pub inferred: Option<EnvironmentVariablePairs>,
     #[serde(rename = "passthrough")]
     pub pass_through: Option<EnvironmentVariablePairs>,
+    // Variables from the files listed in `globalDotEnv`
+    #[serde(rename = "dotEnv", skip_serializing_if = "Vec::is_empty")]
+    pub dot_env: EnvironmentVariablePairs,
 }
 
 #[derive(Debug, Serialize)]
@@ -34,12 +37,17 @@ pub struct GlobalHashSummary<'a> {
     pub hash_of_internal_dependencies: &'a str,
     pub environment_variables: GlobalEnvVarSummary<'a>,
     pub engines: Option<BTreeMap<&'a str, &'a str>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cache_key_prefix: Option<&'a str>,
 }
 
-impl<'a> TryFrom<GlobalHashableInputs<'a>> for GlobalHashSummary<'a> {
-    type Error = Error;
-    #[allow(clippy::too_many_arguments)]
-    fn try_from(global_hashable_inputs: GlobalHashableInputs<'a>) -> Result<Self, Self::Error> {
+impl<'a> GlobalHashSummary<'a> {
+    /// Summarizes the global hash inputs, hashing environment variable values
+    /// with `env_hash_salt` if one is given
+    pub fn new(
+        global_hashable_inputs: GlobalHashableInputs<'a>,
+        env_hash_salt: Option<&str>,
+    ) -> Result<Self, Error> {
         let GlobalHashableInputs {
             global_cache_key,
             global_file_hash_map,
@@ -50,6 +58,8 @@ impl<'a> TryFrom<GlobalHashableInputs<'a>> for GlobalHashSummary<'a> {
             pass_through_env,
             env_at_execution_start,
             engines,
+            cache_key_prefix,
+            dot_env,
             ..
         } = global_hashable_inputs;
 
@@ -59,7 +69,7 @@ impl<'a> TryFrom<GlobalHashableInputs<'a>> for GlobalHashSummary<'a> {
                     Ok(env_at_execution_start
                         .from_wildcards(pass_through_env)
                         .map_err(Error::Env)?
-                        .to_secret_hashable())
+                        .to_salted_hashable(env_hash_salt))
                 },
             )
             .transpose()?;
@@ -79,13 +89,15 @@ impl<'a> TryFrom<GlobalHashableInputs<'a>> for GlobalHashSummary<'a> {
                 },
                 configured: resolved_env_vars
                     .as_ref()
-                    .map(|vars| vars.by_source.explicit.to_secret_hashable()),
+                    .map(|vars| vars.by_source.explicit.to_salted_hashable(env_hash_salt)),
                 inferred: resolved_env_vars
                     .as_ref()
-                    .map(|vars| vars.by_source.matching.to_secret_hashable()),
+                    .map(|vars| vars.by_source.matching.to_salted_hashable(env_hash_salt)),
                 pass_through,
+                dot_env: dot_env.to_salted_hashable(env_hash_salt),
             },
             engines,
+            cache_key_prefix,
         })
     }
 }
@@ -3,10 +3,12 @@
 //! A tracker tracks the live data and then gets turned into a summary for
 //! displaying it We have this split because the tracker representation is not
 //! exactly what we want to display to the user.
+mod cache_analytics;
 #[allow(dead_code)]
 mod duration;
 mod execution;
 mod global_hash;
+mod otel;
 mod scm;
 mod spaces;
 mod task;
@@ -25,14 +27,18 @@ use tabwriter::TabWriter;
 use thiserror::Error;
 use tracing::{error, log::warn};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath};
-use turborepo_api_client::{spaces::CreateSpaceRunPayload, APIAuth, APIClient};
+use turborepo_api_client::{
+    spaces::CreateSpaceRunPayload, APIAuth, APIClient, ArtifactTransferTracker,
+};
+use turborepo_cache::transfer::TransferTracker;
 use turborepo_env::EnvironmentVariableMap;
 use turborepo_repository::package_graph::{PackageGraph, PackageName};
 use turborepo_scm::SCM;
 use turborepo_ui::{color, cprintln, cwriteln, BOLD, BOLD_CYAN, GREY, UI};
 
 use self::{
-    execution::TaskState, task::SinglePackageTaskSummary, task_factory::TaskSummaryFactory,
+    cache_analytics::CacheAnalyticsClient, execution::TaskState, task::SinglePackageTaskSummary,
+    task_factory::TaskSummaryFactory,
 };
 use super::task_id::TaskId;
 use crate::{
@@ -107,17 +113,31 @@ pub struct RunSummary<'a> {
     #[serde(skip)]
     run_type: RunType,
     #[serde(skip)]
+    otel_exporter_endpoint: Option<&'a str>,
+    #[serde(skip)]
+    http_client: reqwest::Client,
+    #[serde(skip)]
     spaces_client_handle: Option<SpacesClientHandle>,
+    #[serde(skip)]
+    cache_analytics_client: Option<CacheAnalyticsClient>,
 }
 
 /// We use this to track the run, so it's constructed before the run.
 #[derive(Debug)]
 pub struct RunTracker {
+    // Generated when the run starts so that tasks can be given the run's id
+    id: Ksuid,
     scm: SCMState,
     version: &'static str,
     started_at: DateTime<Local>,
     execution_tracker: ExecutionTracker,
+    artifact_transfers: ArtifactTransferTracker,
+    cache_transfers: TransferTracker,
     spaces_client_handle: Option<SpacesClientHandle>,
+    cache_analytics_client: Option<CacheAnalyticsClient>,
+    // Trusts the same certificate authorities as the API client, for requests
+    // to services other than the API
+    http_client: reqwest::Client,
     user: String,
     synthesized_command: String,
 }
@@ -134,10 +154,22 @@ impl RunTracker {
         spaces_id: Option<String>,
         spaces_api_client: APIClient,
         api_auth: Option<APIAuth>,
+        cache_transfers: TransferTracker,
+        cache_analytics: bool,
         user: String,
         scm: &SCM,
     ) -> Self {
         let scm = SCMState::get(env_at_execution_start, scm, repo_root);
+        // Clones of the API client share transfer counters, so this picks up
+        // retries made by the remote cache as well.
+        let artifact_transfers = spaces_api_client.artifact_transfers();
+        let http_client = spaces_api_client.cache_client().clone();
+
+        // Cache analytics can only be sent when linked to a remote cache
+        let cache_analytics_client = api_auth
+            .clone()
+            .filter(|_| cache_analytics)
+            .map(|api_auth| CacheAnalyticsClient::new(spaces_api_client.clone(), api_auth));
 
         let spaces_client_handle =
             SpacesClient::new(spaces_id.clone(), spaces_api_client, api_auth).and_then(
@@ -156,16 +188,26 @@ impl RunTracker {
             );
 
         RunTracker {
+            id: Ksuid::new(None, None),
             scm,
             version,
             started_at,
             execution_tracker: ExecutionTracker::new(),
+            artifact_transfers,
+            cache_transfers,
             user,
             synthesized_command,
             spaces_client_handle,
+            cache_analytics_client,
+            http_client,
         }
     }
 
+    /// The id of the run, which is also the id of its summary
+    pub fn id(&self) -> &Ksuid {
+        &self.id
+    }
+
     #[allow(clippy::too_many_arguments)]
     #[tracing::instrument(skip(
         repo_root,
@@ -204,6 +246,10 @@ impl RunTracker {
             .cloned()
             .map(|TaskState { task_id, execution }| task_factory.task_summary(task_id, execution))
             .collect::<Result<Vec<_>, task_factory::Error>>()?;
+        let time_saved = tasks
+            .iter()
+            .map(|task| task.shared.cache.time_saved())
+            .sum();
         let execution_summary = ExecutionSummary::new(
             self.synthesized_command.clone(),
             summary_state,
@@ -211,10 +257,13 @@ impl RunTracker {
             exit_code,
             self.started_at,
             end_time,
+            self.artifact_transfers.stats(),
+            self.cache_transfers.totals(),
+            time_saved,
         );
 
         Ok(RunSummary {
-            id: Ksuid::new(None, None),
+            id: self.id,
             version: RUN_SUMMARY_SCHEMA_VERSION.to_string(),
             turbo_version: self.version,
             packages: packages.iter().sorted().collect(),
@@ -229,7 +278,10 @@ impl RunTracker {
             repo_root,
             should_save,
             run_type,
+            otel_exporter_endpoint: run_opts.otel_exporter_endpoint.as_deref(),
+            http_client: self.http_client,
             spaces_client_handle: self.spaces_client_handle,
+            cache_analytics_client: self.cache_analytics_client,
         })
     }
 
@@ -381,11 +433,28 @@ impl<'a> RunSummary<'a> {
             }
         }
 
+        if let Some(endpoint) = self.otel_exporter_endpoint {
+            if let Err(err) = otel::export(&self.http_client, endpoint, &self).await {
+                warn!("Error exporting run trace: {}", err)
+            }
+        }
+
         if let Some(spaces_client_handle) = self.spaces_client_handle.take() {
             self.send_to_space(spaces_client_handle, end_time, exit_code)
                 .await;
         }
 
+        if let (Some(client), Some(execution)) = (&self.cache_analytics_client, &self.execution) {
+            let analytics = cache_analytics::run_cache_analytics(
+                self.tasks.iter().map(|task| &task.shared.cache),
+                execution.remote_cache_totals(),
+            );
+            // Like the run summary, failing to send analytics doesn't fail the run
+            if let Err(err) = client.send(&analytics).await {
+                warn!("Error sending cache analytics: {}", err);
+            }
+        }
+
         Ok(())
     }
 
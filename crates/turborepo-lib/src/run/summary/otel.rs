@@ -0,0 +1,311 @@
+//! Exports a finished run to an OpenTelemetry collector. Each run becomes a
+//! trace with a root span for the run and a child span for every task that
+//! was executed or restored from the cache.
+//!
+//! Spans are sent using the JSON encoding of OTLP over HTTP which every
+//! collector accepts and doesn't require pulling in a gRPC stack.
+
+use std::time::Duration;
+
+use serde::Serialize;
+use thiserror::Error;
+use tracing::debug;
+
+use super::{execution::TaskExecutionSummary, task::TaskSummary, RunSummary};
+
+const TRACES_PATH: &str = "v1/traces";
+const EXPORT_TIMEOUT: Duration = Duration::from_secs(10);
+
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+const SPAN_KIND_INTERNAL: u8 = 1;
+const STATUS_CODE_OK: u8 = 1;
+const STATUS_CODE_ERROR: u8 = 2;
+
+#[derive(Debug, Error)]
+pub enum Error {
+    #[error("failed to export trace to {url}: {source}")]
+    Request {
+        url: String,
+        #[source]
+        source: reqwest::Error,
+    },
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ExportTraceServiceRequest {
+    resource_spans: Vec<ResourceSpans>,
+}
+
+#[derive(Debug, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct ResourceSpans {
+    resource: Resource,
+    scope_spans: Vec<ScopeSpans>,
+}
+
+#[derive(Debug, Serialize)]
+struct Resource {
+    attributes: Vec<KeyValue>,
+}
+
+#[derive(Debug, Serialize)]
+struct ScopeSpans {
+    scope: Scope,
+    spans: Vec<Span>,
+}
+
+#[derive(Debug, Serialize)]
+struct Scope {
+    name: &'static str,
+    version: &'static str,
+}
+
+#[derive(Debug, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+struct Span {
+    trace_id: String,
+    span_id: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    parent_span_id: Option<String>,
+    name: String,
+    kind: u8,
+    // 64 bit integers are encoded as strings in OTLP JSON
+    start_time_unix_nano: String,
+    end_time_unix_nano: String,
+    attributes: Vec<KeyValue>,
+    status: Status,
+}
+
+#[derive(Debug, PartialEq, Serialize)]
+struct KeyValue {
+    key: &'static str,
+    value: AnyValue,
+}
+
+#[derive(Debug, PartialEq, Serialize)]
+#[serde(rename_all = "camelCase")]
+enum AnyValue {
+    StringValue(String),
+    IntValue(String),
+    BoolValue(bool),
+}
+
+#[derive(Debug, PartialEq, Serialize)]
+struct Status {
+    code: u8,
+}
+
+impl KeyValue {
+    fn string(key: &'static str, value: impl Into<String>) -> Self {
+        Self {
+            key,
+            value: AnyValue::StringValue(value.into()),
+        }
+    }
+
+    fn int(key: &'static str, value: i64) -> Self {
+        Self {
+            key,
+            value: AnyValue::IntValue(value.to_string()),
+        }
+    }
+
+    fn bool(key: &'static str, value: bool) -> Self {
+        Self {
+            key,
+            value: AnyValue::BoolValue(value),
+        }
+    }
+}
+
+impl Span {
+    fn new(
+        trace_id: &str,
+        parent_span_id: Option<&str>,
+        name: String,
+        start_time_ms: i64,
+        end_time_ms: i64,
+        attributes: Vec<KeyValue>,
+        failed: bool,
+    ) -> Self {
+        Self {
+            trace_id: trace_id.to_string(),
+            span_id: hex::encode(rand::random::<[u8; 8]>()),
+            parent_span_id: parent_span_id.map(|id| id.to_string()),
+            name,
+            kind: SPAN_KIND_INTERNAL,
+            start_time_unix_nano: millis_to_nanos(start_time_ms),
+            end_time_unix_nano: millis_to_nanos(end_time_ms),
+            attributes,
+            status: Status {
+                code: if failed {
+                    STATUS_CODE_ERROR
+                } else {
+                    STATUS_CODE_OK
+                },
+            },
+        }
+    }
+
+    fn for_task(
+        trace_id: &str,
+        parent_span_id: &str,
+        task: &TaskSummary,
+        execution: &TaskExecutionSummary,
+    ) -> Self {
+        let mut attributes = vec![
+            KeyValue::string("turbo.task.id", task.task_id.to_string()),
+            KeyValue::string("turbo.task.package", task.package.clone()),
+            KeyValue::string("turbo.task.name", task.task.clone()),
+            KeyValue::string("turbo.task.hash", task.shared.hash.clone()),
+            KeyValue::bool("turbo.task.cache_hit", task.shared.cache.is_hit()),
+        ];
+        if let Some(exit_code) = execution.exit_code {
+            attributes.push(KeyValue::int("turbo.task.exit_code", exit_code.into()));
+        }
+
+        Self::new(
+            trace_id,
+            Some(parent_span_id),
+            task.task_id.to_string(),
+            execution.start_time,
+            execution.end_time,
+            attributes,
+            execution.is_failure(),
+        )
+    }
+}
+
+fn millis_to_nanos(millis: i64) -> String {
+    (i128::from(millis) * 1_000_000).to_string()
+}
+
+fn trace_request(summary: &RunSummary) -> Option<ExportTraceServiceRequest> {
+    let execution = summary.execution.as_ref()?;
+    let trace_id = hex::encode(rand::random::<[u8; 16]>());
+
+    let mut run_attributes = vec![
+        KeyValue::string("turbo.run.id", summary.id.to_string()),
+        KeyValue::int("turbo.run.exit_code", execution.exit_code.into()),
+    ];
+    if let Some(branch) = &summary.scm.branch {
+        run_attributes.push(KeyValue::string("vcs.branch", branch.clone()));
+    }
+    if let Some(sha) = &summary.scm.sha {
+        run_attributes.push(KeyValue::string("vcs.sha", sha.clone()));
+    }
+    let run_span = Span::new(
+        &trace_id,
+        None,
+        "turbo run".to_string(),
+        execution.start_time,
+        execution.end_time,
+        run_attributes,
+        execution.exit_code != 0,
+    );
+
+    let task_spans = summary.tasks.iter().filter_map(|task| {
+        let task_execution = task.shared.execution.as_ref()?;
+        Some(Span::for_task(
+            &trace_id,
+            &run_span.span_id,
+            task,
+            task_execution,
+        ))
+    });
+    let spans = task_spans.collect::<Vec<_>>();
+
+    Some(ExportTraceServiceRequest {
+        resource_spans: vec![ResourceSpans {
+            resource: Resource {
+                attributes: vec![
+                    KeyValue::string("service.name", "turbo"),
+                    KeyValue::string("service.version", summary.turbo_version),
+                ],
+            },
+            scope_spans: vec![ScopeSpans {
+                scope: Scope {
+                    name: "turbo",
+                    version: summary.turbo_version,
+                },
+                spans: std::iter::once(run_span).chain(spans).collect(),
+            }],
+        }],
+    })
+}
+
+/// Sends the trace for `summary` to the collector at `endpoint` with `client`,
+/// which only limits the time to connect, so the export sets its own timeout.
+pub async fn export(
+    client: &reqwest::Client,
+    endpoint: &str,
+    summary: &RunSummary<'_>,
+) -> Result<(), Error> {
+    let Some(request) = trace_request(summary) else {
+        return Ok(());
+    };
+    let url = format!("{}/{TRACES_PATH}", endpoint.trim_end_matches('/'));
+    debug!("exporting run trace to {url}");
+
+    client
+        .post(&url)
+        .timeout(EXPORT_TIMEOUT)
+        .json(&request)
+        .send()
+        .await
+        .and_then(|response| response.error_for_status())
+        .map_err(|source| Error::Request { url, source })?;
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod test {
+    use serde_json::json;
+
+    use super::*;
+
+    #[test]
+    fn test_span_serialization() {
+        let span = Span::new(
+            "5b8efff798038103d269b633813fc60c",
+            Some("eee19b7ec3c1b174"),
+            "web#build".to_string(),
+            1_700_000_000_000,
+            1_700_000_001_500,
+            vec![
+                KeyValue::string("turbo.task.hash", "2f192ed93e20f940"),
+                KeyValue::bool("turbo.task.cache_hit", false),
+                KeyValue::int("turbo.task.exit_code", 1),
+            ],
+            true,
+        );
+
+        let mut actual = serde_json::to_value(&span).unwrap();
+        // Span ids are random
+        assert_eq!(actual["spanId"].as_str().unwrap().len(), 16);
+        actual.as_object_mut().unwrap().remove("spanId");
+
+        assert_eq!(
+            actual,
+            json!({
+                "traceId": "5b8efff798038103d269b633813fc60c",
+                "parentSpanId": "eee19b7ec3c1b174",
+                "name": "web#build",
+                "kind": 1,
+                "startTimeUnixNano": "1700000000000000000",
+                "endTimeUnixNano": "1700000001500000000",
+                "attributes": [
+                    {
+                        "key": "turbo.task.hash",
+                        "value": { "stringValue": "2f192ed93e20f940" }
+                    },
+                    { "key": "turbo.task.cache_hit", "value": { "boolValue": false } },
+                    { "key": "turbo.task.exit_code", "value": { "intValue": "1" } },
+                ],
+                "status": { "code": 2 },
+            })
+        );
+    }
+}
@@ -8,6 +8,8 @@ use turborepo_scm::SCM;
 #[serde(rename_all = "lowercase")]
 enum SCMType {
     Git,
+    Hg,
+    Manual,
 }
 
 #[derive(Clone, Debug, Deserialize, Serialize)]
@@ -21,7 +23,11 @@ pub(crate) struct SCMState {
 impl SCMState {
     pub fn get(env_vars: &EnvironmentVariableMap, scm: &SCM, dir: &AbsoluteSystemPath) -> Self {
         let mut state = SCMState {
-            ty: SCMType::Git,
+            ty: match scm {
+                SCM::Git(_) => SCMType::Git,
+                SCM::Hg(_) => SCMType::Hg,
+                SCM::Manual => SCMType::Manual,
+            },
             sha: None,
             branch: None,
         };
@@ -38,7 +44,7 @@ impl SCMState {
             }
         }
 
-        // Fall back to using `git`
+        // Fall back to using the SCM
         if state.branch.is_none() && state.sha.is_none() {
             if state.branch.is_none() {
                 state.branch = scm.get_current_branch(dir).ok();
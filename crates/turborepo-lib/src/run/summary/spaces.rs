@@ -356,7 +356,7 @@ mod tests {
     use test_case::test_case;
     use turborepo_api_client::{
         spaces::{CreateSpaceRunPayload, SpaceTaskSummary},
-        APIAuth, APIClient,
+        APIAuth, APIClient, CacheTimeouts, TlsOptions,
     };
     use turborepo_vercel_api_mock::{
         start_test_server, EXPECTED_SPACE_ID, EXPECTED_SPACE_RUN_ID, EXPECTED_TEAM_ID,
@@ -377,9 +377,10 @@ mod tests {
         let api_client = APIClient::new(
             format!("http://localhost:{}", port),
             Some(Duration::from_secs(2)),
-            None,
+            CacheTimeouts::default(),
             "",
             true,
+            &TlsOptions::default(),
         )?;
 
         let api_auth = Some(APIAuth {
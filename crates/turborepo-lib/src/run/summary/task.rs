@@ -9,7 +9,8 @@ use super::{execution::TaskExecutionSummary, EnvMode};
 use crate::{
     cli::OutputLogsMode,
     run::task_id::TaskId,
-    task_graph::{TaskDefinition, TaskOutputs},
+    task_graph::{ReadyProbe, TaskDefinition, TaskOutputs, TaskShell},
+    task_hash::TaskHashInputs,
 };
 
 #[derive(Debug, Serialize, Clone)]
@@ -25,6 +26,10 @@ pub struct TaskCacheSummary {
     source: Option<CacheSource>,
     // 0 if a cache miss
     time_saved: u64,
+    // Set for remote hits of tasks with local only outputs, which aren't
+    // uploaded and so weren't restored
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    partial: bool,
 }
 
 #[derive(Debug, Serialize, Copy, Clone)]
@@ -83,6 +88,21 @@ pub(crate) struct SharedTaskSummary<T> {
     pub environment_variables: TaskEnvVarSummary,
     #[serde(skip_serializing_if = "Option::is_none")]
     pub execution: Option<TaskExecutionSummary>,
+    // Only included for --dry=json
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub hash_inputs: Option<TaskHashInputsSummary<T>>,
+}
+
+/// Everything that contributed to a task's hash, grouped together so that two
+/// dry runs can be diffed to find the cause of a cache miss.
+#[derive(Debug, Serialize, Clone)]
+#[serde(rename_all = "camelCase")]
+pub(crate) struct TaskHashInputsSummary<T> {
+    #[serde(flatten)]
+    pub hashed: TaskHashInputs,
+    pub files: BTreeMap<RelativeUnixPathBuf, String>,
+    pub dependencies: BTreeMap<T, String>,
+    pub resolved_task_definition: TaskSummaryTaskDefinition,
 }
 
 #[derive(Debug, Serialize, Clone)]
@@ -96,14 +116,41 @@ pub struct TaskEnvConfiguration {
 #[serde(rename_all = "camelCase")]
 pub struct TaskSummaryTaskDefinition {
     outputs: Vec<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    local_only_outputs: Vec<String>,
     cache: bool,
     depends_on: Vec<String>,
     inputs: Vec<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    input_transforms: Vec<String>,
     output_logs: OutputLogsMode,
     persistent: bool,
     env: Vec<String>,
     pass_through_env: Option<Vec<String>>,
     interactive: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    concurrency_group: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    ready: Option<ReadyProbe>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    tool_dependencies: Vec<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    dot_env: Vec<String>,
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    inject_dot_env: bool,
+    // Only shown when it isn't the default of 1
+    #[serde(skip_serializing_if = "Option::is_none")]
+    weight: Option<u32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    timeout: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    runner: Option<String>,
+    #[serde(skip_serializing_if = "TaskShell::is_default")]
+    shell: TaskShell,
+    #[serde(skip_serializing_if = "std::ops::Not::not")]
+    follow_symlinks: bool,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    compression_level: Option<i32>,
 }
 
 #[derive(Debug, Serialize, Clone)]
@@ -124,8 +171,28 @@ impl TaskCacheSummary {
             status: CacheStatus::Miss,
             time_saved: 0,
             source: None,
+            partial: false,
         }
     }
+
+    /// Marks a remote hit as partial if the task has local only outputs
+    pub fn with_local_only_outputs(mut self, has_local_only_outputs: bool) -> Self {
+        self.partial = self.remote && has_local_only_outputs;
+        self
+    }
+
+    pub fn is_hit(&self) -> bool {
+        matches!(self.status, CacheStatus::Hit)
+    }
+
+    pub fn is_remote_hit(&self) -> bool {
+        matches!(self.source, Some(CacheSource::Remote))
+    }
+
+    /// Milliseconds that the task took when it was cached
+    pub fn time_saved(&self) -> u64 {
+        self.time_saved
+    }
 }
 
 impl From<Option<CacheHitMetadata>> for TaskCacheSummary {
@@ -151,6 +218,7 @@ impl From<Option<CacheHitMetadata>> for TaskCacheSummary {
                     status: CacheStatus::Hit,
                     source: Some(source),
                     time_saved,
+                    partial: false,
                 }
             }
             None => Self::cache_miss(),
@@ -172,6 +240,7 @@ impl TaskEnvVarSummary {
         task_definition: &TaskDefinition,
         env_vars: DetailedMap,
         env_at_execution_start: &EnvironmentVariableMap,
+        env_hash_salt: Option<&str>,
     ) -> Result<Self, turborepo_env::Error> {
         // TODO: this operation differs from the actual env that gets passed in during
         // task execution it should be unified, but first we should copy Go's
@@ -182,7 +251,7 @@ impl TaskEnvVarSummary {
             .map(|pass_through_env| -> Result<_, turborepo_env::Error> {
                 Ok(env_at_execution_start
                     .from_wildcards(pass_through_env)?
-                    .to_secret_hashable())
+                    .to_salted_hashable(env_hash_salt))
             })
             .transpose()?;
 
@@ -191,8 +260,14 @@ impl TaskEnvVarSummary {
                 env: task_definition.env.clone(),
                 pass_through_env: task_definition.pass_through_env.clone(),
             },
-            configured: env_vars.by_source.explicit.to_secret_hashable(),
-            inferred: env_vars.by_source.matching.to_secret_hashable(),
+            configured: env_vars
+                .by_source
+                .explicit
+                .to_salted_hashable(env_hash_salt),
+            inferred: env_vars
+                .by_source
+                .matching
+                .to_salted_hashable(env_hash_salt),
             pass_through,
         })
     }
@@ -231,6 +306,7 @@ impl From<SharedTaskSummary<TaskId<'static>>> for SharedTaskSummary<String> {
             execution,
             env_mode,
             environment_variables,
+            hash_inputs,
             ..
         } = value;
         Self {
@@ -258,6 +334,27 @@ impl From<SharedTaskSummary<TaskId<'static>>> for SharedTaskSummary<String> {
             execution,
             env_mode,
             environment_variables,
+            hash_inputs: hash_inputs.map(|hash_inputs| hash_inputs.into()),
+        }
+    }
+}
+
+impl From<TaskHashInputsSummary<TaskId<'static>>> for TaskHashInputsSummary<String> {
+    fn from(value: TaskHashInputsSummary<TaskId<'static>>) -> Self {
+        let TaskHashInputsSummary {
+            hashed,
+            files,
+            dependencies,
+            resolved_task_definition,
+        } = value;
+        Self {
+            hashed,
+            files,
+            dependencies: dependencies
+                .into_iter()
+                .map(|(task_id, hash)| (task_id.task().to_string(), hash))
+                .collect(),
+            resolved_task_definition,
         }
     }
 }
@@ -270,21 +367,42 @@ impl From<TaskDefinition> for TaskSummaryTaskDefinition {
                     inclusions,
                     exclusions,
                 },
+            local_only_outputs:
+                TaskOutputs {
+                    inclusions: local_only_inclusions,
+                    exclusions: local_only_exclusions,
+                },
             cache,
             mut env,
             pass_through_env,
             topological_dependencies,
             task_dependencies,
             mut inputs,
+            input_transforms,
             output_logs,
             persistent,
             interactive,
+            concurrency_group,
+            ready,
+            tool_dependencies,
+            dot_env,
+            inject_dot_env,
+            weight,
+            timeout,
+            runner,
+            shell,
+            follow_symlinks,
+            compression_level,
         } = value;
 
         let mut outputs = inclusions;
         for exclusion in exclusions {
             outputs.push(format!("!{exclusion}"));
         }
+        let mut local_only_outputs = local_only_inclusions;
+        for exclusion in local_only_exclusions {
+            local_only_outputs.push(format!("!{exclusion}"));
+        }
 
         let mut depends_on =
             Vec::with_capacity(task_dependencies.len() + topological_dependencies.len());
@@ -300,19 +418,39 @@ impl From<TaskDefinition> for TaskSummaryTaskDefinition {
         // also, just in case something in the middle mutates the items.
         depends_on.sort();
         outputs.sort();
+        local_only_outputs.sort();
         env.sort();
         inputs.sort();
 
+        // Transforms are applied in order, so they are left unsorted
+        let input_transforms = input_transforms
+            .iter()
+            .map(|transform| transform.to_string())
+            .collect();
+
         Self {
             outputs,
+            local_only_outputs,
             cache,
             depends_on,
             inputs,
+            input_transforms,
             output_logs,
             persistent,
             interactive,
             env,
             pass_through_env,
+            concurrency_group,
+            ready,
+            tool_dependencies,
+            dot_env,
+            inject_dot_env,
+            weight: (weight != 1).then_some(weight),
+            timeout: timeout.map(|timeout| humantime::format_duration(timeout).to_string()),
+            runner,
+            shell,
+            follow_symlinks,
+            compression_level,
         }
     }
 }
@@ -345,6 +483,7 @@ mod test {
             status: CacheStatus::Hit,
             source: Some(CacheSource::Local),
             time_saved: 6,
+            partial: false,
         },
         serde_json::json!({
                 "local": true,
@@ -355,6 +494,37 @@ mod test {
             })
         ; "local cache hit"
     )]
+    #[test_case(
+        TaskCacheSummary::from(Some(CacheHitMetadata {
+            source: turborepo_cache::CacheSource::Remote,
+            time_saved: 6,
+        }))
+        .with_local_only_outputs(true),
+        serde_json::json!({
+                "local": false,
+                "remote": true,
+                "status": "HIT",
+                "source": "REMOTE",
+                "timeSaved": 6,
+                "partial": true,
+            })
+        ; "partial remote cache hit"
+    )]
+    #[test_case(
+        TaskCacheSummary::from(Some(CacheHitMetadata {
+            source: turborepo_cache::CacheSource::Local,
+            time_saved: 6,
+        }))
+        .with_local_only_outputs(true),
+        serde_json::json!({
+                "local": true,
+                "remote": false,
+                "status": "HIT",
+                "source": "LOCAL",
+                "timeSaved": 6,
+            })
+        ; "local cache hit with local only outputs"
+    )]
     #[test_case(
         TaskSummaryTaskDefinition {
             outputs: vec!["foo".into()],
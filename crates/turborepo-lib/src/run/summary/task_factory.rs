@@ -1,15 +1,15 @@
-use std::collections::HashSet;
+use std::collections::{BTreeMap, HashSet};
 
 use turborepo_env::EnvironmentVariableMap;
 use turborepo_repository::package_graph::{PackageGraph, PackageInfo, PackageName};
 
 use super::{
     execution::TaskExecutionSummary,
-    task::{SharedTaskSummary, TaskEnvVarSummary},
+    task::{SharedTaskSummary, TaskCacheSummary, TaskEnvVarSummary, TaskHashInputsSummary},
     SinglePackageTaskSummary, TaskSummary,
 };
 use crate::{
-    cli,
+    cli::{self, DryRunMode},
     engine::{Engine, TaskNode},
     opts::RunOpts,
     run::task_id::TaskId,
@@ -102,7 +102,7 @@ impl<'a> TaskSummaryFactory<'a> {
         })
     }
 
-    fn shared<T>(
+    fn shared<T: Ord>(
         &self,
         task_id: &TaskId<'static>,
         execution: Option<TaskExecutionSummary>,
@@ -141,10 +141,34 @@ impl<'a> TaskSummaryFactory<'a> {
             .env_vars(task_id)
             .expect("env var map is inserted at the same time as hash");
 
-        let cache_summary = self.hash_tracker.cache_status(task_id).into();
+        let cache_summary = TaskCacheSummary::from(self.hash_tracker.cache_status(task_id))
+            .with_local_only_outputs(!task_definition.local_only_outputs.inclusions.is_empty());
 
         let (dependencies, dependents) = self.dependencies_and_dependents(task_id, display_task);
 
+        let hash_inputs = match self.run_opts.dry_run {
+            Some(DryRunMode::Json) => self.hash_tracker.hash_inputs(task_id).map(|hashed| {
+                let dependencies = hashed
+                    .task_dependency_hashes
+                    .iter()
+                    .filter_map(|(dependency, hash)| {
+                        display_task(&TaskNode::Task(dependency.clone()))
+                            .map(|dependency| (dependency, hash.clone()))
+                    })
+                    .collect::<BTreeMap<_, _>>();
+                TaskHashInputsSummary {
+                    hashed,
+                    files: expanded_inputs
+                        .iter()
+                        .map(|(path, hash)| (path.clone(), hash.clone()))
+                        .collect(),
+                    dependencies,
+                    resolved_task_definition: task_definition.clone().into(),
+                }
+            }),
+            _ => None,
+        };
+
         let log_file = {
             let path = workspace_info.package_path().to_owned();
             let relative_log_file = TaskDefinition::workspace_relative_log_file(task_id.task());
@@ -180,9 +204,11 @@ impl<'a> TaskSummaryFactory<'a> {
                 task_definition,
                 env_vars,
                 self.env_at_start,
+                self.run_opts.env_hash_salt.as_deref(),
             )
             .expect("invalid glob in task definition should have been caught earlier"),
             execution,
+            hash_inputs,
         })
     }
 
@@ -0,0 +1,392 @@
+//! Predicts how long a run will take for `--time-budget`, using how long each
+//! task took the last time it ran according to the saved run summaries.
+//! Tasks that will hit the cache are predicted to take as long as their last
+//! cache hit did. A run can't finish sooner than its critical path, the longest
+//! chain of dependent tasks, or sooner than all of its tasks spread across the
+//! available concurrency.
+
+use std::{
+    collections::{HashMap, HashSet},
+    fs,
+    time::{Duration, SystemTime},
+};
+
+use serde::Deserialize;
+use tracing::debug;
+use turbopath::AbsoluteSystemPath;
+
+use crate::{
+    engine::{Engine, TaskNode},
+    run::task_id::TaskId,
+};
+
+const RUNS_DIR: [&str; 2] = [".turbo", "runs"];
+// Reading every summary ever saved would slow down starting a run
+const MAX_SUMMARIES: usize = 20;
+
+#[derive(Debug, Deserialize)]
+struct SavedRunSummary {
+    #[serde(default)]
+    tasks: Vec<SavedTaskSummary>,
+}
+
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct SavedTaskSummary {
+    task_id: String,
+    cache: SavedCacheSummary,
+    execution: Option<SavedExecutionSummary>,
+}
+
+#[derive(Debug, Deserialize)]
+struct SavedCacheSummary {
+    status: String,
+}
+
+#[derive(Debug, Deserialize)]
+#[serde(rename_all = "camelCase")]
+struct SavedExecutionSummary {
+    duration: u64,
+    exit_code: Option<i32>,
+}
+
+/// How long tasks took the last time they ran, by task id
+#[derive(Debug, Default, PartialEq)]
+pub struct Durations {
+    // Runs that missed the cache and executed the task
+    misses: HashMap<String, Duration>,
+    // Runs that restored the task's outputs from the cache
+    hits: HashMap<String, Duration>,
+}
+
+impl Durations {
+    // How long the task is predicted to take, or `None` if it hasn't run before
+    fn predicted(&self, task_id: &TaskId, cache_hit: bool) -> Option<Duration> {
+        let task_id = task_id.to_string();
+        match cache_hit {
+            // Restoring outputs that were never timed is assumed to be quick
+            true => Some(self.hits.get(&task_id).copied().unwrap_or_default()),
+            false => self.misses.get(&task_id).copied(),
+        }
+    }
+}
+
+// How long it takes until each task finishes, and the dependency that it
+// waits on the longest
+type Finishes = HashMap<TaskId<'static>, (Duration, Option<TaskId<'static>>)>;
+
+#[derive(Debug, Clone, PartialEq)]
+pub struct Prediction {
+    pub duration: Duration,
+    // The tasks on the critical path with their predicted durations, in the
+    // order they run
+    pub critical_path: Vec<(TaskId<'static>, Duration)>,
+    // Tasks that haven't run before, which are predicted to take no time
+    pub unknown_tasks: usize,
+}
+
+/// Reads how long each task took the last time it ran and succeeded, from
+/// the most recent run summaries in `.turbo/runs`. Cache hits and misses are
+/// kept apart, since a hit only says how long restoring the outputs takes.
+pub fn load_durations(repo_root: &AbsoluteSystemPath) -> Durations {
+    let runs_dir = repo_root.join_components(&RUNS_DIR);
+    let Ok(entries) = fs::read_dir(&runs_dir) else {
+        return Durations::default();
+    };
+    let mut summaries = entries
+        .filter_map(|entry| {
+            let entry = entry.ok()?;
+            let path = entry.path();
+            if path.extension()? != "json" {
+                return None;
+            }
+            let modified = entry
+                .metadata()
+                .and_then(|metadata| metadata.modified())
+                .unwrap_or(SystemTime::UNIX_EPOCH);
+            Some((modified, path))
+        })
+        .collect::<Vec<_>>();
+    summaries.sort_by(|a, b| b.0.cmp(&a.0));
+
+    let mut durations = Durations::default();
+    for (_, path) in summaries.into_iter().take(MAX_SUMMARIES) {
+        let summary = match fs::read(&path)
+            .map_err(|err| err.to_string())
+            .and_then(|contents| {
+                serde_json::from_slice::<SavedRunSummary>(&contents).map_err(|err| err.to_string())
+            }) {
+            Ok(summary) => summary,
+            Err(err) => {
+                debug!("skipping run summary {}: {err}", path.display());
+                continue;
+            }
+        };
+        record_durations(&mut durations, summary);
+    }
+
+    durations
+}
+
+// Summaries are recorded from newest to oldest, so the first duration seen for
+// a task is kept
+fn record_durations(durations: &mut Durations, summary: SavedRunSummary) {
+    for task in summary.tasks {
+        let Some(execution) = task.execution else {
+            continue;
+        };
+        if execution.exit_code != Some(0) {
+            continue;
+        }
+        let durations = match task.cache.status.as_str() {
+            "MISS" => &mut durations.misses,
+            "HIT" => &mut durations.hits,
+            _ => continue,
+        };
+        durations
+            .entry(task.task_id)
+            .or_insert(Duration::from_millis(execution.duration));
+    }
+}
+
+/// Predicts how long running the tasks in `engine` will take, where
+/// `cache_hits` are the tasks that are in the cache. Persistent tasks never
+/// finish, so they aren't part of the prediction.
+pub fn predict(
+    engine: &Engine,
+    durations: &Durations,
+    cache_hits: &HashSet<TaskId<'static>>,
+    concurrency: u32,
+) -> Prediction {
+    let tasks = engine
+        .tasks()
+        .filter_map(|node| match node {
+            TaskNode::Task(task_id) => Some(task_id.clone()),
+            TaskNode::Root => None,
+        })
+        .filter(|task_id| {
+            !engine
+                .task_definition(task_id)
+                .map_or(false, |definition| definition.persistent)
+        })
+        .collect::<Vec<_>>();
+
+    predict_tasks(
+        &tasks,
+        |task_id| {
+            engine
+                .dependencies(task_id)
+                .into_iter()
+                .flatten()
+                .filter_map(|node| match node {
+                    TaskNode::Task(dependency) => Some(dependency.clone()),
+                    TaskNode::Root => None,
+                })
+                .collect()
+        },
+        |task_id| durations.predicted(task_id, cache_hits.contains(task_id)),
+        |task_id| {
+            engine
+                .task_definition(task_id)
+                .map_or(1, |definition| definition.weight)
+        },
+        concurrency,
+    )
+}
+
+fn predict_tasks(
+    tasks: &[TaskId<'static>],
+    dependencies: impl Fn(&TaskId<'static>) -> Vec<TaskId<'static>>,
+    predicted_duration: impl Fn(&TaskId<'static>) -> Option<Duration>,
+    weight: impl Fn(&TaskId<'static>) -> u32,
+    concurrency: u32,
+) -> Prediction {
+    let duration_of = |task_id: &TaskId<'static>| predicted_duration(task_id).unwrap_or_default();
+
+    let mut finishes = Finishes::new();
+    let mut end: Option<(Duration, &TaskId<'static>)> = None;
+    for task_id in tasks {
+        let finished = finish(task_id, &dependencies, &duration_of, &mut finishes);
+        if end.map_or(true, |(end, _)| finished > end) {
+            end = Some((finished, task_id));
+        }
+    }
+
+    let mut critical_path = Vec::new();
+    let mut next = end.map(|(_, task_id)| task_id.clone());
+    while let Some(task_id) = next {
+        next = finishes
+            .get(&task_id)
+            .and_then(|(_, dependency)| dependency.clone());
+        let duration = duration_of(&task_id);
+        critical_path.push((task_id, duration));
+    }
+    critical_path.reverse();
+
+    let critical_path_duration = end.map(|(end, _)| end).unwrap_or_default();
+    // A task takes up as many slots as its weight, capped at the concurrency
+    // like when it runs
+    let concurrency = concurrency.max(1);
+    let total = tasks
+        .iter()
+        .map(|task_id| duration_of(task_id) * weight(task_id).clamp(1, concurrency))
+        .sum::<Duration>();
+    let spread = total / concurrency;
+
+    Prediction {
+        duration: critical_path_duration.max(spread),
+        critical_path,
+        unknown_tasks: tasks
+            .iter()
+            .filter(|task_id| predicted_duration(task_id).is_none())
+            .count(),
+    }
+}
+
+// Returns how long it takes until the task finishes, recording the dependency
+// that it waits on the longest
+fn finish(
+    task_id: &TaskId<'static>,
+    dependencies: &impl Fn(&TaskId<'static>) -> Vec<TaskId<'static>>,
+    duration_of: &impl Fn(&TaskId<'static>) -> Duration,
+    finishes: &mut Finishes,
+) -> Duration {
+    if let Some((finished, _)) = finishes.get(task_id) {
+        return *finished;
+    }
+    let mut longest: Option<(Duration, TaskId<'static>)> = None;
+    for dependency in dependencies(task_id) {
+        let finished = finish(&dependency, dependencies, duration_of, finishes);
+        if longest
+            .as_ref()
+            .map_or(true, |(longest, _)| finished > *longest)
+        {
+            longest = Some((finished, dependency));
+        }
+    }
+    let (waited, longest_dependency) = match longest {
+        Some((waited, dependency)) => (waited, Some(dependency)),
+        None => (Duration::ZERO, None),
+    };
+    let finished = waited + duration_of(task_id);
+    finishes.insert(task_id.clone(), (finished, longest_dependency));
+    finished
+}
+
+#[cfg(test)]
+mod test {
+    use std::{collections::HashMap, time::Duration};
+
+    use super::{predict_tasks, record_durations, Durations, SavedRunSummary};
+    use crate::run::task_id::TaskId;
+
+    #[test]
+    fn test_record_durations() {
+        let newer: SavedRunSummary = serde_json::from_str(
+            r#"{"tasks": [
+                {"taskId": "web#build", "cache": {"status": "MISS"}, "execution": {"duration": 3000, "exitCode": 0}},
+                {"taskId": "web#lint", "cache": {"status": "HIT"}, "execution": {"duration": 10, "exitCode": 0}},
+                {"taskId": "web#test", "cache": {"status": "MISS"}, "execution": {"duration": 500, "exitCode": 1}}
+            ]}"#,
+        )
+        .unwrap();
+        let older: SavedRunSummary = serde_json::from_str(
+            r#"{"tasks": [
+                {"taskId": "web#build", "cache": {"status": "MISS"}, "execution": {"duration": 1000, "exitCode": 0}},
+                {"taskId": "web#lint", "cache": {"status": "MISS"}, "execution": {"duration": 2000, "exitCode": 0}}
+            ]}"#,
+        )
+        .unwrap();
+
+        let mut durations = Durations::default();
+        record_durations(&mut durations, newer);
+        record_durations(&mut durations, older);
+
+        assert_eq!(
+            durations,
+            Durations {
+                misses: HashMap::from([
+                    ("web#build".to_string(), Duration::from_secs(3)),
+                    ("web#lint".to_string(), Duration::from_secs(2)),
+                ]),
+                hits: HashMap::from([("web#lint".to_string(), Duration::from_millis(10))]),
+            }
+        );
+    }
+
+    #[test]
+    fn test_predict_tasks() {
+        let ui_build = TaskId::new("ui", "build");
+        let web_build = TaskId::new("web", "build");
+        let docs_build = TaskId::new("docs", "build");
+        let web_lint = TaskId::new("web", "lint");
+        let tasks = vec![
+            ui_build.clone(),
+            web_build.clone(),
+            docs_build.clone(),
+            web_lint.clone(),
+        ];
+        let durations = HashMap::from([
+            ("ui#build".to_string(), Duration::from_secs(60)),
+            ("web#build".to_string(), Duration::from_secs(120)),
+            ("docs#build".to_string(), Duration::from_secs(30)),
+        ]);
+        let dependencies = |task_id: &TaskId<'static>| {
+            if task_id.task() == "build" && task_id.package() != "ui" {
+                vec![TaskId::new("ui", "build")]
+            } else {
+                vec![]
+            }
+        };
+
+        let predicted_duration =
+            |task_id: &TaskId<'static>| durations.get(&task_id.to_string()).copied();
+        let weight = |_: &TaskId<'static>| 1;
+
+        let prediction = predict_tasks(&tasks, dependencies, predicted_duration, weight, 10);
+        assert_eq!(prediction.duration, Duration::from_secs(180));
+        assert_eq!(
+            prediction.critical_path,
+            vec![
+                (ui_build, Duration::from_secs(60)),
+                (web_build, Duration::from_secs(120)),
+            ]
+        );
+        assert_eq!(prediction.unknown_tasks, 1);
+
+        // With a single task at a time everything runs one after another
+        let prediction = predict_tasks(&tasks, dependencies, predicted_duration, weight, 1);
+        assert_eq!(prediction.duration, Duration::from_secs(210));
+
+        // Tasks that take up every slot also run one after another
+        let prediction = predict_tasks(&tasks, dependencies, predicted_duration, |_| 2, 2);
+        assert_eq!(prediction.duration, Duration::from_secs(210));
+        let prediction = predict_tasks(&tasks, dependencies, predicted_duration, weight, 2);
+        assert_eq!(prediction.duration, Duration::from_secs(180));
+    }
+
+    #[test]
+    fn test_predicted_duration() {
+        let durations = Durations {
+            misses: HashMap::from([
+                ("web#build".to_string(), Duration::from_secs(60)),
+                ("docs#build".to_string(), Duration::from_secs(30)),
+            ]),
+            hits: HashMap::from([("web#build".to_string(), Duration::from_secs(2))]),
+        };
+        let web_build = TaskId::new("web", "build");
+        let docs_build = TaskId::new("docs", "build");
+        let ui_build = TaskId::new("ui", "build");
+
+        assert_eq!(
+            durations.predicted(&web_build, false),
+            Some(Duration::from_secs(60))
+        );
+        assert_eq!(
+            durations.predicted(&web_build, true),
+            Some(Duration::from_secs(2))
+        );
+        assert_eq!(durations.predicted(&docs_build, true), Some(Duration::ZERO));
+        assert_eq!(durations.predicted(&ui_build, false), None);
+    }
+}
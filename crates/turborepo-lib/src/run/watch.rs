@@ -50,6 +50,8 @@ pub struct WatchClient {
     persistent_tasks_handle: Option<JoinHandle<Result<i32, run::Error>>>,
     connector: DaemonConnector,
     base: CommandBase,
+    // The arguments of `turbo run --watch`, which every run is started with
+    run_args: Box<RunArgs>,
     telemetry: CommandEventBuilder,
     handler: SignalHandler,
     ui_sender: Option<AppSender>,
@@ -98,7 +100,11 @@ pub enum Error {
 }
 
 impl WatchClient {
-    pub async fn new(base: CommandBase, telemetry: CommandEventBuilder) -> Result<Self, Error> {
+    pub async fn new(
+        base: CommandBase,
+        run_args: Box<RunArgs>,
+        telemetry: CommandEventBuilder,
+    ) -> Result<Self, Error> {
         let signal = commands::run::get_signal()?;
         let handler = SignalHandler::new(signal);
 
@@ -108,7 +114,7 @@ impl WatchClient {
 
         let mut new_base = base.clone();
         new_base.args_mut().command = Some(Command::Run {
-            run_args: Box::default(),
+            run_args: run_args.clone(),
             execution_args: execution_args.clone(),
         });
 
@@ -128,6 +134,7 @@ impl WatchClient {
 
         Ok(Self {
             base,
+            run_args,
             run,
             watched_packages,
             connector,
@@ -226,6 +233,16 @@ impl WatchClient {
         Ok(())
     }
 
+    // Runs started by changes don't write to the cache and always use the
+    // daemon
+    fn rerun_args(&self) -> Box<RunArgs> {
+        Box::new(RunArgs {
+            no_cache: true,
+            daemon: true,
+            ..(*self.run_args).clone()
+        })
+    }
+
     async fn execute_run(&mut self, changed_packages: ChangedPackages) -> Result<i32, Error> {
         // Should we recover here?
         match changed_packages {
@@ -243,11 +260,7 @@ impl WatchClient {
                     if let Command::Watch(execution_args) = c {
                         Command::Run {
                             execution_args,
-                            run_args: Box::new(RunArgs {
-                                no_cache: true,
-                                daemon: true,
-                                ..Default::default()
-                            }),
+                            run_args: self.rerun_args(),
                         }
                     } else {
                         unreachable!()
@@ -277,11 +290,7 @@ impl WatchClient {
                 args.command = args.command.map(|c| {
                     if let Command::Watch(execution_args) = c {
                         Command::Run {
-                            run_args: Box::new(RunArgs {
-                                no_cache: true,
-                                daemon: true,
-                                ..Default::default()
-                            }),
+                            run_args: self.rerun_args(),
                             execution_args,
                         }
                     } else {
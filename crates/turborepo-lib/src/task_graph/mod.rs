@@ -1,11 +1,17 @@
+mod ready;
+mod shell;
 mod visitor;
 
-use std::str::FromStr;
+use std::{str::FromStr, time::Duration};
 
 use globwalk::{GlobError, ValidatedGlob};
+pub use ready::ReadyProbe;
 use serde::{Deserialize, Serialize};
+pub use shell::{CommandLine, TaskShell};
 use turbopath::{AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPathBuf};
 use turborepo_errors::Spanned;
+use turborepo_repository::package_graph::PackageInfo;
+use turborepo_scm::{package_deps::INPUT_INCLUDE_DEFAULT_FILES, transform::InputTransform};
 pub use visitor::{Error as VisitorError, Visitor};
 
 use crate::{
@@ -23,9 +29,18 @@ pub struct TaskOutputs {
 }
 
 impl TaskOutputs {
+    /// Whether the outputs include the files the task writes that are ignored
+    /// by git, which is requested with `$TURBO_DEFAULT$`
+    pub fn infers_outputs(&self) -> bool {
+        self.inclusions
+            .iter()
+            .any(|i| i == INPUT_INCLUDE_DEFAULT_FILES)
+    }
+
     pub fn validated_inclusions(&self) -> Result<Vec<ValidatedGlob>, GlobError> {
         self.inclusions
             .iter()
+            .filter(|i| *i != INPUT_INCLUDE_DEFAULT_FILES)
             .map(|i| ValidatedGlob::from_str(i))
             .collect()
     }
@@ -42,6 +57,11 @@ impl TaskOutputs {
 #[derive(Debug, PartialEq, Clone, Eq)]
 pub struct TaskDefinition {
     pub outputs: TaskOutputs,
+
+    // LocalOnlyOutputs are outputs that are stored in the local cache, but never
+    // uploaded to the remote cache. They are also part of Outputs.
+    pub(crate) local_only_outputs: TaskOutputs,
+
     pub(crate) cache: bool,
 
     // This field is custom-marshalled from `env` and `depends_on``
@@ -65,6 +85,10 @@ pub struct TaskDefinition {
     // we can conclude that any cached outputs or logs for this Task should be invalidated.
     pub(crate) inputs: Vec<String>,
 
+    // InputTransforms are applied to the contents of matching input files before they
+    // are hashed, e.g. so that comment-only changes don't invalidate the cache.
+    pub(crate) input_transforms: Vec<InputTransform>,
+
     // OutputMode determines how we should log the output.
     pub(crate) output_logs: OutputLogsMode,
 
@@ -76,6 +100,52 @@ pub struct TaskDefinition {
     // Tasks that take stdin input cannot be cached as their outputs may depend on the
     // input.
     pub interactive: bool,
+
+    // Tasks in the same concurrency group are never run at the same time, even if
+    // the task graph would allow it.
+    pub(crate) concurrency_group: Option<String>,
+
+    // Ready is how a persistent task signals that the tasks depending on it
+    // can start. Without it, nothing can depend on a persistent task.
+    pub ready: Option<ReadyProbe>,
+
+    // ToolDependencies are commands that print the version of a tool the task uses,
+    // e.g. `node --version`. Their output is included in the task hash.
+    pub(crate) tool_dependencies: Vec<String>,
+
+    // DotEnv are dotenv files, relative to the package, whose variables are
+    // included in the task hash instead of the files themselves. Earlier files
+    // take precedence.
+    pub(crate) dot_env: Vec<String>,
+
+    // InjectDotEnv adds the variables from the task's dotenv files, and then from the
+    // global dotenv files, to its environment. Variables that are already set are not
+    // overridden.
+    pub(crate) inject_dot_env: bool,
+
+    // Weight is how many concurrency slots the task takes up while it runs, so that
+    // heavy tasks can't all run at the same time. Persistent tasks always take one.
+    pub(crate) weight: u32,
+
+    // Timeout is how long the task can run before it's terminated and marked as
+    // failed, so that hung tasks don't block the run forever.
+    pub(crate) timeout: Option<Duration>,
+
+    // Runner is the command that runs the task's script instead of the package
+    // manager, e.g. `bun run` or `deno task`, for packages that use another runtime.
+    pub(crate) runner: Option<Runner>,
+
+    // Shell is how the commands that turbo runs itself, those of tasks with a runner
+    // command and of packages found by a workspace provider, are started.
+    pub(crate) shell: TaskShell,
+
+    // FollowSymlinks makes the output globs descend into symlinked directories,
+    // so the files they point to are cached instead of the links.
+    pub(crate) follow_symlinks: bool,
+
+    // CompressionLevel overrides the zstd level that the task's outputs are
+    // compressed with in the cache.
+    pub(crate) compression_level: Option<i32>,
 }
 
 impl Default for TaskDefinition {
@@ -83,14 +153,49 @@ impl Default for TaskDefinition {
         Self {
             cache: true,
             outputs: Default::default(),
+            local_only_outputs: Default::default(),
             env: Default::default(),
             pass_through_env: Default::default(),
             topological_dependencies: Default::default(),
             task_dependencies: Default::default(),
             inputs: Default::default(),
+            input_transforms: Default::default(),
             output_logs: Default::default(),
             persistent: Default::default(),
             interactive: Default::default(),
+            concurrency_group: Default::default(),
+            ready: Default::default(),
+            tool_dependencies: Default::default(),
+            dot_env: Default::default(),
+            inject_dot_env: Default::default(),
+            weight: 1,
+            timeout: None,
+            runner: None,
+            shell: TaskShell::Default,
+            follow_symlinks: false,
+            compression_level: None,
+        }
+    }
+}
+
+/// A task's `runner`, or the command it's run with once the placeholders in it
+/// have been replaced
+#[derive(Debug, PartialEq, Eq, Clone)]
+pub enum Runner {
+    /// A command that's run with the task's shell
+    Command(String),
+    /// A program and its arguments, which are run without a shell
+    Args(Vec<String>),
+}
+
+impl Runner {
+    /// The runner as it's part of the task hash. Arguments are hashed as a
+    /// JSON array so that they're told apart from a command with the same
+    /// words.
+    pub fn hash_value(&self) -> String {
+        match self {
+            Runner::Command(command) => command.clone(),
+            Runner::Args(args) => serde_json::to_string(args).expect("strings serialize"),
         }
     }
 }
@@ -107,6 +212,30 @@ impl FromIterator<RawTaskDefinition> for RawTaskDefinition {
 
 const LOG_DIR: &str = ".turbo";
 
+/// Inputs starting with this are relative to the repository root instead of
+/// the package
+pub const INPUT_TURBO_ROOT: &str = "$TURBO_ROOT$/";
+
+// The relative path from a package to the root of the repository, e.g. `../..`
+fn path_to_root(package_path: &AnchoredSystemPath) -> String {
+    package_path
+        .to_unix()
+        .as_str()
+        .split('/')
+        .filter(|component| !component.is_empty())
+        .map(|_| "..")
+        .collect::<Vec<_>>()
+        .join("/")
+}
+
+/// An input that stands for the outputs of the task's dependencies instead of
+/// any of the package's files
+pub const INPUT_DEPS_OUTPUTS: &str = "$DEPS_OUTPUTS$";
+
+// Placeholders in a task's `runner`
+const RUNNER_TASK: &str = "{task}";
+const RUNNER_SCRIPT: &str = "{script}";
+
 impl TaskDefinition {
     pub fn workspace_relative_log_file(task_name: &str) -> AnchoredSystemPathBuf {
         let log_dir = AnchoredSystemPath::new(LOG_DIR)
@@ -120,6 +249,95 @@ impl TaskDefinition {
         log_dir.join_component(&task_log_filename(task_name))
     }
 
+    /// Returns the inputs relative to the package, replacing `$TURBO_ROOT$`
+    /// with the path from the package to the repository root
+    pub fn package_inputs(&self, package_path: &AnchoredSystemPath) -> Vec<String> {
+        let path_to_root = path_to_root(package_path);
+
+        self.inputs
+            .iter()
+            .filter(|input| *input != INPUT_DEPS_OUTPUTS)
+            .map(|input| {
+                let (negation, glob) = match input.strip_prefix('!') {
+                    Some(glob) => ("!", glob),
+                    None => ("", input.as_str()),
+                };
+                match glob.strip_prefix(INPUT_TURBO_ROOT) {
+                    Some(glob) if path_to_root.is_empty() => format!("{negation}{glob}"),
+                    Some(glob) => format!("{negation}{path_to_root}/{glob}"),
+                    None => input.clone(),
+                }
+            })
+            .collect()
+    }
+
+    /// The task's dotenv files relative to the package
+    pub fn package_dot_env(&self, package_path: &AnchoredSystemPath) -> Vec<String> {
+        let path_to_root = path_to_root(package_path);
+
+        self.dot_env
+            .iter()
+            .map(|file| match file.strip_prefix(INPUT_TURBO_ROOT) {
+                Some(file) if path_to_root.is_empty() => file.to_string(),
+                Some(file) => format!("{path_to_root}/{file}"),
+                None => file.trim_start_matches("./").to_string(),
+            })
+            .collect()
+    }
+
+    /// Whether the task is hashed on the outputs of its dependencies instead of
+    /// their hashes, which is requested with `$DEPS_OUTPUTS$` in its inputs
+    pub fn hashes_dependency_outputs(&self) -> bool {
+        self.inputs.iter().any(|input| input == INPUT_DEPS_OUTPUTS)
+    }
+
+    /// Whether the task has no outputs besides its logs. These tasks, such as
+    /// linters, are cached on their exit status alone: a cache hit skips the
+    /// task and replays its logs.
+    pub fn is_checkpoint(&self) -> bool {
+        self.outputs.inclusions.is_empty()
+    }
+
+    /// Returns the command that runs `script`, the task's script from
+    /// `package.json`, with the task's runner. `{task}` and `{script}` in the
+    /// runner are replaced with the task name and the script, and the task
+    /// name is appended to runners that use neither. The arguments of a
+    /// runner given as an array are replaced one by one, so a `{script}`
+    /// argument is passed as a single argument.
+    pub fn runner_command(&self, task: &str, script: &str) -> Option<Runner> {
+        let substitute = |arg: &str| {
+            arg.replace(RUNNER_TASK, task)
+                .replace(RUNNER_SCRIPT, script)
+        };
+        let has_placeholder = |arg: &str| arg.contains(RUNNER_TASK) || arg.contains(RUNNER_SCRIPT);
+        Some(match self.runner.as_ref()? {
+            Runner::Command(runner) if has_placeholder(runner) => {
+                Runner::Command(substitute(runner))
+            }
+            Runner::Command(runner) => Runner::Command(format!("{runner} {task}")),
+            Runner::Args(args) if args.iter().any(|arg| has_placeholder(arg)) => {
+                Runner::Args(args.iter().map(|arg| substitute(arg)).collect())
+            }
+            Runner::Args(args) => Runner::Args(
+                args.iter()
+                    .cloned()
+                    .chain(std::iter::once(task.to_string()))
+                    .collect(),
+            ),
+        })
+    }
+
+    /// Whether turbo runs the task's command in `package` with the task's
+    /// `shell`. Otherwise it's run by the package manager, or without a shell
+    /// for runners given as arguments.
+    pub fn uses_shell(&self, package: &PackageInfo) -> bool {
+        match &self.runner {
+            Some(Runner::Command(_)) => true,
+            Some(Runner::Args(_)) => false,
+            None => package.provider.is_some(),
+        }
+    }
+
     pub fn hashable_outputs(&self, task_name: &TaskId) -> TaskOutputs {
         let mut inclusion_outputs =
             vec![Self::sharable_workspace_relative_log_file(task_name.task()).to_string()];
@@ -141,30 +359,45 @@ impl TaskDefinition {
         task_name: &TaskId,
         workspace_dir: &AnchoredSystemPath,
     ) -> TaskOutputs {
-        let make_glob_repo_relative = |glob: &str| -> String {
-            let mut repo_relative_glob = workspace_dir.to_string();
-            repo_relative_glob.push(std::path::MAIN_SEPARATOR);
-            repo_relative_glob.push_str(glob);
-            repo_relative_glob
-        };
-
-        // At this point repo_relative_globs are still workspace relative, but
-        // the processing in the rest of the function converts this to be repo
-        // relative.
-        let mut repo_relative_globs = self.hashable_outputs(task_name);
+        // At this point the globs are still workspace relative
+        make_repo_relative(self.hashable_outputs(task_name), workspace_dir)
+    }
 
-        for input in repo_relative_globs.inclusions.iter_mut() {
-            let relative_input = make_glob_repo_relative(input.as_str());
-            *input = relative_input;
-        }
+    pub fn repo_relative_local_only_outputs(
+        &self,
+        workspace_dir: &AnchoredSystemPath,
+    ) -> TaskOutputs {
+        make_repo_relative(self.local_only_outputs.clone(), workspace_dir)
+    }
+}
 
-        for output in repo_relative_globs.exclusions.iter_mut() {
-            let relative_output = make_glob_repo_relative(output.as_str());
-            *output = relative_output;
-        }
+fn make_repo_relative(
+    mut repo_relative_globs: TaskOutputs,
+    workspace_dir: &AnchoredSystemPath,
+) -> TaskOutputs {
+    let make_glob_repo_relative = |glob: &str| -> String {
+        let mut repo_relative_glob = workspace_dir.to_string();
+        repo_relative_glob.push(std::path::MAIN_SEPARATOR);
+        repo_relative_glob.push_str(glob);
+        repo_relative_glob
+    };
+
+    // `$TURBO_DEFAULT$` isn't a glob, so it's left as is
+    for input in repo_relative_globs
+        .inclusions
+        .iter_mut()
+        .filter(|input| *input != INPUT_INCLUDE_DEFAULT_FILES)
+    {
+        let relative_input = make_glob_repo_relative(input.as_str());
+        *input = relative_input;
+    }
 
-        repo_relative_globs
+    for output in repo_relative_globs.exclusions.iter_mut() {
+        let relative_output = make_glob_repo_relative(output.as_str());
+        *output = relative_output;
     }
+
+    repo_relative_globs
 }
 
 fn task_log_filename(task_name: &str) -> String {
@@ -176,6 +409,8 @@ mod test {
     use std::path::MAIN_SEPARATOR_STR;
 
     use pretty_assertions::assert_eq;
+    use test_case::test_case;
+    use turborepo_repository::package_graph::WorkspaceProvider;
 
     use super::*;
 
@@ -213,6 +448,80 @@ mod test {
         );
     }
 
+    #[test]
+    fn test_checkpoint() {
+        let lint = TaskDefinition::default();
+        let task_id = TaskId::new("foo", "lint");
+        let workspace_dir = AnchoredSystemPath::new("foo").unwrap();
+
+        assert!(lint.is_checkpoint());
+        // The log is still cached so that it can be replayed on a cache hit
+        assert_eq!(
+            lint.repo_relative_hashable_outputs(&task_id, workspace_dir)
+                .inclusions,
+            vec![format!("foo{MAIN_SEPARATOR_STR}.turbo/turbo-lint.log")]
+        );
+
+        let build = TaskDefinition {
+            outputs: TaskOutputs {
+                inclusions: vec!["$TURBO_DEFAULT$".to_string()],
+                exclusions: vec![],
+            },
+            ..Default::default()
+        };
+        assert!(!build.is_checkpoint());
+    }
+
+    #[test]
+    fn test_inferred_outputs() {
+        let task_defn = TaskDefinition {
+            outputs: TaskOutputs {
+                inclusions: vec!["$TURBO_DEFAULT$".to_string()],
+                exclusions: vec![],
+            },
+            ..Default::default()
+        };
+        let task_id = TaskId::new("foo", "build");
+        let workspace_dir = AnchoredSystemPath::new("foo").unwrap();
+
+        let relative_outputs = task_defn.repo_relative_hashable_outputs(&task_id, workspace_dir);
+
+        assert!(relative_outputs.infers_outputs());
+        assert_eq!(
+            relative_outputs.inclusions,
+            vec![
+                "$TURBO_DEFAULT$".to_string(),
+                format!("foo{MAIN_SEPARATOR_STR}.turbo/turbo-build.log"),
+            ]
+        );
+        let validated_inclusions = relative_outputs.validated_inclusions().unwrap();
+        assert_eq!(
+            validated_inclusions
+                .iter()
+                .map(|glob| glob.as_str())
+                .collect::<Vec<_>>(),
+            vec!["foo/.turbo/turbo-build.log"]
+        );
+    }
+
+    #[test_case(&["$TURBO_ROOT$/tsconfig.json"], "", &["tsconfig.json"] ; "root package")]
+    #[test_case(
+        &["src/**", "$TURBO_ROOT$/tsconfig.json", "!$TURBO_ROOT$/schemas/internal/**"],
+        "apps/web",
+        &["src/**", "../../tsconfig.json", "!../../schemas/internal/**"]
+        ; "nested package"
+    )]
+    #[test_case(&["src/**", "$DEPS_OUTPUTS$"], "apps/web", &["src/**"] ; "dependency outputs")]
+    fn test_package_inputs(inputs: &[&str], package_path: &str, expected: &[&str]) {
+        let task_defn = TaskDefinition {
+            inputs: inputs.iter().map(|input| input.to_string()).collect(),
+            ..Default::default()
+        };
+        let package_path = AnchoredSystemPathBuf::from_raw(package_path).unwrap();
+
+        assert_eq!(task_defn.package_inputs(&package_path), expected);
+    }
+
     #[test]
     fn test_escape_log_file() {
         let build_log = TaskDefinition::workspace_relative_log_file("build");
@@ -235,4 +544,42 @@ mod test {
         .unwrap();
         assert_eq!(build_log, build_expected);
     }
+
+    #[test_case(Runner::Command("bun run".into()), Runner::Command("bun run build".into()) ; "appends task")]
+    #[test_case(Runner::Command("deno task {task} --quiet".into()), Runner::Command("deno task build --quiet".into()) ; "task placeholder")]
+    #[test_case(Runner::Command("nix develop -c {script}".into()), Runner::Command("nix develop -c tsc -b".into()) ; "script placeholder")]
+    #[test_case(Runner::Args(vec!["bun".into(), "run".into()]), Runner::Args(vec!["bun".into(), "run".into(), "build".into()]) ; "appends task argument")]
+    #[test_case(Runner::Args(vec!["sh".into(), "-c".into(), "{script}".into()]), Runner::Args(vec!["sh".into(), "-c".into(), "tsc -b".into()]) ; "script argument")]
+    fn test_runner_command(runner: Runner, expected: Runner) {
+        let task_definition = TaskDefinition {
+            runner: Some(runner),
+            ..Default::default()
+        };
+        assert_eq!(
+            task_definition.runner_command("build", "tsc -b"),
+            Some(expected)
+        );
+        assert_eq!(
+            TaskDefinition::default().runner_command("build", "tsc -b"),
+            None
+        );
+    }
+
+    #[test]
+    fn test_uses_shell() {
+        let package = PackageInfo::default();
+        let provided = PackageInfo {
+            provider: Some(WorkspaceProvider::Cargo),
+            ..Default::default()
+        };
+        let task_definition = |runner| TaskDefinition {
+            runner,
+            ..Default::default()
+        };
+
+        assert!(!task_definition(None).uses_shell(&package));
+        assert!(task_definition(None).uses_shell(&provided));
+        assert!(task_definition(Some(Runner::Command("bun run".into()))).uses_shell(&package));
+        assert!(!task_definition(Some(Runner::Args(vec!["bun".into()]))).uses_shell(&provided));
+    }
 }
@@ -0,0 +1,177 @@
+//! Readiness probes for persistent tasks. A persistent task never exits, so
+//! tasks that depend on it are started once its probe reports that it's ready
+//! instead, e.g. once a dev server is accepting connections.
+
+use std::{io::Write, time::Duration};
+
+use regex::Regex;
+use serde::Serialize;
+use tokio::{net::TcpStream, process::Command, sync::oneshot};
+use tracing::debug;
+use turbopath::AbsoluteSystemPath;
+use turborepo_env::EnvironmentVariableMap;
+
+/// How often a port or command probe is retried
+const PROBE_INTERVAL: Duration = Duration::from_millis(250);
+/// Output without newlines, e.g. progress bars, shouldn't grow the line buffer
+/// forever
+const MAX_LINE_LENGTH: usize = 64 * 1024;
+
+#[derive(Debug, PartialEq, Eq, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub enum ReadyProbe {
+    /// Ready once something is listening on the port on localhost
+    Port(u16),
+    /// Ready once the task logs a line matching the pattern
+    LogPattern(String),
+    /// Ready once the command exits successfully
+    Command(String),
+}
+
+impl ReadyProbe {
+    /// Waits until the probe passes. Log patterns are checked as the task's
+    /// output is written, see `ReadyWriter`, so this never resolves for them.
+    pub async fn wait(
+        &self,
+        workspace_directory: &AbsoluteSystemPath,
+        env: &EnvironmentVariableMap,
+    ) {
+        if let ReadyProbe::LogPattern(_) = self {
+            return std::future::pending().await;
+        }
+
+        let mut interval = tokio::time::interval(PROBE_INTERVAL);
+        loop {
+            interval.tick().await;
+            let ready = match self {
+                ReadyProbe::Port(port) => TcpStream::connect(("localhost", *port)).await.is_ok(),
+                ReadyProbe::Command(command) => {
+                    let (shell, flag) = if cfg!(windows) {
+                        ("cmd", "/C")
+                    } else {
+                        ("sh", "-c")
+                    };
+                    Command::new(shell)
+                        .args([flag, command])
+                        .current_dir(workspace_directory.as_std_path())
+                        .env_clear()
+                        .envs(env.iter())
+                        .kill_on_drop(true)
+                        .stdin(std::process::Stdio::null())
+                        .stdout(std::process::Stdio::null())
+                        .stderr(std::process::Stdio::null())
+                        .status()
+                        .await
+                        .map_or(false, |status| status.success())
+                }
+                ReadyProbe::LogPattern(_) => unreachable!("log patterns are handled above"),
+            };
+            if ready {
+                return;
+            }
+        }
+    }
+
+    pub fn log_pattern(&self) -> Option<Regex> {
+        match self {
+            ReadyProbe::LogPattern(pattern) => {
+                Some(Regex::new(pattern).expect("pattern is validated when turbo.json is loaded"))
+            }
+            _ => None,
+        }
+    }
+}
+
+/// Passes the task's output through to the inner writer, and signals that the
+/// task is ready the first time a line matches the pattern.
+pub struct ReadyWriter<W> {
+    inner: W,
+    watch: Option<(Regex, oneshot::Sender<()>)>,
+    line: Vec<u8>,
+}
+
+impl<W: Write> ReadyWriter<W> {
+    pub fn new(inner: W, watch: Option<(Regex, oneshot::Sender<()>)>) -> Self {
+        Self {
+            inner,
+            watch,
+            line: Vec::new(),
+        }
+    }
+
+    fn check_lines(&mut self, buf: &[u8]) {
+        let Some((pattern, _)) = &self.watch else {
+            return;
+        };
+
+        let mut matched = false;
+        for chunk in buf.split_inclusive(|b| *b == b'\n') {
+            self.line.extend_from_slice(chunk);
+            // Partial lines are kept until the rest of the line is written
+            if !chunk.ends_with(b"\n") {
+                if self.line.len() > MAX_LINE_LENGTH {
+                    self.line.clear();
+                }
+                break;
+            }
+            let line = String::from_utf8_lossy(&self.line);
+            matched = pattern.is_match(line.trim_end());
+            self.line.clear();
+            if matched {
+                break;
+            }
+        }
+
+        if matched {
+            if let Some((_, ready)) = self.watch.take() {
+                debug!("task output matched readiness pattern");
+                ready.send(()).ok();
+            }
+            self.line = Vec::new();
+        }
+    }
+}
+
+impl<W: Write> Write for ReadyWriter<W> {
+    fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
+        let written = self.inner.write(buf)?;
+        self.check_lines(&buf[..written]);
+        Ok(written)
+    }
+
+    fn flush(&mut self) -> std::io::Result<()> {
+        self.inner.flush()
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use std::io::Write;
+
+    use regex::Regex;
+    use tokio::sync::oneshot;
+
+    use super::ReadyWriter;
+
+    #[test]
+    fn test_ready_writer_matches_line() {
+        let (ready, mut is_ready) = oneshot::channel();
+        let pattern = Regex::new("listening on \\d+").unwrap();
+        let mut writer = ReadyWriter::new(Vec::new(), Some((pattern, ready)));
+
+        writer.write_all(b"starting\nlistening").unwrap();
+        assert!(is_ready.try_recv().is_err());
+        writer.write_all(b" on 3000\nserving\n").unwrap();
+        assert!(is_ready.try_recv().is_ok());
+
+        assert_eq!(writer.inner, b"starting\nlistening on 3000\nserving\n");
+    }
+
+    #[test]
+    fn test_ready_writer_without_pattern() {
+        let mut writer = ReadyWriter::new(Vec::new(), None);
+        writer.write_all(b"hello\n").unwrap();
+        assert_eq!(writer.inner, b"hello\n");
+        assert!(writer.line.is_empty());
+    }
+}
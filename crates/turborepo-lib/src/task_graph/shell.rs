@@ -0,0 +1,289 @@
+//! How the commands that turbo runs itself, i.e. those of tasks with a
+//! `runner` command and of packages found by a workspace provider, are
+//! started. They
+//! go through the platform's shell by default, but a task can pick another
+//! shell for each platform or be run without one with `shell` in turbo.json.
+
+use serde::{Serialize, Serializer};
+
+#[derive(Debug, Default, PartialEq, Eq, Clone)]
+pub enum TaskShell {
+    /// `sh -c` on Unix and `cmd /C` on Windows
+    #[default]
+    Default,
+    /// A shell and the arguments it's started with, which come before the
+    /// flag that passes it the command
+    Custom(Vec<String>),
+    /// The command is split into arguments and run without a shell
+    Exec,
+}
+
+impl TaskShell {
+    /// Returns the shell configured as `shell`, e.g. `bash -e`, or `None` if
+    /// it's empty or has an unterminated quote
+    pub fn custom(shell: &str) -> Option<Self> {
+        split_command(shell)
+            .filter(|shell| !shell.is_empty())
+            .map(TaskShell::Custom)
+    }
+
+    pub fn is_default(&self) -> bool {
+        matches!(self, TaskShell::Default)
+    }
+
+    /// Returns the command line that runs `command` followed by
+    /// `pass_through_args`. The arguments are quoted for the shell, so the
+    /// command receives them as is. Returns `None` when running without a
+    /// shell if `command` has an unterminated quote or is empty.
+    pub fn command_line(&self, command: &str, pass_through_args: &[String]) -> Option<CommandLine> {
+        let shell = match self {
+            TaskShell::Exec => {
+                let args = split_command(command)?;
+                return CommandLine::exec(&args, pass_through_args);
+            }
+            TaskShell::Default if cfg!(windows) => vec!["cmd".to_string()],
+            TaskShell::Default => vec!["sh".to_string()],
+            TaskShell::Custom(shell) => shell.clone(),
+        };
+        let syntax = shell
+            .first()
+            .map_or(Syntax::Posix, |program| Syntax::of(program));
+
+        let mut script = command.to_string();
+        for arg in pass_through_args {
+            script.push(' ');
+            script.push_str(&syntax.quote(arg));
+        }
+        let mut args = shell;
+        let program = args.remove(0);
+        // `cmd` doesn't unquote its arguments, it runs the rest of its command line
+        // after `/C`. With `/S` it only strips the quotes around the script, so
+        // the script has to be passed as is instead of being quoted as an argument.
+        if syntax == Syntax::Cmd {
+            return Some(CommandLine {
+                program,
+                args,
+                raw_arg: Some(format!("/S {} \"{script}\"", syntax.command_flag())),
+            });
+        }
+        args.push(syntax.command_flag().to_string());
+        args.push(script);
+        Some(CommandLine {
+            program,
+            args,
+            raw_arg: None,
+        })
+    }
+
+    /// The shell as it's configured in turbo.json, `false` when tasks are run
+    /// without one. Used for the task hash, so it's `None` for the default
+    /// shell to keep the hashes of other tasks the same.
+    pub fn config_value(&self) -> Option<String> {
+        match self {
+            TaskShell::Default => None,
+            TaskShell::Custom(shell) => Some(shell.join(" ")),
+            TaskShell::Exec => Some("false".to_string()),
+        }
+    }
+}
+
+/// A program and the arguments it's run with
+#[derive(Debug, PartialEq, Eq, Clone)]
+pub struct CommandLine {
+    pub program: String,
+    pub args: Vec<String>,
+    /// Comes after `args` and is passed to the program without being quoted on
+    /// Windows
+    pub raw_arg: Option<String>,
+}
+
+impl CommandLine {
+    /// The command line that runs `args` followed by `pass_through_args`
+    /// without a shell, or `None` if `args` is empty
+    pub fn exec(args: &[String], pass_through_args: &[String]) -> Option<Self> {
+        let (program, args) = args.split_first()?;
+        if program.is_empty() {
+            return None;
+        }
+        Some(CommandLine {
+            program: program.clone(),
+            args: args.iter().chain(pass_through_args).cloned().collect(),
+            raw_arg: None,
+        })
+    }
+}
+
+impl Serialize for TaskShell {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        match self {
+            TaskShell::Default => serializer.serialize_bool(true),
+            TaskShell::Custom(shell) => serializer.serialize_str(&shell.join(" ")),
+            TaskShell::Exec => serializer.serialize_bool(false),
+        }
+    }
+}
+
+/// Splits a command into arguments at whitespace. Quotes keep whitespace in
+/// an argument, and a backslash escapes a quote or whitespace. Backslashes
+/// before anything else are kept, so that Windows paths can be written as
+/// is. Returns `None` if a quote isn't terminated.
+fn split_command(command: &str) -> Option<Vec<String>> {
+    let mut args = Vec::new();
+    let mut arg = String::new();
+    // Tells an empty quoted argument apart from no argument at all
+    let mut in_arg = false;
+    let mut chars = command.chars().peekable();
+    while let Some(c) = chars.next() {
+        match c {
+            '\'' => {
+                in_arg = true;
+                loop {
+                    match chars.next()? {
+                        '\'' => break,
+                        c => arg.push(c),
+                    }
+                }
+            }
+            '"' => {
+                in_arg = true;
+                loop {
+                    match chars.next()? {
+                        '"' => break,
+                        '\\' if chars.peek() == Some(&'"') => arg.extend(chars.next()),
+                        c => arg.push(c),
+                    }
+                }
+            }
+            '\\' if chars
+                .peek()
+                .is_some_and(|&next| matches!(next, '"' | '\'') || next.is_whitespace()) =>
+            {
+                in_arg = true;
+                arg.extend(chars.next());
+            }
+            c if c.is_whitespace() => {
+                if in_arg {
+                    args.push(std::mem::take(&mut arg));
+                    in_arg = false;
+                }
+            }
+            c => {
+                in_arg = true;
+                arg.push(c);
+            }
+        }
+    }
+    if in_arg {
+        args.push(arg);
+    }
+    Some(args)
+}
+
+// The quoting rules of the shell a command is passed to
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Syntax {
+    Posix,
+    Cmd,
+    PowerShell,
+}
+
+impl Syntax {
+    fn of(program: &str) -> Self {
+        let name = program
+            .rsplit(['/', '\\'])
+            .next()
+            .unwrap_or(program)
+            .to_ascii_lowercase();
+        match name.strip_suffix(".exe").unwrap_or(&name) {
+            "cmd" => Syntax::Cmd,
+            "powershell" | "pwsh" => Syntax::PowerShell,
+            _ => Syntax::Posix,
+        }
+    }
+
+    fn command_flag(self) -> &'static str {
+        match self {
+            Syntax::Posix => "-c",
+            Syntax::Cmd => "/C",
+            Syntax::PowerShell => "-Command",
+        }
+    }
+
+    fn quote(self, arg: &str) -> String {
+        let is_plain = |c: char| match self {
+            Syntax::Posix => c.is_ascii_alphanumeric() || "-_./=:,@%+".contains(c),
+            Syntax::PowerShell => c.is_ascii_alphanumeric() || "-_./=:+".contains(c),
+            Syntax::Cmd => !c.is_whitespace() && !"\"&|<>^()".contains(c),
+        };
+        if !arg.is_empty() && arg.chars().all(is_plain) {
+            return arg.to_string();
+        }
+        match self {
+            Syntax::Posix => format!("'{}'", arg.replace('\'', r"'\''")),
+            Syntax::Cmd => format!("\"{}\"", arg.replace('"', "\"\"")),
+            Syntax::PowerShell => format!("'{}'", arg.replace('\'', "''")),
+        }
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+
+    use super::*;
+
+    #[test_case("tsc -b", Some(&["tsc", "-b"]) ; "words")]
+    #[test_case("  node  index.js ", Some(&["node", "index.js"]) ; "extra whitespace")]
+    #[test_case(r#"echo "hello world" 'it''s'"#, Some(&["echo", "hello world", "its"]) ; "quotes")]
+    #[test_case(r#"echo "say \"hi\"" a\ b"#, Some(&["echo", r#"say "hi""#, "a b"]) ; "escapes")]
+    #[test_case(r"C:\tools\node.exe ''", Some(&[r"C:\tools\node.exe", ""]) ; "windows path")]
+    #[test_case("echo 'unterminated", None ; "unterminated quote")]
+    fn test_split_command(command: &str, expected: Option<&[&str]>) {
+        assert_eq!(
+            split_command(command),
+            expected.map(|args| args.iter().map(|arg| arg.to_string()).collect())
+        );
+    }
+
+    #[test_case(TaskShell::Custom(vec!["bash".into(), "-e".into()]), &["bash", "-e", "-c", "vitest 'a b' --run"], None ; "posix")]
+    #[test_case(TaskShell::Custom(vec!["pwsh".into()]), &["pwsh", "-Command", "vitest 'a b' --run"], None ; "powershell")]
+    #[test_case(TaskShell::Custom(vec![r"C:\Windows\System32\cmd.exe".into()]), &[r"C:\Windows\System32\cmd.exe"], Some(r#"/S /C "vitest "a b" --run""#) ; "cmd")]
+    #[test_case(TaskShell::Exec, &["vitest", "a b", "--run"], None ; "exec")]
+    fn test_command_line(shell: TaskShell, expected: &[&str], raw_arg: Option<&str>) {
+        let command_line = shell
+            .command_line("vitest", &["a b".to_string(), "--run".to_string()])
+            .unwrap();
+        assert_eq!(
+            std::iter::once(&command_line.program)
+                .chain(&command_line.args)
+                .collect::<Vec<_>>(),
+            expected
+        );
+        assert_eq!(command_line.raw_arg.as_deref(), raw_arg);
+    }
+
+    // `cmd` has to receive the script as is for the quotes in it to work
+    #[cfg(windows)]
+    #[test]
+    fn test_cmd_quoting() {
+        use std::os::windows::process::CommandExt;
+
+        let command_line = TaskShell::Default
+            .command_line("echo", &["a b".to_string(), "c&d".to_string()])
+            .unwrap();
+        let output = std::process::Command::new(&command_line.program)
+            .args(&command_line.args)
+            .raw_arg(command_line.raw_arg.unwrap())
+            .output()
+            .unwrap();
+        assert_eq!(
+            String::from_utf8(output.stdout).unwrap().trim_end(),
+            r#""a b" "c&d""#
+        );
+    }
+
+    #[test]
+    fn test_exec_without_command() {
+        assert_eq!(TaskShell::Exec.command_line(" ", &[]), None);
+    }
+}
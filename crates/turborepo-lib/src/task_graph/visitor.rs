@@ -2,7 +2,10 @@ use std::{
     borrow::Cow,
     collections::HashSet,
     io::Write,
-    sync::{Arc, Mutex, OnceLock},
+    sync::{
+        atomic::{AtomicBool, Ordering},
+        Arc, Mutex, OnceLock,
+    },
     time::{Duration, Instant},
 };
 
@@ -14,6 +17,7 @@ use regex::Regex;
 use tokio::sync::{mpsc, oneshot};
 use tracing::{debug, error, Instrument, Span};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath};
+use turborepo_cache::CacheHitMetadata;
 use turborepo_ci::{Vendor, VendorBehavior};
 use turborepo_env::EnvironmentVariableMap;
 use turborepo_repository::{
@@ -24,33 +28,46 @@ use turborepo_telemetry::events::{
     generic::GenericEventBuilder, task::PackageTaskEventBuilder, EventBuilder, TrackedErrors,
 };
 use turborepo_ui::{
+    color, stable_color_for_key,
     tui::{self, AppSender, TuiTask},
-    ColorSelector, OutputClient, OutputSink, OutputWriter, PrefixedUI, UI,
+    OutputClient, OutputSink, OutputWriter, PrefixedUI, GREY, UI,
 };
 use which::which;
 
+use super::{
+    ready::{ReadyProbe, ReadyWriter},
+    CommandLine, Runner, TaskShell,
+};
 use crate::{
-    cli::EnvMode,
-    engine::{Engine, ExecutionOptions, StopExecution},
+    cli::{ContinueMode, EnvMode},
+    engine::{Engine, ExecutionOptions, StopExecution, TaskNode},
     opts::RunOpts,
     process::{ChildExit, Command, ProcessManager},
     run::{
+        env_audit::{EnvAudit, TaskEnvAudit},
+        executor::{RemoteExecutor, RemoteTask},
         global_hash::GlobalHashableInputs,
+        hooks::{HookEvent, Hooks, TaskCacheStatus},
+        resume::ResumeTracker,
         summary::{
             self, GlobalHashSummary, RunTracker, SpacesTaskClient, SpacesTaskInformation,
             TaskExecutionSummary, TaskTracker,
         },
         task_access::TaskAccess,
         task_id::TaskId,
-        CacheOutput, RunCache, TaskCache,
+        CacheError, CacheOutput, RunCache, TaskCache,
     },
     task_hash::{self, PackageInputsHashes, TaskHashTracker, TaskHashTrackerState, TaskHasher},
 };
 
+// How often a task that's locked by another turbo process checks whether it
+// has finished
+const TASK_LOCK_POLL_INTERVAL: Duration = Duration::from_millis(200);
+
 // This holds the whole world
 pub struct Visitor<'a> {
-    color_cache: ColorSelector,
     dry: bool,
+    hash_only: bool,
     global_env: EnvironmentVariableMap,
     global_env_mode: EnvMode,
     manager: ProcessManager,
@@ -64,6 +81,10 @@ pub struct Visitor<'a> {
     task_hasher: TaskHasher<'a>,
     ui: UI,
     experimental_ui_sender: Option<AppSender>,
+    hooks: Hooks,
+    resume: ResumeTracker,
+    env_audit: Option<EnvAudit>,
+    executor: Option<RemoteExecutor>,
 }
 
 #[derive(Debug, thiserror::Error)]
@@ -109,20 +130,22 @@ impl<'a> Visitor<'a> {
         repo_root: &'a AbsoluteSystemPath,
         global_env: EnvironmentVariableMap,
         experimental_ui_sender: Option<AppSender>,
+        hooks: Hooks,
     ) -> Self {
         let task_hasher = TaskHasher::new(
             package_inputs_hashes,
             run_opts,
             env_at_execution_start,
             global_hash,
+            repo_root,
         );
 
         let sink = Self::sink(run_opts);
-        let color_cache = ColorSelector::default();
+        let resume = ResumeTracker::new(repo_root, run_opts.resume);
 
         Self {
-            color_cache,
             dry: false,
+            hash_only: false,
             global_env_mode,
             manager,
             run_opts,
@@ -136,6 +159,10 @@ impl<'a> Visitor<'a> {
             ui,
             global_env,
             experimental_ui_sender,
+            hooks,
+            resume,
+            env_audit: None,
+            executor: None,
         }
     }
 
@@ -157,11 +184,18 @@ impl<'a> Visitor<'a> {
         let span = Span::current();
 
         let factory = ExecContextFactory::new(self, errors.clone(), self.manager.clone(), &engine);
+        if !self.dry && !self.hash_only {
+            self.resume.start();
+        }
 
         while let Some(message) = node_stream.recv().await {
             let span = tracing::debug_span!(parent: &span, "queue_task", task = %message.info);
             let _enter = span.enter();
-            let crate::engine::Message { info, callback } = message;
+            let crate::engine::Message {
+                info,
+                callback,
+                ready,
+            } = message;
             let package_name = PackageName::from(info.package());
 
             let workspace_info =
@@ -201,22 +235,42 @@ impl<'a> Visitor<'a> {
             let dependency_set = engine.dependencies(&info).ok_or(Error::MissingDefinition)?;
 
             let task_hash_telemetry = package_task_event.child();
-            let task_hash = self.task_hasher.calculate_task_hash(
-                &info,
-                task_definition,
-                task_env_mode,
-                workspace_info,
-                dependency_set,
-                task_hash_telemetry,
-            )?;
+            let task_hash = self
+                .task_hasher
+                .calculate_task_hash(
+                    &info,
+                    task_definition,
+                    task_env_mode,
+                    workspace_info,
+                    dependency_set,
+                    task_hash_telemetry,
+                )
+                .await?;
 
             debug!("task {} hash is {}", info, task_hash);
+            // Dependent tasks only need the hash, which the hasher already recorded
+            if self.hash_only {
+                continue;
+            }
             // We do this calculation earlier than we do in Go due to the `task_hasher`
             // being !Send. In the future we can look at doing this right before
             // task execution instead.
             let execution_env =
                 self.task_hasher
                     .env(&info, task_env_mode, task_definition, &self.global_env)?;
+            let env_audit = match &self.env_audit {
+                Some(env_audit) => {
+                    let mut declared =
+                        self.task_hasher
+                            .declared_env(&info, task_definition, &self.global_env)?;
+                    // Dotenv files are hashed as inputs of the task
+                    if let Some(dot_env) = self.task_hasher.task_hash_tracker().dot_env(&info) {
+                        declared.union(&dot_env);
+                    }
+                    Some(env_audit.task(&task_hash, &declared))
+                }
+                None => None,
+            };
 
             let task_cache = self.run_cache.task_cache(
                 task_definition,
@@ -244,21 +298,65 @@ impl<'a> Visitor<'a> {
                     // hashing so that downstream tasks can count on the hash existing
                     //
                     // bail if the script doesn't exist or is empty
-                    if command.map_or(true, |s| s.is_empty()) {
+                    let Some(command) = command.filter(|s| !s.is_empty()) else {
                         continue;
-                    }
+                    };
+                    // Tasks with a runner are run with it instead of the package manager.
+                    // Packages found by a workspace provider don't have a package manager to
+                    // run their scripts, so they're run directly.
+                    let virtual_command =
+                        match task_definition.runner_command(info.task(), &command) {
+                            Some(runner_command) => Some(runner_command),
+                            None => workspace_info
+                                .provider
+                                .is_some()
+                                .then_some(Runner::Command(command)),
+                        };
 
                     let workspace_directory = self.repo_root.resolve(workspace_info.package_path());
 
                     let takes_input = task_definition.interactive || task_definition.persistent;
+                    let resumed = self.resume.is_completed(&info, &task_hash);
+                    // Persistent tasks never exit, so --task-timeout doesn't apply to them
+                    let timeout = match self.run_opts.task_timeout {
+                        Some(timeout) if !task_definition.persistent => Some(timeout),
+                        _ => task_definition.timeout,
+                    };
+                    // Outputs are shared with the executor through the remote cache, so tasks
+                    // that aren't cached or that take input always run locally. Only the
+                    // variables the task declares are sent, not the whole environment.
+                    let remote_task = match &self.executor {
+                        Some(executor) if task_definition.cache && !takes_input => {
+                            let env = self.task_hasher.configured_env(
+                                &info,
+                                task_definition,
+                                &self.global_env,
+                            )?;
+                            Some(executor.task(
+                                &info,
+                                workspace_info.package_path().to_unix().to_string(),
+                                &task_hash,
+                                self.run_opts.args_for_task(&info).unwrap_or_default(),
+                                &env,
+                            ))
+                        }
+                        _ => None,
+                    };
                     let mut exec_context = factory.exec_context(
                         info.clone(),
                         task_hash,
+                        resumed,
                         task_cache,
                         workspace_directory,
                         execution_env,
+                        env_audit,
                         takes_input,
+                        task_definition.ready.clone(),
+                        timeout,
                         self.task_access.clone(),
+                        virtual_command,
+                        task_definition.shell.clone(),
+                        remote_task,
                     );
 
                     let vendor_behavior =
@@ -281,6 +379,7 @@ impl<'a> Visitor<'a> {
                                 tracker,
                                 output_client,
                                 callback,
+                                ready,
                                 spaces_client,
                                 &execution_telemetry,
                             )
@@ -291,7 +390,10 @@ impl<'a> Visitor<'a> {
         }
 
         // Wait for the engine task to finish and for all of our tasks to finish
-        engine_handle.await.expect("engine execution panicked")?;
+        let skipped_tasks = engine_handle.await.expect("engine execution panicked")?;
+        for task_id in skipped_tasks {
+            self.run_tracker.track_task(task_id).skipped().await;
+        }
         // This will poll the futures until they are all completed
         let mut internal_errors = Vec::new();
         while let Some(result) = tasks.next().await {
@@ -349,7 +451,8 @@ impl<'a> Visitor<'a> {
             ..
         } = self;
 
-        let global_hash_summary = GlobalHashSummary::try_from(global_hash_inputs)?;
+        let global_hash_summary =
+            GlobalHashSummary::new(global_hash_inputs, run_opts.env_hash_salt.as_deref())?;
 
         Ok(self
             .run_tracker
@@ -417,18 +520,36 @@ impl<'a> Visitor<'a> {
         logger
     }
 
+    // The prefix of a task's logs, including the separator from the logs
     fn prefix<'b>(&self, task_id: &'b TaskId) -> Cow<'b, str> {
-        match self.run_opts.log_prefix {
-            crate::opts::ResolvedLogPrefix::Task if self.run_opts.single_package => {
-                task_id.task().into()
+        match &self.run_opts.log_prefix {
+            crate::opts::ResolvedLogPrefix::Task | crate::opts::ResolvedLogPrefix::Package
+                if self.run_opts.single_package =>
+            {
+                format!("{}: ", task_id.task()).into()
             }
             crate::opts::ResolvedLogPrefix::Task => {
-                format!("{}:{}", task_id.package(), task_id.task()).into()
+                format!("{}:{}: ", task_id.package(), task_id.task()).into()
             }
+            crate::opts::ResolvedLogPrefix::Package => format!("{}: ", task_id.package()).into(),
+            crate::opts::ResolvedLogPrefix::Template(template) => template
+                .replace("{package}", task_id.package())
+                .replace("{task}", task_id.task())
+                .into(),
             crate::opts::ResolvedLogPrefix::None => "".into(),
         }
     }
 
+    // Colors the prefix of a task's logs, padding it to `width` so that the logs
+    // of every task start in the same column. A task always gets the same color.
+    fn pretty_prefix(&self, task_id: &TaskId, width: usize) -> StyledObject<String> {
+        let prefix = self.prefix(task_id);
+        if prefix.is_empty() {
+            return Style::new().apply_to(String::new());
+        }
+        stable_color_for_key(&task_id.to_string()).apply_to(format!("{prefix:width$}"))
+    }
+
     // Task ID as displayed in error messages
     fn display_task_id(&self, task_id: &TaskId) -> String {
         match self.run_opts.single_package {
@@ -470,6 +591,39 @@ impl<'a> Visitor<'a> {
         // No need to start a TUI on dry run
         self.experimental_ui_sender = None;
     }
+
+    /// Only calculates task hashes, without running tasks or checking the
+    /// cache
+    pub fn hash_only(&mut self) {
+        self.hash_only = true;
+        self.experimental_ui_sender = None;
+    }
+
+    /// Injects the variables from the `globalDotEnv` files into the tasks that
+    /// inject their dotenv variables
+    pub fn inject_global_dot_env(&mut self, global_dot_env: EnvironmentVariableMap) {
+        self.task_hasher.inject_global_dot_env(global_dot_env);
+    }
+
+    /// Warns about the environment variables that tasks read without
+    /// declaring them
+    pub fn audit_env(&mut self, env_audit: EnvAudit) {
+        self.env_audit = Some(env_audit);
+    }
+
+    /// Sends tasks to a remote executor instead of running them locally
+    pub fn execute_remotely(&mut self, executor: RemoteExecutor) {
+        self.executor = Some(executor);
+    }
+
+    pub fn task_hash(&self, task_id: &TaskId) -> Option<String> {
+        self.task_hasher.task_hash_tracker().hash(task_id)
+    }
+
+    /// The cache status recorded for a task during a dry run
+    pub fn cache_status(&self, task_id: &TaskId) -> Option<CacheHitMetadata> {
+        self.task_hasher.task_hash_tracker().cache_status(task_id)
+    }
 }
 
 // A tiny enum that allows us to use the same type for stdout and stderr without
@@ -545,6 +699,12 @@ enum TaskErrorCause {
     Spawn { msg: String },
     #[error("command {command} exited ({exit_code})")]
     Exit { command: String, exit_code: i32 },
+    #[error("command {command} timed out after {timeout}")]
+    Timeout { command: String, timeout: String },
+    #[error("unable to run task on executor: {msg}")]
+    Remote { msg: String },
+    #[error("cache error: {msg}")]
+    Cache { msg: String },
     #[error("turbo has internal error processing task")]
     Internal,
 }
@@ -560,7 +720,7 @@ pub enum InternalError {
     #[error("external process killed a task")]
     ExternalKill,
     #[error("error writing logs: {0}")]
-    Logs(#[from] crate::run::CacheError),
+    Logs(#[from] CacheError),
 }
 
 impl TaskError {
@@ -605,6 +765,7 @@ struct ExecContextFactory<'a> {
     errors: Arc<Mutex<Vec<TaskError>>>,
     manager: ProcessManager,
     engine: &'a Arc<Engine>,
+    prefix_width: usize,
 }
 
 impl<'a> ExecContextFactory<'a> {
@@ -614,11 +775,25 @@ impl<'a> ExecContextFactory<'a> {
         manager: ProcessManager,
         engine: &'a Arc<Engine>,
     ) -> Self {
+        // Prefixes are only aligned in a terminal, so that logs that are piped
+        // somewhere don't change depending on the other tasks in the run
+        let prefix_width = match visitor.ui.should_strip_ansi {
+            true => 0,
+            false => engine
+                .tasks()
+                .filter_map(|node| match node {
+                    TaskNode::Task(task_id) => Some(visitor.prefix(task_id).chars().count()),
+                    TaskNode::Root => None,
+                })
+                .max()
+                .unwrap_or_default(),
+        };
         Self {
             visitor,
             errors,
             manager,
             engine,
+            prefix_width,
         }
     }
 
@@ -627,23 +802,28 @@ impl<'a> ExecContextFactory<'a> {
         &self,
         task_id: TaskId<'static>,
         task_hash: String,
+        resumed: bool,
         task_cache: TaskCache,
         workspace_directory: AbsoluteSystemPathBuf,
         execution_env: EnvironmentVariableMap,
+        env_audit: Option<TaskEnvAudit>,
         takes_input: bool,
+        ready_probe: Option<ReadyProbe>,
+        timeout: Option<Duration>,
         task_access: TaskAccess,
+        virtual_command: Option<Runner>,
+        shell: TaskShell,
+        remote_task: Option<RemoteTask>,
     ) -> ExecContext {
         let task_id_for_display = self.visitor.display_task_id(&task_id);
         let pass_through_args = self.visitor.run_opts.args_for_task(&task_id);
+        let forward_stdin = self.visitor.run_opts.is_interactive_task(&task_id);
         ExecContext {
             engine: self.engine.clone(),
             ui: self.visitor.ui,
             experimental_ui: self.visitor.experimental_ui_sender.is_some(),
             is_github_actions: self.visitor.run_opts.is_github_actions,
-            pretty_prefix: self
-                .visitor
-                .color_cache
-                .prefix_with_color(&task_hash, &self.visitor.prefix(&task_id)),
+            pretty_prefix: self.visitor.pretty_prefix(&task_id, self.prefix_width),
             task_id,
             task_id_for_display,
             task_cache,
@@ -653,11 +833,23 @@ impl<'a> ExecContextFactory<'a> {
             manager: self.manager.clone(),
             task_hash,
             execution_env,
+            env_audit,
             continue_on_error: self.visitor.run_opts.continue_on_error,
+            shutdown_grace_period: self.visitor.run_opts.shutdown_grace_period,
             pass_through_args,
             errors: self.errors.clone(),
             takes_input,
+            forward_stdin,
+            ready_probe,
+            timeout,
             task_access,
+            hooks: self.visitor.hooks.clone(),
+            virtual_command,
+            shell,
+            remote_task,
+            resume: self.visitor.resume.clone(),
+            resumed,
+            run_id: self.visitor.run_tracker.id().to_string(),
         }
     }
 
@@ -666,10 +858,21 @@ impl<'a> ExecContextFactory<'a> {
         task_id: TaskId<'static>,
         task_cache: TaskCache,
     ) -> DryRunExecContext {
+        let outputs_hashed_by_dependents =
+            self.engine.dependents(&task_id).into_iter().flatten().any(
+                |dependent| match dependent {
+                    TaskNode::Task(dependent) => self
+                        .engine
+                        .task_definition(dependent)
+                        .map_or(false, |definition| definition.hashes_dependency_outputs()),
+                    TaskNode::Root => false,
+                },
+            );
         DryRunExecContext {
             task_id,
             task_cache,
             hash_tracker: self.visitor.task_hasher.task_hash_tracker(),
+            outputs_hashed_by_dependents,
         }
     }
 }
@@ -689,11 +892,31 @@ struct ExecContext {
     manager: ProcessManager,
     task_hash: String,
     execution_env: EnvironmentVariableMap,
-    continue_on_error: bool,
+    // Records the environment variables that the task reads with `--env-audit`
+    env_audit: Option<TaskEnvAudit>,
+    continue_on_error: ContinueMode,
+    shutdown_grace_period: Duration,
     pass_through_args: Option<Vec<String>>,
     errors: Arc<Mutex<Vec<TaskError>>>,
     takes_input: bool,
+    // Whether turbo's stdin is forwarded to the task, the UI handles this itself
+    forward_stdin: bool,
+    ready_probe: Option<ReadyProbe>,
+    // How long the task can run before it's terminated
+    timeout: Option<Duration>,
     task_access: TaskAccess,
+    hooks: Hooks,
+    // The script of a package found by a workspace provider, or the command of a
+    // task with a runner, which is run by turbo instead of the package manager
+    virtual_command: Option<Runner>,
+    // The shell the virtual command is run with, if any
+    shell: TaskShell,
+    // Set when the task is sent to `--experimental-executor`
+    remote_task: Option<RemoteTask>,
+    resume: ResumeTracker,
+    // Whether the task completed in the run that's being resumed
+    resumed: bool,
+    run_id: String,
 }
 
 enum ExecOutcome {
@@ -703,6 +926,8 @@ enum ExecOutcome {
     Task {
         exit_code: Option<i32>,
         message: String,
+        // The task was terminated for running longer than its timeout
+        timed_out: bool,
     },
     // Task didn't execute normally due to a shutdown being initiated by another task
     Shutdown,
@@ -711,6 +936,8 @@ enum ExecOutcome {
 enum SuccessOutcome {
     CacheHit,
     Run,
+    // The task completed in the run that's being resumed
+    Resumed,
 }
 
 impl ExecContext {
@@ -728,21 +955,39 @@ impl ExecContext {
         tracker: TaskTracker<()>,
         output_client: TaskOutput<impl std::io::Write>,
         callback: oneshot::Sender<Result<(), StopExecution>>,
+        ready: Option<oneshot::Sender<()>>,
         spaces_client: Option<SpacesTaskClient>,
         telemetry: &PackageTaskEventBuilder,
     ) -> Result<(), InternalError> {
         let tracker = tracker.start().await;
+        let task_start = Instant::now();
         let span = tracing::debug_span!("execute_task", task = %self.task_id.task());
         span.follows_from(parent_span_id);
         let mut result = self
-            .execute_inner(&output_client, telemetry)
+            .execute_inner(&output_client, ready, telemetry)
             .instrument(span)
             .await;
+        self.task_cache.unlock().await;
+
+        if let Ok(ExecOutcome::Success(outcome)) = &result {
+            let outcome = match outcome {
+                SuccessOutcome::CacheHit => "cache hit",
+                SuccessOutcome::Run => "cache miss",
+                SuccessOutcome::Resumed => "resumed",
+            };
+            self.task_cache.on_success(
+                &mut self.prefixed_ui(&output_client),
+                &self.task_id_for_display,
+                outcome,
+                task_start.elapsed(),
+            );
+        }
 
         // If the task resulted in an error, do not group in order to better highlight
         // the error.
         let is_error = matches!(result, Ok(ExecOutcome::Task { .. }));
-        let logs = match output_client.finish(is_error) {
+        let is_cache_hit = matches!(result, Ok(ExecOutcome::Success(SuccessOutcome::CacheHit)));
+        let logs = match output_client.finish(is_error, is_cache_hit) {
             Ok(logs) => logs,
             Err(e) => {
                 telemetry.track_error(TrackedErrors::DaemonFailedToMarkOutputsAsCached);
@@ -754,27 +999,50 @@ impl ExecContext {
 
         match result {
             Ok(ExecOutcome::Success(outcome)) => {
-                let task_summary = match outcome {
-                    SuccessOutcome::CacheHit => tracker.cached().await,
-                    SuccessOutcome::Run => tracker.build_succeeded(0).await,
+                if !matches!(outcome, SuccessOutcome::Resumed) {
+                    self.resume.complete(&self.task_id, &self.task_hash);
+                }
+                let (task_summary, cache_status, exit_code) = match outcome {
+                    SuccessOutcome::CacheHit | SuccessOutcome::Resumed => {
+                        (tracker.cached().await, TaskCacheStatus::Hit, None)
+                    }
+                    SuccessOutcome::Run => (
+                        tracker.build_succeeded(0).await,
+                        TaskCacheStatus::Miss,
+                        Some(0),
+                    ),
                 };
                 callback.send(Ok(())).ok();
+                self.post_task_hook(task_start, cache_status, exit_code)
+                    .await;
                 if let Some(client) = spaces_client {
                     let logs = logs.expect("spaces enabled logs should be collected");
                     let info = self.spaces_task_info(self.task_id.clone(), task_summary, logs);
                     client.finish_task(info).await.ok();
                 }
             }
-            Ok(ExecOutcome::Task { exit_code, message }) => {
-                let task_summary = tracker.build_failed(exit_code, message).await;
+            Ok(ExecOutcome::Task {
+                exit_code,
+                message,
+                timed_out,
+            }) => {
+                let task_summary = if timed_out {
+                    tracker.build_timed_out(message).await
+                } else {
+                    tracker.build_failed(exit_code, message).await
+                };
                 callback
                     .send(match self.continue_on_error {
-                        true => Ok(()),
-                        false => Err(StopExecution),
+                        ContinueMode::Always => Ok(()),
+                        ContinueMode::DependenciesSuccessful => Err(StopExecution::DependentTasks),
+                        ContinueMode::Never => Err(StopExecution::AllTasks),
                     })
                     .ok();
+                self.post_task_hook(task_start, TaskCacheStatus::Miss, exit_code)
+                    .await;
 
-                match (spaces_client, self.continue_on_error) {
+                let continue_on_error = self.continue_on_error != ContinueMode::Never;
+                match (spaces_client, continue_on_error) {
                     // Nothing to do
                     (None, true) => (),
                     // Shut down manager
@@ -798,14 +1066,14 @@ impl ExecContext {
             }
             Ok(ExecOutcome::Shutdown) => {
                 tracker.cancel();
-                callback.send(Err(StopExecution)).ok();
+                callback.send(Err(StopExecution::AllTasks)).ok();
                 // Probably overkill here, but we should make sure the process manager is
                 // stopped if we think we're shutting down.
                 self.manager.stop().await;
             }
             Err(e) => {
                 tracker.cancel();
-                callback.send(Err(StopExecution)).ok();
+                callback.send(Err(StopExecution::AllTasks)).ok();
                 self.manager.stop().await;
                 return Err(e);
             }
@@ -814,6 +1082,25 @@ impl ExecContext {
         Ok(())
     }
 
+    async fn post_task_hook(
+        &self,
+        task_start: Instant,
+        cache_status: TaskCacheStatus,
+        exit_code: Option<i32>,
+    ) {
+        self.hooks
+            .run(HookEvent::PostTask {
+                task_id: self.task_id.to_string(),
+                package: self.task_id.package().to_string(),
+                task: self.task_id.task().to_string(),
+                hash: self.task_hash.clone(),
+                duration_ms: task_start.elapsed().as_millis(),
+                cache_status,
+                exit_code,
+            })
+            .await;
+    }
+
     fn prefixed_ui<'a, W: Write>(
         &self,
         output_client: &'a TaskOutput<W>,
@@ -833,6 +1120,7 @@ impl ExecContext {
     async fn execute_inner(
         &mut self,
         output_client: &TaskOutput<impl std::io::Write>,
+        ready: Option<oneshot::Sender<()>>,
         telemetry: &PackageTaskEventBuilder,
     ) -> Result<ExecOutcome, InternalError> {
         let task_start = Instant::now();
@@ -844,41 +1132,130 @@ impl ExecContext {
             }
         }
 
+        if self.resumed {
+            prefixed_ui.status(&format!(
+                "completed in the previous run, skipping {}",
+                color!(self.ui, GREY, "{}", self.task_hash)
+            ));
+            return Ok(ExecOutcome::Success(SuccessOutcome::Resumed));
+        }
+
         match self
             .task_cache
             .restore_outputs(&mut prefixed_ui, telemetry)
             .await
         {
             Ok(Some(status)) => {
-                // we need to set expanded outputs
-                self.hash_tracker.insert_expanded_outputs(
-                    self.task_id.clone(),
-                    self.task_cache.expanded_outputs().to_vec(),
-                );
-                self.hash_tracker
-                    .insert_cache_status(self.task_id.clone(), status);
-                return Ok(ExecOutcome::Success(SuccessOutcome::CacheHit));
+                return Ok(self.cache_hit(status).await);
+            }
+            Ok(None) => {
+                if !self.task_cache.reads_disabled() {
+                    self.hooks
+                        .run(HookEvent::cache_fetch(
+                            &self.task_id,
+                            &self.task_hash,
+                            None,
+                            &[],
+                        ))
+                        .await;
+                }
             }
-            Ok(None) => (),
             Err(e) => {
                 telemetry.track_error(TrackedErrors::ErrorFetchingFromCache);
+                if self.task_cache.strict() {
+                    return Ok(self.cache_failure(&mut prefixed_ui, e));
+                }
                 prefixed_ui.error(&format!("error fetching from cache: {e}"));
             }
         }
 
-        let package_manager_binary = which(self.package_manager.command())?;
+        // Another turbo process on this machine may be running the same task, in
+        // which case its result is used once it finishes. Persistent and
+        // interactive tasks don't finish on their own, so they aren't waited on.
+        if !self.takes_input && !self.task_cache.try_lock().await {
+            prefixed_ui.status("waiting for another turbo process running this task");
+            while !self.task_cache.try_lock().await {
+                if self.manager.is_closing() {
+                    return Ok(ExecOutcome::Shutdown);
+                }
+                tokio::time::sleep(TASK_LOCK_POLL_INTERVAL).await;
+            }
+            match self
+                .task_cache
+                .restore_outputs(&mut prefixed_ui, telemetry)
+                .await
+            {
+                Ok(Some(status)) => {
+                    return Ok(self.cache_hit(status).await);
+                }
+                // The other process failed or didn't cache the task, so it's run here
+                Ok(None) => (),
+                Err(e) => {
+                    telemetry.track_error(TrackedErrors::ErrorFetchingFromCache);
+                    if self.task_cache.strict() {
+                        return Ok(self.cache_failure(&mut prefixed_ui, e));
+                    }
+                    prefixed_ui.error(&format!("error fetching from cache: {e}"));
+                }
+            }
+        }
 
-        let mut cmd = Command::new(package_manager_binary);
-        let mut args = vec!["run".to_string(), self.task_id.task().to_string()];
-        if let Some(pass_through_args) = &self.pass_through_args {
-            args.extend(
-                self.package_manager
-                    .arg_separator(pass_through_args.as_slice())
-                    .map(|s| s.to_string()),
-            );
-            args.extend(pass_through_args.iter().cloned());
+        if let Some(remote_task) = self.remote_task.clone() {
+            return self.execute_remotely(&remote_task, &mut prefixed_ui).await;
         }
-        cmd.args(args);
+
+        let mut cmd = if let Some(virtual_command) = &self.virtual_command {
+            let pass_through_args = self.pass_through_args.as_deref().unwrap_or_default();
+            let (command_line, uses_shell) = match virtual_command {
+                Runner::Command(script) => (
+                    self.shell.command_line(script, pass_through_args),
+                    self.shell != TaskShell::Exec,
+                ),
+                Runner::Args(args) => (CommandLine::exec(args, pass_through_args), false),
+            };
+            let Some(CommandLine {
+                mut program,
+                args,
+                raw_arg,
+            }) = command_line
+            else {
+                let error = std::io::Error::new(
+                    std::io::ErrorKind::InvalidInput,
+                    format!(
+                        "unable to split `{}` into arguments to run it without a shell",
+                        virtual_command.hash_value()
+                    ),
+                );
+                return Ok(self.spawn_failure(&mut prefixed_ui, error));
+            };
+            // Commands that aren't run with a shell have to be looked up on the PATH
+            // ourselves, e.g. for the `.cmd` shims of Windows
+            if !uses_shell && !program.contains(['/', '\\']) {
+                if let Ok(path) = which(&program) {
+                    program = path.to_string_lossy().into_owned();
+                }
+            }
+            let mut cmd = Command::new(program);
+            cmd.args(args);
+            if let Some(raw_arg) = raw_arg {
+                cmd.raw_arg(raw_arg);
+            }
+            cmd
+        } else {
+            let package_manager_binary = which(self.package_manager.command())?;
+            let mut cmd = Command::new(package_manager_binary);
+            let mut args = vec!["run".to_string(), self.task_id.task().to_string()];
+            if let Some(pass_through_args) = &self.pass_through_args {
+                args.extend(
+                    self.package_manager
+                        .arg_separator(pass_through_args.as_slice())
+                        .map(|s| s.to_string()),
+                );
+                args.extend(pass_through_args.iter().cloned());
+            }
+            cmd.args(args);
+            cmd
+        };
         cmd.current_dir(self.workspace_directory.clone());
 
         // We clear the env before populating it with variables we expect
@@ -886,6 +1263,13 @@ impl ExecContext {
         cmd.envs(self.execution_env.iter());
         // Always last to make sure it overwrites any user configured env var.
         cmd.env("TURBO_HASH", &self.task_hash);
+        cmd.env("TURBO_TASK", self.task_id.task());
+        cmd.env("TURBO_PACKAGE", self.task_id.package());
+        cmd.env(
+            "TURBO_IS_CACHE_WRITE_ENABLED",
+            (!self.task_cache.writes_disabled()).to_string(),
+        );
+        cmd.env("TURBO_RUN_ID", &self.run_id);
         // enable task access tracing
 
         // set the trace file env var - frameworks that support this can use it to
@@ -894,25 +1278,18 @@ impl ExecContext {
             let (task_access_trace_key, trace_file) = self.task_access.get_env_var(&self.task_hash);
             cmd.env(task_access_trace_key, trace_file.to_string());
         }
+        if let Some(env_audit) = &self.env_audit {
+            env_audit.prepare(&mut cmd, &self.execution_env);
+        }
 
         cmd.open_stdin();
 
-        let mut process = match self.manager.spawn(cmd, Duration::from_millis(500)) {
+        self.task_cache.record_ignored_files();
+
+        let mut process = match self.manager.spawn(cmd, self.shutdown_grace_period) {
             Some(Ok(child)) => child,
             // Turbo was unable to spawn a process
-            Some(Err(e)) => {
-                // Note: we actually failed to spawn, but this matches the Go output
-                prefixed_ui.error(&format!("command finished with error: {e}"));
-                let error_string = e.to_string();
-                self.errors
-                    .lock()
-                    .expect("lock poisoned")
-                    .push(TaskError::from_spawn(self.task_id_for_display.clone(), e));
-                return Ok(ExecOutcome::Task {
-                    exit_code: None,
-                    message: error_string,
-                });
-            }
+            Some(Err(e)) => return Ok(self.spawn_failure(&mut prefixed_ui, e)),
             // Turbo is shutting down
             None => {
                 return Ok(ExecOutcome::Shutdown);
@@ -925,17 +1302,58 @@ impl ExecContext {
                     task.set_stdin(stdin);
                 }
             }
+        } else if !self.experimental_ui && self.forward_stdin {
+            process.forward_stdin();
         }
 
-        let mut stdout_writer = self
+        // Log patterns are matched against the task's output, other probes are polled
+        // until they pass
+        let (log_watch, probe) = match (self.ready_probe.clone(), ready) {
+            (Some(probe), Some(ready)) => match probe.log_pattern() {
+                Some(pattern) => (Some((pattern, ready)), None),
+                None => {
+                    let workspace_directory = self.workspace_directory.clone();
+                    let env = self.execution_env.clone();
+                    let probe = tokio::spawn(async move {
+                        probe.wait(&workspace_directory, &env).await;
+                        ready.send(()).ok();
+                    });
+                    (None, Some(probe))
+                }
+            },
+            _ => (None, None),
+        };
+
+        let stdout_writer = self
             .task_cache
             .output_writer(prefixed_ui.task_writer())
             .map_err(|e| {
                 telemetry.track_error(TrackedErrors::FailedToCaptureOutputs);
                 e
             })?;
-
-        let exit_status = match process.wait_with_piped_outputs(&mut stdout_writer).await {
+        let mut stdout_writer = ReadyWriter::new(stdout_writer, log_watch);
+
+        // The task is sent a SIGTERM once its timeout elapses, followed by a SIGKILL if
+        // it doesn't exit within the shutdown grace period
+        let timed_out = Arc::new(AtomicBool::new(false));
+        let timeout_watcher = self.timeout.map(|timeout| {
+            let mut process = process.clone();
+            let timed_out = timed_out.clone();
+            tokio::spawn(async move {
+                tokio::time::sleep(timeout).await;
+                timed_out.store(true, Ordering::SeqCst);
+                process.terminate().await;
+            })
+        });
+
+        let exit_status = process.wait_with_piped_outputs(&mut stdout_writer).await;
+        if let Some(probe) = probe {
+            probe.abort();
+        }
+        if let Some(timeout_watcher) = timeout_watcher {
+            timeout_watcher.abort();
+        }
+        let exit_status = match exit_status {
             Ok(Some(exit_status)) => exit_status,
             Err(e) => {
                 telemetry.track_error(TrackedErrors::FailedToPipeOutputs);
@@ -952,6 +1370,47 @@ impl ExecContext {
         };
         let task_duration = task_start.elapsed();
 
+        if let Some(env_audit) = &self.env_audit {
+            match env_audit.undeclared(&self.execution_env) {
+                Ok(undeclared) if !undeclared.is_empty() => prefixed_ui.warn(&format!(
+                    "reads environment variables that aren't declared in `env`: {}",
+                    undeclared.join(", ")
+                )),
+                Ok(_) => (),
+                Err(e) => error!("unable to read the audited environment variables: {e}"),
+            }
+        }
+
+        // A task that exited successfully just as it timed out still succeeded
+        if timed_out.load(Ordering::SeqCst) && !matches!(exit_status, ChildExit::Finished(Some(0)))
+        {
+            if let Err(e) = stdout_writer.flush() {
+                error!("error flushing logs: {e}");
+            }
+            if let Err(e) = self.task_cache.on_error(&mut prefixed_ui) {
+                error!("error reading logs: {e}");
+            }
+            let error = TaskErrorCause::Timeout {
+                command: process.label().to_string(),
+                timeout: humantime::format_duration(self.timeout.unwrap_or_default()).to_string(),
+            };
+            let message = error.to_string();
+            if self.continue_on_error != ContinueMode::Never {
+                prefixed_ui.warn(&format!("{message}, but continuing..."));
+            } else {
+                prefixed_ui.error(&message);
+            }
+            self.errors.lock().expect("lock poisoned").push(TaskError {
+                task_id: self.task_id_for_display.clone(),
+                cause: error,
+            });
+            return Ok(ExecOutcome::Task {
+                exit_code: None,
+                message,
+                timed_out: true,
+            });
+        }
+
         match exit_status {
             ChildExit::Finished(Some(0)) => {
                 // Attempt to flush stdout_writer and log any errors encountered
@@ -962,15 +1421,33 @@ impl ExecContext {
                     .can_cache(&self.task_hash, &self.task_id_for_display)
                     .unwrap_or(true)
                 {
-                    if let Err(e) = self.task_cache.save_outputs(task_duration, telemetry).await {
-                        error!("error caching output: {e}");
-                        return Err(e.into());
-                    } else {
-                        // If no errors, update hash tracker with expanded outputs
-                        self.hash_tracker.insert_expanded_outputs(
-                            self.task_id.clone(),
-                            self.task_cache.expanded_outputs().to_vec(),
-                        );
+                    match self.task_cache.save_outputs(task_duration, telemetry).await {
+                        Err(e @ CacheError::MissingOutputs { .. }) => {
+                            return Ok(self.cache_failure(&mut prefixed_ui, e));
+                        }
+                        Err(e) => {
+                            error!("error caching output: {e}");
+                            return Err(e.into());
+                        }
+                        Ok(saved) => {
+                            // If no errors, update hash tracker with expanded outputs
+                            self.hash_tracker.insert_expanded_outputs(
+                                self.task_id.clone(),
+                                self.task_cache.expanded_outputs().to_vec(),
+                            );
+                            // Puts are queued, so only report the save once the
+                            // artifact is actually in every cache
+                            if let Some(saved) = saved {
+                                self.hooks.run_when(
+                                    saved,
+                                    HookEvent::cache_save(
+                                        &self.task_id,
+                                        &self.task_hash,
+                                        self.task_cache.expanded_outputs(),
+                                    ),
+                                );
+                            }
+                        }
                     }
                 }
 
@@ -987,7 +1464,7 @@ impl ExecContext {
                 }
                 let error = TaskErrorCause::from_execution(process.label().to_string(), code);
                 let message = error.to_string();
-                if self.continue_on_error {
+                if self.continue_on_error != ContinueMode::Never {
                     prefixed_ui.warn("command finished with error, but continuing...");
                 } else {
                     prefixed_ui.error(&format!("command finished with error: {error}"));
@@ -999,6 +1476,7 @@ impl ExecContext {
                 Ok(ExecOutcome::Task {
                     exit_code: Some(code),
                     message,
+                    timed_out: false,
                 })
             }
             // The child exited in a way where we can't figure out how it finished so we assume it
@@ -1011,6 +1489,139 @@ impl ExecContext {
         }
     }
 
+    async fn cache_hit(&mut self, status: CacheHitMetadata) -> ExecOutcome {
+        // we need to set expanded outputs
+        self.hash_tracker.insert_expanded_outputs(
+            self.task_id.clone(),
+            self.task_cache.expanded_outputs().to_vec(),
+        );
+        self.hash_tracker
+            .insert_cache_status(self.task_id.clone(), status);
+        self.hooks
+            .run(HookEvent::cache_fetch(
+                &self.task_id,
+                &self.task_hash,
+                Some(status),
+                self.task_cache.expanded_outputs(),
+            ))
+            .await;
+        ExecOutcome::Success(SuccessOutcome::CacheHit)
+    }
+
+    async fn execute_remotely(
+        &mut self,
+        remote_task: &RemoteTask,
+        prefixed_ui: &mut TaskCacheOutput<impl Write>,
+    ) -> Result<ExecOutcome, InternalError> {
+        prefixed_ui.status(&format!(
+            "running on executor {}",
+            color!(self.ui, GREY, "{}", self.task_hash)
+        ));
+        let error = match remote_task.execute().await {
+            Ok(response) if response.exit_code == 0 => {
+                match self
+                    .task_cache
+                    .restore_executed_outputs(prefixed_ui)
+                    .await?
+                {
+                    Some(_) => {
+                        self.hash_tracker.insert_expanded_outputs(
+                            self.task_id.clone(),
+                            self.task_cache.expanded_outputs().to_vec(),
+                        );
+                        return Ok(ExecOutcome::Success(SuccessOutcome::Run));
+                    }
+                    None => TaskErrorCause::Remote {
+                        msg: "the task's outputs weren't saved to the remote cache".to_string(),
+                    },
+                }
+            }
+            Ok(response) => {
+                // The logs of a task that failed aren't cached, so the executor sends them
+                if let Err(e) = prefixed_ui
+                    .task_writer()
+                    .write_all(response.logs.as_bytes())
+                {
+                    error!("error writing logs: {e}");
+                }
+                TaskErrorCause::from_execution(
+                    format!("{} on executor", self.task_id),
+                    response.exit_code,
+                )
+            }
+            Err(e) => TaskErrorCause::Remote { msg: e.to_string() },
+        };
+
+        let exit_code = match error {
+            TaskErrorCause::Exit { exit_code, .. } => Some(exit_code),
+            _ => None,
+        };
+        let message = error.to_string();
+        if self.continue_on_error != ContinueMode::Never {
+            prefixed_ui.warn(format!("{message}, but continuing..."));
+        } else {
+            prefixed_ui.error(&message);
+        }
+        self.errors.lock().expect("lock poisoned").push(TaskError {
+            task_id: self.task_id_for_display.clone(),
+            cause: error,
+        });
+        Ok(ExecOutcome::Task {
+            exit_code,
+            message,
+            timed_out: false,
+        })
+    }
+
+    fn spawn_failure(
+        &mut self,
+        prefixed_ui: &mut TaskCacheOutput<impl Write>,
+        error: std::io::Error,
+    ) -> ExecOutcome {
+        // Note: we actually failed to spawn, but this matches the Go output
+        prefixed_ui.error(&format!("command finished with error: {error}"));
+        let message = error.to_string();
+        self.errors
+            .lock()
+            .expect("lock poisoned")
+            .push(TaskError::from_spawn(
+                self.task_id_for_display.clone(),
+                error,
+            ));
+        ExecOutcome::Task {
+            exit_code: None,
+            message,
+            timed_out: false,
+        }
+    }
+
+    // With `--strict-cache`, a problem with the cache fails the task like a
+    // failing command would
+    fn cache_failure(
+        &mut self,
+        prefixed_ui: &mut TaskCacheOutput<impl Write>,
+        error: CacheError,
+    ) -> ExecOutcome {
+        let error = TaskErrorCause::Cache {
+            msg: error.to_string(),
+        };
+        let message = error.to_string();
+        if self.continue_on_error != ContinueMode::Never {
+            prefixed_ui.warn(format!("{message}, but continuing..."));
+        } else {
+            prefixed_ui.error(&message);
+        }
+        self.errors.lock().expect("lock poisoned").push(TaskError {
+            task_id: self.task_id_for_display.clone(),
+            cause: error,
+        });
+        ExecOutcome::Task {
+            exit_code: None,
+            message,
+            timed_out: false,
+        }
+    }
+
     fn spaces_task_info(
         &self,
         task_id: TaskId<'static>,
@@ -1036,15 +1647,30 @@ struct DryRunExecContext {
     task_id: TaskId<'static>,
     task_cache: TaskCache,
     hash_tracker: TaskHashTracker,
+    // Whether a dependent has `$DEPS_OUTPUTS$` in its inputs
+    outputs_hashed_by_dependents: bool,
 }
 
 impl DryRunExecContext {
     pub async fn execute_dry_run(&self, tracker: TaskTracker<()>) -> Result<(), InternalError> {
         // may also need to do framework & command stuff?
-        if let Ok(Some(status)) = self.task_cache.exists().await {
+        let status = self.task_cache.exists().await.ok().flatten();
+        if let Some(status) = status {
             self.hash_tracker
                 .insert_cache_status(self.task_id.clone(), status);
         }
+        // A real run records the outputs that a task restores or caches, which
+        // dependents with `$DEPS_OUTPUTS$` hash. The outputs on disk stand in
+        // for them so that the dry run computes the same hashes.
+        let records_outputs = status.is_some() || !self.task_cache.writes_disabled();
+        if self.outputs_hashed_by_dependents && records_outputs {
+            match self.task_cache.outputs_on_disk() {
+                Ok(outputs) => self
+                    .hash_tracker
+                    .insert_expanded_outputs(self.task_id.clone(), outputs),
+                Err(e) => debug!("unable to find outputs of {}: {e}", self.task_id),
+            }
+        }
         tracker.dry_run().await;
         Ok(())
     }
@@ -1104,10 +1730,11 @@ impl<W: Write> CacheOutput for TaskCacheOutput<W> {
 
 /// Struct for displaying information about task
 impl<W: Write> TaskOutput<W> {
-    pub fn finish(self, use_error: bool) -> std::io::Result<Option<Vec<u8>>> {
+    pub fn finish(self, use_error: bool, is_cache_hit: bool) -> std::io::Result<Option<Vec<u8>>> {
         match self {
             TaskOutput::Direct(client) => client.finish(use_error),
             TaskOutput::UI(client) if use_error => Ok(Some(client.failed())),
+            TaskOutput::UI(client) if is_cache_hit => Ok(Some(client.cached())),
             TaskOutput::UI(client) => Ok(Some(client.succeeded())),
         }
     }
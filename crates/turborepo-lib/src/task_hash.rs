@@ -1,15 +1,19 @@
 use std::{
-    collections::{HashMap, HashSet},
+    collections::{BTreeMap, HashMap, HashSet},
+    process::Stdio,
     sync::{Arc, Mutex},
 };
 
 use rayon::prelude::*;
 use serde::Serialize;
 use thiserror::Error;
+use tokio::process::Command;
 use tracing::{debug, Span};
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf};
+use turbopath::{
+    AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPathBuf,
+};
 use turborepo_cache::CacheHitMetadata;
-use turborepo_env::{BySource, DetailedMap, EnvironmentVariableMap};
+use turborepo_env::{dotenv, BySource, DetailedMap, EnvironmentVariableMap};
 use turborepo_repository::package_graph::{PackageInfo, PackageName};
 use turborepo_scm::SCM;
 use turborepo_telemetry::events::{
@@ -23,7 +27,8 @@ use crate::{
     hash::{FileHashes, LockFilePackages, TaskHashable, TurboHash},
     opts::RunOpts,
     run::task_id::TaskId,
-    task_graph::TaskDefinition,
+    task_graph::{Runner, TaskDefinition, TaskOutputs, INPUT_TURBO_ROOT},
+    turbo_json::CONFIG_FILE,
     DaemonClient, DaemonConnector,
 };
 
@@ -41,6 +46,8 @@ pub enum Error {
     Mutex,
     #[error("missing environment variables for {0}")]
     MissingEnvVars(TaskId<'static>),
+    #[error("unable to get the tool version from `{command}`: {reason}")]
+    ToolDependency { command: String, reason: String },
     #[error(transparent)]
     Scm(#[from] turborepo_scm::Error),
     #[error(transparent)]
@@ -51,6 +58,39 @@ pub enum Error {
     Path(#[from] turbopath::PathError),
 }
 
+/// Environment variables that are available to every task in strict env mode,
+/// in addition to the ones declared in `turbo.json`. These are required for
+/// most tools to function and would otherwise need to be declared in every
+/// repository.
+pub const DEFAULT_ENV_VAR_PASS_THROUGH: &[&str] = &[
+    "HOME",
+    "TZ",
+    "LANG",
+    "SHELL",
+    "PWD",
+    "CI",
+    "NODE_OPTIONS",
+    "LD_LIBRARY_PATH",
+    "DYLD_FALLBACK_LIBRARY_PATH",
+    "LIBPATH",
+    "COLORTERM",
+    "TERM",
+    "TERM_PROGRAM",
+    // Vercel specific
+    "VERCEL_*",
+    "NEXT_*",
+    "USE_OUTPUT_FOR_EDGE_FUNCTIONS",
+    "NOW_BUILDER",
+    // Command Prompt casing of env variables
+    "APPDATA",
+    "PATH",
+    "SYSTEMROOT",
+    // Powershell casing of env variables
+    "Path",
+    "SystemRoot",
+    "AppData",
+];
+
 impl TaskHashable<'_> {
     fn calculate_task_hash(mut self) -> String {
         if matches!(self.env_mode, EnvMode::Loose) {
@@ -61,7 +101,7 @@ impl TaskHashable<'_> {
     }
 }
 
-#[derive(Debug, Default)]
+#[derive(Debug, Default, Clone)]
 pub struct PackageInputsHashes {
     hashes: HashMap<TaskId<'static>, String>,
     expanded_hashes: HashMap<TaskId<'static>, FileHashes>,
@@ -78,12 +118,12 @@ impl PackageInputsHashes {
         telemetry: &GenericEventBuilder,
         daemon: &mut Option<DaemonClient<DaemonConnector>>,
     ) -> Result<PackageInputsHashes, Error> {
-        tracing::trace!(scm_manual=%scm.is_manual(), "scm running in {} mode", if scm.is_manual() { "manual" } else { "git" });
+        tracing::trace!(scm_manual=%scm.is_manual(), "scm running in {} mode", scm.name());
 
         let span = Span::current();
         let (hashes, expanded_hashes): (HashMap<_, _>, HashMap<_, _>) = all_tasks
             .filter_map(|task| {
-                let span = tracing::info_span!(parent: &span, "calculate_file_hash", ?task);
+                let span = tracing::info_span!(parent: &span, "calculate_file_hash", %task);
                 let _enter = span.enter();
                 let TaskNode::Task(task_id) = task else {
                     return None;
@@ -100,7 +140,7 @@ impl PackageInputsHashes {
                     PackageTaskEventBuilder::new(task_id.package(), task_id.task())
                         .with_parent(telemetry);
 
-                package_task_event.track_scm_mode(if scm.is_manual() { "manual" } else { "git" });
+                package_task_event.track_scm_mode(scm.name());
                 let workspace_name = task_id.to_workspace_name();
 
                 let pkg = match workspaces
@@ -115,6 +155,7 @@ impl PackageInputsHashes {
                     .package_json_path
                     .parent()
                     .unwrap_or_else(|| AnchoredSystemPath::new("").unwrap());
+                let inputs = task_definition.package_inputs(package_path);
 
                 let scm_telemetry = package_task_event.child();
                 // Try hashing with the daemon, if we have a connection. If we don't, or if we
@@ -137,8 +178,7 @@ impl PackageInputsHashes {
                                 .block_on(async {
                                     tokio::time::timeout(
                                         std::time::Duration::from_millis(100),
-                                        daemon
-                                            .get_file_hashes(package_path, &task_definition.inputs),
+                                        daemon.get_file_hashes(package_path, &inputs),
                                     )
                                     .await
                                 })
@@ -185,13 +225,13 @@ impl PackageInputsHashes {
                     None
                 };
 
-                let hash_object = match hash_object {
+                let mut hash_object = match hash_object {
                     Some(hash_object) => hash_object,
                     None => {
                         let local_hash_result = scm.get_package_file_hashes(
                             repo_root,
                             package_path,
-                            &task_definition.inputs,
+                            &inputs,
                             Some(scm_telemetry),
                         );
                         match local_hash_result {
@@ -201,6 +241,22 @@ impl PackageInputsHashes {
                     }
                 };
 
+                remove_turbo_json(&mut hash_object, &task_definition.inputs);
+                remove_dot_env(
+                    &mut hash_object,
+                    &task_definition.package_dot_env(package_path),
+                    &inputs,
+                );
+
+                if let Err(err) = turborepo_scm::transform::apply_input_transforms(
+                    repo_root,
+                    package_path,
+                    &task_definition.input_transforms,
+                    &mut hash_object,
+                ) {
+                    return Some(Err(err.into()));
+                }
+
                 let file_hashes = FileHashes(hash_object);
                 let hash = file_hashes.clone().hash();
 
@@ -236,6 +292,35 @@ pub struct TaskHashTrackerState {
     package_task_cache: HashMap<TaskId<'static>, CacheHitMetadata>,
     #[serde(skip)]
     package_task_inputs_expanded_hashes: HashMap<TaskId<'static>, FileHashes>,
+    #[serde(skip)]
+    package_task_hash_inputs: HashMap<TaskId<'static>, TaskHashInputs>,
+    // Dotenv variables of the tasks that have them injected into their environment
+    #[serde(skip)]
+    package_task_dot_env: HashMap<TaskId<'static>, EnvironmentVariableMap>,
+}
+
+/// An owned copy of the values that were fed into a task's hash. Environment
+/// variable values are replaced with their hashes so that this is safe to
+/// display.
+#[derive(Debug, Clone, Serialize)]
+#[serde(rename_all = "camelCase")]
+pub struct TaskHashInputs {
+    pub global_hash: String,
+    pub hash_of_files: String,
+    pub hash_of_external_dependencies: Option<String>,
+    #[serde(skip)]
+    pub task_dependency_hashes: BTreeMap<TaskId<'static>, String>,
+    pub package_dir: Option<RelativeUnixPathBuf>,
+    pub outputs: TaskOutputs,
+    pub pass_through_args: Vec<String>,
+    pub env: Vec<String>,
+    pub resolved_env_vars: Vec<String>,
+    pub pass_through_env: Vec<String>,
+    pub env_mode: EnvMode,
+    #[serde(skip_serializing_if = "BTreeMap::is_empty")]
+    pub tool_versions: BTreeMap<String, String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub dot_env: Vec<String>,
 }
 
 /// Caches package-inputs hashes, and package-task hashes.
@@ -244,7 +329,13 @@ pub struct TaskHasher<'a> {
     run_opts: &'a RunOpts,
     env_at_execution_start: &'a EnvironmentVariableMap,
     global_hash: &'a str,
+    repo_root: &'a AbsoluteSystemPath,
     task_hash_tracker: TaskHashTracker,
+    // Output of tool dependency commands, keyed by command and package path
+    tool_versions: Mutex<HashMap<(String, AnchoredSystemPathBuf), String>>,
+    // Variables from the `globalDotEnv` files, which are injected along with a
+    // task's own dotenv variables
+    global_dot_env: EnvironmentVariableMap,
 }
 
 impl<'a> TaskHasher<'a> {
@@ -253,6 +344,7 @@ impl<'a> TaskHasher<'a> {
         run_opts: &'a RunOpts,
         env_at_execution_start: &'a EnvironmentVariableMap,
         global_hash: &'a str,
+        repo_root: &'a AbsoluteSystemPath,
     ) -> Self {
         let PackageInputsHashes {
             hashes,
@@ -263,12 +355,21 @@ impl<'a> TaskHasher<'a> {
             run_opts,
             env_at_execution_start,
             global_hash,
+            repo_root,
             task_hash_tracker: TaskHashTracker::new(expanded_hashes),
+            tool_versions: Mutex::default(),
+            global_dot_env: EnvironmentVariableMap::default(),
         }
     }
 
+    /// Sets the variables from the `globalDotEnv` files for tasks that inject
+    /// their dotenv variables
+    pub fn inject_global_dot_env(&mut self, global_dot_env: EnvironmentVariableMap) {
+        self.global_dot_env = global_dot_env;
+    }
+
     #[tracing::instrument(skip(self, task_definition, task_env_mode, workspace, dependency_set))]
-    pub fn calculate_task_hash(
+    pub async fn calculate_task_hash(
         &self,
         task_id: &TaskId<'static>,
         task_definition: &TaskDefinition,
@@ -362,7 +463,17 @@ impl<'a> TaskHasher<'a> {
 
         let hashable_env_pairs = env_vars.all.to_hashable();
         let outputs = task_definition.hashable_outputs(task_id);
-        let task_dependency_hashes = self.calculate_dependency_hashes(dependency_set)?;
+        let dependency_hashes_by_task = self.calculate_dependency_hashes(dependency_set)?;
+        let mut task_dependency_hashes = if task_definition.hashes_dependency_outputs() {
+            self.dependency_outputs_hashes(&dependency_hashes_by_task)?
+        } else {
+            dependency_hashes_by_task
+                .values()
+                .cloned()
+                .collect::<Vec<_>>()
+        };
+        task_dependency_hashes.sort();
+        task_dependency_hashes.dedup();
         let external_deps_hash =
             is_monorepo.then(|| get_external_deps_hash(&workspace.transitive_dependencies));
 
@@ -377,6 +488,14 @@ impl<'a> TaskHasher<'a> {
         let is_root_package = package_dir.is_empty();
         // We wrap in an Option to mimic Go's serialization of nullable values
         let optional_package_dir = (!is_root_package).then_some(package_dir);
+        let tool_versions = self.tool_versions(task_definition, workspace).await?;
+        let dot_env = self.dot_env(task_definition, workspace)?;
+        let runner = task_definition.runner.as_ref().map(Runner::hash_value);
+        // The shell only changes how the task runs if turbo runs its command itself
+        let shell = task_definition
+            .uses_shell(workspace)
+            .then(|| task_definition.shell.config_value())
+            .flatten();
 
         let task_hashable = TaskHashable {
             global_hash: self.global_hash,
@@ -395,6 +514,33 @@ impl<'a> TaskHasher<'a> {
                 .as_deref()
                 .unwrap_or_default(),
             env_mode: task_env_mode,
+            tool_versions: &tool_versions,
+            dot_env: dot_env.to_hashable(),
+            runner: runner.as_deref(),
+            shell,
+            follow_symlinks: task_definition.follow_symlinks,
+        };
+
+        let hash_inputs = TaskHashInputs {
+            global_hash: task_hashable.global_hash.to_string(),
+            hash_of_files: task_hashable.hash_of_files.to_string(),
+            hash_of_external_dependencies: task_hashable.external_deps_hash.clone(),
+            task_dependency_hashes: dependency_hashes_by_task,
+            package_dir: task_hashable.package_dir.clone(),
+            outputs: task_hashable.outputs.clone(),
+            pass_through_args: task_hashable.pass_through_args.to_vec(),
+            env: task_hashable.env.to_vec(),
+            resolved_env_vars: env_vars
+                .all
+                .to_salted_hashable(self.run_opts.env_hash_salt.as_deref()),
+            // Loose mode doesn't include pass through env in the hash
+            pass_through_env: match task_env_mode {
+                EnvMode::Loose => Vec::new(),
+                EnvMode::Strict => task_hashable.pass_through_env.to_vec(),
+            },
+            env_mode: task_env_mode,
+            tool_versions: tool_versions.clone(),
+            dot_env: dot_env.to_salted_hashable(self.run_opts.env_hash_salt.as_deref()),
         };
 
         let task_hash = task_hashable.calculate_task_hash();
@@ -404,11 +550,84 @@ impl<'a> TaskHasher<'a> {
             env_vars,
             task_hash.clone(),
             framework_slug,
+            hash_inputs,
         );
+        if task_definition.inject_dot_env {
+            // The task's own files are more specific than the global ones
+            let mut injected = dot_env;
+            for (key, value) in self.global_dot_env.iter() {
+                injected.entry(key.clone()).or_insert_with(|| value.clone());
+            }
+            self.task_hash_tracker
+                .insert_dot_env(task_id.clone(), injected);
+        }
 
         Ok(task_hash)
     }
 
+    /// Loads the variables from a task's dotenv files. Files are relative to
+    /// the package unless they start with `$TURBO_ROOT$/`.
+    fn dot_env(
+        &self,
+        task_definition: &TaskDefinition,
+        workspace: &PackageInfo,
+    ) -> Result<EnvironmentVariableMap, Error> {
+        if task_definition.dot_env.is_empty() {
+            return Ok(EnvironmentVariableMap::default());
+        }
+        let package_dir = self.repo_root.resolve(workspace.package_path());
+        let paths = task_definition
+            .dot_env
+            .iter()
+            .map(|file| match file.strip_prefix(INPUT_TURBO_ROOT) {
+                Some(file) => self.repo_root.as_std_path().join(file),
+                None => package_dir.as_std_path().join(file),
+            })
+            .collect::<Vec<_>>();
+
+        Ok(dotenv::load(&paths)?)
+    }
+
+    /// Gets the versions of the tools that a task depends on. Each command is
+    /// run once per package since the version can depend on the directory,
+    /// e.g. through `.nvmrc` or `rust-toolchain.toml`.
+    async fn tool_versions(
+        &self,
+        task_definition: &TaskDefinition,
+        workspace: &PackageInfo,
+    ) -> Result<BTreeMap<String, String>, Error> {
+        let package_path = workspace.package_path();
+        let mut tool_versions = BTreeMap::new();
+        for command in &task_definition.tool_dependencies {
+            let key = (command.clone(), package_path.to_owned());
+            let cached = self
+                .tool_versions
+                .lock()
+                .map_err(|_| Error::Mutex)?
+                .get(&key)
+                .cloned();
+            let version = match cached {
+                Some(version) => version,
+                None => {
+                    let version = tool_version(
+                        &self.repo_root.resolve(package_path),
+                        command,
+                        self.env_at_execution_start,
+                    )
+                    .await?;
+                    self.tool_versions
+                        .lock()
+                        .map_err(|_| Error::Mutex)?
+                        .insert(key, version.clone());
+                    version
+                }
+            };
+            tool_versions.insert(command.clone(), version);
+        }
+
+        Ok(tool_versions)
+    }
+
     /// Gets the hashes of a task's dependencies. Because the visitor
     /// receives the nodes in topological order, we know that all of
     /// the dependencies have been processed before the current task.
@@ -417,12 +636,12 @@ impl<'a> TaskHasher<'a> {
     ///
     /// * `dependency_set`: The dependencies of the current task
     ///
-    /// returns: Result<Vec<String, Global>, Error>
+    /// returns: Result<BTreeMap<TaskId, String>, Error>
     fn calculate_dependency_hashes(
         &self,
         dependency_set: HashSet<&TaskNode>,
-    ) -> Result<Vec<String>, Error> {
-        let mut dependency_hash_set = HashSet::new();
+    ) -> Result<BTreeMap<TaskId<'static>, String>, Error> {
+        let mut dependency_hashes = BTreeMap::new();
 
         for dependency_task in dependency_set {
             let TaskNode::Task(dependency_task_id) = dependency_task else {
@@ -433,13 +652,43 @@ impl<'a> TaskHasher<'a> {
                 .task_hash_tracker
                 .hash(dependency_task_id)
                 .ok_or_else(|| Error::MissingDependencyTaskHash(dependency_task.to_string()))?;
-            dependency_hash_set.insert(dependency_hash.clone());
+            dependency_hashes.insert(dependency_task_id.clone(), dependency_hash);
         }
 
-        let mut dependency_hash_list = dependency_hash_set.into_iter().collect::<Vec<_>>();
-        dependency_hash_list.sort();
+        Ok(dependency_hashes)
+    }
 
-        Ok(dependency_hash_list)
+    /// Hashes the files that each of a task's dependencies wrote, for tasks
+    /// with `$DEPS_OUTPUTS$` in their inputs, so that they only miss the cache
+    /// when those files change. Log files are left out since they can change
+    /// on every run. Dependencies without any recorded outputs, e.g. because
+    /// they aren't cached, are represented by their task hash instead.
+    fn dependency_outputs_hashes(
+        &self,
+        dependency_hashes: &BTreeMap<TaskId<'static>, String>,
+    ) -> Result<Vec<String>, Error> {
+        dependency_hashes
+            .iter()
+            .map(|(task_id, hash)| {
+                let log_file = TaskDefinition::workspace_relative_log_file(task_id.task());
+                let outputs = self
+                    .task_hash_tracker
+                    .expanded_outputs(task_id)
+                    .unwrap_or_default()
+                    .into_iter()
+                    .filter(|output| {
+                        !output.as_path().ends_with(log_file.as_path())
+                            && self.repo_root.resolve(output).as_std_path().is_file()
+                    })
+                    .collect::<Vec<_>>();
+                if outputs.is_empty() {
+                    return Ok(hash.clone());
+                }
+                let file_hashes =
+                    turborepo_scm::manual::hash_files(self.repo_root, outputs.iter(), true)?;
+                Ok(FileHashes(file_hashes).hash())
+            })
+            .collect()
     }
 
     pub fn into_task_hash_tracker_state(self) -> TaskHashTrackerState {
@@ -452,6 +701,50 @@ impl<'a> TaskHasher<'a> {
         self.task_hash_tracker.clone()
     }
 
+    /// The variables that a task declares through `env`, `passThroughEnv` and
+    /// the global configuration. These are the variables available to the
+    /// task in strict mode.
+    pub fn declared_env(
+        &self,
+        task_id: &TaskId,
+        task_definition: &TaskDefinition,
+        global_env: &EnvironmentVariableMap,
+    ) -> Result<EnvironmentVariableMap, Error> {
+        let mut env = self
+            .env_at_execution_start
+            .from_wildcards(DEFAULT_ENV_VAR_PASS_THROUGH)?;
+        env.union(&self.configured_env(task_id, task_definition, global_env)?);
+        Ok(env)
+    }
+
+    /// Like `declared_env`, but without the system variables such as `PATH`
+    /// that every task gets in strict mode
+    pub fn configured_env(
+        &self,
+        task_id: &TaskId,
+        task_definition: &TaskDefinition,
+        global_env: &EnvironmentVariableMap,
+    ) -> Result<EnvironmentVariableMap, Error> {
+        let mut pass_through_env = EnvironmentVariableMap::default();
+        let tracker_env = self
+            .task_hash_tracker
+            .env_vars(task_id)
+            .ok_or_else(|| Error::MissingEnvVars(task_id.clone().into_owned()))?;
+
+        pass_through_env.union(global_env);
+        pass_through_env.union(&tracker_env.all);
+
+        let env_var_pass_through_map = self.env_at_execution_start.from_wildcards(
+            task_definition
+                .pass_through_env
+                .as_deref()
+                .unwrap_or_default(),
+        )?;
+        pass_through_env.union(&env_var_pass_through_map);
+
+        Ok(pass_through_env)
+    }
+
     pub fn env(
         &self,
         task_id: &TaskId,
@@ -459,62 +752,86 @@ impl<'a> TaskHasher<'a> {
         task_definition: &TaskDefinition,
         global_env: &EnvironmentVariableMap,
     ) -> Result<EnvironmentVariableMap, Error> {
-        match task_env_mode {
-            EnvMode::Strict => {
-                let mut pass_through_env = EnvironmentVariableMap::default();
-                let default_env_var_pass_through_map =
-                    self.env_at_execution_start.from_wildcards(&[
-                        "HOME",
-                        "TZ",
-                        "LANG",
-                        "SHELL",
-                        "PWD",
-                        "CI",
-                        "NODE_OPTIONS",
-                        "LD_LIBRARY_PATH",
-                        "DYLD_FALLBACK_LIBRARY_PATH",
-                        "LIBPATH",
-                        "COLORTERM",
-                        "TERM",
-                        "TERM_PROGRAM",
-                        // Vercel specific
-                        "VERCEL_*",
-                        "NEXT_*",
-                        "USE_OUTPUT_FOR_EDGE_FUNCTIONS",
-                        "NOW_BUILDER",
-                        // Command Prompt casing of env variables
-                        "APPDATA",
-                        "PATH",
-                        "SYSTEMROOT",
-                        // Powershell casing of env variables
-                        "Path",
-                        "SystemRoot",
-                        "AppData",
-                    ])?;
-                let tracker_env = self
-                    .task_hash_tracker
-                    .env_vars(task_id)
-                    .ok_or_else(|| Error::MissingEnvVars(task_id.clone().into_owned()))?;
-
-                pass_through_env.union(&default_env_var_pass_through_map);
-                pass_through_env.union(global_env);
-                pass_through_env.union(&tracker_env.all);
-
-                let env_var_pass_through_map = self.env_at_execution_start.from_wildcards(
-                    task_definition
-                        .pass_through_env
-                        .as_deref()
-                        .unwrap_or_default(),
-                )?;
-                pass_through_env.union(&env_var_pass_through_map);
-
-                Ok(pass_through_env)
+        let mut env = match task_env_mode {
+            EnvMode::Strict => self.declared_env(task_id, task_definition, global_env)?,
+            EnvMode::Loose => self.env_at_execution_start.clone(),
+        };
+
+        // Dotenv files don't override variables that are already set
+        if let Some(dot_env) = self.task_hash_tracker.dot_env(task_id) {
+            for (key, value) in dot_env.iter() {
+                env.entry(key.clone()).or_insert_with(|| value.clone());
             }
-            EnvMode::Loose => Ok(self.env_at_execution_start.clone()),
         }
+
+        Ok(env)
+    }
+}
+
+/// Removes the package's `turbo.json` from the files hashed for a task, unless
+/// it's listed in the task's `inputs`. The parts of it that affect the task
+/// are already hashed through the task's resolved definition, so editing the
+/// definition of one task doesn't change the hashes of the package's other
+/// tasks.
+fn remove_turbo_json(hash_object: &mut HashMap<RelativeUnixPathBuf, String>, inputs: &[String]) {
+    if inputs.iter().any(|input| input == CONFIG_FILE) {
+        return;
+    }
+    hash_object.retain(|path, _| path.as_str() != CONFIG_FILE);
+}
+
+/// Removes the task's dotenv files from the files hashed for it, unless they're
+/// listed in its `inputs`. Their variables are already hashed, so changes that
+/// don't affect the variables, like comments, don't change the hash.
+fn remove_dot_env(
+    hash_object: &mut HashMap<RelativeUnixPathBuf, String>,
+    dot_env: &[String],
+    inputs: &[String],
+) {
+    let dot_env = dot_env
+        .iter()
+        .filter(|file| !inputs.contains(file))
+        .map(String::as_str)
+        .collect::<HashSet<_>>();
+    if !dot_env.is_empty() {
+        hash_object.retain(|path, _| !dot_env.contains(path.as_str()));
     }
 }
 
+/// Runs a command that prints the version of a tool and returns its trimmed
+/// output
+async fn tool_version(
+    directory: &AbsoluteSystemPath,
+    command: &str,
+    env: &EnvironmentVariableMap,
+) -> Result<String, Error> {
+    let (shell, flag) = if cfg!(windows) {
+        ("cmd", "/C")
+    } else {
+        ("sh", "-c")
+    };
+    let output = Command::new(shell)
+        .args([flag, command])
+        .current_dir(directory.as_std_path())
+        .env_clear()
+        .envs(env.iter())
+        .stdin(Stdio::null())
+        .output()
+        .await
+        .map_err(|err| Error::ToolDependency {
+            command: command.to_string(),
+            reason: err.to_string(),
+        })?;
+    if !output.status.success() {
+        return Err(Error::ToolDependency {
+            command: command.to_string(),
+            reason: format!("command exited with {}", output.status),
+        });
+    }
+
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
 pub fn get_external_deps_hash(
     transitive_dependencies: &Option<HashSet<turborepo_lockfiles::Package>>,
 ) -> String {
@@ -582,11 +899,15 @@ impl TaskHashTracker {
         env_vars: DetailedMap,
         hash: String,
         framework_slug: Option<String>,
+        hash_inputs: TaskHashInputs,
     ) {
         let mut state = self.state.lock().expect("hash tracker mutex poisoned");
         state
             .package_task_env_vars
             .insert(task_id.clone(), env_vars);
+        state
+            .package_task_hash_inputs
+            .insert(task_id.clone(), hash_inputs);
         if let Some(framework) = framework_slug {
             state
                 .package_task_framework
@@ -595,11 +916,26 @@ impl TaskHashTracker {
         state.package_task_hashes.insert(task_id, hash);
     }
 
+    fn insert_dot_env(&self, task_id: TaskId<'static>, dot_env: EnvironmentVariableMap) {
+        let mut state = self.state.lock().expect("hash tracker mutex poisoned");
+        state.package_task_dot_env.insert(task_id, dot_env);
+    }
+
+    pub fn dot_env(&self, task_id: &TaskId) -> Option<EnvironmentVariableMap> {
+        let state = self.state.lock().expect("hash tracker mutex poisoned");
+        state.package_task_dot_env.get(task_id).cloned()
+    }
+
     pub fn env_vars(&self, task_id: &TaskId) -> Option<DetailedMap> {
         let state = self.state.lock().expect("hash tracker mutex poisoned");
         state.package_task_env_vars.get(task_id).cloned()
     }
 
+    pub fn hash_inputs(&self, task_id: &TaskId) -> Option<TaskHashInputs> {
+        let state = self.state.lock().expect("hash tracker mutex poisoned");
+        state.package_task_hash_inputs.get(task_id).cloned()
+    }
+
     pub fn framework(&self, task_id: &TaskId) -> Option<String> {
         let state = self.state.lock().expect("hash tracker mutex poisoned");
         state.package_task_framework.get(task_id).cloned()
@@ -651,4 +987,204 @@ mod test {
         assert_send::<TaskHashTracker>();
         assert_sync::<TaskHashTracker>();
     }
+
+    #[test]
+    fn test_remove_turbo_json() {
+        let hashes = HashMap::from([
+            (
+                RelativeUnixPathBuf::new("turbo.json").unwrap(),
+                "a".to_string(),
+            ),
+            (
+                RelativeUnixPathBuf::new("src/turbo.json").unwrap(),
+                "b".to_string(),
+            ),
+            (
+                RelativeUnixPathBuf::new("package.json").unwrap(),
+                "c".to_string(),
+            ),
+        ]);
+
+        let mut default_inputs = hashes.clone();
+        remove_turbo_json(&mut default_inputs, &[]);
+        let mut paths = default_inputs
+            .keys()
+            .map(|path| path.as_str())
+            .collect::<Vec<_>>();
+        paths.sort();
+        assert_eq!(paths, vec!["package.json", "src/turbo.json"]);
+
+        let mut listed = hashes.clone();
+        remove_turbo_json(
+            &mut listed,
+            &["src/**".to_string(), "turbo.json".to_string()],
+        );
+        assert_eq!(listed, hashes);
+    }
+
+    #[test]
+    fn test_remove_dot_env() {
+        let task_definition = TaskDefinition {
+            dot_env: vec![
+                "./.env".to_string(),
+                ".env.local".to_string(),
+                "$TURBO_ROOT$/.env".to_string(),
+            ],
+            ..Default::default()
+        };
+        let package_path = AnchoredSystemPathBuf::from_raw("apps/web").unwrap();
+        let dot_env = task_definition.package_dot_env(&package_path);
+        assert_eq!(dot_env, vec![".env", ".env.local", "../../.env"]);
+
+        let mut hashes = [".env", ".env.local", "../../.env", "src/index.ts"]
+            .into_iter()
+            .map(|path| (RelativeUnixPathBuf::new(path).unwrap(), "hash".to_string()))
+            .collect::<HashMap<_, _>>();
+        // Files that are listed in the inputs are still hashed
+        remove_dot_env(&mut hashes, &dot_env, &[".env.local".to_string()]);
+        let mut paths = hashes.keys().map(|path| path.as_str()).collect::<Vec<_>>();
+        paths.sort();
+        assert_eq!(paths, vec![".env.local", "src/index.ts"]);
+    }
+
+    #[test]
+    fn test_strict_env_only_passes_through_declared_vars() {
+        let env_at_execution_start = EnvironmentVariableMap::from(
+            [
+                ("PATH", "/usr/bin"),
+                ("HOME", "/home/turbo"),
+                ("MY_API_URL", "https://example.com"),
+                ("AWS_SECRET", "hunter2"),
+                ("GLOBAL_VAR", "global"),
+                ("PASS_THROUGH_VAR", "pass"),
+                ("UNDECLARED", "leak"),
+            ]
+            .into_iter()
+            .map(|(k, v)| (k.to_string(), v.to_string()))
+            .collect::<HashMap<_, _>>(),
+        );
+        let task_id = TaskId::new("web", "build").into_owned();
+        let task_definition = TaskDefinition {
+            pass_through_env: Some(vec!["PASS_THROUGH_*".to_string()]),
+            ..Default::default()
+        };
+        let global_env = env_at_execution_start
+            .from_wildcards(&["GLOBAL_VAR"])
+            .unwrap();
+        let hashed_env = env_at_execution_start
+            .from_wildcards(&["MY_API_URL"])
+            .unwrap();
+
+        let run_opts = RunOpts {
+            env_mode: EnvMode::Strict,
+            ..RunOpts::for_tasks(vec!["build".to_string()])
+        };
+        let repo_root = tempfile::tempdir().unwrap();
+        let repo_root = turbopath::AbsoluteSystemPathBuf::try_from(repo_root.path()).unwrap();
+        let hasher = TaskHasher::new(
+            PackageInputsHashes {
+                hashes: HashMap::new(),
+                expanded_hashes: HashMap::new(),
+            },
+            &run_opts,
+            &env_at_execution_start,
+            "global-hash",
+            &repo_root,
+        );
+        hasher
+            .task_hash_tracker
+            .state
+            .lock()
+            .unwrap()
+            .package_task_env_vars
+            .insert(
+                task_id.clone(),
+                DetailedMap {
+                    all: hashed_env,
+                    by_source: BySource::default(),
+                },
+            );
+
+        let strict_env = hasher
+            .env(&task_id, EnvMode::Strict, &task_definition, &global_env)
+            .unwrap();
+        assert_eq!(
+            strict_env.names(),
+            vec![
+                "GLOBAL_VAR",
+                "HOME",
+                "MY_API_URL",
+                "PASS_THROUGH_VAR",
+                "PATH"
+            ]
+        );
+
+        let loose_env = hasher
+            .env(&task_id, EnvMode::Loose, &task_definition, &global_env)
+            .unwrap();
+        assert!(loose_env.contains_key("UNDECLARED"));
+        assert!(loose_env.contains_key("AWS_SECRET"));
+    }
+
+    #[test]
+    fn test_dependency_outputs_hashes() {
+        let run_opts = RunOpts::for_tasks(vec!["build".to_string()]);
+        let env = EnvironmentVariableMap::default();
+        let repo_root = tempfile::tempdir().unwrap();
+        let repo_root = turbopath::AbsoluteSystemPathBuf::try_from(repo_root.path()).unwrap();
+        let hasher = TaskHasher::new(
+            PackageInputsHashes::default(),
+            &run_opts,
+            &env,
+            "global-hash",
+            &repo_root,
+        );
+
+        let built = TaskId::new("lib", "build").into_owned();
+        let unrecorded = TaskId::new("utils", "build").into_owned();
+        let dependency_hashes = BTreeMap::from([
+            (built.clone(), "built-hash".to_string()),
+            (unrecorded, "unrecorded-hash".to_string()),
+        ]);
+        let dist = repo_root.join_components(&["packages", "lib", "dist", "index.js"]);
+        let log = repo_root.join_components(&["packages", "lib", ".turbo", "turbo-build.log"]);
+        let outputs = [&dist, &log].map(|file| {
+            file.ensure_dir().unwrap();
+            AnchoredSystemPathBuf::relative_path_between(&repo_root, file)
+        });
+        hasher
+            .task_hash_tracker
+            .insert_expanded_outputs(built, outputs.to_vec());
+
+        let hashes = |contents: &str, log_contents: &str| {
+            dist.create_with_contents(contents).unwrap();
+            log.create_with_contents(log_contents).unwrap();
+            hasher
+                .dependency_outputs_hashes(&dependency_hashes)
+                .unwrap()
+        };
+
+        let first = hashes("export {}", "built in 1s");
+        assert_ne!(first[0], "built-hash");
+        // Dependencies without recorded outputs fall back to their task hash
+        assert_eq!(first[1], "unrecorded-hash");
+        assert_eq!(hashes("export {}", "built in 2s"), first);
+        assert_ne!(hashes("export const a = 1", "built in 2s"), first);
+    }
+
+    #[tokio::test]
+    async fn test_tool_version() {
+        let dir = tempfile::tempdir().unwrap();
+        let dir = turbopath::AbsoluteSystemPathBuf::try_from(dir.path()).unwrap();
+        let env = EnvironmentVariableMap::default();
+
+        assert_eq!(
+            tool_version(&dir, "echo v1.2.3", &env).await.unwrap(),
+            "v1.2.3"
+        );
+        assert!(matches!(
+            tool_version(&dir, "exit 1", &env).await,
+            Err(Error::ToolDependency { .. })
+        ));
+    }
 }
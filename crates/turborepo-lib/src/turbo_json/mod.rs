@@ -1,38 +1,96 @@
 use std::{
     collections::{BTreeMap, HashMap, HashSet},
     ops::{Deref, DerefMut},
+    str::FromStr,
     sync::Arc,
 };
 
 use biome_deserialize_macros::Deserializable;
 use camino::Utf8Path;
 use miette::{NamedSource, SourceSpan};
+use regex::Regex;
 use serde::{Deserialize, Serialize};
 use struct_iterable::Iterable;
-use tracing::debug;
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPath};
+use tracing::{debug, warn};
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf};
 use turborepo_errors::Spanned;
-use turborepo_repository::{package_graph::ROOT_PKG_NAME, package_json::PackageJson};
+use turborepo_repository::{
+    package_graph::{WorkspaceProvider, ROOT_PKG_NAME},
+    package_json::PackageJson,
+};
+use turborepo_scm::transform::InputTransform;
 
 use crate::{
-    cli::OutputLogsMode,
-    config::{ConfigurationOptions, Error, InvalidEnvPrefixError},
+    cli::{OutputLogsMode, UIMode},
+    config::{self, ConfigurationOptions, Error, InvalidEnvPrefixError},
     run::{
         task_access::{TaskAccessTraceFile, TASK_ACCESS_CONFIG_PATH},
         task_id::{TaskId, TaskName},
     },
-    task_graph::{TaskDefinition, TaskOutputs},
+    task_graph::{ReadyProbe, Runner, TaskDefinition, TaskOutputs, TaskShell, INPUT_TURBO_ROOT},
     unescape::UnescapedString,
 };
 
 pub mod parser;
+pub mod schema;
 
 #[derive(Serialize, Deserialize, Debug, Default, PartialEq, Clone, Deserializable)]
 #[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
 pub struct SpacesJson {
     pub id: Option<UnescapedString>,
 }
 
+#[derive(Serialize, Deserialize, Debug, Default, PartialEq, Clone, Deserializable)]
+#[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
+pub struct RawCacheOptions {
+    // The codec and level used to compress cache artifacts, e.g. "zstd:3"
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub compression: Option<String>,
+    // Local cache directories that are searched after `cacheDir`, in order
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub tiers: Option<Vec<RawCacheTier>>,
+}
+
+#[derive(Serialize, Deserialize, Debug, Default, PartialEq, Clone, Deserializable)]
+#[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
+pub struct RawCacheTier {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub dir: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub read_only: Option<bool>,
+}
+
+// Executables that are run at points during a run, relative to the repository
+// root. Each one receives a JSON description of the event on stdin.
+#[derive(Serialize, Deserialize, Debug, Default, PartialEq, Clone, Deserializable)]
+#[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
+pub struct HooksJson {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub pre_run: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub post_task: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub cache_event: Option<String>,
+    // Seconds a hook may run before it's killed
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub timeout: Option<u64>,
+}
+
+// Options for `turbo prune`
+#[derive(Serialize, Deserialize, Debug, Default, PartialEq, Clone, Deserializable)]
+#[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
+pub struct PruneJson {
+    // Globs, relative to the repository root, of extra files and directories
+    // to copy into the pruned output
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub include: Option<Vec<String>>,
+}
+
 // A turbo.json config that is synthesized but not yet resolved.
 // This means that we've done the work to synthesize the config from
 // package.json, but we haven't yet resolved the workspace
@@ -49,14 +107,27 @@ pub struct TurboJson {
     path: Option<Arc<str>>,
     pub(crate) extends: Spanned<Vec<String>>,
     pub(crate) global_deps: Vec<String>,
+    pub(crate) global_dot_env: Vec<String>,
     pub(crate) global_env: Vec<String>,
     pub(crate) global_pass_through_env: Option<Vec<String>>,
     pub(crate) tasks: Pipeline,
+    pub(crate) hooks: HooksJson,
+    // Shareable configs from packages listed in `extends`, in the order they
+    // were listed
+    pub(crate) extended: Vec<TurboJson>,
+    pub(crate) profiles: BTreeMap<String, Pipeline>,
+    // Task definitions of the profile selected with `--profile-name`, these
+    // are merged last so they take precedence over package configurations
+    pub(crate) profile_tasks: Pipeline,
+    // Providers of non-JavaScript workspaces for package graphs that are built
+    // without the layered config, such as the daemon's
+    pub(crate) workspace_providers: Vec<WorkspaceProvider>,
 }
 
 // Iterable is required to enumerate allowed keys
 #[derive(Clone, Debug, Default, Iterable, Serialize, Deserializable)]
 #[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
 pub(crate) struct RawRemoteCacheOptions {
     #[serde(skip_serializing_if = "Option::is_none")]
     api_url: Option<String>,
@@ -73,7 +144,35 @@ pub(crate) struct RawRemoteCacheOptions {
     #[serde(skip_serializing_if = "Option::is_none")]
     timeout: Option<u64>,
     #[serde(skip_serializing_if = "Option::is_none")]
+    upload_timeout: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    download_timeout: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    connect_timeout: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    retry_attempts: Option<u32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    retry_backoff: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    retry_max_elapsed: Option<u64>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    failure_limit: Option<u32>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     enabled: Option<bool>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    read_only: Option<bool>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    write_only: Option<bool>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    provider: Option<RemoteCacheProvider>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    bucket: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    region: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    endpoint: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    prefix: Option<String>,
 }
 
 impl From<&RawRemoteCacheOptions> for ConfigurationOptions {
@@ -86,7 +185,21 @@ impl From<&RawRemoteCacheOptions> for ConfigurationOptions {
             signature: remote_cache_opts.signature,
             preflight: remote_cache_opts.preflight,
             timeout: remote_cache_opts.timeout,
+            upload_timeout: remote_cache_opts.upload_timeout,
+            download_timeout: remote_cache_opts.download_timeout,
+            connect_timeout: remote_cache_opts.connect_timeout,
+            retry_attempts: remote_cache_opts.retry_attempts,
+            retry_backoff: remote_cache_opts.retry_backoff,
+            retry_max_elapsed: remote_cache_opts.retry_max_elapsed,
+            failure_limit: remote_cache_opts.failure_limit,
             enabled: remote_cache_opts.enabled,
+            read_only: remote_cache_opts.read_only,
+            write_only: remote_cache_opts.write_only,
+            provider: remote_cache_opts.provider,
+            bucket: remote_cache_opts.bucket.clone(),
+            region: remote_cache_opts.region.clone(),
+            endpoint: remote_cache_opts.endpoint.clone(),
+            prefix: remote_cache_opts.prefix.clone(),
             ..Self::default()
         }
     }
@@ -94,6 +207,7 @@ impl From<&RawRemoteCacheOptions> for ConfigurationOptions {
 
 #[derive(Serialize, Default, Debug, Clone, Iterable, Deserializable)]
 #[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
 // The raw deserialized turbo.json file.
 pub struct RawTurboJson {
     #[serde(skip)]
@@ -109,10 +223,17 @@ pub struct RawTurboJson {
     // Global root filesystem dependencies
     #[serde(skip_serializing_if = "Option::is_none")]
     global_dependencies: Option<Vec<Spanned<UnescapedString>>>,
+    // Dotenv files, relative to the repository root, whose variables are
+    // included in the global hash
+    #[serde(skip_serializing_if = "Option::is_none")]
+    global_dot_env: Option<Vec<Spanned<UnescapedString>>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     global_env: Option<Vec<Spanned<UnescapedString>>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     global_pass_through_env: Option<Vec<Spanned<UnescapedString>>>,
+    // Deprecated name of `globalPassThroughEnv`
+    #[serde(skip_serializing)]
+    experimental_global_pass_through_env: Option<Vec<Spanned<UnescapedString>>>,
     // Tasks is a map of task entries which define the task graph
     // and cache behavior on a per task or per package-task basis.
     #[serde(skip_serializing_if = "Option::is_none")]
@@ -123,8 +244,24 @@ pub struct RawTurboJson {
     // Configuration options when interfacing with the remote cache
     #[serde(skip_serializing_if = "Option::is_none")]
     pub(crate) remote_cache: Option<RawRemoteCacheOptions>,
+    // The directory of the local cache, relative to the repository root
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub(crate) cache_dir: Option<String>,
+    // Configuration options for how artifacts are stored in the cache
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub(crate) cache_options: Option<RawCacheOptions>,
+    // Non-JavaScript workspaces whose members are added as packages
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub(crate) experimental_workspace_providers: Option<Vec<String>>,
     #[serde(skip_serializing_if = "Option::is_none", rename = "ui")]
-    pub ui: Option<UI>,
+    pub ui: Option<UIMode>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub hooks: Option<HooksJson>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub prune: Option<PruneJson>,
+    // Named sets of task options that can be layered on top of `tasks`
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub profiles: Option<Profiles>,
 
     #[deserializable(rename = "//")]
     #[serde(skip)]
@@ -135,6 +272,18 @@ pub struct RawTurboJson {
 #[serde(transparent)]
 pub struct Pipeline(BTreeMap<TaskName<'static>, Spanned<RawTaskDefinition>>);
 
+#[derive(Serialize, Default, Debug, PartialEq, Clone)]
+#[serde(transparent)]
+pub struct Profiles(BTreeMap<String, RawProfile>);
+
+#[derive(Serialize, Default, Debug, PartialEq, Clone, Deserializable)]
+#[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
+pub struct RawProfile {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub tasks: Option<Pipeline>,
+}
+
 impl IntoIterator for Pipeline {
     type Item = (TaskName<'static>, Spanned<RawTaskDefinition>);
     type IntoIter =
@@ -159,23 +308,12 @@ impl DerefMut for Pipeline {
     }
 }
 
-#[derive(Serialize, Debug, Copy, Clone, Deserializable, PartialEq, Eq)]
+#[derive(Serialize, Deserialize, Debug, Copy, Clone, Default, Deserializable, PartialEq, Eq)]
 #[serde(rename_all = "camelCase")]
-pub enum UI {
-    Tui,
-    Stream,
-}
-
-impl Default for UI {
-    fn default() -> Self {
-        Self::Tui
-    }
-}
-
-impl UI {
-    pub fn use_tui(&self) -> bool {
-        matches!(self, Self::Tui)
-    }
+pub enum RemoteCacheProvider {
+    #[default]
+    Vercel,
+    S3,
 }
 
 #[derive(Serialize, Default, Debug, PartialEq, Clone, Iterable, Deserializable)]
@@ -191,15 +329,88 @@ pub struct RawTaskDefinition {
     #[serde(skip_serializing_if = "Option::is_none")]
     inputs: Option<Vec<Spanned<UnescapedString>>>,
     #[serde(skip_serializing_if = "Option::is_none")]
+    input_transforms: Option<Vec<Spanned<UnescapedString>>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     pass_through_env: Option<Vec<Spanned<UnescapedString>>>,
+    // Deprecated name of `passThroughEnv`
+    #[serde(skip_serializing)]
+    experimental_pass_through_env: Option<Vec<Spanned<UnescapedString>>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     persistent: Option<Spanned<bool>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     outputs: Option<Vec<Spanned<UnescapedString>>>,
     #[serde(skip_serializing_if = "Option::is_none")]
+    local_only_outputs: Option<Vec<Spanned<UnescapedString>>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     output_logs: Option<Spanned<OutputLogsMode>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     interactive: Option<Spanned<bool>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    concurrency_group: Option<Spanned<UnescapedString>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    ready: Option<Spanned<RawReadyDefinition>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    tool_dependencies: Option<Vec<Spanned<UnescapedString>>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    dot_env: Option<Vec<Spanned<UnescapedString>>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    inject_dot_env: Option<Spanned<bool>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    weight: Option<Spanned<u32>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    timeout: Option<Spanned<UnescapedString>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    runner: Option<Spanned<RawRunner>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    shell: Option<Spanned<RawShell>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    follow_symlinks: Option<Spanned<bool>>,
+    // Overrides `cacheOptions.compression` for the task's artifacts
+    #[serde(skip_serializing_if = "Option::is_none")]
+    compression: Option<Spanned<UnescapedString>>,
+    // Replaces the inherited definition of the task in a package's turbo.json
+    // instead of being merged into it
+    #[serde(skip_serializing_if = "Option::is_none", rename = "override")]
+    #[deserializable(rename = "override")]
+    r#override: Option<Spanned<bool>>,
+}
+
+// How a persistent task signals that the tasks depending on it can start.
+// Exactly one of the fields has to be set.
+#[derive(Serialize, Default, Debug, PartialEq, Clone, Deserializable)]
+#[serde(rename_all = "camelCase")]
+#[deserializable(unknown_fields = "deny")]
+pub struct RawReadyDefinition {
+    #[serde(skip_serializing_if = "Option::is_none")]
+    port: Option<u16>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    log_pattern: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    command: Option<String>,
+}
+
+// A command that's run with the task's shell, or a program and its arguments
+// that are run without one
+#[derive(Serialize, Debug, PartialEq, Clone)]
+#[serde(untagged)]
+pub enum RawRunner {
+    Command(UnescapedString),
+    Args(Vec<UnescapedString>),
+}
+
+// `false` runs the task's command without a shell, and `true` with the
+// platform's default one. The shell for each platform can also be set, e.g.
+// `{ "windows": "pwsh" }`, and the default is used on the other platforms.
+#[derive(Serialize, Debug, PartialEq, Clone)]
+#[serde(untagged)]
+pub enum RawShell {
+    Enabled(bool),
+    Platforms {
+        #[serde(skip_serializing_if = "Option::is_none")]
+        unix: Option<UnescapedString>,
+        #[serde(skip_serializing_if = "Option::is_none")]
+        windows: Option<UnescapedString>,
+    },
 }
 
 macro_rules! set_field {
@@ -211,10 +422,19 @@ macro_rules! set_field {
 }
 
 impl RawTaskDefinition {
+    /// Returns the `override` key if the definition replaces the inherited
+    /// one
+    pub(crate) fn override_key(&self) -> Option<&Spanned<bool>> {
+        self.r#override
+            .as_ref()
+            .filter(|r#override| r#override.value)
+    }
+
     // merge accepts a RawTaskDefinition and
     // merges it into RawTaskDefinition.
     pub fn merge(&mut self, other: RawTaskDefinition) {
         set_field!(self, other, outputs);
+        set_field!(self, other, local_only_outputs);
 
         let other_has_range = other.cache.as_ref().map_or(false, |c| c.range.is_some());
         let self_does_not_have_range = self.cache.as_ref().map_or(false, |c| c.range.is_none());
@@ -227,65 +447,97 @@ impl RawTaskDefinition {
         }
         set_field!(self, other, depends_on);
         set_field!(self, other, inputs);
+        set_field!(self, other, input_transforms);
         set_field!(self, other, output_logs);
         set_field!(self, other, persistent);
         set_field!(self, other, env);
         set_field!(self, other, pass_through_env);
+        set_field!(self, other, experimental_pass_through_env);
         set_field!(self, other, interactive);
+        set_field!(self, other, concurrency_group);
+        set_field!(self, other, ready);
+        set_field!(self, other, tool_dependencies);
+        set_field!(self, other, dot_env);
+        set_field!(self, other, inject_dot_env);
+        set_field!(self, other, weight);
+        set_field!(self, other, timeout);
+        set_field!(self, other, runner);
+        set_field!(self, other, shell);
+        set_field!(self, other, follow_symlinks);
+        set_field!(self, other, compression);
+    }
+}
+
+// Deprecated keys are still read as the key that replaced them, unless that
+// one is set as well
+fn deprecated_key<T>(value: Option<T>, key: &str, replacement: &str) -> Option<T> {
+    if value.is_some() {
+        warn!("`{key}` in turbo.json is deprecated, use `{replacement}` instead");
     }
+    value
 }
 
-const CONFIG_FILE: &str = "turbo.json";
+pub(crate) const CONFIG_FILE: &str = "turbo.json";
 const ENV_PIPELINE_DELIMITER: &str = "$";
 const TOPOLOGICAL_PIPELINE_DELIMITER: &str = "^";
 
 impl TryFrom<Vec<Spanned<UnescapedString>>> for TaskOutputs {
     type Error = Error;
     fn try_from(outputs: Vec<Spanned<UnescapedString>>) -> Result<Self, Self::Error> {
-        let mut inclusions = Vec::new();
-        let mut exclusions = Vec::new();
-
-        for glob in outputs {
-            if let Some(stripped_glob) = glob.value.strip_prefix('!') {
-                if Utf8Path::new(stripped_glob).is_absolute() {
-                    let (span, text) = glob.span_and_text("turbo.json");
-                    return Err(Error::AbsolutePathInConfig {
-                        field: "outputs",
-                        span,
-                        text,
-                    });
-                }
+        task_outputs(outputs, "outputs")
+    }
+}
 
-                exclusions.push(stripped_glob.to_string());
-            } else {
-                if Utf8Path::new(&glob.value).is_absolute() {
-                    let (span, text) = glob.span_and_text("turbo.json");
-                    return Err(Error::AbsolutePathInConfig {
-                        field: "outputs",
-                        span,
-                        text,
-                    });
-                }
+fn task_outputs(
+    outputs: Vec<Spanned<UnescapedString>>,
+    field: &'static str,
+) -> Result<TaskOutputs, Error> {
+    let mut inclusions = Vec::new();
+    let mut exclusions = Vec::new();
+
+    for glob in outputs {
+        if let Some(stripped_glob) = glob.value.strip_prefix('!') {
+            if Utf8Path::new(stripped_glob).is_absolute() {
+                let (span, text) = glob.span_and_text("turbo.json");
+                return Err(Error::AbsolutePathInConfig { field, span, text });
+            }
 
-                inclusions.push(glob.into_inner().into());
+            exclusions.push(stripped_glob.to_string());
+        } else {
+            if Utf8Path::new(&glob.value).is_absolute() {
+                let (span, text) = glob.span_and_text("turbo.json");
+                return Err(Error::AbsolutePathInConfig { field, span, text });
             }
+
+            inclusions.push(glob.into_inner().into());
         }
+    }
 
-        inclusions.sort();
-        exclusions.sort();
+    inclusions.sort();
+    exclusions.sort();
 
-        Ok(TaskOutputs {
-            inclusions,
-            exclusions,
-        })
-    }
+    Ok(TaskOutputs {
+        inclusions,
+        exclusions,
+    })
 }
 
 impl TryFrom<RawTaskDefinition> for TaskDefinition {
     type Error = Error;
 
     fn try_from(raw_task: RawTaskDefinition) -> Result<Self, Error> {
-        let outputs = raw_task.outputs.unwrap_or_default().try_into()?;
+        let mut outputs: TaskOutputs = raw_task.outputs.unwrap_or_default().try_into()?;
+        let local_only_outputs = task_outputs(
+            raw_task.local_only_outputs.unwrap_or_default(),
+            "localOnlyOutputs",
+        )?;
+        // Local only outputs are still cached, so they're added to the outputs
+        for inclusion in &local_only_outputs.inclusions {
+            if !outputs.inclusions.contains(inclusion) {
+                outputs.inclusions.push(inclusion.clone());
+            }
+        }
+        outputs.inclusions.sort();
 
         let cache = raw_task.cache.map_or(true, |c| c.into_inner());
         let interactive = raw_task
@@ -301,6 +553,41 @@ impl TryFrom<RawTaskDefinition> for TaskDefinition {
             }
         }
 
+        let persistent = *raw_task.persistent.unwrap_or_default();
+        let concurrency_group = match raw_task.concurrency_group {
+            // A persistent task never exits, so it would hold the group forever
+            Some(group) if persistent => {
+                let (span, text) = group.span_and_text("turbo.json");
+                return Err(Error::PersistentConcurrencyGroup { span, text });
+            }
+            Some(group) => Some(String::from(group.into_inner())),
+            None => None,
+        };
+
+        let ready = raw_task
+            .ready
+            .map(|ready| {
+                let (span, text) = ready.span_and_text("turbo.json");
+                if !persistent {
+                    return Err(Error::ReadyWithoutPersistent { span, text });
+                }
+                let RawReadyDefinition {
+                    port,
+                    log_pattern,
+                    command,
+                } = ready.into_inner();
+                match (port, log_pattern, command) {
+                    (Some(port), None, None) => Ok(ReadyProbe::Port(port)),
+                    (None, Some(pattern), None) => match Regex::new(&pattern) {
+                        Ok(_) => Ok(ReadyProbe::LogPattern(pattern)),
+                        Err(err) => Err(Error::InvalidReadyPattern { err, span, text }),
+                    },
+                    (None, None, Some(command)) => Ok(ReadyProbe::Command(command)),
+                    _ => Err(Error::InvalidReadyDefinition { span, text }),
+                }
+            })
+            .transpose()?;
+
         let mut env_var_dependencies = HashSet::new();
         let mut topological_dependencies: Vec<Spanned<TaskName>> = Vec::new();
         let mut task_dependencies: Vec<Spanned<TaskName>> = Vec::new();
@@ -352,14 +639,160 @@ impl TryFrom<RawTaskDefinition> for TaskDefinition {
                         span,
                         text,
                     })
+                } else if input.value.contains("$TURBO_ROOT$")
+                    && !input
+                        .value
+                        .trim_start_matches('!')
+                        .starts_with(INPUT_TURBO_ROOT)
+                {
+                    let (span, text) = input.span_and_text("turbo.json");
+                    Err(Error::InvalidTurboRootUse { span, text })
                 } else {
                     Ok(input.to_string())
                 }
             })
             .collect::<Result<Vec<_>, _>>()?;
 
+        let input_transforms = raw_task
+            .input_transforms
+            .unwrap_or_default()
+            .into_iter()
+            .map(|transform| {
+                InputTransform::from_str(&transform.value).map_err(|err| {
+                    let (span, text) = transform.span_and_text("turbo.json");
+                    Error::UnknownInputTransform {
+                        name: err.name,
+                        valid: InputTransform::names().collect::<Vec<_>>().join(", "),
+                        span,
+                        text,
+                    }
+                })
+            })
+            .collect::<Result<Vec<_>, _>>()?;
+
+        let tool_dependencies = raw_task
+            .tool_dependencies
+            .unwrap_or_default()
+            .into_iter()
+            .map(|command| {
+                if command.value.trim().is_empty() {
+                    let (span, text) = command.span_and_text("turbo.json");
+                    Err(Error::EmptyToolDependency { span, text })
+                } else {
+                    Ok(command.value.to_string())
+                }
+            })
+            .collect::<Result<Vec<_>, _>>()?;
+
+        let dot_env = raw_task
+            .dot_env
+            .unwrap_or_default()
+            .into_iter()
+            .map(|file| {
+                if Utf8Path::new(&file.value).is_absolute() {
+                    let (span, text) = file.span_and_text("turbo.json");
+                    Err(Error::AbsolutePathInConfig {
+                        field: "dotEnv",
+                        span,
+                        text,
+                    })
+                } else if file.value.contains("$TURBO_ROOT$")
+                    && !file.value.starts_with(INPUT_TURBO_ROOT)
+                {
+                    let (span, text) = file.span_and_text("turbo.json");
+                    Err(Error::InvalidTurboRootUse { span, text })
+                } else {
+                    Ok(file.value.to_string())
+                }
+            })
+            .collect::<Result<Vec<_>, _>>()?;
+        let inject_dot_env = raw_task.inject_dot_env.is_some_and(|inject| inject.value);
+
+        let weight = match raw_task.weight {
+            Some(weight) if weight.value == 0 => {
+                let (span, text) = weight.span_and_text("turbo.json");
+                return Err(Error::ZeroTaskWeight { span, text });
+            }
+            Some(weight) => weight.into_inner(),
+            None => 1,
+        };
+
+        let timeout = raw_task
+            .timeout
+            .map(|timeout| {
+                let (span, text) = timeout.span_and_text("turbo.json");
+                match humantime::parse_duration(timeout.as_inner()) {
+                    Ok(timeout) if timeout.is_zero() => Err(Error::InvalidTaskTimeout {
+                        reason: "timeout must be greater than 0".to_string(),
+                        span,
+                        text,
+                    }),
+                    Ok(timeout) => Ok(timeout),
+                    Err(err) => Err(Error::InvalidTaskTimeout {
+                        reason: err.to_string(),
+                        span,
+                        text,
+                    }),
+                }
+            })
+            .transpose()?;
+
+        let runner = raw_task
+            .runner
+            .map(|runner| {
+                let (span, text) = runner.span_and_text("turbo.json");
+                match runner.into_inner() {
+                    RawRunner::Command(command) if !command.trim().is_empty() => {
+                        Ok(Runner::Command(command.into()))
+                    }
+                    RawRunner::Args(args)
+                        if args.first().is_some_and(|program| !program.is_empty()) =>
+                    {
+                        Ok(Runner::Args(args.into_iter().map(String::from).collect()))
+                    }
+                    _ => Err(Error::EmptyTaskRunner { span, text }),
+                }
+            })
+            .transpose()?;
+
+        let shell = match raw_task.shell {
+            None => TaskShell::Default,
+            Some(shell) => {
+                let (span, text) = shell.span_and_text("turbo.json");
+                match shell.into_inner() {
+                    RawShell::Enabled(true) => TaskShell::Default,
+                    RawShell::Enabled(false) => TaskShell::Exec,
+                    RawShell::Platforms { unix, windows } => {
+                        let shell = if cfg!(windows) { windows } else { unix };
+                        match shell {
+                            None => TaskShell::Default,
+                            Some(shell) => TaskShell::custom(&shell)
+                                .ok_or(Error::InvalidTaskShell { span, text })?,
+                        }
+                    }
+                }
+            }
+        };
+
+        let follow_symlinks = raw_task.follow_symlinks.is_some_and(|follow| follow.value);
+
+        let compression_level = raw_task
+            .compression
+            .map(|compression| {
+                config::compression_level(compression.as_inner()).ok_or_else(|| {
+                    let (span, text) = compression.span_and_text("turbo.json");
+                    Error::InvalidTaskCompression { span, text }
+                })
+            })
+            .transpose()?;
+
         let pass_through_env = raw_task
             .pass_through_env
+            .or(deprecated_key(
+                raw_task.experimental_pass_through_env,
+                "experimentalPassThroughEnv",
+                "passThroughEnv",
+            ))
             .map(|env| -> Result<Vec<String>, Error> {
                 let mut pass_through_env = HashSet::new();
                 gather_env_vars(env, "passThroughEnv", &mut pass_through_env)?;
@@ -371,15 +804,28 @@ impl TryFrom<RawTaskDefinition> for TaskDefinition {
 
         Ok(TaskDefinition {
             outputs,
+            local_only_outputs,
             cache,
             topological_dependencies,
             task_dependencies,
             env,
             inputs,
+            input_transforms,
             pass_through_env,
             output_logs: *raw_task.output_logs.unwrap_or_default(),
-            persistent: *raw_task.persistent.unwrap_or_default(),
+            persistent,
             interactive,
+            concurrency_group,
+            ready,
+            tool_dependencies,
+            dot_env,
+            inject_dot_env,
+            weight,
+            timeout,
+            runner,
+            shell,
+            follow_symlinks,
+            compression_level,
         })
     }
 }
@@ -460,6 +906,7 @@ impl TryFrom<RawTurboJson> for TurboJson {
         }
         let mut global_env = HashSet::new();
         let mut global_file_dependencies = HashSet::new();
+        let mut global_dot_env = Vec::new();
 
         if let Some(global_env_from_turbo) = raw_turbo.global_env {
             gather_env_vars(global_env_from_turbo, "globalEnv", &mut global_env)?;
@@ -485,6 +932,19 @@ impl TryFrom<RawTurboJson> for TurboJson {
             }
         }
 
+        for file in raw_turbo.global_dot_env.into_iter().flatten() {
+            if Utf8Path::new(&file.value).is_absolute() {
+                let (span, text) = file.span_and_text("turbo.json");
+                return Err(Error::AbsolutePathInConfig {
+                    field: "globalDotEnv",
+                    span,
+                    text,
+                });
+            }
+            // Order is kept as earlier files take precedence
+            global_dot_env.push(file.into_inner().into());
+        }
+
         Ok(TurboJson {
             text: raw_turbo.span.text,
             path: raw_turbo.span.path,
@@ -495,6 +955,11 @@ impl TryFrom<RawTurboJson> for TurboJson {
             },
             global_pass_through_env: raw_turbo
                 .global_pass_through_env
+                .or(deprecated_key(
+                    raw_turbo.experimental_global_pass_through_env,
+                    "experimentalGlobalPassThroughEnv",
+                    "globalPassThroughEnv",
+                ))
                 .map(|env| -> Result<Vec<String>, Error> {
                     let mut global_pass_through_env = HashSet::new();
                     gather_env_vars(env, "globalPassThroughEnv", &mut global_pass_through_env)?;
@@ -510,12 +975,33 @@ impl TryFrom<RawTurboJson> for TurboJson {
 
                 global_deps
             },
+            global_dot_env,
             tasks: raw_turbo.tasks.unwrap_or_default(),
+            hooks: raw_turbo.hooks.unwrap_or_default(),
             // copy these over, we don't need any changes here.
             extends: raw_turbo
                 .extends
                 .unwrap_or_default()
                 .map(|s| s.into_iter().map(|s| s.into()).collect()),
+            // Shareable configs are loaded separately as they need to be resolved
+            extended: Vec::new(),
+            profiles: raw_turbo
+                .profiles
+                .map(|profiles| {
+                    profiles
+                        .0
+                        .into_iter()
+                        .map(|(name, profile)| (name, profile.tasks.unwrap_or_default()))
+                        .collect()
+                })
+                .unwrap_or_default(),
+            profile_tasks: Pipeline::default(),
+            workspace_providers: raw_turbo
+                .experimental_workspace_providers
+                .into_iter()
+                .flatten()
+                .map(|provider| provider.parse::<WorkspaceProvider>())
+                .collect::<Result<_, _>>()?,
             // Spaces and Remote Cache config is handled through layered config
         })
     }
@@ -601,6 +1087,64 @@ impl TurboJson {
         Ok(turbo_json)
     }
 
+    /// Loads the turbo.json of the package in `dir` along with the shareable
+    /// configs it extends, or returns `None` if the package doesn't have one
+    pub(crate) fn load_package(
+        repo_root: &AbsoluteSystemPath,
+        dir: &AnchoredSystemPath,
+        package_json: &PackageJson,
+    ) -> Result<Option<TurboJson>, Error> {
+        let mut turbo_json = match Self::load(repo_root, dir, package_json, false) {
+            Ok(turbo_json) => turbo_json,
+            Err(Error::NoTurboJSON) => return Ok(None),
+            Err(err) => return Err(err),
+        };
+        turbo_json.load_extended(repo_root, dir)?;
+        Ok(Some(turbo_json))
+    }
+
+    /// Loads the shareable configs listed in `extends` from the packages that
+    /// publish them. Packages are resolved by looking for them in the
+    /// `node_modules` of `dir` and each of its parent directories, the same way
+    /// Node resolves dependencies.
+    pub(crate) fn load_extended(
+        &mut self,
+        repo_root: &AbsoluteSystemPath,
+        dir: &AnchoredSystemPath,
+    ) -> Result<(), Error> {
+        let mut extended = Vec::new();
+        for package_name in self.extends.iter().filter(|name| *name != ROOT_PKG_NAME) {
+            let Some(config_path) = resolve_extended_config(repo_root, dir, package_name) else {
+                let (span, text) = self.extends.span_and_text("turbo.json");
+                return Err(Error::ExtendedConfigNotFound {
+                    package_name: package_name.clone(),
+                    span,
+                    text,
+                });
+            };
+            debug!("loading {package_name} config from {config_path}");
+            let config = TurboJson::read(repo_root, &config_path)?;
+            if !config.extends.is_empty() {
+                let (span, text) = config.extends.span_and_text("turbo.json");
+                return Err(Error::ExtendFromSharedConfig { span, text });
+            }
+            extended.push(config);
+        }
+        self.extended = extended;
+        Ok(())
+    }
+
+    /// Returns the definitions of `task_name` from the shareable configs this
+    /// config extends, in merge order
+    pub(crate) fn extended_tasks<'a>(
+        &'a self,
+        task_name: &'a TaskName<'a>,
+    ) -> impl Iterator<Item = &'a Spanned<RawTaskDefinition>> + 'a {
+        self.extended
+            .iter()
+            .filter_map(move |config| config.tasks.get(task_name))
+    }
+
     fn has_task(&self, task_name: &TaskName) -> bool {
         for key in self.tasks.keys() {
             if key == task_name || (key.task() == task_name.task() && !task_name.is_package_task())
@@ -629,6 +1173,31 @@ impl TurboJson {
         }
     }
 
+    /// Selects the profile whose task definitions are layered on top of the
+    /// definitions from `tasks` and package configurations
+    pub fn select_profile(&mut self, name: &str) -> Result<(), Error> {
+        let profile = self
+            .profiles
+            .get(name)
+            .ok_or_else(|| Error::MissingProfile {
+                name: name.to_string(),
+            })?;
+        self.profile_tasks = profile.clone();
+        Ok(())
+    }
+
+    /// Returns the definition of the task from the selected profile
+    pub fn profile_task(
+        &self,
+        task_id: &TaskId,
+        task_name: &TaskName,
+    ) -> Option<RawTaskDefinition> {
+        self.profile_tasks
+            .get(&task_id.as_task_name())
+            .or_else(|| self.profile_tasks.get(task_name))
+            .map(|entry| entry.value.clone())
+    }
+
     pub fn validate(&self, validations: &[TurboJSONValidation]) -> Vec<Error> {
         validations
             .iter()
@@ -662,9 +1231,27 @@ pub fn validate_no_package_task_syntax(turbo_json: &TurboJson) -> Vec<Error> {
         .collect()
 }
 
+pub fn validate_no_override(turbo_json: &TurboJson) -> Vec<Error> {
+    turbo_json
+        .tasks
+        .values()
+        .filter_map(|entry| entry.value.r#override.as_ref())
+        .map(|r#override| {
+            let (span, text) = r#override.span_and_text("turbo.json");
+            Error::OverrideInRootTurboJson { span, text }
+        })
+        .collect()
+}
+
 pub fn validate_extends(turbo_json: &TurboJson) -> Vec<Error> {
     match turbo_json.extends.first() {
-        Some(package_name) if package_name != ROOT_PKG_NAME || turbo_json.extends.len() > 1 => {
+        // The root workspace must come first and can't be extended again by shareable configs
+        Some(package_name)
+            if package_name != ROOT_PKG_NAME
+                || turbo_json.extends[1..]
+                    .iter()
+                    .any(|name| name == ROOT_PKG_NAME) =>
+        {
             let (span, text) = turbo_json.extends.span_and_text("turbo.json");
             vec![Error::ExtendFromNonRoot { span, text }]
         }
@@ -692,6 +1279,20 @@ pub fn validate_extends(turbo_json: &TurboJson) -> Vec<Error> {
     }
 }
 
+fn resolve_extended_config(
+    repo_root: &AbsoluteSystemPath,
+    dir: &AnchoredSystemPath,
+    package_name: &str,
+) -> Option<AnchoredSystemPathBuf> {
+    let mut components = vec!["node_modules"];
+    components.extend(package_name.split('/'));
+    components.push(CONFIG_FILE);
+
+    dir.ancestors()
+        .map(|ancestor| ancestor.join_components(&components))
+        .find(|config_path| repo_root.resolve(config_path).exists())
+}
+
 fn gather_env_vars(
     vars: Vec<Spanned<impl Into<String>>>,
     key: &str,
@@ -724,7 +1325,7 @@ fn gather_env_vars(
 
 #[cfg(test)]
 mod tests {
-    use std::fs;
+    use std::{fs, time::Duration};
 
     use anyhow::Result;
     use biome_deserialize::json::deserialize_from_json_str;
@@ -734,14 +1335,15 @@ mod tests {
     use tempfile::tempdir;
     use test_case::test_case;
     use turbopath::{AbsoluteSystemPath, AnchoredSystemPath};
-    use turborepo_repository::package_json::PackageJson;
+    use turborepo_repository::{package_graph::WorkspaceProvider, package_json::PackageJson};
 
-    use super::{Pipeline, RawTurboJson, Spanned, UI};
+    use super::{Pipeline, RawRunner, RawShell, RawTurboJson, Spanned};
     use crate::{
-        cli::OutputLogsMode,
-        run::task_id::TaskName,
-        task_graph::{TaskDefinition, TaskOutputs},
-        turbo_json::{RawTaskDefinition, TurboJson},
+        cli::{OutputLogsMode, UIMode},
+        config::Error,
+        run::task_id::{TaskId, TaskName},
+        task_graph::{ReadyProbe, Runner, TaskDefinition, TaskOutputs, TaskShell},
+        turbo_json::{HooksJson, RawTaskDefinition, TurboJson},
         unescape::UnescapedString,
     };
 
@@ -758,6 +1360,21 @@ mod tests {
             ..TurboJson::default()
         }
     )]
+    #[test_case(r#"{ "hooks": { "postTask": "./scripts/report.sh" } }"#,
+        TurboJson {
+            hooks: HooksJson {
+                post_task: Some("./scripts/report.sh".to_string()),
+                ..HooksJson::default()
+            },
+            ..TurboJson::default()
+        }
+    ; "hooks")]
+    #[test_case(r#"{ "experimentalWorkspaceProviders": ["go", "cargo"] }"#,
+        TurboJson {
+            workspace_providers: vec![WorkspaceProvider::Go, WorkspaceProvider::Cargo],
+            ..TurboJson::default()
+        }
+    ; "workspace providers")]
     #[test_case(r#"{ "//": "A comment"}"#, TurboJson::default() ; "faux comment")]
     #[test_case(r#"{ "//": "A comment", "//": "Another comment" }"#, TurboJson::default() ; "two faux comments")]
     fn test_get_root_turbo_no_synthesizing(
@@ -896,6 +1513,20 @@ mod tests {
             output_logs: Some(Spanned::new(OutputLogsMode::Full).with_range(246..252)),
             persistent: Some(Spanned::new(true).with_range(278..282)),
             interactive: Some(Spanned::new(true).with_range(309..313)),
+            concurrency_group: None,
+            local_only_outputs: None,
+            ready: None,
+            input_transforms: None,
+            tool_dependencies: None,
+            dot_env: None,
+            inject_dot_env: None,
+            weight: None,
+            timeout: None,
+            runner: None,
+            shell: None,
+            follow_symlinks: None,
+            compression: None,
+            r#override: None,
         },
         TaskDefinition {
           env: vec!["OS".to_string()],
@@ -911,6 +1542,19 @@ mod tests {
           topological_dependencies: vec![],
           persistent: true,
           interactive: true,
+          concurrency_group: None,
+          local_only_outputs: TaskOutputs::default(),
+          ready: None,
+          input_transforms: vec![],
+          tool_dependencies: vec![],
+          dot_env: vec![],
+          inject_dot_env: false,
+          weight: 1,
+          timeout: None,
+          runner: None,
+          shell: TaskShell::Default,
+          follow_symlinks: false,
+          compression_level: None,
         }
       ; "full"
     )]
@@ -935,6 +1579,20 @@ mod tests {
             output_logs: Some(Spanned::new(OutputLogsMode::Full).with_range(279..285)),
             persistent: Some(Spanned::new(true).with_range(315..319)),
             interactive: None,
+            concurrency_group: None,
+            local_only_outputs: None,
+            ready: None,
+            input_transforms: None,
+            tool_dependencies: None,
+            dot_env: None,
+            inject_dot_env: None,
+            weight: None,
+            timeout: None,
+            runner: None,
+            shell: None,
+            follow_symlinks: None,
+            compression: None,
+            r#override: None,
         },
         TaskDefinition {
             env: vec!["OS".to_string()],
@@ -950,9 +1608,211 @@ mod tests {
             topological_dependencies: vec![],
             persistent: true,
             interactive: false,
+            concurrency_group: None,
+            local_only_outputs: TaskOutputs::default(),
+            ready: None,
+            input_transforms: vec![],
+            tool_dependencies: vec![],
+            dot_env: vec![],
+            inject_dot_env: false,
+            weight: 1,
+            timeout: None,
+            runner: None,
+            shell: TaskShell::Default,
+            follow_symlinks: false,
+          compression_level: None,
         }
       ; "full (windows)"
     )]
+    #[test_case(
+        r#"{ "concurrencyGroup": "db" }"#,
+        RawTaskDefinition {
+            concurrency_group: Some(Spanned::<UnescapedString>::new("db".into()).with_range(22..26)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            concurrency_group: Some("db".to_string()),
+            ..TaskDefinition::default()
+        }
+      ; "concurrency group"
+    )]
+    #[test_case(
+        r#"{ "inputTransforms": ["stripLineComments:ts"] }"#,
+        RawTaskDefinition {
+            input_transforms: Some(vec![Spanned::<UnescapedString>::new("stripLineComments:ts".into()).with_range(22..44)]),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            input_transforms: vec![InputTransform::from_str("stripLineComments:ts").unwrap()],
+            ..TaskDefinition::default()
+        }
+      ; "input transforms"
+    )]
+    #[test_case(
+        r#"{ "toolDependencies": ["node --version"] }"#,
+        RawTaskDefinition {
+            tool_dependencies: Some(vec![Spanned::<UnescapedString>::new("node --version".into()).with_range(23..39)]),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            tool_dependencies: vec!["node --version".to_string()],
+            ..TaskDefinition::default()
+        }
+      ; "tool dependencies"
+    )]
+    #[test_case(
+        r#"{ "dotEnv": [".env", "$TURBO_ROOT$/.env"], "injectDotEnv": true }"#,
+        RawTaskDefinition {
+            dot_env: Some(vec![
+                Spanned::<UnescapedString>::new(".env".into()).with_range(13..19),
+                Spanned::<UnescapedString>::new("$TURBO_ROOT$/.env".into()).with_range(21..40),
+            ]),
+            inject_dot_env: Some(Spanned::new(true).with_range(59..63)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            dot_env: vec![".env".to_string(), "$TURBO_ROOT$/.env".to_string()],
+            inject_dot_env: true,
+            ..TaskDefinition::default()
+        }
+      ; "dot env"
+    )]
+    #[test_case(
+        r#"{ "followSymlinks": true }"#,
+        RawTaskDefinition {
+            follow_symlinks: Some(Spanned::new(true).with_range(20..24)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            follow_symlinks: true,
+            ..TaskDefinition::default()
+        }
+      ; "follow symlinks"
+    )]
+    #[test_case(
+        r#"{ "compression": "zstd:19" }"#,
+        RawTaskDefinition {
+            compression: Some(Spanned::<UnescapedString>::new("zstd:19".into()).with_range(17..26)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            compression_level: Some(19),
+            ..TaskDefinition::default()
+        }
+      ; "compression"
+    )]
+    #[test_case(
+        r#"{ "localOnlyOutputs": ["dist/**/*.map"] }"#,
+        RawTaskDefinition {
+            local_only_outputs: Some(vec![Spanned::<UnescapedString>::new("dist/**/*.map".into()).with_range(23..38)]),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            outputs: TaskOutputs {
+                inclusions: vec!["dist/**/*.map".to_string()],
+                exclusions: vec![],
+            },
+            local_only_outputs: TaskOutputs {
+                inclusions: vec!["dist/**/*.map".to_string()],
+                exclusions: vec![],
+            },
+            ..TaskDefinition::default()
+        }
+      ; "local only outputs"
+    )]
+    #[test_case(
+        r#"{ "weight": 4 }"#,
+        RawTaskDefinition {
+            weight: Some(Spanned::new(4).with_range(12..13)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            weight: 4,
+            ..TaskDefinition::default()
+        }
+      ; "weight"
+    )]
+    #[test_case(
+        r#"{ "timeout": "1h 30m" }"#,
+        RawTaskDefinition {
+            timeout: Some(Spanned::<UnescapedString>::new("1h 30m".into()).with_range(13..21)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            timeout: Some(Duration::from_secs(90 * 60)),
+            ..TaskDefinition::default()
+        }
+      ; "timeout"
+    )]
+    #[test_case(
+        r#"{ "runner": "bun run" }"#,
+        RawTaskDefinition {
+            runner: Some(Spanned::new(RawRunner::Command("bun run".into())).with_range(12..21)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            runner: Some(Runner::Command("bun run".to_string())),
+            ..TaskDefinition::default()
+        }
+      ; "runner"
+    )]
+    #[test_case(
+        r#"{ "runner": ["deno", "task", "{task}"] }"#,
+        RawTaskDefinition {
+            runner: Some(
+                Spanned::new(RawRunner::Args(vec![
+                    "deno".into(),
+                    "task".into(),
+                    "{task}".into()
+                ]))
+                .with_range(12..38)
+            ),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            runner: Some(Runner::Args(vec![
+                "deno".to_string(),
+                "task".to_string(),
+                "{task}".to_string()
+            ])),
+            ..TaskDefinition::default()
+        }
+      ; "runner arguments"
+    )]
+    #[test_case(
+        r#"{ "shell": false }"#,
+        RawTaskDefinition {
+            shell: Some(Spanned::new(RawShell::Enabled(false)).with_range(11..16)),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            shell: TaskShell::Exec,
+            ..TaskDefinition::default()
+        }
+      ; "shell disabled"
+    )]
+    #[test_case(
+        r#"{ "shell": { "unix": "bash -e", "windows": "pwsh" } }"#,
+        RawTaskDefinition {
+            shell: Some(
+                Spanned::new(RawShell::Platforms {
+                    unix: Some("bash -e".into()),
+                    windows: Some("pwsh".into()),
+                })
+                .with_range(11..51)
+            ),
+            ..RawTaskDefinition::default()
+        },
+        TaskDefinition {
+            shell: if cfg!(windows) {
+                TaskShell::Custom(vec!["pwsh".to_string()])
+            } else {
+                TaskShell::Custom(vec!["bash".to_string(), "-e".to_string()])
+            },
+            ..TaskDefinition::default()
+        }
+      ; "shell per platform"
+    )]
     fn test_deserialize_task_definition(
         task_definition_content: &str,
         expected_raw_task_definition: RawTaskDefinition,
@@ -973,6 +1833,108 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_persistent_task_in_concurrency_group() {
+        let raw_task_definition: RawTaskDefinition = deserialize_from_json_str(
+            r#"{ "persistent": true, "concurrencyGroup": "db" }"#,
+            JsonParserOptions::default(),
+            "turbo.json",
+        )
+        .into_deserialized()
+        .unwrap();
+
+        let result = TaskDefinition::try_from(raw_task_definition);
+        assert!(matches!(
+            result,
+            Err(Error::PersistentConcurrencyGroup { .. })
+        ));
+    }
+
+    #[test_case(r#"{ "persistent": true, "ready": { "port": 3000 } }"#, ReadyProbe::Port(3000) ; "port")]
+    #[test_case(r#"{ "persistent": true, "ready": { "logPattern": "ready on \\d+" } }"#, ReadyProbe::LogPattern(r"ready on \d+".to_string()) ; "log pattern")]
+    #[test_case(r#"{ "persistent": true, "ready": { "command": "curl -sf localhost:3000" } }"#, ReadyProbe::Command("curl -sf localhost:3000".to_string()) ; "command")]
+    fn test_ready_probe(task_definition_content: &str, expected: ReadyProbe) {
+        let raw_task_definition: RawTaskDefinition = deserialize_from_json_str(
+            task_definition_content,
+            JsonParserOptions::default(),
+            "turbo.json",
+        )
+        .into_deserialized()
+        .unwrap();
+
+        let task_definition = TaskDefinition::try_from(raw_task_definition).unwrap();
+        assert_eq!(task_definition.ready, Some(expected));
+    }
+
+    #[test_case(r#"{ "ready": { "port": 3000 } }"# ; "not persistent")]
+    #[test_case(r#"{ "persistent": true, "ready": {} }"# ; "no probe")]
+    #[test_case(r#"{ "persistent": true, "ready": { "port": 3000, "command": "true" } }"# ; "two probes")]
+    #[test_case(r#"{ "persistent": true, "ready": { "logPattern": "(" } }"# ; "invalid pattern")]
+    fn test_invalid_ready_probe(task_definition_content: &str) {
+        let raw_task_definition: RawTaskDefinition = deserialize_from_json_str(
+            task_definition_content,
+            JsonParserOptions::default(),
+            "turbo.json",
+        )
+        .into_deserialized()
+        .unwrap();
+
+        let result = TaskDefinition::try_from(raw_task_definition);
+        assert!(matches!(
+            result,
+            Err(Error::ReadyWithoutPersistent { .. }
+                | Error::InvalidReadyDefinition { .. }
+                | Error::InvalidReadyPattern { .. })
+        ));
+    }
+
+    #[test]
+    fn test_unknown_input_transform() {
+        let raw_task_definition: RawTaskDefinition = deserialize_from_json_str(
+            r#"{ "inputTransforms": ["stripBlockComments"] }"#,
+            JsonParserOptions::default(),
+            "turbo.json",
+        )
+        .into_deserialized()
+        .unwrap();
+
+        let result = TaskDefinition::try_from(raw_task_definition);
+        assert!(matches!(
+            result,
+            Err(Error::UnknownInputTransform { name, .. }) if name == "stripBlockComments"
+        ));
+    }
+
+    #[test_case("gzip" ; "other codec")]
+    #[test_case("zstd:23" ; "level too high")]
+    fn test_invalid_task_compression(compression: &str) {
+        let raw_task_definition: RawTaskDefinition = deserialize_from_json_str(
+            &format!(r#"{{ "compression": "{compression}" }}"#),
+            JsonParserOptions::default(),
+            "turbo.json",
+        )
+        .into_deserialized()
+        .unwrap();
+
+        let result = TaskDefinition::try_from(raw_task_definition);
+        assert!(matches!(result, Err(Error::InvalidTaskCompression { .. })));
+    }
+
+    #[test_case(r#"["src/$TURBO_ROOT$/tsconfig.json"]"# ; "in the middle")]
+    #[test_case(r#"["$TURBO_ROOT$tsconfig.json"]"# ; "without a separator")]
+    fn test_invalid_turbo_root_input(inputs: &str) {
+        let raw_task_definition: RawTaskDefinition = deserialize_from_json_str(
+            &format!(r#"{{ "inputs": {inputs} }}"#),
+            JsonParserOptions::default(),
+            "turbo.json",
+        )
+        .into_deserialized()
+        .unwrap();
+
+        let result = TaskDefinition::try_from(raw_task_definition);
+        assert!(matches!(result, Err(Error::InvalidTurboRootUse { .. })));
+    }
+
     #[test_case("[]", TaskOutputs::default() ; "empty")]
     #[test_case(r#"["target/**"]"#, TaskOutputs { inclusions: vec!["target/**".to_string()], exclusions: vec![] })]
     #[test_case(
@@ -1042,10 +2004,91 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_select_profile() {
+        let raw = RawTurboJson::parse_from_serde(json!({
+            "tasks": {
+                "build": {},
+            },
+            "profiles": {
+                "ci": {
+                    "tasks": {
+                        "build": { "outputLogs": "errors-only" },
+                        "web#build": { "cache": false },
+                    }
+                }
+            }
+        }))
+        .unwrap();
+        let mut turbo_json = TurboJson::try_from(raw).unwrap();
+        let build = TaskName::from("build");
+        let docs_build = TaskId::new("docs", "build");
+        let web_build = TaskId::new("web", "build");
+        assert!(turbo_json.profile_task(&docs_build, &build).is_none());
+
+        turbo_json.select_profile("ci").unwrap();
+        let docs_definition = turbo_json.profile_task(&docs_build, &build).unwrap();
+        assert_eq!(
+            docs_definition.output_logs.map(|mode| mode.into_inner()),
+            Some(OutputLogsMode::ErrorsOnly)
+        );
+        let web_definition = turbo_json.profile_task(&web_build, &build).unwrap();
+        assert_eq!(
+            web_definition.cache.map(|cache| cache.into_inner()),
+            Some(false)
+        );
+        assert!(web_definition.output_logs.is_none());
+
+        assert!(matches!(
+            turbo_json.select_profile("local"),
+            Err(Error::MissingProfile { .. })
+        ));
+    }
+
+    #[test]
+    fn test_prune_include() {
+        let json = RawTurboJson::parse_from_serde(json!({
+            "prune": {
+                "include": [".npmrc", "patches/"]
+            }
+        }))
+        .unwrap();
+        assert_eq!(
+            json.prune.and_then(|prune| prune.include),
+            Some(vec![".npmrc".to_string(), "patches/".to_string()])
+        );
+    }
+
+    #[test]
+    fn test_deprecated_pass_through_env() {
+        let raw = RawTurboJson::parse_from_serde(json!({
+            "experimentalGlobalPassThroughEnv": ["AWS_SECRET_KEY"],
+            "tasks": {
+                "build": {
+                    "experimentalPassThroughEnv": ["GITHUB_TOKEN"]
+                }
+            }
+        }))
+        .unwrap();
+        let turbo_json = TurboJson::try_from(raw).unwrap();
+        assert_eq!(
+            turbo_json.global_pass_through_env,
+            Some(vec!["AWS_SECRET_KEY".to_string()])
+        );
+
+        let build = turbo_json.tasks[&TaskName::from("build")].clone();
+        let task_definition = TaskDefinition::try_from(build.into_inner()).unwrap();
+        assert_eq!(
+            task_definition.pass_through_env,
+            Some(vec!["GITHUB_TOKEN".to_string()])
+        );
+    }
+
     #[test_case("full", Some(OutputLogsMode::Full) ; "full")]
     #[test_case("hash-only", Some(OutputLogsMode::HashOnly) ; "hash-only")]
     #[test_case("new-only", Some(OutputLogsMode::NewOnly) ; "new-only")]
     #[test_case("errors-only", Some(OutputLogsMode::ErrorsOnly) ; "errors-only")]
+    #[test_case("summary-line", Some(OutputLogsMode::SummaryLine) ; "summary-line")]
     #[test_case("none", Some(OutputLogsMode::None) ; "none")]
     #[test_case("junk", None ; "invalid value")]
     fn test_parsing_output_logs_mode(output_logs: &str, expected: Option<OutputLogsMode>) {
@@ -1067,10 +2110,10 @@ mod tests {
         assert_eq!(actual, expected);
     }
 
-    #[test_case(r#"{ "ui": "tui" }"#, Some(UI::Tui) ; "tui")]
-    #[test_case(r#"{ "ui": "stream" }"#, Some(UI::Stream) ; "stream")]
+    #[test_case(r#"{ "ui": "tui" }"#, Some(UIMode::Tui) ; "tui")]
+    #[test_case(r#"{ "ui": "stream" }"#, Some(UIMode::Stream) ; "stream")]
     #[test_case(r#"{}"#, None ; "missing")]
-    fn test_ui(json: &str, expected: Option<UI>) {
+    fn test_ui(json: &str, expected: Option<UIMode>) {
         let json = RawTurboJson::parse(json, AnchoredSystemPath::new("").unwrap()).unwrap();
         assert_eq!(json.ui, expected);
     }
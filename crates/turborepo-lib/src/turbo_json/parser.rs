@@ -7,7 +7,7 @@ use std::{
 
 use biome_deserialize::{
     json::deserialize_from_json_str, Deserializable, DeserializableValue,
-    DeserializationDiagnostic, DeserializationVisitor, VisitableType,
+    DeserializationDiagnostic, DeserializationVisitor, Text, VisitableType,
 };
 use biome_diagnostics::DiagnosticExt;
 use biome_json_parser::JsonParserOptions;
@@ -21,10 +21,15 @@ use turborepo_errors::WithMetadata;
 
 use crate::{
     run::task_id::TaskName,
-    turbo_json::{Pipeline, RawTaskDefinition, RawTurboJson, Spanned},
+    turbo_json::{
+        schema, Pipeline, Profiles, RawProfile, RawRunner, RawShell, RawTaskDefinition,
+        RawTurboJson, Spanned,
+    },
     unescape::UnescapedString,
 };
 
+const UNKNOWN_KEY_MESSAGE: &str = "Found an unknown key `";
+
 #[derive(Debug, Error, Diagnostic)]
 #[error("failed to parse turbo json")]
 #[diagnostic(code(turbo_json_parse_error))]
@@ -58,6 +63,7 @@ impl From<biome_diagnostics::Error> for ParseDiagnostic {
                 let len: usize = span.len().into();
                 (start, len).into()
             }),
+            suggestion: None,
         }
     }
 }
@@ -71,6 +77,114 @@ struct ParseDiagnostic {
     source_code: String,
     #[label]
     label: Option<SourceSpan>,
+    #[help]
+    suggestion: Option<String>,
+}
+
+impl ParseDiagnostic {
+    // Suggests the key that was most likely meant for unknown keys, out of
+    // the keys of the object that it's in
+    fn with_suggestion(mut self) -> Self {
+        let unknown_key = self
+            .message
+            .strip_prefix(UNKNOWN_KEY_MESSAGE)
+            .and_then(|rest| rest.split_once('`'))
+            .map(|(key, _)| key);
+        let (Some(key), Some(label)) = (unknown_key, self.label) else {
+            return self;
+        };
+        let keys = schema::keys_at(&key_path(&self.source_code, label.offset()));
+        if let Some(key) = closest_key(key, keys) {
+            self.suggestion = Some(format!("did you mean `{key}`?"));
+        }
+        self
+    }
+}
+
+// Returns the path to the object that `offset` is in. Each element is the key
+// of an object or `None` for the elements of an array.
+fn key_path(text: &str, offset: usize) -> Vec<Option<String>> {
+    // The key of each object or array that hasn't been closed yet
+    let mut path = Vec::new();
+    let mut last_string = None;
+    let mut key = None;
+    let mut chars = text
+        .char_indices()
+        .take_while(|(index, _)| *index < offset)
+        .peekable();
+    while let Some((_, c)) = chars.next() {
+        match c {
+            '"' => {
+                let mut string = String::new();
+                while let Some((_, c)) = chars.next() {
+                    match c {
+                        '"' => break,
+                        '\\' => {
+                            if let Some((_, escaped)) = chars.next() {
+                                string.push(escaped);
+                            }
+                        }
+                        c => string.push(c),
+                    }
+                }
+                last_string = Some(string);
+            }
+            // turbo.json can have comments
+            '/' if chars.peek().is_some_and(|(_, c)| *c == '/') => {
+                chars.by_ref().find(|(_, c)| *c == '\n');
+            }
+            '/' if chars.peek().is_some_and(|(_, c)| *c == '*') => {
+                chars.next();
+                let mut previous = ' ';
+                for (_, c) in chars.by_ref() {
+                    if previous == '*' && c == '/' {
+                        break;
+                    }
+                    previous = c;
+                }
+            }
+            ':' => key = last_string.take(),
+            '{' | '[' => path.push(key.take()),
+            '}' | ']' => {
+                path.pop();
+            }
+            ',' => key = None,
+            _ => (),
+        }
+    }
+    // The root object isn't the value of a key
+    if !path.is_empty() {
+        path.remove(0);
+    }
+    path
+}
+
+// Returns the key with the smallest edit distance to `key`, if it's close
+// enough to be a typo
+fn closest_key(key: &str, keys: impl IntoIterator<Item = String>) -> Option<String> {
+    let max_distance = (key.chars().count() / 3).max(1);
+    keys.into_iter()
+        .map(|candidate| (edit_distance(key, &candidate), candidate))
+        .filter(|(distance, _)| *distance <= max_distance)
+        .min_by_key(|(distance, _)| *distance)
+        .map(|(_, candidate)| candidate)
+}
+
+// The Levenshtein distance between `a` and `b`, ignoring case so that keys
+// with the wrong casing are matched
+fn edit_distance(a: &str, b: &str) -> usize {
+    let a = a.to_lowercase().chars().collect::<Vec<_>>();
+    let b = b.to_lowercase().chars().collect::<Vec<_>>();
+    let mut previous = (0..=b.len()).collect::<Vec<_>>();
+    for (i, a_char) in a.iter().enumerate() {
+        let mut current = vec![i + 1];
+        for (j, b_char) in b.iter().enumerate() {
+            let substitution = previous[j] + usize::from(a_char != b_char);
+            current.push(substitution.min(previous[j + 1] + 1).min(current[j] + 1));
+        }
+        previous = current;
+    }
+    previous[b.len()]
 }
 
 fn create_unknown_key_diagnostic_from_struct<T: Iterable>(
@@ -80,7 +194,8 @@ fn create_unknown_key_diagnostic_from_struct<T: Iterable>(
 ) -> DeserializationDiagnostic {
     let allowed_keys = struct_iterable
         .iter()
-        .map(|(k, _)| k.to_case(Case::Camel))
+        // Fields named after keywords are raw identifiers, e.g. `r#override`
+        .map(|(k, _)| k.trim_start_matches("r#").to_case(Case::Camel))
         .collect::<Vec<_>>();
     let allowed_keys_borrowed = allowed_keys.iter().map(|s| s.as_str()).collect::<Vec<_>>();
 
@@ -140,14 +255,150 @@ impl DeserializationVisitor for PipelineVisitor {
     }
 }
 
+impl Deserializable for RawRunner {
+    fn deserialize(
+        value: &impl DeserializableValue,
+        name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self> {
+        value.deserialize(RunnerVisitor, name, diagnostics)
+    }
+}
+
+struct RunnerVisitor;
+
+impl DeserializationVisitor for RunnerVisitor {
+    type Output = RawRunner;
+
+    const EXPECTED_TYPE: VisitableType = VisitableType::STR.union(VisitableType::ARRAY);
+
+    fn visit_str(
+        self,
+        value: Text,
+        _range: TextRange,
+        _name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self::Output> {
+        UnescapedString::from_escaped(value.text().to_string(), diagnostics).map(RawRunner::Command)
+    }
+
+    fn visit_array(
+        self,
+        items: impl Iterator<Item = Option<impl DeserializableValue>>,
+        _range: TextRange,
+        name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self::Output> {
+        items
+            .flatten()
+            .map(|item| UnescapedString::deserialize(&item, name, diagnostics))
+            .collect::<Option<Vec<_>>>()
+            .map(RawRunner::Args)
+    }
+}
+
+impl Deserializable for RawShell {
+    fn deserialize(
+        value: &impl DeserializableValue,
+        name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self> {
+        value.deserialize(ShellVisitor, name, diagnostics)
+    }
+}
+
+struct ShellVisitor;
+
+impl DeserializationVisitor for ShellVisitor {
+    type Output = RawShell;
+
+    const EXPECTED_TYPE: VisitableType = VisitableType::BOOL.union(VisitableType::MAP);
+
+    fn visit_bool(
+        self,
+        value: bool,
+        _range: TextRange,
+        _name: &str,
+        _diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self::Output> {
+        Some(RawShell::Enabled(value))
+    }
+
+    fn visit_map(
+        self,
+        members: impl Iterator<Item = Option<(impl DeserializableValue, impl DeserializableValue)>>,
+        _range: TextRange,
+        _name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self::Output> {
+        let mut unix = None;
+        let mut windows = None;
+        for (key, value) in members.flatten() {
+            let platform: String = UnescapedString::deserialize(&key, "", diagnostics)?.into();
+            match platform.as_str() {
+                "unix" => unix = UnescapedString::deserialize(&value, &platform, diagnostics),
+                "windows" => windows = UnescapedString::deserialize(&value, &platform, diagnostics),
+                _ => diagnostics.push(DeserializationDiagnostic::new_unknown_key(
+                    &platform,
+                    key.range(),
+                    &["unix", "windows"],
+                )),
+            }
+        }
+
+        Some(RawShell::Platforms { unix, windows })
+    }
+}
+
+impl Deserializable for Profiles {
+    fn deserialize(
+        value: &impl DeserializableValue,
+        name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self> {
+        value.deserialize(ProfilesVisitor, name, diagnostics)
+    }
+}
+
+struct ProfilesVisitor;
+
+impl DeserializationVisitor for ProfilesVisitor {
+    type Output = Profiles;
+
+    const EXPECTED_TYPE: VisitableType = VisitableType::MAP;
+
+    fn visit_map(
+        self,
+        members: impl Iterator<Item = Option<(impl DeserializableValue, impl DeserializableValue)>>,
+        _range: TextRange,
+        _name: &str,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self::Output> {
+        let mut result = BTreeMap::new();
+        for (key, value) in members.flatten() {
+            let profile_name: String = UnescapedString::deserialize(&key, "", diagnostics)?.into();
+            result.insert(
+                profile_name,
+                RawProfile::deserialize(&value, "", diagnostics)?,
+            );
+        }
+
+        Some(Profiles(result))
+    }
+}
+
 impl WithMetadata for RawTurboJson {
     fn add_text(&mut self, text: Arc<str>) {
         self.span.add_text(text.clone());
         self.extends.add_text(text.clone());
         self.global_dependencies.add_text(text.clone());
+        self.global_dot_env.add_text(text.clone());
         self.global_env.add_text(text.clone());
         self.global_pass_through_env.add_text(text.clone());
+        self.experimental_global_pass_through_env
+            .add_text(text.clone());
         self.tasks.add_text(text.clone());
+        self.profiles.add_text(text.clone());
         self.pipeline.add_text(text);
     }
 
@@ -155,13 +406,31 @@ impl WithMetadata for RawTurboJson {
         self.span.add_path(path.clone());
         self.extends.add_path(path.clone());
         self.global_dependencies.add_path(path.clone());
+        self.global_dot_env.add_path(path.clone());
         self.global_env.add_path(path.clone());
         self.global_pass_through_env.add_path(path.clone());
+        self.experimental_global_pass_through_env
+            .add_path(path.clone());
         self.tasks.add_path(path.clone());
+        self.profiles.add_path(path.clone());
         self.pipeline.add_path(path);
     }
 }
 
+impl WithMetadata for Profiles {
+    fn add_text(&mut self, text: Arc<str>) {
+        for profile in self.0.values_mut() {
+            profile.tasks.add_text(text.clone());
+        }
+    }
+
+    fn add_path(&mut self, path: Arc<str>) {
+        for profile in self.0.values_mut() {
+            profile.tasks.add_path(path.clone());
+        }
+    }
+}
+
 impl WithMetadata for Pipeline {
     fn add_text(&mut self, text: Arc<str>) {
         for (_, entry) in self.0.iter_mut() {
@@ -186,11 +455,26 @@ impl WithMetadata for RawTaskDefinition {
         }
         self.env.add_text(text.clone());
         self.inputs.add_text(text.clone());
+        self.input_transforms.add_text(text.clone());
         self.pass_through_env.add_text(text.clone());
+        self.experimental_pass_through_env.add_text(text.clone());
         self.persistent.add_text(text.clone());
         self.outputs.add_text(text.clone());
+        self.local_only_outputs.add_text(text.clone());
         self.output_logs.add_text(text.clone());
-        self.interactive.add_text(text);
+        self.interactive.add_text(text.clone());
+        self.concurrency_group.add_text(text.clone());
+        self.tool_dependencies.add_text(text.clone());
+        self.dot_env.add_text(text.clone());
+        self.inject_dot_env.add_text(text.clone());
+        self.weight.add_text(text.clone());
+        self.timeout.add_text(text.clone());
+        self.runner.add_text(text.clone());
+        self.shell.add_text(text.clone());
+        self.follow_symlinks.add_text(text.clone());
+        self.compression.add_text(text.clone());
+        self.r#override.add_text(text.clone());
+        self.ready.add_text(text);
     }
 
     fn add_path(&mut self, path: Arc<str>) {
@@ -200,11 +484,26 @@ impl WithMetadata for RawTaskDefinition {
         }
         self.env.add_path(path.clone());
         self.inputs.add_path(path.clone());
+        self.input_transforms.add_path(path.clone());
         self.pass_through_env.add_path(path.clone());
+        self.experimental_pass_through_env.add_path(path.clone());
         self.persistent.add_path(path.clone());
         self.outputs.add_path(path.clone());
+        self.local_only_outputs.add_path(path.clone());
         self.output_logs.add_path(path.clone());
-        self.interactive.add_path(path);
+        self.interactive.add_path(path.clone());
+        self.concurrency_group.add_path(path.clone());
+        self.tool_dependencies.add_path(path.clone());
+        self.dot_env.add_path(path.clone());
+        self.inject_dot_env.add_path(path.clone());
+        self.weight.add_path(path.clone());
+        self.timeout.add_path(path.clone());
+        self.runner.add_path(path.clone());
+        self.shell.add_path(path.clone());
+        self.follow_symlinks.add_path(path.clone());
+        self.compression.add_path(path.clone());
+        self.r#override.add_path(path.clone());
+        self.ready.add_path(path);
     }
 }
 
@@ -237,9 +536,11 @@ impl RawTurboJson {
                 .into_diagnostics()
                 .into_iter()
                 .map(|d| {
-                    d.with_file_source_code(text)
-                        .with_file_path(file_path.as_str())
-                        .into()
+                    ParseDiagnostic::from(
+                        d.with_file_source_code(text)
+                            .with_file_path(file_path.as_str()),
+                    )
+                    .with_suggestion()
                 })
                 .collect();
 
@@ -262,3 +563,61 @@ impl RawTurboJson {
         Ok(turbo_json)
     }
 }
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+    use turbopath::AnchoredSystemPath;
+
+    use super::{closest_key, key_path};
+    use crate::turbo_json::{schema, RawTurboJson};
+
+    #[test_case("globalEnvs", &[], Some("globalEnv") ; "extra letter")]
+    #[test_case("dependOn", &[Some("tasks"), Some("build")], Some("dependsOn") ; "nested key")]
+    #[test_case("OUTPUTS", &[Some("tasks"), Some("build")], Some("outputs") ; "casing")]
+    #[test_case("outputs", &[], None ; "key of another object")]
+    #[test_case("dirr", &[Some("cacheOptions"), Some("tiers"), None], Some("dir") ; "array element")]
+    #[test_case("banana", &[], None ; "no close key")]
+    fn test_closest_key(key: &str, path: &[Option<&str>], expected: Option<&str>) {
+        let path = path
+            .iter()
+            .map(|key| key.map(str::to_string))
+            .collect::<Vec<_>>();
+        assert_eq!(
+            closest_key(key, schema::keys_at(&path)).as_deref(),
+            expected
+        );
+    }
+
+    #[test]
+    fn test_key_path() {
+        let text = r#"{
+          // "remoteCache": {
+          "tasks": {
+            "lint": { "outputs": ["{a,b}"] },
+            /* "test": { */
+            "build": { "dependsOn": ["^build"], "here": true }
+          }
+        }"#;
+        let offset = text.find("\"here\"").unwrap();
+        assert_eq!(
+            key_path(text, offset),
+            vec![Some("tasks".to_string()), Some("build".to_string())]
+        );
+    }
+
+    #[test]
+    fn test_unknown_key_diagnostic() {
+        let err = RawTurboJson::parse(
+            r#"{"tasks": {"build": {"outpts": []}}}"#,
+            AnchoredSystemPath::new("turbo.json").unwrap(),
+        )
+        .unwrap_err();
+
+        let diagnostic = &err.diagnostics[0];
+        assert_eq!(
+            diagnostic.suggestion.as_deref(),
+            Some("did you mean `outputs`?")
+        );
+    }
+}
@@ -0,0 +1,126 @@
+//! The JSON schema of turbo.json, which is generated from the types in
+//! `@turbo/types` with `pnpm --filter @turbo/types generate-schema`.
+//! `turbo config schema` prints it so editors can validate and complete the
+//! file, and unknown keys are checked against it for suggestions.
+
+use std::collections::BTreeSet;
+
+use serde_json::Value;
+
+const SCHEMA: &str = include_str!("../../../../packages/turbo-types/schemas/schema.json");
+
+/// Returns the JSON schema of turbo.json
+pub fn schema() -> Value {
+    serde_json::from_str(SCHEMA).expect("schema.json is valid JSON")
+}
+
+/// Returns the keys that the object at `path` in turbo.json can have, e.g.
+/// `[Some("tasks"), Some("build")]` for the keys of a task definition. `None`
+/// stands for the elements of an array.
+pub(crate) fn keys_at(path: &[Option<String>]) -> BTreeSet<String> {
+    let schema = schema();
+    let mut objects = Vec::new();
+    resolve(&schema, &schema, &mut objects);
+    for segment in path {
+        let mut children = Vec::new();
+        for object in objects {
+            let child = match segment {
+                Some(key) => object
+                    .get("properties")
+                    .and_then(|properties| properties.get(key))
+                    .or_else(|| object.get("additionalProperties").filter(|a| a.is_object())),
+                None => object.get("items"),
+            };
+            if let Some(child) = child {
+                resolve(&schema, child, &mut children);
+            }
+        }
+        objects = children;
+    }
+
+    objects
+        .into_iter()
+        .filter_map(|object| object.get("properties").and_then(Value::as_object))
+        .flat_map(|properties| properties.keys().cloned())
+        .collect()
+}
+
+// Follows references and unions, e.g. of the root and package schemas, to the
+// schemas they're made of
+fn resolve<'a>(schema: &'a Value, value: &'a Value, resolved: &mut Vec<&'a Value>) {
+    if let Some(reference) = value.get("$ref").and_then(Value::as_str) {
+        let name = reference
+            .trim_start_matches("#/definitions/")
+            .replace("%3C", "<")
+            .replace("%3E", ">");
+        if let Some(definition) = schema
+            .get("definitions")
+            .and_then(|definitions| definitions.get(&name))
+        {
+            resolve(schema, definition, resolved);
+        }
+    } else if let Some(variants) = value.get("anyOf").and_then(Value::as_array) {
+        for variant in variants {
+            resolve(schema, variant, resolved);
+        }
+    } else {
+        resolved.push(value);
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use std::collections::BTreeSet;
+
+    use convert_case::{Case, Casing};
+    use struct_iterable::Iterable;
+    use test_case::test_case;
+
+    use super::keys_at;
+    use crate::turbo_json::{RawRemoteCacheOptions, RawTaskDefinition, RawTurboJson};
+
+    fn struct_keys<T: Iterable>(value: &T) -> BTreeSet<String> {
+        value
+            .iter()
+            .map(|(key, _)| match key {
+                "schema" => "$schema".to_string(),
+                "_comment" => "//".to_string(),
+                key => key.trim_start_matches("r#").to_case(Case::Camel),
+            })
+            .filter(|key| key != "span")
+            .collect()
+    }
+
+    // The keys that turbo reads but that aren't part of the types: comments,
+    // options that are being phased out and ones that are only set by older
+    // clients.
+    #[test_case(
+        struct_keys(&RawTurboJson::default()),
+        &[],
+        &["//", "experimentalSpaces", "pipeline"] ;
+        "root"
+    )]
+    #[test_case(
+        struct_keys(&RawTaskDefinition::default()),
+        &["tasks", "build"],
+        &[] ;
+        "task definition"
+    )]
+    #[test_case(
+        struct_keys(&RawRemoteCacheOptions::default()),
+        &["remoteCache"],
+        &["apiUrl", "loginUrl", "teamSlug", "teamId", "preflight", "timeout"] ;
+        "remote cache"
+    )]
+    fn test_schema_matches_structs(keys: BTreeSet<String>, path: &[&str], struct_only: &[&str]) {
+        let path = path
+            .iter()
+            .map(|key| Some(key.to_string()))
+            .collect::<Vec<_>>();
+        let keys = keys
+            .into_iter()
+            .filter(|key| !struct_only.contains(&key.as_str()))
+            .collect::<BTreeSet<_>>();
+        assert_eq!(keys_at(&path), keys);
+    }
+}
@@ -41,7 +41,17 @@ impl Deserializable for UnescapedString {
         diagnostics: &mut Vec<DeserializationDiagnostic>,
     ) -> Option<Self> {
         let str = String::deserialize(value, name, diagnostics)?;
+        Self::from_escaped(str, diagnostics)
+    }
+}
 
+impl UnescapedString {
+    /// Unescapes a string as it's written in JSON, for visitors that
+    /// deserialize strings themselves
+    pub(crate) fn from_escaped(
+        str: String,
+        diagnostics: &mut Vec<DeserializationDiagnostic>,
+    ) -> Option<Self> {
         match unescape_str(str) {
             Ok(s) => Some(Self(s)),
             Err(e) => {
@@ -0,0 +1,18 @@
+// read environment variables in each of the ways that the env audit shim records
+
+const { spawnSync } = require("child_process");
+
+process.env.API_URL;
+"DEBUG" in process.env;
+process.env.DECLARED;
+
+// copying the environment, including for a child process, enumerates every
+// variable without reading them
+const copy = { ...process.env };
+spawnSync(process.execPath, ["-e", "process.env.CHILD_VAR"], {
+  stdio: "inherit",
+});
+
+setTimeout(() => {
+  process.env.LATER;
+}, 0);
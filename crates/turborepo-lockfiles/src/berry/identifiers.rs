@@ -75,6 +75,15 @@ impl<'a> Ident<'a> {
         let name = Cow::Owned(name.to_string());
         Ident { scope, name }
     }
+
+    /// The ident as it appears in file names, e.g. `@babel/core` becomes
+    /// `@babel-core`
+    pub fn slug(&self) -> String {
+        match self.scope.as_deref() {
+            Some(scope) => format!("@{scope}-{}", self.name),
+            None => self.name.to_string(),
+        }
+    }
 }
 
 // These TryFrom impls should be FromStr, but to avoid unnecessary copying we
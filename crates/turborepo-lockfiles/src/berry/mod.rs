@@ -511,6 +511,59 @@ impl Lockfile for BerryLockfile {
         Ok(patches)
     }
 
+    fn cache_files(&self, file_names: Vec<String>) -> Vec<String> {
+        // Yarn names cache archives `<ident>-<reference>-<locator hash>-<checksum>.zip`
+        // using the first 10 characters of the locator hash and checksum. The locator
+        // hash isn't in the lockfile so it's the only part that isn't checked.
+        let mut prefixes_by_checksum: HashMap<&str, HashSet<String>> = HashMap::new();
+        for locator in self.resolutions.values() {
+            let Some(package) = self.locator_package.get(locator) else {
+                continue;
+            };
+            let Some(checksum) = package.checksum.as_deref() else {
+                continue;
+            };
+            // Yarn 4 prefixes checksums with the cache key, e.g. `10c0/`
+            let checksum = checksum.rsplit('/').next().unwrap_or(checksum);
+            let Some(checksum) = checksum.get(..10) else {
+                continue;
+            };
+            let (protocol, selector) = locator
+                .reference
+                .split_once(':')
+                .unwrap_or(("exotic", locator.reference.as_ref()));
+            // Versions are only included for references that are one, e.g. `npm:4.17.21`
+            let reference = match selector == package.version {
+                true => format!("{protocol}-{selector}"),
+                false => protocol.to_string(),
+            };
+            prefixes_by_checksum
+                .entry(checksum)
+                .or_default()
+                .insert(format!("{}-{reference}-", locator.ident.slug()));
+        }
+
+        file_names
+            .into_iter()
+            .filter(|file_name| {
+                let Some((rest, checksum)) = file_name
+                    .strip_suffix(".zip")
+                    .and_then(|stem| stem.rsplit_once('-'))
+                else {
+                    return false;
+                };
+                prefixes_by_checksum
+                    .get(checksum)
+                    .map_or(false, |prefixes| {
+                        prefixes.iter().any(|prefix| {
+                            rest.strip_prefix(prefix.as_str())
+                                .map_or(false, |locator_hash| locator_hash.len() == 10)
+                        })
+                    })
+            })
+            .collect()
+    }
+
     fn global_change(&self, other: &dyn Lockfile) -> bool {
         let any_other = other as &dyn Any;
         if let Some(other) = any_other.downcast_ref::<Self>() {
@@ -521,6 +574,22 @@ impl Lockfile for BerryLockfile {
         }
     }
 
+    fn package_names(&self) -> Option<HashSet<String>> {
+        // Descriptors are included for the names that aliased packages are
+        // depended on with
+        let names = self
+            .locator_package
+            .keys()
+            .map(|locator| locator.ident.to_string())
+            .chain(
+                self.resolutions
+                    .keys()
+                    .map(|descriptor| descriptor.ident.to_string()),
+            )
+            .collect();
+        Some(names)
+    }
+
     fn turbo_version(&self) -> Option<String> {
         let turbo_ident = Ident::try_from("turbo").expect("'turbo' is valid identifier");
         let key = self
@@ -772,6 +841,32 @@ mod test {
         assert_eq!(lodash_desc.unwrap().reference, "npm:4.17.21");
     }
 
+    #[test]
+    fn test_cache_files() {
+        let data: LockfileData =
+            serde_yaml::from_str(include_str!("../../fixtures/minimal-berry.lock")).unwrap();
+        let lockfile = BerryLockfile::new(data, None).unwrap();
+        let cache_files = || {
+            vec![
+                "lodash-npm-4.17.21-6382451519-eb835a2e51.zip".to_string(),
+                "lodash-es-npm-4.17.21-6382451519-eb835a2e51.zip".to_string(),
+                "lodash-npm-3.10.1-f2a1b3c4d5-eb835a2e51.zip".to_string(),
+                "lodash-npm-4.17.21-6382451519-eb835a2e51.zip.tmp".to_string(),
+            ]
+        };
+
+        let pruned_lockfile = lockfile
+            .subgraph(&["packages/a".into()], &["lodash@npm:4.17.21".into()])
+            .unwrap();
+        assert_eq!(
+            pruned_lockfile.cache_files(cache_files()),
+            vec!["lodash-npm-4.17.21-6382451519-eb835a2e51.zip".to_string()]
+        );
+
+        let pruned_lockfile = lockfile.subgraph(&["packages/c".into()], &[]).unwrap();
+        assert!(pruned_lockfile.cache_files(cache_files()).is_empty());
+    }
+
     #[test]
     fn test_closure_with_patch() {
         let data = LockfileData::from_bytes(include_bytes!("../../fixtures/berry.lock")).unwrap();
@@ -7,6 +7,7 @@ mod berry;
 mod bun;
 mod error;
 mod npm;
+mod overrides;
 mod pnpm;
 mod yarn1;
 
@@ -19,6 +20,7 @@ pub use berry::{Error as BerryError, *};
 pub use bun::BunLockfile;
 pub use error::Error;
 pub use npm::*;
+pub use overrides::{override_applies, selector_packages};
 pub use pnpm::{pnpm_global_change, pnpm_subgraph, PnpmLockfile};
 use rayon::prelude::*;
 use serde::Serialize;
@@ -60,6 +62,20 @@ pub trait Lockfile: Send + Sync + Any + std::fmt::Debug {
         Ok(Vec::new())
     }
 
+    /// Names of all of the packages in the lockfile, used to drop the
+    /// overrides that don't apply to a pruned lockfile. Returns `None` if the
+    /// lockfile can't tell, in which case every override is kept.
+    fn package_names(&self) -> Option<HashSet<String>> {
+        None
+    }
+
+    /// Filters the names of the files in the package manager's offline cache,
+    /// e.g. Yarn's `.yarn/cache`, down to the ones holding packages in this
+    /// lockfile
+    fn cache_files(&self, file_names: Vec<String>) -> Vec<String> {
+        file_names
+    }
+
     /// Determine if there's a global change between two lockfiles
     fn global_change(&self, other: &dyn Lockfile) -> bool;
 
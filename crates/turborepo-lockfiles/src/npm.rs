@@ -1,4 +1,7 @@
-use std::{any::Any, collections::HashMap};
+use std::{
+    any::Any,
+    collections::{HashMap, HashSet},
+};
 
 use serde::{Deserialize, Serialize};
 use serde_json::Value;
@@ -148,6 +151,27 @@ impl Lockfile for NpmLockfile {
         }
     }
 
+    fn package_names(&self) -> Option<HashSet<String>> {
+        let names = self
+            .packages
+            .iter()
+            .filter_map(|(key, package)| {
+                let (_, install_name) = key.rsplit_once("node_modules/")?;
+                // Aliased packages are installed under the alias, and record the
+                // name of the package they're an alias of
+                let name = package.other.get("name").and_then(|name| name.as_str());
+                Some(
+                    [Some(install_name), name]
+                        .into_iter()
+                        .flatten()
+                        .map(String::from),
+                )
+            })
+            .flatten()
+            .collect();
+        Some(names)
+    }
+
     fn turbo_version(&self) -> Option<String> {
         let turbo_entry = self.packages.get("node_modules/turbo")?;
         turbo_entry.version.clone()
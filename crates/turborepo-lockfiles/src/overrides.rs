@@ -0,0 +1,74 @@
+//! Overrides force the version of a package anywhere in the dependency tree.
+//! They're npm's `overrides`, pnpm's `pnpm.overrides` and Yarn's
+//! `resolutions`, and each of them is keyed by a selector that names the
+//! package, optionally with a version range and the packages it's a
+//! dependency of.
+
+use std::collections::HashSet;
+
+/// Returns the names of the packages in an override selector, e.g. `foo` and
+/// `@scope/bar` for `foo@1>@scope/bar` (pnpm) or `foo/**/@scope/bar` (Yarn)
+pub fn selector_packages(selector: &str) -> Vec<&str> {
+    let mut names = Vec::new();
+    for part in selector.split('>') {
+        let mut rest = part;
+        while !rest.is_empty() {
+            if let Some(after_glob) = rest.strip_prefix("**") {
+                rest = after_glob.strip_prefix('/').unwrap_or(after_glob);
+                continue;
+            }
+            // The slash in a scoped name doesn't separate packages
+            let scope_len = match rest.starts_with('@') {
+                true => rest.find('/').map_or(rest.len(), |slash| slash + 1),
+                false => 0,
+            };
+            let end = rest[scope_len..]
+                .find(['/', '@'])
+                .map_or(rest.len(), |end| end + scope_len);
+            if end > 0 {
+                names.push(&rest[..end]);
+            }
+            // Anything after an `@` is a version range, which can contain slashes
+            match rest[end..].strip_prefix('/') {
+                Some(next) => rest = next,
+                None => break,
+            }
+        }
+    }
+    names
+}
+
+/// Returns if an override with the given selector could change any of the
+/// packages in `package_names`
+pub fn override_applies(selector: &str, package_names: &HashSet<String>) -> bool {
+    selector_packages(selector)
+        .into_iter()
+        .any(|name| package_names.contains(name))
+}
+
+#[cfg(test)]
+mod test {
+    use std::collections::HashSet;
+
+    use test_case::test_case;
+
+    use super::*;
+
+    #[test_case("foo", &["foo"] ; "name")]
+    #[test_case("@scope/foo@^1.0.0", &["@scope/foo"] ; "scoped with range")]
+    #[test_case("bar@1>foo@<2", &["bar", "foo"] ; "pnpm parent")]
+    #[test_case("**/foo", &["foo"] ; "yarn glob")]
+    #[test_case("@scope/bar/**/@scope/foo", &["@scope/bar", "@scope/foo"] ; "yarn scoped path")]
+    #[test_case("foo@npm:1.0.0", &["foo"] ; "berry protocol")]
+    #[test_case("foo@github:user/repo", &["foo"] ; "slash in range")]
+    fn test_selector_packages(selector: &str, expected: &[&str]) {
+        assert_eq!(selector_packages(selector), expected);
+    }
+
+    #[test]
+    fn test_override_applies() {
+        let package_names = HashSet::from(["foo".to_string()]);
+        assert!(override_applies("bar>foo", &package_names));
+        assert!(!override_applies("bar", &package_names));
+    }
+}
@@ -1,7 +1,7 @@
 use std::{
     any::Any,
     borrow::Cow,
-    collections::{BTreeMap, HashMap},
+    collections::{BTreeMap, HashMap, HashSet},
 };
 
 use serde::{Deserialize, Serialize};
@@ -13,6 +13,7 @@ type Map<K, V> = std::collections::BTreeMap<K, V>;
 
 type Packages = Map<String, PackageSnapshot>;
 type Snapshots = Map<String, PackageSnapshotV7>;
+type Catalogs = Map<String, Map<String, CatalogEntry>>;
 
 #[derive(Debug, Serialize, Deserialize, PartialEq, Eq, Clone)]
 #[serde(rename_all = "camelCase")]
@@ -21,14 +22,20 @@ pub struct PnpmLockfile {
     #[serde(skip_serializing_if = "Option::is_none")]
     settings: Option<LockfileSettings>,
     #[serde(skip_serializing_if = "Option::is_none")]
+    catalogs: Option<Catalogs>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     never_built_dependencies: Option<Vec<String>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     only_built_dependencies: Option<Vec<String>>,
     #[serde(skip_serializing_if = "Option::is_none")]
+    ignored_optional_dependencies: Option<Vec<String>>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     overrides: Option<Map<String, String>>,
     #[serde(skip_serializing_if = "Option::is_none")]
     package_extensions_checksum: Option<String>,
     #[serde(skip_serializing_if = "Option::is_none")]
+    pnpmfile_checksum: Option<String>,
+    #[serde(skip_serializing_if = "Option::is_none")]
     patched_dependencies: Option<Map<String, PatchFile>>,
     importers: Map<String, ProjectSnapshot>,
     #[serde(skip_serializing_if = "Option::is_none")]
@@ -47,6 +54,13 @@ pub struct PatchFile {
     hash: String,
 }
 
+// Entry of a catalog introduced in lockfile v9
+#[derive(Debug, Serialize, Deserialize, PartialEq, Eq, Clone)]
+pub struct CatalogEntry {
+    specifier: String,
+    version: String,
+}
+
 #[derive(Debug, Serialize, Deserialize, PartialEq, Eq, Clone)]
 #[serde(rename_all = "camelCase")]
 pub struct ProjectSnapshot {
@@ -168,6 +182,8 @@ pub struct PackageResolution {
 struct LockfileSettings {
     auto_install_peers: Option<bool>,
     exclude_links_from_lockfile: Option<bool>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    inject_workspace_packages: Option<bool>,
 }
 
 impl PnpmLockfile {
@@ -313,6 +329,7 @@ impl PnpmLockfile {
         GlobalFields {
             version: &self.lockfile_version.version,
             checksum: self.package_extensions_checksum.as_deref(),
+            pnpmfile_checksum: self.pnpmfile_checksum.as_deref(),
             overrides: self.overrides.as_ref(),
             patched_dependencies: self.patched_dependencies.as_ref(),
             settings: self.settings.as_ref(),
@@ -358,6 +375,7 @@ impl PnpmLockfile {
 struct GlobalFields<'a> {
     version: &'a str,
     checksum: Option<&'a str>,
+    pnpmfile_checksum: Option<&'a str>,
     overrides: Option<&'a BTreeMap<String, String>>,
     patched_dependencies: Option<&'a BTreeMap<String, PatchFile>>,
     settings: Option<&'a LockfileSettings>,
@@ -473,22 +491,40 @@ impl crate::Lockfile for PnpmLockfile {
             .map(|patches| self.prune_patches(patches, &pruned_packages))
             .transpose()?;
 
-        Ok(Box::new(Self {
+        let mut pruned_lockfile = Self {
             importers,
             packages: match pruned_packages.is_empty() {
                 false => Some(pruned_packages),
                 true => None,
             },
             lockfile_version: self.lockfile_version.clone(),
+            catalogs: self.catalogs.clone(),
             never_built_dependencies: self.never_built_dependencies.clone(),
             only_built_dependencies: self.only_built_dependencies.clone(),
-            overrides: self.overrides.clone(),
+            ignored_optional_dependencies: self.ignored_optional_dependencies.clone(),
+            overrides: None,
             package_extensions_checksum: self.package_extensions_checksum.clone(),
+            pnpmfile_checksum: self.pnpmfile_checksum.clone(),
             patched_dependencies: patches,
             snapshots: pruned_snapshots,
             time: None,
             settings: self.settings.clone(),
-        }))
+        };
+        // pnpm won't install from a lockfile with different overrides than the
+        // package.json, which is pruned the same way using the names of the
+        // remaining packages
+        if let Some(overrides) = &self.overrides {
+            let package_names =
+                crate::Lockfile::package_names(&pruned_lockfile).unwrap_or_default();
+            let pruned_overrides = overrides
+                .iter()
+                .filter(|(selector, _)| crate::override_applies(selector, &package_names))
+                .map(|(selector, value)| (selector.clone(), value.clone()))
+                .collect::<Map<_, _>>();
+            pruned_lockfile.overrides = (!pruned_overrides.is_empty()).then_some(pruned_overrides);
+        }
+
+        Ok(Box::new(pruned_lockfile))
     }
 
     fn encode(&self) -> Result<Vec<u8>, crate::Error> {
@@ -515,6 +551,39 @@ impl crate::Lockfile for PnpmLockfile {
         }
     }
 
+    fn package_names(&self) -> Option<HashSet<String>> {
+        // Overrides can name a package by the alias it's depended on with, so
+        // those are included along with the names of the packages themselves
+        let importer_names = self
+            .importers
+            .values()
+            .flat_map(|importer| importer.dependencies.names())
+            .map(String::from);
+        let package_names = self
+            .packages
+            .iter()
+            .flatten()
+            .filter_map(|(key, _)| DepPath::parse(self.version(), key).ok())
+            .map(|dp| dp.name.to_string());
+        let dependency_names = self
+            .packages
+            .iter()
+            .flatten()
+            .flat_map(|(_, entry)| entry.snapshot.dependencies().into_keys())
+            .chain(
+                self.snapshots
+                    .iter()
+                    .flatten()
+                    .flat_map(|(_, snapshot)| snapshot.dependencies().into_keys()),
+            );
+        Some(
+            importer_names
+                .chain(package_names)
+                .chain(dependency_names)
+                .collect(),
+        )
+    }
+
     fn turbo_version(&self) -> Option<String> {
         let turbo_version = self
             .importers
@@ -554,6 +623,28 @@ impl DependencyInfo {
         }
     }
 
+    // Names of all of the importer's dependencies
+    fn names(&self) -> Vec<&str> {
+        match self {
+            DependencyInfo::PreV6 { specifiers, .. } => specifiers
+                .iter()
+                .flatten()
+                .map(|(name, _)| name.as_str())
+                .collect(),
+            DependencyInfo::V6 {
+                dependencies,
+                optional_dependencies,
+                dev_dependencies,
+            } => dependencies
+                .iter()
+                .chain(optional_dependencies.iter())
+                .chain(dev_dependencies.iter())
+                .flatten()
+                .map(|(name, _)| name.as_str())
+                .collect(),
+        }
+    }
+
     fn get_resolution<'a, V>(maybe_map: &'a Option<Map<String, V>>, key: &str) -> Option<&'a V> {
         maybe_map.as_ref().and_then(|maybe_map| maybe_map.get(key))
     }
@@ -590,6 +681,7 @@ pub fn pnpm_global_change(
     let curr_data = PnpmLockfile::from_bytes(curr_contents)?;
     Ok(prev_data.lockfile_version != curr_data.lockfile_version
         || prev_data.package_extensions_checksum != curr_data.package_extensions_checksum
+        || prev_data.pnpmfile_checksum != curr_data.pnpmfile_checksum
         || prev_data.overrides != curr_data.overrides
         || prev_data.patched_dependencies != curr_data.patched_dependencies
         || prev_data.settings != curr_data.settings)
@@ -620,6 +712,7 @@ mod tests {
     const PNPM_V7_PEER: &[u8] = include_bytes!("../../fixtures/pnpm-v7-peer.yaml").as_slice();
     const PNPM_V7_PATCH: &[u8] = include_bytes!("../../fixtures/pnpm-v7-patch.yaml").as_slice();
     const PNPM_V9: &[u8] = include_bytes!("../../fixtures/pnpm-v9.yaml").as_slice();
+    const PNPM_V9_CATALOG: &[u8] = include_bytes!("../../fixtures/pnpm-v9-catalog.yaml").as_slice();
     const PNPM6_TURBO: &[u8] = include_bytes!("../../fixtures/pnpm6turbo.yaml").as_slice();
     const PNPM8_TURBO: &[u8] = include_bytes!("../../fixtures/pnpm8turbo.yaml").as_slice();
 
@@ -634,6 +727,7 @@ mod tests {
     #[test_case(PNPM_V7_PEER)]
     #[test_case(PNPM_V7_PATCH)]
     #[test_case(PNPM_V9)]
+    #[test_case(PNPM_V9_CATALOG)]
     fn test_roundtrip(fixture: &[u8]) {
         let lockfile = PnpmLockfile::from_bytes(fixture).unwrap();
         let serialized_lockfile = serde_yaml::to_string(&lockfile).unwrap();
@@ -886,6 +980,17 @@ mod tests {
         }))
         ; "v9"
     )]
+    #[test_case(
+        PNPM_V9_CATALOG,
+        "packages/b",
+        "is-odd",
+        "catalog:",
+        Ok(Some(crate::Package {
+            key: "is-odd@3.0.1".into(),
+            version: "3.0.1".into(),
+        }))
+        ; "v9 catalog"
+    )]
     fn test_resolve_package(
         lockfile: &[u8],
         workspace_path: &str,
@@ -966,6 +1071,51 @@ mod tests {
         )
     }
 
+    #[test]
+    fn test_prune_overrides() {
+        let lockfile = PnpmLockfile::from_bytes(
+            br#"lockfileVersion: "6.0"
+
+overrides:
+  is-odd: 3.0.1
+  lodash: 4.17.21
+
+importers:
+  .: {}
+
+  packages/a:
+    dependencies:
+      is-odd:
+        specifier: 3.0.1
+        version: 3.0.1
+
+  packages/b:
+    dependencies:
+      lodash:
+        specifier: 4.17.21
+        version: 4.17.21
+
+packages:
+  /is-odd@3.0.1:
+    resolution: {integrity: sha512-odd}
+    dev: false
+
+  /lodash@4.17.21:
+    resolution: {integrity: sha512-lodash}
+    dev: false
+"#,
+        )
+        .unwrap();
+        let pruned = lockfile
+            .subgraph(&["packages/a".into()], &["/is-odd@3.0.1".into()])
+            .unwrap() as Box<dyn Any>;
+        let pruned: &PnpmLockfile = pruned.downcast_ref().unwrap();
+        assert_eq!(
+            pruned.overrides,
+            Some(Map::from([("is-odd".to_string(), "3.0.1".to_string())]))
+        );
+    }
+
     #[test]
     fn test_pnpm_alias_overlap() {
         let lockfile = PnpmLockfile::from_bytes(PNPM_ABSOLUTE).unwrap();
@@ -1082,6 +1232,48 @@ c:
         let settings = lockfile.settings.unwrap();
         assert_eq!(settings.auto_install_peers, Some(true));
         assert_eq!(settings.exclude_links_from_lockfile, Some(false));
+        assert_eq!(settings.inject_workspace_packages, None);
+    }
+
+    #[test]
+    fn test_lockfile_v9_subgraph_keeps_global_fields() {
+        let lockfile = PnpmLockfile::from_bytes(PNPM_V9_CATALOG).unwrap();
+        let pruned_lockfile = lockfile
+            .subgraph(
+                &["packages/b".into()],
+                &["is-odd@3.0.1".into(), "is-number@6.0.0".into()],
+            )
+            .unwrap() as Box<dyn Any>;
+
+        let pruned_lockfile: &PnpmLockfile = pruned_lockfile.downcast_ref().unwrap();
+        assert_eq!(
+            pruned_lockfile.importers.keys().collect::<Vec<_>>(),
+            vec![".", "packages/b"]
+        );
+        assert_eq!(pruned_lockfile.catalogs, lockfile.catalogs);
+        assert_eq!(
+            pruned_lockfile.pnpmfile_checksum,
+            lockfile.pnpmfile_checksum
+        );
+        assert_eq!(
+            pruned_lockfile.ignored_optional_dependencies,
+            Some(vec!["fsevents".to_string()])
+        );
+        assert_eq!(
+            pruned_lockfile
+                .settings
+                .as_ref()
+                .and_then(|settings| settings.inject_workspace_packages),
+            Some(true)
+        );
+        let snapshots = pruned_lockfile.snapshots.as_ref().unwrap();
+        assert!(snapshots.contains_key("is-odd@3.0.1"));
+        assert!(!snapshots.contains_key("ajv-keywords@5.1.0(ajv@8.12.0)"));
+
+        let encoded = String::from_utf8(pruned_lockfile.encode().unwrap()).unwrap();
+        assert!(encoded.contains("injectWorkspacePackages: true"));
+        assert!(encoded.contains("pnpmfileChecksum:"));
+        assert!(encoded.contains("catalogs:"));
     }
 
     #[test]
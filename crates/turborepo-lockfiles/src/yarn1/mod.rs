@@ -1,4 +1,4 @@
-use std::{any::Any, str::FromStr};
+use std::{any::Any, collections::HashSet, str::FromStr};
 
 use serde::Deserialize;
 
@@ -118,6 +118,18 @@ impl Lockfile for Yarn1Lockfile {
         any_other.downcast_ref::<Self>().is_none()
     }
 
+    fn package_names(&self) -> Option<HashSet<String>> {
+        // Keys list every descriptor that resolves to the entry e.g.
+        // `foo@^1.0.0, foo@^1.1.0` and they all share a name
+        let names = self
+            .inner
+            .keys()
+            .filter_map(|key| crate::selector_packages(key).first().copied())
+            .map(String::from)
+            .collect();
+        Some(names)
+    }
+
     fn turbo_version(&self) -> Option<String> {
         // Yarn lockfiles can have multiple descriptors as a key e.g. turbo@latest,
         // turbo@1.2.3 We just check if the first descriptor is for turbo and
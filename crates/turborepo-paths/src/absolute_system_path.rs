@@ -5,6 +5,7 @@ use std::os::unix::fs::symlink as symlink_dir;
 #[cfg(windows)]
 use std::os::windows::fs::{symlink_dir, symlink_file};
 use std::{
+    borrow::Cow,
     fmt,
     fs::{File, Metadata, OpenOptions, Permissions},
     io::{self, Write},
@@ -300,7 +301,20 @@ impl AbsoluteSystemPath {
     /// Canonicalizes a path. Uses `dunce` to avoid UNC paths when possible.
     pub fn to_realpath(&self) -> Result<AbsoluteSystemPathBuf, PathError> {
         let realpath = dunce::canonicalize(&self.0)?;
-        Ok(AbsoluteSystemPathBuf(Utf8PathBuf::try_from(realpath)?))
+        // dunce keeps the verbatim prefix on paths longer than MAX_PATH, but
+        // the standard library handles those for us.
+        AbsoluteSystemPathBuf::new(Utf8PathBuf::try_from(realpath)?)
+    }
+
+    /// Returns this path without a Windows verbatim (`\\?\`) prefix if the
+    /// prefix can be removed without changing which file the path refers to.
+    /// Paths are returned unchanged on other platforms.
+    pub fn to_simplified(&self) -> Cow<'_, AbsoluteSystemPath> {
+        #[cfg(windows)]
+        if let Some(simplified) = crate::strip_verbatim_prefix(self.0.as_str()) {
+            return Cow::Owned(AbsoluteSystemPathBuf(simplified.into()));
+        }
+        Cow::Borrowed(self)
     }
 
     /// Gets metadata on path.
@@ -546,6 +560,26 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_long_paths() -> Result<()> {
+        let dir = tempdir::TempDir::new("long-paths")?;
+        let root = AbsoluteSystemPath::from_std_path(dir.path())?;
+        // Deeper than MAX_PATH on Windows
+        let segment = "a".repeat(50);
+        let nested = root.join_components(&[segment.as_str(); 6]);
+        let file = nested.join_component("file.txt");
+        assert!(file.as_str().len() > 260);
+
+        nested.create_dir_all()?;
+        file.create_with_contents("contents")?;
+        assert_eq!(file.read_to_string()?, "contents");
+        assert!(file.symlink_metadata()?.is_file());
+        assert!(!file.to_realpath()?.as_str().starts_with(r"\\?\"));
+        file.remove_file()?;
+
+        Ok(())
+    }
+
     #[test]
     fn test_resolve_empty() {
         let root = AbsoluteSystemPathBuf::cwd().unwrap();
@@ -67,11 +67,18 @@ impl AbsoluteSystemPathBuf {
     /// #[cfg(not(windows))]
     /// assert_eq!(absolute_path.as_path(), Utf8Path::new("/Users/user"));
     /// ```
+    ///
+    /// On Windows, verbatim paths (`\\?\C:\Users\user`) are converted to
+    /// regular paths when that doesn't change their meaning, so that they can
+    /// be compared with paths that came from elsewhere.
     pub fn new(unchecked_path: impl Into<String>) -> Result<Self, PathError> {
         let unchecked_path = unchecked_path.into();
         if !Path::new(&unchecked_path).is_absolute() {
             return Err(PathError::NotAbsolute(unchecked_path));
         }
+        #[cfg(windows)]
+        let unchecked_path =
+            crate::strip_verbatim_prefix(&unchecked_path).unwrap_or(unchecked_path);
         Ok(AbsoluteSystemPathBuf(unchecked_path.into()))
     }
 
@@ -81,7 +88,7 @@ impl AbsoluteSystemPathBuf {
         // we have an absolute system path and an unknown kind of system path.
         let unknown: Utf8PathBuf = unknown.into();
         if unknown.is_absolute() {
-            Self(unknown)
+            Self::new(unknown).expect("path is absolute")
         } else {
             Self(
                 base.as_path()
@@ -101,7 +108,7 @@ impl AbsoluteSystemPathBuf {
 
     pub fn cwd() -> Result<Self, PathError> {
         // TODO(errors): Unwrap current_dir()
-        Ok(Self(Utf8PathBuf::try_from(std::env::current_dir()?)?))
+        Self::new(Utf8PathBuf::try_from(std::env::current_dir()?)?)
     }
 
     /// Anchors `path` at `self`.
@@ -300,4 +307,22 @@ mod tests {
             AbsoluteSystemPathBuf::new("C:\\some\\other").unwrap(),
         );
     }
+
+    #[cfg(windows)]
+    #[test]
+    fn test_verbatim_paths_on_windows() {
+        assert_eq!(
+            AbsoluteSystemPathBuf::new(r"\\?\C:\some\dir").unwrap(),
+            AbsoluteSystemPathBuf::new(r"C:\some\dir").unwrap(),
+        );
+
+        let share = AbsoluteSystemPathBuf::new(r"\\?\UNC\server\share\repo").unwrap();
+        assert_eq!(share.as_str(), r"\\server\share\repo");
+        let package = AbsoluteSystemPathBuf::new(r"\\server\share\repo\apps\web").unwrap();
+        assert_eq!(share.anchor(&package).unwrap().as_str(), r"apps\web");
+
+        // `..` is a literal file name in a verbatim path
+        let literal = AbsoluteSystemPathBuf::new(r"\\?\C:\some\..\dir").unwrap();
+        assert_eq!(literal.as_str(), r"\\?\C:\some\..\dir");
+    }
 }
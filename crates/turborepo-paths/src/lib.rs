@@ -203,6 +203,55 @@ pub(crate) fn check_path(name: &str) -> PathValidation {
     }
 }
 
+// Windows device names that refer to a device rather than a file when they
+// appear in a non-verbatim path, regardless of extension.
+const RESERVED_WINDOWS_NAMES: &[&str] = &[
+    "CON", "PRN", "AUX", "NUL", "COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8",
+    "COM9", "LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+];
+
+/// Converts a Windows verbatim path (`\\?\C:\foo` or `\\?\UNC\server\share`) to
+/// the equivalent regular path. The standard library already adds the
+/// verbatim prefix when a path is too long for the Win32 APIs, so removing
+/// it doesn't prevent long paths from working, but it does let verbatim and
+/// regular paths to the same file compare equal.
+///
+/// Returns `None` if the path isn't verbatim, or if it would mean something
+/// different without the prefix. Verbatim paths are passed to the file
+/// system as is, so `.`, `..`, `/`, trailing dots and spaces, and device
+/// names aren't interpreted the way they are in regular paths.
+pub(crate) fn strip_verbatim_prefix(path: &str) -> Option<String> {
+    let (prefix, rest) = if let Some(rest) = path.strip_prefix(r"\\?\UNC\") {
+        (r"\\", rest)
+    } else if let Some(rest) = path.strip_prefix(r"\\?\") {
+        // Only drive letter paths have a regular equivalent, volume GUID
+        // paths such as `\\?\Volume{...}\` don't.
+        match rest.as_bytes() {
+            [drive, b':'] if drive.is_ascii_alphabetic() => return Some(format!("{rest}\\")),
+            [drive, b':', b'\\', ..] if drive.is_ascii_alphabetic() => ("", rest),
+            _ => return None,
+        }
+    } else {
+        return None;
+    };
+
+    let is_ambiguous = |component: &str| {
+        let stem = component.split('.').next().unwrap_or(component);
+        component == "."
+            || component == ".."
+            || component.ends_with('.')
+            || component.ends_with(' ')
+            || RESERVED_WINDOWS_NAMES
+                .iter()
+                .any(|name| name.eq_ignore_ascii_case(stem.trim_end()))
+    };
+    if rest.contains('/') || rest.split('\\').skip(1).any(is_ambiguous) {
+        return None;
+    }
+
+    Some(format!("{prefix}{rest}"))
+}
+
 pub enum UnknownPathType {
     Absolute(AbsoluteSystemPathBuf),
     Anchored(AnchoredSystemPathBuf),
@@ -225,7 +274,7 @@ pub fn categorize(path: &Utf8Path) -> UnknownPathType {
 mod tests {
     use test_case::test_case;
 
-    use crate::{check_path, IntoUnix, PathValidation};
+    use crate::{check_path, strip_verbatim_prefix, IntoUnix, PathValidation};
 
     #[test]
     fn test_into_unix() {
@@ -278,4 +327,18 @@ mod tests {
         let output = check_path(path);
         assert_eq!(output, expected_output);
     }
+
+    #[test_case(r"\\?\C:\foo\bar", Some(r"C:\foo\bar") ; "drive")]
+    #[test_case(r"\\?\C:", Some(r"C:\") ; "drive root")]
+    #[test_case(r"\\?\UNC\server\share\foo", Some(r"\\server\share\foo") ; "unc")]
+    #[test_case(r"C:\foo\bar", None ; "not verbatim")]
+    #[test_case(r"\\server\share\foo", None ; "regular unc")]
+    #[test_case(r"\\?\C:\foo\..\bar", None ; "parent component")]
+    #[test_case(r"\\?\C:\foo/bar", None ; "forward slash")]
+    #[test_case(r"\\?\C:\foo\bar.", None ; "trailing dot")]
+    #[test_case(r"\\?\C:\foo\nul.txt", None ; "device name")]
+    #[test_case(r"\\?\Volume{1234}\foo", None ; "volume guid")]
+    fn test_strip_verbatim_prefix(path: &str, expected: Option<&str>) {
+        assert_eq!(strip_verbatim_prefix(path).as_deref(), expected);
+    }
 }
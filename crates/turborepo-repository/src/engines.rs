@@ -0,0 +1,143 @@
+//! Checks that the package manager and Node.js that will run tasks match the
+//! versions declared in the root package.json. Without this, a mismatched
+//! version often only shows up as a confusing failure in the middle of a run.
+
+use std::process::Command;
+
+use node_semver::{Range, Version};
+use thiserror::Error;
+use turbopath::AbsoluteSystemPath;
+
+use crate::{package_json::PackageJson, package_manager::PackageManager};
+
+#[derive(Debug, Error)]
+pub enum Error {
+    #[error(
+        "package.json requires {manager}@{expected}, but {manager} {found} is installed. Install \
+         {manager}@{expected} or enable corepack with `corepack enable`"
+    )]
+    PackageManagerMismatch {
+        manager: String,
+        expected: String,
+        found: String,
+    },
+    #[error(
+        "package.json requires node {expected}, but node {found} is installed. Switch to a \
+         version of node that satisfies {expected}"
+    )]
+    NodeMismatch { expected: String, found: String },
+    #[error("unable to find {binary} to check its version: {source}")]
+    MissingBinary {
+        binary: String,
+        #[source]
+        source: which::Error,
+    },
+    #[error("unable to run `{binary} --version`: {reason}")]
+    VersionCheck { binary: String, reason: String },
+    #[error("invalid node version range in package.json engines: {0}")]
+    InvalidRange(String),
+    #[error(transparent)]
+    PackageManager(#[from] crate::package_manager::Error),
+}
+
+/// Verifies the `packageManager` field and `engines.node` range of the root
+/// package.json against the binaries on the `PATH`. Either is skipped if it
+/// isn't set.
+pub fn check(repo_root: &AbsoluteSystemPath, root_package_json: &PackageJson) -> Result<(), Error> {
+    if let Some(package_manager) = &root_package_json.package_manager {
+        let (manager, expected) = PackageManager::parse_package_manager_string(package_manager)?;
+        let found = installed_version(repo_root, manager)?;
+        check_package_manager(manager, expected, &found)?;
+    }
+
+    let engines = root_package_json.engines().unwrap_or_default();
+    if let Some(expected) = engines.get("node") {
+        let found = installed_version(repo_root, "node")?;
+        check_node(expected, &found)?;
+    }
+
+    Ok(())
+}
+
+fn check_package_manager(manager: &str, expected: &str, found: &str) -> Result<(), Error> {
+    let matches = match (Version::parse(expected), Version::parse(found)) {
+        (Ok(expected), Ok(found)) => expected == found,
+        _ => expected == found,
+    };
+    if matches {
+        Ok(())
+    } else {
+        Err(Error::PackageManagerMismatch {
+            manager: manager.to_string(),
+            expected: expected.to_string(),
+            found: found.to_string(),
+        })
+    }
+}
+
+fn check_node(expected: &str, found: &str) -> Result<(), Error> {
+    let range = Range::parse(expected).map_err(|_| Error::InvalidRange(expected.to_string()))?;
+    let version =
+        Version::parse(found.trim_start_matches('v')).map_err(|e| Error::VersionCheck {
+            binary: "node".to_string(),
+            reason: e.to_string(),
+        })?;
+    if range.satisfies(&version) {
+        Ok(())
+    } else {
+        Err(Error::NodeMismatch {
+            expected: expected.to_string(),
+            found: found.to_string(),
+        })
+    }
+}
+
+// Runs from the repository root so version managers, e.g. corepack, pick the
+// same version they would for tasks
+fn installed_version(repo_root: &AbsoluteSystemPath, binary: &str) -> Result<String, Error> {
+    let path = which::which(binary).map_err(|source| Error::MissingBinary {
+        binary: binary.to_string(),
+        source,
+    })?;
+    let version_check_error = |reason: String| Error::VersionCheck {
+        binary: binary.to_string(),
+        reason,
+    };
+    let output = Command::new(path)
+        .arg("--version")
+        .current_dir(repo_root.as_std_path())
+        .output()
+        .map_err(|e| version_check_error(e.to_string()))?;
+    if !output.status.success() {
+        return Err(version_check_error(
+            String::from_utf8_lossy(&output.stderr).trim().to_string(),
+        ));
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+
+    use super::{check_node, check_package_manager};
+
+    #[test_case("8.6.0", "8.6.0", true ; "same version")]
+    #[test_case("8.6.0", "8.7.1", false ; "different version")]
+    #[test_case("4.0.0-rc.1", "4.0.0-rc.1", true ; "prerelease")]
+    fn test_check_package_manager(expected: &str, found: &str, ok: bool) {
+        assert_eq!(check_package_manager("pnpm", expected, found).is_ok(), ok);
+    }
+
+    #[test_case(">=18", "v20.11.0", true ; "satisfies range")]
+    #[test_case("^18.17.0", "v20.11.0", false ; "outside range")]
+    #[test_case("18.x || 20.x", "20.1.0", true ; "without v prefix")]
+    fn test_check_node(expected: &str, found: &str, ok: bool) {
+        assert_eq!(check_node(expected, found).is_ok(), ok);
+    }
+
+    #[test]
+    fn test_invalid_node_range() {
+        assert!(check_node("not a range", "v20.11.0").is_err());
+    }
+}
@@ -3,6 +3,7 @@
 
 pub mod change_mapper;
 pub mod discovery;
+pub mod engines;
 pub mod inference;
 pub mod package_graph;
 pub mod package_json;
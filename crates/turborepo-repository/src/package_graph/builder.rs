@@ -4,7 +4,7 @@ use std::{
 };
 
 use petgraph::graph::{Graph, NodeIndex};
-use tracing::{warn, Instrument};
+use tracing::{debug, warn, Instrument};
 use turbopath::{
     AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
 };
@@ -12,8 +12,10 @@ use turborepo_graph_utils as graph;
 use turborepo_lockfiles::Lockfile;
 
 use super::{
-    dep_splitter::DependencySplitter, npmrc::NpmRc, PackageGraph, PackageInfo, PackageName,
-    PackageNode,
+    dep_splitter::DependencySplitter,
+    npmrc::NpmRc,
+    provider::{self, VirtualPackage, WorkspaceProvider},
+    PackageGraph, PackageInfo, PackageName, PackageNode,
 };
 use crate::{
     discovery::{
@@ -30,9 +32,20 @@ pub struct PackageGraphBuilder<'a, T> {
     is_single_package: bool,
     package_jsons: Option<HashMap<AbsoluteSystemPathBuf, PackageJson>>,
     lockfile: Option<Box<dyn Lockfile>>,
+    cached_dependencies: Option<HashMap<String, CachedDependencies>>,
+    workspace_providers: Vec<WorkspaceProvider>,
     package_discovery: T,
 }
 
+/// The transitive dependencies of a package along with the external
+/// dependencies they were resolved from. These only change when the lockfile
+/// or the package's dependencies do, so they can be reused across builds.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CachedDependencies {
+    pub external_dependencies: HashMap<String, String>,
+    pub transitive_dependencies: HashSet<turborepo_lockfiles::Package>,
+}
+
 #[derive(Debug, thiserror::Error)]
 pub enum Error {
     #[error("could not resolve workspaces: {0}")]
@@ -61,6 +74,8 @@ pub enum Error {
     Lockfile(#[from] turborepo_lockfiles::Error),
     #[error(transparent)]
     Discovery(#[from] crate::discovery::Error),
+    #[error("unable to find workspace members: {0}")]
+    Provider(#[from] provider::Error),
 }
 
 impl<'a> PackageGraphBuilder<'a, LocalPackageDiscoveryBuilder> {
@@ -76,6 +91,8 @@ impl<'a> PackageGraphBuilder<'a, LocalPackageDiscoveryBuilder> {
             is_single_package: false,
             package_jsons: None,
             lockfile: None,
+            cached_dependencies: None,
+            workspace_providers: Vec::new(),
         }
     }
 }
@@ -99,6 +116,24 @@ impl<'a, P> PackageGraphBuilder<'a, P> {
         self
     }
 
+    /// Reuse previously computed transitive dependencies, keyed by the unix
+    /// path of the package. These must have been computed from the same
+    /// lockfile. Packages whose external dependencies have changed since are
+    /// still resolved against the lockfile.
+    pub fn with_cached_dependencies(
+        mut self,
+        cached_dependencies: Option<HashMap<String, CachedDependencies>>,
+    ) -> Self {
+        self.cached_dependencies = cached_dependencies;
+        self
+    }
+
+    /// Also add the members of non-JavaScript workspaces to the graph
+    pub fn with_workspace_providers(mut self, workspace_providers: Vec<WorkspaceProvider>) -> Self {
+        self.workspace_providers = workspace_providers;
+        self
+    }
+
     /// Set the package discovery strategy to use. Note that whatever strategy
     /// selected here will be wrapped in a `CachingPackageDiscovery` to
     /// prevent unnecessary work during building.
@@ -112,6 +147,8 @@ impl<'a, P> PackageGraphBuilder<'a, P> {
             is_single_package: self.is_single_package,
             package_jsons: self.package_jsons,
             lockfile: self.lockfile,
+            cached_dependencies: self.cached_dependencies,
+            workspace_providers: self.workspace_providers,
             package_discovery: discovery,
         }
     }
@@ -148,6 +185,11 @@ struct BuildState<'a, S, T> {
     node_lookup: HashMap<PackageNode, NodeIndex>,
     lockfile: Option<Box<dyn Lockfile>>,
     package_jsons: Option<HashMap<AbsoluteSystemPathBuf, PackageJson>>,
+    cached_dependencies: Option<HashMap<String, CachedDependencies>>,
+    workspace_providers: Vec<WorkspaceProvider>,
+    // The internal dependencies of packages found by workspace providers,
+    // which don't come from a package.json
+    virtual_dependencies: HashMap<PackageName, HashSet<PackageName>>,
     state: std::marker::PhantomData<S>,
     package_discovery: T,
 }
@@ -195,6 +237,8 @@ where
 
             package_jsons,
             lockfile,
+            cached_dependencies,
+            workspace_providers,
             package_discovery,
         } = builder;
         let mut workspaces = HashMap::new();
@@ -214,6 +258,9 @@ where
             workspaces,
             lockfile,
             package_jsons,
+            cached_dependencies,
+            workspace_providers,
+            virtual_dependencies: HashMap::new(),
             workspace_graph: Graph::new(),
             node_lookup: HashMap::new(),
             state: std::marker::PhantomData,
@@ -242,6 +289,42 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedPackageManager, T> {
             package_json_path: relative_json_path,
             ..Default::default()
         };
+        self.add_package(name, entry)
+    }
+
+    fn add_virtual_package(
+        &mut self,
+        provider: WorkspaceProvider,
+        package: VirtualPackage,
+    ) -> Result<(), Error> {
+        let VirtualPackage {
+            name,
+            manifest_path,
+            dependencies,
+            scripts,
+        } = package;
+        let entry = PackageInfo {
+            package_json: PackageJson {
+                name: Some(name.clone()),
+                scripts,
+                ..Default::default()
+            },
+            package_json_path: AnchoredSystemPathBuf::relative_path_between(
+                self.repo_root,
+                &manifest_path,
+            ),
+            provider: Some(provider),
+            ..Default::default()
+        };
+        let name = PackageName::Other(name);
+        self.virtual_dependencies.insert(
+            name.clone(),
+            dependencies.into_iter().map(PackageName::Other).collect(),
+        );
+        self.add_package(name, entry)
+    }
+
+    fn add_package(&mut self, name: PackageName, entry: PackageInfo) -> Result<(), Error> {
         if let Some(existing) = self.workspaces.insert(name.clone(), entry) {
             let path = self
                 .workspaces
@@ -293,6 +376,12 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedPackageManager, T> {
             }
         }
 
+        for provider in std::mem::take(&mut self.workspace_providers) {
+            for package in provider.discover(self.repo_root)? {
+                self.add_virtual_package(provider, package)?;
+            }
+        }
+
         let Self {
             repo_root,
             single,
@@ -300,6 +389,8 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedPackageManager, T> {
             workspace_graph,
             node_lookup,
             lockfile,
+            cached_dependencies,
+            virtual_dependencies,
             package_discovery,
             ..
         } = self;
@@ -310,6 +401,9 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedPackageManager, T> {
             workspace_graph,
             node_lookup,
             lockfile,
+            cached_dependencies,
+            workspace_providers: Vec::new(),
+            virtual_dependencies,
             package_discovery,
             package_jsons: None,
             state: std::marker::PhantomData,
@@ -363,10 +457,12 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedWorkspaces, T> {
             .workspaces
             .iter()
             .map(|(name, entry)| {
-                // TODO avoid clone
-                (
-                    name.clone(),
-                    Dependencies::new(
+                let dependencies = match self.virtual_dependencies.remove(name) {
+                    Some(internal) => Dependencies {
+                        internal,
+                        external: BTreeMap::new(),
+                    },
+                    None => Dependencies::new(
                         self.repo_root,
                         &entry.package_json_path,
                         &self.workspaces,
@@ -374,7 +470,9 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedWorkspaces, T> {
                         npmrc.as_ref(),
                         entry.package_json.all_dependencies(),
                     ),
-                )
+                };
+                // TODO avoid clone
+                (name.clone(), dependencies)
             })
             .collect::<Vec<_>>();
         for (name, deps) in split_deps {
@@ -461,6 +559,7 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedWorkspaces, T> {
             workspaces,
             workspace_graph,
             node_lookup,
+            cached_dependencies,
             package_discovery,
             ..
         } = self;
@@ -471,6 +570,9 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedWorkspaces, T> {
             workspace_graph,
             node_lookup,
             lockfile,
+            cached_dependencies,
+            workspace_providers: Vec::new(),
+            virtual_dependencies: HashMap::new(),
             package_jsons: None,
             state: std::marker::PhantomData,
             package_discovery,
@@ -509,13 +611,29 @@ impl<'a, T: PackageDiscovery> BuildState<'a, ResolvedLockfile, T> {
             return Ok(());
         };
 
+        let mut external_dependencies = self.all_external_dependencies()?;
+        let mut cached_closures = HashMap::new();
+        if let Some(mut cached_dependencies) = self.cached_dependencies.take() {
+            external_dependencies.retain(|workspace, dependencies| {
+                match cached_dependencies.remove(workspace) {
+                    Some(cached) if cached.external_dependencies == *dependencies => {
+                        cached_closures.insert(workspace.clone(), cached.transitive_dependencies);
+                        false
+                    }
+                    _ => true,
+                }
+            });
+            debug!(
+                "reusing transitive dependencies for {} packages",
+                cached_closures.len()
+            );
+        }
+
         // We cannot ignore missing packages in this context, it would indicate a
         // malformed or stale lockfile.
-        let mut closures = turborepo_lockfiles::all_transitive_closures(
-            lockfile,
-            self.all_external_dependencies()?,
-            false,
-        )?;
+        let mut closures =
+            turborepo_lockfiles::all_transitive_closures(lockfile, external_dependencies, false)?;
+        closures.extend(cached_closures);
         for (_, entry) in self.workspaces.iter_mut() {
             entry.transitive_dependencies = closures.remove(&entry.unix_dir_str()?);
         }
@@ -652,4 +770,55 @@ mod test {
         }));
         assert_matches!(builder.build().await, Err(Error::DuplicateWorkspace { .. }));
     }
+
+    #[tokio::test]
+    async fn test_workspace_providers() {
+        let tmp = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        root.join_component("go.work")
+            .create_with_contents("use (\n    ./api\n    ./log\n)\n")
+            .unwrap();
+        for (dir, go_mod) in [
+            (
+                "api",
+                "module example.com/api\nrequire example.com/log v0.0.0\n",
+            ),
+            ("log", "module example.com/log\n"),
+        ] {
+            let path = root.join_components(&[dir, "go.mod"]);
+            path.ensure_dir().unwrap();
+            path.create_with_contents(go_mod).unwrap();
+        }
+
+        let graph = PackageGraphBuilder::new(
+            &root,
+            PackageJson {
+                name: Some("root".into()),
+                ..Default::default()
+            },
+        )
+        .with_package_discovery(MockDiscovery)
+        .with_package_jsons(Some(HashMap::new()))
+        .with_workspace_providers(vec![WorkspaceProvider::Go])
+        .build()
+        .await
+        .unwrap();
+
+        let api = PackageName::from("example.com/api");
+        let info = graph.package_info(&api).unwrap();
+        assert_eq!(info.provider, Some(WorkspaceProvider::Go));
+        assert_eq!(info.package_path(), AnchoredSystemPath::new("api").unwrap());
+        assert_eq!(info.package_json.scripts["test"], "go test ./...");
+        assert_eq!(
+            graph.immediate_dependencies(&PackageNode::Workspace(api)),
+            Some(
+                [PackageNode::Workspace(PackageName::from("example.com/log"))]
+                    .iter()
+                    .collect()
+            )
+        );
+    }
 }
@@ -1,9 +1,6 @@
 use std::{collections::HashMap, fmt};
 
-use turbopath::{
-    AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPath,
-    RelativeUnixPathBuf,
-};
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPath};
 
 use super::{npmrc::NpmRc, PackageInfo, PackageName};
 use crate::package_manager::PackageManager;
@@ -34,6 +31,12 @@ impl<'a> DependencySplitter<'a> {
     }
 
     pub fn is_internal(&self, name: &str, version: &str) -> Option<PackageName> {
+        let dependency_version = DependencyVersion::new(version);
+        // Package managers always link a dependency on a directory, regardless of
+        // link_workspace_packages, so it's internal if the directory is in the repo
+        if let Some(path) = dependency_version.directory() {
+            return self.find_linked_package(name, path);
+        }
         // If link_workspace_packages isn't set any version wihtout workspace protocol
         // is considered external.
         if !self.link_workspace_packages && !version.starts_with("workspace:") {
@@ -42,12 +45,10 @@ impl<'a> DependencySplitter<'a> {
         let workspace_specifier = WorkspacePackageSpecifier::new(version)
             .unwrap_or(WorkspacePackageSpecifier::Alias(name));
         let (workspace_name, info) = self.find_package(workspace_specifier)?;
-        let is_internal = DependencyVersion::new(version).matches_workspace_package(
+        let is_internal = dependency_version.matches_workspace_package(
             // This is the current Go behavior, in the future we might not want to paper over a
             // missing version
             info.package_json.version.as_deref().unwrap_or_default(),
-            self.workspace_dir,
-            self.repo_root,
         );
 
         match is_internal {
@@ -87,6 +88,24 @@ impl<'a> DependencySplitter<'a> {
         }
     }
 
+    // Find the package that a `file:` or `link:` dependency points at. This is
+    // the package in that directory, which may have a different name than the
+    // dependency.
+    fn find_linked_package(&self, name: &str, path: &RelativeUnixPath) -> Option<PackageName> {
+        let package_path = self.workspace_dir.join_unix_path(path);
+        // Directories outside of the repository can't be workspace packages
+        let package_path = AnchoredSystemPathBuf::new(self.repo_root, package_path).ok()?;
+        if let Some((name, _)) = self.workspace(&package_path) {
+            return Some(name.clone());
+        }
+        // The path may point at a tarball or a file within the repository, in
+        // which case we defer to the name of the dependency
+        let package_name = PackageName::Other(name.to_string());
+        self.workspaces
+            .contains_key(&package_name)
+            .then_some(package_name)
+    }
+
     fn workspace(&self, path: &AnchoredSystemPath) -> Option<(&PackageName, &PackageInfo)> {
         self.workspaces
             .iter()
@@ -137,6 +156,20 @@ impl<'a> DependencyVersion<'a> {
         )
     }
 
+    // The path of a dependency on a local directory. npm treats relative paths
+    // without a protocol as `file:` dependencies, and yarn's `portal:` protocol
+    // is the equivalent of `link:`.
+    fn directory(&self) -> Option<&'a RelativeUnixPath> {
+        let path = match self.protocol {
+            Some("file") | Some("link") | Some("portal") => self.version,
+            None if self.version.starts_with("./") || self.version.starts_with("../") => {
+                self.version
+            }
+            _ => return None,
+        };
+        RelativeUnixPath::new(path).ok()
+    }
+
     fn is_external(&self) -> bool {
         // The npm protocol for yarn by default still uses the workspace package if the
         // workspace version is in a compatible semver range. See https://github.com/yarnpkg/berry/discussions/4015
@@ -148,12 +181,7 @@ impl<'a> DependencyVersion<'a> {
         self.protocol.map_or(false, |p| p != "npm")
     }
 
-    fn matches_workspace_package(
-        &self,
-        package_version: &str,
-        cwd: &AbsoluteSystemPath,
-        root: &AbsoluteSystemPath,
-    ) -> bool {
+    fn matches_workspace_package(&self, package_version: &str) -> bool {
         match self.protocol {
             Some("workspace") => {
                 // TODO: Since support at the moment is non-existent for workspaces that contain
@@ -161,12 +189,6 @@ impl<'a> DependencyVersion<'a> {
                 // match and don't check the range for an exact match.
                 true
             }
-            Some("file") | Some("link") => {
-                // Default to internal if we have the package but somehow cannot get the path
-                RelativeUnixPathBuf::new(self.version)
-                    .map(|file_path| cwd.join_unix_path(file_path))
-                    .map_or(true, |dep_path| root.contains(&dep_path))
-            }
             Some(_) if self.is_external() => {
                 // Other protocols are assumed to be external references ("github:", etc)
                 false
@@ -230,12 +252,18 @@ mod test {
     #[test_case("1.2.3", None, "file:../../../otherproject", None, true ; "handles file:.. outside repo")]
     #[test_case("1.2.3", None, "link:../libB", Some("@scope/foo"), true ; "handles link:.. inside repo")]
     #[test_case("1.2.3", None, "link:../../../otherproject", None, true ; "handles link:.. outside repo")]
+    #[test_case("1.2.3", Some("foo"), "link:../bar", Some("bar"), true ; "handles link: to differing package")]
+    #[test_case("1.2.3", Some("foo"), "file:../baz", Some("baz"), true ; "handles file: to differing package")]
+    #[test_case("1.2.3", Some("foo"), "portal:../baz", Some("baz"), true ; "handles yarn portal protocol")]
+    #[test_case("1.2.3", Some("foo"), "../baz", Some("baz"), true ; "handles relative path without protocol")]
+    #[test_case("1.2.3", Some("foo"), "file:../baz.tgz", None, true ; "handles file: tarball")]
     #[test_case("0.0.0-development", None, "*", Some("@scope/foo"), true ; "handles development versions")]
     #[test_case("1.2.3", Some("foo"), "workspace:@scope/foo@*", Some("@scope/foo"), true ; "handles pnpm alias star")]
     #[test_case("1.2.3", Some("foo"), "workspace:@scope/foo@~", Some("@scope/foo"), true ; "handles pnpm alias tilda")]
     #[test_case("1.2.3", Some("foo"), "workspace:@scope/foo@^", Some("@scope/foo"), true ; "handles pnpm alias caret")]
     #[test_case("1.2.3", None, "1.2.3", None, false ; "no workspace linking")]
     #[test_case("1.2.3", None, "workspace:1.2.3", Some("@scope/foo"), false ; "no workspace linking with protocol")]
+    #[test_case("1.2.3", Some("foo"), "link:../bar", Some("bar"), false ; "no workspace linking with link protocol")]
     fn test_matches_workspace_package(
         package_version: &str,
         dependency_name: Option<&str>,
@@ -266,6 +294,7 @@ mod test {
                     .unwrap(),
                     unresolved_external_dependencies: None,
                     transitive_dependencies: None,
+                    provider: None,
                 },
             );
             map.insert(
@@ -281,6 +310,7 @@ mod test {
                     .unwrap(),
                     unresolved_external_dependencies: None,
                     transitive_dependencies: None,
+                    provider: None,
                 },
             );
             map.insert(
@@ -296,6 +326,7 @@ mod test {
                     .unwrap(),
                     unresolved_external_dependencies: None,
                     transitive_dependencies: None,
+                    provider: None,
                 },
             );
             map
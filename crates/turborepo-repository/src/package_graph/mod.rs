@@ -20,8 +20,10 @@ use crate::{
 pub mod builder;
 mod dep_splitter;
 mod npmrc;
+pub mod provider;
 
-pub use builder::{Error, PackageGraphBuilder};
+pub use builder::{CachedDependencies, Error, PackageGraphBuilder};
+pub use provider::WorkspaceProvider;
 
 pub const ROOT_PKG_NAME: &str = "//";
 
@@ -64,6 +66,10 @@ pub struct PackageInfo {
     pub package_json_path: AnchoredSystemPathBuf,
     pub unresolved_external_dependencies: Option<BTreeMap<PackageKey, PackageVersion>>, /* name -> version */
     pub transitive_dependencies: Option<HashSet<turborepo_lockfiles::Package>>,
+    /// The provider that found the package if it isn't a JavaScript package.
+    /// Its `package_json` is then made up from the manifest at
+    /// `package_json_path`.
+    pub provider: Option<WorkspaceProvider>,
 }
 
 impl PackageInfo {
@@ -198,6 +204,30 @@ impl PackageGraph {
         self.packages.iter()
     }
 
+    /// The transitive dependencies of every package keyed by the unix path of
+    /// the package, see `PackageGraphBuilder::with_cached_dependencies`
+    pub fn cached_dependencies(&self) -> HashMap<String, CachedDependencies> {
+        self.packages
+            .values()
+            .filter_map(|info| {
+                let transitive_dependencies = info.transitive_dependencies.clone()?;
+                let external_dependencies = info
+                    .unresolved_external_dependencies
+                    .iter()
+                    .flatten()
+                    .map(|(name, version)| (name.clone(), version.clone()))
+                    .collect();
+                Some((
+                    info.package_path().to_unix().to_string(),
+                    CachedDependencies {
+                        external_dependencies,
+                        transitive_dependencies,
+                    },
+                ))
+            })
+            .collect()
+    }
+
     pub fn root_package_json(&self) -> &PackageJson {
         self.package_json(&PackageName::Root)
             .expect("package graph was built without root package.json")
@@ -771,6 +801,66 @@ mod test {
         );
     }
 
+    #[tokio::test]
+    async fn test_cached_dependencies() {
+        let root =
+            AbsoluteSystemPathBuf::new(if cfg!(windows) { r"C:\repo" } else { "/repo" }).unwrap();
+        let package_jsons = || {
+            let mut map = HashMap::new();
+            for (dir, name, dependency) in [("package_a", "foo", "a"), ("package_b", "bar", "b")] {
+                map.insert(
+                    root.join_components(&[dir, "package.json"]),
+                    PackageJson::from_value(json!({
+                        "name": name,
+                        "dependencies": { dependency: "1" }
+                    }))
+                    .unwrap(),
+                );
+            }
+            map
+        };
+        let build = |cached_dependencies| {
+            PackageGraph::builder(
+                &root,
+                PackageJson::from_value(json!({ "name": "root" })).unwrap(),
+            )
+            .with_package_discovery(MockDiscovery)
+            .with_package_jsons(Some(package_jsons()))
+            .with_lockfile(Some(Box::new(MockLockfile {})))
+            .with_cached_dependencies(cached_dependencies)
+            .build()
+        };
+
+        let mut cached = build(None).await.unwrap().cached_dependencies();
+        assert_eq!(
+            cached["package_a"].external_dependencies,
+            HashMap::from([("a".to_string(), "1".to_string())])
+        );
+        let z = turborepo_lockfiles::Package::new("key:z", "1");
+        cached.get_mut("package_a").unwrap().transitive_dependencies =
+            HashSet::from_iter([z.clone()]);
+        // Stale entries are resolved against the lockfile again
+        let stale = cached.get_mut("package_b").unwrap();
+        stale.external_dependencies = HashMap::from([("c".to_string(), "1".to_string())]);
+        stale.transitive_dependencies = HashSet::from_iter([z.clone()]);
+
+        let pkg_graph = build(Some(cached)).await.unwrap();
+        let deps = |name: &str| {
+            pkg_graph
+                .package_info(&PackageName::from(name))
+                .and_then(|info| info.transitive_dependencies.clone())
+                .unwrap()
+        };
+        assert_eq!(deps("foo"), HashSet::from_iter([z]));
+        assert_eq!(
+            deps("bar"),
+            HashSet::from_iter([
+                turborepo_lockfiles::Package::new("key:b", "1"),
+                turborepo_lockfiles::Package::new("key:c", "1"),
+            ])
+        );
+    }
+
     #[tokio::test]
     async fn test_circular_dependency() {
         let root =
@@ -0,0 +1,530 @@
+//! Workspace providers add the members of workspaces that aren't managed by a
+//! JavaScript package manager to the package graph. These virtual packages
+//! don't have a package.json, so the provider defines the scripts that can be
+//! configured as tasks in turbo.json.
+//!
+//! The manifests are only read as far as needed to find the members of the
+//! workspace, their names and which of them depend on each other.
+
+use std::{
+    collections::{BTreeMap, BTreeSet},
+    fmt,
+    str::FromStr,
+};
+
+use globwalk::{GlobError, ValidatedGlob, WalkError, WalkType};
+use serde::Deserialize;
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub enum WorkspaceProvider {
+    /// Go modules listed in the `use` directives of `go.work`
+    Go,
+    /// Cargo packages listed in the `members` of the root `Cargo.toml`
+    Cargo,
+}
+
+#[derive(Debug, thiserror::Error)]
+pub enum Error {
+    #[error("unknown workspace provider `{0}`. Expected `go` or `cargo`")]
+    Unknown(String),
+    #[error("unable to read {path}: {source}")]
+    Io {
+        path: AbsoluteSystemPathBuf,
+        source: std::io::Error,
+    },
+    #[error("unable to parse {path}: {source}")]
+    Toml {
+        path: AbsoluteSystemPathBuf,
+        source: toml::de::Error,
+    },
+    #[error("{path} is missing the name of its package")]
+    MissingName { path: AbsoluteSystemPathBuf },
+    #[error("invalid workspace member `{0}`: {1}")]
+    Glob(String, #[source] GlobError),
+    #[error(transparent)]
+    Walk(#[from] WalkError),
+}
+
+/// A member of a workspace that was found by a `WorkspaceProvider`
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct VirtualPackage {
+    pub name: String,
+    /// The manifest of the package, e.g. its `go.mod`
+    pub manifest_path: AbsoluteSystemPathBuf,
+    /// The other members of the workspace that the package depends on
+    pub dependencies: Vec<String>,
+    pub scripts: BTreeMap<String, String>,
+}
+
+impl WorkspaceProvider {
+    /// The name of the manifest that each member of the workspace has
+    pub fn manifest(&self) -> &'static str {
+        match self {
+            WorkspaceProvider::Go => "go.mod",
+            WorkspaceProvider::Cargo => "Cargo.toml",
+        }
+    }
+
+    /// The lockfile at the root of the repository that pins the workspace's
+    /// external dependencies
+    pub fn lockfile(&self) -> &'static str {
+        match self {
+            WorkspaceProvider::Go => "go.work.sum",
+            WorkspaceProvider::Cargo => "Cargo.lock",
+        }
+    }
+
+    // The file at the root of the repository that lists its members
+    fn workspace_file(&self) -> &'static str {
+        match self {
+            WorkspaceProvider::Go => "go.work",
+            WorkspaceProvider::Cargo => "Cargo.toml",
+        }
+    }
+
+    /// Finds the members of the workspace. A repository without the
+    /// provider's workspace file has no members.
+    pub fn discover(&self, repo_root: &AbsoluteSystemPath) -> Result<Vec<VirtualPackage>, Error> {
+        let workspace_path = repo_root.join_component(self.workspace_file());
+        let Some(workspace) = read(&workspace_path)? else {
+            return Ok(Vec::new());
+        };
+        // Only Cargo has a root manifest, which members can inherit fields from
+        let (members, exclusions, root_manifest) = match self {
+            WorkspaceProvider::Go => (go_work_members(&workspace), Vec::new(), None),
+            WorkspaceProvider::Cargo => {
+                let manifest = CargoManifest::parse(&workspace_path, &workspace)?;
+                let (members, exclusions) = manifest
+                    .workspace
+                    .as_ref()
+                    .map(|workspace| (workspace.members.clone(), workspace.exclude.clone()))
+                    .unwrap_or_default();
+                (members, exclusions, Some(manifest))
+            }
+        };
+        let manifest_globs = |dirs: Vec<String>| {
+            dirs.into_iter()
+                .map(|dir| {
+                    let glob = format!("{}/{}", dir.trim_end_matches('/'), self.manifest());
+                    ValidatedGlob::from_str(&glob).map_err(|e| Error::Glob(dir, e))
+                })
+                .collect::<Result<Vec<_>, _>>()
+        };
+        let mut manifest_paths = globwalk::globwalk(
+            repo_root,
+            &manifest_globs(members)?,
+            &manifest_globs(exclusions)?,
+            WalkType::Files,
+        )?
+        .into_iter()
+        .collect::<Vec<_>>();
+        manifest_paths.sort();
+
+        let mut manifests = Vec::new();
+        for manifest_path in manifest_paths {
+            let Some(contents) = read(&manifest_path)? else {
+                continue;
+            };
+            let (name, requirements) = match &root_manifest {
+                Some(root_manifest) => {
+                    let manifest = CargoManifest::parse(&manifest_path, &contents)?;
+                    (manifest.name(root_manifest), manifest.dependencies())
+                }
+                None => go_mod(&contents),
+            };
+            let name = name.ok_or_else(|| Error::MissingName {
+                path: manifest_path.clone(),
+            })?;
+            manifests.push((name, manifest_path, requirements));
+        }
+
+        let names = manifests
+            .iter()
+            .map(|(name, ..)| name.clone())
+            .collect::<Vec<_>>();
+        Ok(manifests
+            .into_iter()
+            .map(|(name, manifest_path, requirements)| VirtualPackage {
+                scripts: self.scripts(&name),
+                dependencies: requirements
+                    .into_iter()
+                    .filter(|requirement| *requirement != name && names.contains(requirement))
+                    .collect(),
+                name,
+                manifest_path,
+            })
+            .collect())
+    }
+
+    fn scripts(&self, name: &str) -> BTreeMap<String, String> {
+        let scripts = match self {
+            WorkspaceProvider::Go => [
+                ("build", "go build ./...".to_string()),
+                ("test", "go test ./...".to_string()),
+            ],
+            WorkspaceProvider::Cargo => [
+                ("build", format!("cargo build -p {name}")),
+                ("test", format!("cargo test -p {name}")),
+            ],
+        };
+        scripts
+            .into_iter()
+            .map(|(script, command)| (script.to_string(), command))
+            .collect()
+    }
+}
+
+impl FromStr for WorkspaceProvider {
+    type Err = Error;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s {
+            "go" => Ok(WorkspaceProvider::Go),
+            "cargo" => Ok(WorkspaceProvider::Cargo),
+            _ => Err(Error::Unknown(s.to_string())),
+        }
+    }
+}
+
+impl fmt::Display for WorkspaceProvider {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            WorkspaceProvider::Go => write!(f, "go"),
+            WorkspaceProvider::Cargo => write!(f, "cargo"),
+        }
+    }
+}
+
+fn read(path: &AbsoluteSystemPath) -> Result<Option<String>, Error> {
+    path.read_existing_to_string().map_err(|source| Error::Io {
+        path: path.to_owned(),
+        source,
+    })
+}
+
+// Splits a go.work or go.mod into the directives it contains, e.g. `use ./api`
+// or a `use` directive with a block of several directories
+fn go_directives(contents: &str) -> Vec<(&str, &str)> {
+    let mut directives = Vec::new();
+    let mut block = None;
+    for line in contents.lines() {
+        let line = line.split("//").next().unwrap_or_default().trim();
+        if line.is_empty() {
+            continue;
+        }
+        match block {
+            Some(_) if line == ")" => block = None,
+            Some(directive) => directives.push((directive, line)),
+            None => {
+                let (directive, rest) = line.split_once(char::is_whitespace).unwrap_or((line, ""));
+                match rest.trim() {
+                    "(" => block = Some(directive),
+                    rest => directives.push((directive, rest)),
+                }
+            }
+        }
+    }
+    directives
+}
+
+fn go_work_members(contents: &str) -> Vec<String> {
+    go_directives(contents)
+        .into_iter()
+        .filter(|(directive, _)| *directive == "use")
+        .map(|(_, dir)| {
+            let dir = dir.trim_matches('"');
+            dir.strip_prefix("./").unwrap_or(dir).to_string()
+        })
+        .collect()
+}
+
+// Returns the module path and the modules it requires
+fn go_mod(contents: &str) -> (Option<String>, Vec<String>) {
+    let mut module = None;
+    let mut requirements = Vec::new();
+    for (directive, arguments) in go_directives(contents) {
+        let first_argument = arguments
+            .split_whitespace()
+            .next()
+            .unwrap_or_default()
+            .trim_matches('"')
+            .to_string();
+        match directive {
+            "module" => module = Some(first_argument),
+            "require" => requirements.push(first_argument),
+            _ => {}
+        }
+    }
+    (module, requirements)
+}
+
+// The parts of a Cargo.toml that are needed to find the members of the
+// workspace, their names and which of them depend on each other
+#[derive(Debug, Default, Deserialize)]
+struct CargoManifest {
+    workspace: Option<CargoWorkspace>,
+    package: Option<CargoPackage>,
+    #[serde(default)]
+    dependencies: BTreeMap<String, CargoDependency>,
+    #[serde(default, rename = "build-dependencies")]
+    build_dependencies: BTreeMap<String, CargoDependency>,
+    // Platform specific dependencies, e.g. `[target.'cfg(unix)'.dependencies]`
+    #[serde(default)]
+    target: BTreeMap<String, CargoTarget>,
+}
+
+#[derive(Debug, Default, Deserialize)]
+struct CargoWorkspace {
+    #[serde(default)]
+    members: Vec<String>,
+    #[serde(default)]
+    exclude: Vec<String>,
+    package: Option<CargoPackage>,
+}
+
+#[derive(Debug, Default, Deserialize)]
+struct CargoPackage {
+    name: Option<CargoField>,
+}
+
+// A field that is either set in the manifest or inherited from the
+// `[workspace.package]` of the root manifest with `field.workspace = true`
+#[derive(Debug, Deserialize)]
+#[serde(untagged)]
+enum CargoField {
+    Value(String),
+    Inherited { workspace: bool },
+}
+
+// Dev-dependencies aren't included, they're only needed for tests and
+// members commonly use each other in their tests
+#[derive(Debug, Default, Deserialize)]
+struct CargoTarget {
+    #[serde(default)]
+    dependencies: BTreeMap<String, CargoDependency>,
+    #[serde(default, rename = "build-dependencies")]
+    build_dependencies: BTreeMap<String, CargoDependency>,
+}
+
+#[derive(Debug, Deserialize)]
+#[serde(untagged)]
+enum CargoDependency {
+    Version(String),
+    // A renamed dependency has the name of the package in `package`
+    Detailed { package: Option<String> },
+}
+
+impl CargoManifest {
+    fn parse(path: &AbsoluteSystemPath, contents: &str) -> Result<Self, Error> {
+        toml::from_str(contents).map_err(|source| Error::Toml {
+            path: path.to_owned(),
+            source,
+        })
+    }
+
+    fn name(&self, root_manifest: &CargoManifest) -> Option<String> {
+        match self.package.as_ref()?.name.as_ref()? {
+            CargoField::Value(name) => Some(name.clone()),
+            CargoField::Inherited { workspace: true } => {
+                match root_manifest
+                    .workspace
+                    .as_ref()?
+                    .package
+                    .as_ref()?
+                    .name
+                    .as_ref()?
+                {
+                    CargoField::Value(name) => Some(name.clone()),
+                    CargoField::Inherited { .. } => None,
+                }
+            }
+            CargoField::Inherited { workspace: false } => None,
+        }
+    }
+
+    // The names of the packages that this one needs to be built
+    fn dependencies(&self) -> Vec<String> {
+        self.dependencies
+            .iter()
+            .chain(&self.build_dependencies)
+            .chain(
+                self.target.values().flat_map(|target| {
+                    target.dependencies.iter().chain(&target.build_dependencies)
+                }),
+            )
+            .map(|(key, dependency)| match dependency {
+                CargoDependency::Detailed {
+                    package: Some(package),
+                } => package.clone(),
+                _ => key.clone(),
+            })
+            .collect::<BTreeSet<_>>()
+            .into_iter()
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod test {
+    use turbopath::AbsoluteSystemPathBuf;
+
+    use super::*;
+
+    fn tmp_dir() -> (tempfile::TempDir, AbsoluteSystemPathBuf) {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let dir = AbsoluteSystemPathBuf::try_from(tmp_dir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        (tmp_dir, dir)
+    }
+
+    fn dependencies(packages: &[VirtualPackage]) -> Vec<(&str, Vec<String>)> {
+        packages
+            .iter()
+            .map(|package| (package.name.as_str(), package.dependencies.clone()))
+            .collect()
+    }
+
+    #[test]
+    fn test_go_workspace() {
+        let (_tmp, repo_root) = tmp_dir();
+        repo_root
+            .join_component("go.work")
+            .create_with_contents(
+                "go 1.22
+
+use (
+    ./services/api
+    ./libs/log // logging
+)
+",
+            )
+            .unwrap();
+        let api = repo_root.join_components(&["services", "api", "go.mod"]);
+        api.ensure_dir().unwrap();
+        api.create_with_contents(
+            "module example.com/api
+
+require (
+    example.com/log v0.0.0
+    github.com/google/uuid v1.6.0
+)
+",
+        )
+        .unwrap();
+        let log = repo_root.join_components(&["libs", "log", "go.mod"]);
+        log.ensure_dir().unwrap();
+        log.create_with_contents("module example.com/log\n")
+            .unwrap();
+
+        let packages = WorkspaceProvider::Go.discover(&repo_root).unwrap();
+
+        assert_eq!(
+            dependencies(&packages),
+            vec![
+                ("example.com/log", vec![]),
+                ("example.com/api", vec!["example.com/log".to_string()]),
+            ]
+        );
+        assert_eq!(packages[1].manifest_path, api);
+        assert_eq!(packages[1].scripts["build"], "go build ./...");
+    }
+
+    #[test]
+    fn test_cargo_workspace() {
+        let (_tmp, repo_root) = tmp_dir();
+        repo_root
+            .join_component("Cargo.toml")
+            .create_with_contents(
+                r#"[workspace]
+members = [
+    "crates/*", # libraries
+]
+exclude = ["crates/scratch"]
+
+[workspace.package]
+name = "build"
+"#,
+            )
+            .unwrap();
+        let manifests = [
+            (
+                "cli",
+                r#"[package]
+name = "cli"
+
+[dependencies]
+core = { path = "../core" }
+clap = "4"
+
+[target.'cfg(unix)'.build-dependencies]
+build-support = { path = "../build", package = "build" }
+"#,
+            ),
+            (
+                "core",
+                r#"[package]
+name = "core"
+
+[dev-dependencies.cli]
+path = "../cli"
+"#,
+            ),
+            (
+                "build",
+                r#"[package]
+name.workspace = true
+"#,
+            ),
+            ("scratch", "[package]\nname = \"scratch\"\n"),
+        ];
+        for (dir, manifest) in manifests {
+            let path = repo_root.join_components(&["crates", dir, "Cargo.toml"]);
+            path.ensure_dir().unwrap();
+            path.create_with_contents(manifest).unwrap();
+        }
+
+        let packages = WorkspaceProvider::Cargo.discover(&repo_root).unwrap();
+
+        assert_eq!(
+            dependencies(&packages),
+            vec![
+                ("build", vec![]),
+                ("cli", vec!["build".to_string(), "core".to_string()]),
+                ("core", vec![]),
+            ]
+        );
+        assert_eq!(packages[1].scripts["test"], "cargo test -p cli");
+    }
+
+    #[test]
+    fn test_cargo_missing_name() {
+        let (_tmp, repo_root) = tmp_dir();
+        repo_root
+            .join_component("Cargo.toml")
+            .create_with_contents("[workspace]\nmembers = [\"app\"]\n")
+            .unwrap();
+        let app = repo_root.join_components(&["app", "Cargo.toml"]);
+        app.ensure_dir().unwrap();
+        app.create_with_contents("[package]\nname.workspace = true\n")
+            .unwrap();
+
+        assert!(matches!(
+            WorkspaceProvider::Cargo.discover(&repo_root),
+            Err(Error::MissingName { path }) if path == app
+        ));
+    }
+
+    #[test]
+    fn test_no_workspace() {
+        let (_tmp, repo_root) = tmp_dir();
+
+        assert_eq!(WorkspaceProvider::Go.discover(&repo_root).unwrap(), vec![]);
+        assert_eq!(
+            WorkspaceProvider::Cargo.discover(&repo_root).unwrap(),
+            vec![]
+        );
+    }
+}
@@ -95,6 +95,18 @@ impl PackageJson {
                 .collect(),
         )
     }
+
+    /// Returns the tags listed under `turbo.tags`, which can be used to
+    /// select packages with `--filter=tag:<tag>`
+    pub fn tags(&self) -> impl Iterator<Item = &str> + '_ {
+        self.other
+            .get("turbo")
+            .and_then(|turbo| turbo.get("tags"))
+            .and_then(Value::as_array)
+            .into_iter()
+            .flatten()
+            .filter_map(Value::as_str)
+    }
 }
 
 impl FromStr for PackageJson {
@@ -122,4 +134,13 @@ mod test {
         let actual = serde_json::to_value(package_json).unwrap();
         assert_eq!(actual, json);
     }
+
+    #[test_case(json!({"name": "foo"}), &[] ; "no turbo field")]
+    #[test_case(json!({"name": "foo", "turbo": {"tags": ["web", "frontend"]}}), &["web", "frontend"] ; "tags")]
+    #[test_case(json!({"name": "foo", "turbo": {"tags": ["web", 1]}}), &["web"] ; "non string tags")]
+    #[test_case(json!({"name": "foo", "turbo": {"tags": "web"}}), &[] ; "tags not an array")]
+    fn test_tags(json: Value, expected: &[&str]) {
+        let package_json = PackageJson::from_value(json).unwrap();
+        assert_eq!(package_json.tags().collect::<Vec<_>>(), expected);
+    }
 }
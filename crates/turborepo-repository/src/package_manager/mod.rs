@@ -5,18 +5,23 @@ mod yarn;
 
 use std::{
     backtrace,
+    collections::{HashMap, HashSet},
     fmt::{self, Display},
     fs,
+    path::{Path, PathBuf},
     process::Command,
     str::FromStr,
+    sync::{Arc, Mutex},
 };
 
 use globwalk::{fix_glob_pattern, ValidatedGlob};
+use ignore::{gitignore::Gitignore, Match, WalkState};
 use itertools::{Either, Itertools};
 use lazy_regex::{lazy_regex, Lazy};
 use regex::Regex;
 use serde::{Deserialize, Serialize};
 use thiserror::Error;
+use tracing::{debug, warn};
 use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, PathError, RelativeUnixPath};
 use turborepo_lockfiles::Lockfile;
 use wax::{Any, Glob, Program};
@@ -206,6 +211,239 @@ impl WorkspaceGlobs {
     }
 }
 
+fn is_glob(pattern: &str) -> bool {
+    pattern.contains(['*', '?', '[', '{'])
+}
+
+// Finds the package.json files matched by workspace globs. Unlike globwalk,
+// this honors .gitignore files, including nested ones, and doesn't descend into
+// ignored trees or directories that no glob can match. Directories are walked
+// in parallel.
+struct GlobbedWorkspaces {
+    repo_root: PathBuf,
+    inclusions: Any<'static>,
+    exclusions: Any<'static>,
+    // The leading components of each glob without any glob syntax, along with
+    // the maximum depth of a match if the glob has a fixed depth
+    prefixes: Vec<(Vec<String>, Option<usize>)>,
+    // The .gitignore of each directory that has been visited
+    gitignores: Mutex<HashMap<PathBuf, Arc<Gitignore>>>,
+    // The package.json files of workspaces that were skipped because they're
+    // ignored, which are reported once the walk is done
+    ignored: Mutex<Vec<String>>,
+}
+
+impl GlobbedWorkspaces {
+    fn new(
+        repo_root: &AbsoluteSystemPath,
+        inclusions: &[ValidatedGlob],
+        exclusions: &[ValidatedGlob],
+    ) -> Result<Self, Error> {
+        let inclusions = inclusions
+            .iter()
+            .map(|glob| Self::normalize(glob.as_str()))
+            .collect::<Vec<_>>();
+        let prefixes = inclusions
+            .iter()
+            .map(|glob| {
+                let components = glob.split('/').collect::<Vec<_>>();
+                let prefix = components
+                    .iter()
+                    .take_while(|component| !is_glob(component))
+                    .map(|component| component.to_string())
+                    .collect();
+                let depth = (!glob.contains("**")).then_some(components.len());
+                (prefix, depth)
+            })
+            .collect();
+        // Matches the directory as well as what's inside of it, like globwalk
+        let exclusions = exclusions
+            .iter()
+            .map(|glob| Self::normalize(glob.as_str()))
+            .flat_map(|glob| {
+                let glob = glob.trim_end_matches('/');
+                match glob.strip_suffix("/**") {
+                    Some(dir) => [dir.to_string(), glob.to_string()],
+                    None => [glob.to_string(), format!("{glob}/**")],
+                }
+            })
+            .collect::<Vec<_>>();
+        Ok(Self {
+            repo_root: repo_root.as_std_path().to_owned(),
+            inclusions: Self::any(inclusions)?,
+            exclusions: Self::any(exclusions)?,
+            prefixes,
+            gitignores: Mutex::default(),
+            ignored: Mutex::default(),
+        })
+    }
+
+    fn normalize(glob: &str) -> String {
+        let glob = fix_glob_pattern(glob);
+        glob.strip_prefix("./").unwrap_or(&glob).to_string()
+    }
+
+    fn any(globs: Vec<String>) -> Result<Any<'static>, Error> {
+        let compiled = globs
+            .iter()
+            .map(glob_with_contextual_error)
+            .collect::<Result<Vec<_>, _>>()?;
+        any_with_contextual_error(compiled, globs)
+    }
+
+    // Whether a glob can match something inside of `dir`
+    fn can_contain_match(&self, dir: &[&str]) -> bool {
+        self.prefixes.iter().any(|(prefix, depth)| {
+            prefix
+                .iter()
+                .zip(dir)
+                .all(|(expected, actual)| expected == actual)
+                && depth.map_or(true, |depth| dir.len() < depth)
+        })
+    }
+
+    fn gitignore(&self, dir: &Path) -> Arc<Gitignore> {
+        if let Some(gitignore) = self.gitignores.lock().expect("lock poisoned").get(dir) {
+            return gitignore.clone();
+        }
+        let path = dir.join(".gitignore");
+        let gitignore = if path.is_file() {
+            let (gitignore, error) = Gitignore::new(&path);
+            if let Some(error) = error {
+                debug!("unable to read {}: {error}", path.display());
+            }
+            gitignore
+        } else {
+            Gitignore::empty()
+        };
+        self.gitignores
+            .lock()
+            .expect("lock poisoned")
+            .entry(dir.to_owned())
+            .or_insert_with(|| Arc::new(gitignore))
+            .clone()
+    }
+
+    // Like git, the closest .gitignore with a matching pattern decides whether
+    // the path is ignored. Ignored directories aren't descended into, so the
+    // ancestors of the path are known to not be ignored.
+    fn is_ignored(&self, path: &Path, is_dir: bool) -> bool {
+        for dir in path
+            .ancestors()
+            .skip(1)
+            .take_while(|dir| dir.starts_with(&self.repo_root))
+        {
+            match self.gitignore(dir).matched(path, is_dir) {
+                Match::Ignore(_) => return true,
+                Match::Whitelist(_) => return false,
+                Match::None => (),
+            }
+        }
+        false
+    }
+
+    // Whether the walk should visit `path`, either to descend into it or
+    // because it's a package.json that one of the globs matches
+    fn should_visit(&self, path: &Path, is_dir: bool) -> bool {
+        let Ok(relative) = path.strip_prefix(&self.repo_root) else {
+            return true;
+        };
+        if relative.as_os_str().is_empty() {
+            return true;
+        }
+        if is_dir && relative.ends_with(".git") {
+            return false;
+        }
+        let Some(relative) = relative.to_str() else {
+            return false;
+        };
+        let relative = relative.replace(std::path::MAIN_SEPARATOR, "/");
+        if self.exclusions.is_match(relative.as_str()) {
+            return false;
+        }
+        // Only the package.json of the ignored directory itself is checked for
+        // the warning, since looking any deeper would mean walking the tree
+        let package_json = match is_dir {
+            true if self.can_contain_match(&relative.split('/').collect::<Vec<_>>()) => {
+                format!("{relative}/package.json")
+            }
+            false if self.inclusions.is_match(relative.as_str()) => relative,
+            _ => return false,
+        };
+        if !self.is_ignored(path, is_dir) {
+            return true;
+        }
+        let is_workspace = self.inclusions.is_match(package_json.as_str())
+            && (!is_dir || path.join("package.json").is_file());
+        if is_workspace {
+            self.ignored
+                .lock()
+                .expect("lock poisoned")
+                .push(package_json);
+        }
+        false
+    }
+
+    fn walk(self, repo_root: &AbsoluteSystemPath) -> Result<Vec<AbsoluteSystemPathBuf>, Error> {
+        let workspaces = Arc::new(self);
+        let walker = ignore::WalkBuilder::new(repo_root)
+            // .gitignore files are matched by `should_visit` so that ignored
+            // workspaces can be reported
+            .standard_filters(false)
+            .filter_entry({
+                let workspaces = workspaces.clone();
+                move |entry| {
+                    let is_dir = entry
+                        .file_type()
+                        .map_or(false, |file_type| file_type.is_dir());
+                    workspaces.should_visit(entry.path(), is_dir)
+                }
+            })
+            .build_parallel();
+
+        let package_jsons = Mutex::new(Vec::new());
+        let error = Mutex::new(None);
+        walker.run(|| {
+            let (package_jsons, error) = (&package_jsons, &error);
+            Box::new(move |entry| {
+                let result = entry
+                    .map_err(|e| Error::Ignore(Box::new(e)))
+                    .and_then(|entry| {
+                        let is_file = entry
+                            .file_type()
+                            .map_or(false, |file_type| file_type.is_file());
+                        is_file
+                            .then(|| AbsoluteSystemPathBuf::try_from(entry.path()))
+                            .transpose()
+                            .map_err(Error::from)
+                    });
+                match result {
+                    Ok(Some(path)) => package_jsons.lock().expect("lock poisoned").push(path),
+                    Ok(None) => (),
+                    Err(e) => {
+                        *error.lock().expect("lock poisoned") = Some(e);
+                        return WalkState::Quit;
+                    }
+                }
+                WalkState::Continue
+            })
+        });
+
+        if let Some(e) = error.into_inner().expect("lock poisoned") {
+            return Err(e);
+        }
+        let mut ignored = workspaces.ignored.lock().expect("lock poisoned").clone();
+        ignored.sort();
+        for package_json in ignored {
+            warn!(
+                "skipping workspace {package_json} because it's ignored by a .gitignore file. \
+                 List it by its path to include it"
+            );
+        }
+        Ok(package_jsons.into_inner().expect("lock poisoned"))
+    }
+}
+
 #[derive(Debug, Error)]
 pub struct MissingWorkspaceError {
     package_manager: PackageManager,
@@ -296,6 +534,8 @@ pub enum Error {
     InvalidPackageManager(String, String),
     #[error(transparent)]
     WalkError(#[from] globwalk::WalkError),
+    #[error("unable to walk workspaces: {0}")]
+    Ignore(Box<ignore::Error>),
     #[error("invalid workspace glob {0}: {1}")]
     Glob(String, #[source] Box<wax::BuildError>),
     #[error("invalid globwalk pattern {0}")]
@@ -473,15 +713,53 @@ impl PackageManager {
     ) -> Result<impl Iterator<Item = AbsoluteSystemPathBuf>, Error> {
         let globs = self.get_workspace_globs(repo_root)?;
 
-        let files = globwalk::globwalk(
-            repo_root,
-            &globs.package_json_inclusions,
-            &globs.validated_exclusions,
-            globwalk::WalkType::Files,
-        )?;
+        // Workspaces that are listed by their path are always found, only the
+        // ones matched by a glob skip what git ignores
+        let (globbed, listed): (Vec<_>, Vec<_>) = globs
+            .package_json_inclusions
+            .iter()
+            .cloned()
+            .partition(|inclusion| is_glob(inclusion.as_str()));
+        let exclusions = globs
+            .validated_exclusions
+            .iter()
+            .cloned()
+            .chain(Self::tree_exclusions(self.get_default_exclusions()))
+            .collect::<Vec<_>>();
+        let mut files =
+            globwalk::globwalk(repo_root, &listed, &exclusions, globwalk::WalkType::Files)?;
+
+        if !globbed.is_empty() {
+            files
+                .extend(GlobbedWorkspaces::new(repo_root, &globbed, &exclusions)?.walk(repo_root)?);
+        }
         Ok(files.into_iter())
     }
 
+    // Exclusions like `**/node_modules` only exclude the directory itself and
+    // the walk still descends into it. The default exclusions can't contain
+    // packages, so we skip their entire tree instead. This is where most of the
+    // time goes in large repos.
+    fn tree_exclusions(exclusions: impl IntoIterator<Item = String>) -> Vec<ValidatedGlob> {
+        exclusions
+            .into_iter()
+            .map(|exclusion| {
+                if exclusion.ends_with("/**") {
+                    exclusion
+                } else {
+                    format!("{exclusion}/**")
+                }
+            })
+            .filter_map(|exclusion| match ValidatedGlob::from_str(&exclusion) {
+                Ok(glob) => Some(glob),
+                Err(e) => {
+                    debug!("not excluding {exclusion} from package discovery: {e}");
+                    None
+                }
+            })
+            .collect()
+    }
+
     pub fn lockfile_name(&self) -> &'static str {
         match self {
             PackageManager::Npm => npm::LOCKFILE,
@@ -570,6 +848,27 @@ impl PackageManager {
         }
     }
 
+    /// Removes the overrides (`overrides` for npm, `pnpm.overrides` for pnpm
+    /// and `resolutions` for Yarn) that don't apply to any of
+    /// `package_names`, the packages in a pruned lockfile
+    pub fn prune_overrides(
+        &self,
+        package_json: &PackageJson,
+        package_names: &HashSet<String>,
+    ) -> PackageJson {
+        match self {
+            PackageManager::Npm => npm::prune_overrides(package_json, package_names),
+            PackageManager::Pnpm9 | PackageManager::Pnpm6 | PackageManager::Pnpm => {
+                pnpm::prune_overrides(package_json, package_names)
+            }
+            PackageManager::Berry | PackageManager::Yarn => {
+                yarn::prune_resolutions(package_json, package_names)
+            }
+            // Bun isn't supported by `turbo prune`
+            PackageManager::Bun => package_json.clone(),
+        }
+    }
+
     pub fn lockfile_path(&self, turbo_root: &AbsoluteSystemPath) -> AbsoluteSystemPathBuf {
         turbo_root.join_component(self.lockfile_name())
     }
@@ -660,6 +959,85 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_get_package_jsons_skips_excluded_trees() {
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        for (path, contents) in [
+            (
+                "package.json",
+                r#"{"workspaces": ["packages/**", "generated/*"]}"#,
+            ),
+            (".gitignore", "# build output\n/generated/\ndist\n"),
+            ("packages/a/package.json", "{}"),
+            ("packages/a/node_modules/dep/package.json", "{}"),
+            ("packages/a/dist/package.json", "{}"),
+            ("generated/b/package.json", "{}"),
+        ] {
+            let file = repo_root.join_unix_path(RelativeUnixPath::new(path).unwrap());
+            file.ensure_dir().unwrap();
+            file.create_with_contents(contents).unwrap();
+        }
+
+        let found = PackageManager::Bun
+            .get_package_jsons(&repo_root)
+            .unwrap()
+            .collect::<Vec<_>>();
+        assert_eq!(
+            found,
+            vec![repo_root.join_components(&["packages", "a", "package.json"])]
+        );
+    }
+
+    #[test]
+    #[tracing_test::traced_test]
+    fn test_get_package_jsons_gitignore_only_affects_globs() {
+        let tmp = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        for (path, contents) in [
+            (
+                "package.json",
+                r#"{"workspaces": ["packages/*", "apps/*", "vendored/sdk"]}"#,
+            ),
+            (".gitignore", "vendored\nbuild\n"),
+            ("vendored/sdk/package.json", "{}"),
+            ("packages/.gitignore", "generated\n"),
+            ("packages/a/package.json", "{}"),
+            ("packages/generated/package.json", "{}"),
+            // a nested file can re-include what the root file ignores
+            ("apps/.gitignore", "!build\n"),
+            ("apps/build/package.json", "{}"),
+        ] {
+            let file = repo_root.join_unix_path(RelativeUnixPath::new(path).unwrap());
+            file.ensure_dir().unwrap();
+            file.create_with_contents(contents).unwrap();
+        }
+
+        let mut found = PackageManager::Bun
+            .get_package_jsons(&repo_root)
+            .unwrap()
+            .collect::<Vec<_>>();
+        found.sort();
+        assert_eq!(
+            found,
+            vec![
+                repo_root.join_components(&["apps", "build", "package.json"]),
+                repo_root.join_components(&["packages", "a", "package.json"]),
+                repo_root.join_components(&["vendored", "sdk", "package.json"]),
+            ]
+        );
+        assert!(logs_contain(
+            "skipping workspace packages/generated/package.json because it's ignored"
+        ));
+        assert!(!logs_contain("skipping workspace vendored"));
+    }
+
     #[test]
     fn test_get_workspace_ignores() {
         let root = repo_root();
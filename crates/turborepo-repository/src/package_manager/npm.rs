@@ -1 +1,80 @@
+use std::collections::HashSet;
+
+use serde_json::Value;
+use turborepo_lockfiles::override_applies;
+
+use crate::package_json::PackageJson;
+
 pub const LOCKFILE: &str = "package-lock.json";
+
+pub(crate) fn prune_overrides(
+    package_json: &PackageJson,
+    package_names: &HashSet<String>,
+) -> PackageJson {
+    let mut pruned_json = package_json.clone();
+    if let Some(Value::Object(overrides)) = pruned_json.other.get_mut("overrides") {
+        overrides.retain(|selector, value| applies(selector, value, package_names));
+        if overrides.is_empty() {
+            pruned_json.other.remove("overrides");
+        }
+    }
+    pruned_json
+}
+
+// An override can hold the overrides for the dependencies of the package it
+// selects, so it's kept if any of those still apply
+fn applies(selector: &str, value: &Value, package_names: &HashSet<String>) -> bool {
+    override_applies(selector, package_names)
+        || match value {
+            Value::Object(nested) => nested.iter().any(|(selector, value)| {
+                // `.` is the override for the selected package itself
+                selector != "." && applies(selector, value, package_names)
+            }),
+            _ => false,
+        }
+}
+
+#[cfg(test)]
+mod test {
+    use std::collections::HashSet;
+
+    use serde_json::json;
+
+    use super::prune_overrides;
+    use crate::package_json::PackageJson;
+
+    #[test]
+    fn test_prune_overrides() {
+        let package_json: PackageJson = serde_json::from_value(json!({
+            "name": "npm-overrides",
+            "overrides": {
+                "foo": "1.0.0",
+                "bar": "2.0.0",
+                "baz": {
+                    ".": "3.0.0",
+                    "foo": "1.0.1"
+                },
+                "qux": {
+                    "bar": "2.0.1"
+                }
+            }
+        }))
+        .unwrap();
+        let package_names = HashSet::from(["foo".to_string()]);
+
+        let pruned = prune_overrides(&package_json, &package_names);
+        assert_eq!(
+            pruned.other.get("overrides"),
+            Some(&json!({
+                "foo": "1.0.0",
+                "baz": {
+                    ".": "3.0.0",
+                    "foo": "1.0.1"
+                }
+            }))
+        );
+
+        let pruned = prune_overrides(&package_json, &HashSet::new());
+        assert_eq!(pruned.other.get("overrides"), None);
+    }
+}
@@ -1,7 +1,9 @@
 use std::collections::HashSet;
 
 use node_semver::{Range, Version};
+use serde_json::Value;
 use turbopath::RelativeUnixPath;
+use turborepo_lockfiles::override_applies;
 
 use crate::{
     package_json::PackageJson,
@@ -44,6 +46,25 @@ pub(crate) fn prune_patches<R: AsRef<RelativeUnixPath>>(
     pruned_json
 }
 
+// Must match the pruning of the overrides recorded in the lockfile, otherwise
+// pnpm refuses to install from it
+pub(crate) fn prune_overrides(
+    package_json: &PackageJson,
+    package_names: &HashSet<String>,
+) -> PackageJson {
+    let mut pruned_json = package_json.clone();
+    if let Some(config) = pruned_json.pnpm.as_mut() {
+        if let Some(Value::Object(overrides)) = config.other.get_mut("overrides") {
+            overrides.retain(|selector, _| override_applies(selector, package_names));
+            if overrides.is_empty() {
+                config.other.remove("overrides");
+            }
+        }
+    }
+
+    pruned_json
+}
+
 #[cfg(test)]
 mod test {
     use std::collections::BTreeMap;
@@ -83,6 +104,30 @@ mod test {
         );
     }
 
+    #[test]
+    fn test_override_pruning() {
+        let package_json: PackageJson = serde_json::from_value(json!({
+            "name": "pnpm-overrides",
+            "pnpm": {
+                "overrides": {
+                    "foo": "1.0.0",
+                    "bar@1>foo": "1.0.1",
+                    "bar": "2.0.0",
+                }
+            }
+        }))
+        .unwrap();
+        let package_names = HashSet::from(["foo".to_string()]);
+        let pruned = prune_overrides(&package_json, &package_names);
+        assert_eq!(
+            pruned.pnpm.as_ref().and_then(|c| c.other.get("overrides")),
+            Some(&json!({
+                "foo": "1.0.0",
+                "bar@1>foo": "1.0.1",
+            }))
+        );
+    }
+
     #[test_case("6.0.0", PackageManager::Pnpm6)]
     #[test_case("7.0.0", PackageManager::Pnpm)]
     #[test_case("8.0.0", PackageManager::Pnpm)]
@@ -1,5 +1,8 @@
+use std::collections::HashSet;
+
 use node_semver::{Range, Version};
 use turbopath::RelativeUnixPath;
+use turborepo_lockfiles::override_applies;
 
 use crate::{
     package_json::PackageJson,
@@ -43,15 +46,30 @@ pub(crate) fn prune_patches<R: AsRef<RelativeUnixPath>>(
     pruned_json
 }
 
+pub(crate) fn prune_resolutions(
+    package_json: &PackageJson,
+    package_names: &HashSet<String>,
+) -> PackageJson {
+    let mut pruned_json = package_json.clone();
+    if let Some(resolutions) = &mut pruned_json.resolutions {
+        resolutions.retain(|selector, _| override_applies(selector, package_names));
+        if resolutions.is_empty() {
+            pruned_json.resolutions = None;
+        }
+    }
+
+    pruned_json
+}
+
 #[cfg(test)]
 mod tests {
-    use std::collections::BTreeMap;
+    use std::collections::{BTreeMap, HashSet};
 
     use anyhow::Result;
     use serde_json::json;
     use turbopath::RelativeUnixPathBuf;
 
-    use super::prune_patches;
+    use super::{prune_patches, prune_resolutions};
     use crate::{
         package_json::PackageJson,
         package_manager::{yarn::YarnDetector, PackageManager},
@@ -100,4 +118,31 @@ mod tests {
             .as_ref()
         );
     }
+
+    #[test]
+    fn test_resolution_pruning() {
+        let package_json: PackageJson = serde_json::from_value(json!({
+            "name": "yarn-resolutions",
+            "resolutions": {
+                "**/foo": "1.0.0",
+                "bar/foo": "1.0.1",
+                "bar": "2.0.0",
+            }
+        }))
+        .unwrap();
+        let package_names = HashSet::from(["foo".to_string()]);
+        let pruned = prune_resolutions(&package_json, &package_names);
+        assert_eq!(
+            pruned.resolutions,
+            Some(
+                [("**/foo", "1.0.0"), ("bar/foo", "1.0.1")]
+                    .iter()
+                    .map(|(k, v)| (k.to_string(), v.to_string()))
+                    .collect::<BTreeMap<_, _>>()
+            )
+        );
+
+        let pruned = prune_resolutions(&package_json, &HashSet::new());
+        assert_eq!(pruned.resolutions, None);
+    }
 }
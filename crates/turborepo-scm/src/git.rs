@@ -1,15 +1,22 @@
-use std::{backtrace::Backtrace, collections::HashSet, path::PathBuf, process::Command};
+use std::{backtrace::Backtrace, collections::HashSet, path::PathBuf};
 
+use tracing::debug;
 use turbopath::{
     AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf, RelativeUnixPath,
 };
 
 use crate::{Error, Git, SCM};
 
+// How many commits to fetch at a time when looking for a missing commit in a
+// shallow clone, before giving up and fetching the full history
+const DEEPEN_BY: u32 = 100;
+const MAX_DEEPEN_ATTEMPTS: u32 = 5;
+
 impl SCM {
     pub fn get_current_branch(&self, path: &AbsoluteSystemPath) -> Result<String, Error> {
         match self {
             Self::Git(git) => git.get_current_branch(),
+            Self::Hg(hg) => hg.get_current_branch(),
             Self::Manual => Err(Error::GitRequired(path.to_owned())),
         }
     }
@@ -17,6 +24,7 @@ impl SCM {
     pub fn get_current_sha(&self, path: &AbsoluteSystemPath) -> Result<String, Error> {
         match self {
             Self::Git(git) => git.get_current_sha(),
+            Self::Hg(hg) => hg.get_current_sha(),
             Self::Manual => Err(Error::GitRequired(path.to_owned())),
         }
     }
@@ -29,10 +37,42 @@ impl SCM {
     ) -> Result<HashSet<AnchoredSystemPathBuf>, Error> {
         match self {
             Self::Git(git) => git.changed_files(turbo_root, from_commit, to_commit),
+            Self::Hg(hg) => hg.changed_files(turbo_root, from_commit, to_commit),
             Self::Manual => Err(Error::GitRequired(turbo_root.to_owned())),
         }
     }
 
+    /// Finds the best common ancestor of `base` and `head`, i.e. the commit
+    /// a branch at `head` forked from `base` at.
+    pub fn merge_base(
+        &self,
+        path: &AbsoluteSystemPath,
+        base: &str,
+        head: &str,
+    ) -> Result<String, Error> {
+        match self {
+            Self::Git(git) => git.merge_base(base, head),
+            Self::Hg(hg) => hg.merge_base(base, head),
+            Self::Manual => Err(Error::GitRequired(path.to_owned())),
+        }
+    }
+
+    /// Makes sure that the history needed to compare `from_commit` with
+    /// `to_commit`, or with the working directory, is available. In a shallow
+    /// clone the missing history is fetched if `auto_deepen` is set, otherwise
+    /// an error explaining how to get it is returned.
+    pub fn ensure_history(
+        &self,
+        from_commit: &str,
+        to_commit: Option<&str>,
+        auto_deepen: bool,
+    ) -> Result<(), Error> {
+        match self {
+            Self::Git(git) => git.ensure_history(from_commit, to_commit, auto_deepen),
+            Self::Hg(_) | Self::Manual => Ok(()),
+        }
+    }
+
     pub fn previous_content(
         &self,
         from_commit: &str,
@@ -40,6 +80,7 @@ impl SCM {
     ) -> Result<Vec<u8>, Error> {
         match self {
             Self::Git(git) => git.previous_content(from_commit, file_path),
+            Self::Hg(hg) => hg.previous_content(from_commit, file_path),
             Self::Manual => Err(Error::GitRequired(file_path.to_owned())),
         }
     }
@@ -92,6 +133,72 @@ impl Git {
         Ok(output.trim().to_owned())
     }
 
+    fn merge_base(&self, base: &str, head: &str) -> Result<String, Error> {
+        let output = self.execute_git_command(&["merge-base", base, head], "")?;
+        let output = String::from_utf8(output)?;
+        Ok(output.trim().to_owned())
+    }
+
+    fn is_shallow(&self) -> Result<bool, Error> {
+        let output = self.execute_git_command(&["rev-parse", "--is-shallow-repository"], "")?;
+        let output = String::from_utf8(output)?;
+        Ok(output.trim() == "true")
+    }
+
+    fn has_history(&self, from_commit: &str, to_commit: Option<&str>) -> bool {
+        let has_commit = |commit: &str| {
+            let commit = format!("{commit}^{{commit}}");
+            self.execute_git_command(&["rev-parse", "--verify", &commit], "")
+                .is_ok()
+        };
+        match to_commit {
+            Some(to_commit) => {
+                has_commit(from_commit)
+                    && has_commit(to_commit)
+                    && self.merge_base(from_commit, to_commit).is_ok()
+            }
+            None => has_commit(from_commit),
+        }
+    }
+
+    fn ensure_history(
+        &self,
+        from_commit: &str,
+        to_commit: Option<&str>,
+        auto_deepen: bool,
+    ) -> Result<(), Error> {
+        // If the repository isn't shallow, missing history is a mistake in the ref
+        // and git's own error is more helpful
+        if self.has_history(from_commit, to_commit) || !self.is_shallow()? {
+            return Ok(());
+        }
+        if !auto_deepen {
+            return Err(Error::ShallowClone(from_commit.to_owned()));
+        }
+
+        let deepen = format!("--deepen={DEEPEN_BY}");
+        for _ in 0..MAX_DEEPEN_ATTEMPTS {
+            debug!("{from_commit} is missing from shallow clone, fetching {DEEPEN_BY} commits");
+            self.execute_git_command(&["fetch", &deepen], "")?;
+            if self.has_history(from_commit, to_commit) {
+                return Ok(());
+            }
+            if !self.is_shallow()? {
+                break;
+            }
+        }
+        if self.is_shallow()? {
+            debug!("{from_commit} is still missing, fetching the full history");
+            self.execute_git_command(&["fetch", "--unshallow"], "")?;
+        }
+
+        if self.has_history(from_commit, to_commit) {
+            Ok(())
+        } else {
+            Err(Error::ShallowClone(from_commit.to_owned()))
+        }
+    }
+
     fn changed_files(
         &self,
         turbo_root: &AbsoluteSystemPath,
@@ -132,7 +239,7 @@ impl Git {
     }
 
     fn execute_git_command(&self, args: &[&str], pathspec: &str) -> Result<Vec<u8>, Error> {
-        let mut command = Command::new(self.bin.as_std_path());
+        let mut command = self.command();
         command
             .args(args)
             .current_dir(&self.root)
@@ -226,11 +333,11 @@ mod tests {
 
     use git2::{Oid, Repository};
     use tempfile::TempDir;
-    use turbopath::{AbsoluteSystemPathBuf, PathError};
+    use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, PathError};
     use which::which;
 
     use super::previous_content;
-    use crate::{git::changed_files, Error};
+    use crate::{git::changed_files, Error, SCM};
 
     fn setup_repository() -> Result<(TempDir, Repository), Error> {
         let repo_root = tempfile::tempdir()?;
@@ -322,6 +429,40 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_ensure_history_in_shallow_clone() -> Result<(), Error> {
+        let (repo_root, repo) = setup_repository()?;
+        let file = repo_root.path().join("foo.js");
+        fs::write(&file, "let z = 0;")?;
+        let first_commit = commit_file(&repo, Path::new("foo.js"), None);
+        fs::write(&file, "let z = 1;")?;
+        let second_commit = commit_file(&repo, Path::new("foo.js"), Some(first_commit));
+        fs::write(&file, "let z = 2;")?;
+        commit_file(&repo, Path::new("foo.js"), Some(second_commit));
+
+        let clone_dir = tempfile::tempdir()?;
+        let output = Command::new(which("git")?)
+            .args([
+                "clone",
+                "--depth",
+                "1",
+                &format!("file://{}", repo_root.path().display()),
+                clone_dir.path().to_str().unwrap(),
+            ])
+            .output()?;
+        assert!(output.status.success());
+
+        let scm = SCM::new(AbsoluteSystemPath::from_std_path(clone_dir.path())?);
+        assert_matches!(
+            scm.ensure_history("HEAD~2", None, false),
+            Err(Error::ShallowClone(_))
+        );
+        scm.ensure_history("HEAD~2", None, true)?;
+        scm.ensure_history("HEAD~2", Some("HEAD"), false)?;
+
+        Ok(())
+    }
+
     #[test]
     fn test_deleted_files() -> Result<(), Error> {
         let (repo_root, repo) = setup_repository()?;
@@ -376,6 +517,14 @@ mod tests {
 
         assert_eq!(merge_base, second_commit_oid);
 
+        let scm = SCM::new(AbsoluteSystemPath::from_std_path(repo_root.path())?);
+        let found = scm.merge_base(
+            AbsoluteSystemPath::from_std_path(repo_root.path())?,
+            &fourth_commit_oid.to_string(),
+            "HEAD",
+        )?;
+        assert_eq!(found, second_commit_oid.to_string());
+
         let files = changed_files(
             repo_root.path().to_path_buf(),
             repo_root.path().to_path_buf(),
@@ -0,0 +1,187 @@
+//! Support for Mercurial repositories. Mercurial is used for finding changed
+//! files and for getting the previous version of a lockfile. Files are hashed
+//! manually since mercurial doesn't expose git-compatible object hashes.
+
+use std::{backtrace::Backtrace, collections::HashSet, process::Command};
+
+use turbopath::{
+    AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPathBuf, RelativeUnixPath,
+};
+
+use crate::Error;
+
+#[derive(Debug, Clone)]
+pub struct Hg {
+    root: AbsoluteSystemPathBuf,
+    bin: AbsoluteSystemPathBuf,
+}
+
+impl Hg {
+    pub(crate) fn find(path_in_repo: &AbsoluteSystemPath) -> Result<Self, Error> {
+        let bin = which::which("hg")
+            .map_err(|e| Error::hg_error(format!("failed to find hg binary: {}", e)))?;
+        let bin = AbsoluteSystemPathBuf::try_from(bin.as_path())?;
+        let output = Command::new(bin.as_std_path())
+            .arg("root")
+            .current_dir(path_in_repo)
+            .env("HGPLAIN", "1")
+            .output()?;
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            return Err(Error::hg_error(format!("hg root error: {}", stderr)));
+        }
+        let root = String::from_utf8(output.stdout)?;
+        let root = AbsoluteSystemPathBuf::try_from(root.trim())?;
+        Ok(Self { root, bin })
+    }
+
+    pub(crate) fn get_current_branch(&self) -> Result<String, Error> {
+        let output = self.execute_hg_command(&["branch"])?;
+        let output = String::from_utf8(output)?;
+        Ok(output.trim().to_owned())
+    }
+
+    pub(crate) fn get_current_sha(&self) -> Result<String, Error> {
+        let output = self.execute_hg_command(&["log", "--rev", ".", "--template", "{node}"])?;
+        let output = String::from_utf8(output)?;
+        Ok(output.trim().to_owned())
+    }
+
+    pub(crate) fn merge_base(&self, base: &str, head: &str) -> Result<String, Error> {
+        let revset = format!("ancestor({base}, {head})");
+        let output = self.execute_hg_command(&["log", "--rev", &revset, "--template", "{node}"])?;
+        let output = String::from_utf8(output)?;
+        let merge_base = output.trim();
+        if merge_base.is_empty() {
+            return Err(Error::hg_error(format!(
+                "no common ancestor of {base} and {head}"
+            )));
+        }
+        Ok(merge_base.to_owned())
+    }
+
+    /// Finds the files changed since `from_commit`. If `to_commit` is given,
+    /// the comparison is made from the common ancestor of the two revisions to
+    /// `to_commit`, which matches `git diff from...to`. Otherwise the working
+    /// directory is compared, including files not yet tracked by mercurial.
+    pub(crate) fn changed_files(
+        &self,
+        turbo_root: &AbsoluteSystemPath,
+        from_commit: &str,
+        to_commit: Option<&str>,
+    ) -> Result<HashSet<AnchoredSystemPathBuf>, Error> {
+        let turbo_root_relative_to_hg_root = self.root.anchor(turbo_root)?;
+        let pattern = format!("path:{}", turbo_root_relative_to_hg_root.to_unix());
+
+        let output = if let Some(to_commit) = to_commit {
+            let from_revset = format!("ancestor({from_commit}, {to_commit})");
+            self.execute_hg_command(&[
+                "status",
+                "--no-status",
+                "--rev",
+                &from_revset,
+                "--rev",
+                to_commit,
+                &pattern,
+            ])?
+        } else {
+            self.execute_hg_command(&["status", "--no-status", "--rev", from_commit, &pattern])?
+        };
+
+        let output = String::from_utf8(output)?;
+        let mut files = HashSet::new();
+        for line in output.lines() {
+            let path = RelativeUnixPath::new(line)?;
+            let absolute_file_path = self.root.join_unix_path(path);
+            files.insert(turbo_root.anchor(&absolute_file_path)?);
+        }
+
+        Ok(files)
+    }
+
+    pub(crate) fn previous_content(
+        &self,
+        from_commit: &str,
+        file_path: &AbsoluteSystemPath,
+    ) -> Result<Vec<u8>, Error> {
+        let anchored_file_path = self.root.anchor(file_path)?;
+        let pattern = format!("path:{}", anchored_file_path.to_unix());
+
+        self.execute_hg_command(&["cat", "--rev", from_commit, &pattern])
+    }
+
+    fn execute_hg_command(&self, args: &[&str]) -> Result<Vec<u8>, Error> {
+        // HGPLAIN disables user configuration that changes output, such as
+        // aliases and relative paths
+        let output = Command::new(self.bin.as_std_path())
+            .args(args)
+            .current_dir(&self.root)
+            .env("HGPLAIN", "1")
+            .output()?;
+
+        if !output.status.success() {
+            let stderr = String::from_utf8_lossy(&output.stderr).to_string();
+            Err(Error::Hg(stderr, Backtrace::capture()))
+        } else {
+            Ok(output.stdout)
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use std::{collections::HashSet, process::Command};
+
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+
+    use crate::SCM;
+
+    fn hg(root: &AbsoluteSystemPathBuf, args: &[&str]) {
+        let output = Command::new("hg")
+            .args(args)
+            .current_dir(root)
+            .env("HGPLAIN", "1")
+            .env("HGUSER", "test <test@example.com>")
+            .output()
+            .unwrap();
+        assert!(output.status.success(), "hg {:?} failed", args);
+    }
+
+    #[test]
+    fn test_hg_changed_files() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp_dir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        hg(&root, &["init"]);
+        root.join_component("package.json")
+            .create_with_contents("{}")
+            .unwrap();
+        hg(&root, &["commit", "--addremove", "--message", "initial"]);
+
+        let scm = SCM::new(&root);
+        assert!(matches!(scm, SCM::Hg(_)));
+        assert!(scm.has_history());
+
+        root.join_component("package.json")
+            .create_with_contents(r#"{"name": "root"}"#)
+            .unwrap();
+        root.join_component("new.js")
+            .create_with_contents("")
+            .unwrap();
+        let files = scm.changed_files(&root, ".", None).unwrap();
+        assert_eq!(
+            files,
+            HashSet::from([
+                AnchoredSystemPathBuf::from_raw("package.json").unwrap(),
+                AnchoredSystemPathBuf::from_raw("new.js").unwrap(),
+            ])
+        );
+
+        let content = scm
+            .previous_content(".", &root.join_component("package.json"))
+            .unwrap();
+        assert_eq!(content, b"{}");
+    }
+}
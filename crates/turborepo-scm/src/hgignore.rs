@@ -0,0 +1,303 @@
+//! `.hgignore` files exclude paths from mercurial repositories, which are
+//! hashed by walking the files on disk. Mercurial only reads the file at the
+//! root of the repository. Patterns are regular expressions unless a `syntax:`
+//! line or a prefix on the pattern says otherwise.
+
+use ignore::gitignore::{Gitignore, GitignoreBuilder};
+use regex::RegexSet;
+use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf};
+
+use crate::Error;
+
+pub(crate) const HG_DIR: &str = ".hg";
+const HG_IGNORE_FILE: &str = ".hgignore";
+// Kinds of patterns that mercurial supports but that can't be read here, such
+// as ones that include other files
+const UNSUPPORTED_KINDS: &[&str] = &[
+    "include",
+    "subinclude",
+    "listfile",
+    "listfile0",
+    "relpath",
+    "rootfilesin",
+    "filepath",
+    "set",
+];
+
+#[derive(Debug, Clone, Copy, PartialEq)]
+enum Syntax {
+    // Matches anywhere in the path, like Python's `re.search`
+    Regexp,
+    // Matches the path relative to any directory
+    Glob,
+    // Matches the path relative to the root of the repository
+    RootGlob,
+    // Matches a path and everything under it
+    Path,
+}
+
+impl Syntax {
+    fn from_name(name: &str) -> Option<Self> {
+        match name {
+            "re" | "regexp" | "relre" => Some(Syntax::Regexp),
+            "glob" | "relglob" => Some(Syntax::Glob),
+            "rootglob" => Some(Syntax::RootGlob),
+            "path" => Some(Syntax::Path),
+            _ => None,
+        }
+    }
+}
+
+#[derive(Debug)]
+pub(crate) struct HgIgnore {
+    root: AbsoluteSystemPathBuf,
+    globs: Gitignore,
+    regexes: RegexSet,
+}
+
+impl HgIgnore {
+    /// Loads the `.hgignore` of the mercurial repository that contains
+    /// `path_in_repo`. Returns `None` if `path_in_repo` isn't in a mercurial
+    /// repository, or if the repository has no `.hgignore`.
+    pub(crate) fn load(path_in_repo: &AbsoluteSystemPath) -> Result<Option<Self>, Error> {
+        let Some(root) = path_in_repo
+            .ancestors()
+            .find(|dir| dir.join_component(HG_DIR).is_dir())
+        else {
+            return Ok(None);
+        };
+        let Some(contents) = root
+            .join_component(HG_IGNORE_FILE)
+            .read_existing_to_string()?
+        else {
+            return Ok(None);
+        };
+        Self::parse(root, &contents).map(Some)
+    }
+
+    fn parse(root: &AbsoluteSystemPath, contents: &str) -> Result<Self, Error> {
+        let mut globs = GitignoreBuilder::new(root.as_std_path());
+        let mut regexes = Vec::new();
+        let mut syntax = Syntax::Regexp;
+        for (index, line) in contents.lines().enumerate() {
+            let line_number = index + 1;
+            let invalid = |reason: String| Error::HgIgnore {
+                line: line_number,
+                reason,
+            };
+            let line = strip_comment(line);
+            let line = line.trim_end();
+            if line.is_empty() {
+                continue;
+            }
+            if let Some(name) = line.strip_prefix("syntax:") {
+                let name = name.trim();
+                syntax = Syntax::from_name(name)
+                    .ok_or_else(|| invalid(format!("unknown syntax `{name}`")))?;
+                continue;
+            }
+
+            // Like mercurial, a prefix that isn't a kind of pattern is part of
+            // the pattern
+            let (pattern_syntax, pattern) = match line.split_once(':') {
+                Some((prefix, pattern)) => match Syntax::from_name(prefix) {
+                    Some(pattern_syntax) => (pattern_syntax, pattern),
+                    None if UNSUPPORTED_KINDS.contains(&prefix) => {
+                        return Err(invalid(format!("`{prefix}:` patterns aren't supported")));
+                    }
+                    None => (syntax, line),
+                },
+                None => (syntax, line),
+            };
+            // Gitignore patterns that mercurial would read literally
+            let pattern = match pattern_syntax {
+                Syntax::Regexp => {
+                    regex::Regex::new(pattern).map_err(|e| invalid(e.to_string()))?;
+                    regexes.push(pattern.to_string());
+                    continue;
+                }
+                Syntax::Glob => format!("**/{}", escape_gitignore(pattern)),
+                Syntax::RootGlob => format!("/{}", escape_gitignore(pattern)),
+                Syntax::Path => format!("/{}", escape_glob(pattern)),
+            };
+            globs
+                .add_line(None, &pattern)
+                .map_err(|e| invalid(e.to_string()))?;
+        }
+
+        Ok(Self {
+            root: root.to_owned(),
+            globs: globs.build()?,
+            regexes: RegexSet::new(regexes).expect("each regular expression is valid"),
+        })
+    }
+
+    /// Returns whether `path` is ignored. Like mercurial, a path is ignored if
+    /// it or any of the directories it's in match a pattern.
+    pub(crate) fn is_ignored(&self, path: &AbsoluteSystemPath, is_dir: bool) -> bool {
+        let Ok(relative_path) = self.root.anchor(path) else {
+            return false;
+        };
+        let relative_path = relative_path.to_unix();
+        let relative_path = relative_path.as_str();
+        if relative_path.is_empty() {
+            return false;
+        }
+        if self
+            .globs
+            .matched_path_or_any_parents(relative_path, is_dir)
+            .is_ignore()
+        {
+            return true;
+        }
+        relative_path
+            .match_indices('/')
+            .map(|(index, _)| &relative_path[..index])
+            .chain(std::iter::once(relative_path))
+            .any(|path| self.regexes.is_match(path))
+    }
+}
+
+// `#` starts a comment unless it's escaped
+fn strip_comment(line: &str) -> String {
+    let mut stripped = String::with_capacity(line.len());
+    let mut chars = line.chars();
+    while let Some(c) = chars.next() {
+        match c {
+            '\\' => match chars.next() {
+                Some('#') => stripped.push('#'),
+                Some(c) => {
+                    stripped.push('\\');
+                    stripped.push(c);
+                }
+                None => stripped.push('\\'),
+            },
+            '#' => break,
+            c => stripped.push(c),
+        }
+    }
+    stripped
+}
+
+// Mercurial globs can't be negated, and their trailing spaces have already
+// been trimmed
+fn escape_gitignore(pattern: &str) -> String {
+    match pattern.strip_prefix('!') {
+        Some(rest) => format!("\\!{rest}"),
+        None => pattern.to_string(),
+    }
+}
+
+fn escape_glob(path: &str) -> String {
+    let mut escaped = String::with_capacity(path.len());
+    for c in path.trim_matches('/').chars() {
+        if matches!(c, '*' | '?' | '[' | ']' | '\\' | '!') {
+            escaped.push('\\');
+        }
+        escaped.push(c);
+    }
+    escaped
+}
+
+#[cfg(test)]
+mod test {
+    use std::assert_matches::assert_matches;
+
+    use test_case::test_case;
+    use turbopath::{AbsoluteSystemPathBuf, RelativeUnixPath};
+
+    use super::{HgIgnore, HG_DIR, HG_IGNORE_FILE};
+    use crate::Error;
+
+    const HG_IGNORE: &str = r"# regular expressions are the default
+\.pyc$
+^build/
+
+syntax: glob
+*.log
+node_modules
+docs/_site
+
+syntax: rootglob
+dist
+
+# prefixes override the current syntax
+re:^tmp\d+$
+path:generated/file[1].js
+";
+
+    #[test_case("app.pyc", true ; "regexp anywhere")]
+    #[test_case("src/app.pyc", true ; "nested regexp")]
+    #[test_case("build/out.js", true ; "anchored regexp")]
+    #[test_case("src/build/out.js", false ; "anchored regexp elsewhere")]
+    #[test_case("debug.log", true ; "glob")]
+    #[test_case("apps/web/debug.log", true ; "nested glob")]
+    #[test_case("apps/web/node_modules/react/index.js", true ; "glob directory")]
+    #[test_case("apps/docs/_site/index.html", true ; "glob with a slash")]
+    #[test_case("dist/index.js", true ; "rootglob")]
+    #[test_case("apps/web/dist/index.js", false ; "rootglob elsewhere")]
+    #[test_case("tmp12/file", true ; "regexp prefix")]
+    #[test_case("generated/file[1].js", true ; "path prefix")]
+    #[test_case("generated/file1.js", false ; "path prefix is literal")]
+    #[test_case("src/index.js", false ; "not ignored")]
+    #[test_case(".hgignore", false ; "hgignore itself")]
+    fn test_hg_ignore(path: &str, expected: bool) {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        root.join_component(HG_DIR).create_dir_all().unwrap();
+        root.join_component(HG_IGNORE_FILE)
+            .create_with_contents(HG_IGNORE)
+            .unwrap();
+
+        let hg_ignore = HgIgnore::load(&root).unwrap().unwrap();
+        let path = root.join_unix_path(RelativeUnixPath::new(path).unwrap());
+        assert_eq!(hg_ignore.is_ignored(&path, false), expected);
+    }
+
+    #[test]
+    fn test_hg_ignore_requires_hg_dir() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        root.join_component(HG_IGNORE_FILE)
+            .create_with_contents(HG_IGNORE)
+            .unwrap();
+
+        assert!(HgIgnore::load(&root).unwrap().is_none());
+    }
+
+    #[test]
+    fn test_hg_ignore_from_subdirectory() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        root.join_component(HG_DIR).create_dir_all().unwrap();
+        root.join_component(HG_IGNORE_FILE)
+            .create_with_contents("syntax: rootglob\ndist\n")
+            .unwrap();
+        let package = root.join_components(&["apps", "web"]);
+        package.create_dir_all().unwrap();
+
+        let hg_ignore = HgIgnore::load(&package).unwrap().unwrap();
+        // Patterns are relative to the root of the repository, not the package
+        assert!(hg_ignore.is_ignored(&root.join_component("dist"), true));
+        assert!(!hg_ignore.is_ignored(&package.join_component("dist"), true));
+    }
+
+    #[test_case("include:other.hgignore", 1 ; "unsupported prefix")]
+    #[test_case("syntax: glob\nsyntax: regex", 2 ; "unknown syntax")]
+    fn test_invalid_hg_ignore(contents: &str, line: usize) {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+
+        let result = HgIgnore::parse(&root, contents);
+        assert_matches!(result, Err(Error::HgIgnore { line: l, .. }) if l == line);
+    }
+
+    #[test]
+    fn test_invalid_hg_ignore_regexp() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+
+        let result = HgIgnore::parse(&root, "syntax: glob\n*.log\nre:(unclosed\n");
+        assert_matches!(result, Err(Error::HgIgnore { line: 3, .. }));
+    }
+}
@@ -0,0 +1,209 @@
+use std::{
+    collections::HashMap,
+    io::{BufRead, BufReader, Read},
+    process::Stdio,
+    time::SystemTime,
+};
+
+use turbopath::{
+    AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPathBuf,
+};
+
+use crate::{wait_for_success, Error, Git, SCM};
+
+// Dependencies and turbo's own logs are gitignored too, but tasks never
+// produce them as outputs
+const SKIPPED_DIRECTORIES: &[&str] = &[
+    ":(exclude,glob)**/node_modules/**",
+    ":(exclude,glob).turbo/**",
+];
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+struct FileStamp {
+    modified: Option<SystemTime>,
+    len: u64,
+}
+
+/// The gitignored files of a package and when they were last written to.
+/// Paths are relative to the turbo root.
+#[derive(Debug, Default, Clone)]
+pub struct IgnoredFiles(HashMap<AnchoredSystemPathBuf, FileStamp>);
+
+impl IgnoredFiles {
+    /// Returns the files that were created or modified since `before` was
+    /// listed
+    pub fn written_since<'a>(
+        &'a self,
+        before: &'a IgnoredFiles,
+    ) -> impl Iterator<Item = &'a AnchoredSystemPathBuf> {
+        self.0
+            .iter()
+            .filter(|(path, stamp)| before.0.get(*path) != Some(*stamp))
+            .map(|(path, _)| path)
+    }
+}
+
+impl SCM {
+    /// Lists the gitignored files of the package at `package_path`, skipping
+    /// `node_modules` and `.turbo`
+    pub fn ignored_files(
+        &self,
+        turbo_root: &AbsoluteSystemPath,
+        package_path: &AnchoredSystemPath,
+    ) -> Result<IgnoredFiles, Error> {
+        match self {
+            SCM::Git(git) => git.ignored_files(turbo_root, package_path),
+            SCM::Hg(_) => Err(Error::Unsupported {
+                operation: "inferring outputs from ignored files",
+                scm: "mercurial",
+            }),
+            SCM::Manual => Err(Error::GitRequired(turbo_root.to_owned())),
+        }
+    }
+}
+
+impl Git {
+    #[tracing::instrument(skip(self, turbo_root))]
+    fn ignored_files(
+        &self,
+        turbo_root: &AbsoluteSystemPath,
+        package_path: &AnchoredSystemPath,
+    ) -> Result<IgnoredFiles, Error> {
+        let full_pkg_path = turbo_root.resolve(package_path);
+        let mut git = self
+            .command()
+            .args([
+                "ls-files",
+                "--others",
+                "--ignored",
+                "--exclude-standard",
+                "-z",
+                "--",
+                ".",
+            ])
+            .args(SKIPPED_DIRECTORIES)
+            .env("GIT_OPTIONAL_LOCKS", "0")
+            .current_dir(&full_pkg_path)
+            .stdout(Stdio::piped())
+            .stderr(Stdio::piped())
+            .spawn()?;
+
+        let stdout = git
+            .stdout
+            .as_mut()
+            .ok_or_else(|| Error::git_error("failed to get stdout for git ls-files"))?;
+        let mut stderr = git
+            .stderr
+            .take()
+            .ok_or_else(|| Error::git_error("failed to get stderr for git ls-files"))?;
+        let parse_result = read_ignored_files(stdout, turbo_root, &full_pkg_path);
+        wait_for_success(
+            git,
+            &mut stderr,
+            "git ls-files --others --ignored",
+            &full_pkg_path,
+            parse_result,
+        )
+    }
+}
+
+fn read_ignored_files<R: Read>(
+    reader: R,
+    turbo_root: &AbsoluteSystemPath,
+    full_pkg_path: &AbsoluteSystemPath,
+) -> Result<IgnoredFiles, Error> {
+    let mut files = HashMap::new();
+    let mut reader = BufReader::new(reader);
+    let mut buffer = Vec::new();
+    while reader.read_until(b'\0', &mut buffer)? != 0 {
+        if buffer.last() == Some(&b'\0') {
+            buffer.pop();
+        }
+        let path = RelativeUnixPathBuf::new(String::from_utf8(std::mem::take(&mut buffer))?)?;
+        let path = full_pkg_path.join_unix_path(path);
+        // The file may have been removed since git listed it
+        if let Ok(metadata) = path.symlink_metadata() {
+            files.insert(
+                AnchoredSystemPathBuf::relative_path_between(turbo_root, &path),
+                FileStamp {
+                    modified: metadata.modified().ok(),
+                    len: metadata.len(),
+                },
+            );
+        }
+    }
+    Ok(IgnoredFiles(files))
+}
+
+#[cfg(test)]
+mod tests {
+    use std::process::Command;
+
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPathBuf};
+
+    use crate::SCM;
+
+    fn require_git_cmd(repo_root: &AbsoluteSystemPathBuf, args: &[&str]) {
+        let mut cmd = Command::new("git");
+        cmd.args(args).current_dir(repo_root);
+        assert!(cmd.output().unwrap().status.success());
+    }
+
+    #[test]
+    fn test_written_since() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path())
+            .unwrap()
+            .to_realpath()
+            .unwrap();
+        require_git_cmd(&repo_root, &["init", "."]);
+        repo_root
+            .join_component(".gitignore")
+            .create_with_contents("dist\nnode_modules\n.turbo\n")
+            .unwrap();
+        let pkg_path = AnchoredSystemPathBuf::from_raw("my-pkg").unwrap();
+        let pkg_dir = repo_root.resolve(&pkg_path);
+        let dist = pkg_dir.join_component("dist");
+        dist.create_dir_all().unwrap();
+        dist.join_component("unchanged.js")
+            .create_with_contents("unchanged")
+            .unwrap();
+        dist.join_component("changed.js")
+            .create_with_contents("before")
+            .unwrap();
+
+        let scm = SCM::new(&repo_root);
+        let before = scm.ignored_files(&repo_root, &pkg_path).unwrap();
+
+        dist.join_component("changed.js")
+            .create_with_contents("after the task")
+            .unwrap();
+        dist.join_component("new.js")
+            .create_with_contents("new")
+            .unwrap();
+        let node_modules = pkg_dir.join_component("node_modules");
+        node_modules.create_dir_all().unwrap();
+        node_modules
+            .join_component("dependency.js")
+            .create_with_contents("dependency")
+            .unwrap();
+        let turbo_dir = pkg_dir.join_component(".turbo");
+        turbo_dir.create_dir_all().unwrap();
+        turbo_dir
+            .join_component("turbo-build.log")
+            .create_with_contents("log")
+            .unwrap();
+
+        let after = scm.ignored_files(&repo_root, &pkg_path).unwrap();
+        let mut written = after.written_since(&before).cloned().collect::<Vec<_>>();
+        written.sort();
+
+        assert_eq!(
+            written,
+            vec![
+                pkg_path.join_components(&["dist", "changed.js"]),
+                pkg_path.join_components(&["dist", "new.js"]),
+            ]
+        );
+    }
+}
@@ -4,8 +4,10 @@
 #![deny(clippy::all)]
 
 //! Turborepo's library for interacting with source control management (SCM).
-//! Currently we only support git. We use SCM for finding changed files,
-//! for getting the previous version of a lockfile, and for hashing files.
+//! We support git and mercurial, and fall back to treating the repository as
+//! plain files when neither is available. We use SCM for finding changed
+//! files, for getting the previous version of a lockfile, and for hashing
+//! files.
 
 use std::{
     backtrace::{self, Backtrace},
@@ -20,10 +22,19 @@ use turbopath::{AbsoluteSystemPath, AbsoluteSystemPathBuf, PathError, RelativeUn
 
 pub mod git;
 mod hash_object;
+pub mod hg;
+mod hgignore;
+mod ignored;
 mod ls_tree;
 pub mod manual;
 pub mod package_deps;
 mod status;
+pub mod transform;
+mod turboignore;
+
+pub use hg::Hg;
+pub use ignored::IgnoredFiles;
+pub use turboignore::{TurboIgnore, TURBOIGNORE};
 
 #[derive(Debug, Error)]
 pub enum Error {
@@ -36,10 +47,25 @@ pub enum Error {
     #[error("git error: {0}")]
     Git(String, #[backtrace] backtrace::Backtrace),
     #[error(
-        "{0} is not part of a git repository. git is required for operations based on source \
-         control"
+        "{0} is not part of a git or mercurial repository. Source control is required for \
+         operations based on history"
     )]
     GitRequired(AbsoluteSystemPathBuf),
+    #[error(
+        "{0} is missing from the history of this shallow clone. Fetch more history with `git \
+         fetch --deepen=<depth>`, pass `--auto-deepen` to let turbo fetch it, or remove the \
+         filter to run every task"
+    )]
+    ShallowClone(String),
+    #[error("{operation} is not supported in {scm} repositories")]
+    Unsupported {
+        operation: &'static str,
+        scm: &'static str,
+    },
+    #[error("mercurial error: {0}")]
+    Hg(String, #[backtrace] backtrace::Backtrace),
+    #[error("invalid .hgignore pattern on line {line}: {reason}")]
+    HgIgnore { line: usize, reason: String },
     #[error(
         "git command failed due to unsupported git version. Upgrade to git 2.18 or newer: {0}"
     )]
@@ -76,6 +102,10 @@ impl Error {
         Error::Git(s.into(), Backtrace::capture())
     }
 
+    pub(crate) fn hg_error(s: impl Into<String>) -> Self {
+        Error::Hg(s.into(), Backtrace::capture())
+    }
+
     pub(crate) fn git2_error_context(error: git2::Error, error_context: String) -> Self {
         Error::Git2(error, error_context, Backtrace::capture())
     }
@@ -146,6 +176,9 @@ pub(crate) fn wait_for_success<R: Read, T>(
 #[derive(Debug, Clone)]
 pub struct Git {
     root: AbsoluteSystemPathBuf,
+    // Resolved once, since it isn't `<root>/.git` in linked worktrees and
+    // submodules, and git hooks can set a relative `GIT_DIR`
+    git_dir: AbsoluteSystemPathBuf,
     bin: AbsoluteSystemPathBuf,
 }
 
@@ -165,7 +198,33 @@ impl Git {
         let bin = Self::find_bin()?;
         let root =
             find_git_root(path_in_repo).map_err(|e| GitError::Root(path_in_repo.to_owned(), e))?;
-        Ok(Self { root, bin })
+        let git_dir = find_git_dir(Command::new(bin.as_std_path()), path_in_repo)
+            .map_err(|e| GitError::Root(path_in_repo.to_owned(), e))?;
+        Ok(Self { root, git_dir, bin })
+    }
+
+    /// Returns the repository of the submodule, or nested repository, checked
+    /// out at `root`
+    fn submodule(&self, root: &AbsoluteSystemPath) -> Result<Self, Error> {
+        // The variables of the outer repository would point git back at it
+        let mut rev_parse = Command::new(self.bin.as_std_path());
+        rev_parse.env_remove("GIT_DIR").env_remove("GIT_WORK_TREE");
+        let git_dir = find_git_dir(rev_parse, root)?;
+        Ok(Self {
+            root: root.to_owned(),
+            git_dir,
+            bin: self.bin.clone(),
+        })
+    }
+
+    /// Returns a git command that operates on this repository, wherever it's
+    /// run from
+    fn command(&self) -> Command {
+        let mut command = Command::new(self.bin.as_std_path());
+        command
+            .env("GIT_DIR", &self.git_dir)
+            .env("GIT_WORK_TREE", &self.root);
+        command
     }
 
     pub fn find_bin() -> Result<AbsoluteSystemPathBuf, which::Error> {
@@ -207,24 +266,64 @@ fn find_git_root(turbo_root: &AbsoluteSystemPath) -> Result<AbsoluteSystemPathBu
     }
 }
 
+fn find_git_dir(
+    mut rev_parse: Command,
+    path_in_repo: &AbsoluteSystemPath,
+) -> Result<AbsoluteSystemPathBuf, Error> {
+    let output = rev_parse
+        .args(["rev-parse", "--absolute-git-dir"])
+        .current_dir(path_in_repo)
+        .output()?;
+    if !output.status.success() {
+        let stderr = String::from_utf8_lossy(&output.stderr);
+        return Err(Error::git_error(format!(
+            "git rev-parse --absolute-git-dir error: {}",
+            stderr
+        )));
+    }
+    let git_dir = String::from_utf8(output.stdout)?;
+    Ok(AbsoluteSystemPathBuf::new(git_dir.trim_end())?)
+}
+
 #[derive(Debug, Clone)]
 pub enum SCM {
     Git(Git),
+    Hg(Hg),
     Manual,
 }
 
 impl SCM {
+    /// Detects the SCM of the repository containing `path_in_repo`. git is
+    /// preferred over mercurial, and if neither is found the repository is
+    /// treated as plain files.
     #[tracing::instrument]
     pub fn new(path_in_repo: &AbsoluteSystemPath) -> SCM {
         Git::find(path_in_repo).map(SCM::Git).unwrap_or_else(|e| {
-            debug!("{}, continuing with manual hashing", e);
-            SCM::Manual
+            debug!("{}, looking for a mercurial repository", e);
+            Hg::find(path_in_repo).map(SCM::Hg).unwrap_or_else(|e| {
+                debug!("{}, continuing with manual hashing", e);
+                SCM::Manual
+            })
         })
     }
 
     pub fn is_manual(&self) -> bool {
         matches!(self, SCM::Manual)
     }
+
+    /// Whether the SCM has a history that changes can be detected against.
+    /// Without one every file has to be considered changed.
+    pub fn has_history(&self) -> bool {
+        !self.is_manual()
+    }
+
+    pub fn name(&self) -> &'static str {
+        match self {
+            SCM::Git(_) => "git",
+            SCM::Hg(_) => "mercurial",
+            SCM::Manual => "manual",
+        }
+    }
 }
 
 #[cfg(test)]
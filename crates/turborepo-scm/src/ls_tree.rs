@@ -1,6 +1,6 @@
 use std::{
     io::{BufRead, BufReader, Read},
-    process::{Command, Stdio},
+    process::Stdio,
 };
 
 use nom::Finish;
@@ -12,7 +12,8 @@ impl Git {
     #[tracing::instrument(skip(self))]
     pub fn git_ls_tree(&self, root_path: &AbsoluteSystemPathBuf) -> Result<GitHashes, Error> {
         let mut hashes = GitHashes::new();
-        let mut git = Command::new(self.bin.as_std_path())
+        let mut git = self
+            .command()
             .args(["ls-tree", "-r", "-z", "HEAD"])
             .env("GIT_OPTIONAL_LOCKS", "0")
             .current_dir(root_path)
@@ -1,13 +1,21 @@
-use std::io::{ErrorKind, Read};
+use std::{
+    io::{ErrorKind, Read},
+    str::FromStr,
+    sync::Arc,
+};
 
-use globwalk::fix_glob_pattern;
+use globwalk::{fix_glob_pattern, ValidatedGlob};
 use hex::ToHex;
-use ignore::WalkBuilder;
+use ignore::{DirEntry, WalkBuilder};
 use sha1::{Digest, Sha1};
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, IntoUnix};
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, AnchoredSystemPathBuf, IntoUnix};
 use wax::{any, Glob, Program};
 
-use crate::{package_deps::GitHashes, Error};
+use crate::{
+    hgignore::{HgIgnore, HG_DIR},
+    package_deps::GitHashes,
+    Error, TurboIgnore,
+};
 
 fn git_like_hash_file(path: &AbsoluteSystemPath) -> Result<String, Error> {
     let mut hasher = Sha1::new();
@@ -35,7 +43,9 @@ fn to_glob(input: &str) -> Result<Glob, Error> {
     Ok(g)
 }
 
-pub(crate) fn hash_files(
+/// Hashes the files the same way as git does, keyed by their unix paths.
+/// Missing files are skipped if `allow_missing` is set.
+pub fn hash_files(
     root_path: &AbsoluteSystemPath,
     files: impl Iterator<Item = impl AsRef<AnchoredSystemPath>>,
     allow_missing: bool,
@@ -63,16 +73,51 @@ pub(crate) fn get_package_file_hashes_without_git<S: AsRef<str>>(
     include_default_files: bool,
 ) -> Result<GitHashes, Error> {
     let full_package_path = turbo_root.resolve(package_path);
+    // Ignored files are left out before hashing so that they aren't read
+    let turbo_ignore = TurboIgnore::load(turbo_root, package_path)?;
     let mut hashes = GitHashes::new();
     let mut default_file_hashes = GitHashes::new();
     let mut excluded_file_hashes = GitHashes::new();
 
+    // Mercurial repositories are also hashed by walking the package, so its
+    // metadata directory is skipped, and its .hgignore is respected wherever
+    // .gitignore files are
+    let hg_ignore = HgIgnore::load(&full_package_path)?.map(Arc::new);
+    let hg_filter = |respect_ignore: bool| {
+        let hg_ignore = hg_ignore.clone().filter(|_| respect_ignore);
+        move |entry: &DirEntry| {
+            if entry.file_name() == HG_DIR {
+                return false;
+            }
+            let (Some(hg_ignore), Ok(path)) =
+                (&hg_ignore, AbsoluteSystemPath::from_std_path(entry.path()))
+            else {
+                return true;
+            };
+            let is_dir = entry.file_type().is_some_and(|ty| ty.is_dir());
+            !hg_ignore.is_ignored(path, is_dir)
+        }
+    };
+
     let mut walker_builder = WalkBuilder::new(&full_package_path);
+    walker_builder.filter_entry(hg_filter(inputs.is_empty()));
     let mut includes = Vec::new();
     let mut excludes = Vec::new();
+    // Inputs outside of the package aren't reached by walking the package, so
+    // they're globbed from the repository root instead
+    let mut outside_includes = Vec::new();
+    let mut outside_excludes = Vec::new();
+    let package_unix_path = package_path.to_unix();
     for pattern in inputs {
         let pattern = pattern.as_ref();
-        if let Some(exclusion) = pattern.strip_prefix('!') {
+        if pattern.trim_start_matches('!').starts_with("../") {
+            let (globs, pattern) = match pattern.strip_prefix('!') {
+                Some(exclusion) => (&mut outside_excludes, exclusion),
+                None => (&mut outside_includes, pattern),
+            };
+            let glob = [package_unix_path.as_str(), pattern].join("/");
+            globs.push(ValidatedGlob::from_str(&glob)?);
+        } else if let Some(exclusion) = pattern.strip_prefix('!') {
             let g = to_glob(exclusion)?;
             excludes.push(g);
         } else {
@@ -80,7 +125,7 @@ pub(crate) fn get_package_file_hashes_without_git<S: AsRef<str>>(
             includes.push(g);
         }
     }
-    let include_pattern = if includes.is_empty() {
+    let include_pattern = if includes.is_empty() && outside_includes.is_empty() {
         None
     } else {
         // Add in package.json and turbo.json to input patterns. Both file paths are
@@ -142,6 +187,10 @@ pub(crate) fn get_package_file_hashes_without_git<S: AsRef<str>>(
             }
         }
 
+        if turbo_ignore.is_ignored(&relative_path) {
+            continue;
+        }
+
         // FIXME: we don't hash symlinks...
         if metadata.is_symlink() {
             continue;
@@ -153,6 +202,7 @@ pub(crate) fn get_package_file_hashes_without_git<S: AsRef<str>>(
     // If we're including default files, we need to walk again, but this time with
     // git_ignore enabled
     if include_default_files {
+        walker_builder.filter_entry(hg_filter(true));
         let walker = walker_builder
             .follow_links(false)
             .git_ignore(true)
@@ -184,7 +234,7 @@ pub(crate) fn get_package_file_hashes_without_git<S: AsRef<str>>(
             }
 
             // FIXME: we don't hash symlinks...
-            if metadata.is_symlink() {
+            if metadata.is_symlink() || turbo_ignore.is_ignored(&relative_path) {
                 continue;
             }
             let hash = git_like_hash_file(path)?;
@@ -192,6 +242,24 @@ pub(crate) fn get_package_file_hashes_without_git<S: AsRef<str>>(
         }
     }
 
+    if !outside_includes.is_empty() {
+        let files = globwalk::globwalk(
+            turbo_root,
+            &outside_includes,
+            &outside_excludes,
+            globwalk::WalkType::Files,
+        )?;
+        for path in files {
+            let relative_path =
+                AnchoredSystemPathBuf::relative_path_between(&full_package_path, &path).to_unix();
+            if path.symlink_metadata()?.is_symlink() || turbo_ignore.is_ignored(&relative_path) {
+                continue;
+            }
+            let hash = git_like_hash_file(&path)?;
+            hashes.insert(relative_path, hash);
+        }
+    }
+
     // merge default with all hashes
     hashes.extend(default_file_hashes);
     // remove excluded files
@@ -331,6 +399,44 @@ mod tests {
         };
     }
 
+    #[test]
+    fn test_get_package_file_hashes_outside_package() {
+        let (_tmp, turbo_root) = tmp_dir();
+        let pkg_path = AnchoredSystemPathBuf::from_raw("child-dir/libA").unwrap();
+        for (raw_unix_path, contents) in [
+            ("tsconfig.json", "some-file-contents"),
+            ("tsconfig.test.json", "some-file-contents"),
+            ("child-dir/libA/package.json", "lib-package.json-content"),
+            ("child-dir/libA/some-file", "some-file-contents"),
+        ] {
+            let unix_path = RelativeUnixPath::new(raw_unix_path).unwrap();
+            let file_path = turbo_root.join_unix_path(unix_path);
+            file_path.ensure_dir().unwrap();
+            file_path.create_with_contents(contents).unwrap();
+        }
+
+        let hashes = get_package_file_hashes_without_git(
+            &turbo_root,
+            &pkg_path,
+            &["../../tsconfig*.json", "!../../tsconfig.test.json"],
+            false,
+        )
+        .unwrap();
+
+        // Only package.json is included from the package since there are inputs
+        let expected = GitHashes::from([
+            (
+                RelativeUnixPathBuf::new("../../tsconfig.json").unwrap(),
+                "7e59c6a6ea9098c6d3beb00e753e2c54ea502311".to_owned(),
+            ),
+            (
+                RelativeUnixPathBuf::new("package.json").unwrap(),
+                "55d57df9acc1b37d0cfc2c1c70379dab48f3f7e1".to_owned(),
+            ),
+        ]);
+        assert_eq!(hashes, expected);
+    }
+
     #[test]
     fn test_get_package_file_hashes_from_processing_gitignore() {
         let root_ignore_contents = ["ignoreme", "ignorethisdir/"].join("\n");
@@ -452,4 +558,92 @@ mod tests {
 
         assert_eq!(hashes, expected);
     }
+
+    #[test]
+    fn test_get_package_file_hashes_in_hg_repo() {
+        let (_tmp, turbo_root) = tmp_dir();
+        for file in [
+            ".hg/store/data",
+            ".hgignore",
+            "package.json",
+            "src/index.js",
+            "dist/index.js",
+            "debug.log",
+        ] {
+            let path = turbo_root.join_unix_path(RelativeUnixPath::new(file).unwrap());
+            path.ensure_dir().unwrap();
+            path.create_with_contents("").unwrap();
+        }
+        turbo_root
+            .join_component(".hgignore")
+            .create_with_contents("^dist/\nsyntax: glob\n*.log\n")
+            .unwrap();
+
+        let files = |hashes: GitHashes| {
+            let mut files = hashes
+                .into_keys()
+                .map(|path| path.as_str().to_owned())
+                .collect::<Vec<_>>();
+            files.sort();
+            files
+        };
+
+        let hashes = get_package_file_hashes_without_git::<&str>(
+            &turbo_root,
+            AnchoredSystemPath::empty(),
+            &[],
+            false,
+        )
+        .unwrap();
+        assert_eq!(files(hashes), [".hgignore", "package.json", "src/index.js"]);
+
+        // explicit inputs aren't affected by ignore files, but mercurial's
+        // metadata is never an input
+        let hashes = get_package_file_hashes_without_git(
+            &turbo_root,
+            AnchoredSystemPath::empty(),
+            &["**/*"],
+            false,
+        )
+        .unwrap();
+        assert_eq!(
+            files(hashes),
+            [
+                ".hgignore",
+                "debug.log",
+                "dist/index.js",
+                "package.json",
+                "src/index.js"
+            ]
+        );
+
+        let hashes = get_package_file_hashes_without_git(
+            &turbo_root,
+            AnchoredSystemPath::empty(),
+            &["$TURBO_DEFAULT$", "!src/**"],
+            true,
+        )
+        .unwrap();
+        assert_eq!(files(hashes), [".hgignore", "package.json"]);
+
+        // without mercurial's metadata, .hgignore is just another file
+        turbo_root.join_component(".hg").remove_dir_all().unwrap();
+        let hashes = get_package_file_hashes_without_git::<&str>(
+            &turbo_root,
+            AnchoredSystemPath::empty(),
+            &[],
+            false,
+        )
+        .unwrap();
+        assert_eq!(
+            files(hashes),
+            [
+                ".hgignore",
+                "debug.log",
+                "dist/index.js",
+                "package.json",
+                "src/index.js"
+            ]
+        );
+    }
 }
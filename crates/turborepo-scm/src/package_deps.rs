@@ -2,10 +2,13 @@ use std::{collections::HashMap, str::FromStr};
 
 use globwalk::ValidatedGlob;
 use tracing::debug;
-use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, PathError, RelativeUnixPathBuf};
+use turbopath::{
+    AbsoluteSystemPath, AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf,
+    PathError, RelativeUnixPath, RelativeUnixPathBuf,
+};
 use turborepo_telemetry::events::task::{FileHashMethod, PackageTaskEventBuilder};
 
-use crate::{hash_object::hash_objects, Error, Git, SCM};
+use crate::{hash_object::hash_objects, Error, Git, TurboIgnore, SCM};
 
 pub type GitHashes = HashMap<RelativeUnixPathBuf, String>;
 
@@ -41,9 +44,9 @@ impl SCM {
         let include_default_files = inputs
             .iter()
             .any(|input| input.as_ref() == INPUT_INCLUDE_DEFAULT_FILES);
-
         match self {
-            SCM::Manual => {
+            // mercurial doesn't provide git object hashes, so we hash its files manually
+            SCM::Manual | SCM::Hg(_) => {
                 if let Some(telemetry) = telemetry {
                     telemetry.track_file_hash_method(FileHashMethod::Manual);
                 }
@@ -94,7 +97,7 @@ impl SCM {
         files: impl Iterator<Item = impl AsRef<AnchoredSystemPath>>,
     ) -> Result<GitHashes, Error> {
         match self {
-            SCM::Manual => crate::manual::hash_files(turbo_root, files, false),
+            SCM::Manual | SCM::Hg(_) => crate::manual::hash_files(turbo_root, files, false),
             SCM::Git(git) => git.hash_files(turbo_root, files),
         }
     }
@@ -119,9 +122,16 @@ impl Git {
         inputs: &[S],
         include_default_files: bool,
     ) -> Result<GitHashes, Error> {
+        // Ignored files are left out before hashing so that they aren't read
+        let turbo_ignore = TurboIgnore::load(turbo_root, package_path)?;
+
         // no inputs, and no $TURBO_DEFAULT$
         if inputs.is_empty() {
-            return self.get_package_file_hashes_from_index(turbo_root, package_path);
+            return self.get_package_file_hashes_from_index(
+                turbo_root,
+                package_path,
+                &turbo_ignore,
+            );
         }
 
         // we have inputs, but no $TURBO_DEFAULT$
@@ -131,26 +141,85 @@ impl Git {
                 package_path,
                 inputs,
                 true,
+                &turbo_ignore,
             );
         }
 
         // we have inputs, and $TURBO_DEFAULT$
-        self.get_package_file_hashes_from_inputs_and_index(turbo_root, package_path, inputs)
+        self.get_package_file_hashes_from_inputs_and_index(
+            turbo_root,
+            package_path,
+            inputs,
+            &turbo_ignore,
+        )
     }
 
-    #[tracing::instrument(skip(self, turbo_root))]
+    #[tracing::instrument(skip(self, turbo_root, turbo_ignore))]
     fn get_package_file_hashes_from_index(
         &self,
         turbo_root: &AbsoluteSystemPath,
         package_path: &AnchoredSystemPath,
+        turbo_ignore: &TurboIgnore,
     ) -> Result<GitHashes, Error> {
         let full_pkg_path = turbo_root.resolve(package_path);
-        let git_to_pkg_path = self.root.anchor(&full_pkg_path)?;
+        self.hash_from_index(&full_pkg_path, &|path| {
+            RelativeUnixPath::new(path).map_or(false, |path| turbo_ignore.is_ignored(path))
+        })
+    }
+
+    // Hashes the files under `full_pkg_path` with the index, and the working tree
+    // for modified files. Returns hashes relative to `full_pkg_path`, leaving out
+    // the paths that `is_ignored`.
+    fn hash_from_index(
+        &self,
+        full_pkg_path: &AbsoluteSystemPathBuf,
+        is_ignored: &dyn Fn(&str) -> bool,
+    ) -> Result<GitHashes, Error> {
+        let git_to_pkg_path = self.root.anchor(full_pkg_path)?;
         let pkg_prefix = git_to_pkg_path.to_unix();
-        let mut hashes = self.git_ls_tree(&full_pkg_path)?;
+        // Submodules are listed with the commit they're pinned to as their hash
+        let mut hashes = self.git_ls_tree(full_pkg_path)?;
         // Note: to_hash is *git repo relative*
-        let to_hash = self.append_git_status(&full_pkg_path, &pkg_prefix, &mut hashes)?;
-        hash_objects(&self.root, &full_pkg_path, to_hash, &mut hashes)?;
+        let to_hash = self.append_git_status(full_pkg_path, &pkg_prefix, &mut hashes)?;
+        hashes.retain(|path, _| !is_ignored(path.as_str()));
+        let to_hash = to_hash
+            .into_iter()
+            .filter(|path| {
+                let path = path.as_str().trim_end_matches('/');
+                let package_relative = match pkg_prefix.as_str() {
+                    "" => Some(path),
+                    prefix => path
+                        .strip_prefix(prefix)
+                        .and_then(|path| path.strip_prefix('/')),
+                };
+                !package_relative.is_some_and(is_ignored)
+            })
+            .collect::<Vec<_>>();
+        // A submodule with changes, or an untracked nested repository, is reported as
+        // a directory, so its files are hashed with its own repository instead
+        let (submodules, to_hash): (Vec<_>, Vec<_>) = to_hash.into_iter().partition(|path| {
+            self.root
+                .join_unix_path(path)
+                .symlink_metadata()
+                .is_ok_and(|metadata| metadata.is_dir())
+        });
+        hash_objects(&self.root, full_pkg_path, to_hash, &mut hashes)?;
+        for submodule in submodules {
+            // Untracked directories are reported with a trailing slash
+            let submodule = RelativeUnixPathBuf::new(submodule.as_str().trim_end_matches('/'))?;
+            let submodule_path = self.root.join_unix_path(&submodule);
+            let submodule_prefix = full_pkg_path.anchor(&submodule_path)?.to_unix();
+            hashes.remove(&submodule_prefix);
+            let submodule_hashes = self
+                .submodule(&submodule_path)?
+                .hash_from_index(&submodule_path, &|path| {
+                    is_ignored(&format!("{}/{}", submodule_prefix, path))
+                })?;
+            for (path, hash) in submodule_hashes {
+                let path = RelativeUnixPathBuf::new(format!("{}/{}", submodule_prefix, path))?;
+                hashes.insert(path, hash);
+            }
+        }
         Ok(hashes)
     }
 
@@ -173,13 +242,14 @@ impl Git {
         Ok(hashes)
     }
 
-    #[tracing::instrument(skip(self, turbo_root, inputs))]
+    #[tracing::instrument(skip(self, turbo_root, inputs, turbo_ignore))]
     fn get_package_file_hashes_from_inputs<S: AsRef<str>>(
         &self,
         turbo_root: &AbsoluteSystemPath,
         package_path: &AnchoredSystemPath,
         inputs: &[S],
         include_configs: bool,
+        turbo_ignore: &TurboIgnore,
     ) -> Result<GitHashes, Error> {
         let full_pkg_path = turbo_root.resolve(package_path);
         let package_unix_path_buf = package_path.to_unix();
@@ -233,6 +303,10 @@ impl Git {
         )?;
         let to_hash = files
             .iter()
+            .filter(|entry| {
+                let path = AnchoredSystemPathBuf::relative_path_between(&full_pkg_path, entry);
+                !turbo_ignore.is_ignored(&path.to_unix())
+            })
             .map(|entry| {
                 let path = self.root.anchor(entry)?.to_unix();
                 Ok(path)
@@ -243,16 +317,17 @@ impl Git {
         Ok(hashes)
     }
 
-    #[tracing::instrument(skip(self, turbo_root, inputs))]
+    #[tracing::instrument(skip(self, turbo_root, inputs, turbo_ignore))]
     fn get_package_file_hashes_from_inputs_and_index<S: AsRef<str>>(
         &self,
         turbo_root: &AbsoluteSystemPath,
         package_path: &AnchoredSystemPath,
         inputs: &[S],
+        turbo_ignore: &TurboIgnore,
     ) -> Result<GitHashes, Error> {
         // collect the default files and the inputs
         let default_file_hashes =
-            self.get_package_file_hashes_from_index(turbo_root, package_path)?;
+            self.get_package_file_hashes_from_index(turbo_root, package_path, turbo_ignore)?;
 
         // we need to get hashes for excludes separately so we can remove them from the
         // defaults later on
@@ -268,12 +343,23 @@ impl Git {
         }
         // we have to always run the includes search because we add default files to the
         // includes
-        let manual_includes_hashes =
-            self.get_package_file_hashes_from_inputs(turbo_root, package_path, &includes, true)?;
+        let manual_includes_hashes = self.get_package_file_hashes_from_inputs(
+            turbo_root,
+            package_path,
+            &includes,
+            true,
+            turbo_ignore,
+        )?;
 
         // only run the excludes search if there are excludes
         let manual_excludes_hashes = if !excludes.is_empty() {
-            self.get_package_file_hashes_from_inputs(turbo_root, package_path, &excludes, false)?
+            self.get_package_file_hashes_from_inputs(
+                turbo_root,
+                package_path,
+                &excludes,
+                false,
+                turbo_ignore,
+            )?
         } else {
             GitHashes::new()
         };
@@ -353,6 +439,43 @@ mod tests {
         assert!(manual_hashes.is_empty());
     }
 
+    #[test]
+    fn test_get_package_deps_turbo_ignore() {
+        let (_repo_root_tmp, repo_root) = tmp_dir();
+        let pkg_path = AnchoredSystemPathBuf::from_raw("my-pkg").unwrap();
+        for (path, contents) in [
+            (".turboignore", "__snapshots__/\ngenerated/\n"),
+            ("my-pkg/src/index.ts", "index"),
+            ("my-pkg/src/__snapshots__/index.snap", "snapshot"),
+        ] {
+            let file = repo_root.join_unix_path(RelativeUnixPathBuf::new(path).unwrap());
+            file.ensure_dir().unwrap();
+            file.create_with_contents(contents).unwrap();
+        }
+        setup_repository(&repo_root);
+        commit_all(&repo_root);
+        // untracked files are hashed from the working tree
+        let generated = repo_root.join_components(&["my-pkg", "generated", "out.js"]);
+        generated.ensure_dir().unwrap();
+        generated.create_with_contents("generated").unwrap();
+
+        let git = SCM::new(&repo_root);
+        assert_matches!(git, SCM::Git(_));
+        let inputs: &[&[&str]] = &[&[], &["src/**", "generated/**"], &["$TURBO_DEFAULT$"]];
+        for scm in [&git, &SCM::Manual] {
+            for &inputs in inputs {
+                let hashes = scm
+                    .get_package_file_hashes(&repo_root, &pkg_path, inputs, None)
+                    .unwrap();
+                assert_eq!(
+                    hashes.keys().collect::<Vec<_>>(),
+                    vec![&RelativeUnixPathBuf::new("src/index.ts").unwrap()],
+                    "inputs: {inputs:?}"
+                );
+            }
+        }
+    }
+
     #[test]
     fn test_get_package_deps_fallback() {
         let (_repo_root_tmp, repo_root) = tmp_dir();
@@ -615,6 +738,128 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_get_package_deps_in_worktree() -> Result<(), Error> {
+        let (_tmp, tmp_root) = tmp_dir();
+        let repo_root = tmp_root.join_component("repo");
+        let my_pkg_dir = repo_root.join_component("my-pkg");
+        my_pkg_dir.create_dir_all()?;
+        my_pkg_dir
+            .join_component("committed-file")
+            .create_with_contents("committed bytes")?;
+        setup_repository(&repo_root);
+        commit_all(&repo_root);
+        require_git_cmd(&repo_root, &["worktree", "add", "../worktree"]);
+
+        let worktree = tmp_root.join_component("worktree");
+        worktree
+            .join_components(&["my-pkg", "uncommitted-file"])
+            .create_with_contents("uncommitted bytes")?;
+        let git = SCM::new(&worktree);
+        let SCM::Git(git) = git else {
+            panic!("expected git, found {:?}", git);
+        };
+        assert_eq!(git.root, worktree);
+        assert_eq!(
+            git.git_dir,
+            repo_root.join_components(&[".git", "worktrees", "worktree"])
+        );
+
+        let package_path = AnchoredSystemPathBuf::from_raw("my-pkg")?;
+        let hashes = git.get_package_file_hashes::<&str>(&worktree, &package_path, &[], false)?;
+        assert_eq!(
+            hashes,
+            to_hash_map(&[
+                ("committed-file", "3a29e62ea9ba15c4a4009d1f605d391cdd262033"),
+                (
+                    "uncommitted-file",
+                    "4e56ad89387e6379e4e91ddfe9872cf6a72c9976"
+                ),
+            ])
+        );
+        Ok(())
+    }
+
+    #[test]
+    fn test_get_package_deps_with_submodule() -> Result<(), Error> {
+        // Directory structure:
+        // <root>/
+        //   .gitmodules
+        //   my-pkg/
+        //     package.json
+        //     lib/ <- submodule
+        //       committed-file
+        //       uncommitted-file <- new file not added to the submodule
+        let (_tmp, tmp_root) = tmp_dir();
+        let submodule_origin = tmp_root.join_component("lib");
+        let committed_file_path = submodule_origin.join_component("committed-file");
+        committed_file_path.ensure_dir()?;
+        committed_file_path.create_with_contents("committed bytes")?;
+        setup_repository(&submodule_origin);
+        commit_all(&submodule_origin);
+        let rev_parse = Command::new("git")
+            .args(["rev-parse", "HEAD"])
+            .current_dir(&submodule_origin)
+            .output()?;
+        let submodule_head = String::from_utf8(rev_parse.stdout)?.trim().to_string();
+
+        let repo_root = tmp_root.join_component("repo");
+        let my_pkg_dir = repo_root.join_component("my-pkg");
+        my_pkg_dir.create_dir_all()?;
+        my_pkg_dir
+            .join_component("package.json")
+            .create_with_contents("{}")?;
+        setup_repository(&repo_root);
+        require_git_cmd(
+            &repo_root,
+            &[
+                "-c",
+                "protocol.file.allow=always",
+                "submodule",
+                "add",
+                submodule_origin.as_str(),
+                "my-pkg/lib",
+            ],
+        );
+        commit_all(&repo_root);
+        let git = SCM::new(&repo_root);
+        let SCM::Git(git) = git else {
+            panic!("expected git, found {:?}", git);
+        };
+        let package_path = AnchoredSystemPathBuf::from_raw("my-pkg")?;
+
+        // A clean submodule is hashed by the commit it's pinned to
+        let hashes = git.get_package_file_hashes::<&str>(&repo_root, &package_path, &[], false)?;
+        assert_eq!(
+            hashes,
+            to_hash_map(&[
+                ("package.json", "9e26dfeeb6e641a33dae4961196235bdb965b21b"),
+                ("lib", &submodule_head),
+            ])
+        );
+
+        // A submodule with changes is hashed by its files
+        my_pkg_dir
+            .join_components(&["lib", "uncommitted-file"])
+            .create_with_contents("uncommitted bytes")?;
+        let hashes = git.get_package_file_hashes::<&str>(&repo_root, &package_path, &[], false)?;
+        assert_eq!(
+            hashes,
+            to_hash_map(&[
+                ("package.json", "9e26dfeeb6e641a33dae4961196235bdb965b21b"),
+                (
+                    "lib/committed-file",
+                    "3a29e62ea9ba15c4a4009d1f605d391cdd262033"
+                ),
+                (
+                    "lib/uncommitted-file",
+                    "4e56ad89387e6379e4e91ddfe9872cf6a72c9976"
+                ),
+            ])
+        );
+        Ok(())
+    }
+
     fn to_hash_map(pairs: &[(&str, &str)]) -> GitHashes {
         HashMap::from_iter(
             pairs
@@ -1,6 +1,6 @@
 use std::{
     io::{BufRead, BufReader, Read},
-    process::{Command, Stdio},
+    process::Stdio,
 };
 
 use nom::Finish;
@@ -16,7 +16,8 @@ impl Git {
         pkg_prefix: &RelativeUnixPathBuf,
         hashes: &mut GitHashes,
     ) -> Result<Vec<RelativeUnixPathBuf>, Error> {
-        let mut git = Command::new(self.bin.as_std_path())
+        let mut git = self
+            .command()
             .args([
                 "status",
                 "--untracked-files",
@@ -0,0 +1,271 @@
+//! Content transforms that are applied to a task's input files before they
+//! are hashed. They let users opt in to ignoring changes that can't affect a
+//! task's output, such as line ending differences or comment-only edits.
+
+use std::{fmt, str::FromStr};
+
+use hex::ToHex;
+use sha1::{Digest, Sha1};
+use thiserror::Error;
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, RelativeUnixPath};
+
+use crate::{package_deps::GitHashes, Error};
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum TransformKind {
+    NormalizeLineEndings,
+    TrimTrailingWhitespace,
+    StripLineComments,
+}
+
+// The registry of transforms that can be referenced by name
+const TRANSFORMS: &[(&str, TransformKind)] = &[
+    ("normalizeLineEndings", TransformKind::NormalizeLineEndings),
+    (
+        "trimTrailingWhitespace",
+        TransformKind::TrimTrailingWhitespace,
+    ),
+    ("stripLineComments", TransformKind::StripLineComments),
+];
+
+#[derive(Debug, Error, PartialEq, Eq)]
+#[error("unknown input transform: {name}")]
+pub struct UnknownTransformError {
+    pub name: String,
+}
+
+/// A single content transform along with the file extensions it applies to.
+///
+/// Transforms are written as `<name>` or `<name>:<ext>,<ext>`, e.g.
+/// `stripLineComments:ts,tsx`. If no extensions are listed the transform
+/// applies to every input file.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct InputTransform {
+    kind: TransformKind,
+    extensions: Vec<String>,
+}
+
+impl InputTransform {
+    /// Names of all of the available transforms
+    pub fn names() -> impl Iterator<Item = &'static str> {
+        TRANSFORMS.iter().map(|(name, _)| *name)
+    }
+
+    fn name(&self) -> &'static str {
+        TRANSFORMS
+            .iter()
+            .find_map(|(name, kind)| (*kind == self.kind).then_some(*name))
+            .expect("all transforms are registered")
+    }
+
+    fn applies_to(&self, path: &RelativeUnixPath) -> bool {
+        self.extensions.is_empty()
+            || path
+                .extension()
+                .map_or(false, |ext| self.extensions.iter().any(|e| e == ext))
+    }
+
+    fn apply(&self, contents: &[u8]) -> Vec<u8> {
+        let mut output = Vec::with_capacity(contents.len());
+        for line in contents.split_inclusive(|b| *b == b'\n') {
+            let (body, ending) = split_line_ending(line);
+            match self.kind {
+                TransformKind::NormalizeLineEndings => {
+                    output.extend_from_slice(body);
+                    if !ending.is_empty() {
+                        output.push(b'\n');
+                    }
+                }
+                TransformKind::TrimTrailingWhitespace => {
+                    output.extend_from_slice(trim_end(body));
+                    output.extend_from_slice(ending);
+                }
+                TransformKind::StripLineComments => {
+                    if !trim_start(body).starts_with(b"//") {
+                        output.extend_from_slice(line);
+                    }
+                }
+            }
+        }
+        output
+    }
+}
+
+impl FromStr for InputTransform {
+    type Err = UnknownTransformError;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let (name, extensions) = s.split_once(':').unwrap_or((s, ""));
+        let kind = TRANSFORMS
+            .iter()
+            .find_map(|(registered, kind)| (*registered == name).then_some(*kind))
+            .ok_or_else(|| UnknownTransformError {
+                name: name.to_string(),
+            })?;
+        let extensions = extensions
+            .split(',')
+            .map(|ext| ext.trim().trim_start_matches('.'))
+            .filter(|ext| !ext.is_empty())
+            .map(|ext| ext.to_string())
+            .collect();
+
+        Ok(Self { kind, extensions })
+    }
+}
+
+impl fmt::Display for InputTransform {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.name())?;
+        if !self.extensions.is_empty() {
+            write!(f, ":{}", self.extensions.join(","))?;
+        }
+        Ok(())
+    }
+}
+
+/// Re-hashes every file in `hashes` that at least one of `transforms`
+/// applies to. The file contents are passed through the transforms in order
+/// and the result is hashed the same way git hashes a blob.
+///
+/// `hashes` is expected to be keyed by paths relative to `package_path`.
+pub fn apply_input_transforms(
+    turbo_root: &AbsoluteSystemPath,
+    package_path: &AnchoredSystemPath,
+    transforms: &[InputTransform],
+    hashes: &mut GitHashes,
+) -> Result<(), Error> {
+    if transforms.is_empty() {
+        return Ok(());
+    }
+    let full_package_path = turbo_root.resolve(package_path);
+    for (path, hash) in hashes.iter_mut() {
+        let mut applicable = transforms
+            .iter()
+            .filter(|transform| transform.applies_to(path))
+            .peekable();
+        if applicable.peek().is_none() {
+            continue;
+        }
+        let mut contents = full_package_path.join_unix_path(path).read()?;
+        for transform in applicable {
+            contents = transform.apply(&contents);
+        }
+        *hash = git_like_hash_bytes(&contents);
+    }
+    Ok(())
+}
+
+fn git_like_hash_bytes(contents: &[u8]) -> String {
+    let mut hasher = Sha1::new();
+    hasher.update("blob ".as_bytes());
+    hasher.update(contents.len().to_string().as_bytes());
+    hasher.update([b'\0']);
+    hasher.update(contents);
+    hasher.finalize().encode_hex::<String>()
+}
+
+fn split_line_ending(line: &[u8]) -> (&[u8], &[u8]) {
+    if let Some(body) = line.strip_suffix(b"\r\n") {
+        (body, &line[body.len()..])
+    } else if let Some(body) = line.strip_suffix(b"\n") {
+        (body, &line[body.len()..])
+    } else {
+        (line, &[])
+    }
+}
+
+fn trim_start(bytes: &[u8]) -> &[u8] {
+    let start = bytes
+        .iter()
+        .position(|b| !b.is_ascii_whitespace())
+        .unwrap_or(bytes.len());
+    &bytes[start..]
+}
+
+fn trim_end(bytes: &[u8]) -> &[u8] {
+    let end = bytes
+        .iter()
+        .rposition(|b| !b.is_ascii_whitespace())
+        .map_or(0, |i| i + 1);
+    &bytes[..end]
+}
+
+#[cfg(test)]
+mod test {
+    use test_case::test_case;
+    use turbopath::{AbsoluteSystemPathBuf, AnchoredSystemPathBuf, RelativeUnixPathBuf};
+
+    use super::*;
+
+    #[test_case("normalizeLineEndings", "a\r\nb\r\n", "a\nb\n" ; "normalize line endings")]
+    #[test_case("trimTrailingWhitespace", "a  \nb\t\r\nc ", "a\nb\r\nc" ; "trim whitespace")]
+    #[test_case(
+        "stripLineComments",
+        "// header\nconst a = 1; // trailing\n  // indented\nconst b = 2;\n",
+        "const a = 1; // trailing\nconst b = 2;\n"
+        ; "strip line comments"
+    )]
+    fn test_apply(spec: &str, input: &str, expected: &str) {
+        let transform = InputTransform::from_str(spec).unwrap();
+        let actual = transform.apply(input.as_bytes());
+        assert_eq!(String::from_utf8(actual).unwrap(), expected);
+    }
+
+    #[test_case("stripLineComments", "stripLineComments", "a.txt", true ; "no extensions")]
+    #[test_case("stripLineComments:ts,tsx", "stripLineComments:ts,tsx", "a.ts", true ; "listed")]
+    #[test_case("stripLineComments:.ts, .js", "stripLineComments:ts,js", "a.md", false ; "dotted")]
+    fn test_parse(spec: &str, display: &str, path: &str, applies: bool) {
+        let transform = InputTransform::from_str(spec).unwrap();
+        assert_eq!(transform.to_string(), display);
+        let path = RelativeUnixPathBuf::new(path).unwrap();
+        assert_eq!(transform.applies_to(&path), applies);
+    }
+
+    #[test]
+    fn test_unknown_transform() {
+        assert_eq!(
+            InputTransform::from_str("stripBlockComments:ts"),
+            Err(UnknownTransformError {
+                name: "stripBlockComments".to_string()
+            })
+        );
+    }
+
+    #[test]
+    fn test_apply_input_transforms() -> Result<(), Error> {
+        let tmp = tempfile::tempdir()?;
+        let turbo_root = AbsoluteSystemPathBuf::try_from(tmp.path())?;
+        let package_path = AnchoredSystemPathBuf::from_raw("my-pkg")?;
+        let package_root = turbo_root.resolve(&package_path);
+        package_root.create_dir_all()?;
+        package_root
+            .join_component("index.ts")
+            .create_with_contents("// changed comment\nexport const a = 1;\n")?;
+        package_root
+            .join_component("README.md")
+            .create_with_contents("// not a comment\n")?;
+
+        let mut hashes = GitHashes::new();
+        hashes.insert(
+            RelativeUnixPathBuf::new("index.ts")?,
+            "original".to_string(),
+        );
+        hashes.insert(
+            RelativeUnixPathBuf::new("README.md")?,
+            "original".to_string(),
+        );
+
+        let transforms = vec![InputTransform::from_str("stripLineComments:ts").unwrap()];
+        apply_input_transforms(&turbo_root, &package_path, &transforms, &mut hashes)?;
+
+        assert_eq!(
+            hashes.get(&RelativeUnixPathBuf::new("index.ts")?).unwrap(),
+            &git_like_hash_bytes(b"export const a = 1;\n")
+        );
+        assert_eq!(
+            hashes.get(&RelativeUnixPathBuf::new("README.md")?).unwrap(),
+            "original"
+        );
+        Ok(())
+    }
+}
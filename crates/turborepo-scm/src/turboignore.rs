@@ -0,0 +1,167 @@
+//! `.turboignore` files exclude paths from the hashes of packages. They use
+//! the same syntax as `.gitignore`. The file at the root of the repository
+//! applies to every package, and a package's own file can ignore more paths or
+//! re-include paths that the root file ignores with `!`.
+
+use ignore::gitignore::{Gitignore, GitignoreBuilder};
+use turbopath::{AbsoluteSystemPath, AnchoredSystemPath, RelativeUnixPath, RelativeUnixPathBuf};
+
+use crate::Error;
+
+pub const TURBOIGNORE: &str = ".turboignore";
+
+#[derive(Debug, Clone)]
+pub struct TurboIgnore {
+    root: Gitignore,
+    package: Gitignore,
+    package_path: RelativeUnixPathBuf,
+}
+
+impl Default for TurboIgnore {
+    fn default() -> Self {
+        Self {
+            root: Gitignore::empty(),
+            package: Gitignore::empty(),
+            package_path: RelativeUnixPathBuf::default(),
+        }
+    }
+}
+
+impl TurboIgnore {
+    /// Loads the root `.turboignore` and the `.turboignore` of the package at
+    /// `package_path`. Either file may be missing.
+    pub fn load(
+        turbo_root: &AbsoluteSystemPath,
+        package_path: &AnchoredSystemPath,
+    ) -> Result<Self, Error> {
+        let root = load_gitignore(turbo_root)?;
+        let package = if package_path.as_str().is_empty() {
+            Gitignore::empty()
+        } else {
+            load_gitignore(&turbo_root.resolve(package_path))?
+        };
+        Ok(Self {
+            root,
+            package,
+            package_path: package_path.to_unix(),
+        })
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.root.is_empty() && self.package.is_empty()
+    }
+
+    /// Returns whether the file at `path`, relative to the package, is
+    /// ignored. Patterns in the package's `.turboignore` take precedence.
+    pub fn is_ignored(&self, path: &RelativeUnixPath) -> bool {
+        let in_package = !path.as_str().starts_with("../");
+        if in_package {
+            let package_match = self
+                .package
+                .matched_path_or_any_parents(path.as_str(), false);
+            if !package_match.is_none() {
+                return package_match.is_ignore();
+            }
+        }
+        let Some(repo_path) = repo_relative(self.package_path.as_str(), path.as_str()) else {
+            return false;
+        };
+        self.root
+            .matched_path_or_any_parents(repo_path, false)
+            .is_ignore()
+    }
+}
+
+fn load_gitignore(dir: &AbsoluteSystemPath) -> Result<Gitignore, Error> {
+    let mut builder = GitignoreBuilder::new(dir.as_std_path());
+    let file = dir.join_component(TURBOIGNORE);
+    if file.exists() {
+        if let Some(err) = builder.add(file.as_std_path()) {
+            return Err(err.into());
+        }
+    }
+    Ok(builder.build()?)
+}
+
+// Joins a path relative to the package onto the package path, resolving `..`
+// so that inputs outside of the package can be matched against the root
+// `.turboignore`. Returns `None` for paths outside of the repository.
+fn repo_relative(package_path: &str, path: &str) -> Option<String> {
+    let mut components = package_path
+        .split('/')
+        .filter(|component| !component.is_empty())
+        .collect::<Vec<_>>();
+    for component in path.split('/') {
+        match component {
+            "" | "." => (),
+            ".." => {
+                components.pop()?;
+            }
+            component => components.push(component),
+        }
+    }
+    Some(components.join("/"))
+}
+
+#[cfg(test)]
+mod test {
+    use turbopath::{
+        AbsoluteSystemPathBuf, AnchoredSystemPath, AnchoredSystemPathBuf, RelativeUnixPathBuf,
+    };
+
+    use super::{TurboIgnore, TURBOIGNORE};
+
+    #[test]
+    fn test_turbo_ignore() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+        let package_path = AnchoredSystemPathBuf::from_raw("apps/web").unwrap();
+        let package_dir = repo_root.resolve(&package_path);
+        package_dir.create_dir_all().unwrap();
+        repo_root
+            .join_component(TURBOIGNORE)
+            .create_with_contents("# generated\n__snapshots__/\nvendor/\n*.log\n")
+            .unwrap();
+        package_dir
+            .join_component(TURBOIGNORE)
+            .create_with_contents("!vendor/\nfixtures/large\n")
+            .unwrap();
+
+        let turbo_ignore = TurboIgnore::load(&repo_root, &package_path).unwrap();
+        let mut paths = [
+            "src/index.ts",
+            "src/__snapshots__/index.test.ts.snap",
+            "vendor/sdk.js",
+            "fixtures/large/data.json",
+            "fixtures/small.json",
+            "debug.log",
+            "../../tsconfig.json",
+            "../../vendor/sdk.js",
+            "../../../outside.json",
+        ]
+        .into_iter()
+        .filter(|path| !turbo_ignore.is_ignored(&RelativeUnixPathBuf::new(*path).unwrap()))
+        .collect::<Vec<_>>();
+        paths.sort();
+        assert_eq!(
+            paths,
+            vec![
+                "../../../outside.json",
+                "../../tsconfig.json",
+                "fixtures/small.json",
+                "src/index.ts",
+                "vendor/sdk.js",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_no_turbo_ignore() {
+        let tmp_dir = tempfile::tempdir().unwrap();
+        let repo_root = AbsoluteSystemPathBuf::try_from(tmp_dir.path()).unwrap();
+
+        let turbo_ignore = TurboIgnore::load(&repo_root, AnchoredSystemPath::empty()).unwrap();
+
+        assert!(turbo_ignore.is_empty());
+    }
+}
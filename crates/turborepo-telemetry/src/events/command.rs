@@ -58,6 +58,7 @@ pub enum CodePath {
 pub enum LoginMethod {
     SSO,
     Standard,
+    Device,
 }
 
 impl CommandEventBuilder {
@@ -146,6 +147,7 @@ impl CommandEventBuilder {
             value: match method {
                 LoginMethod::SSO => "sso".to_string(),
                 LoginMethod::Standard => "standard".to_string(),
+                LoginMethod::Device => "device".to_string(),
             },
             is_sensitive: EventType::NonSensitive,
         });
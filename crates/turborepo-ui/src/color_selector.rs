@@ -19,6 +19,17 @@ pub fn get_terminal_package_colors() -> &'static [Style; 5] {
     })
 }
 
+/// Picks the color for a key from a hash of it, so that the key gets the same
+/// color in every run regardless of the order keys are seen in.
+pub fn stable_color_for_key(key: &str) -> &'static Style {
+    let colors = get_terminal_package_colors();
+    // FNV-1a, which unlike the std hasher is guaranteed to be stable
+    let hash = key.bytes().fold(0xcbf29ce484222325u64, |hash, byte| {
+        (hash ^ u64::from(byte)).wrapping_mul(0x100000001b3)
+    });
+    &colors[(hash % colors.len() as u64) as usize]
+}
+
 /// Selects colors for tasks and caches accordingly.
 /// Shared between tasks so allows for concurrent access.
 #[derive(Default)]
@@ -113,6 +124,14 @@ mod tests {
         assert_eq!(selector.inner.read().unwrap().idx, 2);
     }
 
+    #[test]
+    fn test_stable_color_for_key() {
+        let color = super::stable_color_for_key("web#dev");
+        assert_eq!(color, super::stable_color_for_key("web#dev"));
+        // Changing the hash would change the colors users are used to
+        assert_eq!(color, &super::get_terminal_package_colors()[3]);
+    }
+
     #[test]
     fn test_color_selector_wraps_around() {
         let selector = super::ColorSelector::default();
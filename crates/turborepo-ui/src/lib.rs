@@ -19,7 +19,7 @@ use lazy_static::lazy_static;
 use thiserror::Error;
 
 pub use crate::{
-    color_selector::ColorSelector,
+    color_selector::{stable_color_for_key, ColorSelector},
     line::LineWriter,
     logs::{replay_logs, LogWriter},
     output::{OutputClient, OutputClientBehavior, OutputSink, OutputWriter},
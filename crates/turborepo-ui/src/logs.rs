@@ -8,10 +8,10 @@ use turbopath::AbsoluteSystemPath;
 
 use crate::Error;
 
-/// Receives logs and multiplexes them to a log file and/or a prefixed
+/// Receives logs and multiplexes them to log files and/or a prefixed
 /// writer
 pub struct LogWriter<W> {
-    log_file: Option<BufWriter<File>>,
+    log_files: Vec<BufWriter<File>>,
     writer: Option<W>,
 }
 
@@ -20,13 +20,15 @@ pub struct LogWriter<W> {
 impl<W> Default for LogWriter<W> {
     fn default() -> Self {
         Self {
-            log_file: None,
+            log_files: Vec::new(),
             writer: None,
         }
     }
 }
 
 impl<W: Write> LogWriter<W> {
+    /// Adds a log file to write to. Can be called more than once to write
+    /// the same logs to several files.
     pub fn with_log_file(&mut self, log_file_path: &AbsoluteSystemPath) -> Result<(), Error> {
         log_file_path.ensure_dir().map_err(|err| {
             warn!("error creating log file directory: {:?}", err);
@@ -38,7 +40,7 @@ impl<W: Write> LogWriter<W> {
             Error::CannotWriteLogs(err)
         })?;
 
-        self.log_file = Some(BufWriter::new(log_file));
+        self.log_files.push(BufWriter::new(log_file));
 
         Ok(())
     }
@@ -50,23 +52,27 @@ impl<W: Write> LogWriter<W> {
 
 impl<W: Write> Write for LogWriter<W> {
     fn write(&mut self, buf: &[u8]) -> std::io::Result<usize> {
-        match (&mut self.log_file, &mut self.writer) {
-            (Some(log_file), Some(prefixed_writer)) => {
-                let _ = prefixed_writer.write(buf)?;
-                log_file.write(buf)
-            }
-            (Some(log_file), None) => log_file.write(buf),
-            (None, Some(prefixed_writer)) => prefixed_writer.write(buf),
-            (None, None) => {
+        match (self.log_files.as_mut_slice(), &mut self.writer) {
+            ([], Some(prefixed_writer)) => prefixed_writer.write(buf),
+            ([], None) => {
                 // Should this be an error or even a panic?
                 debug!("no log file or prefixed writer");
                 Ok(0)
             }
+            (log_files, prefixed_writer) => {
+                if let Some(prefixed_writer) = prefixed_writer {
+                    let _ = prefixed_writer.write(buf)?;
+                }
+                for log_file in log_files {
+                    log_file.write_all(buf)?;
+                }
+                Ok(buf.len())
+            }
         }
     }
 
     fn flush(&mut self) -> std::io::Result<()> {
-        if let Some(log_file) = &mut self.log_file {
+        for log_file in &mut self.log_files {
             log_file.flush()?;
         }
         if let Some(prefixed_writer) = &mut self.writer {
@@ -162,6 +168,27 @@ mod tests {
         Ok(())
     }
 
+    #[test]
+    fn test_log_writer_multiple_files() -> Result<()> {
+        let dir = tempdir()?;
+        let log_file_path = AbsoluteSystemPathBuf::try_from(dir.path().join("test.txt"))?;
+        let copy_path = AbsoluteSystemPathBuf::try_from(dir.path().join("logs").join("copy.txt"))?;
+        let mut log_writer = LogWriter::<Vec<u8>>::default();
+
+        log_writer.with_log_file(&log_file_path)?;
+        log_writer.with_log_file(&copy_path)?;
+
+        writeln!(log_writer, "one fish")?;
+        writeln!(log_writer, "two fish")?;
+
+        log_writer.flush()?;
+
+        assert_eq!(log_file_path.read_to_string()?, "one fish\ntwo fish\n");
+        assert_eq!(copy_path.read_to_string()?, "one fish\ntwo fish\n");
+
+        Ok(())
+    }
+
     #[test]
     fn test_replay_logs() -> Result<()> {
         let ui = UI::new(false);
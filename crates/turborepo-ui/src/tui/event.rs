@@ -37,6 +37,7 @@ pub enum Event {
 #[derive(Debug, PartialEq, Eq, PartialOrd, Ord, Clone, Copy)]
 pub enum TaskResult {
     Success,
+    CacheHit,
     Failure,
 }
 
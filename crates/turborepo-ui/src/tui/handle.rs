@@ -96,6 +96,11 @@ impl TuiTask {
         self.finish(TaskResult::Success)
     }
 
+    /// Mark the task as finished with its outputs restored from the cache
+    pub fn cached(&self) -> Vec<u8> {
+        self.finish(TaskResult::CacheHit)
+    }
+
     /// Mark the task as finished
     pub fn failed(&self) -> Vec<u8> {
         self.finish(TaskResult::Failure)
@@ -1,3 +1,5 @@
+use std::time::Duration;
+
 use ratatui::{
     layout::{Constraint, Rect},
     style::{Color, Style, Stylize},
@@ -30,6 +32,9 @@ pub struct TaskTable {
     spinner: SpinnerState,
 }
 
+// Width of the duration column, fits durations up to "59m59s" with padding
+const DURATION_WIDTH: u16 = 7;
+
 impl TaskTable {
     /// Construct a new table with all of the planned tasks
     pub fn new(tasks: impl IntoIterator<Item = String>) -> Self {
@@ -54,8 +59,8 @@ impl TaskTable {
             // Task column width should be large enough to fit "Task" title
             // and truncate tasks with more than 40 chars.
             .clamp(4, 40) as u16;
-        // Add space for column divider and status emoji
-        task_name_width + 1
+        // Add space for the duration, column divider and status emoji
+        task_name_width + DURATION_WIDTH + 1
     }
 
     /// Number of rows in the table
@@ -214,8 +219,10 @@ impl TaskTable {
         self.finished.iter().map(move |task| {
             Row::new(vec![
                 Cell::new(task.name()),
+                duration_cell(task.duration()),
                 Cell::new(match task.result() {
                     TaskResult::Success => Text::raw("✔").style(Style::default().light_green()),
+                    TaskResult::CacheHit => Text::raw("⊙").style(Style::default().light_cyan()),
                     TaskResult::Failure => Text::raw("✘").style(Style::default().red()),
                 }),
             ])
@@ -224,15 +231,19 @@ impl TaskTable {
 
     fn running_rows(&self) -> impl Iterator<Item = Row> + '_ {
         let spinner = self.spinner.current();
-        self.running
-            .iter()
-            .map(move |task| Row::new(vec![Cell::new(task.name()), Cell::new(Text::raw(spinner))]))
+        self.running.iter().map(move |task| {
+            Row::new(vec![
+                Cell::new(task.name()),
+                duration_cell(task.elapsed()),
+                Cell::new(Text::raw(spinner)),
+            ])
+        })
     }
 
     fn planned_rows(&self) -> impl Iterator<Item = Row> + '_ {
         self.planned
             .iter()
-            .map(move |task| Row::new(vec![Cell::new(task.name()), Cell::new(" ")]))
+            .map(move |task| Row::new(vec![Cell::new(task.name()), Cell::new(""), Cell::new(" ")]))
     }
 
     /// Convenience method which renders and updates scroll state
@@ -256,6 +267,7 @@ impl<'a> StatefulWidget for &'a TaskTable {
                 .chain(self.planned_rows()),
             [
                 Constraint::Min(4),
+                Constraint::Length(DURATION_WIDTH),
                 // Status takes one cell to render
                 Constraint::Length(1),
             ],
@@ -263,20 +275,63 @@ impl<'a> StatefulWidget for &'a TaskTable {
         .highlight_style(Style::default().fg(Color::Yellow))
         .column_spacing(0)
         .header(
-            vec![format!("Task\n{bar}"), "\n─".to_owned()]
-                .into_iter()
-                .map(Cell::from)
-                .collect::<Row>()
-                .height(2),
+            vec![
+                format!("Task\n{bar}"),
+                format!(
+                    "{:>6} \n{}",
+                    "Time",
+                    "─".repeat(usize::from(DURATION_WIDTH))
+                ),
+                "\n─".to_owned(),
+            ]
+            .into_iter()
+            .map(Cell::from)
+            .collect::<Row>()
+            .height(2),
         );
         StatefulWidget::render(table, area, buf, state);
     }
 }
 
+fn duration_cell(duration: Duration) -> Cell<'static> {
+    Cell::new(Text::raw(format_duration(duration)).style(Style::default().dark_gray()))
+}
+
+/// Formats a duration to fit in the duration column
+fn format_duration(duration: Duration) -> String {
+    let formatted = if duration < Duration::from_secs(1) {
+        format!("{}ms", duration.as_millis())
+    } else if duration < Duration::from_secs(60) {
+        format!("{:.1}s", duration.as_secs_f64())
+    } else {
+        let secs = duration.as_secs();
+        format!("{}m{}s", secs / 60, secs % 60)
+    };
+    format!("{formatted:>6} ")
+}
+
 #[cfg(test)]
 mod test {
+    use test_case::test_case;
+
     use super::*;
 
+    #[test_case(Duration::from_millis(42), "  42ms " ; "milliseconds")]
+    #[test_case(Duration::from_millis(1240), "  1.2s " ; "seconds")]
+    #[test_case(Duration::from_secs(125), "  2m5s " ; "minutes")]
+    fn test_format_duration(duration: Duration, expected: &str) {
+        assert_eq!(format_duration(duration), expected);
+    }
+
+    #[test]
+    fn test_cache_hit() {
+        let mut table = TaskTable::new(vec!["a".to_string(), "b".to_string()]);
+        table.start_task("a").unwrap();
+        table.finish_task("a", TaskResult::CacheHit).unwrap();
+        assert_eq!(table.finished[0].result(), TaskResult::CacheHit);
+        assert_eq!(table.get(0), Some("a"), "cached task is finished");
+    }
+
     #[test]
     fn test_scroll() {
         let mut table = TaskTable::new(vec![
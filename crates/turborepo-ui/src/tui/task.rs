@@ -1,5 +1,5 @@
 #![allow(dead_code)]
-use std::time::Instant;
+use std::time::{Duration, Instant};
 
 use super::event::TaskResult;
 
@@ -67,6 +67,11 @@ impl Task<Running> {
     pub fn start(&self) -> Instant {
         self.state.start
     }
+
+    /// Time elapsed since the task started
+    pub fn elapsed(&self) -> Duration {
+        self.state.start.elapsed()
+    }
 }
 
 impl Task<Finished> {
@@ -81,4 +86,9 @@ impl Task<Finished> {
     pub fn result(&self) -> TaskResult {
         self.state.result
     }
+
+    /// Time the task took to finish
+    pub fn duration(&self) -> Duration {
+        self.state.end.duration_since(self.state.start)
+    }
 }
@@ -12,8 +12,8 @@ use axum::{
 use futures_util::StreamExt;
 use tokio::sync::Mutex;
 use turborepo_vercel_api::{
-    AnalyticsEvent, CachingStatus, CachingStatusResponse, Membership, Role, Space, SpaceRun,
-    SpacesResponse, Team, TeamsResponse, User, UserResponse, VerificationResponse,
+    AnalyticsEvent, CachingStatus, CachingStatusResponse, Membership, Role, RunCacheAnalytics,
+    Space, SpaceRun, SpacesResponse, Team, TeamsResponse, User, UserResponse, VerificationResponse,
 };
 
 pub const EXPECTED_TOKEN: &str = "expected_token";
@@ -229,6 +229,10 @@ pub async fn start_test_server(port: u16) -> Result<()> {
             "/v8/artifacts/events",
             get(|| async move { Json(get_analytics_events_ref.lock().await.clone()) }),
         )
+        .route(
+            "/v8/artifacts/analytics",
+            post(|Json(_analytics): Json<RunCacheAnalytics>| async {}),
+        )
         .route(
             "/preflight/absolute-location",
             options(|| async {
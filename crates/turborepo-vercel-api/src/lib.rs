@@ -118,6 +118,19 @@ pub struct UserResponse {
     pub user: User,
 }
 
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(rename_all = "camelCase")]
+pub struct DeviceCodeResponse {
+    pub device_code: String,
+    pub user_code: String,
+    pub verification_uri: String,
+    pub verification_uri_complete: Option<String>,
+    /// Seconds until the device code expires
+    pub expires_in: u64,
+    /// Seconds to wait between polling for the token
+    pub interval: u64,
+}
+
 #[derive(Debug)]
 pub struct PreflightResponse {
     pub location: Url,
@@ -160,6 +173,20 @@ impl AnalyticsEvent {
     }
 }
 
+/// How much a run used the cache. Only counts are included, nothing that
+/// identifies the tasks or the repository they were run in.
+#[derive(Debug, Serialize, Deserialize, Clone, Default, PartialEq)]
+#[serde(rename_all = "camelCase")]
+pub struct RunCacheAnalytics {
+    pub tasks: usize,
+    pub local_hits: usize,
+    pub remote_hits: usize,
+    // milliseconds saved by cache hits
+    pub time_saved: u64,
+    pub downloaded_bytes: usize,
+    pub uploaded_bytes: usize,
+}
+
 #[cfg(test)]
 mod tests {
     use test_case::test_case;
@@ -111,7 +111,8 @@ where
     // `AsRef<TreeEntry>` or       similar. This does not require dynamic
     // dispatch, but places more restrictive       constraints on entry types.
     // Revisit this.
-    type Substituent<'a> = &'a dyn Entry
+    type Substituent<'a>
+        = &'a dyn Entry
     where
         Self: 'a;
 
@@ -185,6 +186,12 @@ impl WalkError {
     pub fn depth(&self) -> usize {
         self.depth
     }
+
+    /// Returns `true` if the error was caused by a symbolic link that forms a
+    /// cycle with one of its ancestors.
+    pub fn is_link_cycle(&self) -> bool {
+        matches!(self.kind, WalkErrorKind::LinkCycle { .. })
+    }
 }
 
 impl From<walkdir::Error> for WalkError {
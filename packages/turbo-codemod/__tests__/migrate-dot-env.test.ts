@@ -7,7 +7,7 @@ describe("migrate-dot-env", () => {
     directory: __dirname,
     test: "migrate-dot-env",
   });
-  it("migrates turbo.json dot-env - basic", () => {
+  it("removes null dot-env entries - basic", () => {
     // load the fixture for the test
     const { root, read } = useFixture({
       fixture: "with-dot-env",
@@ -21,15 +21,17 @@ describe("migrate-dot-env", () => {
 
     expect(JSON.parse(read("turbo.json") || "{}")).toStrictEqual({
       $schema: "https://turbo.build/schema.json",
-      globalDependencies: [".env"],
+      globalDotEnv: [".env"],
       tasks: {
         "build-one": {
-          inputs: ["$TURBO_DEFAULT$", "build-one/.env"],
+          dotEnv: ["build-one/.env"],
         },
         "build-two": {
-          inputs: ["build-two/main.js", "build-two/.env"],
+          dotEnv: ["build-two/.env"],
+          inputs: ["build-two/main.js"],
         },
         "build-three": {},
+        "build-four": {},
       },
     });
 
@@ -38,14 +40,14 @@ describe("migrate-dot-env", () => {
       Object {
         "turbo.json": Object {
           "action": "modified",
-          "additions": 3,
-          "deletions": 3,
+          "additions": 1,
+          "deletions": 1,
         },
       }
     `);
   });
 
-  it("migrates turbo.json dot-env - workspace configs", () => {
+  it("keeps dot-env entries in workspace configs", () => {
     // load the fixture for the test
     const { root, readJson } = useFixture({
       fixture: "workspace-configs",
@@ -61,10 +63,11 @@ describe("migrate-dot-env", () => {
       $schema: "https://turbo.build/schema.json",
       tasks: {
         "build-one": {
-          inputs: ["$TURBO_DEFAULT$", "build-one/.env"],
+          dotEnv: ["build-one/.env"],
         },
         "build-two": {
-          inputs: ["build-two/**/*.ts", "build-two/.env"],
+          dotEnv: ["build-two/.env"],
+          inputs: ["build-two/**/*.ts"],
         },
         "build-three": {},
       },
@@ -83,7 +86,8 @@ describe("migrate-dot-env", () => {
       extends: ["//"],
       tasks: {
         build: {
-          inputs: ["src/**/*.ts", ".env"],
+          dotEnv: [".env"],
+          inputs: ["src/**/*.ts"],
         },
       },
     });
@@ -93,7 +97,7 @@ describe("migrate-dot-env", () => {
       extends: ["//"],
       tasks: {
         "build-three": {
-          inputs: ["$TURBO_DEFAULT$", ".env"],
+          dotEnv: [".env"],
         },
       },
     });
@@ -112,14 +116,14 @@ describe("migrate-dot-env", () => {
           "deletions": 0,
         },
         "packages/ui/turbo.json": Object {
-          "action": "modified",
-          "additions": 1,
-          "deletions": 1,
+          "action": "unchanged",
+          "additions": 0,
+          "deletions": 0,
         },
         "turbo.json": Object {
-          "action": "modified",
-          "additions": 2,
-          "deletions": 2,
+          "action": "unchanged",
+          "additions": 0,
+          "deletions": 0,
         },
       }
     `);
@@ -147,8 +151,8 @@ describe("migrate-dot-env", () => {
       Object {
         "turbo.json": Object {
           "action": "skipped",
-          "additions": 3,
-          "deletions": 3,
+          "additions": 1,
+          "deletions": 1,
         },
       }
     `);
@@ -168,15 +172,17 @@ describe("migrate-dot-env", () => {
 
     expect(JSON.parse(read("turbo.json") || "{}")).toStrictEqual({
       $schema: "https://turbo.build/schema.json",
-      globalDependencies: [".env"],
+      globalDotEnv: [".env"],
       tasks: {
         "build-one": {
-          inputs: ["$TURBO_DEFAULT$", "build-one/.env"],
+          dotEnv: ["build-one/.env"],
         },
-        "build-three": {},
         "build-two": {
-          inputs: ["build-two/main.js", "build-two/.env"],
+          dotEnv: ["build-two/.env"],
+          inputs: ["build-two/main.js"],
         },
+        "build-three": {},
+        "build-four": {},
       },
     });
 
@@ -185,8 +191,8 @@ describe("migrate-dot-env", () => {
       Object {
         "turbo.json": Object {
           "action": "modified",
-          "additions": 3,
-          "deletions": 3,
+          "additions": 1,
+          "deletions": 1,
         },
       }
     `);
@@ -214,8 +220,8 @@ describe("migrate-dot-env", () => {
       Object {
         "turbo.json": Object {
           "action": "skipped",
-          "additions": 3,
-          "deletions": 3,
+          "additions": 1,
+          "deletions": 1,
         },
       }
     `);
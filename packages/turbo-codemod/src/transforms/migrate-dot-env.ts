@@ -14,28 +14,19 @@ import { loadTurboJson } from "../utils/loadTurboJson";
 
 // transformer details
 const TRANSFORMER = "migrate-dot-env";
-const DESCRIPTION = 'Migrate the "dotEnv" entries to "inputs" in `turbo.json`';
+const DESCRIPTION = 'Remove the "dotEnv" entries that are set to null in `turbo.json`';
 const INTRODUCED_IN = "2.0.0-canary.0";
 
+// `globalDotEnv` and `dotEnv` are supported again, with files still ordered
+// from most to least significant, so their files are kept instead of being
+// moved to `inputs`. They no longer accept null.
 function migrateConfig(config: LegacySchema) {
-  if ("globalDotEnv" in config) {
-    if (config.globalDotEnv) {
-      config.globalDependencies = config.globalDependencies ?? [];
-      for (const dotEnvPath of config.globalDotEnv) {
-        config.globalDependencies.push(dotEnvPath);
-      }
-    }
+  if ("globalDotEnv" in config && config.globalDotEnv === null) {
     delete config.globalDotEnv;
   }
 
   forEachTaskDef(config, ([_, taskDef]) => {
-    if ("dotEnv" in taskDef) {
-      if (taskDef.dotEnv) {
-        taskDef.inputs = taskDef.inputs ?? ["$TURBO_DEFAULT$"];
-        for (const dotEnvPath of taskDef.dotEnv) {
-          taskDef.inputs.push(dotEnvPath);
-        }
-      }
+    if ("dotEnv" in taskDef && taskDef.dotEnv === null) {
       delete taskDef.dotEnv;
     }
   });
@@ -71,7 +62,7 @@ export function transformer({
     });
   }
 
-  log.info(`Moving entries in \`dotEnv\` key in task config to \`inputs\``);
+  log.info(`Removing \`dotEnv\` keys that are set to null`);
   const turboConfigPath = path.join(root, "turbo.json");
   if (!existsSync(turboConfigPath)) {
     return runner.abortTransform({
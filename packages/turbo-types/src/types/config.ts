@@ -47,7 +47,9 @@ export interface WorkspaceSchema extends BaseSchema {
    * and overrides with the keys provided
    * in your Workspace Configs.
    *
-   * Currently, only the "//" value is allowed.
+   * The first entry must be "//". It can be followed by the names of
+   * packages that publish a shareable `turbo.json`, which are resolved
+   * through `node_modules` and merged in the order they are listed.
    *
    * @defaultValue ["//"]
    */
@@ -79,6 +81,19 @@ export interface RootSchema extends BaseSchema {
    */
   globalDependencies?: Array<string>;
 
+  /**
+   * A list of dotenv files, relative to the root of the repository, whose
+   * variables are included in the global hash. Unlike globalDependencies,
+   * only the parsed keys and values are hashed, so comments and formatting
+   * don't affect it. Files are ordered from most to least significant and
+   * missing files are skipped.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#globaldotenv
+   *
+   * @defaultValue []
+   */
+  globalDotEnv?: Array<string>;
+
   /**
    * A list of environment variables for implicit global hash dependencies.
    *
@@ -112,23 +127,32 @@ export interface RootSchema extends BaseSchema {
   globalPassThroughEnv?: null | Array<EnvWildcard>;
 
   /**
-   * A priority-ordered (most-significant to least-significant) array of project-anchored
-   * Unix-style paths to `.env` files to include in the global hash.
+   * Configuration options that control how turbo interfaces with the remote cache.
    *
-   * Documentation: https://turbo.build/repo/docs/reference/configuration#globalDotEnv
+   * Documentation: https://turbo.build/repo/docs/core-concepts/remote-caching
    *
-   * @defaultValue null
+   * @defaultValue `{}`
    */
-  globalDotEnv?: null | Array<AnchoredUnixPath>;
+  remoteCache?: RemoteCache;
 
   /**
-   * Configuration options that control how turbo interfaces with the remote cache.
+   * The directory of the local cache, relative to the repository root.
+   * `--cache-dir` takes precedence over it.
    *
-   * Documentation: https://turbo.build/repo/docs/core-concepts/remote-caching
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#cachedir
+   *
+   * @defaultValue `".turbo/cache"`
+   */
+  cacheDir?: string;
+
+  /**
+   * Configuration options that control how artifacts are stored in the cache.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#cacheoptions
    *
    * @defaultValue `{}`
    */
-  remoteCache?: RemoteCache;
+  cacheOptions?: CacheOptions;
 
   /**
    * Enable use of the UI for `turbo`.
@@ -138,6 +162,48 @@ export interface RootSchema extends BaseSchema {
    * @defaultValue `"tui"`
    */
   ui?: UI;
+
+  /**
+   * Executables to run at points during a run. Each hook receives a JSON
+   * description of the event on stdin.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#hooks
+   *
+   * @defaultValue `{}`
+   */
+  hooks?: Hooks;
+
+  /**
+   * Options for `turbo prune`.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#prune
+   *
+   * @defaultValue `{}`
+   */
+  prune?: Prune;
+
+  /**
+   * Named sets of task options that are layered on top of `tasks` when
+   * selected with `--profile-name` or `TURBO_PROFILE`.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#profiles
+   *
+   * @defaultValue `{}`
+   */
+  // eslint-disable-next-line @typescript-eslint/consistent-indexed-object-style -- it's more readable to specify a name for the key
+  profiles?: {
+    [profile: string]: Profile;
+  };
+
+  /**
+   * Include the modules of a `go.work` file or the members of a Cargo
+   * workspace in the package graph.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#experimentalworkspaceproviders
+   *
+   * @defaultValue `[]`
+   */
+  experimentalWorkspaceProviders?: Array<WorkspaceProvider>;
 }
 
 export type LegacyRootSchema = RootSchema & LegacyBaseSchema;
@@ -200,16 +266,6 @@ export interface Pipeline {
    */
   passThroughEnv?: null | Array<EnvWildcard>;
 
-  /**
-   * A priority-ordered (most-significant to least-significant) array of workspace-anchored
-   * Unix-style paths to `.env` files to include in the task hash.
-   *
-   * Documentation: https://turbo.build/repo/docs/reference/configuration#dotEnv
-   *
-   * @defaultValue null
-   */
-  dotEnv?: null | Array<AnchoredUnixPath>;
-
   /**
    * The set of glob patterns indicating a task's cacheable filesystem outputs.
    *
@@ -217,12 +273,29 @@ export interface Pipeline {
    * produce no artifacts other than logs (such as linters). Logs are always treated as a
    * cacheable artifact and never need to be specified.
    *
+   * Use the special string "$TURBO_DEFAULT$" to also cache the files ignored by git
+   * that the task creates or modifies.
+   *
    * Documentation: https://turbo.build/repo/docs/reference/configuration#outputs
    *
    * @defaultValue []
    */
   outputs?: Array<string>;
 
+  /**
+   * The set of glob patterns indicating task outputs that are only stored in
+   * the local cache and never uploaded to the Remote Cache.
+   *
+   * These files are cached in addition to the files matched by `outputs`.
+   * Use this for large intermediate artifacts that are worth caching locally,
+   * but too big to push.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#localonlyoutputs
+   *
+   * @defaultValue []
+   */
+  localOnlyOutputs?: Array<string>;
+
   /**
    * Whether or not to cache the outputs of the task.
    *
@@ -245,12 +318,67 @@ export interface Pipeline {
    *
    * If omitted or empty, all files in the package are considered as inputs.
    *
+   * Globs starting with "$TURBO_ROOT$/" are relative to the repository root
+   * instead of the package.
+   *
    * Documentation: https://turbo.build/repo/docs/reference/configuration#inputs
    *
    * @defaultValue []
    */
   inputs?: Array<string>;
 
+  /**
+   * Transforms to apply to the contents of this task's inputs before hashing.
+   *
+   * Each entry is a transform name, optionally followed by a comma-separated
+   * list of file extensions to apply it to, e.g. "stripLineComments:ts,tsx".
+   *
+   * Available transforms are "normalizeLineEndings", "trimTrailingWhitespace"
+   * and "stripLineComments".
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#inputtransforms
+   *
+   * @defaultValue []
+   */
+  inputTransforms?: Array<string>;
+
+  /**
+   * Commands that print the versions of tools this task uses, e.g.
+   * "node --version". Their output is included in the task's hash, so
+   * upgrading a tool invalidates the cache.
+   *
+   * Commands run in the directory of the package.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#tooldependencies
+   *
+   * @defaultValue []
+   */
+  toolDependencies?: Array<string>;
+
+  /**
+   * A list of dotenv files, relative to the package, whose variables are
+   * included in the task's hash. Use "$TURBO_ROOT$/" to refer to files at
+   * the root of the repository. The files themselves aren't hashed unless
+   * they're listed in inputs. Files are ordered from most to least
+   * significant and missing files are skipped.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#dotenv
+   *
+   * @defaultValue []
+   */
+  dotEnv?: Array<string>;
+
+  /**
+   * Add the variables from the task's dotEnv files, and then from the
+   * globalDotEnv files, to its environment. Variables that are already set
+   * are not overridden.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#injectdotenv
+   *
+   * @defaultValue false
+   */
+  injectDotEnv?: boolean;
+
   /**
    * Output mode for the task.
    *
@@ -262,6 +390,9 @@ export interface Pipeline {
    *
    * "errors-only": Only show output from task failures
    *
+   * "summary-line": Only show output from task failures, and one line with the
+   * duration of every other task
+   *
    * "none": Hides all task output
    *
    * Documentation: https://turbo.build/repo/docs/reference/run#--output-logs-option
@@ -281,6 +412,15 @@ export interface Pipeline {
    */
   persistent?: boolean;
 
+  /**
+   * How turbo knows that a persistent task is ready, so tasks that depend on
+   * it can start while it keeps running. Set exactly one of `port`,
+   * `logPattern` or `command`.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#ready
+   */
+  ready?: ReadyProbe;
+
   /**
    * Mark a task as interactive allowing it to receive input from stdin.
    * Interactive tasks must be marked with "cache": false as the input
@@ -289,6 +429,102 @@ export interface Pipeline {
    * Documentation: https://turbo.build/repo/docs/reference/configuration#interactive
    */
   interactive?: boolean;
+
+  /**
+   * Tasks that share a concurrency group are never run at the same time,
+   * even if the task graph would allow it. Persistent tasks cannot be
+   * part of a concurrency group.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#concurrencygroup
+   */
+  concurrencyGroup?: string;
+
+  /**
+   * How many of the slots allowed by `--concurrency` the task takes up while
+   * it runs. Give heavy tasks a higher weight so that fewer of them run at the
+   * same time. Persistent tasks always take up one slot.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#weight
+   *
+   * @defaultValue 1
+   */
+  weight?: number;
+
+  /**
+   * How long the task can run before it's terminated and marked as failed,
+   * written as a duration like "30s", "10m" or "1h 30m".
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#timeout
+   */
+  timeout?: string;
+
+  /**
+   * The command that runs the task's script instead of the package manager,
+   * such as "bun run" or "deno task". `{task}` is replaced with the name of the
+   * task and `{script}` with the script from `package.json`. Without either,
+   * the task name is appended to the command. An array of the program and its
+   * arguments is run without a shell.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#runner
+   */
+  runner?: string | Array<string>;
+
+  /**
+   * The shell that the commands of tasks with a `runner` command, and of packages
+   * found by a workspace provider, are started with. `false` runs them without a
+   * shell, and a shell can be set for each platform.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#shell
+   *
+   * @defaultValue true
+   */
+  shell?: boolean | TaskShell;
+
+  /**
+   * Descend into symlinked directories matched by `outputs`, so that the files
+   * inside them are cached instead of the links.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#followsymlinks
+   *
+   * @defaultValue false
+   */
+  followSymlinks?: boolean;
+
+  /**
+   * The compression used for the task's cache artifacts, in the form `zstd` or
+   * `zstd:<level>`. Overrides `cacheOptions.compression`.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#compression-1
+   */
+  compression?: string;
+
+  /**
+   * Only valid in a package's `turbo.json`. Replace the definition of the task
+   * inherited from the root `turbo.json` and any shareable configs instead of
+   * merging into it. Keys that aren't set use their defaults.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/package-configurations#overriding-a-task-completely
+   *
+   * @defaultValue false
+   */
+  override?: boolean;
+}
+
+export interface ReadyProbe {
+  /**
+   * Ready once something accepts connections on this port on localhost.
+   */
+  port?: number;
+
+  /**
+   * Ready once the task logs a line matching this regular expression.
+   */
+  logPattern?: string;
+
+  /**
+   * Ready once this command exits successfully. It's retried until it does.
+   */
+  command?: string;
 }
 
 export interface RemoteCache {
@@ -311,6 +547,207 @@ export interface RemoteCache {
    * @defaultValue true
    */
   enabled?: boolean;
+
+  /**
+   * When `true`, artifacts are downloaded from the remote cache but never uploaded.
+   * Useful for untrusted jobs, such as CI runs for pull requests from forks.
+   *
+   * @defaultValue false
+   */
+  readOnly?: boolean;
+
+  /**
+   * When `true`, artifacts are uploaded to the remote cache but never downloaded.
+   * Useful for trusted jobs that should always produce fresh artifacts.
+   * Cannot be combined with `readOnly`.
+   *
+   * @defaultValue false
+   */
+  writeOnly?: boolean;
+
+  /**
+   * The remote cache provider to use. `"vercel"` uses the Vercel Remote Cache API, while
+   * `"s3"` reads and writes artifacts directly to an S3 compatible bucket. Credentials for
+   * `"s3"` are read from `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY` and
+   * `AWS_SESSION_TOKEN`, or from the `AWS_PROFILE` profile of the shared AWS credentials
+   * file. Other sources, like SSO, assumed roles and instance metadata, aren't supported.
+   *
+   * Documentation: https://turbo.build/repo/docs/core-concepts/remote-caching#s3-compatible-buckets
+   *
+   * @defaultValue `"vercel"`
+   */
+  provider?: RemoteCacheProvider;
+
+  /**
+   * The bucket to store artifacts in. Required when `provider` is `"s3"`.
+   */
+  bucket?: string;
+
+  /**
+   * The region of the bucket. Falls back to `AWS_REGION`, then `AWS_DEFAULT_REGION`.
+   *
+   * @defaultValue `"us-east-1"`
+   */
+  region?: string;
+
+  /**
+   * A custom endpoint for S3 compatible services such as MinIO or Cloudflare R2.
+   * When set, requests use path-style addressing.
+   */
+  endpoint?: string;
+
+  /**
+   * A key prefix to store artifacts under within the bucket.
+   */
+  prefix?: string;
+
+  /**
+   * The number of times a failed artifact upload or download is retried. Requests are
+   * retried on connection errors, timeouts, and `429` or `5xx` responses.
+   *
+   * @defaultValue `2`
+   */
+  retryAttempts?: number;
+
+  /**
+   * The initial delay in seconds before retrying a failed artifact transfer. The delay
+   * doubles with each attempt and includes random jitter.
+   *
+   * @defaultValue `2`
+   */
+  retryBackoff?: number;
+
+  /**
+   * The maximum number of seconds to spend retrying a single artifact transfer. `0`
+   * disables the limit.
+   *
+   * @defaultValue `0`
+   */
+  retryMaxElapsed?: number;
+
+  /**
+   * The number of Remote Cache requests in a row that can't connect or time out
+   * before the Remote Cache is skipped for the rest of the run. `0` never skips it.
+   *
+   * @defaultValue `3`
+   */
+  failureLimit?: number;
+
+  /**
+   * The number of seconds to wait for a connection to the Remote Cache. `0` disables
+   * the timeout. Defaults to the value of `--remote-cache-timeout`.
+   */
+  connectTimeout?: number;
+
+  /**
+   * The number of seconds an artifact upload may take. `0` disables the timeout.
+   *
+   * @defaultValue `60`
+   */
+  uploadTimeout?: number;
+
+  /**
+   * The number of seconds to allow for downloading every 100MB of an artifact. `0`
+   * disables the timeout.
+   *
+   * @defaultValue `60`
+   */
+  downloadTimeout?: number;
+}
+
+export type RemoteCacheProvider = "vercel" | "s3";
+
+export interface TaskShell {
+  /**
+   * The shell used on Linux and macOS, with any arguments, e.g. "bash -e".
+   */
+  unix?: string;
+
+  /**
+   * The shell used on Windows, with any arguments, e.g. "pwsh".
+   */
+  windows?: string;
+}
+
+export interface CacheOptions {
+  /**
+   * The compression used for cache artifacts, in the form `zstd` or `zstd:<level>`.
+   * Levels range from 1 to 22. Higher levels produce smaller artifacts at the cost
+   * of more CPU time when saving to the cache.
+   *
+   * @defaultValue `"zstd"`
+   */
+  compression?: string;
+
+  /**
+   * Local cache directories that are searched, in order, after `cacheDir` and
+   * before the Remote Cache.
+   *
+   * @defaultValue `[]`
+   */
+  tiers?: Array<CacheTier>;
+}
+
+export interface CacheTier {
+  /**
+   * The directory of the tier, relative to the repository root.
+   */
+  dir: string;
+
+  /**
+   * Never write artifacts to this tier.
+   *
+   * @defaultValue `false`
+   */
+  readOnly?: boolean;
+}
+
+export interface Hooks {
+  /**
+   * Run once before any tasks are started.
+   */
+  preRun?: string;
+
+  /**
+   * Run after each task finishes, whether it was run or restored from the cache.
+   */
+  postTask?: string;
+
+  /**
+   * Run after the cache is checked for a task and once a task's outputs have
+   * been saved to every cache.
+   */
+  cacheEvent?: string;
+
+  /**
+   * The number of seconds a hook may run before it's killed.
+   *
+   * @defaultValue `30`
+   */
+  timeout?: number;
+}
+
+export interface Prune {
+  /**
+   * Globs of extra files and directories, relative to the root of the
+   * repository, to copy into the pruned output, e.g. `tsconfig.base.json`.
+   *
+   * @defaultValue `[]`
+   */
+  include?: Array<string>;
+}
+
+export interface Profile {
+  /**
+   * Task options to merge on top of the definitions of the tasks, keyed by
+   * task name or `package#task`.
+   *
+   * @defaultValue `{}`
+   */
+  // eslint-disable-next-line @typescript-eslint/consistent-indexed-object-style -- it's more readable to specify a name for the key
+  tasks?: {
+    [script: string]: Partial<Pipeline>;
+  };
 }
 
 export type OutputMode =
@@ -318,9 +755,12 @@ export type OutputMode =
   | "hash-only"
   | "new-only"
   | "errors-only"
+  | "summary-line"
   | "none";
 
 export type UI = "tui" | "stream";
 
+export type WorkspaceProvider = "go" | "cargo";
+
 export type AnchoredUnixPath = string;
 export type EnvWildcard = string;
@@ -0,0 +1,32 @@
+Setup
+  $ . ${TESTDIR}/../../helpers/setup_integration_test.sh
+
+List a filtered package
+  $ ${TURBO} ls --filter=my-app
+  1 package (npm)
+  
+  Name    Path         Tasks              Dependencies
+  my-app  apps/my-app  build, maybefails  util
+
+List packages as JSON
+  $ ${TURBO} ls --filter='./packages/*' --json
+  {
+    "packageManager": "npm",
+    "packages": [
+      {
+        "name": "another",
+        "path": "packages/another",
+        "tasks": [],
+        "dependencies": []
+      },
+      {
+        "name": "util",
+        "path": "packages/util",
+        "tasks": [
+          "build",
+          "maybefails"
+        ],
+        "dependencies": []
+      }
+    ]
+  }
@@ -102,6 +102,47 @@ Setup
       "configured": [],
       "inferred": [],
       "passthrough": null
+    },
+    "hashInputs": {
+      "globalHash": "[a-f0-9]+", (re)
+      "hashOfFiles": "[a-f0-9]+", (re)
+      "hashOfExternalDependencies": "459c029558afe716",
+      "packageDir": "apps/my-app",
+      "outputs": {
+        "inclusions": [
+          ".turbo/turbo-build.log",
+          "apple.json",
+          "banana.txt"
+        ],
+        "exclusions": []
+      },
+      "passThroughArgs": [],
+      "env": [],
+      "resolvedEnvVars": [],
+      "passThroughEnv": [],
+      "envMode": "strict",
+      "files": {
+        ".env.local": "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+        "package.json": "1746e0db2361085b5953a6a3beab08c24af5bc08"
+      },
+      "dependencies": {},
+      "resolvedTaskDefinition": {
+        "outputs": [
+          "apple.json",
+          "banana.txt"
+        ],
+        "cache": true,
+        "dependsOn": [],
+        "inputs": [
+          "$TURBO_DEFAULT$",
+          ".env.local"
+        ],
+        "outputLogs": "full",
+        "persistent": false,
+        "env": [],
+        "passThroughEnv": null,
+        "interactive": false
+      }
     }
   }
 
@@ -156,6 +197,42 @@ Setup
       "configured": [],
       "inferred": [],
       "passthrough": null
+    },
+    "hashInputs": {
+      "globalHash": "[a-f0-9]+", (re)
+      "hashOfFiles": "[a-f0-9]+", (re)
+      "hashOfExternalDependencies": "459c029558afe716",
+      "packageDir": "packages/util",
+      "outputs": {
+        "inclusions": [
+          ".turbo/turbo-build.log"
+        ],
+        "exclusions": []
+      },
+      "passThroughArgs": [],
+      "env": [
+        "NODE_ENV"
+      ],
+      "resolvedEnvVars": [],
+      "passThroughEnv": [],
+      "envMode": "strict",
+      "files": {
+        "package.json": "e755064fd7893809d10fc067bb409c7ae516327f"
+      },
+      "dependencies": {},
+      "resolvedTaskDefinition": {
+        "outputs": [],
+        "cache": true,
+        "dependsOn": [],
+        "inputs": [],
+        "outputLogs": "full",
+        "persistent": false,
+        "env": [
+          "NODE_ENV"
+        ],
+        "passThroughEnv": null,
+        "interactive": false
+      }
     }
   }
 
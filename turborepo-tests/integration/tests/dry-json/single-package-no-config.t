@@ -77,6 +77,41 @@ Setup
           "configured": [],
           "inferred": [],
           "passthrough": null
+        },
+        "hashInputs": {
+          "globalHash": "[a-f0-9]+", (re)
+          "hashOfFiles": "[a-f0-9]+", (re)
+          "hashOfExternalDependencies": null,
+          "packageDir": null,
+          "outputs": {
+            "inclusions": [
+              ".turbo/turbo-build.log"
+            ],
+            "exclusions": []
+          },
+          "passThroughArgs": [],
+          "env": [],
+          "resolvedEnvVars": [],
+          "passThroughEnv": [],
+          "envMode": "strict",
+          "files": {
+            ".gitignore": "03b541460c1b836f96f9c0a941ceb48e91a9fd83",
+            "package-lock.json": "1c117cce37347befafe3a9cba1b8a609b3600021",
+            "package.json": "8606ff4b95a5330740d8d9d0948faeada64f1f32",
+            "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057"
+          },
+          "dependencies": {},
+          "resolvedTaskDefinition": {
+            "outputs": [],
+            "cache": false,
+            "dependsOn": [],
+            "inputs": [],
+            "outputLogs": "full",
+            "persistent": false,
+            "env": [],
+            "passThroughEnv": null,
+            "interactive": false
+          }
         }
       }
     ],
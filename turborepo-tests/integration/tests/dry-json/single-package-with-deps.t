@@ -33,13 +33,12 @@ Setup
       {
         "taskId": "build",
         "task": "build",
-        "hash": "7ece7b62aad25615",
+        "hash": "254363d40da29250",
         "inputs": {
           ".gitignore": "03b541460c1b836f96f9c0a941ceb48e91a9fd83",
           "package-lock.json": "1c117cce37347befafe3a9cba1b8a609b3600021",
           "package.json": "8606ff4b95a5330740d8d9d0948faeada64f1f32",
-          "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057",
-          "turbo.json": "ce5bdbed55601768de641f5d8d005a8f5be8d3f7"
+          "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057"
         },
         "hashOfExternalDependencies": "",
         "cache": {
@@ -83,18 +82,55 @@ Setup
           "configured": [],
           "inferred": [],
           "passthrough": null
+        },
+        "hashInputs": {
+          "globalHash": "[a-f0-9]+", (re)
+          "hashOfFiles": "[a-f0-9]+", (re)
+          "hashOfExternalDependencies": null,
+          "packageDir": null,
+          "outputs": {
+            "inclusions": [
+              ".turbo/turbo-build.log",
+              "foo.txt"
+            ],
+            "exclusions": []
+          },
+          "passThroughArgs": [],
+          "env": [],
+          "resolvedEnvVars": [],
+          "passThroughEnv": [],
+          "envMode": "strict",
+          "files": {
+            ".gitignore": "03b541460c1b836f96f9c0a941ceb48e91a9fd83",
+            "package-lock.json": "1c117cce37347befafe3a9cba1b8a609b3600021",
+            "package.json": "8606ff4b95a5330740d8d9d0948faeada64f1f32",
+            "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057"
+          },
+          "dependencies": {},
+          "resolvedTaskDefinition": {
+            "outputs": [
+              "foo.txt"
+            ],
+            "cache": true,
+            "dependsOn": [],
+            "inputs": [],
+            "outputLogs": "full",
+            "persistent": false,
+            "env": [],
+            "passThroughEnv": null,
+            "interactive": false
+          }
         }
       },
       {
         "taskId": "test",
         "task": "test",
-        "hash": "cb5839f7284aa5f3",
+        "hash": "3ee3e60d7556092b",
         "inputs": {
           ".gitignore": "03b541460c1b836f96f9c0a941ceb48e91a9fd83",
           "package-lock.json": "1c117cce37347befafe3a9cba1b8a609b3600021",
           "package.json": "8606ff4b95a5330740d8d9d0948faeada64f1f32",
-          "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057",
-          "turbo.json": "ce5bdbed55601768de641f5d8d005a8f5be8d3f7"
+          "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057"
         },
         "hashOfExternalDependencies": "",
         "cache": {
@@ -136,6 +172,45 @@ Setup
           "configured": [],
           "inferred": [],
           "passthrough": null
+        },
+        "hashInputs": {
+          "globalHash": "[a-f0-9]+", (re)
+          "hashOfFiles": "[a-f0-9]+", (re)
+          "hashOfExternalDependencies": null,
+          "packageDir": null,
+          "outputs": {
+            "inclusions": [
+              ".turbo/turbo-test.log"
+            ],
+            "exclusions": []
+          },
+          "passThroughArgs": [],
+          "env": [],
+          "resolvedEnvVars": [],
+          "passThroughEnv": [],
+          "envMode": "strict",
+          "files": {
+            ".gitignore": "03b541460c1b836f96f9c0a941ceb48e91a9fd83",
+            "package-lock.json": "1c117cce37347befafe3a9cba1b8a609b3600021",
+            "package.json": "8606ff4b95a5330740d8d9d0948faeada64f1f32",
+            "somefile.txt": "45b983be36b73c0788dc9cbcb76cbb80fc7bb057"
+          },
+          "dependencies": {
+            "build": "254363d40da29250"
+          },
+          "resolvedTaskDefinition": {
+            "outputs": [],
+            "cache": true,
+            "dependsOn": [
+              "build"
+            ],
+            "inputs": [],
+            "outputLogs": "full",
+            "persistent": false,
+            "env": [],
+            "passThroughEnv": null,
+            "interactive": false
+          }
         }
       }
     ],
@@ -81,3 +81,19 @@ Change util#build impacts itself and my-app
     "taskId": "util#build",
     "hash": "74c8eb9bab702b4b"
   }
+
+Adding a task or changing keys that aren't hashed doesn't change the hashes of other tasks
+  $ cp "$TESTDIR/fixture-configs/f-add-task.json" "$(pwd)/turbo.json" && git commit -am "no comment" --quiet
+  $ ${TURBO} build --dry=json | jq -r '.tasks | sort_by(.taskId)[] | {taskId, hash}'
+  {
+    "taskId": "another#build",
+    "hash": "3639431fdcdf9f9e"
+  }
+  {
+    "taskId": "my-app#build",
+    "hash": "2721f01b53b758d0"
+  }
+  {
+    "taskId": "util#build",
+    "hash": "74c8eb9bab702b4b"
+  }
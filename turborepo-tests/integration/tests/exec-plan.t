@@ -0,0 +1,35 @@
+Setup
+  $ . ${TESTDIR}/../../helpers/setup_integration_test.sh strict_env_vars
+Set the output file with the right path separator for the OS
+  $ if [[ "$OSTYPE" == "msys" ]]; then OUTPUT="apps\\my-app\\out.txt"; else OUTPUT="apps/my-app/out.txt"; fi
+
+Each entry's env is passed to its tasks in strict mode and changes their hash
+  $ cat > plan.json <<PLAN
+  > {"entries": [
+  >   {"tasks": ["build"], "env": {"OTHER_VAR": "first"}},
+  >   {"tasks": ["build"], "env": {"OTHER_VAR": "second"}}
+  > ]}
+  > PLAN
+  $ ${TURBO} exec-plan plan.json --env-mode=strict --output-logs=hash-only | grep "my-app:build"
+  my-app:build: cache miss, executing [0-9a-f]{16} (re)
+  my-app:build: cache miss, executing [0-9a-f]{16} (re)
+  $ cat "$OUTPUT"
+  globalpt: '', localpt: '', globaldep: '', localdep: '', other: 'second', sysroot set: 'yes', path set: 'yes'
+
+Running the plan again hits the cache for both entries
+  $ ${TURBO} exec-plan plan.json --env-mode=strict --output-logs=hash-only | grep "my-app:build"
+  my-app:build: cache hit, suppressing logs [0-9a-f]{16} (re)
+  my-app:build: cache hit, suppressing logs [0-9a-f]{16} (re)
+
+Entries without env aren't affected by the ones before them
+  $ cat > plan.json <<PLAN
+  > {"entries": [
+  >   {"tasks": ["build"], "env": {"OTHER_VAR": "first"}},
+  >   {"tasks": ["build"]}
+  > ]}
+  > PLAN
+  $ ${TURBO} exec-plan plan.json --env-mode=strict --output-logs=hash-only | grep "my-app:build"
+  my-app:build: cache hit, suppressing logs [0-9a-f]{16} (re)
+  my-app:build: cache miss, executing [0-9a-f]{16} (re)
+  $ cat "$OUTPUT"
+  globalpt: '', localpt: '', globaldep: '', localdep: '', other: '', sysroot set: 'yes', path set: 'yes'
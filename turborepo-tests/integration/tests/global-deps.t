@@ -32,6 +32,6 @@ Run a build
   my-app:build: cache hit, suppressing logs 3883869b5e1dc9cf
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
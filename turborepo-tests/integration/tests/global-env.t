@@ -22,7 +22,7 @@ Setup
   util:build: cache hit, suppressing logs bf1798d3e46e1b48
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # set global env var and ensure cache miss
@@ -44,7 +44,7 @@ Setup
   util:build: cache hit, suppressing logs bf1798d3e46e1b48
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # set vercel analytics env var and ensure cache miss
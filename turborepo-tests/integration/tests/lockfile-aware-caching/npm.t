@@ -50,7 +50,7 @@ Only b should have a cache miss
   a:build: building
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 
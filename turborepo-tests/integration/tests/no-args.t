@@ -8,43 +8,79 @@ Make sure exit code is 2 when no args are passed
   Usage: turbo(\.exe)? \[OPTIONS\] \[COMMAND\] (re)
   
   Commands:
-    bin         Get the path to the Turbo binary
-    completion  Generate the autocompletion script for the specified shell
-    daemon      Runs the Turborepo background daemon
-    generate    Generate a new app / package
-    telemetry   Enable or disable anonymous telemetry
-    scan        Turbo your monorepo by running a number of 'repo lints' to identify common issues, suggest fixes, and improve performance
-    link        Link your local directory to a Vercel organization and enable remote caching
-    login       Login to your Vercel account
-    logout      Logout to your Vercel account
-    prune       Prepare a subset of your monorepo
-    run         Run tasks across projects in your monorepo
-    watch       Arguments used in run and watch
-    unlink      Unlink the current directory from your Vercel organization and disable Remote Caching
+    bin           Get the path to the Turbo binary
+    cache         Inspect and manage the artifacts in the local cache
+    completion    Generate the autocompletion script for the specified shell
+    config        Inspect the configuration of turbo
+    daemon        Runs the Turborepo background daemon
+    diff          Explain why the task hashes of two run summaries differ
+    exec-plan     Run several sets of tasks from a JSON plan in a single process
+    generate      Generate a new app / package
+    telemetry     Enable or disable anonymous telemetry
+    graph         Print the task graph for the given tasks without running them
+    scan          Turbo your monorepo by running a number of 'repo lints' to identify common issues, suggest fixes, and improve performance
+    link          Link your local directory to a Vercel organization and enable remote caching
+    lint-config   Check turbo.json and package scripts for common configuration mistakes
+    login         Login to your Vercel account
+    logout        Logout to your Vercel account
+    ls            List the packages in your monorepo with their tasks and dependencies
+    prune         Prepare a subset of your monorepo
+    remote-cache  Check the connection to your remote cache
+    run           Run tasks across projects in your monorepo
+    watch         Arguments used in run and watch
+    unlink        Unlink the current directory from your Vercel organization and disable Remote Caching
   
   Options:
-        --version                         
-        --skip-infer                      Skip any attempts to infer which version of Turbo the project is configured to use
-        --no-update-notifier              Disable the turbo update notification
-        --api <API>                       Override the endpoint for API calls
-        --color                           Force color usage in the terminal
-        --cwd <CWD>                       The directory in which to run turbo
-        --heap <HEAP>                     Specify a file to save a pprof heap profile
-        --login <LOGIN>                   Override the login endpoint
-        --no-color                        Suppress color usage in the terminal
-        --preflight                       When enabled, turbo will precede HTTP requests with an OPTIONS request for authorization
-        --remote-cache-timeout <TIMEOUT>  Set a timeout for all HTTP requests
-        --team <TEAM>                     Set the team slug for API calls
-        --token <TOKEN>                   Set the auth token for API calls
-        --trace <TRACE>                   Specify a file to save a pprof trace
-        --verbosity <COUNT>               Verbosity level
-    -h, --help                            Print help
+        --version
+            
+        --skip-infer
+            Skip any attempts to infer which version of Turbo the project is configured to use
+        --no-update-notifier
+            Disable the turbo update notification
+        --api <API>
+            Override the endpoint for API calls
+        --color
+            Force color usage in the terminal
+        --cwd <CWD>
+            The directory in which to run turbo
+        --error-format <ERROR_FORMAT>
+            Print errors that make turbo exit in the given format [default: human] [possible values: human, json]
+        --heap <HEAP>
+            Specify a file to save a pprof heap profile
+        --login <LOGIN>
+            Override the login endpoint
+        --no-color
+            Suppress color usage in the terminal
+        --preflight
+            When enabled, turbo will precede HTTP requests with an OPTIONS request for authorization
+        --experimental-remote-cache-signature
+            Sign artifacts uploaded to the remote cache and verify the signature of artifacts downloaded from it. The signing key is read from `TURBO_REMOTE_CACHE_SIGNATURE_KEY`
+        --remote-cache-timeout <TIMEOUT>
+            Set a timeout for all HTTP requests
+        --cacert <PATH>
+            Path to a PEM file of certificate authorities to trust for HTTP requests, in addition to the system's
+        --insecure-skip-tls-verify
+            Skip verification of TLS certificates for HTTP requests. Only use this for debugging
+        --team <TEAM>
+            Set the team slug for API calls
+        --token <TOKEN>
+            Set the auth token for API calls
+        --trace <TRACE>
+            Specify a file to save a pprof trace
+        --verbosity <COUNT>
+            Verbosity level
+    -h, --help
+            Print help
   
   Run Arguments:
         --cache-workers <CACHE_WORKERS>
             Set the number of concurrent cache operations (default 10) [default: 10]
         --dry-run [<DRY_RUN>]
             [possible values: text, json]
+        --hash-only
+            Print the hash of each task and exit without running tasks or checking the cache
+        --check-cache-only
+            Check whether each task is cached and exit without restoring outputs or running tasks. Exits with 1 if any task is not cached
         --graph [<GRAPH>]
             Generate a graph of the task execution and output to a file when a filename is specified (.svg, .png, .jpg, .pdf, .json, .html, .mermaid, .dot). Outputs dot graph to stdout when if no filename is provided
         --no-cache
@@ -56,17 +92,47 @@ Make sure exit code is 2 when no args are passed
         --anon-profile <ANON_PROFILE>
             File to write turbo's performance profile output into. All identifying data omitted from the profile
         --remote-cache-read-only [<BOOL>]
-            Treat remote cache as read only [env: TURBO_REMOTE_CACHE_READ_ONLY=] [default: false] [possible values: true, false]
+            Treat remote cache as read only [env: TURBO_REMOTE_CACHE_READ_ONLY=] [possible values: true, false]
+        --remote-cache-write-only [<BOOL>]
+            Treat remote cache as write only [env: TURBO_REMOTE_CACHE_WRITE_ONLY=] [possible values: true, false]
+        --remote-cache-upload-concurrency <N>
+            Set the maximum number of artifacts uploaded to the remote cache at the same time. Uploads also count towards --cache-workers. (default the number of cache workers) [env: TURBO_REMOTE_CACHE_UPLOAD_CONCURRENCY=]
         --summarize [<SUMMARIZE>]
             Generate a summary of the turbo run [env: TURBO_RUN_SUMMARY=] [possible values: true, false]
+        --cache-analytics [<BOOL>]
+            Send the number of cache hits and the time and bytes that the cache saved to the remote cache after the run. No task names or hashes are sent [env: TURBO_CACHE_ANALYTICS=] [default: false] [possible values: true, false]
+        --env-hash-salt <SALT>
+            Salt the hashes of environment variable values in run summaries and --dry=json. Values are comparable across machines that use the same salt, without being guessable by anyone who doesn't know it [env: TURBO_ENV_HASH_SALT]
+        --env-audit
+            Warn about the environment variables that tasks read without declaring them. Only the variables read by Node.js processes are recorded
+        --otel-exporter-endpoint <URL>
+            Export a trace of the run to an OpenTelemetry collector. Spans are sent with OTLP over HTTP to <URL>/v1/traces [env: TURBO_OTEL_EXPORTER_ENDPOINT=]
+        --experimental-executor <URL>
+            Send tasks to the executor at <URL> to run them on remote workers. Outputs are shared through the remote cache. Experimental [env: TURBO_EXPERIMENTAL_EXECUTOR=]
         --parallel
-            Execute all tasks in parallel
+            Execute all tasks in parallel. Deprecated, use --dependency-mode=ignore-topology instead
+        --resume
+            Skip the tasks that completed successfully in the previous run, e.g. after it was interrupted. Tasks whose hash changed are run again
+        --time-budget <DURATION>
+            Predict how long the run will take from the durations of tasks in previous run summaries, and fail before running any tasks if it's longer than <DURATION>
+        --time-budget-mode <MODE>
+            Whether to fail or only warn when the run is predicted to take longer than --time-budget [default: fail] [possible values: fail, warn]
         --cache-dir <CACHE_DIR>
             Override the filesystem cache directory [env: TURBO_CACHE_DIR=]
+        --cache-max-size <CACHE_MAX_SIZE>
+            Set the maximum size of the filesystem cache (e.g. 500MB, 10GB). Once the cache grows past this size, the least recently used artifacts are removed [env: TURBO_CACHE_MAX_SIZE=]
+        --cache-skip-unchanged
+            When restoring outputs from the cache, only write the files that differ from the ones already on disk [env: TURBO_CACHE_SKIP_UNCHANGED=]
+        --cache-key-prefix <PREFIX>
+            Mix a prefix into the hashes of tasks so that their cache artifacts are only shared with runs that use the same prefix (e.g. a branch or deployment environment) [env: TURBO_CACHE_KEY_PREFIX=]
+        --strict-cache
+            Fail tasks on cache problems that are otherwise only warned about: artifacts that don't match their checksum or signature, artifacts that would restore files outside of the repository, and outputs that a task didn't write [env: TURBO_STRICT_CACHE=]
         --concurrency <CONCURRENCY>
             Limit the concurrency of task execution. Use 1 for serial (i.e. one-at-a-time) execution
-        --continue
-            Continue execution even if a task exits with an error or non-zero exit code. The default behavior is to bail
+        --continue[=<CONTINUE_EXECUTION>]
+            Continue execution even if a task exits with an error or non-zero exit code. Use "dependencies-successful" to only skip the tasks that depend on a failed task. The default behavior is to bail [default: never] [possible values: never, dependencies-successful, always]
+        --dependency-mode <DEPENDENCY_MODE>
+            Choose which task dependencies are run. Use "ignore-topology" to skip dependencies on tasks in other packages declared with "^". Use "only-direct" to run the direct dependencies of the specified tasks, but not their dependencies [default: all] [possible values: all, ignore-topology, only-direct]
         --single-package
             Run turbo in single-package mode
         --force [<FORCE>]
@@ -79,16 +145,38 @@ Make sure exit code is 2 when no args are passed
             Environment variable mode. Use "loose" to pass the entire existing environment. Use "strict" to use an allowlist specified in turbo.json [default: strict] [possible values: loose, strict]
     -F, --filter <FILTER>
             Use the given selector to specify package(s) to act as entry points. The syntax mirrors pnpm's syntax, and additional documentation and examples can be found in turbo's documentation https://turbo.build/repo/docs/reference/command-line-reference/run#--filter
+        --affected
+            Run only the tasks of packages that have changed compared to the base branch, along with the packages that depend on them. Uncommitted changes are included
+        --affected-base <REF>
+            The git ref that --affected compares against (default origin/main) [env: TURBO_SCM_BASE=]
+        --explain-filter
+            Print the packages each filter matched and the packages that were selected once all filters were applied
+        --auto-deepen
+            Fetch more history when a commit that a filter or --affected compares against is missing from a shallow clone
         --output-logs <OUTPUT_LOGS>
-            Set type of process output logging. Use "full" to show all output. Use "hash-only" to show only turbo-computed task hashes. Use "new-only" to show only new output with only hashes for cached tasks. Use "none" to hide process output. (default full) [possible values: full, none, hash-only, new-only, errors-only]
+            Set type of process output logging. Use "full" to show all output. Use "hash-only" to show only turbo-computed task hashes. Use "new-only" to show only new output with only hashes for cached tasks. Use "none" to hide process output. (default full) [possible values: full, none, hash-only, new-only, errors-only, summary-line]
         --log-order <LOG_ORDER>
             Set type of task output order. Use "stream" to show output as soon as it is available. Use "grouped" to show output when a command has finished execution. Use "auto" to let turbo decide based on its own heuristics. (default auto) [env: TURBO_LOG_ORDER=] [default: auto] [possible values: auto, stream, grouped]
+        --ui <UI>
+            Specify whether to use the terminal UI or stream task logs. The terminal UI is only used if stdout is a TTY [possible values: tui, stream]
         --only
             Only executes the tasks specified, does not execute parent tasks
         --remote-only [<BOOL>]
             Ignore the local filesystem cache for all tasks. Only allow reading and caching artifacts using the remote cache [env: TURBO_REMOTE_ONLY=] [default: false] [possible values: true, false]
+        --shutdown-grace-period <MS>
+            How long, in milliseconds, to wait for tasks to exit after turbo is interrupted before they are forcibly killed. (default 500) [env: TURBO_SHUTDOWN_GRACE_PERIOD=]
+        --task-timeout <DURATION>
+            Terminate tasks that run for longer than the given duration (e.g. 30m, 1h 30m) and mark them as failed. Overrides the `timeout` of every task that isn't persistent [env: TURBO_TASK_TIMEOUT=]
+        --interactive <TASK>
+            Forward turbo's stdin to the given task, e.g. to answer prompts from a persistent task. Only used when the terminal UI isn't, since the UI forwards input to whichever task is selected
+        --strict-engines
+            Fail before running any tasks if the installed package manager or Node.js don't match the `packageManager` field or `engines.node` range in the root package.json
+        --profile-name <NAME>
+            Layer the task options of the named profile in turbo.json on top of the task definitions, e.g. to change caching and logging in CI [env: TURBO_PROFILE=]
+        --log-dir[=<DIR>]
+            Write the output of every task to <DIR>/<package>/<task>.log, even if --output-logs hides it. Uses .turbo/logs in the repository root if no directory is given [env: TURBO_LOG_DIR=]
         --log-prefix <LOG_PREFIX>
-            Use "none" to remove prefixes from task logs. Use "task" to get task id prefixing. Use "auto" to let turbo decide how to prefix the logs based on the execution environment. In most cases this will be the same as "task". Note that tasks running in parallel interleave their logs, so removing prefixes can make it difficult to associate logs with tasks. Use --log-order=grouped to prevent interleaving. (default auto) [default: auto] [possible values: auto, none, task]
+            Use "none" to remove prefixes from task logs. Use "task" to get task id prefixing, or "package" to only prefix with the package name. Use "template:<template>" to write your own prefix, where {package} and {task} are replaced, e.g. --log-prefix='template:[{package}] '. Use "auto" to let turbo decide how to prefix the logs based on the execution environment. In most cases this will be the same as "task". Note that tasks running in parallel interleave their logs, so removing prefixes can make it difficult to associate logs with tasks. Use --log-order=grouped to prevent interleaving. (default auto) [default: auto]
   [1]
 
   $ ${TURBO} run
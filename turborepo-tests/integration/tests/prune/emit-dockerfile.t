@@ -0,0 +1,34 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh monorepo_with_root_dep pnpm@7.25.1
+
+  $ ${TURBO} prune web --docker --emit-dockerfile
+  Generating pruned monorepo for web in .*out (re)
+   - Added shared
+   - Added util
+   - Added web
+   - Wrote out/Dockerfile
+  $ cat out/Dockerfile
+  # Generated by `turbo prune web --docker --emit-dockerfile`
+  #
+  # Build it from the pruned output:
+  #   docker build -f out/Dockerfile out
+  
+  FROM node:20-alpine AS base
+  RUN apk add --no-cache libc6-compat
+  RUN corepack enable
+  WORKDIR /app
+  
+  # Only the package.json files and the lockfile, so that dependencies are only
+  # reinstalled when they change
+  FROM base AS installer
+  COPY json/ .
+  RUN pnpm install --frozen-lockfile
+  
+  FROM base AS builder
+  COPY --from=installer /app/ .
+  COPY full/ .
+  RUN pnpm turbo run build --filter=web...
+
+The Dockerfile needs the json and full directories
+  $ ${TURBO} prune web --emit-dockerfile > /dev/null 2>&1
+  [2]
@@ -0,0 +1,33 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh monorepo_with_root_dep pnpm@7.25.1
+
+  $ mkdir config
+  $ echo '{}' > config/tsconfig.base.json
+  $ echo 'node_modules' > .dockerignore
+
+Included files and directories are copied into the output
+  $ ${TURBO} prune web --include=config --include=.dockerignore
+  Generating pruned monorepo for web in .*out (re)
+   - Added shared
+   - Added util
+   - Added web
+  $ cat out/config/tsconfig.base.json
+  {}
+  $ cat out/.dockerignore
+  node_modules
+
+With --docker, they're in both the json and full directories
+  $ rm -rf out
+  $ ${TURBO} prune web --docker --include=config --include=.dockerignore
+  Generating pruned monorepo for web in .*out (re)
+   - Added shared
+   - Added util
+   - Added web
+  $ cat out/json/config/tsconfig.base.json
+  {}
+  $ cat out/full/config/tsconfig.base.json
+  {}
+  $ cat out/json/.dockerignore
+  node_modules
+  $ cat out/full/.dockerignore
+  node_modules
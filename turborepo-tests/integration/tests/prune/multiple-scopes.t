@@ -0,0 +1,26 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh monorepo_with_root_dep pnpm@7.25.1
+
+Make sure that multiple workspaces can be pruned at once
+  $ ${TURBO} prune web docs
+  Generating pruned monorepo for web, docs in .*(\/|\\)out (re)
+   - Added docs
+   - Added shared
+   - Added util
+   - Added web
+  $ ls out/apps
+  docs
+  web
+
+Make sure that the pruned lockfile contains the external dependencies of all workspaces
+  $ grep "/is-number/" out/pnpm-lock.yaml
+    /is-number/7.0.0_4fcx2ubzko3upkndnus4sjwpd4:
+  $ rm -rf out
+
+Make sure that the deprecated --scope flag can be passed multiple times
+  $ ${TURBO} prune --scope=web --scope=docs
+  Generating pruned monorepo for web, docs in .*(\/|\\)out (re)
+   - Added docs
+   - Added shared
+   - Added util
+   - Added web
@@ -33,3 +33,18 @@ Validate that local cache is true in dry run
     "source": "LOCAL",
     "timeSaved": [0-9]+ (re)
   }
+
+Fix the durations in the cache metadata so that the time saved is known
+  $ for hash in $(cat dry.json | jq -r '.tasks[] | select(.cache.local) | .hash'); do
+  >   jq '.duration = 41725' ".turbo/cache/$hash-meta.json" > meta.json
+  >   mv meta.json ".turbo/cache/$hash-meta.json"
+  > done
+  $ ${TURBO} run build --output-logs=none
+  \xe2\x80\xa2 Packages in scope: another, my-app, util (esc)
+  \xe2\x80\xa2 Running build in 3 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  
+   Tasks:    2 successful, 2 total
+  Cached:    2 cached, 2 total, saved ~1m23.45s
+    Time:\s+[.0-9]+m?s >>> FULL TURBO (re)
+  
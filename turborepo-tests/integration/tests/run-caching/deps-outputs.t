@@ -0,0 +1,47 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+
+my-app hashes the build outputs of util, which it depends on, in place of util's hash
+  $ echo '{"tasks": {"build": {"dependsOn": ["^build"], "inputs": ["$TURBO_DEFAULT$", "$DEPS_OUTPUTS$"]}, "util#build": {"outputs": ["dist/**"]}}}' > turbo.json
+  $ jq '.scripts.build = "mkdir -p dist && echo util > dist/index.js"' packages/util/package.json > package.json.new
+  $ mv package.json.new packages/util/package.json
+  $ echo "dist/" >> .gitignore
+  $ git add . && git commit -m "build util to dist" --quiet
+
+  $ ${TURBO} run build --filter=my-app --output-logs=errors-only
+  \xe2\x80\xa2 Packages in scope: my-app (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  
+   Tasks:    2 successful, 2 total
+  Cached:    0 cached, 2 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
+
+Changing util's README misses util's cache, but its outputs are the same so my-app is a hit.
+A dry run computes the same hash for my-app as the run that follows it.
+  $ echo "# util" > packages/util/README.md
+  $ ${TURBO} run build --filter=my-app --dry=json | jq -r '.tasks[] | select(.taskId == "my-app#build") | .cache.status'
+  HIT
+  $ ${TURBO} run build --filter=my-app --output-logs=errors-only
+  \xe2\x80\xa2 Packages in scope: my-app (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  
+   Tasks:    2 successful, 2 total
+  Cached:    1 cached, 2 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
+
+Changing util's outputs misses my-app's cache
+  $ jq '.scripts.build = "mkdir -p dist && echo changed > dist/index.js"' packages/util/package.json > package.json.new
+  $ mv package.json.new packages/util/package.json
+  $ ${TURBO} run build --filter=my-app --output-logs=errors-only
+  \xe2\x80\xa2 Packages in scope: my-app (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  
+   Tasks:    2 successful, 2 total
+  Cached:    0 cached, 2 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
@@ -19,7 +19,7 @@ Run again to get cache hit
   \xe2\x80\xa2 Remote caching disabled (esc)
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 
@@ -0,0 +1,42 @@
+Setup
+  $ . ${TESTDIR}/../../../../helpers/setup_integration_test.sh
+
+  $ cp ${TESTDIR}/turbo.json $TARGET_DIR/turbo.json # overwrite
+  $ git commit --quiet -am "Exclude log files from outputs"
+
+Build my-app to populate the cache
+  $ ${TURBO} run build --filter=my-app --output-logs=hash-only
+  \xe2\x80\xa2 Packages in scope: my-app (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  my-app:build: cache miss, executing [0-9a-f]+ (re)
+  
+   Tasks:    1 successful, 1 total
+  Cached:    0 cached, 1 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
+The outputs exclude the log, so only its own artifact holds it
+  $ tar -tf .turbo/cache/*-log.tar.zst
+  apps/my-app/.turbo/turbo-build.log
+  $ ls .turbo/cache/*.tar.zst | grep -v -- '-log.tar.zst$' | xargs -n1 tar -tf | grep -c turbo-build.log
+  0
+  [1]
+
+Remove the log file, as if the artifact was produced by another machine. The
+log is restored from its artifact, so it can still be replayed.
+  $ rm -rf apps/my-app/.turbo
+  $ ${TURBO} run build --filter=my-app
+  \xe2\x80\xa2 Packages in scope: my-app (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  my-app:build: cache hit, replaying logs [0-9a-f]+ (re)
+  my-app:build: 
+  my-app:build: > build
+  my-app:build: > echo building
+  my-app:build: 
+  my-app:build: building
+  
+   Tasks:    1 successful, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
+    Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
+  
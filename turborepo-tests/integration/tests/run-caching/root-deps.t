@@ -25,7 +25,7 @@ Confirm cache hit
   another:build: cache hit, suppressing logs 6a4c300cb14847b0
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s+[.0-9]+m?s >>> FULL TURBO (re)
   
 
@@ -65,7 +65,7 @@ Cache hit since only tracked files contribute to root dep hash
   another:build: cache hit, suppressing logs 34787620f332fb95
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 
@@ -0,0 +1,25 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+
+Run a build to get a local cache.
+  $ ${TURBO} run build --filter=my-app --output-logs=none > /dev/null
+
+Corrupt the cached artifact of my-app#build
+  $ HASH=$(${TURBO} run build --filter=my-app --dry=json | jq -r '.tasks | map(select(.taskId == "my-app#build")) | .[0].hash')
+  $ ARTIFACT=$(ls .turbo/cache/$HASH.tar*)
+  $ head -c 100 $ARTIFACT > truncated && mv truncated $ARTIFACT
+
+Without --strict-cache the corrupted artifact is a cache miss
+  $ cp $ARTIFACT corrupted
+  $ ${TURBO} run build --filter=my-app --output-logs=none > out.txt 2>&1
+  $ grep "Cached:" out.txt
+  Cached:    0 cached, 1 total
+
+With --strict-cache the task fails instead
+  $ cp corrupted $ARTIFACT
+  $ ${TURBO} run build --filter=my-app --output-logs=none --strict-cache > out.txt 2>&1
+  [1]
+  $ grep -q "cache error" out.txt
+
+The corrupted artifact is kept
+  $ cmp corrupted $ARTIFACT
@@ -90,3 +90,15 @@ Setup
 
 
 
+
+# [x] --output-logs=summary-line prints a line for each successful task
+  $ ${TURBO} run build --output-logs=summary-line
+  \xe2\x80\xa2 Packages in scope: app-a (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  app-a:build: app-a#build completed in .+ \(cache (hit|miss)\) (re)
+  
+   Tasks:    1 successful, 1 total
+  Cached:    [01] cached, 1 total.* (re)
+    Time:.* (re)
+  
@@ -0,0 +1,21 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh run_logging
+
+Logs are written to the log directory even if they're hidden
+  $ ${TURBO} run build --output-logs=none --log-dir > /dev/null
+  $ cat .turbo/logs/app-a/build.log
+  
+  \> build (re)
+  \> echo build-app-a (re)
+  
+  build-app-a
+
+Cache hits copy the logs from the cache
+  $ rm -r .turbo/logs
+  $ ${TURBO} run build --output-logs=none --log-dir=ci-logs > /dev/null
+  $ cat ci-logs/app-a/build.log
+  
+  \> build (re)
+  \> echo build-app-a (re)
+  
+  build-app-a
@@ -0,0 +1,67 @@
+# Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh ordered
+
+# Build as if we are in Buildkite, where each task gets its own group
+  $ BUILDKITE=1 ${TURBO} run build --force
+  \xe2\x80\xa2 Packages in scope: my-app, util (esc)
+  \xe2\x80\xa2 Running build in 2 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  --- my-app:build
+  my-app:build: cache bypass, force executing [0-9a-f]+ (re)
+  my-app:build: 
+  my-app:build: > build
+  my-app:build: > echo building && sleep 1 && echo done
+  my-app:build: 
+  my-app:build: building
+  my-app:build: done
+  --- util:build
+  util:build: cache bypass, force executing [0-9a-f]+ (re)
+  util:build: 
+  util:build: > build
+  util:build: > sleep 0.5 && echo building && sleep 1 && echo completed
+  util:build: 
+  util:build: building
+  util:build: completed
+  
+   Tasks:    2 successful, 2 total
+  Cached:    0 cached, 2 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
+
+# Build as if we are in GitLab CI, where each task gets a collapsed section
+  $ GITHUB_ACTIONS= BUILDKITE= GITLAB_CI=1 ${TURBO} run build --force --filter=util
+  \xe2\x80\xa2 Packages in scope: util (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  \x1b\[0Ksection_start:[0-9]+:util_build\[collapsed=true\]\r\x1b\[0Kutil:build (re)
+  util:build: cache bypass, force executing [0-9a-f]+ (re)
+  util:build: 
+  util:build: > build
+  util:build: > sleep 0.5 && echo building && sleep 1 && echo completed
+  util:build: 
+  util:build: building
+  util:build: completed
+  \x1b\[0Ksection_end:[0-9]+:util_build\r\x1b\[0K (re)
+  
+   Tasks:    1 successful, 1 total
+  Cached:    0 cached, 1 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
+
+# An explicit log order isn't changed
+  $ BUILDKITE=1 ${TURBO} run build --force --filter=util --log-order=stream
+  \xe2\x80\xa2 Packages in scope: util (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  util:build: cache bypass, force executing [0-9a-f]+ (re)
+  util:build: 
+  util:build: > build
+  util:build: > sleep 0.5 && echo building && sleep 1 && echo completed
+  util:build: 
+  util:build: building
+  util:build: completed
+  
+   Tasks:    1 successful, 1 total
+  Cached:    0 cached, 1 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
@@ -38,7 +38,7 @@ Setup
   build-app-a
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # Running again withuot `--log-prefix` should get a cache hit, but should print prefixes this time
@@ -54,14 +54,38 @@ Setup
   app-a:build: build-app-a
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 
+# Running with a template replaces the placeholders
+  $ ${TURBO} run build --log-prefix="template:[{package}] "
+  \xe2\x80\xa2 Packages in scope: app-a (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  [app-a] cache hit, replaying logs 612027951a2848ce
+  [app-a] 
+  [app-a] > build
+  [app-a] > echo build-app-a
+  [app-a] 
+  [app-a] build-app-a
+  
+   Tasks:    1 successful, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
+    Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
+  
+
+# Running with an unknown placeholder
+  $ ${TURBO} run build --log-prefix="template:{hash} "
+   ERROR  invalid value 'template:{hash} ' for '--log-prefix <LOG_PREFIX>': unknown placeholder {hash} in template, expected {package} or {task}
+  
+  For more information, try '--help'.
+  
+  [1]
+
 # Running with bogus option
   $ ${TURBO} run build --log-prefix=blah
-   ERROR  invalid value 'blah' for '--log-prefix <LOG_PREFIX>'
-    [possible values: auto, none, task]
+   ERROR  invalid value 'blah' for '--log-prefix <LOG_PREFIX>': expected auto, none, task, package, or template:<template>
   
   For more information, try '--help'.
   
@@ -70,7 +94,6 @@ Setup
 # Running with missing value for option
   $ ${TURBO} run build --log-prefix
    ERROR  a value is required for '--log-prefix <LOG_PREFIX>' but none was supplied
-    [possible values: auto, none, task]
   
   For more information, try '--help'.
   
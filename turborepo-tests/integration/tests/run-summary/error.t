@@ -78,6 +78,7 @@ Validate that we got a full task summary for the failed task with an error in .e
     "execution": {
       "startTime": [0-9]+, (re)
       "endTime": [0-9]+, (re)
+      "duration": [0-9]+, (re)
       "error": "command .*npm(?:\.cmd)? run maybefails exited \(1\)", (re)
       "exitCode": 1
     }
@@ -115,6 +116,7 @@ success should be 1, and attempted should be 2
   {
     "startTime": [0-9]+, (re)
     "endTime": [0-9]+, (re)
+    "duration": [0-9]+, (re)
     "error": "command .*npm(?:\.cmd)? run maybefails exited \(1\)", (re)
     "exitCode": 1
   }
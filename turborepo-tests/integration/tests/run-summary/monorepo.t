@@ -81,6 +81,7 @@ Setup
   {
     "startTime": [0-9]+, (re)
     "endTime": [0-9]+, (re)
+    "duration": [0-9]+, (re)
     "exitCode": 0
   }
   $ echo $FIRST_APP_BUILD | jq '.cliArguments'
@@ -115,6 +116,7 @@ Setup
   {
     "startTime": [0-9]+, (re)
     "endTime": [0-9]+, (re)
+    "duration": [0-9]+, (re)
     "exitCode": 0
   }
 
@@ -92,6 +92,7 @@ Check
   {
     "startTime": [0-9]+, (re)
     "endTime": [0-9]+, (re)
+    "duration": [0-9]+, (re)
     "exitCode": 0
   }
   $ echo $TASK_SUMMARY | jq '.cliArguments'
@@ -0,0 +1,23 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+
+Nothing is cached before the first run, and packages without a build script are uncacheable
+  $ ${TURBO} run build --check-cache-only
+  another#build => uncacheable
+  my-app#build => miss
+  util#build => miss
+  [1]
+
+After a run every cacheable task is a hit
+  $ ${TURBO} run build > /dev/null
+  $ ${TURBO} run build --check-cache-only
+  another#build => uncacheable
+  my-app#build => hit (local)
+  util#build => hit (local)
+
+Tasks with caching disabled are uncacheable and don't affect the exit code
+  $ echo '{"extends": ["//"], "tasks": {"build": {"cache": false}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --check-cache-only
+  another#build => uncacheable
+  my-app#build => hit (local)
+  util#build => uncacheable
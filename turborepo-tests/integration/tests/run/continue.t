@@ -86,3 +86,29 @@ Run with --continue
   
    ERROR  run failed: command  exited (1)
   [1]
+
+Run with --continue=dependencies-successful
+  $ ${TURBO} build --output-logs=errors-only --continue=dependencies-successful
+  \xe2\x80\xa2 Packages in scope: my-app, other-app, some-lib (esc)
+  \xe2\x80\xa2 Running build in 3 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  some-lib:build: cache miss, executing ab8c4a02e3facf55
+  some-lib:build: 
+  some-lib:build: > build
+  some-lib:build: > exit 2
+  some-lib:build: 
+  some-lib:build: npm ERR! Lifecycle script `build` failed with error: 
+  some-lib:build: npm ERR! Error: command failed 
+  some-lib:build: npm ERR!   in workspace: some-lib 
+  some-lib:build: npm ERR!   at location: (.*)(\/|\\)apps(\/|\\)some-lib  (re)
+  some-lib:build: command finished with error, but continuing...
+  some-lib#build: command \((.*)(\/|\\)apps(\/|\\)some-lib\) .*npm(?:\.cmd)? run build exited \(1\) (re)
+  
+    Tasks:    0 successful, 1 total
+   Cached:    0 cached, 1 total
+     Time:\s*[\.0-9]+m?s  (re)
+   Failed:    some-lib#build
+  Skipped:    my-app#build, other-app#build
+  
+   ERROR  run failed: command  exited (1)
+  [1]
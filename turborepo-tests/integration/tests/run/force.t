@@ -61,7 +61,7 @@ baseline to generate cache
   my-app:build: cache hit, suppressing logs 0555ce94ca234049
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # env var=true, --flag (no value): cache bypass
@@ -84,7 +84,7 @@ baseline to generate cache
   my-app:build: cache hit, suppressing logs 0555ce94ca234049
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # env var=false, --flag=true: cache bypass
@@ -106,7 +106,7 @@ baseline to generate cache
   my-app:build: cache hit, suppressing logs 0555ce94ca234049
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # env var=false, --flag (no value): cache bypass
@@ -129,7 +129,7 @@ baseline to generate cache
   my-app:build: cache hit, suppressing logs 0555ce94ca234049
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # missing env var, --flag=true: cache bypass
@@ -151,7 +151,7 @@ baseline to generate cache
   my-app:build: cache hit, suppressing logs 0555ce94ca234049
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 # missing env var, --flag (no value): cache bypass
@@ -165,3 +165,19 @@ baseline to generate cache
   Cached:    0 cached, 1 total
     Time:\s*[\.0-9]+m?s  (re)
   
+# TURBO_FORCE=1 forces every task
+  $ TURBO_FORCE=1 ${TURBO} run build --output-logs=hash-only --filter=my-app
+  \xe2\x80\xa2 Packages in scope: my-app (esc)
+  \xe2\x80\xa2 Running build in 1 packages (esc)
+  \xe2\x80\xa2 Remote caching disabled (esc)
+  my-app:build: cache bypass, force executing 0555ce94ca234049
+  
+   Tasks:    1 successful, 1 total
+  Cached:    0 cached, 1 total
+    Time:\s*[\.0-9]+m?s  (re)
+  
+# Forcing a task that isn't part of the run errors
+  $ ${TURBO} run build --filter=my-app --force=my-app#biuld > OUTPUT 2>&1
+  [1]
+  $ grep -o "\-\-force was given my-app#biuld, which isn't a task in this run" OUTPUT
+  --force was given my-app#biuld, which isn't a task in this run
@@ -32,7 +32,7 @@ Verify that input directory change causes cache miss
   util:build: cache hit, suppressing logs 1b809be951dcbf2e
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
   $ cat packages/util/dist/hello.txt
@@ -0,0 +1,26 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+
+Prints the hash of every task, sorted by task, without running them
+  $ ${TURBO} run build --hash-only
+  another#build => [0-9a-f]{16} (re)
+  my-app#build => [0-9a-f]{16} (re)
+  util#build => [0-9a-f]{16} (re)
+
+Nothing was written to the cache
+  $ ${TURBO} run build --check-cache-only --filter=my-app
+  my-app#build => miss
+  [1]
+
+The hashes are the ones used by a run
+  $ HASH=$(${TURBO} run build --hash-only --filter=my-app | sed 's/^my-app#build => //')
+  $ ${TURBO} run build --filter=my-app --output-logs=hash-only | grep -c "my-app:build: cache miss, executing $HASH"
+  1
+
+Only the hash of the task whose inputs changed is different
+  $ ${TURBO} run build --hash-only > ../before.txt
+  $ echo "changed" > packages/util/index.js
+  $ ${TURBO} run build --hash-only > ../after.txt
+  $ diff ../before.txt ../after.txt | grep '^[<>]' | cut -d' ' -f1-2
+  < util#build
+  > util#build
@@ -26,6 +26,11 @@ Setup
   
   [1]
 
+# Errors can be printed as JSON
+  $ ${TURBO} run doesnotexist --error-format=json
+  {"error":{"code":"missing_tasks","exitCode":2,"message":"missing tasks in project","related":[{"code":"missing_task","message":"could not find task `doesnotexist` in project"}]}}
+  [2]
+
 # Bad command
   $ ${TURBO} run something --dry > OUTPUT 2>&1
   [1]
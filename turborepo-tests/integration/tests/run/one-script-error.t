@@ -57,7 +57,7 @@ Make sure error isn't cached
   my-app#error: command \(.*apps(\/|\\)my-app\) (.*)npm(?:\.cmd)? run error exited \(1\) (re)
   
    Tasks:    1 successful, 2 total
-  Cached:    1 cached, 2 total
+  Cached:    1 cached, 2 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s  (re)
   Failed:    my-app#error
   
@@ -94,7 +94,7 @@ Make sure error code isn't swallowed with continue
   my-app#error: command \((.*)(\/|\\)apps(\/|\\)my-app\) (.*)npm(?:\.cmd)? run error exited \(1\) (re)
   
    Tasks:    2 successful, 3 total
-  Cached:    1 cached, 3 total
+  Cached:    1 cached, 3 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s  (re)
   Failed:    my-app#error
   
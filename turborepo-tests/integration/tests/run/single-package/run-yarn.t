@@ -5,7 +5,7 @@ Check
   $ ${TURBO} run build
   \xe2\x80\xa2 Running build (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache miss, executing a6fef17cc2efc81c
+  build: cache miss, executing f78705a3f86add05
   build: yarn run v1.22.17
   build: warning package.json: No license field
   build: $ echo building > foo.txt
@@ -18,13 +18,13 @@ Check
   $ ${TURBO} run build
   \xe2\x80\xa2 Running build (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache hit, replaying logs a6fef17cc2efc81c
+  build: cache hit, replaying logs f78705a3f86add05
   build: yarn run v1.22.17
   build: warning package.json: No license field
   build: $ echo building > foo.txt
   build: Done in \s*[\.0-9]+m?s\. (re)
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
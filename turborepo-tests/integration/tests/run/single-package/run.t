@@ -5,7 +5,7 @@ Check
   $ ${TURBO} run build
   \xe2\x80\xa2 Running build (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache miss, executing 7ece7b62aad25615
+  build: cache miss, executing 254363d40da29250
   build: 
   build: > build
   build: > echo building > foo.txt
@@ -22,13 +22,13 @@ Run a second time, verify caching works because there is a config
   $ ${TURBO} run build
   \xe2\x80\xa2 Running build (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache hit, replaying logs 7ece7b62aad25615
+  build: cache hit, replaying logs 254363d40da29250
   build: 
   build: > build
   build: > echo building > foo.txt
   build: 
   
    Tasks:    1 successful, 1 total
-  Cached:    1 cached, 1 total
+  Cached:    1 cached, 1 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
@@ -18,7 +18,7 @@ Check
   Tasks to Run
   build
     Task                           = build\s* (re)
-    Hash                           = 7ece7b62aad25615
+    Hash                           = 254363d40da29250
     Cached \(Local\)                 = false\s* (re)
     Cached \(Remote\)                = false\s* (re)
     Command                        = echo building > foo.txt\s* (re)
@@ -26,7 +26,7 @@ Check
     Log File                       = .turbo(\/|\\)turbo-build.log\s* (re)
     Dependencies                   =\s* (re)
     Dependents                     = test\s* (re)
-    Inputs Files Considered        = 5\s* (re)
+    Inputs Files Considered        = 4\s* (re)
     Env Vars                       = 
     Env Vars Values                = 
     Inferred Env Vars Values       = 
@@ -36,7 +36,7 @@ Check
     Framework                      = 
   test
     Task                           = test\s* (re)
-    Hash                           = cb5839f7284aa5f3
+    Hash                           = 3ee3e60d7556092b
     Cached \(Local\)                 = false\s* (re)
     Cached \(Remote\)                = false\s* (re)
     Command                        = cat foo.txt\s* (re)
@@ -44,7 +44,7 @@ Check
     Log File                       = .turbo(\/|\\)turbo-test.log\s* (re)
     Dependencies                   = build\s* (re)
     Dependents                     =\s* (re)
-    Inputs Files Considered        = 5\s* (re)
+    Inputs Files Considered        = 4\s* (re)
     Env Vars                       = 
     Env Vars Values                = 
     Inferred Env Vars Values       = 
@@ -5,12 +5,12 @@ Check
   $ ${TURBO} run test
   \xe2\x80\xa2 Running test (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache miss, executing 7ece7b62aad25615
+  build: cache miss, executing 254363d40da29250
   build: 
   build: > build
   build: > echo building > foo.txt
   build: 
-  test: cache miss, executing cb5839f7284aa5f3
+  test: cache miss, executing 3ee3e60d7556092b
   test: 
   test: > test
   test: > cat foo.txt
@@ -25,12 +25,12 @@ Run a second time, verify caching works because there is a config
   $ ${TURBO} run test
   \xe2\x80\xa2 Running test (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache hit, replaying logs 7ece7b62aad25615
+  build: cache hit, replaying logs 254363d40da29250
   build: 
   build: > build
   build: > echo building > foo.txt
   build: 
-  test: cache hit, replaying logs cb5839f7284aa5f3
+  test: cache hit, replaying logs 3ee3e60d7556092b
   test: 
   test: > test
   test: > cat foo.txt
@@ -38,18 +38,18 @@ Run a second time, verify caching works because there is a config
   test: building
   
    Tasks:    2 successful, 2 total
-  Cached:    2 cached, 2 total
+  Cached:    2 cached, 2 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 Run with --output-logs=hash-only
   $ ${TURBO} run test --output-logs=hash-only
   \xe2\x80\xa2 Running test (esc)
   \xe2\x80\xa2 Remote caching disabled (esc)
-  build: cache hit, suppressing logs 7ece7b62aad25615
-  test: cache hit, suppressing logs cb5839f7284aa5f3
+  build: cache hit, suppressing logs 254363d40da29250
+  test: cache hit, suppressing logs 3ee3e60d7556092b
   
    Tasks:    2 successful, 2 total
-  Cached:    2 cached, 2 total
+  Cached:    2 cached, 2 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 Run with --output-logs=errors-only
@@ -58,7 +58,7 @@ Run with --output-logs=errors-only
   \xe2\x80\xa2 Remote caching disabled (esc)
   
    Tasks:    2 successful, 2 total
-  Cached:    2 cached, 2 total
+  Cached:    2 cached, 2 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
 Run with --output-logs=none
@@ -67,6 +67,6 @@ Run with --output-logs=none
   \xe2\x80\xa2 Remote caching disabled (esc)
   
    Tasks:    2 successful, 2 total
-  Cached:    2 cached, 2 total
+  Cached:    2 cached, 2 total(, saved ~.+)? (re)
     Time:\s*[\.0-9]+m?s >>> FULL TURBO (re)
   
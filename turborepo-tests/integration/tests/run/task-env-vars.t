@@ -0,0 +1,29 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+  $ cat > packages/util/env.js <<SCRIPT
+  > const { TURBO_TASK, TURBO_PACKAGE, TURBO_IS_CACHE_WRITE_ENABLED, TURBO_RUN_ID } = process.env;
+  > console.log(\`task=\${TURBO_TASK} package=\${TURBO_PACKAGE} cache=\${TURBO_IS_CACHE_WRITE_ENABLED}\`);
+  > console.log(\`run=\${TURBO_RUN_ID}\`);
+  > SCRIPT
+  $ jq '.scripts.build = "node env.js"' packages/util/package.json > package.json.new
+  $ mv package.json.new packages/util/package.json
+  $ rm -rf .turbo/runs
+
+Tasks know which task and package they are, whether their outputs are cached and the id of the run
+  $ ${TURBO} run build --filter=util --summarize > out.txt 2>&1
+  $ grep "util:build: task=" out.txt
+  util:build: task=build package=util cache=true
+  $ RUN_ID=$(cat .turbo/runs/*.json | jq -r '.id')
+  $ grep "util:build: run=" out.txt | sed "s/$RUN_ID/<run id>/"
+  util:build: run=<run id>
+
+Cache writes are disabled by --no-cache
+  $ ${TURBO} run build --filter=util --no-cache --force > out.txt 2>&1
+  $ grep "util:build: task=" out.txt
+  util:build: task=build package=util cache=false
+
+And for tasks that don't cache
+  $ echo '{"extends": ["//"], "tasks": {"build": {"cache": false}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --filter=util > out.txt 2>&1
+  $ grep "util:build: task=" out.txt
+  util:build: task=build package=util cache=false
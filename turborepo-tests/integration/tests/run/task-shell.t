@@ -0,0 +1,28 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+  $ jq '.scripts.build = "node -e \"console.log(JSON.stringify(process.argv.slice(1)))\""' packages/util/package.json > package.json.new
+  $ mv package.json.new packages/util/package.json
+
+Arguments passed after -- are quoted for the shell that runs a runner command
+  $ echo '{"extends": ["//"], "tasks": {"build": {"runner": "{script}"}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --filter=util --output-logs=new-only -- "a b" "it's" | grep "util:build: \["
+  util:build: ["a b","it's"]
+
+Without a shell, the command is split into arguments and they're passed as is
+  $ echo '{"extends": ["//"], "tasks": {"build": {"runner": "{script}", "shell": false}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --filter=util --output-logs=new-only -- "a b" "it's" | grep "util:build: \["
+  util:build: ["a b","it's"]
+
+Changing the shell misses the cache
+  $ ${TURBO} run build --filter=util --output-logs=hash-only -- "a b" "it's" | grep "util:build"
+  util:build: cache hit, suppressing logs [0-9a-f]{16} (re)
+  $ echo '{"extends": ["//"], "tasks": {"build": {"runner": "{script}", "shell": {"unix": "bash", "windows": "cmd"}}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --filter=util --output-logs=hash-only -- "a b" "it's" | grep "util:build"
+  util:build: cache miss, executing [0-9a-f]{16} (re)
+
+Scripts run by the package manager can't set a shell
+  $ echo '{"extends": ["//"], "tasks": {"build": {"shell": false}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --filter=util > out.txt 2>&1
+  [1]
+  $ grep "no effect" out.txt
+  .*`shell` has no effect on util#build (re)
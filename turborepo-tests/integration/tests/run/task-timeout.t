@@ -0,0 +1,36 @@
+Setup
+  $ . ${TESTDIR}/../../../helpers/setup_integration_test.sh
+  $ jq '.scripts.build = "node -e \"setTimeout(() => {}, 30000)\""' packages/util/package.json > package.json.new
+  $ mv package.json.new packages/util/package.json
+  $ rm -rf .turbo/runs
+
+A task that runs for longer than --task-timeout is stopped and fails
+  $ ${TURBO} run build --filter=util --task-timeout=1s --summarize > out.txt 2>&1
+  [1]
+  $ grep "timed out" out.txt
+  util:build: ERROR: command \(.*packages(\/|\\)util\) .*npm(?:\.cmd)? run build timed out after 1s (re)
+  util#build: command \(.*packages(\/|\\)util\) .*npm(?:\.cmd)? run build timed out after 1s (re)
+  $ grep "Failed:" out.txt
+  Failed:    util#build
+
+The run summary records that the task timed out
+  $ cat .turbo/runs/*.json | jq '.tasks[0].execution | {exitCode, timedOut}'
+  {
+    "exitCode": null,
+    "timedOut": true
+  }
+
+The timeout can also be set in turbo.json
+  $ echo '{"extends": ["//"], "tasks": {"build": {"timeout": "1s"}}}' > packages/util/turbo.json
+  $ ${TURBO} run build --filter=util > out.txt 2>&1
+  [1]
+  $ grep "timed out" out.txt
+  util:build: ERROR: command \(.*packages(\/|\\)util\) .*npm(?:\.cmd)? run build timed out after 1s (re)
+  util#build: command \(.*packages(\/|\\)util\) .*npm(?:\.cmd)? run build timed out after 1s (re)
+
+--task-timeout overrides the timeout in turbo.json
+  $ ${TURBO} run build --filter=util --task-timeout=2s > out.txt 2>&1
+  [1]
+  $ grep "timed out" out.txt
+  util:build: ERROR: command \(.*packages(\/|\\)util\) .*npm(?:\.cmd)? run build timed out after 2s (re)
+  util#build: command \(.*packages(\/|\\)util\) .*npm(?:\.cmd)? run build timed out after 2s (re)
@@ -11,7 +11,7 @@ This tests asserts that root tasks can depend on workspace#task
   lib-a:build: > echo build-lib-a
   lib-a:build: 
   lib-a:build: build-lib-a
-  //:mytask: cache miss, executing 82474628cac2e34c
+  //:mytask: cache miss, executing 41271d667b9fd582
   //:mytask: 
   //:mytask: > mytask
   //:mytask: > echo root-mytask
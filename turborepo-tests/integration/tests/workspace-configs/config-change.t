@@ -3,13 +3,19 @@ Setup
 
 # 1. First run, check the hash
   $ ${TURBO} run config-change-task --filter=config-change --dry=json | jq .tasks[0].hash
-  "4fbfb44c77d14468"
+  "8707a441eaa23036"
 
 2. Run again and assert task hash stays the same
   $ ${TURBO} run config-change-task --filter=config-change --dry=json | jq .tasks[0].hash
-  "4fbfb44c77d14468"
+  "8707a441eaa23036"
 
-3. Change turbo.json and assert that hash changes
+3. Change another task in turbo.json and assert that hash stays the same
   $ cp $TARGET_DIR/apps/config-change/turbo-changed.json $TARGET_DIR/apps/config-change/turbo.json
   $ ${TURBO} run config-change-task --filter=config-change --dry=json | jq .tasks[0].hash
-  "01e59b7f88f8a211"
+  "8707a441eaa23036"
+
+4. Change the task itself in turbo.json and assert that hash changes
+  $ jq '.tasks["config-change-task"].outputs = ["dist/**"]' $TARGET_DIR/apps/config-change/turbo.json > turbo.json.new
+  $ mv turbo.json.new $TARGET_DIR/apps/config-change/turbo.json
+  $ ${TURBO} run config-change-task --filter=config-change --dry=json | jq .tasks[0].hash
+  "ee3cf1f31dccf17c"